@@ -27,6 +27,11 @@ func (df *dataflow) vn(n *Node) (retVal *Node, unique bool) {
 	enterLoggingContext()
 	defer leaveLoggingContext()
 
+	// stateful nodes are always unique
+	if n.isStateful() {
+		return n, true
+	}
+
 	node, ok := df.uniques[n.Hashcode()]
 
 	if ok {
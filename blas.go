@@ -3,12 +3,14 @@ package gorgonia
 import (
 	"sync"
 
+	tf32 "github.com/chewxy/gorgonia/tensor/f32"
 	tf64 "github.com/chewxy/gorgonia/tensor/f64"
 	"github.com/gonum/blas"
 	"github.com/gonum/blas/native"
 )
 
-var blasdoor sync.Mutex
+// blasdoor guards whichblas. linAlgBinOp holds the read lock while it multiplies, so a multiplication is carried out by one BLAS from start to end.
+var blasdoor sync.RWMutex
 var whichblas BLAS
 
 // BLAS represents all the possible implementations of BLAS.
@@ -34,6 +36,10 @@ type batchedBLAS interface {
 //		Use(cubone.Implementation())
 //		Use(cgo.Implementation)
 // Note the differences in the brackets. The blastoise and cubone ones are functions.
+//
+// The BLAS is used for both Float64 and Float32 by the linear algebra ops (Mul, BatchMatVecMul, OuterProd etc), and is process-wide.
+// Use is safe to call while graphs are being executed: it waits for the matrix multiplications in flight to finish,
+// and the ones that come after are carried out by the new BLAS. A tape machine checks if the BLAS batches its calls when it is created.
 func Use(b BLAS) {
 	// close the blast door! close the blast door!
 	blasdoor.Lock()
@@ -42,15 +48,16 @@ func Use(b BLAS) {
 	// those lines were few of the better additions to the Special Edition. There, I said it. The Special Edition is superior. Except Han still shot first in my mind.
 
 	whichblas = b
-	f64 := b.(blas.Float64)
-	tf64.Use(f64)
-
-	// TODO:
-	// float32
+	tf64.Use(b)
+	tf32.Use(b)
 }
 
 // WhichBLAS() returns the BLAS that gorgonia uses.
-func WhichBLAS() BLAS { return whichblas }
+func WhichBLAS() BLAS {
+	blasdoor.RLock()
+	defer blasdoor.RUnlock()
+	return whichblas
+}
 
 func init() {
 	whichblas = native.Implementation{}
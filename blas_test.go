@@ -0,0 +1,125 @@
+package gorgonia
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	tf32 "github.com/chewxy/gorgonia/tensor/f32"
+	tf64 "github.com/chewxy/gorgonia/tensor/f64"
+	"github.com/gonum/blas"
+	"github.com/gonum/blas/native"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingBLAS is a BLAS that counts the matrix-matrix and matrix-vector multiplications it carries out
+type countingBLAS struct {
+	native.Implementation
+	calls *int64
+}
+
+func (b countingBLAS) Dgemm(tA, tB blas.Transpose, m, n, k int, alpha float64, a []float64, lda int, bs []float64, ldb int, beta float64, c []float64, ldc int) {
+	atomic.AddInt64(b.calls, 1)
+	b.Implementation.Dgemm(tA, tB, m, n, k, alpha, a, lda, bs, ldb, beta, c, ldc)
+}
+
+func (b countingBLAS) Dgemv(tA blas.Transpose, m, n int, alpha float64, a []float64, lda int, x []float64, incX int, beta float64, y []float64, incY int) {
+	atomic.AddInt64(b.calls, 1)
+	b.Implementation.Dgemv(tA, m, n, alpha, a, lda, x, incX, beta, y, incY)
+}
+
+func (b countingBLAS) Sgemm(tA, tB blas.Transpose, m, n, k int, alpha float32, a []float32, lda int, bs []float32, ldb int, beta float32, c []float32, ldc int) {
+	atomic.AddInt64(b.calls, 1)
+	b.Implementation.Sgemm(tA, tB, m, n, k, alpha, a, lda, bs, ldb, beta, c, ldc)
+}
+
+func (b countingBLAS) Sgemv(tA blas.Transpose, m, n int, alpha float32, a []float32, lda int, x []float32, incX int, beta float32, y []float32, incY int) {
+	atomic.AddInt64(b.calls, 1)
+	b.Implementation.Sgemv(tA, m, n, alpha, a, lda, x, incX, beta, y, incY)
+}
+
+func TestUse(t *testing.T) {
+	assert := assert.New(t)
+
+	prev := WhichBLAS()
+	defer Use(prev)
+
+	as := []float64{1, 2, 3, 4, 5, 6}
+	bs := []float64{0.5, -1, 2, 0, 1, 3}
+	vs := []float64{1, -2, 0.5}
+
+	// runs A × B and A × v
+	run := func(dt Dtype) (mm, mv []float64) {
+		g := NewGraph()
+		var a, b, v *Node
+		if dt == Float32 {
+			a = NewMatrix(g, dt, WithName("a"), WithShape(2, 3), WithValue(tf32.NewTensor(tf32.WithBacking(f64sToF32s(as)), tf32.WithShape(2, 3))))
+			b = NewMatrix(g, dt, WithName("b"), WithShape(3, 2), WithValue(tf32.NewTensor(tf32.WithBacking(f64sToF32s(bs)), tf32.WithShape(3, 2))))
+			v = NewVector(g, dt, WithName("v"), WithShape(3), WithValue(tf32.NewTensor(tf32.WithBacking(f64sToF32s(vs)), tf32.WithShape(3))))
+		} else {
+			a = NewMatrix(g, dt, WithName("a"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(as), tf64.WithShape(2, 3))))
+			b = NewMatrix(g, dt, WithName("b"), WithShape(3, 2), WithValue(tf64.NewTensor(tf64.WithBacking(bs), tf64.WithShape(3, 2))))
+			v = NewVector(g, dt, WithName("v"), WithShape(3), WithValue(tf64.NewTensor(tf64.WithBacking(vs), tf64.WithShape(3))))
+		}
+		ab := Must(Mul(a, b))
+		av := Must(Mul(a, v))
+
+		m := NewLispMachine(g, ExecuteFwdOnly())
+		if err := m.RunAll(); err != nil {
+			t.Fatal(err)
+		}
+		if dt == Float32 {
+			return f32sToF64s(ab.Value().Data().([]float32)), f32sToF64s(av.Value().Data().([]float32))
+		}
+		return extractF64s(ab.Value()), extractF64s(av.Value())
+	}
+
+	for _, dt := range []Dtype{Float64, Float32} {
+		Use(native.Implementation{})
+		mm, mv := run(dt)
+
+		var calls int64
+		Use(countingBLAS{calls: &calls})
+		assert.Equal(countingBLAS{calls: &calls}, WhichBLAS())
+		mm2, mv2 := run(dt)
+		assert.Equal(int64(2), atomic.LoadInt64(&calls), "%v", dt)
+		assert.Equal(mm, mm2, "%v", dt)
+		assert.Equal(mv, mv2, "%v", dt)
+
+		// and back again
+		Use(native.Implementation{})
+		mm3, mv3 := run(dt)
+		assert.Equal(int64(2), atomic.LoadInt64(&calls), "%v", dt)
+		assert.Equal(mm, mm3, "%v", dt)
+		assert.Equal(mv, mv3, "%v", dt)
+	}
+
+	// switching while multiplying
+	a := FromTensor(tf64.NewTensor(tf64.WithBacking(as), tf64.WithShape(2, 3)))
+	b := FromTensor(tf64.NewTensor(tf64.WithBacking(bs), tf64.WithShape(3, 2)))
+	mm := linAlgBinOp{āBinaryOperator: matMulOperator}
+
+	var calls int64
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			ab, err := mm.Do(a, b)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			assert.Equal([]float64{7.5, 8, 18, 14}, extractF64s(ab))
+		}()
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				Use(countingBLAS{calls: &calls})
+				return
+			}
+			Use(native.Implementation{})
+		}(i)
+	}
+	wg.Wait()
+}
@@ -1,6 +1,9 @@
 package gorgonia
 
-import "github.com/pkg/errors"
+import (
+	"github.com/chewxy/gorgonia/tensor/types"
+	"github.com/pkg/errors"
+)
 
 const (
 	bcAllowableAxes = 4
@@ -98,3 +101,27 @@ func Broadcast(binOp ʘBinaryOperatorType, a, b *Node, pattern BroadcastPattern)
 	op := newElemBinOp(binOp, x, y)
 	return applyOp(op, x, y)
 }
+
+// BroadcastTo explicitly broadcasts n to the given shape, following the usual (NumPy) broadcasting rules: the shapes are aligned on their last axes,
+// and each axis of n has to be either 1 or the size of the corresponding axis of the shape. n may have fewer dimensions than the shape.
+// The gradient is summed back over the broadcast axes.
+//
+// For example, a (3, 1) column vector can be broadcast to (3, 4), and a (4) vector can be broadcast to (2, 3, 4).
+func BroadcastTo(n *Node, shape types.Shape) (retVal *Node, err error) {
+	if n.IsScalar() {
+		return nil, errors.Errorf("Cannot broadcast a scalar (%v). Use a tensor of shape (1) instead", n)
+	}
+
+	if _, err = broadcastAxes(n.shape, shape); err != nil {
+		return nil, err
+	}
+	if len(n.shape) == len(shape) && n.shape.Eq(shape) {
+		return n, nil
+	}
+
+	op := broadcastToOp{
+		from: n.shape.Clone(),
+		to:   shape.Clone(),
+	}
+	return applyOp(op, n)
+}
@@ -1,9 +1,11 @@
 package gorgonia
 
 import (
+	"math"
 	"testing"
 
 	tf64 "github.com/chewxy/gorgonia/tensor/f64"
+	"github.com/chewxy/gorgonia/tensor/types"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -78,3 +80,205 @@ func TestBroadcast2(t *testing.T) {
 	assert.Equal([]float64{100, 101, 102, 203, 204, 205}, extractF64s(z.Value()))
 
 }
+
+func TestBroadcastTo(t *testing.T) {
+	assert := assert.New(t)
+
+	ws := []float64{
+		1, 2, 3, 4,
+		5, 6, 7, 8,
+		-1, -2, -3, -4,
+	}
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		x := NewVector(g, Float64, WithName("x"), WithShape(3, 1), WithValue(tf64.NewTensor(tf64.WithBacking([]float64{1, 2, 3}), tf64.WithShape(3, 1))))
+		w := NewMatrix(g, Float64, WithName("w"), WithShape(3, 4), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(3, 4))))
+
+		b, err := BroadcastTo(x, types.Shape{3, 4})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(types.Shape{3, 4}, b.Shape())
+		cost := Must(Sum(Must(HadamardProd(b, w))))
+
+		if useTape {
+			if _, err = Grad(cost, x); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		correct := []float64{
+			1, 1, 1, 1,
+			2, 2, 2, 2,
+			3, 3, 3, 3,
+		}
+		assert.Equal(correct, extractF64s(b.Value()), "Tape %t", useTape)
+		assert.Equal(types.Shape{3, 4}, b.Value().Shape(), "Tape %t", useTape)
+
+		// the gradient is the sum of w across the columns
+		grad, err := x.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal([]float64{10, 26, -10}, extractF64s(grad), "Tape %t", useTape)
+		assert.Equal(types.Shape{3, 1}, grad.Shape(), "Tape %t", useTape)
+	}
+
+	// missing leading axes are added
+	op := broadcastToOp{from: types.Shape{1, 3}, to: types.Shape{2, 2, 3}}
+	v, err := op.Do(FromTensor(tf64.NewTensor(tf64.WithBacking([]float64{1, 2, 3}), tf64.WithShape(1, 3))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(types.Shape{2, 2, 3}, v.Shape())
+	assert.Equal([]float64{1, 2, 3, 1, 2, 3, 1, 2, 3, 1, 2, 3}, extractF64s(v))
+
+	// and summed over in the gradient
+	d, err := broadcastToDiffOp(op).Do(FromTensor(tf64.NewTensor(tf64.WithBacking(tf64.RangeFloat64(0, 12)), tf64.WithShape(2, 2, 3))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(types.Shape{1, 3}, d.Shape())
+	assert.Equal([]float64{18, 22, 26}, extractF64s(d))
+
+	// incompatible shapes
+	g := NewGraph()
+	x := NewMatrix(g, Float64, WithName("x"), WithShape(3, 2))
+	if _, err = BroadcastTo(x, types.Shape{3, 4}); err == nil {
+		t.Error("Expected an error broadcasting (3, 2) to (3, 4)")
+	}
+	if _, err = BroadcastTo(x, types.Shape{2}); err == nil {
+		t.Error("Expected an error broadcasting to fewer dimensions")
+	}
+	assert.Equal(x, Must(BroadcastTo(x, types.Shape{3, 2})))
+}
+
+func TestBroadcastPow(t *testing.T) {
+	assert := assert.New(t)
+
+	// the base has to be positive for the gradient of the exponent
+	bases := []float64{
+		0.5, 1, 2,
+		1.5, 3, 0.25,
+		2, 0.75, 1.25,
+		4, 2.5, 0.1,
+	}
+	exps := []float64{2, -0.5, 1.5}
+	ws := []float64{
+		1, -1, 2,
+		0.5, 3, -2,
+		-1, 1, 1,
+		2, -0.5, 0.25,
+	}
+
+	powRef := func(b, e []float64) (retVal float64) {
+		for i, v := range b {
+			retVal += ws[i] * math.Pow(v, e[i%3])
+		}
+		return
+	}
+	bc, ec := clonef64s(bases), clonef64s(exps)
+	correctDB := numericGrad(bc, func() float64 { return powRef(bc, ec) })
+	correctDE := numericGrad(ec, func() float64 { return powRef(bc, ec) })
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		b := NewMatrix(g, Float64, WithName("b"), WithShape(4, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(bases)), tf64.WithShape(4, 3))))
+		e := NewVector(g, Float64, WithName("e"), WithShape(3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(exps)), tf64.WithShape(3))))
+		w := NewMatrix(g, Float64, WithName("w"), WithShape(4, 3), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(4, 3))))
+
+		z, err := BroadcastPow(b, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(types.Shape{4, 3}, z.Shape())
+		var zV Value
+		Read(z, &zV)
+		cost := Must(Sum(Must(HadamardProd(z, w))))
+
+		if useTape {
+			if _, err = Grad(cost, b, e); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = NewTapeMachine(prog, locMap).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			if err = NewLispMachine(g).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		correct := make([]float64, len(bases))
+		for i, v := range bases {
+			correct[i] = math.Pow(v, exps[i%3])
+		}
+		assert.True(floatsClose(correct, extractF64s(zV)), "Tape %t: %v", useTape, zV)
+
+		db, err := b.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		de, err := e.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(correctDB, extractF64s(db)), "Tape %t. Expected %v. Got %v", useTape, correctDB, db)
+		assert.True(floatsClose(correctDE, extractF64s(de)), "Tape %t. Expected %v. Got %v", useTape, correctDE, de)
+		assert.Equal(types.Shape{3}, de.Shape(), "Tape %t", useTape)
+	}
+
+	// a constant exponent takes no gradient, so the base may be negative
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		x := NewVector(g, Float64, WithName("x"), WithShape(3), WithValue(tf64.NewTensor(tf64.WithBacking([]float64{-1, 2, -3}), tf64.WithShape(3))))
+		cost := Must(Sum(Must(Pow(x, NewConstant(2.0)))))
+		if useTape {
+			if _, err := Grad(cost, x); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = NewTapeMachine(prog, locMap, WithNaNWatch()).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else if err := NewLispMachine(g, WithNaNWatch()).RunAll(); err != nil {
+			t.Fatal(err)
+		}
+
+		dx, err := x.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose([]float64{-2, 4, -6}, extractF64s(dx)), "Tape %t: %v", useTape, dx)
+	}
+
+	g := NewGraph()
+	b := NewMatrix(g, Float64, WithName("b"), WithShape(4, 3))
+	if _, err := BroadcastPow(b, NewVector(g, Float64, WithName("e"), WithShape(4))); err == nil {
+		t.Error("Expected an error broadcasting a (4) exponent to a (4, 3) base")
+	}
+	if _, err := BroadcastPow(b, NewScalar(g, Float64, WithName("s"))); err == nil {
+		t.Error("Expected an error with a scalar exponent")
+	}
+}
@@ -129,14 +129,14 @@ func (ns Nodes) AllSameGraph() bool {
 
 	var g *ExprGraph
 	for _, n := range ns {
-		if !n.isConstant() {
+		if !n.isDetached() {
 			g = n.g
 			break
 		}
 	}
 
 	for _, n := range ns {
-		if n.g != g && !n.isConstant() {
+		if n.g != g && !n.isDetached() {
 			return false
 		}
 	}
@@ -305,7 +305,7 @@ func compileState(w io.Writer, g *ExprGraph, df *dataflow) {
 		row[6] = fmt.Sprintf("%d", n.children)
 
 		if n.op != nil {
-			row[1] = fmt.Sprintf("%s", n.op)
+			row[1] = opString(n.op)
 			overwrites := n.op.overwriteInput()
 			if overwrites >= 0 {
 				row[7] = fmt.Sprintf("%d", n.children[overwrites].ID())
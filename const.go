@@ -58,11 +58,15 @@ const (
 	subFail             = "Failed to carry Sub()"
 	addFail             = "Failed to carry Add()"
 	signFail            = "Failed to carry Sign()"
+	absFail             = "Failed to carry Abs()"
 	softplusFail        = "Failed to carry Softplus()"
+	sumFail             = "Failed to carry Sum()"
 	incrErr             = "increment couldn't be done. Safe op was performed instead"
 	bindFail            = "Failed to bind"
 	anyToValueFail      = "Failed to convert %v(%T) into a Value"
 	dtypeExtractionFail = "Failed to extract dtype from %v"
+	dtypeMismatch       = "Dtype mismatch. Expected %v. Got %v instead"
+	invalidAxis         = "Invalid axis %d for a tensor with %d dims"
 	operationError      = "Operation failed"
 	doFail              = "Doing %v failed"
 	unsafeDoFail        = "UnsafeDoing %v failed."
@@ -88,6 +92,9 @@ var (
 	ln2f64   = NewConstant(math.Ln2)
 	ln2f32   = NewConstant(float32(math.Ln2))
 
+	negHalff64 = NewConstant(float64(-0.5))
+	negHalff32 = NewConstant(float32(-0.5))
+
 	onef32ConstOp  = onef32.op.(constant)
 	onef64ConstOp  = onef64.op.(constant)
 	zerof32ConstOp = zerof32.op.(constant)
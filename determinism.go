@@ -0,0 +1,67 @@
+package gorgonia
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gonum/blas/native"
+)
+
+// defaultSeed is the seed the random number generators start from when execution is deterministic
+const defaultSeed int64 = 1337
+
+var determinism = struct {
+	sync.Mutex
+	on       bool
+	seed     int64 // the next seed handed out to a random number generator
+	prevBLAS BLAS  // the BLAS in use before SetDeterministic(true) was called
+}{}
+
+// SetDeterministic turns the deterministic execution mode on or off. It is off by default.
+//
+// When it is on, running the same graph on the same inputs gives bit-identical results every time (and across processes), at some cost in speed.
+// These are the things that are affected:
+//		sumOp (Sum, Mean and everything built on them): the elements are always summed sequentially, in row-major order.
+//		randomOp (Dropout, UniformRandomNode, GaussianRandomNode, BinomialRandomNode) and the weight initializers
+//			(Gaussian64, Uniform64, GlorotEtAlN64, HeEtAlU64 etc): the random number generators are seeded from a fixed sequence instead of the clock.
+//			The sequence restarts every time SetDeterministic(true) is called.
+//		linAlgBinOp (Mul, OuterProd etc): Gonum's native BLAS is used. It computes each element of a matrix multiplication on a single goroutine,
+//			so the result does not depend on the number of threads. The BLAS set with Use() is restored when the mode is turned off.
+//
+// Calling Use() while the deterministic mode is on will replace the native BLAS.
+func SetDeterministic(on bool) {
+	determinism.Lock()
+	defer determinism.Unlock()
+
+	if on {
+		determinism.seed = defaultSeed
+		if !determinism.on {
+			determinism.prevBLAS = WhichBLAS()
+			Use(native.Implementation{})
+		}
+	} else if determinism.on {
+		Use(determinism.prevBLAS)
+		determinism.prevBLAS = nil
+	}
+	determinism.on = on
+}
+
+// Deterministic returns true if the deterministic execution mode is on. See SetDeterministic.
+func Deterministic() bool {
+	determinism.Lock()
+	defer determinism.Unlock()
+	return determinism.on
+}
+
+// randomSeed returns the seed for a new random number generator: the time, or the next seed in the fixed sequence when execution is deterministic.
+func randomSeed() int64 {
+	determinism.Lock()
+	defer determinism.Unlock()
+
+	if !determinism.on {
+		return time.Now().UnixNano()
+	}
+	seed := determinism.seed
+	determinism.seed++
+	return seed
+}
@@ -0,0 +1,63 @@
+package gorgonia
+
+import (
+	"math"
+	"testing"
+
+	tf64 "github.com/chewxy/gorgonia/tensor/f64"
+	"github.com/gonum/blas/native"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetDeterministic(t *testing.T) {
+	assert := assert.New(t)
+
+	SetDeterministic(true)
+	defer SetDeterministic(false)
+	assert.True(Deterministic())
+	assert.Equal(native.Implementation{}, WhichBLAS())
+
+	// a large reduction, computed twice
+	backing := Gaussian64(0, 1000, 1<<20)
+	sums := make([]float64, 2)
+	for i := range sums {
+		g := NewGraph()
+		x := NewMatrix(g, Float64, WithName("x"), WithShape(1<<10, 1<<10), WithValue(tf64.NewTensor(tf64.WithBacking(backing), tf64.WithShape(1<<10, 1<<10))))
+		sum := Must(Sum(x))
+
+		prog, locMap, err := Compile(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := NewTapeMachine(prog, locMap)
+		if err = m.RunAll(); err != nil {
+			t.Fatal(err)
+		}
+		sums[i] = extractF64(sum.Value())
+	}
+	assert.Equal(math.Float64bits(sums[0]), math.Float64bits(sums[1]))
+
+	var seq float64
+	for _, v := range backing {
+		seq += v
+	}
+	assert.Equal(math.Float64bits(seq), math.Float64bits(sums[0]))
+
+	// random numbers are drawn from the same seeds once the mode is turned on again
+	SetDeterministic(true)
+	assert.Equal(backing, Gaussian64(0, 1000, 1<<20))
+
+	SetDeterministic(true)
+	g := NewGraph()
+	r := UniformRandomNode(g, Float64, 0, 1, 2, 3)
+	m := NewLispMachine(g, ExecuteFwdOnly())
+	if err := m.RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	SetDeterministic(true)
+	assert.Equal(Uniform64(0, 1, 2, 3), extractF64s(r.Value()))
+
+	SetDeterministic(false)
+	assert.False(Deterministic())
+	assert.Equal(native.Implementation{}, WhichBLAS())
+}
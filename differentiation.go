@@ -1,12 +1,16 @@
 package gorgonia
 
-import "github.com/pkg/errors"
+import (
+	tf32 "github.com/chewxy/gorgonia/tensor/f32"
+	tf64 "github.com/chewxy/gorgonia/tensor/f64"
+	"github.com/pkg/errors"
+)
 
 /*
 This file holds code for symbolic differentiation.
 The purpose of the symbolic differentiation is to analyze and prepare the nodes for automatic differentiation.
 
-The main function that does all the magic is in Backpropagate().
+The main function that does all the magic is in Backpropagate(). JVP() does the same in forward mode.
 
 
 see also: http://colah.github.io/posts/2015-08-Backprop/
@@ -278,6 +282,12 @@ func Backpropagate(outputs, gradOutputs, wrt Nodes) (retVal Nodes, err error) {
 				differentiable := diffs[i]
 				childGrad := childrenGrads[i]
 
+				if differentiable && childGrad == nil {
+					// ops may leave out the gradients of their constant inputs, which nothing needs
+					symdiffLogf("Child %x takes no gradient", child.ID())
+					continue
+				}
+
 				if differentiable {
 					// node.derives = append(node.derives, childGrad)
 					childGrad.setGroup(gradClust)
@@ -310,3 +320,192 @@ func Backpropagate(outputs, gradOutputs, wrt Nodes) (retVal Nodes, err error) {
 	}
 	return
 }
+
+// JVP builds the Jacobian-vector product of output with regards to inputs, along the direction v: the directional derivative
+//		Σᵢ ∂output/∂inputᵢ · vᵢ
+// It is computed in forward mode, by pushing a tangent (the vᵢ to start with) through every op between the inputs and the output, so the Jacobian is never materialized.
+// The result has the shape of output, and is computed along with output when the graph is run. Each vᵢ must have the shape of inputᵢ.
+//
+// The tangents are pushed through elementwise ops with their differentiation expressions, as their Jacobians are diagonal,
+// and through linear and bilinear ops (sums, reshapes, transposes, slices, broadcasts and matrix multiplications) by applying the op to the tangents.
+// Other ops return an error.
+func JVP(output *Node, inputs, v Nodes) (retVal *Node, err error) {
+	if len(inputs) != len(v) {
+		return nil, errors.Errorf("Expected a direction for each of the %d inputs. Got %d instead", len(inputs), len(v))
+	}
+	for i, in := range inputs {
+		if !in.shape.Eq(v[i].shape) {
+			return nil, errors.Errorf("Expected the direction of %v to be shaped %v. Got %v instead", in, in.shape, v[i].shape)
+		}
+	}
+
+	g := output.g
+	var sortedNodes Nodes
+	if sortedNodes, err = Sort(g); err != nil {
+		return nil, errors.Wrap(err, sortFail)
+	}
+
+	var affectsOutput, affectedByInputs NodeSet
+	if affectsOutput, err = forwardDiffAnalysis(Nodes{output}, sortedNodes); err != nil {
+		return nil, errors.Wrap(err, "Failed during forward differentiation analysis")
+	}
+	if affectedByInputs, err = backwardDiffAnalysis(inputs, sortedNodes); err != nil {
+		return nil, errors.Wrap(err, "Failed during backward differentiation analysis")
+	}
+	activeNodes := affectsOutput.Intersect(affectedByInputs)
+
+	tangents := make(map[*Node]*Node)
+	for i, in := range inputs {
+		if t, ok := tangents[in]; ok {
+			if tangents[in], err = Add(t, v[i]); err != nil {
+				return nil, errors.Wrap(err, operationError)
+			}
+			continue
+		}
+		tangents[in] = v[i]
+	}
+
+	// the sorted nodes start from the roots, so the tangents are pushed through them in reverse
+	for i := len(sortedNodes) - 1; i >= 0; i-- {
+		n := sortedNodes[i]
+		if _, ok := activeNodes[n]; !ok || n.isInput() {
+			continue
+		}
+		if _, ok := tangents[n]; ok {
+			continue
+		}
+
+		childTangents := make(Nodes, len(n.children))
+		var moving bool
+		for j, child := range n.children {
+			childTangents[j] = tangents[child]
+			moving = moving || childTangents[j] != nil
+		}
+		if !moving {
+			continue
+		}
+
+		var t *Node
+		if t, err = pushTangent(n, childTangents); err != nil {
+			return nil, errors.Wrapf(err, "Unable to push the tangents through %v", n)
+		}
+		if t != nil {
+			tangents[n] = t
+		}
+	}
+
+	if retVal = tangents[output]; retVal == nil {
+		return nil, errors.Errorf("%v does not depend on the inputs %v", output, inputs)
+	}
+	return
+}
+
+// pushTangent returns the tangent of n, given the tangents of its children. A nil tangent is a tangent of zeros.
+func pushTangent(n *Node, tangents Nodes) (retVal *Node, err error) {
+	diffs := n.op.DiffWRT(len(n.children))
+	for i := range tangents {
+		if !diffs[i] {
+			tangents[i] = nil
+		}
+	}
+
+	switch op := n.op.(type) {
+	case elemUnaryOp:
+		if tangents[0] == nil {
+			return nil, nil
+		}
+		return ʘUnaryOpDiffExprs[op.unaryOpType()](n.children[0], n, tangents[0])
+
+	case elemBinOp:
+		// each term is the derivative of n with regards to one child, along the tangent of the child
+		x, y := n.children[0], n.children[1]
+		for i, t := range tangents {
+			if t == nil {
+				continue
+			}
+
+			var terms Nodes
+			if terms, err = ʘBinOpDiffExprs[op.binOpType()](x, y, n, t); err != nil {
+				return nil, err
+			}
+			if retVal, err = addTangents(retVal, terms[i]); err != nil {
+				return nil, err
+			}
+		}
+		return broadcastTangent(n, retVal)
+
+	case addScalarOp:
+		for _, t := range tangents {
+			if retVal, err = addTangents(retVal, t); err != nil {
+				return nil, err
+			}
+		}
+		return broadcastTangent(n, retVal)
+
+	case linAlgBinOp:
+		// (x + ẋ) × (y + ẏ) = x × y + ẋ × y + x × ẏ + ẋ × ẏ, and the last term is of the second order
+		x, y := n.children[0], n.children[1]
+		var t *Node
+		if tangents[0] != nil {
+			if t, err = binOpNode(op, tangents[0], y); err != nil {
+				return nil, errors.Wrapf(err, binOpNodeFail, op)
+			}
+			retVal = t
+		}
+		if tangents[1] != nil {
+			if t, err = binOpNode(op, x, tangents[1]); err != nil {
+				return nil, errors.Wrapf(err, binOpNodeFail, op)
+			}
+			if retVal, err = addTangents(retVal, t); err != nil {
+				return nil, err
+			}
+		}
+		return
+
+	case sumOp, reshapeOp, transposeOp, sliceOp, broadcastToOp, repeatOp:
+		// these are linear in their first child, and the rest of their children (if any) are not differentiable
+		if tangents[0] == nil {
+			return nil, nil
+		}
+		children := append(Nodes{tangents[0]}, n.children[1:]...)
+		if retVal, err = applyOp(op, children...); err != nil {
+			return nil, errors.Wrap(err, applyOpFail)
+		}
+		return
+	}
+	return nil, errors.Errorf("JVP does not know how to push tangents through %v", opString(n.op))
+}
+
+// addTangents adds two tangents, either of which may be nil
+func addTangents(a, b *Node) (*Node, error) {
+	switch {
+	case a == nil:
+		return b, nil
+	case b == nil:
+		return a, nil
+	}
+	return Add(a, b)
+}
+
+// broadcastTangent turns the scalar tangent of a node that is not a scalar (the tangent of b in a + b, where b is a scalar) into a tensor shaped like the node
+func broadcastTangent(n, t *Node) (retVal *Node, err error) {
+	if t == nil || !t.IsScalar() || n.IsScalar() {
+		return t, nil
+	}
+
+	var dt Dtype
+	if dt, err = dtypeOf(n.t); err != nil {
+		return nil, err
+	}
+
+	var zeros *Node
+	switch dt {
+	case Float64:
+		zeros = NewConstant(tf64.NewTensor(tf64.WithShape(n.shape.Clone()...)))
+	case Float32:
+		zeros = NewConstant(tf32.NewTensor(tf32.WithShape(n.shape.Clone()...)))
+	default:
+		return nil, errors.Errorf(nyiFail, "broadcastTangent", dt)
+	}
+	return Add(zeros, t)
+}
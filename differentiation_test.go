@@ -3,6 +3,7 @@ package gorgonia
 import (
 	"testing"
 
+	tf64 "github.com/chewxy/gorgonia/tensor/f64"
 	"github.com/gonum/graph/topo"
 	"github.com/stretchr/testify/assert"
 )
@@ -159,3 +160,104 @@ func TestCompoundOpDiff(t *testing.T) {
 	}
 
 }
+
+func TestJVP(t *testing.T) {
+	assert := assert.New(t)
+
+	xs := []float64{0.5, -1, 2, 0.25, 1.5, -0.75}
+	ws := []float64{0.2, -0.4, 1, 0.3, -0.5, 0.6}
+	bs := 0.1
+	vxs := []float64{1, 0.5, -1, 2, 0, -0.5}
+	vws := []float64{-0.3, 0.2, 0.7, -1, 0.4, 0.1}
+	vbs := 0.8
+
+	// out = mean(tanh(x × w + b)² ⊙ (x × w)ᵀ, along the rows)
+	build := func(x, w []float64, b float64) (g *ExprGraph, xn, wn, bn, out *Node) {
+		g = NewGraph()
+		xn = NewMatrix(g, Float64, WithName("x"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(x)), tf64.WithShape(2, 3))))
+		wn = NewMatrix(g, Float64, WithName("w"), WithShape(3, 2), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(w)), tf64.WithShape(3, 2))))
+		bn = NewScalar(g, Float64, WithName("b"), WithValue(b))
+		h := Must(Tanh(Must(Add(Must(Mul(xn, wn)), bn))))
+		out = Must(Mean(Must(HadamardProd(Must(Square(h)), Must(Transpose(Must(Mul(xn, wn)))))), 1))
+		return
+	}
+
+	eval := func(x, w []float64, b float64) []float64 {
+		_, _, _, _, out := build(x, w, b)
+		if err := NewLispMachine(out.g, ExecuteFwdOnly()).RunAll(); err != nil {
+			t.Fatal(err)
+		}
+		return clonef64s(extractF64s(out.Value()))
+	}
+
+	// central differences along the direction
+	const eps = 1e-6
+	step := func(a, v []float64, s float64) []float64 {
+		retVal := clonef64s(a)
+		for i := range retVal {
+			retVal[i] += s * v[i]
+		}
+		return retVal
+	}
+	plus := eval(step(xs, vxs, eps), step(ws, vws, eps), bs+eps*vbs)
+	minus := eval(step(xs, vxs, -eps), step(ws, vws, -eps), bs-eps*vbs)
+	correct := make([]float64, len(plus))
+	for i := range correct {
+		correct[i] = (plus[i] - minus[i]) / (2 * eps)
+	}
+
+	for _, useTape := range []bool{true, false} {
+		g, x, w, b, out := build(xs, ws, bs)
+		vx := NewMatrix(g, Float64, WithName("vx"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(vxs)), tf64.WithShape(2, 3))))
+		vw := NewMatrix(g, Float64, WithName("vw"), WithShape(3, 2), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(vws)), tf64.WithShape(3, 2))))
+		vb := NewScalar(g, Float64, WithName("vb"), WithValue(vbs))
+
+		jvp, err := JVP(out, Nodes{x, w, b}, Nodes{vx, vw, vb})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(out.Shape(), jvp.Shape())
+
+		if useTape {
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = NewTapeMachine(prog, locMap).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else if err = NewLispMachine(g, ExecuteFwdOnly()).RunAll(); err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(correct, extractF64s(jvp.Value())), "Tape %t. Expected %v. Got %v", useTape, correct, jvp.Value())
+	}
+
+	// along one input only, with the others held still
+	g, x, _, _, out := build(xs, ws, bs)
+	vx := NewMatrix(g, Float64, WithName("vx"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(vxs)), tf64.WithShape(2, 3))))
+	jvp, err := JVP(out, Nodes{x}, Nodes{vx})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = NewLispMachine(g, ExecuteFwdOnly()).RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	plus = eval(step(xs, vxs, eps), ws, bs)
+	minus = eval(step(xs, vxs, -eps), ws, bs)
+	for i := range correct {
+		correct[i] = (plus[i] - minus[i]) / (2 * eps)
+	}
+	assert.True(floatsClose(correct, extractF64s(jvp.Value())), "Expected %v. Got %v", correct, jvp.Value())
+
+	// bad directions and ops that cannot be pushed through
+	if _, err = JVP(out, Nodes{x}, Nodes{vx, vx}); err == nil {
+		t.Error("Expected an error with more directions than inputs")
+	}
+	if _, err = JVP(out, Nodes{x}, Nodes{NewVector(g, Float64, WithName("v"), WithShape(6))}); err == nil {
+		t.Error("Expected an error with a direction of the wrong shape")
+	}
+	m := Must(Max(x, 1))
+	if _, err = JVP(m, Nodes{x}, Nodes{vx}); err == nil {
+		t.Error("Expected an error when pushing tangents through Max")
+	}
+}
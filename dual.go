@@ -148,6 +148,8 @@ func constantDV(val Value) *dualValue {
 			d = NewScalarValue(float32(0.0))
 		case Int:
 			d = NewScalarValue(int(0))
+		case Bool:
+			d = NewScalarValue(false)
 		default:
 			panic(fmt.Sprintf("Scalar of type %v not yet handled", v.t))
 		}
@@ -179,6 +181,8 @@ func variableDV(val Value) *dualValue {
 			d = NewScalarValue(float32(1.0))
 		case Int:
 			d = NewScalarValue(int(1))
+		case Bool:
+			d = NewScalarValue(true)
 		default:
 			panic(fmt.Sprintf("Scalar of type %v not yet handled", v.t))
 		}
@@ -307,6 +311,8 @@ func dvBindVar0(op Op, retVal *dualValue, inputs []*dualValue) (err error) {
 			err = v.SetAll(float32(1.0))
 		case Int:
 			err = v.SetAll(int(1))
+		case Bool:
+			err = v.SetAll(true)
 		default:
 			panic(fmt.Sprintf("Tensor of type %v not yet handled", v.Dtype()))
 		}
@@ -319,6 +325,8 @@ func dvBindVar0(op Op, retVal *dualValue, inputs []*dualValue) (err error) {
 			d = NewScalarValue(float32(1.0))
 		case Int:
 			d = NewScalarValue(int(1))
+		case Bool:
+			d = NewScalarValue(true)
 		default:
 			panic(fmt.Sprintf("Scalar of type %v not yet handled", v.t))
 		}
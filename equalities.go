@@ -59,6 +59,12 @@ func constEq(a, b constant) (ok bool) {
 			return
 		}
 		return reflect.DeepEqual(at, bt)
+	case positionalEncodingOp:
+		var bt positionalEncodingOp
+		if bt, ok = b.(positionalEncodingOp); !ok {
+			return
+		}
+		return at.seqLen == bt.seqLen && at.dModel == bt.dModel && at.dt == bt.dt
 	default:
 		panic("Not yet implemented")
 	}
@@ -71,6 +77,11 @@ func nodeEq(a, b *Node) bool {
 		return true
 	}
 
+	// nodes with hidden state are only ever equal to themselves
+	if a.isStateful() || b.isStateful() {
+		return false
+	}
+
 	if a.isInput() {
 		if !b.isInput() {
 			return false
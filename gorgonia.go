@@ -138,6 +138,17 @@ func NewConstant(v interface{}, opts ...NodeConsOpt) *Node {
 	return newNode(consOpts...)
 }
 
+// NewConstantTensor makes a constant node out of a tensor, such as a fixed mask or a lookup table. It is NewConstant for tensors, but the node is named
+// after the shape of the tensor instead of its elements, which may be many. Pass WithName to name it otherwise.
+//
+// Constants have no gradient: backpropagation stops at them, while the ops that use them still differentiate wrt their other inputs.
+// They take part in FoldConstants like any other constant.
+func NewConstantTensor(value types.Tensor, opts ...NodeConsOpt) *Node {
+	name := fmt.Sprintf("const %v%v", dtypeToDtype(value.Dtype()), value.Shape())
+	consOpts := append([]NodeConsOpt{WithName(name)}, opts...)
+	return NewConstant(value, consOpts...)
+}
+
 // UniformRandomNode creates an input node that has a random op so everytime the node is passed, random values will be plucked from
 // a uniform distribution. The type of the node depends on the
 // shape passed in. To get a scalar value at run time, don't pass in any shapes
@@ -195,6 +206,32 @@ func BinomialRandomNode(g *ExprGraph, dt Dtype, trials, prob float64, shape ...i
 	return retVal
 }
 
+// GaussianNoise creates a node that draws fresh values from a gaussian distribution with the mean and stdev provided every time it is evaluated.
+// The values are drawn from a random number generator seeded with seed, so the same seed gives the same sequence of values from one run to the next.
+// Pass in an empty shape to get a scalar.
+//
+// Like a constant, the node belongs to no graph until it is used, and it is not differentiable. Unlike a constant, it is never folded into a fixed value. Its Value() is the last value it drew.
+func GaussianNoise(dt Dtype, shape types.Shape, mean, stdev float64, seed int64, opts ...NodeConsOpt) *Node {
+	return newNoiseNode(makeNoiseOp(gaussian, dt, mean, stdev, seed, shape), opts...)
+}
+
+// UniformNoise creates a node that draws fresh values from a uniform distribution between [low, high) every time it is evaluated.
+// See GaussianNoise.
+func UniformNoise(dt Dtype, shape types.Shape, low, high float64, seed int64, opts ...NodeConsOpt) *Node {
+	return newNoiseNode(makeNoiseOp(uniform, dt, low, high, seed, shape), opts...)
+}
+
+func newNoiseNode(op noiseOp, opts ...NodeConsOpt) *Node {
+	s := op.shape
+	if s.IsScalar() {
+		s = scalarShape
+	}
+
+	consOpts := []NodeConsOpt{withOp(op), withType(op.Type()), WithName(op.String()), WithShape(s...)}
+	consOpts = append(consOpts, opts...)
+	return newNode(consOpts...)
+}
+
 // OneHotVector creates a node representing a one hot vector
 func OneHotVector(id, classes int, t Dtype, opts ...NodeConsOpt) *Node {
 	switch t {
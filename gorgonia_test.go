@@ -39,6 +39,80 @@ func TestNewConstant(t *testing.T) {
 	assert.Equal(expectedType, cs.t)
 }
 
+func TestNewConstantTensor(t *testing.T) {
+	assert := assert.New(t)
+
+	xs := []float64{1, 2, 3, 4, 5, 6}
+	mask := []float64{1, 0, 1, 0, 0, 1}
+	ws := []float64{1, -1, 2, 0.5, 3, -2}
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		x := NewMatrix(g, Float64, WithName("x"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(2, 3))))
+		w := NewMatrix(g, Float64, WithName("w"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(2, 3))))
+		m := g.AddNode(NewConstantTensor(tf64.NewTensor(tf64.WithBacking(mask), tf64.WithShape(2, 3))))
+		assert.True(m.isConstant())
+		assert.Equal("const Float64(2, 3)", m.name)
+
+		masked := Must(HadamardProd(x, m))
+		cost := Must(Sum(Must(HadamardProd(masked, w))))
+		if useTape {
+			grads, err := Grad(cost, x)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(1, len(grads))
+
+			// the mask is not differentiated
+			if _, err = Grad(cost, m); err == nil {
+				t.Error("Expected an error differentiating wrt a constant")
+			}
+
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = NewTapeMachine(prog, locMap).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+			_, err = m.Grad()
+			assert.NotNil(err, "Tape: expected the mask to have no gradient")
+		} else {
+			if err := NewLispMachine(g).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+			// the lisp machine gives every node a dual value, but nothing is added to the one of the mask
+			dm, err := m.Grad()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(make([]float64, 6), extractF64s(dm))
+		}
+
+		dx, err := x.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal([]float64{1, 0, 2, 0, 0, -2}, extractF64s(dx), "Tape %t", useTape)
+		assert.Equal(mask, extractF64s(m.Value()), "Tape %t: the mask was changed", useTape)
+	}
+
+	// constant tensors are folded like the rest
+	g := NewGraph()
+	a := g.AddNode(NewConstantTensor(tf64.NewTensor(tf64.WithBacking([]float64{1, 2}), tf64.WithShape(2))))
+	b := g.AddNode(NewConstantTensor(tf64.NewTensor(tf64.WithBacking([]float64{3, 4}), tf64.WithShape(2)), WithName("b")))
+	assert.Equal("b", b.name)
+	sum := Must(Add(a, b))
+	if err := FoldConstants(g); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(1, len(g.AllNodes()))
+	folded := g.AllNodes()[0]
+	assert.True(folded.isConstant())
+	assert.Equal([]float64{4, 6}, folded.op.(constant).Value().Data())
+	_ = sum
+}
+
 var anyNodeTest = []struct {
 	name string
 	any  interface{}
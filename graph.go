@@ -25,6 +25,8 @@ type ExprGraph struct {
 	leaves    Nodes
 	constants Nodes
 	roots     Nodes
+
+	pool *bufferPool // temporaries of the ops that run on the graph
 }
 
 type graphconopt func(g *ExprGraph)
@@ -46,6 +48,8 @@ func NewGraph(opts ...graphconopt) *ExprGraph {
 
 		leaves:    make(Nodes, 0),
 		constants: make(Nodes, 0),
+
+		pool: new(bufferPool),
 	}
 
 	for _, opt := range opts {
@@ -90,7 +94,7 @@ func (g *ExprGraph) AddNode(n *Node) (retVal *Node) {
 		return existing
 	}
 
-	if n.isConstant() {
+	if n.isDetached() {
 		n = n.clone()
 		n.g = g
 	}
@@ -493,6 +497,8 @@ func (g *ExprGraph) subgraph(ns Nodes, opts ...Nodes) *ExprGraph {
 		leaves:    g.leaves,
 		constants: g.constants,
 		roots:     roots,
+
+		pool: g.pool,
 	}
 
 	return retVal
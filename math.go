@@ -33,6 +33,9 @@ func _cubef32(x float32) float32 { return x * x * x }
 func _negf32(x float32) float32 { return -x }
 func _negf64(x float64) float64 { return -x }
 
+func _rsqrtf64(x float64) float64 { return 1 / math.Sqrt(x) }
+func _rsqrtf32(x float32) float32 { return 1 / math32.Sqrt(x) }
+
 /* TODO: write optimized versions of these */
 
 func _sigmoidf64(x float64) float64 {
@@ -87,3 +90,22 @@ func _softplusf32(x float32) float32 {
 	}
 	return float32(math.Log1p(math.Exp(float64(x))))
 }
+
+/* LOGICAL OPS */
+
+// non zero values are considered true
+func _notf64(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return 0
+}
+
+func _notf32(x float32) float32 {
+	if x == 0 {
+		return 1
+	}
+	return 0
+}
+
+func _notb(x bool) bool { return !x }
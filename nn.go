@@ -2,7 +2,9 @@ package gorgonia
 
 import (
 	"fmt"
+	"math"
 
+	"github.com/chewxy/gorgonia/tensor/types"
 	"github.com/pkg/errors"
 )
 
@@ -125,9 +127,370 @@ func Rectify(x *Node) (retVal *Node, err error) {
 	cmp := newElemBinOp(gteOpType, x, zero)
 	cmp.retSame = true
 
-	if retVal, err = applyOp(cmp, x); err != nil {
+	if retVal, err = applyOp(cmp, x, zero); err != nil {
 		return nil, errors.Wrap(err, applyOpFail)
 	}
 
 	return HadamardProd(x, retVal)
 }
+
+// Relu computes the rectified linear unit max(x, 0) as a single op, MaximumScalar(x, 0). Unlike Rectify, it neither makes a mask nor multiplies by it,
+// and the gradient at 0 is 0.
+func Relu(x *Node) (retVal *Node, err error) {
+	return MaximumScalar(x, 0)
+}
+
+// Swish computes the swish activation x × σ(βx) as a single op. Swish(x, 1) is the SiLU.
+// The gradient is σ(βx) + βx σ(βx)(1 - σ(βx)), in which σ(βx) is recovered from the output instead of being computed again.
+func Swish(x *Node, beta float64) (retVal *Node, err error) {
+	return applyOp(swishOp{beta: beta}, x)
+}
+
+// LSTMCell computes a single step of a LSTM cell. x is the input vector, h and c are the previous hidden and cell states.
+// wx, wh and b are the stacked weights and biases of the four gates, laid out as [input; forget; output; candidate]:
+//		wx: (4*hidden, inputSize)
+//		wh: (4*hidden, hidden)
+//		b:  (4*hidden)
+// The new hidden state and cell state are returned, and are fully differentiable with regards to all the inputs.
+func LSTMCell(x, h, c, wx, wh, b *Node) (hNew, cNew *Node, err error) {
+	if !c.IsVector() {
+		return nil, nil, errors.Errorf("Expected the cell state to be a vector. Got %v instead", c.shape)
+	}
+	hidden := c.shape[0]
+
+	var wxx, whh, gates *Node
+	if wxx, err = Mul(wx, x); err != nil {
+		return nil, nil, errors.Wrap(err, mulFail)
+	}
+
+	if whh, err = Mul(wh, h); err != nil {
+		return nil, nil, errors.Wrap(err, mulFail)
+	}
+
+	if gates, err = Add(wxx, whh); err != nil {
+		return nil, nil, errors.Wrap(err, addFail)
+	}
+
+	if gates, err = Add(gates, b); err != nil {
+		return nil, nil, errors.Wrap(err, addFail)
+	}
+
+	var states *Node
+	if states, err = applyOp(lstmCellOp{}, gates, c); err != nil {
+		return nil, nil, errors.Wrap(err, applyOpFail)
+	}
+
+	if hNew, err = Slice(states, S(0, hidden)); err != nil {
+		return nil, nil, errors.Wrap(err, operationError)
+	}
+
+	if cNew, err = Slice(states, S(hidden, 2*hidden)); err != nil {
+		return nil, nil, errors.Wrap(err, operationError)
+	}
+	return
+}
+
+// PositionalEncoding creates a constant node holding the fixed sinusoidal position encodings used in transformers.
+// The resulting node is a (seqLen, dModel) matrix, where
+//		PE(pos, 2i)   = sin(pos / 10000^(2i/dModel))
+//		PE(pos, 2i+1) = cos(pos / 10000^(2i/dModel))
+// Like all constants, it is not differentiable.
+func PositionalEncoding(seqLen, dModel int, dtype Dtype) (retVal *Node, err error) {
+	var op positionalEncodingOp
+	if op, err = newPositionalEncodingOp(seqLen, dModel, dtype); err != nil {
+		return nil, errors.Wrap(err, operationError)
+	}
+
+	name := fmt.Sprintf("PE(%d, %d)", seqLen, dModel)
+	return newNode(withOp(op), withType(op.Type()), WithName(name), WithShape(seqLen, dModel), WithValue(op.v)), nil
+}
+
+// RoPE applies the rotary positional embeddings to a (batch, heads, seq, dim) query or key tensor.
+// seqOffset is the position of the first element of the sequence, which is useful when decoding incrementally.
+// The features are rotated in interleaved pairs (x[2i], x[2i+1]), so dim has to be even.
+func RoPE(n *Node, seqOffset int) (retVal *Node, err error) {
+	if seqOffset < 0 {
+		return nil, errors.Errorf("Expected a non negative sequence offset. Got %d instead", seqOffset)
+	}
+	return applyOp(ropeOp{offset: seqOffset}, n)
+}
+
+// SplitHeads splits the last axis of a (batch, seq, dModel) tensor into numHeads heads, returning a (batch, heads, seq, headDim) tensor,
+// where headDim = dModel / numHeads. This is the glue needed before the attention is calculated.
+func SplitHeads(n *Node, numHeads int) (retVal *Node, err error) {
+	if len(n.shape) != 3 {
+		return nil, errors.Errorf("Expected a (batch, seq, dModel) tensor. Got %v instead", n.shape)
+	}
+	batch, seq, dModel := n.shape[0], n.shape[1], n.shape[2]
+	if numHeads <= 0 || dModel%numHeads != 0 {
+		return nil, errors.Errorf("Cannot split dModel %d into %d heads", dModel, numHeads)
+	}
+
+	if retVal, err = Reshape(n, types.Shape{batch, seq, numHeads, dModel / numHeads}); err != nil {
+		return nil, errors.Wrap(err, operationError)
+	}
+	return Transpose(retVal, 0, 2, 1, 3)
+}
+
+// MergeHeads is the inverse of SplitHeads. It takes a (batch, heads, seq, headDim) tensor and returns a (batch, seq, heads * headDim) tensor.
+func MergeHeads(n *Node) (retVal *Node, err error) {
+	if len(n.shape) != 4 {
+		return nil, errors.Errorf("Expected a (batch, heads, seq, headDim) tensor. Got %v instead", n.shape)
+	}
+	batch, heads, seq, headDim := n.shape[0], n.shape[1], n.shape[2], n.shape[3]
+
+	if retVal, err = Transpose(n, 0, 2, 1, 3); err != nil {
+		return nil, errors.Wrap(err, operationError)
+	}
+	return Reshape(retVal, types.Shape{batch, seq, heads * headDim})
+}
+
+// MoEGate computes the gating of a sparse mixture of experts layer. logits is a (tokens, experts) matrix of router logits.
+// For each token the top k experts are selected. weights is a (tokens, experts) matrix holding the softmax over the selected logits,
+// with 0 for the experts that were not selected. indices is a (tokens, k) Int matrix of the selected experts, in descending order of weight.
+// The gradient only flows to the logits of the selected experts.
+func MoEGate(logits *Node, k int) (weights, indices *Node, err error) {
+	if len(logits.shape) != 2 {
+		return nil, nil, errors.Errorf("Expected a (tokens, experts) matrix of logits. Got %v instead", logits.shape)
+	}
+	if k <= 0 || k > logits.shape[1] {
+		return nil, nil, errors.Errorf("Cannot select the top %d of %d experts", k, logits.shape[1])
+	}
+
+	if weights, err = applyOp(moeGateOp{k: k}, logits); err != nil {
+		return nil, nil, errors.Wrap(err, operationError)
+	}
+	if indices, err = applyOp(topKIndicesOp{k: k}, logits); err != nil {
+		return nil, nil, errors.Wrap(err, operationError)
+	}
+	return
+}
+
+// MoELoadBalanceLoss computes the auxiliary loss that encourages a mixture of experts router to spread the tokens evenly across the experts.
+// routerProbs is a (tokens, numExperts) matrix of router probabilities, and assignments is an Int tensor of the experts that the tokens
+// were routed to - for example the indices returned by MoEGate. The loss is numExperts * Σᵢ fᵢPᵢ, where fᵢ is the fraction of the assignments
+// that went to expert i and Pᵢ is the mean router probability of expert i. It is differentiable wrt routerProbs only.
+func MoELoadBalanceLoss(routerProbs, assignments *Node, numExperts int) (retVal *Node, err error) {
+	if numExperts <= 0 {
+		return nil, errors.Errorf("Expected a positive number of experts. Got %d instead", numExperts)
+	}
+
+	op := loadBalanceLossOp{
+		numExperts: numExperts,
+		assignDims: assignments.Dims(),
+	}
+	return applyOp(op, routerProbs, assignments)
+}
+
+// ArcFace computes the additive angular margin loss used in face recognition. features is a (batch, dim) matrix and weights is a
+// (classes, dim) matrix, both of which should have L2 normalized rows. targets is an Int vector of the target class of each feature.
+// The margin (in radians) is added to the angle between each feature and the weight of its target class, and the resulting cosines,
+// multiplied by scale, are used as the logits of a softmax cross entropy. The mean loss is returned.
+func ArcFace(features, weights, targets *Node, margin, scale float64) (retVal *Node, err error) {
+	if scale <= 0 {
+		return nil, errors.Errorf("Expected a positive scale. Got %v instead", scale)
+	}
+	return applyOp(arcFaceOp{margin: margin, scale: scale}, features, weights, targets)
+}
+
+// CenterLoss computes the center loss ½ Σᵢ ‖xᵢ - c(yᵢ)‖², which encourages the features of the same class to cluster together.
+// features is a (batch, dim) matrix and labels is an Int vector of the classes of the features. The class centers start at 0, and
+// are kept by the op. Every time the gradient is calculated, the centers are moved towards the features of their class at the rate alpha.
+func CenterLoss(features, labels *Node, numClasses int, alpha float64) (retVal *Node, err error) {
+	if len(features.shape) != 2 {
+		return nil, errors.Errorf("Expected features shaped (batch, dim). Got %v instead", features.shape)
+	}
+	if numClasses <= 0 {
+		return nil, errors.Errorf("Expected a positive number of classes. Got %d instead", numClasses)
+	}
+
+	dim := features.shape[1]
+	op := centerLossOp{
+		numClasses: numClasses,
+		alpha:      alpha,
+		centerLossState: &centerLossState{
+			centers: make([]float64, numClasses*dim),
+			dim:     dim,
+		},
+	}
+	return applyOp(op, features, labels)
+}
+
+// RunningStats normalizes each feature (column) of x, a (batch, features) matrix, to a mean of 0 and a variance of 1, as batch normalization does,
+// and keeps track of the running mean and variance of the features in the returned stats. eps is added to the variance before its square root is taken,
+// and momentum is how much of each batch goes into the running statistics. The learnt scale and shift of batch norm are left to the caller.
+//
+// It starts out training: batches are normalized by their own statistics, and the gradient is that of batch norm. Once stats.SetTraining(false) is called,
+// the running statistics are used instead, and no longer updated.
+func RunningStats(x *Node, momentum, eps float64) (retVal *Node, stats *BatchNormStats, err error) {
+	if len(x.shape) != 2 {
+		return nil, nil, errors.Errorf("Expected x shaped (batch, features). Got %v instead", x.shape)
+	}
+	if momentum < 0 || momentum > 1 {
+		return nil, nil, errors.Errorf("Expected a momentum between 0 and 1. Got %v instead", momentum)
+	}
+	if eps < 0 {
+		return nil, nil, errors.Errorf("Expected eps to not be negative. Got %v instead", eps)
+	}
+
+	features := x.shape[1]
+	stats = &BatchNormStats{
+		mean:     make([]float64, features),
+		variance: make([]float64, features),
+		momentum: momentum,
+		eps:      eps,
+		training: true,
+	}
+	for k := range stats.variance {
+		stats.variance[k] = 1
+	}
+	if retVal, err = applyOp(runningStatsOp{stats}, x); err != nil {
+		return nil, nil, err
+	}
+	return
+}
+
+// NTXent computes the normalized temperature-scaled cross entropy loss used in contrastive learning (SimCLR).
+// embeddings is a (2N, dim) matrix, where the ith and the (i+N)th rows are the embeddings of two views of the same sample.
+// Each embedding is pulled towards its pair and pushed away from every other embedding in the batch, using the cosine similarities scaled by the temperature.
+func NTXent(embeddings *Node, temperature float64) (retVal *Node, err error) {
+	if temperature <= 0 {
+		return nil, errors.Errorf("Expected a positive temperature. Got %v instead", temperature)
+	}
+	return applyOp(ntxentOp{temperature: temperature}, embeddings)
+}
+
+// HuberLoss computes the mean Huber loss between pred and target, which must be of the same shape. Each residual r = pred - target
+// contributes ½r² if |r| ≤ delta and delta(|r| - ½delta) otherwise, so the loss is quadratic for small residuals and only grows linearly
+// for the large ones, making it less sensitive to outliers than the mean squared error. The gradient of pred is the residual clamped into
+// [-delta, delta], divided by the number of elements.
+func HuberLoss(pred, target *Node, delta float64) (retVal *Node, err error) {
+	if delta <= 0 {
+		return nil, errors.Errorf("Expected a positive delta. Got %v instead", delta)
+	}
+	if pred.IsScalar() || target.IsScalar() {
+		return nil, errors.Errorf("Expected Tensors. Got %v and %v instead", pred, target)
+	}
+	if !pred.shape.Eq(target.shape) {
+		return nil, errors.Errorf("Expected pred and target to be of the same shape. Got %v and %v instead", pred.shape, target.shape)
+	}
+	return applyOp(huberLossOp{delta: delta, d: pred.Dims()}, pred, target)
+}
+
+// LogSoftmax computes log(softmax(x)) along an axis in a numerically stable way, as x - logsumexp(x). Use it with the negative log likelihood
+// instead of taking the Log of SoftMax, which turns into -Inf as soon as a probability underflows.
+// A negative axis counts from the end, so -1, the usual choice, is the last axis. A vector is always a single softmax.
+func LogSoftmax(x *Node, axis int) (retVal *Node, err error) {
+	if x.IsScalar() {
+		return nil, errors.Errorf("Expected a Tensor. Got a scalar %v instead", x)
+	}
+
+	d := x.Dims()
+	along := axis
+	if along < 0 {
+		along += d
+	}
+	if along < 0 || along >= d {
+		return nil, errors.Errorf("Cannot compute the log softmax along axis %d of a tensor with %d dims", axis, d)
+	}
+	return applyOp(logSoftmaxOp{along: along, d: d}, x)
+}
+
+// SoftmaxGrad computes the backward pass of the softmax along the last axis, s * (upstream - Σ upstream*s), where s is the output of the softmax
+// and upstream is the gradient of s. It is the gradient of the softmax's input, for custom losses that need it without reimplementing it.
+// A vector is always a single softmax. SoftmaxGrad is itself differentiable.
+func SoftmaxGrad(softmaxOutput, upstream *Node) (retVal *Node, err error) {
+	if softmaxOutput.IsScalar() {
+		return nil, errors.Errorf("Expected a Tensor. Got a scalar %v instead", softmaxOutput)
+	}
+	return applyOp(softmaxGradOp{d: softmaxOutput.Dims()}, softmaxOutput, upstream)
+}
+
+// GELUGrad computes the backward pass of the exact GELU, x × Φ(x) where Φ is the standard normal CDF, as upstream × (Φ(x) + x × φ(x)),
+// where x is the input of the GELU, upstream is the gradient of its output, and φ is the standard normal PDF. It is the gradient of x,
+// for custom blocks that need it without reimplementing it. x and upstream must be shaped the same. GELUGrad is itself differentiable.
+func GELUGrad(x, upstream *Node) (retVal *Node, err error) {
+	return applyOp(geluGradOp{}, x, upstream)
+}
+
+// L2Normalize scales x to unit L2 length along an axis, as x / √(Σx² + eps), where eps = 1e-12 keeps a vector of zeros from being divided by 0.
+// A negative axis counts from the end, and a vector is always normalized as a whole. The gradient is the projection of gradZ orthogonal to x,
+// divided by the norm.
+func L2Normalize(x *Node, axis int) (retVal *Node, err error) {
+	if x.IsScalar() {
+		return nil, errors.Errorf("Expected a Tensor. Got a scalar %v instead", x)
+	}
+
+	d := x.Dims()
+	along := axis
+	if along < 0 {
+		along += d
+	}
+	if along < 0 || along >= d {
+		return nil, errors.Errorf("Cannot normalize along axis %d of a tensor with %d dims", axis, d)
+	}
+	return applyOp(l2NormalizeOp{along: along, d: d, eps: 1e-12}, x)
+}
+
+// BiasAdd adds the bias, a vector of n elements, to each row of x, a (batch, n) matrix. It computes the same thing as x + b broadcast across the rows,
+// but in a single pass over x. The gradient of the bias is the sum of the gradient of the rows.
+func BiasAdd(x, bias *Node) (retVal *Node, err error) {
+	return applyOp(biasAddOp{}, x, bias)
+}
+
+// Affine scales and shifts each row of x, a (batch, n) matrix, by gamma and beta, two vectors of n elements, as layer and batch normalization do
+// after normalizing. It computes the same thing as gamma ⊙ x + beta broadcast across the rows, but in a single pass over x.
+// The gradients of gamma and beta are summed over the rows.
+func Affine(x, gamma, beta *Node) (retVal *Node, err error) {
+	return applyOp(affineOp{}, x, gamma, beta)
+}
+
+// ClipGrad passes n through as it is, but clips the gradient flowing back through it into [-limit, limit], element by element.
+// Unlike clipping by norm, which scales the whole gradient down, each element is clipped on its own, so the direction of the gradient may change.
+// It is typically put right after a layer whose gradients may blow up, to keep them from destabilizing training.
+func ClipGrad(n *Node, limit float64) (retVal *Node, err error) {
+	if !(limit > 0) || math.IsInf(limit, 1) {
+		return nil, errors.Errorf("Expected the limit to be a positive number. Got %v instead", limit)
+	}
+	return applyOp(clipGradOp{limit: limit}, n)
+}
+
+// L2Reg returns the sum of the squares of all the elements of the given nodes (typically the parameters of a model), as a scalar.
+// Scale it and add it to the cost for weight decay. The gradient flowing back to each node w is 2w.
+func L2Reg(nodes ...*Node) (retVal *Node, err error) {
+	return sumOfElems(Square, pointWiseSquareFail, nodes)
+}
+
+// L1Reg returns the sum of the absolute values of all the elements of the given nodes (typically the parameters of a model), as a scalar.
+// The gradient flowing back to each node w is sign(w).
+func L1Reg(nodes ...*Node) (retVal *Node, err error) {
+	return sumOfElems(Abs, absFail, nodes)
+}
+
+// sumOfElems applies fn to each of the nodes, and sums up all the elements of the results into a scalar.
+func sumOfElems(fn func(*Node) (*Node, error), fnFail string, nodes []*Node) (retVal *Node, err error) {
+	if len(nodes) == 0 {
+		return nil, errors.New("Expected at least one node")
+	}
+
+	for _, n := range nodes {
+		var r *Node
+		if r, err = fn(n); err != nil {
+			return nil, errors.Wrap(err, fnFail)
+		}
+		if !r.IsScalar() {
+			if r, err = Sum(r); err != nil {
+				return nil, errors.Wrap(err, sumFail)
+			}
+		}
+
+		if retVal == nil {
+			retVal = r
+			continue
+		}
+		if retVal, err = Add(retVal, r); err != nil {
+			return nil, errors.Wrap(err, addFail)
+		}
+	}
+	return
+}
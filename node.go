@@ -208,6 +208,17 @@ func (n *Node) isArg() bool      { return n.op == nil }
 func (n *Node) isInput() bool    { return n.isArg() && !n.isStmt }
 func (n *Node) isMutable() bool  { return !n.isInput() && n.op.returnsPtr() }
 func (n *Node) isConstant() bool { _, ok := n.op.(constant); return ok }
+func (n *Node) isStateful() bool { _, ok := n.op.(stateful); return ok }
+func (n *Node) isNoise() bool    { _, ok := n.op.(noiseOp); return ok }
+
+// isDetached returns true if the node belongs to no graph until it is used: constants and noise are such nodes
+func (n *Node) isDetached() bool { return n.isConstant() || n.isNoise() }
+
+// commutes returns true if n is the result of a commutative binary operation, whose children may be swapped around
+func (n *Node) commutes() bool {
+	op, ok := n.op.(elemBinOp)
+	return ok && len(n.children) == 2 && op.isCommutative()
+}
 
 func (n *Node) isRoot() bool {
 	if n.g == nil {
@@ -290,6 +301,10 @@ func (n *Node) Value() Value {
 	if n.isConstant() {
 		return n.op.(constant).Value()
 	}
+	if n.boundTo == nil && n.isNoise() {
+		// the noise node the user holds is not the one added to the graph. Either way, it has drawn what its noiseOp drew last
+		return n.op.(noiseOp).lastValue()
+	}
 	if dv, ok := n.boundTo.(*dualValue); ok {
 		return dv.Value
 	}
@@ -327,7 +342,7 @@ func (n *Node) Name() string {
 	}
 
 	var buf bytes.Buffer
-	fmt.Fprintf(&buf, "%s(", n.op)
+	fmt.Fprintf(&buf, "%s(", opString(n.op))
 	for i, child := range n.children {
 		fmt.Fprintf(&buf, "%%%x", child.Hashcode())
 		if i < len(n.children)-1 {
@@ -339,7 +354,8 @@ func (n *Node) Name() string {
 }
 
 func (n *Node) WriteHash(h hash.Hash32) {
-	fmt.Fprintf(h, "%v%v", n.t, n.shape)
+	writeTypeHash(h, n.t)
+	fmt.Fprintf(h, "%v", n.shape)
 
 	if n.isInput() {
 		h.Write([]byte(n.name))
@@ -353,6 +369,16 @@ func (n *Node) WriteHash(h hash.Hash32) {
 	// }
 
 	binary.Write(h, binary.LittleEndian, byte(len(n.children)))
+	if n.commutes() {
+		// a + b and b + a hash the same, so that they can be merged
+		a, b := n.children[0].Hashcode(), n.children[1].Hashcode()
+		if b < a {
+			a, b = b, a
+		}
+		binary.Write(h, binary.LittleEndian, a)
+		binary.Write(h, binary.LittleEndian, b)
+		return
+	}
 	for _, child := range n.children {
 		binary.Write(h, binary.LittleEndian, child.Hashcode())
 	}
@@ -442,7 +468,7 @@ func (n *Node) String() string {
 	if n.Name() != "" {
 		fmt.Fprintf(&buf, "%s :: ", n.Name())
 	} else {
-		fmt.Fprintf(&buf, "%s :: ", n.op)
+		fmt.Fprintf(&buf, "%s :: ", opString(n.op))
 	}
 	if c, ok := n.op.(constant); ok {
 		fmt.Fprintf(&buf, "%v{%v}", n.t, c.Value())
@@ -542,7 +568,7 @@ func (n *Node) fix() {
 		n.shape = scalarShape
 	}
 
-	if n.isConstant() {
+	if n.isDetached() {
 		return
 	}
 
@@ -0,0 +1,83 @@
+package gorgonia
+
+import (
+	"math"
+	"sync/atomic"
+
+	tf32 "github.com/chewxy/gorgonia/tensor/f32"
+	tf64 "github.com/chewxy/gorgonia/tensor/f64"
+	"github.com/pkg/errors"
+)
+
+// numericsChecked is 1 when the checked execution mode is on. It is read for every op that is executed, so it is accessed atomically instead of behind a lock.
+var numericsChecked int32
+
+// SetCheckNumerics turns the checked execution mode on or off. It is off by default.
+//
+// When it is on, the output of every op is scanned for NaNs and Infs as soon as the op has been executed, and the VM stops with an error
+// that names the op and the node where the first of them appeared, along with the inputs of that node. The lisp machine also checks
+// the gradients it computes when it differentiates an op, which the tape machine does not need to, as its gradients are the outputs of ops.
+//
+// Scanning an output is a single pass over it, which is cheap enough to leave on while debugging. Turn it off in production.
+// Unlike WithNaNWatch and WithInfWatch, which are options of one VM, this applies to every VM.
+func SetCheckNumerics(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&numericsChecked, v)
+}
+
+// CheckNumerics returns true if the checked execution mode is on. See SetCheckNumerics.
+func CheckNumerics() bool { return atomic.LoadInt32(&numericsChecked) == 1 }
+
+// checkOutput returns an error if v, the output of the op at node n, holds a NaN or an Inf
+func checkOutput(op Op, n *Node, v Value) error {
+	if i, f, found := firstNonFinite(v); found {
+		return errors.Errorf("%v found in the output of %v, at element %d. Node: %v(%x). Inputs: %v", f, opString(op), i, n, n.ID(), n.children)
+	}
+	return nil
+}
+
+// checkGrad returns an error if d, the gradient of x computed when differentiating the op at node n, holds a NaN or an Inf
+func checkGrad(op Op, n, x *Node, d Value) error {
+	if i, f, found := firstNonFinite(d); found {
+		return errors.Errorf("%v found in the gradient of %v(%x), at element %d, when differentiating %v. Node: %v(%x)", f, x, x.ID(), i, opString(op), n, n.ID())
+	}
+	return nil
+}
+
+// firstNonFinite finds the first NaN or Inf in a Value, in row-major order. Values that are not floats never hold any.
+func firstNonFinite(v Value) (i int, f float64, found bool) {
+	switch vt := v.(type) {
+	case *dualValue:
+		return firstNonFinite(vt.Value)
+	case Scalar:
+		switch s := vt.v.(type) {
+		case float64:
+			f = s
+		case float32:
+			f = float64(s)
+		default:
+			return
+		}
+		found = math.IsNaN(f) || math.IsInf(f, 0)
+		return
+	case Tensor:
+		switch t := vt.Tensor.(type) {
+		case *tf64.Tensor:
+			for i, f = range materializedF64s(t) {
+				if math.IsNaN(f) || math.IsInf(f, 0) {
+					return i, f, true
+				}
+			}
+		case *tf32.Tensor:
+			for j, s := range materializedF32s(t) {
+				if f = float64(s); math.IsNaN(f) || math.IsInf(f, 0) {
+					return j, f, true
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,86 @@
+package gorgonia
+
+import (
+	"testing"
+
+	tf64 "github.com/chewxy/gorgonia/tensor/f64"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetCheckNumerics(t *testing.T) {
+	assert := assert.New(t)
+
+	// runs Σ(x ÷ y)
+	run := func(useTape bool, ys []float64) error {
+		g := NewGraph()
+		x := NewVector(g, Float64, WithName("x"), WithShape(3), WithValue(tf64.NewTensor(tf64.WithBacking([]float64{1, 2, 3}), tf64.WithShape(3))))
+		y := NewVector(g, Float64, WithName("y"), WithShape(3), WithValue(tf64.NewTensor(tf64.WithBacking(ys), tf64.WithShape(3))))
+		z := Must(HadamardDiv(x, y))
+		WithName("z")(z)
+		cost := Must(Sum(z))
+
+		if useTape {
+			if _, err := Grad(cost, x, y); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return NewTapeMachine(prog, locMap).RunAll()
+		}
+		return NewLispMachine(g).RunAll()
+	}
+
+	assert.False(CheckNumerics())
+	for _, useTape := range []bool{true, false} {
+		// off by default: the Inf goes through unnoticed
+		assert.Nil(run(useTape, []float64{1, 0, 2}), "Tape %t", useTape)
+	}
+
+	SetCheckNumerics(true)
+	defer SetCheckNumerics(false)
+	assert.True(CheckNumerics())
+
+	for _, useTape := range []bool{true, false} {
+		assert.Nil(run(useTape, []float64{1, 4, 2}), "Tape %t", useTape)
+
+		err := run(useTape, []float64{1, 0, 2})
+		if err == nil {
+			t.Errorf("Tape %t: expected the division by zero to be caught", useTape)
+			continue
+		}
+		assert.Contains(err.Error(), "+Inf found in the output of ÷, at element 1. Node: z ::", "Tape %t", useTape)
+		assert.Contains(err.Error(), "Inputs: [x, y]", "Tape %t", useTape)
+	}
+
+	// √x is fine at 0, but its gradient is not
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		x := NewVector(g, Float64, WithName("x"), WithShape(2), WithValue(tf64.NewTensor(tf64.WithBacking([]float64{4, 0}), tf64.WithShape(2))))
+		cost := Must(Sum(Must(Sqrt(x))))
+
+		var err error
+		if useTape {
+			if _, err = Grad(cost, x); err != nil {
+				t.Fatal(err)
+			}
+			var prog *program
+			var locMap map[*Node]register
+			if prog, locMap, err = Compile(g); err != nil {
+				t.Fatal(err)
+			}
+			err = NewTapeMachine(prog, locMap).RunAll()
+		} else {
+			err = NewLispMachine(g).RunAll()
+		}
+		if err == nil {
+			t.Errorf("Tape %t: expected the gradient of √0 to be caught", useTape)
+			continue
+		}
+		assert.Contains(err.Error(), "+Inf found in the", "Tape %t", useTape)
+		if !useTape {
+			assert.Contains(err.Error(), "gradient of x ::", "Tape %t", useTape)
+		}
+	}
+}
@@ -111,6 +111,14 @@ type UnsafeDoer interface {
 	UnsafeDo(inputs ...Value) (Value, error)
 }
 
+// a stateful op is an op with hidden state - applying it twice on the same inputs may yield different results (random ops, for example).
+// Nodes of stateful ops are never merged with one another.
+type stateful interface {
+	Op
+
+	isStateful() bool
+}
+
 // a constant is an unchanging value. I think everyone would know what a constant is
 // a constant op is an op that creates a constant. It is also a Value of a constant value
 type constant interface {
@@ -152,6 +160,21 @@ func (c constantScalar) Hashcode() uint32 {
 	return h.Sum32()
 }
 
+func (c constantScalar) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeValue(c.v)
+	return w.bytes()
+}
+
+func (c *constantScalar) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	v := r.readValue()
+	s, ok := v.(Scalar)
+	r.check(ok, "Expected a Scalar. Got %v instead", v)
+	c.v = s
+	return r.done()
+}
+
 func (c constantScalar) isconstant() bool { return true }
 func (c constantScalar) Value() Value     { return c.v }
 
@@ -183,6 +206,21 @@ func (c constantTensor) Hashcode() uint32 {
 	return h.Sum32()
 }
 
+func (c constantTensor) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeValue(c.v)
+	return w.bytes()
+}
+
+func (c *constantTensor) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	v := r.readValue()
+	t, ok := v.(Tensor)
+	r.check(ok, "Expected a Tensor. Got %v instead", v)
+	c.v = t
+	return r.done()
+}
+
 func (c constantTensor) isconstant() bool { return true }
 func (c constantTensor) Value() Value     { return c.v }
 
@@ -1 +1,362 @@
 package gorgonia
+
+import (
+	"fmt"
+	"hash"
+	"hash/fnv"
+
+	"github.com/chewxy/gorgonia/tensor/types"
+	"github.com/pkg/errors"
+)
+
+/*
+This file holds the ops that are made up of a whole subgraph of other ops.
+*/
+
+/* CHECKPOINT */
+
+// Checkpoint builds the subgraph fn(inputs), but does not keep the intermediate values of the subgraph around.
+// Only the values of the outputs are kept: the intermediate values are thrown away once the outputs are computed,
+// and the subgraph is computed again from the inputs when the gradient is needed. This trades compute for memory in deep graphs.
+//
+// fn is called once, with stand-ins for the inputs, and has to build its outputs from those stand-ins only. The returned nodes
+// are the outputs of fn in the graph of the inputs, and may be used as any other node. Since the subgraph is computed more than once,
+// fn should be deterministic: random ops in it draw new values every time it is recomputed.
+//
+// Each output computes its own part of the subgraph, and each gradient computes the subgraph again, so Checkpoint is best wrapped around
+// subgraphs with few inputs and outputs.
+func Checkpoint(fn func(Nodes) Nodes, inputs Nodes) (retVal Nodes, err error) {
+	if len(inputs) == 0 {
+		return nil, errors.New("Cannot checkpoint a subgraph with no inputs")
+	}
+	if !inputs.AllSameGraph() {
+		return nil, errors.New("Not all inputs have the same graph")
+	}
+
+	cp := &checkpoint{g: NewGraph()}
+	for i, in := range inputs {
+		p := newUniqueNode(withType(in.t), withGraph(cp.g), WithShape(in.shape...), WithName(fmt.Sprintf("checkpoint input %d", i)))
+		cp.inputs = append(cp.inputs, p)
+	}
+
+	cp.outputs = fn(cp.inputs)
+	if len(cp.outputs) == 0 {
+		return nil, errors.New("Expected fn to return at least one output")
+	}
+	for k, out := range cp.outputs {
+		if out.g != cp.g {
+			return nil, errors.Errorf("Expected the outputs of fn to be built from its inputs. Output %d (%v) is not", k, out)
+		}
+
+		grad := newUniqueNode(withType(out.t), withGraph(cp.g), WithShape(out.shape...), WithName(fmt.Sprintf("checkpoint gradient %d", k)))
+		var cost *Node
+		if out.IsScalar() {
+			if cost, err = Mul(out, grad); err != nil {
+				return nil, errors.Wrap(err, operationError)
+			}
+		} else {
+			var prod *Node
+			if prod, err = HadamardProd(out, grad); err != nil {
+				return nil, errors.Wrap(err, operationError)
+			}
+			if cost, err = Sum(prod); err != nil {
+				return nil, errors.Wrap(err, sumFail)
+			}
+		}
+		cp.grads = append(cp.grads, grad)
+		cp.costs = append(cp.costs, cost)
+	}
+
+	retVal = make(Nodes, len(cp.outputs))
+	for k := range cp.outputs {
+		if retVal[k], err = applyOp(checkpointOp{checkpoint: cp, out: k}, inputs...); err != nil {
+			return nil, errors.Wrap(err, applyOpFail)
+		}
+	}
+	return
+}
+
+// checkpoint is a subgraph of its own, which is run by a *lispMachine of its own whenever its values are needed.
+// The values of the subgraph are let go of after every run.
+type checkpoint struct {
+	g       *ExprGraph
+	inputs  Nodes // stand-ins for the inputs
+	outputs Nodes
+
+	// costs[k] = Σ outputs[k] ⊙ grads[k], where grads[k] stands in for the gradient of outputs[k].
+	// Backpropagating from costs[k] backpropagates the gradient of outputs[k] through the subgraph.
+	grads Nodes
+	costs Nodes
+}
+
+// forward computes the kth output from the inputs
+func (cp *checkpoint) forward(k int, inputs []Value) (retVal Value, err error) {
+	defer cp.release()
+	if err = cp.let(inputs); err != nil {
+		return
+	}
+
+	m := NewLispMachine(cp.g.SubgraphRoots(cp.outputs[k]), ExecuteFwdOnly())
+	if err = m.RunAll(); err != nil {
+		return nil, errors.Wrap(err, "Checkpoint forward")
+	}
+	return cp.outputs[k].Value().clone()
+}
+
+// backward computes the subgraph again from the inputs, and backpropagates grad, the gradient of the kth output, through it.
+// It returns the gradients of the inputs.
+func (cp *checkpoint) backward(k int, inputs []Value, grad Value) (retVal []Value, err error) {
+	defer cp.release()
+	if err = cp.let(inputs); err != nil {
+		return
+	}
+	if err = Let(cp.grads[k], grad); err != nil {
+		return
+	}
+
+	m := NewLispMachine(cp.g.SubgraphRoots(cp.costs[k]))
+	if err = m.RunAll(); err != nil {
+		return nil, errors.Wrap(err, "Checkpoint backward")
+	}
+
+	retVal = make([]Value, len(cp.inputs))
+	for i, in := range cp.inputs {
+		dv, ok := in.boundTo.(*dualValue)
+		if !ok {
+			// the kth output does not depend on this input
+			if retVal[i], err = inputs[i].clone(); err != nil {
+				return nil, errors.Wrap(err, cloneFail)
+			}
+			retVal[i] = retVal[i].zero()
+			continue
+		}
+		retVal[i] = dv.d
+	}
+	return
+}
+
+func (cp *checkpoint) let(inputs []Value) (err error) {
+	if len(inputs) != len(cp.inputs) {
+		return errors.Errorf("Checkpoint expects %d inputs. Got %d instead", len(cp.inputs), len(inputs))
+	}
+	for i, v := range inputs {
+		if err = Let(cp.inputs[i], v); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// release lets go of every value in the subgraph. The values are not returned to the pools, as some of them may be the inputs,
+// or views of the inputs.
+func (cp *checkpoint) release() {
+	for _, n := range cp.g.AllNodes() {
+		n.boundTo = nil
+	}
+}
+
+// live counts the values the subgraph is holding on to
+func (cp *checkpoint) live() (retVal int) {
+	for _, n := range cp.g.AllNodes() {
+		if n.boundTo != nil {
+			retVal++
+		}
+	}
+	return
+}
+
+// checkpointOp computes the outth output of a checkpointed subgraph. See Checkpoint.
+type checkpointOp struct {
+	*checkpoint
+	out int
+}
+
+// checkpointOp has the type of the subgraph:
+//		op :: a → b → ... → c
+func (op checkpointOp) Type() Type {
+	ts := make([]Type, 0, len(op.inputs)+1)
+	for _, in := range op.inputs {
+		ts = append(ts, in.t)
+	}
+	ts = append(ts, op.outputs[op.out].t)
+	return newFunctionType(ts...)
+}
+
+func (op checkpointOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != len(op.inputs) {
+		err = NewError(GraphError, "checkpointOp expects %d inputs. Got %d instead", len(op.inputs), len(inputs))
+		return
+	}
+	return op.outputs[op.out].shape.Clone(), nil
+}
+
+// DiffWRT is true for the inputs that are floats
+func (op checkpointOp) DiffWRT(inputs int) []bool {
+	retVal := make([]bool, inputs)
+	for i := range retVal {
+		if i >= len(op.inputs) {
+			break
+		}
+		dt, err := dtypeOf(op.inputs[i].t)
+		retVal[i] = err == nil && (dt == Float64 || dt == Float32)
+	}
+	return retVal
+}
+
+func (op checkpointOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != len(op.inputs) {
+		err = NewError(GraphError, "checkpointOp expects %d inputs. Got %d instead", len(op.inputs), len(inputs))
+		return
+	}
+
+	children := make(Nodes, 0, len(inputs)+1)
+	children = append(children, inputs...)
+	children = append(children, gradNode)
+
+	retVal = make(Nodes, len(inputs))
+	for i, ok := range op.DiffWRT(len(inputs)) {
+		if !ok {
+			continue
+		}
+		diff := checkpointDiffOp{fwd: op, wrt: i}
+		if retVal[i], err = applyOp(diff, children...); err != nil {
+			return nil, errors.Wrap(err, applyOpFail)
+		}
+		retVal[i].setGroup(gradClust)
+	}
+	return
+}
+
+func (op checkpointOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != len(op.inputs) {
+		err = NewError(GraphError, "checkpointOp expects %d inputs. Got %d instead", len(op.inputs), len(inputs))
+		return
+	}
+
+	vals := make([]Value, len(inputs))
+	for i, in := range inputs {
+		vals[i] = in.Value()
+	}
+
+	ydv := output.boundTo.(*dualValue)
+	var grads []Value
+	if grads, err = op.backward(op.out, vals, ydv.d); err != nil {
+		return errors.Wrap(err, "checkpointOp.DoDiff()")
+	}
+
+	for i, ok := range op.DiffWRT(len(inputs)) {
+		if !ok {
+			continue
+		}
+
+		xdv := inputs[i].boundTo.(*dualValue)
+		add := newElemBinOp(addOpType, inputs[i], inputs[i])
+		var d Value
+		if d, err = add.UnsafeDo(xdv.d, grads[i]); err != nil {
+			return errors.Wrapf(err, unsafeDoFail, add)
+		}
+
+		// scalars are not added in place
+		if inputs[i].IsScalar() {
+			if err = xdv.SetDeriv(d); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func (op checkpointOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != len(op.inputs) {
+		err = NewError(GraphError, "checkpointOp expects %d inputs. Got %d instead", len(op.inputs), len(inputs))
+		return
+	}
+	return op.forward(op.out, inputs)
+}
+
+func (op checkpointOp) returnsPtr() bool    { return false }
+func (op checkpointOp) callsExtern() bool   { return false }
+func (op checkpointOp) overwriteInput() int { return -1 }
+
+// WriteHash writes the identity of the subgraph, as two checkpoints may have the same inputs but different subgraphs
+func (op checkpointOp) WriteHash(h hash.Hash) {
+	fmt.Fprintf(h, "checkpoint%p:%d", op.checkpoint, op.out)
+}
+
+func (op checkpointOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op checkpointOp) String() string { return fmt.Sprintf("Checkpoint[%d]", op.out) }
+
+// MarshalBinary always fails. A checkpointOp holds a subgraph of its own, which lives only as long as the graph it was made for, and cannot be written out
+func (op checkpointOp) MarshalBinary() ([]byte, error) {
+	return nil, errors.Errorf("%v cannot be encoded: it holds a checkpointed subgraph. Encode the graph before it is checkpointed instead", op)
+}
+
+// checkpointDiffOp is the gradient of the input wrt of a checkpointed subgraph, given the gradient of its outth output.
+// The inputs are the inputs of the subgraph, followed by the gradient of the output. It computes the subgraph again every time.
+type checkpointDiffOp struct {
+	fwd checkpointOp
+	wrt int
+}
+
+// checkpointDiffOp has this type:
+//		op :: a → b → ... → c → a
+func (op checkpointDiffOp) Type() Type {
+	ts := make([]Type, 0, len(op.fwd.inputs)+2)
+	for _, in := range op.fwd.inputs {
+		ts = append(ts, in.t)
+	}
+	ts = append(ts, op.fwd.outputs[op.fwd.out].t, op.fwd.inputs[op.wrt].t)
+	return newFunctionType(ts...)
+}
+
+func (op checkpointDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != len(op.fwd.inputs)+1 {
+		err = NewError(GraphError, "checkpointDiffOp expects %d inputs. Got %d instead", len(op.fwd.inputs)+1, len(inputs))
+		return
+	}
+	return inputs[op.wrt].shape.Clone(), nil
+}
+
+func (op checkpointDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op checkpointDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op checkpointDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != len(op.fwd.inputs)+1 {
+		err = NewError(GraphError, "checkpointDiffOp expects %d inputs. Got %d instead", len(op.fwd.inputs)+1, len(inputs))
+		return
+	}
+
+	last := len(inputs) - 1
+	var grads []Value
+	if grads, err = op.fwd.backward(op.fwd.out, inputs[:last], inputs[last]); err != nil {
+		return nil, errors.Wrap(err, "checkpointDiffOp.Do()")
+	}
+	return grads[op.wrt], nil
+}
+
+func (op checkpointDiffOp) returnsPtr() bool    { return false }
+func (op checkpointDiffOp) callsExtern() bool   { return false }
+func (op checkpointDiffOp) overwriteInput() int { return -1 }
+func (op checkpointDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	op.fwd.WriteHash(h)
+	fmt.Fprintf(h, "/%d", op.wrt)
+}
+
+func (op checkpointDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op checkpointDiffOp) String() string { return fmt.Sprintf("∂%v/∂%d", op.fwd, op.wrt) }
+
+// MarshalBinary always fails, for the same reason checkpointOp.MarshalBinary does
+func (op checkpointDiffOp) MarshalBinary() ([]byte, error) {
+	return nil, errors.Errorf("%v cannot be encoded: it holds a checkpointed subgraph. Encode the graph before it is checkpointed instead", op)
+}
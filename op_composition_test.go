@@ -0,0 +1,151 @@
+package gorgonia
+
+import (
+	"math"
+	"testing"
+
+	tf64 "github.com/chewxy/gorgonia/tensor/f64"
+	"github.com/chewxy/gorgonia/tensor/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpoint(t *testing.T) {
+	assert := assert.New(t)
+
+	xs := []float64{
+		0.5, -1, 2,
+		1.5, 0.25, -0.5,
+	}
+	ws := []float64{
+		1, 2, -1,
+		0.5, -2, 1,
+	}
+	cs := []float64{
+		1, -1, 2,
+		0.5, 3, -2,
+	}
+
+	// block is the subgraph being checkpointed. It returns y = σ(tanh(x ⊙ w) + x) and s = Σx², so that x is used by both outputs
+	block := func(ns Nodes) Nodes {
+		x, w := ns[0], ns[1]
+		h := Must(Tanh(Must(HadamardProd(x, w))))
+		y := Must(Sigmoid(Must(Add(h, x))))
+		s := Must(Sum(Must(Square(x))))
+		return Nodes{y, s}
+	}
+
+	// run returns the gradients of x and w, and the number of values held on to once the lisp machine is done
+	run := func(checkpointed, useTape bool) (dx, dw []float64, live int) {
+		g := NewGraph()
+		x := NewMatrix(g, Float64, WithName("x"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(2, 3))))
+		w := NewMatrix(g, Float64, WithName("w"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(ws)), tf64.WithShape(2, 3))))
+		c := NewMatrix(g, Float64, WithName("c"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(cs), tf64.WithShape(2, 3))))
+
+		var outs Nodes
+		var cp *checkpoint
+		if checkpointed {
+			var err error
+			if outs, err = Checkpoint(block, Nodes{x, w}); err != nil {
+				t.Fatal(err)
+			}
+			cp = outs[0].op.(checkpointOp).checkpoint
+		} else {
+			outs = block(Nodes{x, w})
+		}
+		assert.Equal(types.Shape{2, 3}, outs[0].Shape())
+		assert.True(outs[1].IsScalar())
+
+		cost := Must(Add(Must(Sum(Must(HadamardProd(outs[0], c)))), outs[1]))
+		if useTape {
+			if _, err := Grad(cost, x, w); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = NewTapeMachine(prog, locMap).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			if err := NewLispMachine(g).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+			for _, n := range g.AllNodes() {
+				if n.boundTo != nil {
+					live++
+				}
+			}
+		}
+		if cp != nil {
+			assert.Equal(0, cp.live(), "The checkpointed subgraph should let go of its values")
+			live += cp.live()
+		}
+
+		dxV, err := x.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dwV, err := w.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return clonef64s(extractF64s(dxV)), clonef64s(extractF64s(dwV)), live
+	}
+
+	for _, useTape := range []bool{true, false} {
+		correctDX, correctDW, plainLive := run(false, useTape)
+		dx, dw, live := run(true, useTape)
+		assert.True(floatsClose(correctDX, dx), "Tape %t. Expected %v. Got %v", useTape, correctDX, dx)
+		assert.True(floatsClose(correctDW, dw), "Tape %t. Expected %v. Got %v", useTape, correctDW, dw)
+		if !useTape {
+			assert.True(live < plainLive, "Expected fewer live values when checkpointed. Got %d, and %d without", live, plainLive)
+		}
+	}
+
+	// the gradient of x agrees with a numerical one
+	xc := clonef64s(xs)
+	correctDX := numericGrad(xc, func() (retVal float64) {
+		for i, v := range xc {
+			y := 1 / (1 + math.Exp(-(math.Tanh(v*ws[i]) + v)))
+			retVal += cs[i]*y + v*v
+		}
+		return
+	})
+	dx, _, _ := run(true, false)
+	assert.True(floatsClose(correctDX, dx), "Expected %v. Got %v", correctDX, dx)
+
+	// forward only
+	g := NewGraph()
+	x := NewMatrix(g, Float64, WithName("x"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(2, 3))))
+	w := NewMatrix(g, Float64, WithName("w"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(ws)), tf64.WithShape(2, 3))))
+	outs, err := Checkpoint(block, Nodes{x, w})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = NewLispMachine(g, ExecuteFwdOnly()).RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	var sumSq float64
+	for _, v := range xs {
+		sumSq += v * v
+	}
+	assert.InDelta(sumSq, extractF64(outs[1].Value()), 1e-12)
+	assert.Equal(0, outs[0].op.(checkpointOp).live())
+
+	// checkpoints are registered, but cannot be encoded
+	for _, op := range []Op{outs[0].op, checkpointDiffOp{fwd: outs[0].op.(checkpointOp)}} {
+		_, err = EncodeOp(op)
+		if assert.NotNil(err) {
+			assert.Contains(err.Error(), "checkpointed subgraph")
+		}
+	}
+
+	// bad subgraphs
+	_, err = Checkpoint(block, nil)
+	assert.NotNil(err)
+	_, err = Checkpoint(func(Nodes) Nodes { return nil }, Nodes{x})
+	assert.NotNil(err)
+	_, err = Checkpoint(func(Nodes) Nodes { return Nodes{w} }, Nodes{x})
+	assert.NotNil(err, "Outputs that are not built from the inputs are not allowed")
+}
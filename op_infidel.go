@@ -5,6 +5,7 @@ import (
 	"hash/fnv"
 
 	"github.com/chewxy/gorgonia/tensor/types"
+	"github.com/pkg/errors"
 )
 
 /*
@@ -62,4 +63,9 @@ func (op readOp) Hashcode() uint32 {
 	return h.Sum32()
 }
 
+// MarshalBinary always fails. A readOp reads into a Value of the caller of Read, which only exists in this process
+func (op readOp) MarshalBinary() ([]byte, error) {
+	return nil, errors.Errorf("%v cannot be encoded: it reads into a Value held by the caller of Read", op)
+}
+
 func (op readOp) isStmt() bool { return true }
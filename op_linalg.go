@@ -0,0 +1,1188 @@
+package gorgonia
+
+import (
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"math"
+	"sync/atomic"
+
+	tf32 "github.com/chewxy/gorgonia/tensor/f32"
+	tf64 "github.com/chewxy/gorgonia/tensor/f64"
+	"github.com/chewxy/gorgonia/tensor/types"
+	"github.com/pkg/errors"
+)
+
+/*
+	This file holds the Ops for linear algebra that are not simple binary operations - decompositions and the like.
+
+	See also: operatorLinAlg.go for the binary linear algebra operators (matmul, matvecmul etc)
+*/
+
+/* CHOLESKY */
+
+// choleskyOp computes the lower triangular factor L of a symmetric positive definite matrix A, such that A = L × Lᵀ.
+// Like LAPACK's potrf, only the lower triangle of A (including the diagonal) is read.
+type choleskyOp struct{}
+
+// choleskyOp has this type:
+//		op :: (Float a) ⇒ Matrix a → Matrix a
+func (op choleskyOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	m := newTensorType(2, a)
+	return newFunctionType(m, m)
+}
+
+func (op choleskyOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "choleskyOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	x := inputs[0]
+	if len(x.shape) != 2 || x.shape[0] != x.shape[1] {
+		return nil, errors.Errorf("Expected a square matrix. Got %v instead", x.shape)
+	}
+	return x.shape.Clone(), nil
+}
+
+func (op choleskyOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+// SymDiff differentiates the Cholesky decomposition following Murray (2016), "Differentiation of the Cholesky decomposition":
+//		∂A = L⁻ᵀ Φ(Lᵀ × ∂L) L⁻¹
+// where Φ takes the lower triangle of a matrix and halves its diagonal. Lᵀ × ∂L is computed with a matMul, and the rest by choleskyDiffOp.
+func (op choleskyOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "choleskyOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	mm := linAlgBinOp{āBinaryOperator: matMulOperator, transA: true}
+	var ltg, dx *Node
+	if ltg, err = binOpNode(mm, output, gradNode); err != nil {
+		return nil, errors.Wrapf(err, binOpNodeFail, mm)
+	}
+	ltg.setGroup(gradClust)
+
+	if dx, err = applyOp(choleskyDiffOp{}, output, ltg); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op choleskyOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "choleskyOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	odv := output.boundTo.(*dualValue)
+
+	mm := linAlgBinOp{āBinaryOperator: matMulOperator, transA: true}
+	var ltg, d Value
+	if ltg, err = mm.Do(odv.Value, odv.d); err != nil {
+		return errors.Wrapf(err, doFail, mm)
+	}
+	if d, err = (choleskyDiffOp{}).Do(odv.Value, ltg); err != nil {
+		return errors.Wrap(err, "choleskyOp.DoDiff()")
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op choleskyOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "choleskyOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var a []float64
+	var n int
+	var dt Dtype
+	if a, n, dt, err = squareOperand(inputs[0]); err != nil {
+		return
+	}
+
+	var l []float64
+	if l, err = cholesky(a, n); err != nil {
+		return
+	}
+	return squareValue(l, n, dt), nil
+}
+
+func (op choleskyOp) returnsPtr() bool    { return false }
+func (op choleskyOp) callsExtern() bool   { return false }
+func (op choleskyOp) overwriteInput() int { return -1 }
+func (op choleskyOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("cholesky"))
+}
+
+func (op choleskyOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op choleskyOp) String() string { return "Cholesky" }
+
+// choleskyDiffOp is the derivative of choleskyOp. It takes L and Lᵀ × ∂L, and returns the gradient of the lower triangle of A.
+type choleskyDiffOp struct{}
+
+// choleskyDiffOp has this type:
+//		op :: (Float a) ⇒ Matrix a → Matrix a → Matrix a
+func (op choleskyDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	m := newTensorType(2, a)
+	return newFunctionType(m, m, m)
+}
+
+func (op choleskyDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "choleskyDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op choleskyDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op choleskyDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op choleskyDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "choleskyDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var l, p []float64
+	var n, pn int
+	var dt Dtype
+	if l, n, dt, err = squareOperand(inputs[0]); err != nil {
+		return
+	}
+	if p, pn, _, err = squareOperand(inputs[1]); err != nil {
+		return
+	}
+	if pn != n {
+		return nil, errors.Errorf("Expected Lᵀ × ∂L to be a (%d, %d) matrix. Got %v instead", n, n, inputs[1].Shape())
+	}
+
+	// P = Φ(Lᵀ × ∂L)
+	for i := 0; i < n; i++ {
+		p[i*n+i] /= 2
+		for j := i + 1; j < n; j++ {
+			p[i*n+j] = 0
+		}
+	}
+
+	// ∂A = L⁻ᵀ P L⁻¹, by solving Lᵀ Y = P, and then Lᵀ ∂Aᵀ = Yᵀ
+	y := solveLowerT(l, p, n)
+	transposeSquare(y, n)
+	da := solveLowerT(l, y, n)
+	transposeSquare(da, n)
+
+	// only the lower triangle of A is read, so an element below the diagonal stands for both itself and its mirror image
+	for i := 0; i < n; i++ {
+		for j := 0; j < i; j++ {
+			da[i*n+j] += da[j*n+i]
+			da[j*n+i] = 0
+		}
+	}
+	return squareValue(da, n, dt), nil
+}
+
+func (op choleskyDiffOp) returnsPtr() bool    { return false }
+func (op choleskyDiffOp) callsExtern() bool   { return false }
+func (op choleskyDiffOp) overwriteInput() int { return -1 }
+func (op choleskyDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂cholesky"))
+}
+
+func (op choleskyDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op choleskyDiffOp) String() string { return "∂Cholesky" }
+
+/* MATRIX POWER */
+
+// matPowOp raises a square matrix A to the p-th power, by repeated squaring. A⁰ is the identity, which does not depend on A,
+// so matPowOp is not differentiable when p is 0.
+type matPowOp struct {
+	p int
+}
+
+// matPowOp has this type:
+//		op :: (Float a) ⇒ Matrix a → Matrix a
+func (op matPowOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	m := newTensorType(2, a)
+	return newFunctionType(m, m)
+}
+
+func (op matPowOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "matPowOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	x := inputs[0]
+	if len(x.shape) != 2 || x.shape[0] != x.shape[1] {
+		return nil, errors.Errorf("Expected a square matrix. Got %v instead", x.shape)
+	}
+	return x.shape.Clone(), nil
+}
+
+func (op matPowOp) DiffWRT(inputs int) []bool { return []bool{op.p != 0} }
+
+// SymDiff differentiates Aᵖ as a product of p factors of A. Each factor contributes the gradient with the factors before it
+// multiplied on the left, and the factors after it on the right, so:
+//		∂A = Σ (Aᵀ)ᵏ × ∂Z × (Aᵀ)ᵖ⁻¹⁻ᵏ, for k = 0 ... p-1
+// which is computed by matPowDiffOp.
+func (op matPowOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "matPowOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(matPowDiffOp(op), inputs[0], gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op matPowOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "matPowOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	odv := output.boundTo.(*dualValue)
+
+	diff := matPowDiffOp(op)
+	var d Value
+	if d, err = diff.Do(xdv.Value, odv.d); err != nil {
+		return errors.Wrapf(err, doFail, diff)
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op matPowOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "matPowOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var a []float64
+	var n int
+	var dt Dtype
+	if a, n, dt, err = squareOperand(inputs[0]); err != nil {
+		return
+	}
+
+	// the bits of p pick out which of A, A², A⁴ ... go into the product
+	res := identity(n)
+	for p := op.p; p > 0; p >>= 1 {
+		if p&1 == 1 {
+			res = matMulSquare(res, a, n)
+		}
+		if p > 1 {
+			a = matMulSquare(a, a, n)
+		}
+	}
+	return squareValue(res, n, dt), nil
+}
+
+func (op matPowOp) returnsPtr() bool    { return false }
+func (op matPowOp) callsExtern() bool   { return false }
+func (op matPowOp) overwriteInput() int { return -1 }
+func (op matPowOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("matPow"))
+	fmt.Fprintf(h, "%d", op.p)
+}
+
+func (op matPowOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op matPowOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.p)
+	return w.bytes()
+}
+
+func (op *matPowOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.p = r.readInt()
+	r.check(op.p >= 0, "Expected a power that is not negative. Got %d instead", op.p)
+	return r.done()
+}
+
+func (op matPowOp) String() string { return fmt.Sprintf("MatPow{%d}", op.p) }
+
+// matPowDiffOp is the derivative of matPowOp. It takes A and the gradient of Aᵖ, and returns the gradient of A.
+type matPowDiffOp matPowOp
+
+// matPowDiffOp has this type:
+//		op :: (Float a) ⇒ Matrix a → Matrix a → Matrix a
+func (op matPowDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	m := newTensorType(2, a)
+	return newFunctionType(m, m, m)
+}
+
+func (op matPowDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "matPowDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op matPowDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op matPowDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op matPowDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "matPowDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var a, g []float64
+	var n, gn int
+	var dt Dtype
+	if a, n, dt, err = squareOperand(inputs[0]); err != nil {
+		return
+	}
+	if g, gn, _, err = squareOperand(inputs[1]); err != nil {
+		return
+	}
+	if gn != n {
+		return nil, errors.Errorf("Expected the gradient to be a (%d, %d) matrix. Got %v instead", n, n, inputs[1].Shape())
+	}
+
+	// powers[k] = (Aᵀ)ᵏ
+	transposeSquare(a, n)
+	powers := make([][]float64, op.p)
+	if op.p > 0 {
+		powers[0] = identity(n)
+	}
+	for k := 1; k < op.p; k++ {
+		powers[k] = matMulSquare(powers[k-1], a, n)
+	}
+
+	da := make([]float64, n*n)
+	for k := 0; k < op.p; k++ {
+		term := matMulSquare(matMulSquare(powers[k], g, n), powers[op.p-1-k], n)
+		for i, v := range term {
+			da[i] += v
+		}
+	}
+	return squareValue(da, n, dt), nil
+}
+
+func (op matPowDiffOp) returnsPtr() bool    { return false }
+func (op matPowDiffOp) callsExtern() bool   { return false }
+func (op matPowDiffOp) overwriteInput() int { return -1 }
+func (op matPowDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	matPowOp(op).WriteHash(h)
+}
+
+func (op matPowDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op matPowDiffOp) MarshalBinary() ([]byte, error) {
+	return matPowOp(op).MarshalBinary()
+}
+
+func (op *matPowDiffOp) UnmarshalBinary(data []byte) error {
+	return (*matPowOp)(op).UnmarshalBinary(data)
+}
+
+func (op matPowDiffOp) String() string { return fmt.Sprintf("∂MatPow{%d}", op.p) }
+
+/* LU DECOMPOSITION */
+
+// luFactorizations counts the LU decompositions computed by luOp, so that it can be checked that Det and MatInverse share theirs.
+var luFactorizations int64
+
+// luOp computes the LU decomposition of a square matrix A with partial pivoting, PA = LU, which Det and MatInverse are computed from.
+// The result is packed into a (n+1, n) matrix: the first n rows hold U on and above the diagonal and L below it (the unit diagonal of L is left out),
+// and the last row holds the permutation, in the Dtype of A: row i of PA is row perm[i] of A.
+//
+// luOp is not differentiable. The ops that use it take A as well as its decomposition, and differentiate wrt A directly.
+type luOp struct{}
+
+// luOp has this type:
+//		op :: (Float a) ⇒ Matrix a → Matrix a
+func (op luOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	m := newTensorType(2, a)
+	return newFunctionType(m, m)
+}
+
+func (op luOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "luOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	x := inputs[0]
+	if len(x.shape) != 2 || x.shape[0] != x.shape[1] {
+		return nil, errors.Errorf("Expected a square matrix. Got %v instead", x.shape)
+	}
+	return types.Shape{x.shape[0] + 1, x.shape[1]}, nil
+}
+
+func (op luOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op luOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op luOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "luOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var a []float64
+	var n int
+	var dt Dtype
+	if a, n, dt, err = squareOperand(inputs[0]); err != nil {
+		return
+	}
+
+	atomic.AddInt64(&luFactorizations, 1)
+	lu, perm := luDecompose(a, n)
+	packed := append(lu, make([]float64, n)...)
+	for i, p := range perm {
+		packed[n*n+i] = float64(p)
+	}
+	if dt == Float32 {
+		return FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(packed)), tf32.WithShape(n+1, n))), nil
+	}
+	return FromTensor(tf64.NewTensor(tf64.WithBacking(packed), tf64.WithShape(n+1, n))), nil
+}
+
+func (op luOp) returnsPtr() bool      { return false }
+func (op luOp) callsExtern() bool     { return false }
+func (op luOp) overwriteInput() int   { return -1 }
+func (op luOp) WriteHash(h hash.Hash) { h.Write([]byte("lu")) }
+
+func (op luOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op luOp) String() string { return "LU" }
+
+// detOp computes the determinant of a square matrix A from its LU decomposition, as the product of the diagonal of U, negated if the permutation is odd.
+// It takes A and the output of luOp. Only A is differentiated:
+//		∂A = ∂det × det × A⁻ᵀ
+// where A⁻¹ is solved for with the same decomposition. The gradient needs A to be invertible.
+type detOp struct{}
+
+// detOp has this type:
+//		op :: (Float a) ⇒ Matrix a → Matrix a → a
+func (op detOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	m := newTensorType(2, a)
+	return newFunctionType(m, m, a)
+}
+
+func (op detOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "detOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return scalarShape, nil
+}
+
+func (op detOp) DiffWRT(inputs int) []bool { return []bool{true, false} }
+
+func (op detOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "detOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(detDiffOp{}, inputs[1], output, gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx, nil}, nil
+}
+
+func (op detOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "detOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	odv := output.boundTo.(*dualValue)
+
+	var d Value
+	if d, err = (detDiffOp{}).Do(inputs[1].Value(), odv.Value, odv.d); err != nil {
+		return errors.Wrap(err, "detOp.DoDiff()")
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op detOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "detOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var lu []float64
+	var perm []int
+	var dt Dtype
+	if lu, perm, dt, err = luOperand(inputs[1]); err != nil {
+		return
+	}
+
+	det := luDet(lu, perm)
+	if dt == Float32 {
+		return NewScalarValue(float32(det)), nil
+	}
+	return NewScalarValue(det), nil
+}
+
+func (op detOp) returnsPtr() bool      { return false }
+func (op detOp) callsExtern() bool     { return false }
+func (op detOp) overwriteInput() int   { return -1 }
+func (op detOp) WriteHash(h hash.Hash) { h.Write([]byte("det")) }
+
+func (op detOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op detOp) String() string { return "Det" }
+
+// detDiffOp is the derivative of detOp. It takes the output of luOp, the determinant and its gradient, and returns ∂det × det × A⁻ᵀ.
+type detDiffOp struct{}
+
+// detDiffOp has this type:
+//		op :: (Float a) ⇒ Matrix a → a → a → Matrix a
+func (op detDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	m := newTensorType(2, a)
+	return newFunctionType(m, a, a, m)
+}
+
+func (op detDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "detDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+	n := inputs[0].shape[1]
+	return types.Shape{n, n}, nil
+}
+
+func (op detDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op detDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op detDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "detDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var lu, dets []float64
+	var perm []int
+	var dt Dtype
+	if lu, perm, dt, err = luOperand(inputs[0]); err != nil {
+		return
+	}
+	if dets, err = reducedFloats(inputs[1], 1); err != nil {
+		return nil, errors.Wrap(err, "detDiffOp.Do()")
+	}
+	var grad []float64
+	if grad, err = reducedFloats(inputs[2], 1); err != nil {
+		return nil, errors.Wrap(err, "detDiffOp.Do()")
+	}
+
+	n := len(perm)
+	var inv []float64
+	if inv, err = luInverse(lu, perm); err != nil {
+		return nil, errors.Wrap(err, "Cannot differentiate the determinant")
+	}
+	transposeSquare(inv, n)
+	scale := grad[0] * dets[0]
+	for i := range inv {
+		inv[i] *= scale
+	}
+	return squareValue(inv, n, dt), nil
+}
+
+func (op detDiffOp) returnsPtr() bool      { return false }
+func (op detDiffOp) callsExtern() bool     { return false }
+func (op detDiffOp) overwriteInput() int   { return -1 }
+func (op detDiffOp) WriteHash(h hash.Hash) { h.Write([]byte("∂det")) }
+
+func (op detDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op detDiffOp) String() string { return "∂Det" }
+
+// logDetOp computes the log of the absolute value of the determinant of a square matrix A from its LU decomposition, as the sum of the logs
+// of the absolute values of the diagonal of U. Unlike the log of detOp, the determinant itself is never formed, so it does not overflow or underflow
+// for large matrices. For a symmetric positive definite matrix, it is the log determinant. It takes A and the output of luOp. Only A is differentiated:
+//		∂A = ∂y × A⁻ᵀ
+// which needs A to be invertible.
+type logDetOp struct{}
+
+// logDetOp has this type:
+//		op :: (Float a) ⇒ Matrix a → Matrix a → a
+func (op logDetOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	m := newTensorType(2, a)
+	return newFunctionType(m, m, a)
+}
+
+func (op logDetOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "logDetOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return scalarShape, nil
+}
+
+func (op logDetOp) DiffWRT(inputs int) []bool { return []bool{true, false} }
+
+func (op logDetOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "logDetOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(logDetDiffOp{}, inputs[1], gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx, nil}, nil
+}
+
+func (op logDetOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "logDetOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	odv := output.boundTo.(*dualValue)
+
+	var d Value
+	if d, err = (logDetDiffOp{}).Do(inputs[1].Value(), odv.d); err != nil {
+		return errors.Wrap(err, "logDetOp.DoDiff()")
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op logDetOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "logDetOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var lu []float64
+	var perm []int
+	var dt Dtype
+	if lu, perm, dt, err = luOperand(inputs[1]); err != nil {
+		return
+	}
+
+	n := len(perm)
+	var logDet float64
+	for i := 0; i < n; i++ {
+		logDet += math.Log(math.Abs(lu[i*n+i]))
+	}
+	if dt == Float32 {
+		return NewScalarValue(float32(logDet)), nil
+	}
+	return NewScalarValue(logDet), nil
+}
+
+func (op logDetOp) returnsPtr() bool      { return false }
+func (op logDetOp) callsExtern() bool     { return false }
+func (op logDetOp) overwriteInput() int   { return -1 }
+func (op logDetOp) WriteHash(h hash.Hash) { h.Write([]byte("logDet")) }
+
+func (op logDetOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op logDetOp) String() string { return "LogDet" }
+
+// logDetDiffOp is the derivative of logDetOp. It takes the output of luOp and the gradient of the log determinant, and returns ∂y × A⁻ᵀ.
+type logDetDiffOp struct{}
+
+// logDetDiffOp has this type:
+//		op :: (Float a) ⇒ Matrix a → a → Matrix a
+func (op logDetDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	m := newTensorType(2, a)
+	return newFunctionType(m, a, m)
+}
+
+func (op logDetDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "logDetDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	n := inputs[0].shape[1]
+	return types.Shape{n, n}, nil
+}
+
+func (op logDetDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op logDetDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op logDetDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "logDetDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var lu, grad []float64
+	var perm []int
+	var dt Dtype
+	if lu, perm, dt, err = luOperand(inputs[0]); err != nil {
+		return
+	}
+	if grad, err = reducedFloats(inputs[1], 1); err != nil {
+		return nil, errors.Wrap(err, "logDetDiffOp.Do()")
+	}
+
+	n := len(perm)
+	var inv []float64
+	if inv, err = luInverse(lu, perm); err != nil {
+		return nil, errors.Wrap(err, "Cannot differentiate the log determinant")
+	}
+	transposeSquare(inv, n)
+	for i := range inv {
+		inv[i] *= grad[0]
+	}
+	return squareValue(inv, n, dt), nil
+}
+
+func (op logDetDiffOp) returnsPtr() bool      { return false }
+func (op logDetDiffOp) callsExtern() bool     { return false }
+func (op logDetDiffOp) overwriteInput() int   { return -1 }
+func (op logDetDiffOp) WriteHash(h hash.Hash) { h.Write([]byte("∂logDet")) }
+
+func (op logDetDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op logDetDiffOp) String() string { return "∂LogDet" }
+
+// matInverseOp computes the inverse of a square matrix A from its LU decomposition, by solving for each column of the identity.
+// It takes A and the output of luOp, and returns an error if A is singular. Only A is differentiated:
+//		∂A = -A⁻ᵀ × ∂Y × A⁻ᵀ
+// where A⁻¹ is the output.
+type matInverseOp struct{}
+
+// matInverseOp has this type:
+//		op :: (Float a) ⇒ Matrix a → Matrix a → Matrix a
+func (op matInverseOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	m := newTensorType(2, a)
+	return newFunctionType(m, m, m)
+}
+
+func (op matInverseOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "matInverseOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op matInverseOp) DiffWRT(inputs int) []bool { return []bool{true, false} }
+
+func (op matInverseOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "matInverseOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(matInverseDiffOp{}, output, gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx, nil}, nil
+}
+
+func (op matInverseOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "matInverseOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	odv := output.boundTo.(*dualValue)
+
+	var d Value
+	if d, err = (matInverseDiffOp{}).Do(odv.Value, odv.d); err != nil {
+		return errors.Wrap(err, "matInverseOp.DoDiff()")
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op matInverseOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "matInverseOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var lu []float64
+	var perm []int
+	var dt Dtype
+	if lu, perm, dt, err = luOperand(inputs[1]); err != nil {
+		return
+	}
+
+	var inv []float64
+	if inv, err = luInverse(lu, perm); err != nil {
+		return
+	}
+	return squareValue(inv, len(perm), dt), nil
+}
+
+func (op matInverseOp) returnsPtr() bool      { return false }
+func (op matInverseOp) callsExtern() bool     { return false }
+func (op matInverseOp) overwriteInput() int   { return -1 }
+func (op matInverseOp) WriteHash(h hash.Hash) { h.Write([]byte("matInverse")) }
+
+func (op matInverseOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op matInverseOp) String() string { return "MatInverse" }
+
+// matInverseDiffOp is the derivative of matInverseOp. It takes the inverse Y and its gradient, and returns -Yᵀ × ∂Y × Yᵀ.
+type matInverseDiffOp struct{}
+
+// matInverseDiffOp has this type:
+//		op :: (Float a) ⇒ Matrix a → Matrix a → Matrix a
+func (op matInverseDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	m := newTensorType(2, a)
+	return newFunctionType(m, m, m)
+}
+
+func (op matInverseDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "matInverseDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op matInverseDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op matInverseDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op matInverseDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "matInverseDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var y, g []float64
+	var n, gn int
+	var dt Dtype
+	if y, n, dt, err = squareOperand(inputs[0]); err != nil {
+		return
+	}
+	if g, gn, _, err = squareOperand(inputs[1]); err != nil {
+		return
+	}
+	if gn != n {
+		return nil, errors.Errorf("Expected the gradient to be a (%d, %d) matrix. Got %v instead", n, n, inputs[1].Shape())
+	}
+
+	transposeSquare(y, n)
+	da := matMulSquare(matMulSquare(y, g, n), y, n)
+	for i := range da {
+		da[i] = -da[i]
+	}
+	return squareValue(da, n, dt), nil
+}
+
+func (op matInverseDiffOp) returnsPtr() bool      { return false }
+func (op matInverseDiffOp) callsExtern() bool     { return false }
+func (op matInverseDiffOp) overwriteInput() int   { return -1 }
+func (op matInverseDiffOp) WriteHash(h hash.Hash) { h.Write([]byte("∂matInverse")) }
+
+func (op matInverseDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op matInverseDiffOp) String() string { return "∂MatInverse" }
+
+// luDecompose computes the LU decomposition of a (n, n) matrix a with partial pivoting, with Doolittle's algorithm.
+// L and U are packed into a copy of a (see luOp), and perm is the permutation of the rows. A singular matrix leaves a 0 on the diagonal of U.
+func luDecompose(a []float64, n int) (lu []float64, perm []int) {
+	lu = append([]float64(nil), a...)
+	perm = intRange(0, n)
+	for k := 0; k < n; k++ {
+		// pick the largest pivot in the column
+		p := k
+		for i := k + 1; i < n; i++ {
+			if math.Abs(lu[i*n+k]) > math.Abs(lu[p*n+k]) {
+				p = i
+			}
+		}
+		if p != k {
+			for j := 0; j < n; j++ {
+				lu[k*n+j], lu[p*n+j] = lu[p*n+j], lu[k*n+j]
+			}
+			perm[k], perm[p] = perm[p], perm[k]
+		}
+
+		pivot := lu[k*n+k]
+		if pivot == 0 {
+			continue
+		}
+		for i := k + 1; i < n; i++ {
+			lu[i*n+k] /= pivot
+			l := lu[i*n+k]
+			for j := k + 1; j < n; j++ {
+				lu[i*n+j] -= l * lu[k*n+j]
+			}
+		}
+	}
+	return
+}
+
+// luOperand unpacks the output of luOp
+func luOperand(v Value) (lu []float64, perm []int, dt Dtype, err error) {
+	var packed []float64
+	var shp types.Shape
+	if packed, shp, dt, err = floatsOperand(v); err != nil {
+		return
+	}
+	if len(shp) != 2 || shp[0] != shp[1]+1 {
+		err = errors.Errorf("Expected a packed LU decomposition shaped (n+1, n). Got %v instead", shp)
+		return
+	}
+
+	n := shp[1]
+	lu = packed[:n*n]
+	perm = make([]int, n)
+	for i, p := range packed[n*n:] {
+		perm[i] = int(p)
+	}
+	return
+}
+
+// luDet computes the determinant from the LU decomposition: the product of the diagonal of U, negated if the permutation is odd
+func luDet(lu []float64, perm []int) float64 {
+	n := len(perm)
+	det := 1.0
+	for i := 0; i < n; i++ {
+		det *= lu[i*n+i]
+	}
+
+	// each cycle of length c takes c-1 swaps
+	visited := make([]bool, n)
+	swaps := 0
+	for i := range perm {
+		for j := i; !visited[j]; j = perm[j] {
+			visited[j] = true
+			if j != i {
+				swaps++
+			}
+		}
+	}
+	if swaps%2 == 1 {
+		det = -det
+	}
+	return det
+}
+
+// luInverse solves for the inverse from the LU decomposition, one column at a time: L y = P eⱼ by forward substitution, then U x = y by back substitution.
+func luInverse(lu []float64, perm []int) (inv []float64, err error) {
+	n := len(perm)
+	for i := 0; i < n; i++ {
+		if lu[i*n+i] == 0 {
+			return nil, errors.Errorf("Cannot invert a singular matrix")
+		}
+	}
+
+	inv = make([]float64, n*n)
+	y := make([]float64, n)
+	for c := 0; c < n; c++ {
+		for i := 0; i < n; i++ {
+			var s float64
+			if perm[i] == c {
+				s = 1
+			}
+			for k := 0; k < i; k++ {
+				s -= lu[i*n+k] * y[k]
+			}
+			y[i] = s
+		}
+		for i := n - 1; i >= 0; i-- {
+			s := y[i]
+			for k := i + 1; k < n; k++ {
+				s -= lu[i*n+k] * inv[k*n+c]
+			}
+			inv[i*n+c] = s / lu[i*n+i]
+		}
+	}
+	return
+}
+
+// cholesky computes the lower triangular L of a (n, n) matrix a, such that a = L × Lᵀ, with the Cholesky–Banachiewicz algorithm.
+// It returns an error if a is not positive definite.
+func cholesky(a []float64, n int) (l []float64, err error) {
+	l = make([]float64, n*n)
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			s := a[i*n+j]
+			for k := 0; k < j; k++ {
+				s -= l[i*n+k] * l[j*n+k]
+			}
+
+			if i != j {
+				l[i*n+j] = s / l[j*n+j]
+				continue
+			}
+
+			if !(s > 0) || math.IsInf(s, 0) {
+				return nil, errors.Errorf("Cannot perform a Cholesky decomposition of a matrix that is not symmetric positive definite: the pivot of row %d is %v", i, s)
+			}
+			l[i*n+i] = math.Sqrt(s)
+		}
+	}
+	return
+}
+
+// solveLowerT solves Lᵀ X = B for X, where L is a (n, n) lower triangular matrix and B is (n, n), by back substitution.
+func solveLowerT(l, b []float64, n int) []float64 {
+	x := make([]float64, n*n)
+	for c := 0; c < n; c++ {
+		for i := n - 1; i >= 0; i-- {
+			s := b[i*n+c]
+			for k := i + 1; k < n; k++ {
+				s -= l[k*n+i] * x[k*n+c]
+			}
+			x[i*n+c] = s / l[i*n+i]
+		}
+	}
+	return x
+}
+
+// transposeSquare transposes a (n, n) matrix in place
+func transposeSquare(a []float64, n int) {
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			a[i*n+j], a[j*n+i] = a[j*n+i], a[i*n+j]
+		}
+	}
+}
+
+// matMulSquare multiplies two (n, n) matrices
+func matMulSquare(a, b []float64, n int) []float64 {
+	c := make([]float64, n*n)
+	for i := 0; i < n; i++ {
+		for k := 0; k < n; k++ {
+			aik := a[i*n+k]
+			for j := 0; j < n; j++ {
+				c[i*n+j] += aik * b[k*n+j]
+			}
+		}
+	}
+	return c
+}
+
+// identity returns the (n, n) identity matrix
+func identity(n int) []float64 {
+	a := make([]float64, n*n)
+	for i := 0; i < n; i++ {
+		a[i*n+i] = 1
+	}
+	return a
+}
+
+// squareOperand returns a copy of the elements of a square matrix as float64s, along with its size and Dtype.
+func squareOperand(v Value) (a []float64, n int, dt Dtype, err error) {
+	t, ok := v.(Tensor)
+	if !ok || t.Dims() != 2 || t.Shape()[0] != t.Shape()[1] {
+		err = errors.Errorf("Expected a square matrix. Got %v instead", v)
+		return
+	}
+
+	n = t.Shape()[0]
+	dt = t.Dtype()
+	switch tt := t.Tensor.(type) {
+	case *tf64.Tensor:
+		a = append([]float64(nil), materializedF64s(tt)...)
+	case *tf32.Tensor:
+		a = f32sToF64s(materializedF32s(tt))
+	default:
+		err = errors.Errorf(nyiFail, "squareOperand", t.Tensor)
+	}
+	return
+}
+
+// squareValue creates a (n, n) matrix of the given Dtype out of a
+func squareValue(a []float64, n int, dt Dtype) Value {
+	if dt == Float32 {
+		return FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(a)), tf32.WithShape(n, n)))
+	}
+	return FromTensor(tf64.NewTensor(tf64.WithBacking(a), tf64.WithShape(n, n)))
+}
@@ -0,0 +1,439 @@
+package gorgonia
+
+import (
+	"math"
+	"sync/atomic"
+	"testing"
+
+	tf32 "github.com/chewxy/gorgonia/tensor/f32"
+	tf64 "github.com/chewxy/gorgonia/tensor/f64"
+	"github.com/chewxy/gorgonia/tensor/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCholesky(t *testing.T) {
+	assert := assert.New(t)
+
+	n := 3
+	as := []float64{
+		4, 2, -2,
+		2, 10, 2,
+		-2, 2, 5,
+	}
+	ws := []float64{
+		1, 2, -1,
+		0.5, -3, 2,
+		1, 1, 4,
+	}
+	correct := []float64{
+		2, 0, 0,
+		1, 3, 0,
+		-1, 1, math.Sqrt(3),
+	}
+
+	// cost = Σ w * L
+	cost := func(a []float64) float64 {
+		l, err := cholesky(a, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var retVal float64
+		for i, v := range l {
+			retVal += ws[i] * v
+		}
+		return retVal
+	}
+	xs := clonef64s(as)
+	correctDA := numericGrad(xs, func() float64 { return cost(xs) })
+
+	// the upper triangle is never read
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			assert.Equal(0.0, correctDA[i*n+j])
+		}
+	}
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		a := NewMatrix(g, Float64, WithName("a"), WithShape(n, n), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(as)), tf64.WithShape(n, n))))
+		w := NewMatrix(g, Float64, WithName("w"), WithShape(n, n), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(ws)), tf64.WithShape(n, n))))
+
+		l, err := Cholesky(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(types.Shape{n, n}, l.Shape())
+
+		c := Must(Sum(Must(HadamardProd(l, w))))
+
+		if useTape {
+			if _, err = Grad(c, a); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.True(floatsClose(correct, extractF64s(l.Value())), "Tape %t: %v", useTape, l.Value())
+
+		da, err := a.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(correctDA, extractF64s(da)), "Tape %t. Expected %v. Got %v", useTape, correctDA, da)
+	}
+
+	// float32
+	a32 := FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(as)), tf32.WithShape(n, n)))
+	l32, err := choleskyOp{}.Do(a32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(floatsClose(correct, f32sToF64s(l32.Data().([]float32))))
+
+	// not positive definite
+	notSPD := FromTensor(tf64.NewTensor(tf64.WithBacking([]float64{1, 2, 2, 1}), tf64.WithShape(2, 2)))
+	if _, err = (choleskyOp{}).Do(notSPD); err == nil {
+		t.Error("Expected an error with a matrix that is not positive definite")
+	}
+
+	g := NewGraph()
+	m := NewMatrix(g, Float64, WithName("m"), WithShape(2, 3))
+	if _, err = Cholesky(m); err == nil {
+		t.Error("Expected an error with a matrix that is not square")
+	}
+	v := NewVector(g, Float64, WithName("v"), WithShape(3))
+	if _, err = Cholesky(v); err == nil {
+		t.Error("Expected an error with a vector")
+	}
+}
+
+func TestMatPow(t *testing.T) {
+	assert := assert.New(t)
+
+	n := 3
+	as := []float64{
+		1, 2, 0,
+		-1, 0.5, 1,
+		0, 1, -2,
+	}
+	ws := []float64{
+		1, 2, -1,
+		0.5, -3, 2,
+		1, 1, 4,
+	}
+
+	// naivePow multiplies p copies of a, one at a time
+	naivePow := func(a []float64, p int) []float64 {
+		res := identity(n)
+		for i := 0; i < p; i++ {
+			res = matMulSquare(res, a, n)
+		}
+		return res
+	}
+
+	for _, p := range []int{2, 3} {
+		// cost = Σ w * Aᵖ
+		cost := func(a []float64) float64 {
+			var retVal float64
+			for i, v := range naivePow(a, p) {
+				retVal += ws[i] * v
+			}
+			return retVal
+		}
+		xs := clonef64s(as)
+		correctDA := numericGrad(xs, func() float64 { return cost(xs) })
+		correct := naivePow(as, p)
+
+		for _, useTape := range []bool{true, false} {
+			g := NewGraph()
+			a := NewMatrix(g, Float64, WithName("a"), WithShape(n, n), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(as)), tf64.WithShape(n, n))))
+			w := NewMatrix(g, Float64, WithName("w"), WithShape(n, n), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(ws)), tf64.WithShape(n, n))))
+
+			ap, err := MatPow(a, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(types.Shape{n, n}, ap.Shape())
+
+			c := Must(Sum(Must(HadamardProd(ap, w))))
+
+			if useTape {
+				if _, err = Grad(c, a); err != nil {
+					t.Fatal(err)
+				}
+				prog, locMap, err := Compile(g)
+				if err != nil {
+					t.Fatal(err)
+				}
+				m := NewTapeMachine(prog, locMap)
+				if err = m.RunAll(); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				m := NewLispMachine(g)
+				if err = m.RunAll(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			assert.True(floatsClose(correct, extractF64s(ap.Value())), "p=%d Tape %t: %v", p, useTape, ap.Value())
+
+			da, err := a.Grad()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.True(floatsClose(correctDA, extractF64s(da)), "p=%d Tape %t. Expected %v. Got %v", p, useTape, correctDA, da)
+		}
+	}
+
+	// float32, and powers that take more than one squaring
+	a32 := FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(as)), tf32.WithShape(n, n)))
+	for _, p := range []int{0, 1, 5, 8} {
+		ap, err := matPowOp{p: p}.Do(a32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(naivePow(as, p), f32sToF64s(ap.Data().([]float32))), "p=%d: %v", p, ap)
+	}
+
+	// A⁰ does not depend on A
+	g := NewGraph()
+	a := NewMatrix(g, Float64, WithName("a"), WithShape(n, n))
+	a0, err := MatPow(a, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Grad(Must(Sum(a0)), a); err == nil {
+		t.Error("Expected an error differentiating A⁰")
+	}
+
+	m := NewMatrix(g, Float64, WithName("m"), WithShape(2, 3))
+	if _, err = MatPow(m, 2); err == nil {
+		t.Error("Expected an error with a matrix that is not square")
+	}
+	if _, err = MatPow(a, -1); err == nil {
+		t.Error("Expected an error with a negative power")
+	}
+}
+
+func TestDetMatInverse(t *testing.T) {
+	assert := assert.New(t)
+
+	n := 3
+	// the 0 in the corner needs the rows to be pivoted
+	as := []float64{
+		0, 2, 1,
+		1, 1, 0,
+		2, 0, 3,
+	}
+	ws := []float64{
+		1, 2, -1,
+		0.5, -3, 2,
+		1, 1, 4,
+	}
+
+	// det3 and inv3 are the cofactor expansions of the determinant and the inverse of a 3x3 matrix
+	det3 := func(a []float64) float64 {
+		return a[0]*(a[4]*a[8]-a[5]*a[7]) - a[1]*(a[3]*a[8]-a[5]*a[6]) + a[2]*(a[3]*a[7]-a[4]*a[6])
+	}
+	inv3 := func(a []float64) []float64 {
+		d := det3(a)
+		return []float64{
+			(a[4]*a[8] - a[5]*a[7]) / d, (a[2]*a[7] - a[1]*a[8]) / d, (a[1]*a[5] - a[2]*a[4]) / d,
+			(a[5]*a[6] - a[3]*a[8]) / d, (a[0]*a[8] - a[2]*a[6]) / d, (a[2]*a[3] - a[0]*a[5]) / d,
+			(a[3]*a[7] - a[4]*a[6]) / d, (a[1]*a[6] - a[0]*a[7]) / d, (a[0]*a[4] - a[1]*a[3]) / d,
+		}
+	}
+
+	// cost = det(A) + Σ w * A⁻¹
+	cost := func(a []float64) float64 {
+		retVal := det3(a)
+		for i, v := range inv3(a) {
+			retVal += ws[i] * v
+		}
+		return retVal
+	}
+	xs := clonef64s(as)
+	correctDA := numericGrad(xs, func() float64 { return cost(xs) })
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		a := NewMatrix(g, Float64, WithName("a"), WithShape(n, n), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(as)), tf64.WithShape(n, n))))
+		w := NewMatrix(g, Float64, WithName("w"), WithShape(n, n), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(ws)), tf64.WithShape(n, n))))
+
+		det, err := Det(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inv, err := MatInverse(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(scalarShape, det.Shape())
+		assert.Equal(types.Shape{n, n}, inv.Shape())
+
+		c := Must(Add(det, Must(Sum(Must(HadamardProd(inv, w))))))
+
+		var detV, invV Value
+		Read(det, &detV)
+		Read(inv, &invV)
+
+		before := atomic.LoadInt64(&luFactorizations)
+		if useTape {
+			if _, err = Grad(c, a); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		assert.Equal(int64(1), atomic.LoadInt64(&luFactorizations)-before, "Tape %t: Det and MatInverse should share one LU factorization", useTape)
+
+		assert.InDelta(-8.0, extractF64(detV), 1e-9, "Tape %t", useTape)
+		assert.True(floatsClose(inv3(as), extractF64s(invV)), "Tape %t: %v", useTape, invV)
+
+		da, err := a.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(correctDA, extractF64s(da)), "Tape %t. Expected %v. Got %v", useTape, correctDA, da)
+	}
+
+	// errors
+	g := NewGraph()
+	rect := NewMatrix(g, Float64, WithShape(2, 3))
+	_, err := Det(rect)
+	assert.NotNil(err)
+	_, err = MatInverse(rect)
+	assert.NotNil(err)
+
+	singular := FromTensor(tf64.NewTensor(tf64.WithBacking([]float64{1, 2, 2, 4}), tf64.WithShape(2, 2)))
+	lu, err := luOp{}.Do(singular)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := detOp{}.Do(singular, lu)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(0.0, extractF64(d))
+	_, err = matInverseOp{}.Do(singular, lu)
+	assert.NotNil(err)
+}
+
+func TestLogDet(t *testing.T) {
+	assert := assert.New(t)
+
+	n := 3
+	// a well conditioned symmetric positive definite matrix
+	as := []float64{
+		4, 1, 0.5,
+		1, 3, 0.2,
+		0.5, 0.2, 2,
+	}
+	det3 := func(a []float64) float64 {
+		return a[0]*(a[4]*a[8]-a[5]*a[7]) - a[1]*(a[3]*a[8]-a[5]*a[6]) + a[2]*(a[3]*a[7]-a[4]*a[6])
+	}
+
+	// cost = 2 × log(det(A))
+	xs := clonef64s(as)
+	correctDA := numericGrad(xs, func() float64 { return 2 * math.Log(det3(xs)) })
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		a := NewMatrix(g, Float64, WithName("a"), WithShape(n, n), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(as)), tf64.WithShape(n, n))))
+
+		logDet, err := LogDet(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(scalarShape, logDet.Shape())
+
+		var logDetV Value
+		Read(logDet, &logDetV)
+		c := Must(Mul(logDet, NewConstant(2.0)))
+
+		if useTape {
+			if _, err = Grad(c, a); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = NewTapeMachine(prog, locMap).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			if err = NewLispMachine(g).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.InDelta(math.Log(det3(as)), extractF64(logDetV), 1e-12, "Tape %t", useTape)
+
+		// the gradient is 2 × A⁻ᵀ
+		da, err := a.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(correctDA, extractF64s(da)), "Tape %t. Expected %v. Got %v", useTape, correctDA, da)
+	}
+
+	// the determinant of a 40x40 diagonal matrix of 1e10s overflows, but its log does not
+	big := make([]float64, 40*40)
+	for i := 0; i < 40; i++ {
+		big[i*40+i] = 1e10
+	}
+	bigT := FromTensor(tf64.NewTensor(tf64.WithBacking(big), tf64.WithShape(40, 40)))
+	lu, err := luOp{}.Do(bigT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := detOp{}.Do(bigT, lu)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(math.IsInf(extractF64(d), 1))
+	ld, err := logDetOp{}.Do(bigT, lu)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.InDelta(400*math.Ln10, extractF64(ld), 1e-9)
+
+	// float32
+	a32 := FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(as)), tf32.WithShape(n, n)))
+	if lu, err = (luOp{}).Do(a32); err != nil {
+		t.Fatal(err)
+	}
+	if ld, err = (logDetOp{}).Do(a32, lu); err != nil {
+		t.Fatal(err)
+	}
+	assert.InDelta(math.Log(det3(as)), float64(ld.Data().(float32)), 1e-5)
+
+	g := NewGraph()
+	_, err = LogDet(NewMatrix(g, Float64, WithShape(2, 3)))
+	assert.NotNil(err)
+}
@@ -7,6 +7,9 @@ mathematical operations, they're classified into 3 main types:
 	elemUnaryOp - a representation of a mathematical operation that is performed elmentwise
 	linAlgBinOp - a representation of a binary mathematical operation that is performed on matrices
 
+addScalarOp is a specialized elemBinOp for adding a scalar to a tensor, and powConstOp is a specialized Pow for integer powers.
+applyFnOp applies a Go function supplied by the user elementwise, much like an elemUnaryOp.
+
 The individual operators are further exanded on operator*.go files. Their datatypes are often embedded in the datatypes here.
 
 For all data type, the methods are standardized by arrangement in the order the Op interface is defined.
@@ -19,8 +22,11 @@ import (
 	"fmt"
 	"hash"
 	"hash/fnv"
+	"math"
+	"sync/atomic"
 
 	"github.com/chewxy/gorgonia/tensor"
+	tb "github.com/chewxy/gorgonia/tensor/b"
 	tf32 "github.com/chewxy/gorgonia/tensor/f32"
 	tf64 "github.com/chewxy/gorgonia/tensor/f64"
 	"github.com/chewxy/gorgonia/tensor/types"
@@ -69,6 +75,20 @@ func newEBOByType(ot ʘBinaryOperatorType, at, bt Type) elemBinOp {
 	}
 }
 
+// withIntResult returns a copy of the comparison op that returns Int 1s and 0s, rather than Bools or 1s and 0s in the Dtype of its operands.
+// It has no effect on arithmetic and logical ops.
+func (op elemBinOp) withIntResult() elemBinOp {
+	switch o := op.ʘBinaryOperator.(type) {
+	case scalarBinOp:
+		o.asInt = true
+		op.ʘBinaryOperator = o
+	case tBinOp:
+		o.asInt = true
+		op.ʘBinaryOperator = o
+	}
+	return op
+}
+
 func newElemBinOp(ot ʘBinaryOperatorType, a, b *Node) elemBinOp {
 	at := prune(a.t)
 	bt := prune(b.t)
@@ -86,6 +106,14 @@ func newElemBinOp(ot ʘBinaryOperatorType, a, b *Node) elemBinOp {
 // 		elemBinOp :: (Floats a) ⇒ Tensor a → a → Tensor Bool
 //		elemBinOp :: (Floats a) ⇒ a → Tensor a → Bool
 //
+// Comparison operators made with withIntResult return Int instead of Bool:
+// 		elemBinOp :: (Floats a) ⇒ Tensor a → Tensor a → Tensor Int
+//		elemBinOp :: (Floats a) ⇒ a → a → Int
+//
+// Logical operators (∧, ∨, ⊕) return the same type as their inputs, which may be Bool or the numeric 1/0 representation:
+// 		elemBinOp :: (Logical a) ⇒ Tensor a → Tensor a → Tensor a
+//		elemBinOp :: (Logical a) ⇒ a → a → a
+//
 // To make things clearer, it helps to consider elemBinOp to be the representation of
 // a dispatch table for different functions. In a sense it's "overloading" functions.
 //
@@ -93,6 +121,9 @@ func newElemBinOp(ot ʘBinaryOperatorType, a, b *Node) elemBinOp {
 // Type() happens pretty much at close to run time
 func (op elemBinOp) Type() Type {
 	a := newTypeVariable("a", withTVConstraints(floats))
+	if op.isLogical() {
+		a = newTypeVariable("a", withTVConstraints(logicals))
+	}
 
 	var a0, a1, retType Type
 	switch arg0 := op.arg0.(type) {
@@ -127,15 +158,19 @@ func (op elemBinOp) Type() Type {
 		a1 = a
 	}
 
-	if op.isArith() || (!op.isArith() && op.retSame) {
+	if !op.returnsInt() && (op.isArith() || op.isLogical() || (!op.isArith() && op.retSame)) {
 		return newFunctionType(a0, a1, retType)
 	}
 
+	of := Bool
+	if op.returnsInt() {
+		of = Int
+	}
 	switch rt := retType.(type) {
 	case *TensorType:
-		rt.of = Bool
+		rt.of = of
 	default:
-		retType = Bool
+		retType = of
 	}
 
 	return newFunctionType(a0, a1, retType)
@@ -238,17 +273,20 @@ func (op elemBinOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes,
 
 	if retVal, err = ʘBinOpDiffExprs[b](inputs[0], inputs[1], output, gradNode); err == nil {
 		for _, n := range retVal {
-			n.setGroup(gradClust)
+			if n != nil {
+				n.setGroup(gradClust)
+			}
 		}
 	}
 
 	// needed to handle scalar gradients such as b in the logit regression example
 	for i, grad := range retVal {
-		if inputs[i].IsScalar() && !grad.IsScalar() {
-			if retVal[i], err = Sum(grad); err != nil {
-				err = errors.Wrap(err, operationError)
-				return
-			}
+		if grad == nil {
+			continue // not differentiated, like the constant exponent of a Pow
+		}
+		if retVal[i], err = reduceGradientToShape(grad, inputs[i].shape); err != nil {
+			err = errors.Wrap(err, operationError)
+			return
 		}
 	}
 
@@ -273,24 +311,66 @@ func (op elemBinOp) DoDiff(inputs Nodes, output *Node) (err error) {
 	//handle scalar gradients
 	for _, in := range inputs {
 		indv := in.boundTo.(*dualValue)
-		if _, ok := indv.d.(Scalar); in.IsScalar() && !ok {
-			indvdT := indv.d.(Tensor)
-			var d Value
-			var t types.Tensor
-			if t, err = tensor.Sum(indvdT.Tensor); err != nil {
-				return errors.Wrap(err, operationError)
-			}
-
-			if d, err = anyToValue(t.ScalarValue()); err != nil {
-				return errors.Wrap(err, operationError)
+		var d Value
+		if d, err = reduceGradValueToShape(indv.d, in.shape); err != nil {
+			return errors.Wrap(err, operationError)
+		}
+		if d != indv.d {
+			if t, ok := indv.d.(Tensor); ok {
+				returnTensor(t)
 			}
-			returnTensor(indvdT)
 			indv.SetDeriv(d)
 		}
 	}
 	return
 }
 
+// reduceGradientToShape sums a gradient back down to the shape of the input it is the gradient of, undoing a broadcast.
+// Only the axes that were broadcast are summed: a scalar target sums over everything, and otherwise the shapes are aligned on their last axes,
+// as in BroadcastTo, and the leading axes and the axes where the target is 1 are summed. Gradients that already have as many elements as the target are returned as is.
+func reduceGradientToShape(grad *Node, target types.Shape) (retVal *Node, err error) {
+	switch {
+	case target.IsScalar():
+		if grad.IsScalar() {
+			return grad, nil
+		}
+		return Sum(grad)
+	case grad.shape.TotalSize() == target.TotalSize():
+		return grad, nil
+	}
+
+	if retVal, err = applyOp(broadcastToDiffOp{from: target.Clone(), to: grad.shape.Clone()}, grad); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	retVal.setGroup(gradClust)
+	return
+}
+
+// reduceGradValueToShape is the counterpart of reduceGradientToShape for values. The gradient is returned as is if there is nothing to reduce.
+func reduceGradValueToShape(grad Value, target types.Shape) (retVal Value, err error) {
+	t, ok := grad.(Tensor)
+	if !ok {
+		return grad, nil
+	}
+
+	switch {
+	case target.IsScalar():
+		var sum types.Tensor
+		if sum, err = tensor.Sum(t.Tensor); err != nil {
+			return nil, errors.Wrap(err, sumFail)
+		}
+		return anyToValue(sum.ScalarValue())
+	case t.Shape().TotalSize() == target.TotalSize():
+		return grad, nil
+	}
+
+	back := broadcastToDiffOp{from: target.Clone(), to: t.Shape().Clone()}
+	if retVal, err = back.Do(grad); err != nil {
+		return nil, errors.Wrapf(err, doFail, back)
+	}
+	return
+}
+
 func (op elemBinOp) returnsPtr() bool {
 	if _, ok := op.arg0.(*TensorType); ok {
 		return true
@@ -318,15 +398,79 @@ func (op elemBinOp) WriteHash(h hash.Hash) {
 		panic(err)
 	}
 
-	fmt.Fprintf(h, "%v,%v", op.arg0, op.arg1)
+	// a + b is b + a, so the operands of a commutative operator are hashed in a canonical order
+	a, b := op.arg0, op.arg1
+	if op.isCommutative() && typeHash(b) < typeHash(a) {
+		a, b = b, a
+	}
+	writeTypeHash(h, a)
+	writeTypeHash(h, b)
+	if op.returnsInt() {
+		h.Write([]byte("int"))
+	}
 }
 
+// isCommutative returns true if the operator is commutative, in which case the order of the operands does not matter
+func (op elemBinOp) isCommutative() bool { return op.binOpType().isCommutative() }
+
 func (op elemBinOp) Hashcode() uint32 {
 	h := fnv.New32a()
 	op.WriteHash(h)
 	return h.Sum32()
 }
 
+// MarshalBinary writes out the operator and the pruned types of the operands. The types are needed to rebuild the op exactly,
+// as they decide what Type() returns.
+func (op elemBinOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	switch o := op.ʘBinaryOperator.(type) {
+	case scalarBinOp:
+		w.writeByte(0)
+		w.writeByte(byte(o.ʘBinaryOperatorType))
+		w.writeByte(byte(o.t))
+		w.writeBool(o.asInt)
+	case tBinOp:
+		w.writeByte(1)
+		w.writeByte(byte(o.ʘBinaryOperatorType))
+		w.writeBool(o.tensorLeft)
+		w.writeBool(o.asInt)
+	default:
+		w.fail("Cannot write out the binary operator %v of %T", op.ʘBinaryOperator, op.ʘBinaryOperator)
+	}
+	w.writeType(op.arg0)
+	w.writeType(op.arg1)
+	w.writeBool(op.retSame)
+	return w.bytes()
+}
+
+func (op *elemBinOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	kind := r.readByte()
+	ot := ʘBinaryOperatorType(r.readByte())
+	r.check(ot < maxʘBinaryOpType, "Expected a binary operator. Got %v instead", ot)
+	switch kind {
+	case 0:
+		op.ʘBinaryOperator = scalarBinOp{ʘBinaryOperatorType: ot, t: r.readDtype(), asInt: r.readBool()}
+	case 1:
+		op.ʘBinaryOperator = tBinOp{ʘBinaryOperatorType: ot, tensorLeft: r.readBool(), asInt: r.readBool()}
+	default:
+		r.check(false, "Expected a scalar or a tensor binary operator. Got kind %d instead", kind)
+	}
+	op.arg0 = r.readType()
+	op.arg1 = r.readType()
+	op.retSame = r.readBool()
+
+	// the operator has to agree with the types of the operands, as newEBOByType would have picked it
+	_, leftTensor := op.arg0.(*TensorType)
+	_, rightTensor := op.arg1.(*TensorType)
+	if o, ok := op.ʘBinaryOperator.(tBinOp); ok {
+		r.check(o.tensorLeft == leftTensor && (leftTensor || rightTensor), "%v does not take operands of %v and %v", op.ʘBinaryOperator, op.arg0, op.arg1)
+	} else {
+		r.check(!leftTensor && !rightTensor, "%v does not take operands of %v and %v", op.ʘBinaryOperator, op.arg0, op.arg1)
+	}
+	return r.done()
+}
+
 // Fulfils UsePreallocDoer interface
 func (op elemBinOp) UsePreallocDo(prealloc Value, inputs ...Value) (retVal Value, err error) {
 	if !op.returnsPtr() {
@@ -401,6 +545,8 @@ func newElemUnaryOp(op ʘUnaryOperatorType, a *Node) elemUnaryOp {
 		operator = sf32UnaryOperators[op]
 	case Float64:
 		operator = sf64UnaryOperators[op]
+	case Bool:
+		operator = sbUnaryOperators[op]
 	}
 
 	return elemUnaryOp{
@@ -507,11 +653,77 @@ func (op elemUnaryOp) Hashcode() uint32 {
 	return h.Sum32()
 }
 
+// MarshalBinary writes out the operator as its Dtype and its ʘUnaryOperatorType, from which the operator is looked up again
+func (op elemUnaryOp) MarshalBinary() ([]byte, error) {
+	var dt Dtype
+	switch op.ʘUnaryOperator.(type) {
+	case *sf64UnaryOperator:
+		dt = Float64
+	case *sf32UnaryOperator:
+		dt = Float32
+	case *sbUnaryOperator:
+		dt = Bool
+	default:
+		return nil, errors.Errorf("Cannot write out the unary operator %v of %T", op.ʘUnaryOperator, op.ʘUnaryOperator)
+	}
+
+	w := newParamWriter()
+	w.writeByte(byte(dt))
+	w.writeByte(byte(op.unaryOpType()))
+	w.writeBool(op.argTensor)
+	w.writeBool(op.numericResult)
+	return w.bytes()
+}
+
+func (op *elemUnaryOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	dt := r.readDtype()
+	u := ʘUnaryOperatorType(r.readByte())
+	op.argTensor = r.readBool()
+	op.numericResult = r.readBool()
+	r.check(u < maxʘUnaryOperator, "Expected a unary operator. Got %v instead", u)
+	if r.err != nil {
+		return r.err
+	}
+
+	// the tables hold nil for the operators that are not defined on the Dtype, which must not end up in the interface
+	op.ʘUnaryOperator = nil
+	switch dt {
+	case Float64:
+		if f := sf64UnaryOperators[u]; f != nil {
+			op.ʘUnaryOperator = f
+		}
+	case Float32:
+		if f := sf32UnaryOperators[u]; f != nil {
+			op.ʘUnaryOperator = f
+		}
+	case Bool:
+		if f := sbUnaryOperators[u]; f != nil {
+			op.ʘUnaryOperator = f
+		}
+	}
+	r.check(op.ʘUnaryOperator != nil, "There is no %v operator on %v", u, dt)
+	return r.done()
+}
+
 // fulfils UnsafeDoer interface
 func (op elemUnaryOp) UnsafeDo(inputs ...Value) (Value, error) {
 	return op.do(inputs, types.UseUnsafe())
 }
 
+// fulfils UsePreallocDoer interface
+func (op elemUnaryOp) UsePreallocDo(prealloc Value, inputs ...Value) (retVal Value, err error) {
+	if !op.returnsPtr() {
+		return op.Do(inputs...)
+	}
+
+	t, ok := prealloc.(Tensor)
+	if !ok {
+		return nil, errors.Errorf("Expected Tensor as preallocated value. Got %v of %T instead", prealloc, prealloc)
+	}
+	return op.do(inputs, types.WithReuse(t.Tensor))
+}
+
 // fulfils UnaryOp interface
 
 func (op elemUnaryOp) isUnary() bool { return true }
@@ -543,6 +755,15 @@ func (op elemUnaryOp) do(inputs []Value, opts ...types.FuncOpt) (retVal Value, e
 			fn := (func(float32) float32)(*opFn)
 
 			// TODO: this is pretty shit.... the tf64 lib provides a whole bunch of these
+			var t types.Tensor
+			if t, err = vt.Apply(fn, opts...); err != nil {
+				return nil, errors.Wrap(err, applyFail)
+			}
+			retVal = FromTensor(t)
+		case *tb.Tensor:
+			opFn := op.ʘUnaryOperator.(*sbUnaryOperator)
+			fn := (func(bool) bool)(*opFn)
+
 			var t types.Tensor
 			if t, err = vt.Apply(fn, opts...); err != nil {
 				return nil, errors.Wrap(err, applyFail)
@@ -561,6 +782,10 @@ func (op elemUnaryOp) do(inputs []Value, opts ...types.FuncOpt) (retVal Value, e
 			f := v.v.(float64)
 			opFn := op.ʘUnaryOperator.(*sf64UnaryOperator)
 			retVal = NewScalarValue((*opFn)(f))
+		case Bool:
+			b := v.v.(bool)
+			opFn := op.ʘUnaryOperator.(*sbUnaryOperator)
+			retVal = NewScalarValue((*opFn)(b))
 		default:
 			return nil, errors.Errorf(nyiFail, "elemUnaryOp.do", v.t)
 		}
@@ -573,6 +798,30 @@ func (op elemUnaryOp) do(inputs []Value, opts ...types.FuncOpt) (retVal Value, e
 type linAlgBinOp struct {
 	āBinaryOperator
 	transA, transB bool
+
+	// batched is only used by matVecMulOperator. When it is set, the right operand is a matrix whose columns are the vectors to be multiplied.
+	batched bool
+
+	// highPrecAccum makes a float32 operation accumulate in float64: the inputs are upcast, multiplied, and the result is downcast.
+	highPrecAccum bool
+}
+
+// LinAlgOpt is an option for the linear algebra operations, such as Mul.
+type LinAlgOpt func(*linAlgBinOp)
+
+// WithHighPrecisionAccum makes a float32 multiplication accumulate its sums in float64, and round the result to float32 only at the end.
+// Long float32 dot products lose precision with every addition, so this is more accurate for large matrices, at the cost of the conversions.
+// It has no effect on float64. The gradients are multiplied at the usual precision.
+func WithHighPrecisionAccum() LinAlgOpt {
+	return func(op *linAlgBinOp) { op.highPrecAccum = true }
+}
+
+// Type returns the type of the op. A batched matVecMul takes and returns matrices; everything else is typed by the operator.
+func (op linAlgBinOp) Type() Type {
+	if op.āBinaryOperator == matVecMulOperator && op.batched {
+		return batchedMatVecMulType()
+	}
+	return op.āBinaryOperator.Type()
 }
 
 func (op linAlgBinOp) inferShape(retType Type, inputs ...*Node) (retVal types.Shape, err error) {
@@ -611,6 +860,16 @@ func (op linAlgBinOp) inferShape(retType Type, inputs ...*Node) (retVal types.Sh
 		if op.transA {
 			xshape = transpose(xshape)
 		}
+
+		// a batch of vectors: (m, k) × (k, b) → (m, b)
+		if y.IsMatrix() {
+			if len(y.shape) != 2 || xshape[1] != y.shape[0] {
+				return nil, errors.Errorf("Incompatible shapes: %v and a batch of vectors %v", xshape, y.shape)
+			}
+			retVal = types.Shape{xshape[0], y.shape[1]}
+			return
+		}
+
 		if xshape[0] != y.shape[0] && xshape[1] != y.shape[0] {
 			return nil, errors.Errorf("Incompatible shapes: %v and %v", xshape, y.shape)
 		}
@@ -618,6 +877,11 @@ func (op linAlgBinOp) inferShape(retType Type, inputs ...*Node) (retVal types.Sh
 		retVal = types.Shape{xshape[0], 1}
 	case vecDotOperator:
 		retVal = scalarShape
+	case batchedDotOperator:
+		if len(x.shape) != 2 || !x.shape.Eq(y.shape) {
+			return nil, errors.Errorf("Expected two matrices of the same shape. Got %v and %v instead", x.shape, y.shape)
+		}
+		retVal = types.Shape{x.shape[0]}
 	case outerProdOperator:
 		// outerprods only handles vec x vec for now
 		retVal = types.Shape{x.shape.TotalSize(), y.shape.TotalSize()}
@@ -653,14 +917,15 @@ func (op linAlgBinOp) DoDiff(inputs Nodes, output *Node) (err error) {
 	return āBinOpDiffs[o](op.transA, op.transB, inputs[0], inputs[1], output)
 }
 
-func (op linAlgBinOp) Do(inputs ...Value) (retVal Value, err error) { return op.do(inputs) }
+func (op linAlgBinOp) Do(inputs ...Value) (retVal Value, err error) { return op.do(nil, inputs) }
 func (op linAlgBinOp) returnsPtr() bool                             { return true }
 func (op linAlgBinOp) overwriteInput() int                          { return -1 }
 func (op linAlgBinOp) callsExtern() bool {
-	if op.āBinaryOperator != vecDotOperator {
-		return true
+	switch op.āBinaryOperator {
+	case vecDotOperator, batchedDotOperator:
+		return false
 	}
-	return false
+	return true
 }
 
 func (op linAlgBinOp) WriteHash(h hash.Hash) {
@@ -679,6 +944,18 @@ func (op linAlgBinOp) WriteHash(h hash.Hash) {
 	} else {
 		h.Write([]byte{0})
 	}
+
+	if op.batched {
+		h.Write([]byte{1})
+	} else {
+		h.Write([]byte{0})
+	}
+
+	if op.highPrecAccum {
+		h.Write([]byte{1})
+	} else {
+		h.Write([]byte{0})
+	}
 }
 
 func (op linAlgBinOp) Hashcode() uint32 {
@@ -687,11 +964,32 @@ func (op linAlgBinOp) Hashcode() uint32 {
 	return h.Sum32()
 }
 
+func (op linAlgBinOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeByte(byte(op.āBinaryOperator))
+	w.writeBool(op.transA)
+	w.writeBool(op.transB)
+	w.writeBool(op.batched)
+	w.writeBool(op.highPrecAccum)
+	return w.bytes()
+}
+
+func (op *linAlgBinOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.āBinaryOperator = āBinaryOperator(r.readByte())
+	op.transA = r.readBool()
+	op.transB = r.readBool()
+	op.batched = r.readBool()
+	op.highPrecAccum = r.readBool()
+	r.check(op.āBinaryOperator < maxĀBinaryOperator, "Expected a linear algebra operator. Got %d instead", byte(op.āBinaryOperator))
+	return r.done()
+}
+
 func (op linAlgBinOp) String() string {
 	var buf bytes.Buffer
 
 	switch op.āBinaryOperator {
-	case matMulOperator, matVecMulOperator:
+	case matMulOperator, matVecMulOperator, batchedDotOperator:
 		buf.WriteString("A")
 	case vecDotOperator, outerProdOperator:
 		buf.WriteString("a")
@@ -701,10 +999,10 @@ func (op linAlgBinOp) String() string {
 		buf.WriteString("ᵀ")
 	}
 
-	switch op.āBinaryOperator {
-	case matMulOperator:
+	switch {
+	case op.āBinaryOperator == matMulOperator, op.āBinaryOperator == batchedDotOperator, op.batched:
 		fmt.Fprintf(&buf, " %v B", op.āBinaryOperator)
-	case matVecMulOperator, vecDotOperator, outerProdOperator:
+	default:
 		fmt.Fprintf(&buf, " %v b", op.āBinaryOperator)
 	}
 
@@ -717,17 +1015,22 @@ func (op linAlgBinOp) String() string {
 
 // fulfils IncrDoer
 func (op linAlgBinOp) IncrDo(incr Value, inputs ...Value) (err error) {
+	return op.incrDo(nil, incr, inputs...)
+}
+
+// incrDo is IncrDo, with the transposed copies of the inputs drawn from the pool
+func (op linAlgBinOp) incrDo(pool *bufferPool, incr Value, inputs ...Value) (err error) {
 	t, ok := incr.(Tensor)
 	var reuse types.Tensor
 
 	if ok {
 		reuse = t.Tensor
-		_, err = op.do(inputs, types.WithIncr(reuse))
+		_, err = op.do(pool, inputs, types.WithIncr(reuse))
 		return
 	}
 
 	var retVal Value
-	if retVal, err = op.do(inputs); err != nil {
+	if retVal, err = op.do(pool, inputs); err != nil {
 		return errors.Wrapf(err, doFail, op)
 	}
 
@@ -747,7 +1050,7 @@ func (op linAlgBinOp) UsePreallocDo(prealloc Value, inputs ...Value) (retVal Val
 		return nil, errors.Errorf("Expected Tensor as preallocated value. Got %v of %T instead", prealloc, prealloc)
 	}
 
-	return op.do(inputs, types.WithReuse(t.Tensor))
+	return op.do(nil, inputs, types.WithReuse(t.Tensor))
 }
 
 // fulfils BinaryOp
@@ -755,7 +1058,8 @@ func (op linAlgBinOp) isBinary() bool { return true }
 
 /* PRIVATE METHODS */
 
-func (op linAlgBinOp) do(inputs []Value, opts ...types.FuncOpt) (retVal Value, err error) {
+// do carries out the operation. The copies of the inputs that are transposed are drawn from the pool, which may be nil, and returned to it afterwards.
+func (op linAlgBinOp) do(pool *bufferPool, inputs []Value, opts ...types.FuncOpt) (retVal Value, err error) {
 	if len(inputs) != 2 {
 		err = NewError(GraphError, "linalg binary operations only take TWO inputs. Got %d inputs instead", len(inputs))
 		return
@@ -763,32 +1067,50 @@ func (op linAlgBinOp) do(inputs []Value, opts ...types.FuncOpt) (retVal Value, e
 
 	a, b := inputs[0].(Tensor), inputs[1].(Tensor)
 
+	// the inputs may be shared with other ops that are running concurrently, so clones are transposed instead of the inputs themselves
 	if op.transA {
-		if err = a.Tensor.T(); err != nil {
+		at := pool.clone(a.Tensor)
+		defer pool.put(at)
+		if err = at.T(); err != nil {
 			return nil, errors.Wrap(err, tFail)
 		}
-		// untranspose
-		defer a.Tensor.T()
+		a = FromTensor(at)
 	}
 
 	if op.transB {
-		if err = b.Tensor.T(); err != nil {
+		bt := pool.clone(b.Tensor)
+		defer pool.put(bt)
+		if err = bt.T(); err != nil {
 			return nil, errors.Wrap(err, tFail)
 		}
-		// untranspose
-		defer b.Tensor.T()
+		b = FromTensor(bt)
+	}
+
+	if _, ok := a.Tensor.(*tf32.Tensor); ok && op.highPrecAccum {
+		return op.doUpcast(a, b, opts...)
 	}
 
+	// the BLAS may not be swapped out halfway through
+	blasdoor.RLock()
+	defer blasdoor.RUnlock()
+
 	var r interface{}
 	switch op.āBinaryOperator {
 	case matMulOperator:
 		r, err = tensor.MatMul(a.Tensor, b.Tensor, opts...)
 	case matVecMulOperator:
+		if op.batched {
+			// the vectors are the columns of b, so multiplying them all in one go is a matrix multiplication
+			r, err = tensor.MatMul(a.Tensor, b.Tensor, opts...)
+			break
+		}
 		r, err = tensor.MatVecMul(a.Tensor, b.Tensor, opts...)
 	case vecDotOperator:
 		var ret types.Tensor
 		ret, err = tensor.Inner(a.Tensor, b.Tensor)
 		r = ret.ScalarValue()
+	case batchedDotOperator:
+		r, err = tensor.BatchedInner(a.Tensor, b.Tensor, opts...)
 	case outerProdOperator:
 		r, err = tensor.Outer(a.Tensor, b.Tensor, opts...)
 	}
@@ -799,3 +1121,1772 @@ func (op linAlgBinOp) do(inputs []Value, opts ...types.FuncOpt) (retVal Value, e
 	}
 	return
 }
+
+// doUpcast carries out a float32 operation in float64. a and b have already been transposed. The result is downcast,
+// and then written into or added to the float32 tensor passed in with WithReuse or WithIncr, if any.
+func (op linAlgBinOp) doUpcast(a, b Tensor, opts ...types.FuncOpt) (retVal Value, err error) {
+	a64, err := upcastF32(a)
+	if err != nil {
+		return nil, err
+	}
+	b64, err := upcastF32(b)
+	if err != nil {
+		return nil, err
+	}
+
+	op64 := op
+	op64.transA, op64.transB, op64.highPrecAccum = false, false, false
+	var r Value
+	if r, err = op64.do(nil, []Value{a64, b64}); err != nil {
+		return
+	}
+
+	var res []float32
+	switch rt := r.(type) {
+	case Scalar:
+		return NewScalarValue(float32(rt.v.(float64))), nil
+	case Tensor:
+		data := materializedF64s(rt.Tensor.(*tf64.Tensor))
+		res = make([]float32, len(data))
+		for i, v := range data {
+			res[i] = float32(v)
+		}
+	}
+	shp := r.Shape().Clone()
+
+	for _, opt := range opts {
+		flag, v := opt()
+		switch flag {
+		case types.Reuse:
+			reuse, ok := v.(*tf32.Tensor)
+			if !ok {
+				return nil, errors.Errorf("Expected a *tf32.Tensor to reuse. Got %T instead", v)
+			}
+			copy(reuse.Data().([]float32), res)
+			return FromTensor(reuse), nil
+		case types.Incr:
+			incr, ok := v.(*tf32.Tensor)
+			if !ok {
+				return nil, errors.Errorf("Expected a *tf32.Tensor to increment. Got %T instead", v)
+			}
+			data := incr.Data().([]float32)
+			for i, v := range res {
+				data[i] += v
+			}
+			return FromTensor(incr), nil
+		}
+	}
+	return FromTensor(tf32.NewTensor(tf32.WithBacking(res), tf32.WithShape(shp...))), nil
+}
+
+// upcastF32 copies a float32 tensor into a float64 tensor of the same shape
+func upcastF32(t Tensor) (retVal Tensor, err error) {
+	t32, ok := t.Tensor.(*tf32.Tensor)
+	if !ok {
+		return retVal, errors.Errorf(dtypeMismatch, Float32, t.Dtype())
+	}
+
+	data := materializedF32s(t32)
+	up := make([]float64, len(data))
+	for i, v := range data {
+		up[i] = float64(v)
+	}
+	return FromTensor(tf64.NewTensor(tf64.WithBacking(up), tf64.WithShape(t.Shape().Clone()...))), nil
+}
+
+/* SCALAR ADDITION */
+
+// addScalarOp adds a scalar to every element of a tensor. It is a specialization of elemBinOp for the common tensor + scalar case:
+// a tensor that is not a view is never materialized, and when the tensor may be overwritten, the scalar is added to it in place.
+type addScalarOp struct {
+	d int // dims of the tensor
+}
+
+// addScalarOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → a → Tensor d a
+func (op addScalarOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(op.d, a)
+	return newFunctionType(t, a, t)
+}
+
+func (op addScalarOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "addScalarOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op addScalarOp) DiffWRT(inputs int) []bool { return []bool{true, true} }
+
+// SymDiff passes the gradient straight through to the tensor. The gradient of the scalar is the sum of the gradient.
+func (op addScalarOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "addScalarOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var ds *Node
+	if ds, err = Sum(gradNode); err != nil {
+		return nil, errors.Wrap(err, sumFail)
+	}
+	ds.setGroup(gradClust)
+	return Nodes{gradNode, ds}, nil
+}
+
+func (op addScalarOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "addScalarOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	sdv := inputs[1].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	sum := newSumOp(intRange(0, op.d), output.shape, op.d)
+	var ds Value
+	if ds, err = sum.Do(ydv.d); err != nil {
+		return errors.Wrapf(err, doFail, sum)
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], output)
+	if _, err = add.UnsafeDo(xdv.d, ydv.d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+
+	// scalars are not added in place
+	add = newElemBinOp(addOpType, inputs[1], inputs[1])
+	if ds, err = add.UnsafeDo(sdv.d, ds); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return sdv.SetDeriv(ds)
+}
+
+func (op addScalarOp) Do(inputs ...Value) (retVal Value, err error) { return op.do(inputs) }
+
+func (op addScalarOp) returnsPtr() bool    { return true }
+func (op addScalarOp) callsExtern() bool   { return false }
+func (op addScalarOp) overwriteInput() int { return 0 }
+func (op addScalarOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("addScalar"))
+	if err := binary.Write(h, binary.LittleEndian, byte(op.d)); err != nil {
+		panic(err)
+	}
+}
+
+func (op addScalarOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op addScalarOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.d)
+	return w.bytes()
+}
+
+func (op *addScalarOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.d = r.readInt()
+	r.checkAxes(op.d)
+	return r.done()
+}
+
+func (op addScalarOp) String() string { return "+ scalar" }
+
+// fulfils UnsafeDoer
+func (op addScalarOp) UnsafeDo(inputs ...Value) (retVal Value, err error) {
+	return op.do(inputs, types.UseUnsafe())
+}
+
+func (op addScalarOp) do(inputs []Value, opts ...types.FuncOpt) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "addScalarOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	t, ok := inputs[0].(Tensor)
+	if !ok {
+		return nil, errors.Errorf("Expected a Tensor. Got %v of %T instead", inputs[0], inputs[0])
+	}
+	s, ok := inputs[1].(Scalar)
+	if !ok {
+		return nil, errors.Errorf("Expected a Scalar. Got %v of %T instead", inputs[1], inputs[1])
+	}
+	if s.t != t.Dtype() {
+		return nil, errors.Errorf("Dtype mismatch for addScalarOp: %v and %v", t.Dtype(), s.t)
+	}
+
+	// a view is materialized into a new tensor, which is then safe to add to in place
+	var r types.Tensor
+	switch tt := t.Tensor.(type) {
+	case *tf64.Tensor:
+		if tt.IsMaterializable() {
+			tt = tt.Materialize().(*tf64.Tensor)
+		}
+		r, err = tf64.Add(tt, s.v.(float64), opts...)
+	case *tf32.Tensor:
+		if tt.IsMaterializable() {
+			tt = tt.Materialize().(*tf32.Tensor)
+		}
+		r, err = tf32.Add(tt, s.v.(float32), opts...)
+	default:
+		return nil, errors.Errorf(nyiFail, "addScalarOp.do()", t.Tensor)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to add a scalar")
+	}
+	return FromTensor(r), nil
+}
+
+/* POWER OF AN INTEGER CONSTANT */
+
+// powConstOp raises every element to an integer power by repeated multiplication, which is exact for squares and cubes, unlike going through math.Pow.
+type powConstOp struct {
+	exp int
+}
+
+// powConstOp has this type:
+//		op :: (Float a) ⇒ a → a
+func (op powConstOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(a, a)
+}
+
+func (op powConstOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "powConstOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op powConstOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+func (op powConstOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "powConstOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(powConstDiffOp(op), inputs[0], gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op powConstOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "powConstOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	var d Value
+	if d, err = powConstDiffOp(op).Do(xdv.Value, ydv.d); err != nil {
+		return errors.Wrap(err, "powConstOp.DoDiff()")
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], output)
+	if d, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+
+	// scalars are not added in place
+	if inputs[0].IsScalar() {
+		return xdv.SetDeriv(d)
+	}
+	return
+}
+
+func (op powConstOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "powConstOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return zipFloats(inputs[0], inputs[0], func(x, _ float64) float64 { return ipow(x, op.exp) })
+}
+
+func (op powConstOp) returnsPtr() bool    { return false }
+func (op powConstOp) callsExtern() bool   { return false }
+func (op powConstOp) overwriteInput() int { return -1 }
+func (op powConstOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("powConst"))
+	if err := binary.Write(h, binary.LittleEndian, int64(op.exp)); err != nil {
+		panic(err)
+	}
+}
+
+func (op powConstOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op powConstOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.exp)
+	return w.bytes()
+}
+
+func (op *powConstOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.exp = r.readInt()
+	return r.done()
+}
+
+func (op powConstOp) String() string { return fmt.Sprintf("^%d", op.exp) }
+
+// powConstDiffOp is the derivative of powConstOp. It takes x and the gradient of the output, and returns exp × x^(exp-1) × gradZ.
+type powConstDiffOp powConstOp
+
+// powConstDiffOp has this type:
+//		op :: (Float a) ⇒ a → a → a
+func (op powConstDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(a, a, a)
+}
+
+func (op powConstDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "powConstDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op powConstDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op powConstDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op powConstDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "powConstDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	exp := op.exp
+	if exp == 0 {
+		return zipFloats(inputs[0], inputs[1], func(_, _ float64) float64 { return 0 })
+	}
+	return zipFloats(inputs[0], inputs[1], func(x, g float64) float64 { return float64(exp) * ipow(x, exp-1) * g })
+}
+
+func (op powConstDiffOp) returnsPtr() bool    { return false }
+func (op powConstDiffOp) callsExtern() bool   { return false }
+func (op powConstDiffOp) overwriteInput() int { return -1 }
+func (op powConstDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	powConstOp(op).WriteHash(h)
+}
+
+func (op powConstDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op powConstDiffOp) MarshalBinary() ([]byte, error) {
+	return powConstOp(op).MarshalBinary()
+}
+
+func (op *powConstDiffOp) UnmarshalBinary(data []byte) error {
+	return (*powConstOp)(op).UnmarshalBinary(data)
+}
+
+func (op powConstDiffOp) String() string { return fmt.Sprintf("∂^%d", op.exp) }
+
+// ipow computes x to the nth power by repeated squaring and multiplication
+func ipow(x float64, n int) float64 {
+	if n < 0 {
+		return 1 / ipow(x, -n)
+	}
+
+	retVal := 1.0
+	for ; n > 0; n >>= 1 {
+		if n&1 == 1 {
+			retVal *= x
+		}
+		x *= x
+	}
+	return retVal
+}
+
+// zipFloats applies fn to the elements of a and b pairwise, and returns the results in a new Value of the same shape and Dtype as a.
+// a and b are either both Scalars or both Tensors of the same shape.
+func zipFloats(a, b Value, fn func(x, y float64) float64) (retVal Value, err error) {
+	if as, ok := a.(Scalar); ok {
+		bs, ok := b.(Scalar)
+		if !ok {
+			return nil, errors.Errorf("Expected a Scalar. Got %v of %T instead", b, b)
+		}
+		switch x := as.v.(type) {
+		case float64:
+			return NewScalarValue(fn(x, bs.v.(float64))), nil
+		case float32:
+			return NewScalarValue(float32(fn(float64(x), float64(bs.v.(float32))))), nil
+		}
+		return nil, errors.Errorf(nyiFail, "zipFloats", as.t)
+	}
+
+	var xs, ys []float64
+	var shp, bShape types.Shape
+	var dt Dtype
+	if xs, shp, dt, err = floatsOperand(a); err != nil {
+		return
+	}
+	if ys, bShape, _, err = floatsOperand(b); err != nil {
+		return
+	}
+	if !shp.Eq(bShape) {
+		return nil, errors.Errorf("Shape mismatch: %v and %v", shp, bShape)
+	}
+
+	zs := make([]float64, len(xs))
+	for i, x := range xs {
+		zs[i] = fn(x, ys[i])
+	}
+	return floatsValue(zs, shp, dt), nil
+}
+
+/* THRESHOLD MASK */
+
+// thresholdOp compares every element with a threshold, and returns 1 where the element is greater than the threshold and 0 where it is not,
+// in the Dtype of the input, the way elemBinOp does with retSame. The comparison is not differentiable. If straightThrough is set,
+// the gradient is passed straight through instead, as if the op were the identity; otherwise nothing is backpropagated through the mask.
+type thresholdOp struct {
+	threshold       float64
+	straightThrough bool
+}
+
+// thresholdOp has this type:
+//		op :: (Float a) ⇒ a → a
+func (op thresholdOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(a, a)
+}
+
+func (op thresholdOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "thresholdOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op thresholdOp) DiffWRT(inputs int) []bool { return []bool{op.straightThrough} }
+
+func (op thresholdOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if !op.straightThrough {
+		return nil, nondiffErr(op)
+	}
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "thresholdOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return Nodes{gradNode}, nil
+}
+
+func (op thresholdOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if !op.straightThrough {
+		return nondiffErr(op)
+	}
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "thresholdOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	add := newElemBinOp(addOpType, inputs[0], output)
+	var d Value
+	if d, err = add.UnsafeDo(xdv.d, ydv.d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+
+	// scalars are not added in place
+	if inputs[0].IsScalar() {
+		return xdv.SetDeriv(d)
+	}
+	return
+}
+
+func (op thresholdOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "thresholdOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return zipFloats(inputs[0], inputs[0], func(x, _ float64) float64 {
+		if x > op.threshold {
+			return 1
+		}
+		return 0
+	})
+}
+
+func (op thresholdOp) returnsPtr() bool    { return false }
+func (op thresholdOp) callsExtern() bool   { return false }
+func (op thresholdOp) overwriteInput() int { return -1 }
+func (op thresholdOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("threshold"))
+	if err := binary.Write(h, binary.LittleEndian, op.threshold); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, op.straightThrough); err != nil {
+		panic(err)
+	}
+}
+
+func (op thresholdOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op thresholdOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeFloat(op.threshold)
+	w.writeBool(op.straightThrough)
+	return w.bytes()
+}
+
+func (op *thresholdOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.threshold = r.readFloat()
+	op.straightThrough = r.readBool()
+	return r.done()
+}
+
+func (op thresholdOp) String() string {
+	if op.straightThrough {
+		return fmt.Sprintf("> %v (straight through)", op.threshold)
+	}
+	return fmt.Sprintf("> %v", op.threshold)
+}
+
+// maximumScalarOp computes max(x, c) of every element x with the scalar c, which the op carries. The gradient is passed through
+// where x > c, and is 0 elsewhere, including where x = c. With c = 0 it is the ReLU.
+type maximumScalarOp struct {
+	c float64
+}
+
+// maximumScalarOp has this type:
+//		op :: (Float a) ⇒ a → a
+func (op maximumScalarOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(a, a)
+}
+
+func (op maximumScalarOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "maximumScalarOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op maximumScalarOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+func (op maximumScalarOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "maximumScalarOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(maximumScalarDiffOp(op), inputs[0], gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op maximumScalarOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "maximumScalarOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	diff := maximumScalarDiffOp(op)
+	var d Value
+	if d, err = diff.Do(xdv.Value, ydv.d); err != nil {
+		return errors.Wrapf(err, doFail, diff)
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], output)
+	if d, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+
+	// scalars are not added in place
+	if inputs[0].IsScalar() {
+		return xdv.SetDeriv(d)
+	}
+	return
+}
+
+func (op maximumScalarOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "maximumScalarOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return zipFloats(inputs[0], inputs[0], func(x, _ float64) float64 {
+		if x > op.c {
+			return x
+		}
+		return op.c
+	})
+}
+
+func (op maximumScalarOp) returnsPtr() bool    { return false }
+func (op maximumScalarOp) callsExtern() bool   { return false }
+func (op maximumScalarOp) overwriteInput() int { return -1 }
+func (op maximumScalarOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("maximumScalar"))
+	if err := binary.Write(h, binary.LittleEndian, op.c); err != nil {
+		panic(err)
+	}
+}
+
+func (op maximumScalarOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op maximumScalarOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeFloat(op.c)
+	return w.bytes()
+}
+
+func (op *maximumScalarOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.c = r.readFloat()
+	return r.done()
+}
+
+func (op maximumScalarOp) String() string { return fmt.Sprintf("MaximumScalar(%v)", op.c) }
+
+// maximumScalarDiffOp is the derivative of maximumScalarOp. It takes x and the gradient of the output, and masks the gradient by x > c.
+type maximumScalarDiffOp struct {
+	c float64
+}
+
+// maximumScalarDiffOp has this type:
+//		op :: (Float a) ⇒ a → a → a
+func (op maximumScalarDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(a, a, a)
+}
+
+func (op maximumScalarDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "maximumScalarDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op maximumScalarDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op maximumScalarDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op maximumScalarDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "maximumScalarDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return zipFloats(inputs[0], inputs[1], func(x, grad float64) float64 {
+		if x > op.c {
+			return grad
+		}
+		return 0
+	})
+}
+
+func (op maximumScalarDiffOp) returnsPtr() bool    { return false }
+func (op maximumScalarDiffOp) callsExtern() bool   { return false }
+func (op maximumScalarDiffOp) overwriteInput() int { return -1 }
+func (op maximumScalarDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	maximumScalarOp(op).WriteHash(h)
+}
+
+func (op maximumScalarDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op maximumScalarDiffOp) MarshalBinary() ([]byte, error) {
+	return maximumScalarOp(op).MarshalBinary()
+}
+
+func (op *maximumScalarDiffOp) UnmarshalBinary(data []byte) error {
+	return (*maximumScalarOp)(op).UnmarshalBinary(data)
+}
+
+func (op maximumScalarDiffOp) String() string { return fmt.Sprintf("∂MaximumScalar(%v)", op.c) }
+
+// logitEps is how far Logit keeps p away from 0 and 1
+const logitEps = 1e-7
+
+// logitOp computes the logit, the inverse of the sigmoid, of every element p:
+//		y = log(p / (1-p))
+// p is clamped to [eps, 1-eps] first, so that the logit of a 0 or a 1 is large rather than infinite. The gradient,
+//		∂p = ∂y / (p(1-p))
+// uses the clamped p as well, so it stays finite at the boundaries.
+type logitOp struct {
+	eps float64
+}
+
+// logitOp has this type:
+//		op :: (Float a) ⇒ a → a
+func (op logitOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(a, a)
+}
+
+func (op logitOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "logitOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op logitOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+func (op logitOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "logitOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(logitDiffOp(op), inputs[0], gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op logitOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "logitOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	diff := logitDiffOp(op)
+	var d Value
+	if d, err = diff.Do(xdv.Value, ydv.d); err != nil {
+		return errors.Wrapf(err, doFail, diff)
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], output)
+	if d, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+
+	// scalars are not added in place
+	if inputs[0].IsScalar() {
+		return xdv.SetDeriv(d)
+	}
+	return
+}
+
+func (op logitOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "logitOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return zipFloats(inputs[0], inputs[0], func(p, _ float64) float64 {
+		p = op.clamp(p)
+		return math.Log(p / (1 - p))
+	})
+}
+
+// clamp keeps p within [eps, 1-eps]
+func (op logitOp) clamp(p float64) float64 { return math.Min(math.Max(p, op.eps), 1-op.eps) }
+
+func (op logitOp) returnsPtr() bool    { return false }
+func (op logitOp) callsExtern() bool   { return false }
+func (op logitOp) overwriteInput() int { return -1 }
+func (op logitOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("logit"))
+	if err := binary.Write(h, binary.LittleEndian, op.eps); err != nil {
+		panic(err)
+	}
+}
+
+func (op logitOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op logitOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeFloat(op.eps)
+	return w.bytes()
+}
+
+func (op *logitOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.eps = r.readFloat()
+	r.check(op.eps >= 0 && op.eps < 0.5, "Expected eps to be within [0, 0.5). Got %v instead", op.eps)
+	return r.done()
+}
+
+func (op logitOp) String() string { return "Logit" }
+
+// logitDiffOp is the derivative of logitOp. It takes p and the gradient of the output, and returns gradZ / (p(1-p)) with p clamped.
+type logitDiffOp struct {
+	eps float64
+}
+
+// logitDiffOp has this type:
+//		op :: (Float a) ⇒ a → a → a
+func (op logitDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(a, a, a)
+}
+
+func (op logitDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "logitDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op logitDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op logitDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op logitDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "logitDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return zipFloats(inputs[0], inputs[1], func(p, grad float64) float64 {
+		p = logitOp(op).clamp(p)
+		return grad / (p * (1 - p))
+	})
+}
+
+func (op logitDiffOp) returnsPtr() bool    { return false }
+func (op logitDiffOp) callsExtern() bool   { return false }
+func (op logitDiffOp) overwriteInput() int { return -1 }
+func (op logitDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	logitOp(op).WriteHash(h)
+}
+
+func (op logitDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op logitDiffOp) MarshalBinary() ([]byte, error) {
+	return logitOp(op).MarshalBinary()
+}
+
+func (op *logitDiffOp) UnmarshalBinary(data []byte) error {
+	return (*logitOp)(op).UnmarshalBinary(data)
+}
+
+func (op logitDiffOp) String() string { return "∂Logit" }
+
+// hardSigmoidOp computes the hard sigmoid, a piecewise linear approximation of the sigmoid that is cheaper to compute, of every element x:
+//		y = clip(0.2x + 0.5, 0, 1)
+// The gradient is 0.2 × ∂y where 0.2x + 0.5 is strictly within (0, 1), and 0 where it is clipped, including at the boundaries.
+type hardSigmoidOp struct{}
+
+// hardSigmoidOp has this type:
+//		op :: (Float a) ⇒ a → a
+func (op hardSigmoidOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(a, a)
+}
+
+func (op hardSigmoidOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "hardSigmoidOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op hardSigmoidOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+func (op hardSigmoidOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "hardSigmoidOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(hardSigmoidDiffOp{}, inputs[0], gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op hardSigmoidOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "hardSigmoidOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	diff := hardSigmoidDiffOp{}
+	var d Value
+	if d, err = diff.Do(xdv.Value, ydv.d); err != nil {
+		return errors.Wrapf(err, doFail, diff)
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], output)
+	if d, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+
+	// scalars are not added in place
+	if inputs[0].IsScalar() {
+		return xdv.SetDeriv(d)
+	}
+	return
+}
+
+func (op hardSigmoidOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "hardSigmoidOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return zipFloats(inputs[0], inputs[0], func(x, _ float64) float64 {
+		return math.Min(math.Max(hardSigmoidLinear(x), 0), 1)
+	})
+}
+
+// hardSigmoidLinear is the linear part of the hard sigmoid, before it is clipped
+func hardSigmoidLinear(x float64) float64 { return 0.2*x + 0.5 }
+
+func (op hardSigmoidOp) returnsPtr() bool      { return false }
+func (op hardSigmoidOp) callsExtern() bool     { return false }
+func (op hardSigmoidOp) overwriteInput() int   { return -1 }
+func (op hardSigmoidOp) WriteHash(h hash.Hash) { h.Write([]byte("hardSigmoid")) }
+
+func (op hardSigmoidOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op hardSigmoidOp) String() string { return "HardSigmoid" }
+
+// hardSigmoidDiffOp is the derivative of hardSigmoidOp. It takes x and the gradient of the output, and returns 0.2 × gradZ where the hard sigmoid is not clipped.
+type hardSigmoidDiffOp struct{}
+
+// hardSigmoidDiffOp has this type:
+//		op :: (Float a) ⇒ a → a → a
+func (op hardSigmoidDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(a, a, a)
+}
+
+func (op hardSigmoidDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "hardSigmoidDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op hardSigmoidDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op hardSigmoidDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op hardSigmoidDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "hardSigmoidDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return zipFloats(inputs[0], inputs[1], func(x, grad float64) float64 {
+		if l := hardSigmoidLinear(x); l > 0 && l < 1 {
+			return 0.2 * grad
+		}
+		return 0
+	})
+}
+
+func (op hardSigmoidDiffOp) returnsPtr() bool    { return false }
+func (op hardSigmoidDiffOp) callsExtern() bool   { return false }
+func (op hardSigmoidDiffOp) overwriteInput() int { return -1 }
+func (op hardSigmoidDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	hardSigmoidOp{}.WriteHash(h)
+}
+
+func (op hardSigmoidDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op hardSigmoidDiffOp) String() string { return "∂HardSigmoid" }
+
+// signedSqrtEps is the smallest |x| that the gradient of SignedSqrt divides by
+const signedSqrtEps = 1e-12
+
+// signedSqrtOp computes the sign preserving square root of every element x:
+//		y = sign(x) × √|x|
+// It is differentiable everywhere except at 0, where its slope is infinite. The gradient,
+//		∂x = ∂y / (2√|x|)
+// takes |x| to be at least eps, so that it is large rather than infinite near 0.
+type signedSqrtOp struct {
+	eps float64
+}
+
+// signedSqrtOp has this type:
+//		op :: (Float a) ⇒ a → a
+func (op signedSqrtOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(a, a)
+}
+
+func (op signedSqrtOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "signedSqrtOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op signedSqrtOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+func (op signedSqrtOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "signedSqrtOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(signedSqrtDiffOp(op), inputs[0], gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op signedSqrtOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "signedSqrtOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	diff := signedSqrtDiffOp(op)
+	var d Value
+	if d, err = diff.Do(xdv.Value, ydv.d); err != nil {
+		return errors.Wrapf(err, doFail, diff)
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], output)
+	if d, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+
+	// scalars are not added in place
+	if inputs[0].IsScalar() {
+		return xdv.SetDeriv(d)
+	}
+	return
+}
+
+func (op signedSqrtOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "signedSqrtOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return zipFloats(inputs[0], inputs[0], func(x, _ float64) float64 {
+		if x < 0 {
+			return -math.Sqrt(-x)
+		}
+		return math.Sqrt(x)
+	})
+}
+
+func (op signedSqrtOp) returnsPtr() bool    { return false }
+func (op signedSqrtOp) callsExtern() bool   { return false }
+func (op signedSqrtOp) overwriteInput() int { return -1 }
+func (op signedSqrtOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("signedSqrt"))
+	if err := binary.Write(h, binary.LittleEndian, op.eps); err != nil {
+		panic(err)
+	}
+}
+
+func (op signedSqrtOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op signedSqrtOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeFloat(op.eps)
+	return w.bytes()
+}
+
+func (op *signedSqrtOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.eps = r.readFloat()
+	r.check(op.eps >= 0, "Expected eps to not be negative. Got %v instead", op.eps)
+	return r.done()
+}
+
+func (op signedSqrtOp) String() string { return "SignedSqrt" }
+
+// signedSqrtDiffOp is the derivative of signedSqrtOp. It takes x and the gradient of the output, and returns gradZ / (2√max(|x|, eps)).
+type signedSqrtDiffOp struct {
+	eps float64
+}
+
+// signedSqrtDiffOp has this type:
+//		op :: (Float a) ⇒ a → a → a
+func (op signedSqrtDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(a, a, a)
+}
+
+func (op signedSqrtDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "signedSqrtDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op signedSqrtDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op signedSqrtDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op signedSqrtDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "signedSqrtDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return zipFloats(inputs[0], inputs[1], func(x, grad float64) float64 {
+		return 0.5 * grad / math.Sqrt(math.Max(math.Abs(x), op.eps))
+	})
+}
+
+func (op signedSqrtDiffOp) returnsPtr() bool    { return false }
+func (op signedSqrtDiffOp) callsExtern() bool   { return false }
+func (op signedSqrtDiffOp) overwriteInput() int { return -1 }
+func (op signedSqrtDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	signedSqrtOp(op).WriteHash(h)
+}
+
+func (op signedSqrtDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op signedSqrtDiffOp) MarshalBinary() ([]byte, error) {
+	return signedSqrtOp(op).MarshalBinary()
+}
+
+func (op *signedSqrtDiffOp) UnmarshalBinary(data []byte) error {
+	return (*signedSqrtOp)(op).UnmarshalBinary(data)
+}
+
+func (op signedSqrtDiffOp) String() string { return "∂SignedSqrt" }
+
+// swishOp computes the swish of every element x, which is the SiLU when beta is 1:
+//		y = x × σ(βx)
+// The gradient is
+//		∂x = ∂y × (σ(βx) + βx × σ(βx)(1 - σ(βx)))
+// The backwards pass recovers σ(βx) from the output as y / x, instead of computing the exponential again.
+type swishOp struct {
+	beta float64
+}
+
+// swishOp has this type:
+//		op :: (Float a) ⇒ a → a
+func (op swishOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(a, a)
+}
+
+func (op swishOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "swishOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op swishOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+func (op swishOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "swishOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(swishDiffOp(op), inputs[0], output, gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op swishOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "swishOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	diff := swishDiffOp(op)
+	var d Value
+	if d, err = diff.Do(xdv.Value, ydv.Value, ydv.d); err != nil {
+		return errors.Wrapf(err, doFail, diff)
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], output)
+	if d, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+
+	// scalars are not added in place
+	if inputs[0].IsScalar() {
+		return xdv.SetDeriv(d)
+	}
+	return
+}
+
+func (op swishOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "swishOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return zipFloats(inputs[0], inputs[0], func(x, _ float64) float64 { return x * _sigmoidf64(op.beta*x) })
+}
+
+// sigmoid recovers σ(βx) from x and the output y. Close to 0, where y / x would lose precision (and is 0 / 0 at 0), it is computed afresh.
+func (op swishOp) sigmoid(x, y float64) float64 {
+	if math.Abs(x) < 1e-300 {
+		return _sigmoidf64(op.beta * x)
+	}
+	return y / x
+}
+
+func (op swishOp) returnsPtr() bool    { return false }
+func (op swishOp) callsExtern() bool   { return false }
+func (op swishOp) overwriteInput() int { return -1 }
+func (op swishOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("swish"))
+	if err := binary.Write(h, binary.LittleEndian, op.beta); err != nil {
+		panic(err)
+	}
+}
+
+func (op swishOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op swishOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeFloat(op.beta)
+	return w.bytes()
+}
+
+func (op *swishOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.beta = r.readFloat()
+	return r.done()
+}
+
+func (op swishOp) String() string { return fmt.Sprintf("Swish(%v)", op.beta) }
+
+// swishDiffOp is the derivative of swishOp. It takes x, the output y and the gradient of y, and returns (σ + βy(1 - σ)) × gradY, where σ = y / x.
+type swishDiffOp struct {
+	beta float64
+}
+
+// swishDiffOp has this type:
+//		op :: (Float a) ⇒ a → a → a → a
+func (op swishDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(a, a, a, a)
+}
+
+func (op swishDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "swishDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op swishDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op swishDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op swishDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "swishDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var slope Value
+	if slope, err = zipFloats(inputs[0], inputs[1], func(x, y float64) float64 {
+		s := swishOp(op).sigmoid(x, y)
+		return s + op.beta*y*(1-s)
+	}); err != nil {
+		return
+	}
+	return zipFloats(slope, inputs[2], func(s, grad float64) float64 { return s * grad })
+}
+
+func (op swishDiffOp) returnsPtr() bool    { return false }
+func (op swishDiffOp) callsExtern() bool   { return false }
+func (op swishDiffOp) overwriteInput() int { return -1 }
+func (op swishDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	swishOp(op).WriteHash(h)
+}
+
+func (op swishDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op swishDiffOp) MarshalBinary() ([]byte, error) {
+	return swishOp(op).MarshalBinary()
+}
+
+func (op *swishDiffOp) UnmarshalBinary(data []byte) error {
+	return (*swishOp)(op).UnmarshalBinary(data)
+}
+
+func (op swishDiffOp) String() string { return fmt.Sprintf("∂Swish(%v)", op.beta) }
+
+// fakeQuantOp quantizes every element x to an integer in [qmin, qmax], and dequantizes it back, so that the rest of the graph sees
+// the rounding and the clipping of quantization while still working with floats:
+//		q = clip(round(x / scale) + zeroPoint, qmin, qmax)
+//		y = (q - zeroPoint) × scale
+// Halves are rounded to even. As rounding has no useful gradient, the gradient is the straight-through estimator:
+// ∂y is passed through as is where round(x / scale) + zeroPoint is within [qmin, qmax], and is 0 where it is clipped.
+type fakeQuantOp struct {
+	scale                 float64
+	zeroPoint, qmin, qmax int
+}
+
+// fakeQuantOp has this type:
+//		op :: (Float a) ⇒ a → a
+func (op fakeQuantOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(a, a)
+}
+
+func (op fakeQuantOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "fakeQuantOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op fakeQuantOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+func (op fakeQuantOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "fakeQuantOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(fakeQuantDiffOp(op), inputs[0], gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op fakeQuantOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "fakeQuantOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	diff := fakeQuantDiffOp(op)
+	var d Value
+	if d, err = diff.Do(xdv.Value, ydv.d); err != nil {
+		return errors.Wrapf(err, doFail, diff)
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], output)
+	if d, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+
+	// scalars are not added in place
+	if inputs[0].IsScalar() {
+		return xdv.SetDeriv(d)
+	}
+	return
+}
+
+func (op fakeQuantOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "fakeQuantOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return zipFloats(inputs[0], inputs[0], func(x, _ float64) float64 {
+		q := math.Min(math.Max(op.quantize(x), float64(op.qmin)), float64(op.qmax))
+		return (q - float64(op.zeroPoint)) * op.scale
+	})
+}
+
+// quantize is round(x / scale) + zeroPoint, before it is clipped
+func (op fakeQuantOp) quantize(x float64) float64 {
+	return math.RoundToEven(x/op.scale) + float64(op.zeroPoint)
+}
+
+func (op fakeQuantOp) returnsPtr() bool    { return false }
+func (op fakeQuantOp) callsExtern() bool   { return false }
+func (op fakeQuantOp) overwriteInput() int { return -1 }
+func (op fakeQuantOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("fakeQuant"))
+	if err := binary.Write(h, binary.LittleEndian, op.scale); err != nil {
+		panic(err)
+	}
+	for _, v := range []int{op.zeroPoint, op.qmin, op.qmax} {
+		if err := binary.Write(h, binary.LittleEndian, int64(v)); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (op fakeQuantOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op fakeQuantOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeFloat(op.scale)
+	w.writeInt(op.zeroPoint)
+	w.writeInt(op.qmin)
+	w.writeInt(op.qmax)
+	return w.bytes()
+}
+
+// UnmarshalBinary checks the parameters the way FakeQuant does
+func (op *fakeQuantOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.scale = r.readFloat()
+	op.zeroPoint = r.readInt()
+	op.qmin = r.readInt()
+	op.qmax = r.readInt()
+	r.check(op.scale > 0 && !math.IsInf(op.scale, 1), "Expected a positive, finite scale. Got %v instead", op.scale)
+	r.check(op.qmin <= op.zeroPoint && op.zeroPoint <= op.qmax, "Expected qmin <= zeroPoint <= qmax. Got %d, %d and %d instead", op.qmin, op.zeroPoint, op.qmax)
+	return r.done()
+}
+
+func (op fakeQuantOp) String() string {
+	return fmt.Sprintf("FakeQuant(%v, %d, [%d, %d])", op.scale, op.zeroPoint, op.qmin, op.qmax)
+}
+
+// fakeQuantDiffOp is the derivative of fakeQuantOp. It takes x and the gradient of the output, and passes the gradient through
+// where x is not clipped, and 0 where it is.
+type fakeQuantDiffOp fakeQuantOp
+
+// fakeQuantDiffOp has this type:
+//		op :: (Float a) ⇒ a → a → a
+func (op fakeQuantDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(a, a, a)
+}
+
+func (op fakeQuantDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "fakeQuantDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op fakeQuantDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op fakeQuantDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op fakeQuantDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "fakeQuantDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return zipFloats(inputs[0], inputs[1], func(x, grad float64) float64 {
+		if q := fakeQuantOp(op).quantize(x); q >= float64(op.qmin) && q <= float64(op.qmax) {
+			return grad
+		}
+		return 0
+	})
+}
+
+func (op fakeQuantDiffOp) returnsPtr() bool    { return false }
+func (op fakeQuantDiffOp) callsExtern() bool   { return false }
+func (op fakeQuantDiffOp) overwriteInput() int { return -1 }
+func (op fakeQuantDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	fakeQuantOp(op).WriteHash(h)
+}
+
+func (op fakeQuantDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op fakeQuantDiffOp) MarshalBinary() ([]byte, error) {
+	return fakeQuantOp(op).MarshalBinary()
+}
+
+func (op *fakeQuantDiffOp) UnmarshalBinary(data []byte) error {
+	return (*fakeQuantOp)(op).UnmarshalBinary(data)
+}
+
+func (op fakeQuantDiffOp) String() string { return "∂" + fakeQuantOp(op).String() }
+
+/* APPLY A GO FUNCTION */
+
+// applyFnIDs hands out the ids of the applyFnOps. Functions cannot be compared or hashed, so each applyFnOp is made unique by its id instead,
+// which keeps two nodes that apply different closures from being taken for the same node.
+var applyFnIDs uint64
+
+// applyFnOp applies a Go function to every element, the way elemUnaryOp applies its operator. df is the derivative of f, and may be nil
+// if the op is never differentiated.
+type applyFnOp struct {
+	f, df func(float64) float64
+	id    uint64
+}
+
+func newApplyFnOp(f, df func(float64) float64) applyFnOp {
+	return applyFnOp{
+		f:  f,
+		df: df,
+		id: atomic.AddUint64(&applyFnIDs, 1),
+	}
+}
+
+// applyFnOp has this type:
+//		op :: (Float a) ⇒ a → a
+func (op applyFnOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(a, a)
+}
+
+func (op applyFnOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "applyFnOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+// DiffWRT is true even without a derivative, so that differentiating the op fails loudly instead of silently leaving out its input
+func (op applyFnOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+func (op applyFnOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "applyFnOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	if op.df == nil {
+		return nil, op.noDerivative()
+	}
+
+	var dx *Node
+	if dx, err = applyOp(applyFnDiffOp(op), inputs[0], gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op applyFnOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "applyFnOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	if op.df == nil {
+		return op.noDerivative()
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	var d Value
+	if d, err = applyFnDiffOp(op).Do(xdv.Value, ydv.d); err != nil {
+		return errors.Wrap(err, "applyFnOp.DoDiff()")
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], output)
+	if d, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+
+	// scalars are not added in place
+	if inputs[0].IsScalar() {
+		return xdv.SetDeriv(d)
+	}
+	return
+}
+
+func (op applyFnOp) noDerivative() error {
+	return errors.Errorf("Cannot differentiate %v: no derivative was given to ApplyFn. Pass one in, or run the graph without backpropagation", op)
+}
+
+func (op applyFnOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "applyFnOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return applyFloatFn(inputs[0], op.f)
+}
+
+func (op applyFnOp) returnsPtr() bool    { return false }
+func (op applyFnOp) callsExtern() bool   { return false }
+func (op applyFnOp) overwriteInput() int { return -1 }
+func (op applyFnOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("applyFn"))
+	if err := binary.Write(h, binary.LittleEndian, op.id); err != nil {
+		panic(err)
+	}
+}
+
+func (op applyFnOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// MarshalBinary always fails. The functions an applyFnOp applies are Go code, which cannot be written out
+func (op applyFnOp) MarshalBinary() ([]byte, error) {
+	return nil, errors.Errorf("%v cannot be encoded: it applies Go functions. Register an op of its own instead", op)
+}
+
+func (op applyFnOp) String() string { return fmt.Sprintf("ApplyFn#%d", op.id) }
+
+// applyFnDiffOp is the derivative of applyFnOp. It takes x and the gradient of the output, and returns df(x) × gradZ.
+type applyFnDiffOp applyFnOp
+
+// applyFnDiffOp has this type:
+//		op :: (Float a) ⇒ a → a → a
+func (op applyFnDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(a, a, a)
+}
+
+func (op applyFnDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "applyFnDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op applyFnDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op applyFnDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op applyFnDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "applyFnDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	if op.df == nil {
+		return nil, applyFnOp(op).noDerivative()
+	}
+	df := op.df
+	return zipFloats(inputs[0], inputs[1], func(x, g float64) float64 { return df(x) * g })
+}
+
+func (op applyFnDiffOp) returnsPtr() bool    { return false }
+func (op applyFnDiffOp) callsExtern() bool   { return false }
+func (op applyFnDiffOp) overwriteInput() int { return -1 }
+func (op applyFnDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	applyFnOp(op).WriteHash(h)
+}
+
+func (op applyFnDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// MarshalBinary always fails, for the same reason applyFnOp.MarshalBinary does
+func (op applyFnDiffOp) MarshalBinary() ([]byte, error) {
+	return nil, errors.Errorf("%v cannot be encoded: it applies Go functions. Register an op of its own instead", op)
+}
+
+func (op applyFnDiffOp) String() string { return fmt.Sprintf("∂ApplyFn#%d", op.id) }
+
+// applyFloatFn applies fn to every element of a float Scalar or Tensor, as elemUnaryOp.do does. Float32s are applied to as float64s.
+func applyFloatFn(v Value, fn func(float64) float64) (retVal Value, err error) {
+	switch vt := v.(type) {
+	case Tensor:
+		var t types.Tensor
+		switch tt := vt.Tensor.(type) {
+		case *tf64.Tensor:
+			if t, err = tt.Apply(fn); err != nil {
+				return nil, errors.Wrap(err, applyFail)
+			}
+		case *tf32.Tensor:
+			if t, err = tt.Apply(func(x float32) float32 { return float32(fn(float64(x))) }); err != nil {
+				return nil, errors.Wrap(err, applyFail)
+			}
+		default:
+			return nil, errors.Errorf(nyiFail, "applyFloatFn", vt.Tensor)
+		}
+		return FromTensor(t), nil
+	case Scalar:
+		switch f := vt.v.(type) {
+		case float64:
+			return NewScalarValue(fn(f)), nil
+		case float32:
+			return NewScalarValue(float32(fn(float64(f)))), nil
+		}
+		return nil, errors.Errorf(nyiFail, "applyFloatFn", vt.t)
+	}
+	return nil, errors.Errorf(nyiFail, "applyFloatFn", v)
+}
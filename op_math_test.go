@@ -1,9 +1,17 @@
 package gorgonia
 
 import (
+	"math"
+	"math/rand"
+	"reflect"
+	"sync"
 	"testing"
 
+	tf32 "github.com/chewxy/gorgonia/tensor/f32"
 	tf64 "github.com/chewxy/gorgonia/tensor/f64"
+	"github.com/chewxy/gorgonia/tensor/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestBasicArithmeticDo(t *testing.T) {
@@ -19,3 +27,1162 @@ func TestBasicArithmeticDo(t *testing.T) {
 	}
 	t.Log(v)
 }
+
+// TestLinAlgBinOpConcurrentTranspose checks that ops which transpose their inputs do not touch the inputs, so that they may share them while running concurrently
+func TestLinAlgBinOpConcurrentTranspose(t *testing.T) {
+	a := FromTensor(tf64.NewTensor(tf64.WithShape(2, 3), tf64.WithBacking(tf64.RangeFloat64(0, 6))))
+	b := FromTensor(tf64.NewTensor(tf64.WithShape(2, 2), tf64.WithBacking([]float64{1, 2, 3, 4})))
+	v := FromTensor(tf64.NewTensor(tf64.WithShape(2), tf64.WithBacking([]float64{1, 2})))
+
+	matMul := linAlgBinOp{āBinaryOperator: matMulOperator, transA: true}
+	matVecMul := linAlgBinOp{āBinaryOperator: matVecMulOperator, transA: true}
+
+	// aᵀ × b and aᵀ × v
+	correctMM := []float64{9, 12, 13, 18, 17, 24}
+	correctMV := []float64{6, 9, 12}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				ret, err := matMul.Do(a, b)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if !reflect.DeepEqual(correctMM, extractF64s(ret)) {
+					errs <- errors.Errorf("aᵀ × b: expected %v. Got %v", correctMM, ret)
+					return
+				}
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				ret, err := matVecMul.Do(a, v)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if !reflect.DeepEqual(correctMV, extractF64s(ret)) {
+					errs <- errors.Errorf("aᵀ × v: expected %v. Got %v", correctMV, ret)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if !a.Shape().Eq(types.Shape{2, 3}) {
+		t.Errorf("Expected a to still be of shape (2, 3). Got %v", a.Shape())
+	}
+	if a.Tensor.(*tf64.Tensor).IsMaterializable() {
+		t.Error("Expected a to not be transposed")
+	}
+}
+
+func TestMulHighPrecisionAccum(t *testing.T) {
+	assert := assert.New(t)
+
+	const n = 512
+	r := rand.New(rand.NewSource(1337))
+	xs := make([]float32, n*n)
+	ys := make([]float32, n*n)
+	for i := range xs {
+		xs[i] = r.Float32()
+		ys[i] = r.Float32()
+	}
+
+	g := NewGraph()
+	x := NewMatrix(g, Float32, WithName("x"), WithShape(n, n), WithValue(tf32.NewTensor(tf32.WithBacking(xs), tf32.WithShape(n, n))))
+	y := NewMatrix(g, Float32, WithName("y"), WithShape(n, n), WithValue(tf32.NewTensor(tf32.WithBacking(ys), tf32.WithShape(n, n))))
+	def := Must(Mul(x, y))
+	hp := Must(Mul(x, y, WithHighPrecisionAccum()))
+	assert.NotEqual(def.Hashcode(), hp.Hashcode())
+
+	prog, locMap, err := Compile(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewTapeMachine(prog, locMap)
+	if err = m.RunAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the reference is the same multiplication carried out in float64
+	x64, _ := upcastF32(x.Value().(Tensor))
+	y64, _ := upcastF32(y.Value().(Tensor))
+	ref, err := linAlgBinOp{āBinaryOperator: matMulOperator}.Do(x64, y64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var defErr, hpErr float64
+	correct := extractF64s(ref)
+	defs := def.Value().(Tensor).Tensor.(*tf32.Tensor).Data().([]float32)
+	hps := hp.Value().(Tensor).Tensor.(*tf32.Tensor).Data().([]float32)
+	for i, c := range correct {
+		defErr += math.Abs(float64(defs[i]) - c)
+		hpErr += math.Abs(float64(hps[i]) - c)
+	}
+	t.Logf("Total absolute error: %v by default, %v with float64 accumulation", defErr, hpErr)
+	assert.True(hpErr < defErr, "Expected less error with float64 accumulation. Got %v, and %v by default", hpErr, defErr)
+
+	// increments, and transposes, are carried out in float64 too
+	a := FromTensor(tf32.NewTensor(tf32.WithBacking([]float32{1, 2, 3, 4}), tf32.WithShape(2, 2)))
+	b := FromTensor(tf32.NewTensor(tf32.WithBacking([]float32{5, 6, 7, 8}), tf32.WithShape(2, 2)))
+	incr := FromTensor(tf32.NewTensor(tf32.WithBacking([]float32{1, 1, 1, 1}), tf32.WithShape(2, 2)))
+	op := linAlgBinOp{āBinaryOperator: matMulOperator, transA: true, highPrecAccum: true}
+	if err = op.IncrDo(incr, a, b); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]float32{27, 31, 39, 45}, incr.Tensor.(*tf32.Tensor).Data())
+	assert.Equal([]float32{1, 2, 3, 4}, a.Tensor.(*tf32.Tensor).Data())
+}
+
+func TestElemUnaryOpUsePreallocDo(t *testing.T) {
+	g := NewGraph()
+	x := NewVector(g, Float64, WithShape(4), WithName("x"))
+	op := newElemUnaryOp(negOpType, x)
+
+	backing := []float64{1, -2, 3, -4}
+	input := FromTensor(tf64.NewTensor(tf64.WithBacking(backing)))
+	prealloc := FromTensor(tf64.NewTensor(tf64.WithShape(4)))
+
+	ret, err := op.UsePreallocDo(prealloc, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ret.(Tensor).Tensor != prealloc.Tensor {
+		t.Error("Expected the preallocated tensor to be returned")
+	}
+	if !reflect.DeepEqual([]float64{-1, 2, -3, 4}, extractF64s(ret)) {
+		t.Errorf("Expected the negated values. Got %v", ret)
+	}
+	if !reflect.DeepEqual([]float64{1, -2, 3, -4}, backing) {
+		t.Errorf("Expected the input to be untouched. Got %v", backing)
+	}
+
+	// a mismatched preallocated tensor is an error
+	if _, err = op.UsePreallocDo(FromTensor(tf64.NewTensor(tf64.WithShape(3))), input); err == nil {
+		t.Error("Expected an error with a preallocated tensor of the wrong size")
+	}
+}
+
+// the sigmoid chain benchmarks compare the allocations of a chain of 10 sigmoids, with and without a preallocated tensor
+func benchmarkSigmoidChain(b *testing.B, prealloc bool) {
+	g := NewGraph()
+	x := NewVector(g, Float64, WithShape(1024), WithName("x"))
+	op := newElemUnaryOp(sigmoidOpType, x)
+
+	input := FromTensor(tf64.NewTensor(tf64.WithBacking(tf64.RangeFloat64(0, 1024))))
+	bufs := [2]Value{
+		FromTensor(tf64.NewTensor(tf64.WithShape(1024))),
+		FromTensor(tf64.NewTensor(tf64.WithShape(1024))),
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		v := Value(input)
+		for j := 0; j < 10; j++ {
+			var err error
+			if prealloc {
+				v, err = op.UsePreallocDo(bufs[j%2], v)
+			} else {
+				v, err = op.Do(v)
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkSigmoidChain_Do(b *testing.B)            { benchmarkSigmoidChain(b, false) }
+func BenchmarkSigmoidChain_UsePreallocDo(b *testing.B) { benchmarkSigmoidChain(b, true) }
+
+func TestAddScalar(t *testing.T) {
+	assert := assert.New(t)
+
+	xs := []float64{1, 2, 3, 4, 5, 6}
+	ws := []float64{1, -1, 2, 0.5, 3, -2}
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		x := NewMatrix(g, Float64, WithName("x"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(2, 3))))
+		s := NewScalar(g, Float64, WithName("s"), WithValue(2.5))
+		w := NewMatrix(g, Float64, WithName("w"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(2, 3))))
+
+		y, err := applyOp(addScalarOp{d: 2}, x, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cost := Must(Sum(Must(HadamardProd(y, w))))
+
+		if useTape {
+			if _, err = Grad(cost, x, s); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.Equal([]float64{3.5, 4.5, 5.5, 6.5, 7.5, 8.5}, extractF64s(y.Value()), "Tape %t", useTape)
+
+		dx, err := x.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ds, err := s.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(ws, extractF64s(dx), "Tape %t", useTape)
+		assert.Equal(3.5, extractF64(ds), "Tape %t", useTape)
+	}
+
+	// AddScalar
+	g := NewGraph()
+	x := NewVector(g, Float32, WithName("x"), WithShape(3), WithValue(tf32.NewTensor(tf32.WithBacking([]float32{1, 2, 3}), tf32.WithShape(3))))
+	y, err := AddScalar(x, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewLispMachine(g, ExecuteFwdOnly())
+	if err = m.RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]float32{0, 1, 2}, y.Value().Data())
+	if _, err = AddScalar(NewScalar(g, Float64, WithName("s")), 1); err == nil {
+		t.Error("Expected an error when adding to a scalar")
+	}
+
+	// Do leaves the input alone, while UnsafeDo adds in place
+	op := addScalarOp{d: 1}
+	backing := []float64{1, 2, 3}
+	v := FromTensor(tf64.NewTensor(tf64.WithBacking(backing), tf64.WithShape(3)))
+	ret, err := op.Do(v, NewScalarValue(1.0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]float64{2, 3, 4}, extractF64s(ret))
+	assert.Equal([]float64{1, 2, 3}, backing)
+
+	if ret, err = op.UnsafeDo(v, NewScalarValue(1.0)); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]float64{2, 3, 4}, backing)
+	assert.True(ret.(Tensor).Tensor == v.Tensor)
+
+	// a view is copied before it is added to
+	mat := tf64.NewTensor(tf64.WithBacking([]float64{1, 2, 3, 4}), tf64.WithShape(2, 2))
+	view, err := mat.Slice(S(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret, err = op.UnsafeDo(FromTensor(view), NewScalarValue(10.0)); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]float64{13, 14}, extractF64s(ret))
+	assert.Equal([]float64{1, 2, 3, 4}, mat.Data())
+
+	if _, err = op.Do(v, NewScalarValue(float32(1))); err == nil {
+		t.Error("Expected an error when the Dtypes do not match")
+	}
+}
+
+// the add scalar benchmarks compare adding a scalar in place with addScalarOp and with the generic elemBinOp
+func benchmarkAddScalar(b *testing.B, specialized bool) {
+	g := NewGraph()
+	x := NewVector(g, Float64, WithShape(1024), WithName("x"))
+	s := NewScalar(g, Float64, WithName("s"))
+	var op UnsafeDoer = newElemBinOp(addOpType, x, s)
+	if specialized {
+		op = addScalarOp{d: 1}
+	}
+
+	input := FromTensor(tf64.NewTensor(tf64.WithBacking(tf64.RangeFloat64(0, 1024))))
+	scalar := NewScalarValue(1e-3)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := op.UnsafeDo(input, scalar); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestPowConst(t *testing.T) {
+	assert := assert.New(t)
+
+	xs := []float64{-1.5, -0.3, 0, 0.7, 1.1, 2.9}
+	ws := []float64{1, -1, 2, 0.5, 3, -2}
+
+	for _, exp := range []int{2, 3} {
+		// cost = Σ w × x^exp
+		cost := func(x []float64) float64 {
+			var retVal float64
+			for i, v := range x {
+				retVal += ws[i] * math.Pow(v, float64(exp))
+			}
+			return retVal
+		}
+		cxs := clonef64s(xs)
+		correctDX := numericGrad(cxs, func() float64 { return cost(cxs) })
+
+		for _, useTape := range []bool{true, false} {
+			g := NewGraph()
+			x := NewMatrix(g, Float64, WithName("x"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(2, 3))))
+			w := NewMatrix(g, Float64, WithName("w"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(2, 3))))
+
+			y, err := PowConst(x, exp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(types.Shape{2, 3}, y.Shape())
+			c := Must(Sum(Must(HadamardProd(y, w))))
+
+			if useTape {
+				if _, err = Grad(c, x); err != nil {
+					t.Fatal(err)
+				}
+				prog, locMap, err := Compile(g)
+				if err != nil {
+					t.Fatal(err)
+				}
+				m := NewTapeMachine(prog, locMap)
+				if err = m.RunAll(); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				m := NewLispMachine(g)
+				if err = m.RunAll(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// exactly the products
+			correct := make([]float64, len(xs))
+			for i, v := range xs {
+				if exp == 2 {
+					correct[i] = v * v
+				} else {
+					correct[i] = v * v * v
+				}
+			}
+			assert.Equal(correct, extractF64s(y.Value()), "exp %d, Tape %t", exp, useTape)
+
+			dx, err := x.Grad()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.True(floatsClose(correctDX, extractF64s(dx)), "exp %d, Tape %t. Expected %v. Got %v", exp, useTape, correctDX, dx)
+		}
+	}
+
+	// scalars and negative powers
+	g := NewGraph()
+	x := NewScalar(g, Float32, WithName("x"), WithValue(float32(2)))
+	y := Must(PowConst(x, -2))
+	m := NewLispMachine(g)
+	if err := m.RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(float32(0.25), y.Value().Data())
+	dx, err := x.Grad()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(float32(-0.25), dx.Data())
+
+	assert.Equal(1.0, ipow(5, 0))
+	assert.Equal(1024.0, ipow(2, 10))
+}
+
+func TestGreaterThan(t *testing.T) {
+	assert := assert.New(t)
+
+	xs := []float64{-1.5, 0.2, 0.5, 0.7, 3}
+	ws := []float64{1, -1, 2, 0.5, 3}
+	mask := []float64{0, 0, 0, 1, 1} // the threshold itself is not greater than the threshold
+
+	for _, straightThrough := range []bool{false, true} {
+		for _, useTape := range []bool{true, false} {
+			g := NewGraph()
+			x := NewVector(g, Float64, WithName("x"), WithShape(5), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(5))))
+			w := NewVector(g, Float64, WithName("w"), WithShape(5), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(5))))
+
+			m, err := GreaterThan(x, 0.5, straightThrough)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(types.Shape{5}, m.Shape())
+			var mV Value
+			Read(m, &mV)
+
+			// without straight through, x only gets a gradient from the product: cost = Σ w × mask × x
+			var c *Node
+			if straightThrough {
+				c = Must(Sum(Must(HadamardProd(m, w))))
+			} else {
+				c = Must(Sum(Must(HadamardProd(Must(HadamardProd(m, x)), w))))
+			}
+
+			if useTape {
+				if _, err = Grad(c, x); err != nil {
+					t.Fatal(err)
+				}
+				prog, locMap, err := Compile(g)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err = NewTapeMachine(prog, locMap).RunAll(); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				if err = NewLispMachine(g).RunAll(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			assert.Equal(mask, extractF64s(mV), "Straight through %t, Tape %t", straightThrough, useTape)
+
+			correctDX := make([]float64, len(ws))
+			for i, w := range ws {
+				if straightThrough {
+					correctDX[i] = w
+				} else {
+					correctDX[i] = w * mask[i]
+				}
+			}
+			dx, err := x.Grad()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(correctDX, extractF64s(dx), "Straight through %t, Tape %t", straightThrough, useTape)
+		}
+	}
+
+	// the mask alone cannot be differentiated
+	g := NewGraph()
+	x := NewVector(g, Float64, WithName("x"), WithShape(3))
+	c := Must(Sum(Must(GreaterThan(x, 0, false))))
+	if _, err := Grad(c, x); err == nil {
+		t.Error("Expected an error differentiating through a mask that is not straight through")
+	}
+
+	// float32 scalars
+	g = NewGraph()
+	s := NewScalar(g, Float32, WithName("s"), WithValue(float32(2)))
+	y := Must(GreaterThan(s, 1, true))
+	if err := NewLispMachine(g).RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(float32(1), y.Value().Data())
+	ds, err := s.Grad()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(float32(1), ds.Data())
+}
+
+func TestMaximumScalar(t *testing.T) {
+	assert := assert.New(t)
+
+	xs := []float64{-1.5, 0, 0.2, 0.5, 0.7, 3}
+	ws := []float64{1, -1, 2, 0.5, 3, -2}
+
+	testCases := []struct {
+		c       float64
+		correct []float64
+		mask    []float64 // where the gradient passes: x > c, so not at c itself
+	}{
+		{0, []float64{0, 0, 0.2, 0.5, 0.7, 3}, []float64{0, 0, 1, 1, 1, 1}},
+		{0.5, []float64{0.5, 0.5, 0.5, 0.5, 0.7, 3}, []float64{0, 0, 0, 0, 1, 1}},
+	}
+
+	for _, tc := range testCases {
+		for _, useTape := range []bool{true, false} {
+			g := NewGraph()
+			x := NewVector(g, Float64, WithName("x"), WithShape(6), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(6))))
+			w := NewVector(g, Float64, WithName("w"), WithShape(6), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(6))))
+
+			var y *Node
+			var err error
+			if tc.c == 0 {
+				y, err = Relu(x)
+			} else {
+				y, err = MaximumScalar(x, tc.c)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(types.Shape{6}, y.Shape())
+			var yV Value
+			Read(y, &yV)
+
+			c := Must(Sum(Must(HadamardProd(y, w))))
+			if useTape {
+				if _, err = Grad(c, x); err != nil {
+					t.Fatal(err)
+				}
+				prog, locMap, err := Compile(g)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err = NewTapeMachine(prog, locMap).RunAll(); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				if err = NewLispMachine(g).RunAll(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			assert.Equal(tc.correct, extractF64s(yV), "c=%v, Tape %t", tc.c, useTape)
+
+			correctDX := make([]float64, len(ws))
+			for i, w := range ws {
+				correctDX[i] = w * tc.mask[i]
+			}
+			dx, err := x.Grad()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(correctDX, extractF64s(dx), "c=%v, Tape %t", tc.c, useTape)
+		}
+	}
+
+	// float32 scalars
+	g := NewGraph()
+	s := NewScalar(g, Float32, WithName("s"), WithValue(float32(2)))
+	y := Must(MaximumScalar(s, 0.5))
+	if err := NewLispMachine(g).RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(float32(2), y.Value().Data())
+	ds, err := s.Grad()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(float32(1), ds.Data())
+
+	v, err := maximumScalarOp{c: 0.5}.Do(FromTensor(tf32.NewTensor(tf32.WithBacking([]float32{-1, 0.25, 2}), tf32.WithShape(3))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]float32{0.5, 0.5, 2}, v.Data())
+}
+
+func TestLogit(t *testing.T) {
+	assert := assert.New(t)
+
+	ps := []float64{0.1, 0.25, 0.5, 0.8, 0.95}
+	ws := []float64{1, -1, 2, 0.5, 3}
+
+	// cost = Σ w * log(p / (1-p))
+	pc := clonef64s(ps)
+	correctDP := numericGrad(pc, func() float64 {
+		var retVal float64
+		for i, p := range pc {
+			retVal += ws[i] * math.Log(p/(1-p))
+		}
+		return retVal
+	})
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		p := NewVector(g, Float64, WithName("p"), WithShape(5), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(ps)), tf64.WithShape(5))))
+		w := NewVector(g, Float64, WithName("w"), WithShape(5), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(5))))
+		ones := NewConstant(tf64.NewTensor(tf64.WithBacking([]float64{1, 1, 1, 1, 1}), tf64.WithShape(5)))
+
+		y, err := Logit(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(types.Shape{5}, y.Shape())
+
+		// the composed form, log(p / (1-p))
+		composed := Must(Log(Must(HadamardDiv(p, Must(Sub(ones, p))))))
+
+		var yV, composedV Value
+		Read(y, &yV)
+		Read(composed, &composedV)
+
+		c := Must(Sum(Must(HadamardProd(y, w))))
+		if useTape {
+			if _, err = Grad(c, p); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = NewTapeMachine(prog, locMap).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			if err = NewLispMachine(g).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.True(floatsClose(extractF64s(composedV), extractF64s(yV)), "Tape %t. Expected %v. Got %v", useTape, composedV, yV)
+
+		dp, err := p.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(correctDP, extractF64s(dp)), "Tape %t. Expected %v. Got %v", useTape, correctDP, dp)
+	}
+
+	// 0 and 1 are clamped, so both the logits and the gradients are finite
+	op := logitOp{eps: logitEps}
+	bounds := FromTensor(tf32.NewTensor(tf32.WithBacking([]float32{0, 1}), tf32.WithShape(2)))
+	y, err := op.Do(bounds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ys := y.Data().([]float32)
+	assert.InDelta(math.Log(logitEps/(1-logitEps)), float64(ys[0]), 1e-3)
+	assert.InDelta(-math.Log(logitEps/(1-logitEps)), float64(ys[1]), 1e-3)
+
+	grads := FromTensor(tf32.NewTensor(tf32.WithBacking([]float32{1, 1}), tf32.WithShape(2)))
+	d, err := logitDiffOp(op).Do(bounds, grads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range d.Data().([]float32) {
+		assert.False(math.IsInf(float64(v), 0) || math.IsNaN(float64(v)), "%v", d)
+	}
+}
+
+func TestHardSigmoid(t *testing.T) {
+	assert := assert.New(t)
+
+	xs := []float64{-4, -2.5, -1, 0, 2, 2.5, 3}
+	ws := []float64{1, -1, 2, 0.5, 3, -2, 1}
+	correct := []float64{0, 0, 0.3, 0.5, 0.9, 1, 1}
+	mask := []float64{0, 0, 1, 1, 1, 0, 0} // -2.5 and 2.5 are right at the boundaries, where the gradient is 0
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		x := NewVector(g, Float64, WithName("x"), WithShape(7), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(7))))
+		w := NewVector(g, Float64, WithName("w"), WithShape(7), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(7))))
+
+		y, err := HardSigmoid(x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(types.Shape{7}, y.Shape())
+		var yV Value
+		Read(y, &yV)
+
+		c := Must(Sum(Must(HadamardProd(y, w))))
+		if useTape {
+			if _, err = Grad(c, x); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = NewTapeMachine(prog, locMap).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			if err = NewLispMachine(g).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.True(floatsClose(correct, extractF64s(yV)), "Tape %t. Expected %v. Got %v", useTape, correct, yV)
+
+		correctDX := make([]float64, len(ws))
+		for i, w := range ws {
+			correctDX[i] = 0.2 * w * mask[i]
+		}
+		dx, err := x.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(correctDX, extractF64s(dx)), "Tape %t. Expected %v. Got %v", useTape, correctDX, dx)
+	}
+
+	// float32 scalars
+	g := NewGraph()
+	s := NewScalar(g, Float32, WithName("s"), WithValue(float32(1)))
+	y := Must(HardSigmoid(s))
+	if err := NewLispMachine(g).RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	assert.InDelta(0.7, float64(y.Value().Data().(float32)), 1e-6)
+	ds, err := s.Grad()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.InDelta(0.2, float64(ds.Data().(float32)), 1e-6)
+}
+
+func TestSignedSqrt(t *testing.T) {
+	assert := assert.New(t)
+
+	xs := []float64{-9, -2, -0.25, 0.5, 4, 16}
+	ws := []float64{1, -1, 2, 0.5, 3, -2}
+	correct := []float64{-3, -math.Sqrt2, -0.5, math.Sqrt(0.5), 2, 4}
+
+	// none of xs are near 0, so the gradient agrees with a numerical one
+	xc := clonef64s(xs)
+	correctDX := numericGrad(xc, func() (retVal float64) {
+		for i, x := range xc {
+			if x < 0 {
+				retVal -= ws[i] * math.Sqrt(-x)
+			} else {
+				retVal += ws[i] * math.Sqrt(x)
+			}
+		}
+		return
+	})
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		x := NewVector(g, Float64, WithName("x"), WithShape(6), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(6))))
+		w := NewVector(g, Float64, WithName("w"), WithShape(6), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(6))))
+
+		y, err := SignedSqrt(x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(types.Shape{6}, y.Shape())
+		var yV Value
+		Read(y, &yV)
+
+		c := Must(Sum(Must(HadamardProd(y, w))))
+		if useTape {
+			if _, err = Grad(c, x); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = NewTapeMachine(prog, locMap).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			if err = NewLispMachine(g).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.True(floatsClose(correct, extractF64s(yV)), "Tape %t. Expected %v. Got %v", useTape, correct, yV)
+
+		dx, err := x.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(correctDX, extractF64s(dx)), "Tape %t. Expected %v. Got %v", useTape, correctDX, dx)
+	}
+
+	// float32 scalars, at 0, where the gradient is large but finite
+	g := NewGraph()
+	s := NewScalar(g, Float32, WithName("s"), WithValue(float32(0)))
+	y := Must(SignedSqrt(s))
+	if err := NewLispMachine(g).RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(float32(0), y.Value().Data().(float32))
+	ds, err := s.Grad()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := float64(ds.Data().(float32))
+	assert.False(math.IsInf(d, 0) || math.IsNaN(d), "Expected a finite gradient at 0. Got %v", d)
+	assert.InDelta(0.5/math.Sqrt(signedSqrtEps), d, 1)
+}
+
+func TestSwish(t *testing.T) {
+	assert := assert.New(t)
+
+	xs := []float64{-6, -1.5, -0.25, 0, 0.5, 2, 8}
+	ws := []float64{1, -1, 2, 0.5, 3, -2, 1}
+	swish := func(x, beta float64) float64 { return x / (1 + math.Exp(-beta*x)) }
+
+	for _, beta := range []float64{1, 2} {
+		correct := make([]float64, len(xs))
+		for i, x := range xs {
+			correct[i] = swish(x, beta)
+		}
+		xc := clonef64s(xs)
+		correctDX := numericGrad(xc, func() (retVal float64) {
+			for i, x := range xc {
+				retVal += ws[i] * swish(x, beta)
+			}
+			return
+		})
+
+		for _, useTape := range []bool{true, false} {
+			g := NewGraph()
+			x := NewVector(g, Float64, WithName("x"), WithShape(7), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(7))))
+			w := NewVector(g, Float64, WithName("w"), WithShape(7), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(7))))
+
+			y, err := Swish(x, beta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(types.Shape{7}, y.Shape())
+			var yV Value
+			Read(y, &yV)
+
+			c := Must(Sum(Must(HadamardProd(y, w))))
+			if useTape {
+				if _, err = Grad(c, x); err != nil {
+					t.Fatal(err)
+				}
+				prog, locMap, err := Compile(g)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err = NewTapeMachine(prog, locMap).RunAll(); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				if err = NewLispMachine(g).RunAll(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			assert.True(floatsClose(correct, extractF64s(yV)), "Beta %v Tape %t. Expected %v. Got %v", beta, useTape, correct, yV)
+
+			dx, err := x.Grad()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.True(floatsClose(correctDX, extractF64s(dx)), "Beta %v Tape %t. Expected %v. Got %v", beta, useTape, correctDX, dx)
+		}
+	}
+
+	// different betas are different ops
+	assert.NotEqual(swishOp{beta: 1}.Hashcode(), swishOp{beta: 2}.Hashcode())
+
+	// float32 scalars, at 0, where the slope is σ(0) = 0.5 whatever beta is
+	g := NewGraph()
+	s := NewScalar(g, Float32, WithName("s"), WithValue(float32(0)))
+	y := Must(Swish(s, 2))
+	if err := NewLispMachine(g).RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(float32(0), y.Value().Data().(float32))
+	ds, err := s.Grad()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.InDelta(0.5, float64(ds.Data().(float32)), 1e-6)
+}
+
+func TestFakeQuant(t *testing.T) {
+	assert := assert.New(t)
+
+	// scale 0.5 and zero point 2 over [0, 7], so that the representable values are -1, -0.5, ..., 2.5
+	xs := []float64{-3, -1.1, -0.3, 0.25, 0.75, 1.2, 2.6, 2.8, 10}
+	ws := []float64{1, -1, 2, 0.5, 3, -2, 1, 4, -3}
+	correct := []float64{-1, -1, -0.5, 0, 1, 1, 2.5, 2.5, 2.5} // 0.25 / 0.5 and 0.75 / 0.5 are halves, which are rounded to even
+	mask := []float64{0, 1, 1, 1, 1, 1, 1, 0, 0}               // 2.6 rounds to 7, which is just within range, and 2.8 to 8, which is not
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		x := NewVector(g, Float64, WithName("x"), WithShape(9), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(9))))
+		w := NewVector(g, Float64, WithName("w"), WithShape(9), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(9))))
+
+		y, err := FakeQuant(x, 0.5, 2, 0, 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(types.Shape{9}, y.Shape())
+		var yV Value
+		Read(y, &yV)
+
+		c := Must(Sum(Must(HadamardProd(y, w))))
+		if useTape {
+			if _, err = Grad(c, x); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = NewTapeMachine(prog, locMap).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			if err = NewLispMachine(g).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.Equal(correct, extractF64s(yV), "Tape %t", useTape)
+
+		correctDX := make([]float64, len(ws))
+		for i, w := range ws {
+			correctDX[i] = w * mask[i]
+		}
+		dx, err := x.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(correctDX, extractF64s(dx), "Tape %t", useTape)
+	}
+
+	// float32 scalars, quantized to signed 8 bits
+	g := NewGraph()
+	s := NewScalar(g, Float32, WithName("s"), WithValue(float32(0.337)))
+	y := Must(FakeQuant(s, 0.01, 0, -128, 127))
+	if err := NewLispMachine(g).RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	assert.InDelta(0.34, float64(y.Value().Data().(float32)), 1e-6)
+	ds, err := s.Grad()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(float32(1), ds.Data().(float32))
+
+	// different quantization parameters are different ops
+	assert.NotEqual(fakeQuantOp{scale: 0.5, zeroPoint: 2, qmin: 0, qmax: 7}.Hashcode(), fakeQuantOp{scale: 0.5, zeroPoint: 2, qmin: 0, qmax: 15}.Hashcode())
+
+	// bad parameters
+	g = NewGraph()
+	x := NewVector(g, Float64, WithShape(3))
+	_, err = FakeQuant(x, 0, 0, 0, 255)
+	assert.NotNil(err)
+	_, err = FakeQuant(x, 0.1, 0, 255, 0)
+	assert.NotNil(err)
+	_, err = FakeQuant(x, 0.1, 300, 0, 255)
+	assert.NotNil(err)
+}
+
+func BenchmarkAddScalar_ElemBinOp(b *testing.B)   { benchmarkAddScalar(b, false) }
+func BenchmarkAddScalar_AddScalarOp(b *testing.B) { benchmarkAddScalar(b, true) }
+
+func TestApplyFn(t *testing.T) {
+	assert := assert.New(t)
+
+	cube := func(x float64) float64 { return x * x * x }
+	dcube := func(x float64) float64 { return 3 * x * x }
+
+	xs := []float64{-1.5, -0.3, 0, 0.7, 1.1, 2.9}
+	ws := []float64{1, -1, 2, 0.5, 3, -2}
+
+	// cost = Σ w × x³
+	cost := func(x []float64) float64 {
+		var retVal float64
+		for i, v := range x {
+			retVal += ws[i] * cube(v)
+		}
+		return retVal
+	}
+	cxs := clonef64s(xs)
+	correctDX := numericGrad(cxs, func() float64 { return cost(cxs) })
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		x := NewMatrix(g, Float64, WithName("x"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(2, 3))))
+		w := NewMatrix(g, Float64, WithName("w"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(2, 3))))
+
+		y, err := ApplyFn(x, cube, dcube)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(types.Shape{2, 3}, y.Shape())
+		c := Must(Sum(Must(HadamardProd(y, w))))
+
+		if useTape {
+			if _, err = Grad(c, x); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		correct := make([]float64, len(xs))
+		for i, v := range xs {
+			correct[i] = cube(v)
+		}
+		assert.Equal(correct, extractF64s(y.Value()), "Tape %t", useTape)
+		assert.Equal(xs, extractF64s(x.Value()), "Tape %t: the input should be untouched", useTape)
+
+		dx, err := x.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(correctDX, extractF64s(dx)), "Tape %t. Expected %v. Got %v", useTape, correctDX, dx)
+	}
+
+	// float32 scalars
+	g := NewGraph()
+	s := NewScalar(g, Float32, WithName("s"), WithValue(float32(2)))
+	y := Must(ApplyFn(s, cube, dcube))
+	if err := NewLispMachine(g).RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(float32(8), y.Value().Data())
+	ds, err := s.Grad()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(float32(12), ds.Data())
+
+	// the same function applied twice is two different nodes
+	g = NewGraph()
+	x := NewVector(g, Float64, WithName("x"), WithShape(3))
+	assert.NotEqual(Must(ApplyFn(x, cube, dcube)), Must(ApplyFn(x, cube, dcube)))
+
+	// no derivative: fine going forwards, an error going backwards
+	noDerivative := func() (g *ExprGraph, x, y, c *Node) {
+		g = NewGraph()
+		x = NewVector(g, Float64, WithName("x"), WithShape(3), WithValue(tf64.NewTensor(tf64.WithBacking([]float64{1, 2, 3}), tf64.WithShape(3))))
+		y = Must(ApplyFn(x, cube, nil))
+		c = Must(Sum(y))
+		return
+	}
+	g, x, y, c := noDerivative()
+	if err = NewLispMachine(g, ExecuteFwdOnly()).RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]float64{1, 8, 27}, extractF64s(y.Value()))
+
+	if _, err = Grad(c, x); err == nil {
+		t.Error("Expected an error differentiating ApplyFn without a derivative")
+	} else {
+		assert.Contains(err.Error(), "no derivative was given to ApplyFn")
+	}
+	g, x, _, _ = noDerivative()
+	if err = NewLispMachine(g).RunAll(); err == nil {
+		t.Error("Expected an error backpropagating through ApplyFn without a derivative")
+	} else {
+		assert.Contains(err.Error(), "no derivative was given to ApplyFn")
+	}
+
+	if _, err = ApplyFn(x, nil, nil); err == nil {
+		t.Error("Expected an error with a nil function")
+	}
+}
+
+func TestReduceGradientToShape(t *testing.T) {
+	assert := assert.New(t)
+
+	gs := []float64{1, 2, 3, 4, 5, 6}
+	reductions := []struct {
+		target  types.Shape
+		correct []float64
+	}{
+		{types.Shape{1, 3}, []float64{5, 7, 9}},
+		{types.Shape{3}, []float64{5, 7, 9}},
+		{types.Shape{2, 1}, []float64{6, 15}},
+		{types.Shape{2, 3}, gs},
+	}
+
+	// values
+	for _, r := range reductions {
+		grad := FromTensor(tf64.NewTensor(tf64.WithBacking(clonef64s(gs)), tf64.WithShape(2, 3)))
+		d, err := reduceGradValueToShape(grad, r.target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(r.target.Eq(d.Shape()), "%v: got %v", r.target, d.Shape())
+		assert.Equal(r.correct, extractF64s(d), "%v", r.target)
+	}
+
+	grad := FromTensor(tf64.NewTensor(tf64.WithBacking(clonef64s(gs)), tf64.WithShape(2, 3)))
+	d, err := reduceGradValueToShape(grad, scalarShape)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(21.0, extractF64(d))
+	if _, err = reduceGradValueToShape(grad, types.Shape{2, 2}); err == nil {
+		t.Error("Expected an error when the gradient cannot have been broadcast from the target")
+	}
+
+	// nodes
+	for _, r := range reductions {
+		g := NewGraph()
+		gn := NewMatrix(g, Float64, WithName("grad"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(gs)), tf64.WithShape(2, 3))))
+		n, err := reduceGradientToShape(gn, r.target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = NewLispMachine(g, ExecuteFwdOnly()).RunAll(); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(r.correct, extractF64s(n.Value()), "%v", r.target)
+	}
+
+	// a scalar input of an elementwise op: ∂(Σ x*s)/∂s = Σ x
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		x := NewMatrix(g, Float64, WithName("x"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(gs)), tf64.WithShape(2, 3))))
+		s := NewScalar(g, Float64, WithName("s"), WithValue(2.0))
+		cost := Must(Sum(Must(Mul(x, s))))
+
+		if useTape {
+			if _, err = Grad(cost, x, s); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = NewTapeMachine(prog, locMap).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else if err = NewLispMachine(g).RunAll(); err != nil {
+			t.Fatal(err)
+		}
+
+		ds, err := s.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(21.0, extractF64(ds), "Tape %t", useTape)
+
+		dx, err := x.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal([]float64{2, 2, 2, 2, 2, 2}, extractF64s(dx), "Tape %t", useTape)
+	}
+}
@@ -1,14 +1,18 @@
 package gorgonia
 
 import (
+	"encoding/binary"
 	"fmt"
 	"hash"
 	"hash/fnv"
-	"time"
+	"math"
+	"sync"
 
 	tf32 "github.com/chewxy/gorgonia/tensor/f32"
 	tf64 "github.com/chewxy/gorgonia/tensor/f64"
+	ti "github.com/chewxy/gorgonia/tensor/i"
 	"github.com/chewxy/gorgonia/tensor/types"
+	"github.com/chewxy/math32"
 	"github.com/leesper/go_rng"
 	"github.com/pkg/errors"
 )
@@ -68,30 +72,30 @@ func (op randomOp) Do(...Value) (retVal Value, err error) {
 		case Float64:
 			switch op.which {
 			case uniform:
-				rand := rng.NewUniformGenerator(time.Now().UnixNano())
+				rand := rng.NewUniformGenerator(randomSeed())
 				v := rand.Float64Range(op.a, op.b)
 				return anyToValue(v)
 			case gaussian:
-				rand := rng.NewGaussianGenerator(time.Now().UnixNano())
+				rand := rng.NewGaussianGenerator(randomSeed())
 				v := rand.Gaussian(op.a, op.b)
 				return anyToValue(v)
 			case binomial:
-				rand := rng.NewBinomialGenerator(time.Now().UnixNano())
+				rand := rng.NewBinomialGenerator(randomSeed())
 				v := float64(rand.Binomial(int64(op.a), op.b))
 				return anyToValue(v)
 			}
 		case Float32:
 			switch op.which {
 			case uniform:
-				rand := rng.NewUniformGenerator(time.Now().UnixNano())
+				rand := rng.NewUniformGenerator(randomSeed())
 				v := rand.Float32Range(float32(op.a), float32(op.b))
 				return anyToValue(v)
 			case gaussian:
-				rand := rng.NewGaussianGenerator(time.Now().UnixNano())
+				rand := rng.NewGaussianGenerator(randomSeed())
 				v := float32(rand.Gaussian(op.a, op.b))
 				return anyToValue(v)
 			case binomial:
-				rand := rng.NewBinomialGenerator(time.Now().UnixNano())
+				rand := rng.NewBinomialGenerator(randomSeed())
 				v := float32(rand.Binomial(int64(op.a), op.b))
 				return anyToValue(v)
 			}
@@ -138,6 +142,7 @@ func (op randomOp) Do(...Value) (retVal Value, err error) {
 func (op randomOp) returnsPtr() bool    { return false }
 func (op randomOp) callsExtern() bool   { return false }
 func (op randomOp) overwriteInput() int { return -1 }
+func (op randomOp) isStateful() bool    { return true }
 func (op randomOp) WriteHash(h hash.Hash) {
 	fmt.Fprintf(h, "%d%v%f%f", op.which, op.shape, op.a, op.b)
 }
@@ -148,6 +153,4645 @@ func (op randomOp) Hashcode() uint32 {
 	return h.Sum32()
 }
 
+func (op randomOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeByte(byte(op.which))
+	w.writeInts(op.shape)
+	w.writeByte(byte(op.dt))
+	w.writeFloat(op.a)
+	w.writeFloat(op.b)
+	return w.bytes()
+}
+
+func (op *randomOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.which = randomness(r.readByte())
+	op.shape = r.readShape()
+	op.dt = r.readDtype()
+	op.a = r.readFloat()
+	op.b = r.readFloat()
+	r.check(op.which <= binomial, "Unknown randomness %d", op.which)
+	r.check(op.dt == Float64 || op.dt == Float32, "Expected random values of Float64 or Float32. Got %v instead", op.dt)
+	return r.done()
+}
+
 func (op randomOp) String() string {
 	return fmt.Sprintf("%v(%v, %v) - %v", op.which, op.a, op.b, op.shape)
 }
+
+// noiseOp is a randomOp that draws its values from a random number generator seeded with a fixed seed, instead of from a new generator every time.
+// Every time it is executed it draws fresh values, but two noiseOps with the same parameters and seed draw the same sequence of values, which makes runs reproducible.
+//
+// Like a constant, a noiseOp has no inputs and its node belongs to no graph until it is used. Unlike a constant, it is stateful: it must never be folded or
+// eliminated as a common subexpression. The last value drawn is kept in its noiseSource, and is nil until it is first executed.
+// Copies of a noiseOp share the generator, so a node used in several graphs draws from the one sequence.
+type noiseOp struct {
+	randomOp
+	seed int64
+	src  *noiseSource
+}
+
+// noiseSource is the state shared by the copies of a noiseOp
+type noiseSource struct {
+	sync.Mutex
+	uniform  *rng.UniformGenerator
+	gaussian *rng.GaussianGenerator
+	last     Value
+}
+
+func makeNoiseOp(which randomness, dt Dtype, a, b float64, seed int64, shape types.Shape) noiseOp {
+	src := new(noiseSource)
+	switch which {
+	case uniform:
+		src.uniform = rng.NewUniformGenerator(seed)
+	case gaussian:
+		src.gaussian = rng.NewGaussianGenerator(seed)
+	}
+
+	return noiseOp{
+		randomOp: makeRandomOp(which, dt, a, b, shape...),
+		seed:     seed,
+		src:      src,
+	}
+}
+
+func (op noiseOp) Do(...Value) (retVal Value, err error) {
+	if op.src == nil {
+		return nil, errors.Errorf("%v has no random number generator. Use GaussianNoise or UniformNoise to create it", op)
+	}
+
+	op.src.Lock()
+	defer op.src.Unlock()
+
+	size := 1
+	if !op.shape.IsScalar() {
+		size = op.shape.TotalSize()
+	}
+	backing := make([]float64, size)
+	for i := range backing {
+		switch op.which {
+		case uniform:
+			backing[i] = op.src.uniform.Float64Range(op.a, op.b)
+		case gaussian:
+			backing[i] = op.src.gaussian.Gaussian(op.a, op.b)
+		default:
+			return nil, errors.Errorf(nyiFail, "noiseOp.Do()", op.which)
+		}
+	}
+
+	switch {
+	case op.dt == Float64 && op.shape.IsScalar():
+		retVal = NewScalarValue(backing[0])
+	case op.dt == Float64:
+		retVal = FromTensor(tf64.NewTensor(tf64.WithBacking(backing), tf64.WithShape(op.shape...)))
+	case op.dt == Float32 && op.shape.IsScalar():
+		retVal = NewScalarValue(float32(backing[0]))
+	case op.dt == Float32:
+		retVal = FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(backing)), tf32.WithShape(op.shape...)))
+	default:
+		return nil, errors.Errorf(nyiFail, "noiseOp.Do()", op.dt)
+	}
+
+	op.src.last = retVal
+	return
+}
+
+// lastValue returns the last value drawn
+func (op noiseOp) lastValue() Value {
+	if op.src == nil {
+		return nil
+	}
+	op.src.Lock()
+	defer op.src.Unlock()
+	return op.src.last
+}
+
+func (op noiseOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("noise"))
+	op.randomOp.WriteHash(h)
+	if err := binary.Write(h, binary.LittleEndian, op.seed); err != nil {
+		panic(err)
+	}
+}
+
+func (op noiseOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// MarshalBinary writes out the parameters and the seed, but not the state of the generator:
+// a decoded noiseOp draws its sequence of values from the start, as a new one with the same seed would.
+func (op noiseOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeByte(byte(op.which))
+	w.writeInts(op.shape)
+	w.writeByte(byte(op.dt))
+	w.writeFloat(op.a)
+	w.writeFloat(op.b)
+	w.write(op.seed)
+	return w.bytes()
+}
+
+func (op *noiseOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	which := randomness(r.readByte())
+	shape := r.readShape()
+	dt := r.readDtype()
+	a, b := r.readFloat(), r.readFloat()
+	var seed int64
+	r.read(&seed)
+	r.check(which == uniform || which == gaussian, "Expected uniform or gaussian noise. Got randomness %d instead", which)
+	r.check(dt == Float64 || dt == Float32, "Expected noise of Float64 or Float32. Got %v instead", dt)
+	if err := r.done(); err != nil {
+		return err
+	}
+	*op = makeNoiseOp(which, dt, a, b, seed, shape)
+	return nil
+}
+
+func (op noiseOp) String() string {
+	switch op.which {
+	case uniform:
+		return fmt.Sprintf("UniformNoise(%v, %v, seed %d) - %v", op.a, op.b, op.seed, op.shape)
+	default:
+		return fmt.Sprintf("GaussianNoise(%v, %v, seed %d) - %v", op.a, op.b, op.seed, op.shape)
+	}
+}
+
+// lstmCellOp computes one step of a LSTM cell, fusing the non-linearities of the four gates.
+//
+// It takes two inputs: the pre-activation gates (typically Wx·x + Wh·h + b), and the previous cell state.
+// The gates are laid out as [input; forget; output; candidate], each of which has the same size as the cell state.
+// The result is the new hidden state and the new cell state, laid out as [h; c]
+type lstmCellOp struct{}
+
+// lstmCellOp has this type:
+//		op :: Vector a → Vector a → Vector a
+func (op lstmCellOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	tt := newTensorType(1, a)
+	return newFunctionType(tt, tt, tt)
+}
+
+func (op lstmCellOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "lstmCellOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	gates, c := inputs[0], inputs[1]
+	if gates.shape.TotalSize() != 4*c.shape.TotalSize() {
+		return nil, errors.Errorf("Expected gates of shape %v to be 4 times the size of the cell state of shape %v", gates.shape, c.shape)
+	}
+
+	retVal = c.shape.Clone()
+	retVal[0] *= 2
+	return
+}
+
+func (op lstmCellOp) DiffWRT(inputs int) []bool { return []bool{true, true} }
+
+func (op lstmCellOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "lstmCellOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	retVal = make(Nodes, 2)
+	for i := range retVal {
+		diffOp := lstmCellDiffOp{wrt: i}
+		if retVal[i], err = applyOp(diffOp, inputs[0], inputs[1], gradNode); err != nil {
+			return nil, errors.Wrap(err, applyOpFail)
+		}
+		retVal[i].setGroup(gradClust)
+	}
+	return
+}
+
+func (op lstmCellOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "lstmCellOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	gdv := inputs[0].boundTo.(*dualValue)
+	cdv := inputs[1].boundTo.(*dualValue)
+	odv := output.boundTo.(*dualValue)
+
+	var dGates, dC Value
+	if dGates, dC, err = lstmCellBackward(gdv.Value, cdv.Value, odv.d); err != nil {
+		return errors.Wrap(err, "lstmCellOp.DoDiff()")
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(gdv.d, dGates); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+
+	add = newElemBinOp(addOpType, inputs[1], inputs[1])
+	if _, err = add.UnsafeDo(cdv.d, dC); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op lstmCellOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "lstmCellOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return lstmCellForward(inputs[0], inputs[1])
+}
+
+func (op lstmCellOp) returnsPtr() bool    { return false }
+func (op lstmCellOp) callsExtern() bool   { return false }
+func (op lstmCellOp) overwriteInput() int { return -1 }
+func (op lstmCellOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("lstmCell"))
+}
+
+func (op lstmCellOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op lstmCellOp) String() string { return "LSTMCell" }
+
+// lstmCellDiffOp is the derivative of lstmCellOp with regards to either the gates (wrt = 0) or the previous cell state (wrt = 1).
+// The inputs are the gates, the previous cell state and the gradient of the output of the lstmCellOp.
+type lstmCellDiffOp struct {
+	wrt int
+}
+
+// lstmCellDiffOp has this type:
+//		op :: Vector a → Vector a → Vector a → Vector a
+func (op lstmCellDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	tt := newTensorType(1, a)
+	return newFunctionType(tt, tt, tt, tt)
+}
+
+func (op lstmCellDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "lstmCellDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[op.wrt].shape.Clone(), nil
+}
+
+func (op lstmCellDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op lstmCellDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op lstmCellDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "lstmCellDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var dGates, dC Value
+	if dGates, dC, err = lstmCellBackward(inputs[0], inputs[1], inputs[2]); err != nil {
+		return
+	}
+
+	if op.wrt == 0 {
+		return dGates, nil
+	}
+	return dC, nil
+}
+
+func (op lstmCellDiffOp) returnsPtr() bool    { return false }
+func (op lstmCellDiffOp) callsExtern() bool   { return false }
+func (op lstmCellDiffOp) overwriteInput() int { return -1 }
+func (op lstmCellDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("lstmCellDiff"))
+	if err := binary.Write(h, binary.LittleEndian, byte(op.wrt)); err != nil {
+		panic(err)
+	}
+}
+
+func (op lstmCellDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op lstmCellDiffOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.wrt)
+	return w.bytes()
+}
+
+func (op *lstmCellDiffOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.wrt = r.readInt()
+	r.checkWRT(op.wrt, 2)
+	return r.done()
+}
+
+func (op lstmCellDiffOp) String() string { return fmt.Sprintf("∂LSTMCell/∂%d", op.wrt) }
+
+/* LSTM cell kernels */
+
+func lstmCellForward(gates, c Value) (retVal Value, err error) {
+	gt, ct, err := lstmCellOperands(gates, c)
+	if err != nil {
+		return nil, err
+	}
+
+	outShape := ct.Shape().Clone()
+	outShape[0] *= 2
+
+	switch g := gt.(type) {
+	case *tf64.Tensor:
+		out := make([]float64, outShape.TotalSize())
+		lstmCellFwdf64(materializedF64s(g), materializedF64s(ct.(*tf64.Tensor)), out)
+		retVal = FromTensor(tf64.NewTensor(tf64.WithBacking(out), tf64.WithShape(outShape...)))
+	case *tf32.Tensor:
+		out := make([]float32, outShape.TotalSize())
+		lstmCellFwdf32(materializedF32s(g), materializedF32s(ct.(*tf32.Tensor)), out)
+		retVal = FromTensor(tf32.NewTensor(tf32.WithBacking(out), tf32.WithShape(outShape...)))
+	default:
+		return nil, errors.Errorf(nyiFail, "lstmCellOp.Do()", gt)
+	}
+	return
+}
+
+func lstmCellBackward(gates, c, grad Value) (dGates, dC Value, err error) {
+	gt, ct, err := lstmCellOperands(gates, c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var gradT types.Tensor
+	if g, ok := grad.(Tensor); ok {
+		gradT = g.Tensor
+	} else {
+		return nil, nil, errors.Errorf("Expected the gradient of a LSTM cell to be a Tensor. Got %T instead", grad)
+	}
+
+	if gradT.Shape().TotalSize() != 2*ct.Shape().TotalSize() {
+		return nil, nil, errors.Errorf("Expected the gradient of shape %v to be twice the size of the cell state of shape %v", gradT.Shape(), ct.Shape())
+	}
+
+	switch g := gt.(type) {
+	case *tf64.Tensor:
+		gradF64, ok := gradT.(*tf64.Tensor)
+		if !ok {
+			return nil, nil, errors.Errorf(dtypeMismatch, Float64, gradT.Dtype())
+		}
+
+		dg := make([]float64, g.Shape().TotalSize())
+		dc := make([]float64, ct.Shape().TotalSize())
+		lstmCellBwdf64(materializedF64s(g), materializedF64s(ct.(*tf64.Tensor)), materializedF64s(gradF64), dg, dc)
+		dGates = FromTensor(tf64.NewTensor(tf64.WithBacking(dg), tf64.WithShape(g.Shape().Clone()...)))
+		dC = FromTensor(tf64.NewTensor(tf64.WithBacking(dc), tf64.WithShape(ct.Shape().Clone()...)))
+	case *tf32.Tensor:
+		gradF32, ok := gradT.(*tf32.Tensor)
+		if !ok {
+			return nil, nil, errors.Errorf(dtypeMismatch, Float32, gradT.Dtype())
+		}
+
+		dg := make([]float32, g.Shape().TotalSize())
+		dc := make([]float32, ct.Shape().TotalSize())
+		lstmCellBwdf32(materializedF32s(g), materializedF32s(ct.(*tf32.Tensor)), materializedF32s(gradF32), dg, dc)
+		dGates = FromTensor(tf32.NewTensor(tf32.WithBacking(dg), tf32.WithShape(g.Shape().Clone()...)))
+		dC = FromTensor(tf32.NewTensor(tf32.WithBacking(dc), tf32.WithShape(ct.Shape().Clone()...)))
+	default:
+		return nil, nil, errors.Errorf(nyiFail, "lstmCellDiffOp.Do()", gt)
+	}
+	return
+}
+
+// lstmCellOperands checks that the gates and the cell state are tensors of the same Dtype, of the correct sizes
+func lstmCellOperands(gates, c Value) (gt, ct types.Tensor, err error) {
+	var g, cell Tensor
+	var ok bool
+	if g, ok = gates.(Tensor); !ok {
+		return nil, nil, errors.Errorf("Expected the gates of a LSTM cell to be a Tensor. Got %T instead", gates)
+	}
+	if cell, ok = c.(Tensor); !ok {
+		return nil, nil, errors.Errorf("Expected the cell state of a LSTM cell to be a Tensor. Got %T instead", c)
+	}
+
+	if g.Dtype() != cell.Dtype() {
+		return nil, nil, errors.Errorf(dtypeMismatch, g.Dtype(), cell.Dtype())
+	}
+
+	if g.Shape().TotalSize() != 4*cell.Shape().TotalSize() {
+		return nil, nil, errors.Errorf("Expected gates of shape %v to be 4 times the size of the cell state of shape %v", g.Shape(), cell.Shape())
+	}
+	return g.Tensor, cell.Tensor, nil
+}
+
+func materializedF64s(t *tf64.Tensor) []float64 {
+	if t.IsMaterializable() {
+		return t.Materialize().(*tf64.Tensor).Data().([]float64)
+	}
+	return t.Data().([]float64)
+}
+
+func materializedF32s(t *tf32.Tensor) []float32 {
+	if t.IsMaterializable() {
+		return t.Materialize().(*tf32.Tensor).Data().([]float32)
+	}
+	return t.Data().([]float32)
+}
+
+// lstmCellFwdf64 computes
+//		c' = σ(f) * c + σ(i) * tanh(u)
+//		h' = σ(o) * tanh(c')
+//
+// and writes [h'; c'] into out.
+func lstmCellFwdf64(gates, c, out []float64) {
+	hidden := len(c)
+	for j := 0; j < hidden; j++ {
+		i := _sigmoidf64(gates[j])
+		f := _sigmoidf64(gates[hidden+j])
+		o := _sigmoidf64(gates[2*hidden+j])
+		u := math.Tanh(gates[3*hidden+j])
+
+		cNew := f*c[j] + i*u
+		out[j] = o * math.Tanh(cNew)
+		out[hidden+j] = cNew
+	}
+}
+
+// lstmCellBwdf64 computes the gradients wrt the gates and the previous cell state, given the gradient [∂h'; ∂c'].
+func lstmCellBwdf64(gates, c, grad, dGates, dC []float64) {
+	hidden := len(c)
+	for j := 0; j < hidden; j++ {
+		i := _sigmoidf64(gates[j])
+		f := _sigmoidf64(gates[hidden+j])
+		o := _sigmoidf64(gates[2*hidden+j])
+		u := math.Tanh(gates[3*hidden+j])
+
+		tc := math.Tanh(f*c[j] + i*u)
+		dh := grad[j]
+		dc := grad[hidden+j] + dh*o*(1-tc*tc)
+
+		dGates[j] = dc * u * i * (1 - i)
+		dGates[hidden+j] = dc * c[j] * f * (1 - f)
+		dGates[2*hidden+j] = dh * tc * o * (1 - o)
+		dGates[3*hidden+j] = dc * i * (1 - u*u)
+		dC[j] = dc * f
+	}
+}
+
+func lstmCellFwdf32(gates, c, out []float32) {
+	hidden := len(c)
+	for j := 0; j < hidden; j++ {
+		i := _sigmoidf32(gates[j])
+		f := _sigmoidf32(gates[hidden+j])
+		o := _sigmoidf32(gates[2*hidden+j])
+		u := math32.Tanh(gates[3*hidden+j])
+
+		cNew := f*c[j] + i*u
+		out[j] = o * math32.Tanh(cNew)
+		out[hidden+j] = cNew
+	}
+}
+
+func lstmCellBwdf32(gates, c, grad, dGates, dC []float32) {
+	hidden := len(c)
+	for j := 0; j < hidden; j++ {
+		i := _sigmoidf32(gates[j])
+		f := _sigmoidf32(gates[hidden+j])
+		o := _sigmoidf32(gates[2*hidden+j])
+		u := math32.Tanh(gates[3*hidden+j])
+
+		tc := math32.Tanh(f*c[j] + i*u)
+		dh := grad[j]
+		dc := grad[hidden+j] + dh*o*(1-tc*tc)
+
+		dGates[j] = dc * u * i * (1 - i)
+		dGates[hidden+j] = dc * c[j] * f * (1 - f)
+		dGates[2*hidden+j] = dh * tc * o * (1 - o)
+		dGates[3*hidden+j] = dc * i * (1 - u*u)
+		dC[j] = dc * f
+	}
+}
+
+// positionalEncodingOp is a constant op that holds the fixed sinusoidal position encodings used in transformers:
+//		PE(pos, 2i)   = sin(pos / 10000^(2i/dModel))
+//		PE(pos, 2i+1) = cos(pos / 10000^(2i/dModel))
+type positionalEncodingOp struct {
+	seqLen, dModel int
+	dt             Dtype
+
+	v Tensor
+}
+
+func newPositionalEncodingOp(seqLen, dModel int, dt Dtype) (retVal positionalEncodingOp, err error) {
+	if seqLen <= 0 || dModel <= 0 {
+		err = errors.Errorf("Expected positive sequence length and model dimensions. Got %d and %d instead", seqLen, dModel)
+		return
+	}
+
+	retVal = positionalEncodingOp{
+		seqLen: seqLen,
+		dModel: dModel,
+		dt:     dt,
+	}
+
+	switch dt {
+	case Float64:
+		backing := make([]float64, seqLen*dModel)
+		for pos := 0; pos < seqLen; pos++ {
+			for i := 0; i < dModel; i++ {
+				angle := float64(pos) / math.Pow(10000, float64(i-i%2)/float64(dModel))
+				if i%2 == 0 {
+					backing[pos*dModel+i] = math.Sin(angle)
+				} else {
+					backing[pos*dModel+i] = math.Cos(angle)
+				}
+			}
+		}
+		retVal.v = FromTensor(tf64.NewTensor(tf64.WithBacking(backing), tf64.WithShape(seqLen, dModel)))
+	case Float32:
+		backing := make([]float32, seqLen*dModel)
+		for pos := 0; pos < seqLen; pos++ {
+			for i := 0; i < dModel; i++ {
+				angle := float64(pos) / math.Pow(10000, float64(i-i%2)/float64(dModel))
+				if i%2 == 0 {
+					backing[pos*dModel+i] = float32(math.Sin(angle))
+				} else {
+					backing[pos*dModel+i] = float32(math.Cos(angle))
+				}
+			}
+		}
+		retVal.v = FromTensor(tf32.NewTensor(tf32.WithBacking(backing), tf32.WithShape(seqLen, dModel)))
+	default:
+		err = errors.Errorf(nyiFail, "positionalEncodingOp", dt)
+	}
+	return
+}
+
+// positionalEncodingOp has this type:
+//		op :: Matrix a
+func (op positionalEncodingOp) Type() Type { return newTensorType(2, op.dt) }
+
+// like all constants, positional encodings are pointers to the same underlying value
+func (op positionalEncodingOp) returnsPtr() bool                           { return true }
+func (op positionalEncodingOp) callsExtern() bool                          { return false }
+func (op positionalEncodingOp) overwriteInput() int                        { return -1 }
+func (op positionalEncodingOp) DiffWRT(i int) []bool                       { return nil }
+func (op positionalEncodingOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nil }
+
+func (op positionalEncodingOp) inferShape(Type, ...*Node) (types.Shape, error) {
+	return types.Shape{op.seqLen, op.dModel}, nil
+}
+
+func (op positionalEncodingOp) Do(...Value) (Value, error) { return op.v, nil }
+func (op positionalEncodingOp) String() string {
+	return fmt.Sprintf("PositionalEncoding(%d, %d)", op.seqLen, op.dModel)
+}
+
+func (op positionalEncodingOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("positionalEncoding"))
+	if err := binary.Write(h, binary.LittleEndian, op.dt); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, int64(op.seqLen)); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, int64(op.dModel)); err != nil {
+		panic(err)
+	}
+}
+
+func (op positionalEncodingOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// MarshalBinary writes out the sizes and the Dtype only. The encodings are computed again when the op is read back in.
+func (op positionalEncodingOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.seqLen)
+	w.writeInt(op.dModel)
+	w.writeByte(byte(op.dt))
+	return w.bytes()
+}
+
+func (op *positionalEncodingOp) UnmarshalBinary(data []byte) (err error) {
+	r := newParamReader(data)
+	seqLen := r.readInt()
+	dModel := r.readInt()
+	dt := r.readDtype()
+	if err = r.done(); err != nil {
+		return
+	}
+	*op, err = newPositionalEncodingOp(seqLen, dModel, dt)
+	return
+}
+
+func (op positionalEncodingOp) isconstant() bool { return true }
+func (op positionalEncodingOp) Value() Value     { return op.v }
+
+// ropeOp applies the rotary positional embeddings (RoPE) to a (batch, heads, seq, dim) tensor.
+// Each pair of features (x[2i], x[2i+1]) at position pos is rotated by the angle
+//		θ = (pos + offset) / 10000^(2i/dim)
+// Because the rotation is orthogonal, the gradient is simply the inverse rotation of the gradient.
+type ropeOp struct {
+	offset  int
+	inverse bool // rotate by -θ instead
+}
+
+// ropeOp has this type:
+//		op :: Tensor-4 a → Tensor-4 a
+func (op ropeOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	tt := newTensorType(4, a)
+	return newFunctionType(tt, tt)
+}
+
+func (op ropeOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "ropeOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	x := inputs[0]
+	if len(x.shape) != 4 || x.shape[3]%2 != 0 {
+		return nil, errors.Errorf("Expected a (batch, heads, seq, dim) tensor with an even dim. Got %v instead", x.shape)
+	}
+	return x.shape.Clone(), nil
+}
+
+func (op ropeOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+func (op ropeOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "ropeOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	inv := ropeOp{offset: op.offset, inverse: !op.inverse}
+	var dx *Node
+	if dx, err = applyOp(inv, gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op ropeOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "ropeOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	inv := ropeOp{offset: op.offset, inverse: !op.inverse}
+	var d Value
+	if d, err = inv.Do(ydv.d); err != nil {
+		return errors.Wrapf(err, doFail, inv)
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], output)
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op ropeOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "ropeOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	t, ok := inputs[0].(Tensor)
+	if !ok {
+		return nil, errors.Errorf("Expected a Tensor. Got %v of %T instead", inputs[0], inputs[0])
+	}
+
+	shp := t.Shape()
+	if len(shp) != 4 || shp[3]%2 != 0 {
+		return nil, errors.Errorf("Expected a (batch, heads, seq, dim) tensor with an even dim. Got %v instead", shp)
+	}
+	outer, seq, dim := shp[0]*shp[1], shp[2], shp[3]
+
+	switch tt := t.Tensor.(type) {
+	case *tf64.Tensor:
+		out := make([]float64, shp.TotalSize())
+		ropef64(materializedF64s(tt), out, outer, seq, dim, op.offset, op.inverse)
+		retVal = FromTensor(tf64.NewTensor(tf64.WithBacking(out), tf64.WithShape(shp.Clone()...)))
+	case *tf32.Tensor:
+		out := make([]float32, shp.TotalSize())
+		ropef32(materializedF32s(tt), out, outer, seq, dim, op.offset, op.inverse)
+		retVal = FromTensor(tf32.NewTensor(tf32.WithBacking(out), tf32.WithShape(shp.Clone()...)))
+	default:
+		return nil, errors.Errorf(nyiFail, "ropeOp.Do()", t.Tensor)
+	}
+	return
+}
+
+func (op ropeOp) returnsPtr() bool    { return false }
+func (op ropeOp) callsExtern() bool   { return false }
+func (op ropeOp) overwriteInput() int { return -1 }
+func (op ropeOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("rope"))
+	if err := binary.Write(h, binary.LittleEndian, int64(op.offset)); err != nil {
+		panic(err)
+	}
+	if op.inverse {
+		h.Write([]byte{1})
+	} else {
+		h.Write([]byte{0})
+	}
+}
+
+func (op ropeOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op ropeOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.offset)
+	w.writeBool(op.inverse)
+	return w.bytes()
+}
+
+func (op *ropeOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.offset = r.readInt()
+	op.inverse = r.readBool()
+	r.check(op.offset >= 0, "Expected a non negative sequence offset. Got %d instead", op.offset)
+	return r.done()
+}
+
+func (op ropeOp) String() string {
+	if op.inverse {
+		return fmt.Sprintf("RoPE⁻¹(%d)", op.offset)
+	}
+	return fmt.Sprintf("RoPE(%d)", op.offset)
+}
+
+// ropeAngle is the angle that the ith pair of features at position pos is rotated by.
+func ropeAngle(pos, i, dim int) float64 {
+	return float64(pos) / math.Pow(10000, float64(2*i)/float64(dim))
+}
+
+func ropef64(x, out []float64, outer, seq, dim, offset int, inverse bool) {
+	for o := 0; o < outer; o++ {
+		for s := 0; s < seq; s++ {
+			row := (o*seq + s) * dim
+			for i := 0; i < dim/2; i++ {
+				sin, cos := math.Sincos(ropeAngle(s+offset, i, dim))
+				if inverse {
+					sin = -sin
+				}
+				x0, x1 := x[row+2*i], x[row+2*i+1]
+				out[row+2*i] = x0*cos - x1*sin
+				out[row+2*i+1] = x0*sin + x1*cos
+			}
+		}
+	}
+}
+
+func ropef32(x, out []float32, outer, seq, dim, offset int, inverse bool) {
+	for o := 0; o < outer; o++ {
+		for s := 0; s < seq; s++ {
+			row := (o*seq + s) * dim
+			for i := 0; i < dim/2; i++ {
+				sin64, cos64 := math.Sincos(ropeAngle(s+offset, i, dim))
+				sin, cos := float32(sin64), float32(cos64)
+				if inverse {
+					sin = -sin
+				}
+				x0, x1 := x[row+2*i], x[row+2*i+1]
+				out[row+2*i] = x0*cos - x1*sin
+				out[row+2*i+1] = x0*sin + x1*cos
+			}
+		}
+	}
+}
+
+// moeGateOp computes the gating weights of a sparse mixture of experts layer. Given a (tokens, experts) matrix of router logits,
+// the top k experts of each token are selected, and their weights are the softmax over the selected k logits.
+// The weights of the experts that are not selected are 0. Ties are broken in favour of the lower index.
+type moeGateOp struct {
+	k int
+}
+
+// moeGateOp has this type:
+//		op :: Matrix a → Matrix a
+func (op moeGateOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	tt := newTensorType(2, a)
+	return newFunctionType(tt, tt)
+}
+
+func (op moeGateOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "moeGateOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	logits := inputs[0]
+	if len(logits.shape) != 2 || op.k <= 0 || op.k > logits.shape[1] {
+		return nil, errors.Errorf("Cannot select the top %d experts of logits shaped %v", op.k, logits.shape)
+	}
+	return logits.shape.Clone(), nil
+}
+
+func (op moeGateOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+func (op moeGateOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "moeGateOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dLogits *Node
+	if dLogits, err = applyOp(moeGateDiffOp{}, output, gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dLogits.setGroup(gradClust)
+	return Nodes{dLogits}, nil
+}
+
+func (op moeGateOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "moeGateOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	var d Value
+	if d, err = (moeGateDiffOp{}).Do(ydv.Value, ydv.d); err != nil {
+		return errors.Wrap(err, "moeGateOp.DoDiff()")
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op moeGateOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "moeGateOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	t, ok := inputs[0].(Tensor)
+	if !ok || t.Dims() != 2 {
+		return nil, errors.Errorf("Expected a matrix of logits. Got %v instead", inputs[0])
+	}
+
+	shp := t.Shape()
+	experts := shp[1]
+	if op.k <= 0 || op.k > experts {
+		return nil, errors.Errorf("Cannot select the top %d of %d experts", op.k, experts)
+	}
+
+	idx := make([]int, op.k)
+	switch tt := t.Tensor.(type) {
+	case *tf64.Tensor:
+		logits := materializedF64s(tt)
+		out := make([]float64, len(logits))
+		for r := 0; r < shp[0]; r++ {
+			row := logits[r*experts : (r+1)*experts]
+			topKf64(row, idx)
+			moeGatef64(row, idx, out[r*experts:(r+1)*experts])
+		}
+		retVal = FromTensor(tf64.NewTensor(tf64.WithBacking(out), tf64.WithShape(shp.Clone()...)))
+	case *tf32.Tensor:
+		logits := materializedF32s(tt)
+		out := make([]float32, len(logits))
+		for r := 0; r < shp[0]; r++ {
+			row := logits[r*experts : (r+1)*experts]
+			topKf32(row, idx)
+			moeGatef32(row, idx, out[r*experts:(r+1)*experts])
+		}
+		retVal = FromTensor(tf32.NewTensor(tf32.WithBacking(out), tf32.WithShape(shp.Clone()...)))
+	default:
+		return nil, errors.Errorf(nyiFail, "moeGateOp.Do()", t.Tensor)
+	}
+	return
+}
+
+func (op moeGateOp) returnsPtr() bool    { return false }
+func (op moeGateOp) callsExtern() bool   { return false }
+func (op moeGateOp) overwriteInput() int { return -1 }
+func (op moeGateOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("moeGate"))
+	if err := binary.Write(h, binary.LittleEndian, int64(op.k)); err != nil {
+		panic(err)
+	}
+}
+
+func (op moeGateOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op moeGateOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.k)
+	return w.bytes()
+}
+
+func (op *moeGateOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.k = r.readInt()
+	r.check(op.k > 0, "Expected a positive k. Got %d instead", op.k)
+	return r.done()
+}
+
+func (op moeGateOp) String() string { return fmt.Sprintf("MoEGate(%d)", op.k) }
+
+// moeGateDiffOp is the derivative of moeGateOp. It takes the gating weights and the gradient of the weights.
+// Because the weights of the experts that were not selected are 0, the gradient is only routed to the selected experts:
+//		∂logitsⱼ = wⱼ(gradⱼ - Σᵢ wᵢgradᵢ)
+type moeGateDiffOp struct{}
+
+// moeGateDiffOp has this type:
+//		op :: Matrix a → Matrix a → Matrix a
+func (op moeGateDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	tt := newTensorType(2, a)
+	return newFunctionType(tt, tt, tt)
+}
+
+func (op moeGateDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "moeGateDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op moeGateDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op moeGateDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op moeGateDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "moeGateDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	wt, ok := inputs[0].(Tensor)
+	if !ok || wt.Dims() != 2 {
+		return nil, errors.Errorf("Expected a matrix of weights. Got %v instead", inputs[0])
+	}
+	gt, ok := inputs[1].(Tensor)
+	if !ok || !gt.Shape().Eq(wt.Shape()) {
+		return nil, errors.Errorf("Expected the gradient to be a matrix shaped %v. Got %v instead", wt.Shape(), inputs[1])
+	}
+	if wt.Dtype() != gt.Dtype() {
+		return nil, errors.Errorf(dtypeMismatch, wt.Dtype(), gt.Dtype())
+	}
+
+	shp := wt.Shape()
+	experts := shp[1]
+	switch tt := wt.Tensor.(type) {
+	case *tf64.Tensor:
+		w := materializedF64s(tt)
+		g := materializedF64s(gt.Tensor.(*tf64.Tensor))
+		out := make([]float64, len(w))
+		for r := 0; r < shp[0]; r++ {
+			start, end := r*experts, (r+1)*experts
+			var dot float64
+			for j := start; j < end; j++ {
+				dot += w[j] * g[j]
+			}
+			for j := start; j < end; j++ {
+				out[j] = w[j] * (g[j] - dot)
+			}
+		}
+		retVal = FromTensor(tf64.NewTensor(tf64.WithBacking(out), tf64.WithShape(shp.Clone()...)))
+	case *tf32.Tensor:
+		w := materializedF32s(tt)
+		g := materializedF32s(gt.Tensor.(*tf32.Tensor))
+		out := make([]float32, len(w))
+		for r := 0; r < shp[0]; r++ {
+			start, end := r*experts, (r+1)*experts
+			var dot float32
+			for j := start; j < end; j++ {
+				dot += w[j] * g[j]
+			}
+			for j := start; j < end; j++ {
+				out[j] = w[j] * (g[j] - dot)
+			}
+		}
+		retVal = FromTensor(tf32.NewTensor(tf32.WithBacking(out), tf32.WithShape(shp.Clone()...)))
+	default:
+		return nil, errors.Errorf(nyiFail, "moeGateDiffOp.Do()", wt.Tensor)
+	}
+	return
+}
+
+func (op moeGateDiffOp) returnsPtr() bool      { return false }
+func (op moeGateDiffOp) callsExtern() bool     { return false }
+func (op moeGateDiffOp) overwriteInput() int   { return -1 }
+func (op moeGateDiffOp) WriteHash(h hash.Hash) { h.Write([]byte("moeGateDiff")) }
+
+func (op moeGateDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op moeGateDiffOp) String() string { return "∂MoEGate" }
+
+// topKIndicesOp returns the indices of the k largest values of each row of a matrix, in descending order of the values.
+// Ties are broken in favour of the lower index. It is not differentiable.
+type topKIndicesOp struct {
+	k int
+}
+
+// topKIndicesOp has this type:
+//		op :: Matrix a → Matrix Int
+func (op topKIndicesOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(newTensorType(2, a), newTensorType(2, Int))
+}
+
+func (op topKIndicesOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "topKIndicesOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	x := inputs[0]
+	if len(x.shape) != 2 || op.k <= 0 || op.k > x.shape[1] {
+		return nil, errors.Errorf("Cannot select the top %d of a matrix shaped %v", op.k, x.shape)
+	}
+	return types.Shape{x.shape[0], op.k}, nil
+}
+
+func (op topKIndicesOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op topKIndicesOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op topKIndicesOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "topKIndicesOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	t, ok := inputs[0].(Tensor)
+	if !ok || t.Dims() != 2 {
+		return nil, errors.Errorf("Expected a matrix. Got %v instead", inputs[0])
+	}
+
+	shp := t.Shape()
+	rows, cols := shp[0], shp[1]
+	if op.k <= 0 || op.k > cols {
+		return nil, errors.Errorf("Cannot select the top %d of %d", op.k, cols)
+	}
+
+	out := make([]int, rows*op.k)
+	switch tt := t.Tensor.(type) {
+	case *tf64.Tensor:
+		data := materializedF64s(tt)
+		for r := 0; r < rows; r++ {
+			topKf64(data[r*cols:(r+1)*cols], out[r*op.k:(r+1)*op.k])
+		}
+	case *tf32.Tensor:
+		data := materializedF32s(tt)
+		for r := 0; r < rows; r++ {
+			topKf32(data[r*cols:(r+1)*cols], out[r*op.k:(r+1)*op.k])
+		}
+	default:
+		return nil, errors.Errorf(nyiFail, "topKIndicesOp.Do()", t.Tensor)
+	}
+	retVal = FromTensor(ti.NewTensor(ti.WithBacking(out), ti.WithShape(rows, op.k)))
+	return
+}
+
+func (op topKIndicesOp) returnsPtr() bool    { return false }
+func (op topKIndicesOp) callsExtern() bool   { return false }
+func (op topKIndicesOp) overwriteInput() int { return -1 }
+func (op topKIndicesOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("topKIndices"))
+	if err := binary.Write(h, binary.LittleEndian, int64(op.k)); err != nil {
+		panic(err)
+	}
+}
+
+func (op topKIndicesOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op topKIndicesOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.k)
+	return w.bytes()
+}
+
+func (op *topKIndicesOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.k = r.readInt()
+	r.check(op.k > 0, "Expected a positive k. Got %d instead", op.k)
+	return r.done()
+}
+
+func (op topKIndicesOp) String() string { return fmt.Sprintf("TopKIndices(%d)", op.k) }
+
+// topKf64 fills idx with the indices of the len(idx) largest values of a, in descending order. Ties go to the lower index.
+func topKf64(a []float64, idx []int) {
+	for i := range idx {
+		best := -1
+		for j, v := range a {
+			if intsContain(idx[:i], j) {
+				continue
+			}
+			if best < 0 || v > a[best] {
+				best = j
+			}
+		}
+		idx[i] = best
+	}
+}
+
+func topKf32(a []float32, idx []int) {
+	for i := range idx {
+		best := -1
+		for j, v := range a {
+			if intsContain(idx[:i], j) {
+				continue
+			}
+			if best < 0 || v > a[best] {
+				best = j
+			}
+		}
+		idx[i] = best
+	}
+}
+
+// moeGatef64 writes the softmax over the selected logits into out, and zeroes the rest.
+func moeGatef64(logits []float64, selected []int, out []float64) {
+	for i := range out {
+		out[i] = 0
+	}
+
+	max := logits[selected[0]] // selected is in descending order
+	var sum float64
+	for _, j := range selected {
+		out[j] = math.Exp(logits[j] - max)
+		sum += out[j]
+	}
+	for _, j := range selected {
+		out[j] /= sum
+	}
+}
+
+func moeGatef32(logits []float32, selected []int, out []float32) {
+	for i := range out {
+		out[i] = 0
+	}
+
+	max := logits[selected[0]]
+	var sum float32
+	for _, j := range selected {
+		out[j] = math32.Exp(logits[j] - max)
+		sum += out[j]
+	}
+	for _, j := range selected {
+		out[j] /= sum
+	}
+}
+
+// loadBalanceLossOp computes the auxiliary load balancing loss of a mixture of experts layer:
+//		loss = numExperts * Σᵢ fᵢPᵢ
+// where fᵢ is the fraction of the assignments routed to expert i, and Pᵢ is the mean router probability of expert i.
+// The loss is scaled by the number of experts so that perfectly balanced routing has a loss of 1.
+// The assignments are not differentiable.
+type loadBalanceLossOp struct {
+	numExperts int
+	assignDims int // dims of the assignments
+}
+
+// loadBalanceLossOp has this type:
+//		op :: Matrix a → Tensor-d Int → a
+func (op loadBalanceLossOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(newTensorType(2, a), newTensorType(op.assignDims, Int), a)
+}
+
+func (op loadBalanceLossOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "loadBalanceLossOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	probs := inputs[0]
+	if len(probs.shape) != 2 || probs.shape[1] != op.numExperts {
+		return nil, errors.Errorf("Expected router probabilities shaped (tokens, %d). Got %v instead", op.numExperts, probs.shape)
+	}
+	return scalarShape, nil
+}
+
+func (op loadBalanceLossOp) DiffWRT(inputs int) []bool { return []bool{true, false} }
+
+func (op loadBalanceLossOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "loadBalanceLossOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var dProbs *Node
+	if dProbs, err = applyOp(loadBalanceLossDiffOp(op), inputs[0], inputs[1], gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dProbs.setGroup(gradClust)
+	return Nodes{dProbs, nil}, nil
+}
+
+func (op loadBalanceLossOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "loadBalanceLossOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	pdv := inputs[0].boundTo.(*dualValue)
+	odv := output.boundTo.(*dualValue)
+
+	var d Value
+	if d, err = loadBalanceLossDiffOp(op).Do(pdv.Value, inputs[1].Value(), odv.d); err != nil {
+		return errors.Wrap(err, "loadBalanceLossOp.DoDiff()")
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(pdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op loadBalanceLossOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "loadBalanceLossOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var probs Tensor
+	var frac []float64
+	if probs, frac, err = op.operands(inputs[0], inputs[1]); err != nil {
+		return
+	}
+
+	tokens := probs.Shape()[0]
+	switch pt := probs.Tensor.(type) {
+	case *tf64.Tensor:
+		p := materializedF64s(pt)
+		var loss float64
+		for t := 0; t < tokens; t++ {
+			for i := 0; i < op.numExperts; i++ {
+				loss += frac[i] * p[t*op.numExperts+i]
+			}
+		}
+		return anyToValue(loss * float64(op.numExperts) / float64(tokens))
+	case *tf32.Tensor:
+		p := materializedF32s(pt)
+		var loss float64
+		for t := 0; t < tokens; t++ {
+			for i := 0; i < op.numExperts; i++ {
+				loss += frac[i] * float64(p[t*op.numExperts+i])
+			}
+		}
+		return anyToValue(float32(loss * float64(op.numExperts) / float64(tokens)))
+	default:
+		return nil, errors.Errorf(nyiFail, "loadBalanceLossOp.Do()", probs.Tensor)
+	}
+}
+
+// operands checks the inputs, and calculates the fraction of assignments routed to each expert.
+func (op loadBalanceLossOp) operands(probsV, assignV Value) (probs Tensor, frac []float64, err error) {
+	var ok bool
+	if probs, ok = probsV.(Tensor); !ok || probs.Dims() != 2 || probs.Shape()[1] != op.numExperts {
+		return probs, nil, errors.Errorf("Expected router probabilities shaped (tokens, %d). Got %v instead", op.numExperts, probsV)
+	}
+
+	var assignments []int
+	switch a := assignV.(type) {
+	case Tensor:
+		at, ok := a.Tensor.(*ti.Tensor)
+		if !ok {
+			return probs, nil, errors.Errorf(dtypeMismatch, Int, a.Dtype())
+		}
+		if at.IsMaterializable() {
+			at = at.Materialize().(*ti.Tensor)
+		}
+		assignments = at.Data().([]int)
+	default:
+		return probs, nil, errors.Errorf("Expected a tensor of assignments. Got %v of %T instead", assignV, assignV)
+	}
+
+	if len(assignments) == 0 {
+		return probs, nil, errors.New("Expected at least one assignment")
+	}
+
+	frac = make([]float64, op.numExperts)
+	for _, e := range assignments {
+		if e < 0 || e >= op.numExperts {
+			return probs, nil, errors.Errorf("Assignment to expert %d is out of range. There are %d experts", e, op.numExperts)
+		}
+		frac[e]++
+	}
+	for i := range frac {
+		frac[i] /= float64(len(assignments))
+	}
+	return
+}
+
+func (op loadBalanceLossOp) returnsPtr() bool    { return false }
+func (op loadBalanceLossOp) callsExtern() bool   { return false }
+func (op loadBalanceLossOp) overwriteInput() int { return -1 }
+func (op loadBalanceLossOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("loadBalanceLoss"))
+	if err := binary.Write(h, binary.LittleEndian, int64(op.numExperts)); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, byte(op.assignDims)); err != nil {
+		panic(err)
+	}
+}
+
+func (op loadBalanceLossOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op loadBalanceLossOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.numExperts)
+	w.writeInt(op.assignDims)
+	return w.bytes()
+}
+
+func (op *loadBalanceLossOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.numExperts = r.readInt()
+	op.assignDims = r.readInt()
+	r.check(op.numExperts > 0, "Expected a positive number of experts. Got %d instead", op.numExperts)
+	r.checkAxes(op.assignDims)
+	return r.done()
+}
+
+func (op loadBalanceLossOp) String() string { return fmt.Sprintf("LoadBalanceLoss(%d)", op.numExperts) }
+
+// loadBalanceLossDiffOp is the derivative of loadBalanceLossOp wrt the router probabilities. It takes the probabilities, the assignments and the (scalar) gradient.
+//		∂probs[t, i] = grad * numExperts * fᵢ / tokens
+type loadBalanceLossDiffOp struct {
+	numExperts int
+	assignDims int
+}
+
+// loadBalanceLossDiffOp has this type:
+//		op :: Matrix a → Tensor-d Int → a → Matrix a
+func (op loadBalanceLossDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	tt := newTensorType(2, a)
+	return newFunctionType(tt, newTensorType(op.assignDims, Int), a, tt)
+}
+
+func (op loadBalanceLossDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "loadBalanceLossDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op loadBalanceLossDiffOp) DiffWRT(inputs int) []bool { return make([]bool, inputs) }
+func (op loadBalanceLossDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) {
+	return nil, nondiffErr(op)
+}
+
+func (op loadBalanceLossDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "loadBalanceLossDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var probs Tensor
+	var frac []float64
+	if probs, frac, err = loadBalanceLossOp(op).operands(inputs[0], inputs[1]); err != nil {
+		return
+	}
+
+	shp := probs.Shape()
+	scale := float64(op.numExperts) / float64(shp[0])
+	switch probs.Tensor.(type) {
+	case *tf64.Tensor:
+		grad, ok := inputs[2].Data().(float64)
+		if !ok {
+			return nil, errors.Errorf(dtypeMismatch, Float64, inputs[2].Dtype())
+		}
+		out := make([]float64, shp.TotalSize())
+		for j := range out {
+			out[j] = grad * scale * frac[j%op.numExperts]
+		}
+		retVal = FromTensor(tf64.NewTensor(tf64.WithBacking(out), tf64.WithShape(shp.Clone()...)))
+	case *tf32.Tensor:
+		grad, ok := inputs[2].Data().(float32)
+		if !ok {
+			return nil, errors.Errorf(dtypeMismatch, Float32, inputs[2].Dtype())
+		}
+		out := make([]float32, shp.TotalSize())
+		for j := range out {
+			out[j] = grad * float32(scale*frac[j%op.numExperts])
+		}
+		retVal = FromTensor(tf32.NewTensor(tf32.WithBacking(out), tf32.WithShape(shp.Clone()...)))
+	default:
+		return nil, errors.Errorf(nyiFail, "loadBalanceLossDiffOp.Do()", probs.Tensor)
+	}
+	return
+}
+
+func (op loadBalanceLossDiffOp) returnsPtr() bool    { return false }
+func (op loadBalanceLossDiffOp) callsExtern() bool   { return false }
+func (op loadBalanceLossDiffOp) overwriteInput() int { return -1 }
+func (op loadBalanceLossDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	loadBalanceLossOp(op).WriteHash(h)
+}
+
+func (op loadBalanceLossDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op loadBalanceLossDiffOp) MarshalBinary() ([]byte, error) {
+	return loadBalanceLossOp(op).MarshalBinary()
+}
+
+func (op *loadBalanceLossDiffOp) UnmarshalBinary(data []byte) error {
+	return (*loadBalanceLossOp)(op).UnmarshalBinary(data)
+}
+
+func (op loadBalanceLossDiffOp) String() string {
+	return fmt.Sprintf("∂LoadBalanceLoss(%d)", op.numExperts)
+}
+
+// arcFaceOp computes the additive angular margin loss (ArcFace). It takes the L2 normalized features (batch, dim),
+// the L2 normalized class weights (classes, dim) and the Int vector of target classes. The logits are the scaled cosines
+// between the features and the class weights, except that the angle to the target class has the margin added to it:
+//		zᵢⱼ = s * cos(θᵢⱼ + m) if j is the target of i, s * cos(θᵢⱼ) otherwise
+// The result is the mean softmax cross entropy of the logits.
+type arcFaceOp struct {
+	margin, scale float64
+}
+
+// arcFaceOp has this type:
+//		op :: Matrix a → Matrix a → Vector Int → a
+func (op arcFaceOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	tt := newTensorType(2, a)
+	return newFunctionType(tt, tt, newTensorType(1, Int), a)
+}
+
+func (op arcFaceOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "arcFaceOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	features, weights, targets := inputs[0], inputs[1], inputs[2]
+	if len(features.shape) != 2 || len(weights.shape) != 2 || features.shape[1] != weights.shape[1] {
+		return nil, errors.Errorf("Expected features shaped (batch, dim) and weights shaped (classes, dim). Got %v and %v instead", features.shape, weights.shape)
+	}
+	if targets.shape.TotalSize() != features.shape[0] {
+		return nil, errors.Errorf("Expected %d targets. Got %v instead", features.shape[0], targets.shape)
+	}
+	return scalarShape, nil
+}
+
+func (op arcFaceOp) DiffWRT(inputs int) []bool { return []bool{true, true, false} }
+
+func (op arcFaceOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "arcFaceOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	retVal = make(Nodes, 3)
+	for i := 0; i < 2; i++ {
+		diffOp := arcFaceDiffOp{margin: op.margin, scale: op.scale, wrt: i}
+		if retVal[i], err = applyOp(diffOp, inputs[0], inputs[1], inputs[2], gradNode); err != nil {
+			return nil, errors.Wrap(err, applyOpFail)
+		}
+		retVal[i].setGroup(gradClust)
+	}
+	return
+}
+
+func (op arcFaceOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "arcFaceOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	fdv := inputs[0].boundTo.(*dualValue)
+	wdv := inputs[1].boundTo.(*dualValue)
+	odv := output.boundTo.(*dualValue)
+
+	var dF, dW Value
+	if dF, dW, err = op.backward(fdv.Value, wdv.Value, inputs[2].Value(), odv.d); err != nil {
+		return errors.Wrap(err, "arcFaceOp.DoDiff()")
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(fdv.d, dF); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+
+	add = newElemBinOp(addOpType, inputs[1], inputs[1])
+	if _, err = add.UnsafeDo(wdv.d, dW); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op arcFaceOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "arcFaceOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var a arcFaceOperands
+	if a, err = newArcFaceOperands(inputs[0], inputs[1], inputs[2]); err != nil {
+		return
+	}
+
+	loss, _ := op.loss(a)
+	if a.dt == Float32 {
+		return anyToValue(float32(loss))
+	}
+	return anyToValue(loss)
+}
+
+// backward computes the gradients wrt the features and the weights
+func (op arcFaceOp) backward(features, weights, targets, grad Value) (dF, dW Value, err error) {
+	var a arcFaceOperands
+	if a, err = newArcFaceOperands(features, weights, targets); err != nil {
+		return
+	}
+
+	var g float64
+	switch gv := grad.Data().(type) {
+	case float64:
+		g = gv
+	case float32:
+		g = float64(gv)
+	default:
+		return nil, nil, errors.Errorf(nyiFail, "arcFaceOp.backward()", grad)
+	}
+
+	_, dCos := op.loss(a)
+	df := make([]float64, len(a.f))
+	dw := make([]float64, len(a.w))
+	for i := 0; i < a.batch; i++ {
+		for j := 0; j < a.classes; j++ {
+			d := g * dCos[i*a.classes+j]
+			if d == 0 {
+				continue
+			}
+			for k := 0; k < a.dim; k++ {
+				df[i*a.dim+k] += d * a.w[j*a.dim+k]
+				dw[j*a.dim+k] += d * a.f[i*a.dim+k]
+			}
+		}
+	}
+
+	dF = a.fromF64s(df, a.batch, a.dim)
+	dW = a.fromF64s(dw, a.classes, a.dim)
+	return
+}
+
+// loss computes the mean loss, and the derivative of the loss wrt the cosines
+func (op arcFaceOp) loss(a arcFaceOperands) (loss float64, dCos []float64) {
+	cosM, sinM := math.Cos(op.margin), math.Sin(op.margin)
+	z := make([]float64, a.classes)
+	dCos = make([]float64, a.batch*a.classes)
+	for i := 0; i < a.batch; i++ {
+		y := a.targets[i]
+		var dzdc float64 // ∂zᵢy/∂cᵢy
+		for j := 0; j < a.classes; j++ {
+			var c float64
+			for k := 0; k < a.dim; k++ {
+				c += a.f[i*a.dim+k] * a.w[j*a.dim+k]
+			}
+			c = math.Max(-1, math.Min(1, c))
+
+			if j != y {
+				z[j] = op.scale * c
+				continue
+			}
+
+			// cos(θ + m) = cos(θ)cos(m) - sin(θ)sin(m)
+			sin := math.Sqrt(math.Max(1-c*c, 1e-12))
+			z[j] = op.scale * (c*cosM - sin*sinM)
+			dzdc = op.scale * (cosM + sinM*c/sin)
+		}
+
+		max := z[0]
+		for _, v := range z[1:] {
+			max = math.Max(max, v)
+		}
+		var sum float64
+		for _, v := range z {
+			sum += math.Exp(v - max)
+		}
+		loss += max + math.Log(sum) - z[y]
+
+		for j, v := range z {
+			d := math.Exp(v-max) / sum
+			if j == y {
+				d = (d - 1) * dzdc
+			} else {
+				d *= op.scale
+			}
+			dCos[i*a.classes+j] = d / float64(a.batch)
+		}
+	}
+	return loss / float64(a.batch), dCos
+}
+
+func (op arcFaceOp) returnsPtr() bool    { return false }
+func (op arcFaceOp) callsExtern() bool   { return false }
+func (op arcFaceOp) overwriteInput() int { return -1 }
+func (op arcFaceOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("arcFace"))
+	if err := binary.Write(h, binary.LittleEndian, op.margin); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, op.scale); err != nil {
+		panic(err)
+	}
+}
+
+func (op arcFaceOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op arcFaceOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeFloat(op.margin)
+	w.writeFloat(op.scale)
+	return w.bytes()
+}
+
+func (op *arcFaceOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.margin = r.readFloat()
+	op.scale = r.readFloat()
+	r.check(op.scale > 0, "Expected a positive scale. Got %v instead", op.scale)
+	return r.done()
+}
+
+func (op arcFaceOp) String() string { return fmt.Sprintf("ArcFace(m=%v, s=%v)", op.margin, op.scale) }
+
+// arcFaceDiffOp is the derivative of arcFaceOp with regards to either the features (wrt = 0) or the weights (wrt = 1).
+// It takes the features, the weights, the targets and the gradient.
+type arcFaceDiffOp struct {
+	margin, scale float64
+	wrt           int
+}
+
+// arcFaceDiffOp has this type:
+//		op :: Matrix a → Matrix a → Vector Int → a → Matrix a
+func (op arcFaceDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	tt := newTensorType(2, a)
+	return newFunctionType(tt, tt, newTensorType(1, Int), a, tt)
+}
+
+func (op arcFaceDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 4 {
+		err = NewError(GraphError, "arcFaceDiffOp expects 4 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[op.wrt].shape.Clone(), nil
+}
+
+func (op arcFaceDiffOp) DiffWRT(inputs int) []bool { return make([]bool, inputs) }
+func (op arcFaceDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) {
+	return nil, nondiffErr(op)
+}
+
+func (op arcFaceDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 4 {
+		err = NewError(GraphError, "arcFaceDiffOp expects 4 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var dF, dW Value
+	fwd := arcFaceOp{margin: op.margin, scale: op.scale}
+	if dF, dW, err = fwd.backward(inputs[0], inputs[1], inputs[2], inputs[3]); err != nil {
+		return
+	}
+	if op.wrt == 0 {
+		return dF, nil
+	}
+	return dW, nil
+}
+
+func (op arcFaceDiffOp) returnsPtr() bool    { return false }
+func (op arcFaceDiffOp) callsExtern() bool   { return false }
+func (op arcFaceDiffOp) overwriteInput() int { return -1 }
+func (op arcFaceDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	arcFaceOp{margin: op.margin, scale: op.scale}.WriteHash(h)
+	if err := binary.Write(h, binary.LittleEndian, byte(op.wrt)); err != nil {
+		panic(err)
+	}
+}
+
+func (op arcFaceDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op arcFaceDiffOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeFloat(op.margin)
+	w.writeFloat(op.scale)
+	w.writeInt(op.wrt)
+	return w.bytes()
+}
+
+func (op *arcFaceDiffOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.margin = r.readFloat()
+	op.scale = r.readFloat()
+	op.wrt = r.readInt()
+	r.check(op.scale > 0, "Expected a positive scale. Got %v instead", op.scale)
+	r.checkWRT(op.wrt, 2)
+	return r.done()
+}
+
+func (op arcFaceDiffOp) String() string { return fmt.Sprintf("∂ArcFace/∂%d", op.wrt) }
+
+// arcFaceOperands holds the operands of arcFaceOp as float64s
+type arcFaceOperands struct {
+	f, w                []float64
+	targets             []int
+	batch, classes, dim int
+	dt                  Dtype
+}
+
+func newArcFaceOperands(features, weights, targets Value) (a arcFaceOperands, err error) {
+	ft, ok := features.(Tensor)
+	if !ok || ft.Dims() != 2 {
+		return a, errors.Errorf("Expected features to be a matrix. Got %v instead", features)
+	}
+	wt, ok := weights.(Tensor)
+	if !ok || wt.Dims() != 2 {
+		return a, errors.Errorf("Expected weights to be a matrix. Got %v instead", weights)
+	}
+	if ft.Dtype() != wt.Dtype() {
+		return a, errors.Errorf(dtypeMismatch, ft.Dtype(), wt.Dtype())
+	}
+
+	a.batch, a.dim = ft.Shape()[0], ft.Shape()[1]
+	a.classes = wt.Shape()[0]
+	if wt.Shape()[1] != a.dim {
+		return a, errors.Errorf("Expected weights shaped (classes, %d). Got %v instead", a.dim, wt.Shape())
+	}
+
+	a.dt = ft.Dtype()
+	switch a.dt {
+	case Float64:
+		a.f = materializedF64s(ft.Tensor.(*tf64.Tensor))
+		a.w = materializedF64s(wt.Tensor.(*tf64.Tensor))
+	case Float32:
+		a.f = f32sToF64s(materializedF32s(ft.Tensor.(*tf32.Tensor)))
+		a.w = f32sToF64s(materializedF32s(wt.Tensor.(*tf32.Tensor)))
+	default:
+		return a, errors.Errorf(nyiFail, "arcFaceOp", ft.Tensor)
+	}
+
+	tt, ok := targets.(Tensor)
+	if !ok {
+		return a, errors.Errorf("Expected a Tensor of targets. Got %v of %T instead", targets, targets)
+	}
+	it, ok := tt.Tensor.(*ti.Tensor)
+	if !ok {
+		return a, errors.Errorf(dtypeMismatch, Int, tt.Dtype())
+	}
+	if it.IsMaterializable() {
+		it = it.Materialize().(*ti.Tensor)
+	}
+	a.targets = it.Data().([]int)
+	if len(a.targets) != a.batch {
+		return a, errors.Errorf("Expected %d targets. Got %d instead", a.batch, len(a.targets))
+	}
+	for _, y := range a.targets {
+		if y < 0 || y >= a.classes {
+			return a, errors.Errorf("Target class %d is out of range. There are %d classes", y, a.classes)
+		}
+	}
+	return
+}
+
+// fromF64s creates a Value of the operands' Dtype
+func (a arcFaceOperands) fromF64s(data []float64, shape ...int) Value {
+	if a.dt == Float32 {
+		return FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(data)), tf32.WithShape(shape...)))
+	}
+	return FromTensor(tf64.NewTensor(tf64.WithBacking(data), tf64.WithShape(shape...)))
+}
+
+func f32sToF64s(a []float32) []float64 {
+	retVal := make([]float64, len(a))
+	for i, v := range a {
+		retVal[i] = float64(v)
+	}
+	return retVal
+}
+
+func f64sToF32s(a []float64) []float32 {
+	retVal := make([]float32, len(a))
+	for i, v := range a {
+		retVal[i] = float32(v)
+	}
+	return retVal
+}
+
+// centerLossOp computes the center loss, which pulls the features of each class towards the center of the class:
+//		loss = ½ Σᵢ ‖xᵢ - c(yᵢ)‖²
+// The (numClasses, dim) centers are stored on the op. They are not learnt by gradient descent, but updated with the rate alpha
+// whenever the gradient is computed (that is to say, when training):
+//		c(j) -= α * Σ{i: yᵢ = j} (c(j) - xᵢ) / (1 + n(j))
+// Because of the hidden state, the op is stateful - two center losses are never merged.
+type centerLossOp struct {
+	numClasses int
+	alpha      float64
+	*centerLossState
+}
+
+type centerLossState struct {
+	centers []float64 // row major (numClasses, dim)
+	dim     int
+}
+
+// centerLossOp has this type:
+//		op :: Matrix a → Vector Int → a
+func (op centerLossOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(newTensorType(2, a), newTensorType(1, Int), a)
+}
+
+func (op centerLossOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "centerLossOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	features, labels := inputs[0], inputs[1]
+	if len(features.shape) != 2 || features.shape[1] != op.dim {
+		return nil, errors.Errorf("Expected features shaped (batch, %d). Got %v instead", op.dim, features.shape)
+	}
+	if labels.shape.TotalSize() != features.shape[0] {
+		return nil, errors.Errorf("Expected %d labels. Got %v instead", features.shape[0], labels.shape)
+	}
+	return scalarShape, nil
+}
+
+func (op centerLossOp) DiffWRT(inputs int) []bool { return []bool{true, false} }
+
+func (op centerLossOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "centerLossOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(centerLossDiffOp{op}, inputs[0], inputs[1], gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx, nil}, nil
+}
+
+func (op centerLossOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "centerLossOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	odv := output.boundTo.(*dualValue)
+
+	var d Value
+	if d, err = (centerLossDiffOp{op}).Do(xdv.Value, inputs[1].Value(), odv.d); err != nil {
+		return errors.Wrap(err, "centerLossOp.DoDiff()")
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op centerLossOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "centerLossOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var x []float64
+	var labels []int
+	var dt Dtype
+	if x, labels, dt, err = op.operands(inputs[0], inputs[1]); err != nil {
+		return
+	}
+
+	var loss float64
+	for i, y := range labels {
+		for k := 0; k < op.dim; k++ {
+			diff := x[i*op.dim+k] - op.centers[y*op.dim+k]
+			loss += diff * diff
+		}
+	}
+	loss /= 2
+
+	if dt == Float32 {
+		return anyToValue(float32(loss))
+	}
+	return anyToValue(loss)
+}
+
+// operands checks the inputs and returns the features as float64s
+func (op centerLossOp) operands(features, labelsV Value) (x []float64, labels []int, dt Dtype, err error) {
+	ft, ok := features.(Tensor)
+	if !ok || ft.Dims() != 2 || ft.Shape()[1] != op.dim {
+		return nil, nil, dt, errors.Errorf("Expected features shaped (batch, %d). Got %v instead", op.dim, features)
+	}
+
+	dt = ft.Dtype()
+	switch t := ft.Tensor.(type) {
+	case *tf64.Tensor:
+		x = materializedF64s(t)
+	case *tf32.Tensor:
+		x = f32sToF64s(materializedF32s(t))
+	default:
+		return nil, nil, dt, errors.Errorf(nyiFail, "centerLossOp", ft.Tensor)
+	}
+
+	lt, ok := labelsV.(Tensor)
+	if !ok {
+		return nil, nil, dt, errors.Errorf("Expected a Tensor of labels. Got %v of %T instead", labelsV, labelsV)
+	}
+	it, ok := lt.Tensor.(*ti.Tensor)
+	if !ok {
+		return nil, nil, dt, errors.Errorf(dtypeMismatch, Int, lt.Dtype())
+	}
+	if it.IsMaterializable() {
+		it = it.Materialize().(*ti.Tensor)
+	}
+	labels = it.Data().([]int)
+
+	if len(labels) != ft.Shape()[0] {
+		return nil, nil, dt, errors.Errorf("Expected %d labels. Got %d instead", ft.Shape()[0], len(labels))
+	}
+	for _, y := range labels {
+		if y < 0 || y >= op.numClasses {
+			return nil, nil, dt, errors.Errorf("Label %d is out of range. There are %d classes", y, op.numClasses)
+		}
+	}
+	return
+}
+
+// updateCenters moves the centers of the classes towards the mean of the features of the class
+func (op centerLossOp) updateCenters(x []float64, labels []int) {
+	delta := make([]float64, len(op.centers))
+	counts := make([]int, op.numClasses)
+	for i, y := range labels {
+		counts[y]++
+		for k := 0; k < op.dim; k++ {
+			delta[y*op.dim+k] += op.centers[y*op.dim+k] - x[i*op.dim+k]
+		}
+	}
+
+	for j, n := range counts {
+		for k := 0; k < op.dim; k++ {
+			op.centers[j*op.dim+k] -= op.alpha * delta[j*op.dim+k] / float64(1+n)
+		}
+	}
+}
+
+func (op centerLossOp) returnsPtr() bool    { return false }
+func (op centerLossOp) callsExtern() bool   { return false }
+func (op centerLossOp) overwriteInput() int { return -1 }
+func (op centerLossOp) isStateful() bool    { return true }
+func (op centerLossOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("centerLoss"))
+	if err := binary.Write(h, binary.LittleEndian, int64(op.numClasses)); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, op.alpha); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, int64(op.dim)); err != nil {
+		panic(err)
+	}
+}
+
+func (op centerLossOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// MarshalBinary always fails. The centers that a centerLossOp updates as it trains are state that lives with the graph, and are not written out
+func (op centerLossOp) MarshalBinary() ([]byte, error) {
+	return nil, errors.Errorf("%v cannot be encoded: it holds the centers it has learnt", op)
+}
+
+func (op centerLossOp) String() string {
+	return fmt.Sprintf("CenterLoss(%d, α=%v)", op.numClasses, op.alpha)
+}
+
+// centerLossDiffOp is the derivative of centerLossOp wrt the features. It takes the features, the labels and the gradient.
+//		∂xᵢ = grad * (xᵢ - c(yᵢ))
+// Once the gradient is computed, the centers are updated.
+type centerLossDiffOp struct {
+	fwd centerLossOp
+}
+
+// centerLossDiffOp has this type:
+//		op :: Matrix a → Vector Int → a → Matrix a
+func (op centerLossDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	tt := newTensorType(2, a)
+	return newFunctionType(tt, newTensorType(1, Int), a, tt)
+}
+
+func (op centerLossDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "centerLossDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op centerLossDiffOp) DiffWRT(inputs int) []bool { return make([]bool, inputs) }
+func (op centerLossDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) {
+	return nil, nondiffErr(op)
+}
+
+func (op centerLossDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "centerLossDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var x []float64
+	var labels []int
+	var dt Dtype
+	if x, labels, dt, err = op.fwd.operands(inputs[0], inputs[1]); err != nil {
+		return
+	}
+
+	var g float64
+	switch gv := inputs[2].Data().(type) {
+	case float64:
+		g = gv
+	case float32:
+		g = float64(gv)
+	default:
+		return nil, errors.Errorf(nyiFail, "centerLossDiffOp.Do()", inputs[2])
+	}
+
+	dim := op.fwd.dim
+	dx := make([]float64, len(x))
+	for i, y := range labels {
+		for k := 0; k < dim; k++ {
+			dx[i*dim+k] = g * (x[i*dim+k] - op.fwd.centers[y*dim+k])
+		}
+	}
+	op.fwd.updateCenters(x, labels)
+
+	if dt == Float32 {
+		return FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(dx)), tf32.WithShape(len(labels), dim))), nil
+	}
+	return FromTensor(tf64.NewTensor(tf64.WithBacking(dx), tf64.WithShape(len(labels), dim))), nil
+}
+
+func (op centerLossDiffOp) returnsPtr() bool    { return false }
+func (op centerLossDiffOp) callsExtern() bool   { return false }
+func (op centerLossDiffOp) overwriteInput() int { return -1 }
+func (op centerLossDiffOp) isStateful() bool    { return true }
+func (op centerLossDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	op.fwd.WriteHash(h)
+}
+
+func (op centerLossDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// MarshalBinary always fails, for the same reason centerLossOp.MarshalBinary does
+func (op centerLossDiffOp) MarshalBinary() ([]byte, error) {
+	return nil, errors.Errorf("%v cannot be encoded: it holds the centers it has learnt", op)
+}
+
+func (op centerLossDiffOp) String() string { return fmt.Sprintf("∂%v", op.fwd) }
+
+// BatchNormStats holds the running mean and variance of each feature that RunningStats tracks, and whether it is training.
+//
+// While training, every batch is normalized with its own mean and variance, which are then blended into the running statistics:
+//		running = (1 - momentum) × running + momentum × batch
+// For inference, the running statistics are used instead, and are left alone. The running mean starts at 0 and the running variance at 1.
+type BatchNormStats struct {
+	mean, variance []float64
+	momentum, eps  float64
+	training       bool
+}
+
+// Training reports whether the batches are normalized by their own statistics (true), or by the running statistics (false).
+func (s *BatchNormStats) Training() bool { return s.training }
+
+// SetTraining switches between training, and inference. It takes effect the next time the graph is run, so that the same graph,
+// compiled once, can be used for both.
+func (s *BatchNormStats) SetTraining(training bool) { s.training = training }
+
+// Mean returns a copy of the running mean of each feature.
+func (s *BatchNormStats) Mean() []float64 { return append([]float64(nil), s.mean...) }
+
+// Variance returns a copy of the running variance of each feature. It is the variance of the batches (divided by the batch size),
+// which is what they are normalized with while training.
+func (s *BatchNormStats) Variance() []float64 { return append([]float64(nil), s.variance...) }
+
+// moments computes the mean and variance of each column of x, which is shaped (batch, features)
+func (s *BatchNormStats) moments(x []float64, batch int) (mean, variance []float64) {
+	features := len(s.mean)
+	mean = make([]float64, features)
+	variance = make([]float64, features)
+	for i := 0; i < batch; i++ {
+		for k, v := range x[i*features : (i+1)*features] {
+			mean[k] += v
+		}
+	}
+	for k := range mean {
+		mean[k] /= float64(batch)
+	}
+	for i := 0; i < batch; i++ {
+		for k, v := range x[i*features : (i+1)*features] {
+			d := v - mean[k]
+			variance[k] += d * d
+		}
+	}
+	for k := range variance {
+		variance[k] /= float64(batch)
+	}
+	return
+}
+
+// update blends the statistics of a batch into the running statistics
+func (s *BatchNormStats) update(mean, variance []float64) {
+	for k := range s.mean {
+		s.mean[k] = (1-s.momentum)*s.mean[k] + s.momentum*mean[k]
+		s.variance[k] = (1-s.momentum)*s.variance[k] + s.momentum*variance[k]
+	}
+}
+
+// runningStatsOp is the normalization of batch norm, without the learnt scale and shift: the (batch, features) input is normalized per feature,
+//		y = (x - μ) / √(σ² + ε)
+// While training, μ and σ² are those of the batch, and the gradient is that of batch norm, which accounts for them depending on x:
+//		∂x = (∂y - mean(∂y) - y × mean(∂y × y)) / √(σ² + ε)
+// and the running statistics are updated as a side effect of Do. For inference, μ and σ² are the running statistics, so the op is a fixed
+// affine transform whose gradient is ∂y / √(σ² + ε). The mode is read when the op is run, not when the graph is built.
+//
+// Because of the hidden state, the op is stateful - two running stats are never merged.
+type runningStatsOp struct {
+	*BatchNormStats
+}
+
+// runningStatsOp has this type:
+//		op :: (Float a) ⇒ Matrix a → Matrix a
+func (op runningStatsOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(2, a)
+	return newFunctionType(t, t)
+}
+
+func (op runningStatsOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "runningStatsOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	x := inputs[0]
+	if len(x.shape) != 2 || x.shape[1] != len(op.mean) {
+		return nil, errors.Errorf("Expected an input shaped (batch, %d). Got %v instead", len(op.mean), x.shape)
+	}
+	return x.shape.Clone(), nil
+}
+
+func (op runningStatsOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+func (op runningStatsOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "runningStatsOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(runningStatsDiffOp(op), inputs[0], gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op runningStatsOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "runningStatsOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	diff := runningStatsDiffOp(op)
+	var d Value
+	if d, err = diff.Do(xdv.Value, ydv.d); err != nil {
+		return errors.Wrapf(err, doFail, diff)
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op runningStatsOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "runningStatsOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var x []float64
+	var shp types.Shape
+	var dt Dtype
+	if x, shp, dt, err = op.operand(inputs[0]); err != nil {
+		return
+	}
+
+	mean, variance := op.mean, op.variance
+	if op.training {
+		mean, variance = op.moments(x, shp[0])
+	}
+
+	features := len(op.mean)
+	y := make([]float64, len(x))
+	for i, v := range x {
+		k := i % features
+		y[i] = (v - mean[k]) / math.Sqrt(variance[k]+op.eps)
+	}
+
+	if op.training {
+		op.update(mean, variance)
+	}
+	return floatsValue(y, shp, dt), nil
+}
+
+// operand checks that the input is shaped (batch, features), and returns it as float64s
+func (op runningStatsOp) operand(v Value) (x []float64, shp types.Shape, dt Dtype, err error) {
+	if x, shp, dt, err = floatsOperand(v); err != nil {
+		return
+	}
+	if len(shp) != 2 || shp[1] != len(op.mean) || shp[0] == 0 {
+		return nil, nil, dt, errors.Errorf("Expected an input shaped (batch, %d). Got %v instead", len(op.mean), shp)
+	}
+	return
+}
+
+func (op runningStatsOp) returnsPtr() bool    { return false }
+func (op runningStatsOp) callsExtern() bool   { return false }
+func (op runningStatsOp) overwriteInput() int { return -1 }
+func (op runningStatsOp) isStateful() bool    { return true }
+func (op runningStatsOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("runningStats"))
+	if err := binary.Write(h, binary.LittleEndian, int64(len(op.mean))); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, op.momentum); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, op.eps); err != nil {
+		panic(err)
+	}
+}
+
+func (op runningStatsOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// MarshalBinary always fails. A runningStatsOp shares its BatchNormStats with the caller of RunningStats, which is the one to save them
+func (op runningStatsOp) MarshalBinary() ([]byte, error) {
+	return nil, errors.Errorf("%v cannot be encoded: it holds BatchNormStats that are shared with the caller of RunningStats", op)
+}
+
+func (op runningStatsOp) String() string {
+	return fmt.Sprintf("RunningStats(%d, momentum=%v)", len(op.mean), op.momentum)
+}
+
+// runningStatsDiffOp is the derivative of runningStatsOp. It takes x and the gradient of the output. See runningStatsOp for the formulas.
+// While training, the statistics of the batch are recomputed from x, as the running statistics have moved on by then.
+type runningStatsDiffOp runningStatsOp
+
+// runningStatsDiffOp has this type:
+//		op :: (Float a) ⇒ Matrix a → Matrix a → Matrix a
+func (op runningStatsDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(2, a)
+	return newFunctionType(t, t, t)
+}
+
+func (op runningStatsDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "runningStatsDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op runningStatsDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op runningStatsDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op runningStatsDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "runningStatsDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	fwd := runningStatsOp(op)
+	var x, grad []float64
+	var shp, gradShape types.Shape
+	var dt Dtype
+	if x, shp, dt, err = fwd.operand(inputs[0]); err != nil {
+		return
+	}
+	if grad, gradShape, _, err = floatsOperand(inputs[1]); err != nil {
+		return
+	}
+	if !shp.Eq(gradShape) {
+		return nil, errors.Errorf("Shape mismatch: %v and %v", shp, gradShape)
+	}
+
+	features := len(op.mean)
+	dx := make([]float64, len(x))
+	if !op.training {
+		for i, g := range grad {
+			dx[i] = g / math.Sqrt(op.variance[i%features]+op.eps)
+		}
+		return floatsValue(dx, shp, dt), nil
+	}
+
+	batch := shp[0]
+	mean, variance := fwd.moments(x, batch)
+	y := make([]float64, len(x))
+	meanG := make([]float64, features)
+	meanGY := make([]float64, features)
+	for i, v := range x {
+		k := i % features
+		y[i] = (v - mean[k]) / math.Sqrt(variance[k]+op.eps)
+		meanG[k] += grad[i] / float64(batch)
+		meanGY[k] += grad[i] * y[i] / float64(batch)
+	}
+	for i, g := range grad {
+		k := i % features
+		dx[i] = (g - meanG[k] - y[i]*meanGY[k]) / math.Sqrt(variance[k]+op.eps)
+	}
+	return floatsValue(dx, shp, dt), nil
+}
+
+func (op runningStatsDiffOp) returnsPtr() bool    { return false }
+func (op runningStatsDiffOp) callsExtern() bool   { return false }
+func (op runningStatsDiffOp) overwriteInput() int { return -1 }
+func (op runningStatsDiffOp) isStateful() bool    { return true }
+func (op runningStatsDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	runningStatsOp(op).WriteHash(h)
+}
+
+func (op runningStatsDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// MarshalBinary always fails, for the same reason runningStatsOp.MarshalBinary does
+func (op runningStatsDiffOp) MarshalBinary() ([]byte, error) {
+	return runningStatsOp(op).MarshalBinary()
+}
+
+func (op runningStatsDiffOp) String() string { return fmt.Sprintf("∂%v", runningStatsOp(op)) }
+
+// ntxentOp computes the normalized temperature-scaled cross entropy loss (NT-Xent) used in SimCLR.
+// The input is a (2N, dim) matrix of embeddings, where the ith and the (i+N)th embeddings are the two views of the same sample.
+// With sᵢₖ being the cosine similarity between embeddings i and k, and p(i) the positive pair of i, the loss is
+//		loss = 1/2N Σᵢ -log(exp(sᵢₚ₍ᵢ₎/τ) / Σ{k≠i} exp(sᵢₖ/τ))
+type ntxentOp struct {
+	temperature float64
+}
+
+// ntxentOp has this type:
+//		op :: Matrix a → a
+func (op ntxentOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(newTensorType(2, a), a)
+}
+
+func (op ntxentOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "ntxentOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	x := inputs[0]
+	if len(x.shape) != 2 || x.shape[0] < 2 || x.shape[0]%2 != 0 {
+		return nil, errors.Errorf("Expected a (2N, dim) matrix of paired embeddings. Got %v instead", x.shape)
+	}
+	return scalarShape, nil
+}
+
+func (op ntxentOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+func (op ntxentOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "ntxentOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(ntxentDiffOp(op), inputs[0], gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op ntxentOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "ntxentOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	odv := output.boundTo.(*dualValue)
+
+	var d Value
+	if d, err = ntxentDiffOp(op).Do(xdv.Value, odv.d); err != nil {
+		return errors.Wrap(err, "ntxentOp.DoDiff()")
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op ntxentOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "ntxentOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var x []float64
+	var n, dim int
+	var dt Dtype
+	if x, n, dim, dt, err = ntxentOperands(inputs[0]); err != nil {
+		return
+	}
+
+	loss, _, _ := op.loss(x, n, dim)
+	if dt == Float32 {
+		return anyToValue(float32(loss))
+	}
+	return anyToValue(loss)
+}
+
+// loss computes the loss, as well as the normalized embeddings u and the derivative of the loss wrt the similarity matrix.
+func (op ntxentOp) loss(x []float64, n, dim int) (loss float64, u, dS []float64) {
+	u = make([]float64, len(x))
+	for i := 0; i < n; i++ {
+		var norm float64
+		for _, v := range x[i*dim : (i+1)*dim] {
+			norm += v * v
+		}
+		norm = math.Max(math.Sqrt(norm), 1e-12)
+		for k := 0; k < dim; k++ {
+			u[i*dim+k] = x[i*dim+k] / norm
+		}
+	}
+
+	z := make([]float64, n)
+	dS = make([]float64, n*n)
+	for i := 0; i < n; i++ {
+		pos := (i + n/2) % n
+		max := math.Inf(-1)
+		for k := 0; k < n; k++ {
+			if k == i {
+				continue
+			}
+			var s float64
+			for j := 0; j < dim; j++ {
+				s += u[i*dim+j] * u[k*dim+j]
+			}
+			z[k] = s / op.temperature
+			max = math.Max(max, z[k])
+		}
+
+		var sum float64
+		for k := 0; k < n; k++ {
+			if k != i {
+				sum += math.Exp(z[k] - max)
+			}
+		}
+		loss += max + math.Log(sum) - z[pos]
+
+		for k := 0; k < n; k++ {
+			if k == i {
+				continue
+			}
+			d := math.Exp(z[k]-max) / sum
+			if k == pos {
+				d--
+			}
+			dS[i*n+k] = d / op.temperature / float64(n)
+		}
+	}
+	return loss / float64(n), u, dS
+}
+
+func (op ntxentOp) returnsPtr() bool    { return false }
+func (op ntxentOp) callsExtern() bool   { return false }
+func (op ntxentOp) overwriteInput() int { return -1 }
+func (op ntxentOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("ntxent"))
+	if err := binary.Write(h, binary.LittleEndian, op.temperature); err != nil {
+		panic(err)
+	}
+}
+
+func (op ntxentOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op ntxentOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeFloat(op.temperature)
+	return w.bytes()
+}
+
+func (op *ntxentOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.temperature = r.readFloat()
+	r.check(op.temperature > 0, "Expected a positive temperature. Got %v instead", op.temperature)
+	return r.done()
+}
+
+func (op ntxentOp) String() string { return fmt.Sprintf("NTXent(τ=%v)", op.temperature) }
+
+// ntxentDiffOp is the derivative of ntxentOp. It takes the embeddings and the gradient.
+type ntxentDiffOp struct {
+	temperature float64
+}
+
+// ntxentDiffOp has this type:
+//		op :: Matrix a → a → Matrix a
+func (op ntxentDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	tt := newTensorType(2, a)
+	return newFunctionType(tt, a, tt)
+}
+
+func (op ntxentDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "ntxentDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op ntxentDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op ntxentDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op ntxentDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "ntxentDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var x []float64
+	var n, dim int
+	var dt Dtype
+	if x, n, dim, dt, err = ntxentOperands(inputs[0]); err != nil {
+		return
+	}
+
+	var g float64
+	switch gv := inputs[1].Data().(type) {
+	case float64:
+		g = gv
+	case float32:
+		g = float64(gv)
+	default:
+		return nil, errors.Errorf(nyiFail, "ntxentDiffOp.Do()", inputs[1])
+	}
+
+	_, u, dS := ntxentOp(op).loss(x, n, dim)
+
+	// sᵢₖ = uᵢ·uₖ, and the similarity matrix is symmetric, so ∂uᵢ = Σₖ (∂Sᵢₖ + ∂Sₖᵢ)uₖ
+	du := make([]float64, len(x))
+	for i := 0; i < n; i++ {
+		for k := 0; k < n; k++ {
+			d := dS[i*n+k] + dS[k*n+i]
+			if d == 0 {
+				continue
+			}
+			for j := 0; j < dim; j++ {
+				du[i*dim+j] += d * u[k*dim+j]
+			}
+		}
+	}
+
+	// uᵢ = xᵢ/‖xᵢ‖, so ∂xᵢ = (∂uᵢ - (uᵢ·∂uᵢ)uᵢ) / ‖xᵢ‖
+	dx := make([]float64, len(x))
+	for i := 0; i < n; i++ {
+		var norm, dot float64
+		for j := 0; j < dim; j++ {
+			norm += x[i*dim+j] * x[i*dim+j]
+			dot += u[i*dim+j] * du[i*dim+j]
+		}
+		norm = math.Max(math.Sqrt(norm), 1e-12)
+		for j := 0; j < dim; j++ {
+			dx[i*dim+j] = g * (du[i*dim+j] - dot*u[i*dim+j]) / norm
+		}
+	}
+
+	if dt == Float32 {
+		return FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(dx)), tf32.WithShape(n, dim))), nil
+	}
+	return FromTensor(tf64.NewTensor(tf64.WithBacking(dx), tf64.WithShape(n, dim))), nil
+}
+
+func (op ntxentDiffOp) returnsPtr() bool    { return false }
+func (op ntxentDiffOp) callsExtern() bool   { return false }
+func (op ntxentDiffOp) overwriteInput() int { return -1 }
+func (op ntxentDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	ntxentOp(op).WriteHash(h)
+}
+
+func (op ntxentDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op ntxentDiffOp) MarshalBinary() ([]byte, error) {
+	return ntxentOp(op).MarshalBinary()
+}
+
+func (op *ntxentDiffOp) UnmarshalBinary(data []byte) error {
+	return (*ntxentOp)(op).UnmarshalBinary(data)
+}
+
+func (op ntxentDiffOp) String() string { return fmt.Sprintf("∂NTXent(τ=%v)", op.temperature) }
+
+// ntxentOperands returns the embeddings as float64s, along with the number of embeddings (2N) and their dims
+func ntxentOperands(v Value) (x []float64, n, dim int, dt Dtype, err error) {
+	t, ok := v.(Tensor)
+	if !ok || t.Dims() != 2 || t.Shape()[0] < 2 || t.Shape()[0]%2 != 0 {
+		err = errors.Errorf("Expected a (2N, dim) matrix of paired embeddings. Got %v instead", v)
+		return
+	}
+
+	n, dim = t.Shape()[0], t.Shape()[1]
+	dt = t.Dtype()
+	switch tt := t.Tensor.(type) {
+	case *tf64.Tensor:
+		x = materializedF64s(tt)
+	case *tf32.Tensor:
+		x = f32sToF64s(materializedF32s(tt))
+	default:
+		err = errors.Errorf(nyiFail, "ntxentOp", t.Tensor)
+	}
+	return
+}
+
+// biasAddOp adds a (n) bias to each row of a (batch, n) matrix, in a single pass. It is the xW + b of a dense layer.
+// The gradient flows through to the input as is, while the gradient of the bias is the sum of the gradient over the batch.
+type biasAddOp struct{}
+
+// biasAddOp has this type:
+//		op :: (Float a) ⇒ Matrix a → Vector a → Matrix a
+func (op biasAddOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	m := newTensorType(2, a)
+	return newFunctionType(m, newTensorType(1, a), m)
+}
+
+func (op biasAddOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "biasAddOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	x, b := inputs[0], inputs[1]
+	if len(x.shape) != 2 {
+		return nil, errors.Errorf("Expected a (batch, n) matrix. Got %v instead", x.shape)
+	}
+	if b.shape.TotalSize() != x.shape[1] {
+		return nil, errors.Errorf("Expected a bias of %d elements to add to a %v matrix. Got a bias shaped %v instead", x.shape[1], x.shape, b.shape)
+	}
+	return x.shape.Clone(), nil
+}
+
+func (op biasAddOp) DiffWRT(inputs int) []bool { return []bool{true, true} }
+
+func (op biasAddOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "biasAddOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var db *Node
+	if db, err = Sum(gradNode, 0); err != nil {
+		return nil, errors.Wrap(err, sumFail)
+	}
+	db.setGroup(gradClust)
+	return Nodes{gradNode, db}, nil
+}
+
+func (op biasAddOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "biasAddOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	bdv := inputs[1].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	sum := newSumOp(axes{0}, output.shape, 2)
+	var db Value
+	if db, err = sum.Do(ydv.d); err != nil {
+		return errors.Wrapf(err, doFail, sum)
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], output)
+	if _, err = add.UnsafeDo(xdv.d, ydv.d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+
+	add = newElemBinOp(addOpType, inputs[1], inputs[1])
+	if _, err = add.UnsafeDo(bdv.d, db); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op biasAddOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "biasAddOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	x, ok := inputs[0].(Tensor)
+	if !ok || x.Dims() != 2 {
+		return nil, errors.Errorf("Expected a (batch, n) matrix. Got %v instead", inputs[0])
+	}
+	b, ok := inputs[1].(Tensor)
+	if !ok || b.Shape().TotalSize() != x.Shape()[1] {
+		return nil, errors.Errorf("Expected a bias of %d elements. Got %v instead", x.Shape()[1], inputs[1])
+	}
+
+	shp := x.Shape()
+	switch xt := x.Tensor.(type) {
+	case *tf64.Tensor:
+		bt, ok := b.Tensor.(*tf64.Tensor)
+		if !ok {
+			return nil, errors.Errorf("Expected the bias to be of %v like the input. Got %v instead", x.Dtype(), b.Dtype())
+		}
+		out := make([]float64, shp.TotalSize())
+		biasAddf64(materializedF64s(xt), materializedF64s(bt), out)
+		retVal = FromTensor(tf64.NewTensor(tf64.WithBacking(out), tf64.WithShape(shp.Clone()...)))
+	case *tf32.Tensor:
+		bt, ok := b.Tensor.(*tf32.Tensor)
+		if !ok {
+			return nil, errors.Errorf("Expected the bias to be of %v like the input. Got %v instead", x.Dtype(), b.Dtype())
+		}
+		out := make([]float32, shp.TotalSize())
+		biasAddf32(materializedF32s(xt), materializedF32s(bt), out)
+		retVal = FromTensor(tf32.NewTensor(tf32.WithBacking(out), tf32.WithShape(shp.Clone()...)))
+	default:
+		return nil, errors.Errorf(nyiFail, "biasAddOp.Do()", x.Tensor)
+	}
+	return
+}
+
+func (op biasAddOp) returnsPtr() bool      { return false }
+func (op biasAddOp) callsExtern() bool     { return false }
+func (op biasAddOp) overwriteInput() int   { return -1 }
+func (op biasAddOp) WriteHash(h hash.Hash) { h.Write([]byte("biasAdd")) }
+
+func (op biasAddOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op biasAddOp) String() string { return "BiasAdd" }
+
+func biasAddf64(x, b, out []float64) {
+	n := len(b)
+	for i := 0; i < len(x); i += n {
+		for j, v := range b {
+			out[i+j] = x[i+j] + v
+		}
+	}
+}
+
+func biasAddf32(x, b, out []float32) {
+	n := len(b)
+	for i := 0; i < len(x); i += n {
+		for j, v := range b {
+			out[i+j] = x[i+j] + v
+		}
+	}
+}
+
+// outerSumOp adds a (m) vector and a (n) vector into a (m, n) matrix, as if a were broadcast along the columns and b along the rows:
+//		z[i, j] = a[i] + b[j]
+// It turns up in additive attention, where the projected queries are added to every projected key. The gradients are
+//		∂a = Σⱼ ∂z, ∂b = Σᵢ ∂z
+type outerSumOp struct{}
+
+// outerSumOp has this type:
+//		op :: (Float a) ⇒ Vector a → Vector a → Matrix a
+func (op outerSumOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	v := newTensorType(1, a)
+	return newFunctionType(v, v, newTensorType(2, a))
+}
+
+func (op outerSumOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "outerSumOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	a, b := inputs[0], inputs[1]
+	if !a.IsVector() || !b.IsVector() {
+		return nil, errors.Errorf("Expected two vectors. Got %v and %v instead", a.shape, b.shape)
+	}
+	return types.Shape{a.shape.TotalSize(), b.shape.TotalSize()}, nil
+}
+
+func (op outerSumOp) DiffWRT(inputs int) []bool { return []bool{true, true} }
+
+func (op outerSumOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "outerSumOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	retVal = make(Nodes, 2)
+	for i, along := range []int{1, 0} {
+		if retVal[i], err = Sum(gradNode, along); err != nil {
+			return nil, errors.Wrap(err, sumFail)
+		}
+		retVal[i].setGroup(gradClust)
+	}
+	return
+}
+
+func (op outerSumOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "outerSumOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	ydv := output.boundTo.(*dualValue)
+	for i, along := range []int{1, 0} {
+		sum := newSumOp(axes{along}, output.shape, 2)
+		var d Value
+		if d, err = sum.Do(ydv.d); err != nil {
+			return errors.Wrapf(err, doFail, sum)
+		}
+
+		xdv := inputs[i].boundTo.(*dualValue)
+		add := newElemBinOp(addOpType, inputs[i], inputs[i])
+		if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+			return errors.Wrapf(err, unsafeDoFail, add)
+		}
+	}
+	return
+}
+
+func (op outerSumOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "outerSumOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	a, ok := inputs[0].(Tensor)
+	if !ok {
+		return nil, errors.Errorf("Expected a vector. Got %v instead", inputs[0])
+	}
+	b, ok := inputs[1].(Tensor)
+	if !ok {
+		return nil, errors.Errorf("Expected a vector. Got %v instead", inputs[1])
+	}
+
+	m, n := a.Shape().TotalSize(), b.Shape().TotalSize()
+	switch at := a.Tensor.(type) {
+	case *tf64.Tensor:
+		bt, ok := b.Tensor.(*tf64.Tensor)
+		if !ok {
+			return nil, errors.Errorf("Expected b to be of %v like a. Got %v instead", a.Dtype(), b.Dtype())
+		}
+		out := make([]float64, m*n)
+		outerSumf64(materializedF64s(at), materializedF64s(bt), out)
+		retVal = FromTensor(tf64.NewTensor(tf64.WithBacking(out), tf64.WithShape(m, n)))
+	case *tf32.Tensor:
+		bt, ok := b.Tensor.(*tf32.Tensor)
+		if !ok {
+			return nil, errors.Errorf("Expected b to be of %v like a. Got %v instead", a.Dtype(), b.Dtype())
+		}
+		out := make([]float32, m*n)
+		outerSumf32(materializedF32s(at), materializedF32s(bt), out)
+		retVal = FromTensor(tf32.NewTensor(tf32.WithBacking(out), tf32.WithShape(m, n)))
+	default:
+		return nil, errors.Errorf(nyiFail, "outerSumOp.Do()", a.Tensor)
+	}
+	return
+}
+
+func (op outerSumOp) returnsPtr() bool      { return false }
+func (op outerSumOp) callsExtern() bool     { return false }
+func (op outerSumOp) overwriteInput() int   { return -1 }
+func (op outerSumOp) WriteHash(h hash.Hash) { h.Write([]byte("outerSum")) }
+
+func (op outerSumOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op outerSumOp) String() string { return "OuterSum" }
+
+func outerSumf64(a, b, out []float64) {
+	n := len(b)
+	for i, u := range a {
+		for j, v := range b {
+			out[i*n+j] = u + v
+		}
+	}
+}
+
+func outerSumf32(a, b, out []float32) {
+	n := len(b)
+	for i, u := range a {
+		for j, v := range b {
+			out[i*n+j] = u + v
+		}
+	}
+}
+
+// affineOp scales and shifts each row of a (batch, n) matrix by a (n) gamma and a (n) beta, in a single pass:
+//		y = gamma ⊙ x + beta
+// as the normalization layers do after normalizing. The gradients are
+//		∂x = gamma ⊙ ∂y, ∂gamma = Σ x ⊙ ∂y, ∂beta = Σ ∂y
+// where the sums are taken over the batch, which gamma and beta are broadcast across.
+type affineOp struct{}
+
+// affineOp has this type:
+//		op :: (Float a) ⇒ Matrix a → Vector a → Vector a → Matrix a
+func (op affineOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	m := newTensorType(2, a)
+	v := newTensorType(1, a)
+	return newFunctionType(m, v, v, m)
+}
+
+func (op affineOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "affineOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	x, gamma, beta := inputs[0], inputs[1], inputs[2]
+	if len(x.shape) != 2 {
+		return nil, errors.Errorf("Expected a (batch, n) matrix. Got %v instead", x.shape)
+	}
+	if gamma.shape.TotalSize() != x.shape[1] || beta.shape.TotalSize() != x.shape[1] {
+		return nil, errors.Errorf("Expected gamma and beta of %d elements for a %v matrix. Got %v and %v instead", x.shape[1], x.shape, gamma.shape, beta.shape)
+	}
+	return x.shape.Clone(), nil
+}
+
+func (op affineOp) DiffWRT(inputs int) []bool { return []bool{true, true, true} }
+
+// SymDiff computes the gradients of x and gamma with affineDiffOp. The gradient of beta is the sum of the gradient of the rows, as it is for BiasAdd.
+func (op affineOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "affineOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx, dGamma, dBeta *Node
+	if dx, err = applyOp(affineDiffOp{wrt: 0}, inputs[0], inputs[1], gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	if dGamma, err = applyOp(affineDiffOp{wrt: 1}, inputs[0], inputs[1], gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	if dBeta, err = Sum(gradNode, 0); err != nil {
+		return nil, errors.Wrap(err, sumFail)
+	}
+	dx.setGroup(gradClust)
+	dGamma.setGroup(gradClust)
+	dBeta.setGroup(gradClust)
+	return Nodes{dx, dGamma, dBeta}, nil
+}
+
+func (op affineOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "affineOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	gdv := inputs[1].boundTo.(*dualValue)
+	bdv := inputs[2].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	var dx, dGamma, dBeta Value
+	if dx, dGamma, dBeta, err = affineBackward(xdv.Value, gdv.Value, ydv.d); err != nil {
+		return errors.Wrap(err, "affineOp.DoDiff()")
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(xdv.d, dx); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+
+	add = newElemBinOp(addOpType, inputs[1], inputs[1])
+	if _, err = add.UnsafeDo(gdv.d, dGamma); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+
+	add = newElemBinOp(addOpType, inputs[2], inputs[2])
+	if _, err = add.UnsafeDo(bdv.d, dBeta); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op affineOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "affineOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var x, gamma, beta []float64
+	var shp types.Shape
+	var dt Dtype
+	if x, gamma, shp, dt, err = affineOperands(inputs[0], inputs[1]); err != nil {
+		return
+	}
+	if beta, _, _, err = floatsOperand(inputs[2]); err != nil {
+		return
+	}
+	if len(beta) != len(gamma) {
+		return nil, errors.Errorf("Expected a beta of %d elements. Got %v instead", len(gamma), inputs[2])
+	}
+
+	n := len(gamma)
+	y := make([]float64, len(x))
+	for i, v := range x {
+		y[i] = gamma[i%n]*v + beta[i%n]
+	}
+	return floatsValue(y, shp, dt), nil
+}
+
+func (op affineOp) returnsPtr() bool      { return false }
+func (op affineOp) callsExtern() bool     { return false }
+func (op affineOp) overwriteInput() int   { return -1 }
+func (op affineOp) WriteHash(h hash.Hash) { h.Write([]byte("affine")) }
+
+func (op affineOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op affineOp) String() string { return "Affine" }
+
+// affineDiffOp is the derivative of affineOp with regards to either x (wrt = 0) or gamma (wrt = 1).
+// The inputs are x, gamma and the gradient of the output of the affineOp.
+type affineDiffOp struct {
+	wrt int
+}
+
+// affineDiffOp has this type:
+//		op :: (Float a) ⇒ Matrix a → Vector a → Matrix a → b
+// where b is a Matrix for x, and a Vector for gamma
+func (op affineDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	m := newTensorType(2, a)
+	v := newTensorType(1, a)
+	if op.wrt == 0 {
+		return newFunctionType(m, v, m, m)
+	}
+	return newFunctionType(m, v, m, v)
+}
+
+func (op affineDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "affineDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[op.wrt].shape.Clone(), nil
+}
+
+func (op affineDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op affineDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op affineDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "affineDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx, dGamma Value
+	if dx, dGamma, _, err = affineBackward(inputs[0], inputs[1], inputs[2]); err != nil {
+		return
+	}
+	if op.wrt == 0 {
+		return dx, nil
+	}
+	return dGamma, nil
+}
+
+func (op affineDiffOp) returnsPtr() bool    { return false }
+func (op affineDiffOp) callsExtern() bool   { return false }
+func (op affineDiffOp) overwriteInput() int { return -1 }
+func (op affineDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("affineDiff"))
+	if err := binary.Write(h, binary.LittleEndian, byte(op.wrt)); err != nil {
+		panic(err)
+	}
+}
+
+func (op affineDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op affineDiffOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.wrt)
+	return w.bytes()
+}
+
+func (op *affineDiffOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.wrt = r.readInt()
+	r.checkWRT(op.wrt, 2)
+	return r.done()
+}
+
+func (op affineDiffOp) String() string { return fmt.Sprintf("∂Affine/∂%d", op.wrt) }
+
+// affineOperands checks that x is a (batch, n) matrix and that gamma has n elements, and returns them as float64s
+func affineOperands(xv, gammaV Value) (x, gamma []float64, shp types.Shape, dt Dtype, err error) {
+	if x, shp, dt, err = floatsOperand(xv); err != nil {
+		return
+	}
+	if len(shp) != 2 {
+		err = errors.Errorf("Expected a (batch, n) matrix. Got %v instead", xv)
+		return
+	}
+	if gamma, _, _, err = floatsOperand(gammaV); err != nil {
+		return
+	}
+	if len(gamma) != shp[1] {
+		err = errors.Errorf("Expected a gamma of %d elements. Got %v instead", shp[1], gammaV)
+	}
+	return
+}
+
+// affineBackward computes the gradients of x, gamma and beta, given the gradient of the output
+func affineBackward(xv, gammaV, gradV Value) (dx, dGamma, dBeta Value, err error) {
+	var x, gamma, grad []float64
+	var shp, gradShape types.Shape
+	var dt Dtype
+	if x, gamma, shp, dt, err = affineOperands(xv, gammaV); err != nil {
+		return
+	}
+	if grad, gradShape, _, err = floatsOperand(gradV); err != nil {
+		return
+	}
+	if !shp.Eq(gradShape) {
+		err = errors.Errorf("Shape mismatch: %v and %v", shp, gradShape)
+		return
+	}
+
+	n := len(gamma)
+	dX := make([]float64, len(x))
+	dG := make([]float64, n)
+	dB := make([]float64, n)
+	for i, g := range grad {
+		dX[i] = gamma[i%n] * g
+		dG[i%n] += x[i] * g
+		dB[i%n] += g
+	}
+
+	vecShape := types.Shape{n}
+	return floatsValue(dX, shp, dt), floatsValue(dG, vecShape, dt), floatsValue(dB, vecShape, dt), nil
+}
+
+// clipGradOp passes its input through untouched, but clamps the gradient flowing back through it into [-limit, limit], element by element.
+type clipGradOp struct {
+	limit float64
+}
+
+// clipGradOp has this type:
+//		op :: (Float a) ⇒ a → a
+func (op clipGradOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(a, a)
+}
+
+func (op clipGradOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "clipGradOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op clipGradOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+func (op clipGradOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "clipGradOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(clipGradDiffOp(op), gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op clipGradOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "clipGradOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	var d Value
+	if d, err = clipGradDiffOp(op).Do(ydv.d); err != nil {
+		return errors.Wrap(err, "clipGradOp.DoDiff()")
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], output)
+	if d, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+
+	// scalars are not added in place
+	if inputs[0].IsScalar() {
+		return xdv.SetDeriv(d)
+	}
+	return
+}
+
+func (op clipGradOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "clipGradOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return zipFloats(inputs[0], inputs[0], func(x, _ float64) float64 { return x })
+}
+
+func (op clipGradOp) returnsPtr() bool    { return false }
+func (op clipGradOp) callsExtern() bool   { return false }
+func (op clipGradOp) overwriteInput() int { return -1 }
+func (op clipGradOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("clipGrad"))
+	if err := binary.Write(h, binary.LittleEndian, op.limit); err != nil {
+		panic(err)
+	}
+}
+
+func (op clipGradOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op clipGradOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeFloat(op.limit)
+	return w.bytes()
+}
+
+func (op *clipGradOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.limit = r.readFloat()
+	r.check(op.limit > 0, "Expected the limit to be a positive number. Got %v instead", op.limit)
+	return r.done()
+}
+
+func (op clipGradOp) String() string { return fmt.Sprintf("ClipGrad(%v)", op.limit) }
+
+// clipGradDiffOp is the derivative of clipGradOp. It takes the gradient of the output, and clamps it into [-limit, limit].
+type clipGradDiffOp clipGradOp
+
+// clipGradDiffOp has this type:
+//		op :: (Float a) ⇒ a → a
+func (op clipGradDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(a, a)
+}
+
+func (op clipGradDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "clipGradDiffOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op clipGradDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op clipGradDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op clipGradDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "clipGradDiffOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	limit := op.limit
+	return zipFloats(inputs[0], inputs[0], func(g, _ float64) float64 { return math.Max(-limit, math.Min(limit, g)) })
+}
+
+func (op clipGradDiffOp) returnsPtr() bool    { return false }
+func (op clipGradDiffOp) callsExtern() bool   { return false }
+func (op clipGradDiffOp) overwriteInput() int { return -1 }
+func (op clipGradDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	clipGradOp(op).WriteHash(h)
+}
+
+func (op clipGradDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op clipGradDiffOp) MarshalBinary() ([]byte, error) {
+	return clipGradOp(op).MarshalBinary()
+}
+
+func (op *clipGradDiffOp) UnmarshalBinary(data []byte) error {
+	return (*clipGradOp)(op).UnmarshalBinary(data)
+}
+
+func (op clipGradDiffOp) String() string { return fmt.Sprintf("∂ClipGrad(%v)", op.limit) }
+
+// logSoftmaxOp computes the log of the softmax along an axis, as
+//		y = x - logsumexp(x) = x - max(x) - log Σ exp(x - max(x))
+// which, unlike log(softmax(x)), neither overflows nor takes the log of an underflowed 0.
+// The Jacobian-vector product is
+//		∂x = ∂y - softmax(x) Σ∂y
+// where softmax(x) = exp(y), so the gradient only needs the output.
+type logSoftmaxOp struct {
+	along int // axis
+	d     int
+}
+
+// logSoftmaxOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Tensor d a
+func (op logSoftmaxOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(op.d, a)
+	return newFunctionType(t, t)
+}
+
+func (op logSoftmaxOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "logSoftmaxOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	x := inputs[0]
+	if op.d > 1 && op.along >= len(x.shape) {
+		return nil, errors.Errorf("Cannot compute the log softmax along axis %d of a tensor shaped %v", op.along, x.shape)
+	}
+	return x.shape.Clone(), nil
+}
+
+// strides splits up the shape as splitAlong does. A vector is a single softmax, whichever way it is shaped.
+func (op logSoftmaxOp) strides(s types.Shape) (outer, n, inner int) {
+	if op.d == 1 {
+		return 1, s.TotalSize(), 1
+	}
+	return splitAlong(s, op.along)
+}
+
+func (op logSoftmaxOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+func (op logSoftmaxOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "logSoftmaxOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(logSoftmaxDiffOp(op), output, gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op logSoftmaxOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "logSoftmaxOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	diff := logSoftmaxDiffOp(op)
+	var d Value
+	if d, err = diff.Do(ydv.Value, ydv.d); err != nil {
+		return errors.Wrapf(err, doFail, diff)
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], output)
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op logSoftmaxOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "logSoftmaxOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var x []float64
+	var shp types.Shape
+	var dt Dtype
+	if x, shp, dt, err = floatsOperand(inputs[0]); err != nil {
+		return
+	}
+	outer, n, inner := op.strides(shp)
+
+	y := make([]float64, len(x))
+	for o := 0; o < outer; o++ {
+		for i := 0; i < inner; i++ {
+			base := o*n*inner + i
+			max := math.Inf(-1)
+			for k := 0; k < n; k++ {
+				max = math.Max(max, x[base+k*inner])
+			}
+
+			var sum float64
+			for k := 0; k < n; k++ {
+				sum += math.Exp(x[base+k*inner] - max)
+			}
+
+			lse := max + math.Log(sum)
+			for k := 0; k < n; k++ {
+				y[base+k*inner] = x[base+k*inner] - lse
+			}
+		}
+	}
+	return floatsValue(y, shp, dt), nil
+}
+
+func (op logSoftmaxOp) returnsPtr() bool    { return false }
+func (op logSoftmaxOp) callsExtern() bool   { return false }
+func (op logSoftmaxOp) overwriteInput() int { return -1 }
+func (op logSoftmaxOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("logSoftmax"))
+	if err := binary.Write(h, binary.LittleEndian, int64(op.along)); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, byte(op.d)); err != nil {
+		panic(err)
+	}
+}
+
+func (op logSoftmaxOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op logSoftmaxOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.along)
+	w.writeInt(op.d)
+	return w.bytes()
+}
+
+func (op *logSoftmaxOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.along = r.readInt()
+	op.d = r.readInt()
+	r.checkAxes(op.d, op.along)
+	return r.done()
+}
+
+func (op logSoftmaxOp) String() string { return fmt.Sprintf("LogSoftmax(%d)", op.along) }
+
+// logSoftmaxDiffOp is the derivative of logSoftmaxOp. It takes the output of logSoftmaxOp and the gradient of the output.
+type logSoftmaxDiffOp struct {
+	along int
+	d     int
+}
+
+// logSoftmaxDiffOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Tensor d a → Tensor d a
+func (op logSoftmaxDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(op.d, a)
+	return newFunctionType(t, t, t)
+}
+
+func (op logSoftmaxDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "logSoftmaxDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op logSoftmaxDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op logSoftmaxDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op logSoftmaxDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "logSoftmaxDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var y, grad []float64
+	var shp types.Shape
+	var dt Dtype
+	if y, shp, dt, err = floatsOperand(inputs[0]); err != nil {
+		return
+	}
+	if grad, _, _, err = floatsOperand(inputs[1]); err != nil {
+		return
+	}
+	if len(grad) != len(y) {
+		return nil, errors.Errorf("Expected the gradient to be shaped %v. Got %v instead", shp, inputs[1].Shape())
+	}
+	outer, n, inner := logSoftmaxOp(op).strides(shp)
+
+	dx := make([]float64, len(y))
+	for o := 0; o < outer; o++ {
+		for i := 0; i < inner; i++ {
+			base := o*n*inner + i
+			var sum float64
+			for k := 0; k < n; k++ {
+				sum += grad[base+k*inner]
+			}
+			for k := 0; k < n; k++ {
+				j := base + k*inner
+				dx[j] = grad[j] - math.Exp(y[j])*sum
+			}
+		}
+	}
+	return floatsValue(dx, shp, dt), nil
+}
+
+func (op logSoftmaxDiffOp) returnsPtr() bool    { return false }
+func (op logSoftmaxDiffOp) callsExtern() bool   { return false }
+func (op logSoftmaxDiffOp) overwriteInput() int { return -1 }
+func (op logSoftmaxDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	logSoftmaxOp(op).WriteHash(h)
+}
+
+func (op logSoftmaxDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op logSoftmaxDiffOp) MarshalBinary() ([]byte, error) {
+	return logSoftmaxOp(op).MarshalBinary()
+}
+
+func (op *logSoftmaxDiffOp) UnmarshalBinary(data []byte) error {
+	return (*logSoftmaxOp)(op).UnmarshalBinary(data)
+}
+
+func (op logSoftmaxDiffOp) String() string { return fmt.Sprintf("∂LogSoftmax(%d)", op.along) }
+
+// softmaxGradOp computes the Jacobian-vector product of the softmax along the last axis, from its output s and the upstream gradient u:
+//		y = s * (u - Σ u*s)
+// It is differentiable wrt both inputs, with the gradient ∂y:
+//		∂u = s * (∂y - Σ ∂y*s)
+//		∂s = ∂y * (u - Σ u*s) - u * Σ ∂y*s
+type softmaxGradOp struct {
+	d int
+}
+
+// softmaxGradOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Tensor d a → Tensor d a
+func (op softmaxGradOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(op.d, a)
+	return newFunctionType(t, t, t)
+}
+
+func (op softmaxGradOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "softmaxGradOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	s, u := inputs[0], inputs[1]
+	if !s.shape.Eq(u.shape) {
+		return nil, errors.Errorf("Expected the softmax output and the upstream gradient to have the same shape. Got %v and %v instead", s.shape, u.shape)
+	}
+	return s.shape.Clone(), nil
+}
+
+// strides splits up the shape as splitAlong does along the last axis. A vector is a single softmax, whichever way it is shaped.
+func (op softmaxGradOp) strides(s types.Shape) (outer, n, inner int) {
+	if op.d == 1 {
+		return 1, s.TotalSize(), 1
+	}
+	return splitAlong(s, op.d-1)
+}
+
+func (op softmaxGradOp) DiffWRT(inputs int) []bool { return []bool{true, true} }
+
+func (op softmaxGradOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "softmaxGradOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	retVal = make(Nodes, 2)
+	for i := range inputs {
+		if retVal[i], err = applyOp(softmaxGradDiffOp{op, i}, inputs[0], inputs[1], gradNode); err != nil {
+			return nil, errors.Wrap(err, applyOpFail)
+		}
+		retVal[i].setGroup(gradClust)
+	}
+	return
+}
+
+func (op softmaxGradOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "softmaxGradOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	odv := output.boundTo.(*dualValue)
+	for i, in := range inputs {
+		diff := softmaxGradDiffOp{op, i}
+		var d Value
+		if d, err = diff.Do(inputs[0].Value(), inputs[1].Value(), odv.d); err != nil {
+			return errors.Wrapf(err, doFail, diff)
+		}
+
+		xdv := in.boundTo.(*dualValue)
+		add := newElemBinOp(addOpType, in, output)
+		if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+			return errors.Wrapf(err, unsafeDoFail, add)
+		}
+	}
+	return
+}
+
+func (op softmaxGradOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "softmaxGradOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var s, u []float64
+	var shp types.Shape
+	var dt Dtype
+	if s, u, shp, dt, err = op.operands(inputs[0], inputs[1]); err != nil {
+		return
+	}
+	outer, n, _ := op.strides(shp)
+
+	y := make([]float64, len(s))
+	for o := 0; o < outer; o++ {
+		row := o * n
+		var dot float64
+		for k := 0; k < n; k++ {
+			dot += u[row+k] * s[row+k]
+		}
+		for k := 0; k < n; k++ {
+			y[row+k] = s[row+k] * (u[row+k] - dot)
+		}
+	}
+	return floatsValue(y, shp, dt), nil
+}
+
+// operands returns the elements of two tensors that are shaped the same
+func (op softmaxGradOp) operands(a, b Value) (x, y []float64, shp types.Shape, dt Dtype, err error) {
+	if x, shp, dt, err = floatsOperand(a); err != nil {
+		return
+	}
+	if y, _, _, err = floatsOperand(b); err != nil {
+		return
+	}
+	if len(y) != len(x) {
+		err = errors.Errorf("Expected a tensor shaped %v. Got %v instead", shp, b.Shape())
+	}
+	return
+}
+
+func (op softmaxGradOp) returnsPtr() bool    { return false }
+func (op softmaxGradOp) callsExtern() bool   { return false }
+func (op softmaxGradOp) overwriteInput() int { return -1 }
+func (op softmaxGradOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("softmaxGrad"))
+	if err := binary.Write(h, binary.LittleEndian, byte(op.d)); err != nil {
+		panic(err)
+	}
+}
+
+func (op softmaxGradOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op softmaxGradOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.d)
+	return w.bytes()
+}
+
+func (op *softmaxGradOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.d = r.readInt()
+	r.checkAxes(op.d)
+	return r.done()
+}
+
+func (op softmaxGradOp) String() string { return "SoftmaxGrad" }
+
+// softmaxGradDiffOp is the derivative of softmaxGradOp wrt one of its inputs. It takes the softmax output, the upstream gradient and the gradient of the output.
+type softmaxGradDiffOp struct {
+	fwd softmaxGradOp
+	wrt int
+}
+
+// softmaxGradDiffOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Tensor d a → Tensor d a → Tensor d a
+func (op softmaxGradDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(op.fwd.d, a)
+	return newFunctionType(t, t, t, t)
+}
+
+func (op softmaxGradDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "softmaxGradDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[op.wrt].shape.Clone(), nil
+}
+
+func (op softmaxGradDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op softmaxGradDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op softmaxGradDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "softmaxGradDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var s, u, grad []float64
+	var shp types.Shape
+	var dt Dtype
+	if s, u, shp, dt, err = op.fwd.operands(inputs[0], inputs[1]); err != nil {
+		return
+	}
+	if _, grad, _, _, err = op.fwd.operands(inputs[0], inputs[2]); err != nil {
+		return
+	}
+	outer, n, _ := op.fwd.strides(shp)
+
+	d := make([]float64, len(s))
+	for o := 0; o < outer; o++ {
+		row := o * n
+		var us, gs float64
+		for k := 0; k < n; k++ {
+			us += u[row+k] * s[row+k]
+			gs += grad[row+k] * s[row+k]
+		}
+		for k := 0; k < n; k++ {
+			j := row + k
+			if op.wrt == 0 {
+				d[j] = grad[j]*(u[j]-us) - u[j]*gs
+			} else {
+				d[j] = s[j] * (grad[j] - gs)
+			}
+		}
+	}
+	return floatsValue(d, shp, dt), nil
+}
+
+func (op softmaxGradDiffOp) returnsPtr() bool    { return false }
+func (op softmaxGradDiffOp) callsExtern() bool   { return false }
+func (op softmaxGradDiffOp) overwriteInput() int { return -1 }
+func (op softmaxGradDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	op.fwd.WriteHash(h)
+	if err := binary.Write(h, binary.LittleEndian, byte(op.wrt)); err != nil {
+		panic(err)
+	}
+}
+
+func (op softmaxGradDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op softmaxGradDiffOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.fwd.d)
+	w.writeInt(op.wrt)
+	return w.bytes()
+}
+
+func (op *softmaxGradDiffOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.fwd.d = r.readInt()
+	op.wrt = r.readInt()
+	r.checkAxes(op.fwd.d)
+	r.checkWRT(op.wrt, 2)
+	return r.done()
+}
+
+func (op softmaxGradDiffOp) String() string { return fmt.Sprintf("∂SoftmaxGrad/∂%d", op.wrt) }
+
+// geluGradOp computes the backward pass of the exact GELU, x × Φ(x) where Φ is the standard normal CDF, from its input x and the upstream gradient u:
+//		y = u × (Φ(x) + x × φ(x))
+// where φ is the standard normal PDF. It is differentiable wrt both inputs, with the gradient ∂y:
+//		∂x = ∂y × u × φ(x) × (2 - x²)
+//		∂u = ∂y × (Φ(x) + x × φ(x))
+type geluGradOp struct{}
+
+// geluGradOp has this type:
+//		op :: (Float a) ⇒ a → a → a
+func (op geluGradOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(a, a, a)
+}
+
+func (op geluGradOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "geluGradOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	x, u := inputs[0], inputs[1]
+	if !x.shape.Eq(u.shape) {
+		return nil, errors.Errorf("Expected the input and the upstream gradient to have the same shape. Got %v and %v instead", x.shape, u.shape)
+	}
+	return x.shape.Clone(), nil
+}
+
+func (op geluGradOp) DiffWRT(inputs int) []bool { return []bool{true, true} }
+
+func (op geluGradOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "geluGradOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	retVal = make(Nodes, 2)
+	for i := range inputs {
+		if retVal[i], err = applyOp(geluGradDiffOp{i}, inputs[0], inputs[1], gradNode); err != nil {
+			return nil, errors.Wrap(err, applyOpFail)
+		}
+		retVal[i].setGroup(gradClust)
+	}
+	return
+}
+
+func (op geluGradOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "geluGradOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	odv := output.boundTo.(*dualValue)
+	for i, in := range inputs {
+		diff := geluGradDiffOp{i}
+		var d Value
+		if d, err = diff.Do(inputs[0].Value(), inputs[1].Value(), odv.d); err != nil {
+			return errors.Wrapf(err, doFail, diff)
+		}
+
+		xdv := in.boundTo.(*dualValue)
+		add := newElemBinOp(addOpType, in, output)
+		if d, err = add.UnsafeDo(xdv.d, d); err != nil {
+			return errors.Wrapf(err, unsafeDoFail, add)
+		}
+
+		// scalars are not added in place
+		if in.IsScalar() {
+			if err = xdv.SetDeriv(d); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func (op geluGradOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "geluGradOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return zipFloats(inputs[0], inputs[1], func(x, u float64) float64 { return u * geluSlope(x) })
+}
+
+// geluSlope is the derivative of the exact GELU, Φ(x) + x × φ(x)
+func geluSlope(x float64) float64 {
+	return 0.5*(1+math.Erf(x/math.Sqrt2)) + x*normalPDF(x)
+}
+
+// normalPDF is φ, the density of the standard normal distribution
+func normalPDF(x float64) float64 {
+	return math.Exp(-0.5*x*x) / math.Sqrt(2*math.Pi)
+}
+
+func (op geluGradOp) returnsPtr() bool      { return false }
+func (op geluGradOp) callsExtern() bool     { return false }
+func (op geluGradOp) overwriteInput() int   { return -1 }
+func (op geluGradOp) WriteHash(h hash.Hash) { h.Write([]byte("geluGrad")) }
+
+func (op geluGradOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op geluGradOp) String() string { return "GELUGrad" }
+
+// geluGradDiffOp is the derivative of geluGradOp wrt one of its inputs. It takes the GELU input, the upstream gradient and the gradient of the output.
+type geluGradDiffOp struct {
+	wrt int
+}
+
+// geluGradDiffOp has this type:
+//		op :: (Float a) ⇒ a → a → a → a
+func (op geluGradDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(a, a, a, a)
+}
+
+func (op geluGradDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "geluGradDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[op.wrt].shape.Clone(), nil
+}
+
+func (op geluGradDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op geluGradDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op geluGradDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "geluGradDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var slope Value
+	if slope, err = zipFloats(inputs[0], inputs[1], func(x, u float64) float64 {
+		if op.wrt == 0 {
+			return u * normalPDF(x) * (2 - x*x)
+		}
+		return geluSlope(x)
+	}); err != nil {
+		return
+	}
+	return zipFloats(slope, inputs[2], func(s, grad float64) float64 { return s * grad })
+}
+
+func (op geluGradDiffOp) returnsPtr() bool    { return false }
+func (op geluGradDiffOp) callsExtern() bool   { return false }
+func (op geluGradDiffOp) overwriteInput() int { return -1 }
+func (op geluGradDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	geluGradOp{}.WriteHash(h)
+	if err := binary.Write(h, binary.LittleEndian, byte(op.wrt)); err != nil {
+		panic(err)
+	}
+}
+
+func (op geluGradDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op geluGradDiffOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.wrt)
+	return w.bytes()
+}
+
+func (op *geluGradDiffOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.wrt = r.readInt()
+	r.checkWRT(op.wrt, 2)
+	return r.done()
+}
+
+func (op geluGradDiffOp) String() string { return fmt.Sprintf("∂GELUGrad/∂%d", op.wrt) }
+
+// l2NormalizeOp scales x to unit L2 length along an axis:
+//		y = x / n, where n = √(Σx² + eps)
+// The epsilon keeps the division finite for a vector of zeros, which is left as zeros. The gradient is the projection
+//		∂x = (∂y - x (x·∂y)/n²) / n
+// which removes the part of ∂y along x, as the length of y cannot change.
+type l2NormalizeOp struct {
+	along int // axis
+	d     int
+	eps   float64
+}
+
+// l2NormalizeOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Tensor d a
+func (op l2NormalizeOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(op.d, a)
+	return newFunctionType(t, t)
+}
+
+func (op l2NormalizeOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "l2NormalizeOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	x := inputs[0]
+	if op.d > 1 && op.along >= len(x.shape) {
+		return nil, errors.Errorf("Cannot normalize along axis %d of a tensor shaped %v", op.along, x.shape)
+	}
+	return x.shape.Clone(), nil
+}
+
+// strides splits up the shape as splitAlong does. A vector is normalized as a whole, whichever way it is shaped.
+func (op l2NormalizeOp) strides(s types.Shape) (outer, n, inner int) {
+	if op.d == 1 {
+		return 1, s.TotalSize(), 1
+	}
+	return splitAlong(s, op.along)
+}
+
+// norms computes √(Σx² + eps) along the axis, one for each vector that is normalized
+func (op l2NormalizeOp) norms(x []float64, shp types.Shape) []float64 {
+	outer, n, inner := op.strides(shp)
+	norms := make([]float64, outer*inner)
+	for o := 0; o < outer; o++ {
+		for i := 0; i < inner; i++ {
+			var sum float64
+			for k := 0; k < n; k++ {
+				v := x[o*n*inner+k*inner+i]
+				sum += v * v
+			}
+			norms[o*inner+i] = math.Sqrt(sum + op.eps)
+		}
+	}
+	return norms
+}
+
+func (op l2NormalizeOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+func (op l2NormalizeOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "l2NormalizeOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(l2NormalizeDiffOp(op), inputs[0], gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op l2NormalizeOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "l2NormalizeOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	diff := l2NormalizeDiffOp(op)
+	var d Value
+	if d, err = diff.Do(xdv.Value, ydv.d); err != nil {
+		return errors.Wrapf(err, doFail, diff)
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], output)
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op l2NormalizeOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "l2NormalizeOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var x []float64
+	var shp types.Shape
+	var dt Dtype
+	if x, shp, dt, err = floatsOperand(inputs[0]); err != nil {
+		return
+	}
+	outer, n, inner := op.strides(shp)
+	norms := op.norms(x, shp)
+
+	y := make([]float64, len(x))
+	for o := 0; o < outer; o++ {
+		for i := 0; i < inner; i++ {
+			norm := norms[o*inner+i]
+			for k := 0; k < n; k++ {
+				j := o*n*inner + k*inner + i
+				y[j] = x[j] / norm
+			}
+		}
+	}
+	return floatsValue(y, shp, dt), nil
+}
+
+func (op l2NormalizeOp) returnsPtr() bool    { return false }
+func (op l2NormalizeOp) callsExtern() bool   { return false }
+func (op l2NormalizeOp) overwriteInput() int { return -1 }
+func (op l2NormalizeOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("l2Normalize"))
+	if err := binary.Write(h, binary.LittleEndian, int64(op.along)); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, byte(op.d)); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, op.eps); err != nil {
+		panic(err)
+	}
+}
+
+func (op l2NormalizeOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op l2NormalizeOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.along)
+	w.writeInt(op.d)
+	w.writeFloat(op.eps)
+	return w.bytes()
+}
+
+func (op *l2NormalizeOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.along = r.readInt()
+	op.d = r.readInt()
+	op.eps = r.readFloat()
+	r.checkAxes(op.d, op.along)
+	r.check(op.eps >= 0, "Expected eps to not be negative. Got %v instead", op.eps)
+	return r.done()
+}
+
+func (op l2NormalizeOp) String() string { return fmt.Sprintf("L2Normalize(%d)", op.along) }
+
+// l2NormalizeDiffOp is the derivative of l2NormalizeOp. It takes x and the gradient of the output.
+type l2NormalizeDiffOp struct {
+	along int
+	d     int
+	eps   float64
+}
+
+// l2NormalizeDiffOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Tensor d a → Tensor d a
+func (op l2NormalizeDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(op.d, a)
+	return newFunctionType(t, t, t)
+}
+
+func (op l2NormalizeDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "l2NormalizeDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op l2NormalizeDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op l2NormalizeDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op l2NormalizeDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "l2NormalizeDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var x, grad []float64
+	var shp types.Shape
+	var dt Dtype
+	if x, shp, dt, err = floatsOperand(inputs[0]); err != nil {
+		return
+	}
+	if grad, _, _, err = floatsOperand(inputs[1]); err != nil {
+		return
+	}
+	if len(grad) != len(x) {
+		return nil, errors.Errorf("Expected the gradient to be shaped %v. Got %v instead", shp, inputs[1].Shape())
+	}
+
+	fwd := l2NormalizeOp(op)
+	outer, n, inner := fwd.strides(shp)
+	norms := fwd.norms(x, shp)
+
+	dx := make([]float64, len(x))
+	for o := 0; o < outer; o++ {
+		for i := 0; i < inner; i++ {
+			norm := norms[o*inner+i]
+			var dot float64
+			for k := 0; k < n; k++ {
+				j := o*n*inner + k*inner + i
+				dot += x[j] * grad[j]
+			}
+			for k := 0; k < n; k++ {
+				j := o*n*inner + k*inner + i
+				dx[j] = (grad[j] - x[j]*dot/(norm*norm)) / norm
+			}
+		}
+	}
+	return floatsValue(dx, shp, dt), nil
+}
+
+func (op l2NormalizeDiffOp) returnsPtr() bool    { return false }
+func (op l2NormalizeDiffOp) callsExtern() bool   { return false }
+func (op l2NormalizeDiffOp) overwriteInput() int { return -1 }
+func (op l2NormalizeDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	l2NormalizeOp(op).WriteHash(h)
+}
+
+func (op l2NormalizeDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op l2NormalizeDiffOp) MarshalBinary() ([]byte, error) {
+	return l2NormalizeOp(op).MarshalBinary()
+}
+
+func (op *l2NormalizeDiffOp) UnmarshalBinary(data []byte) error {
+	return (*l2NormalizeOp)(op).UnmarshalBinary(data)
+}
+
+func (op l2NormalizeDiffOp) String() string { return fmt.Sprintf("∂L2Normalize(%d)", op.along) }
+
+// huberLossOp computes the mean Huber loss of the residuals r = pred - target:
+//		l(r) = ½r²              if |r| ≤ delta
+//		l(r) = delta(|r| - ½delta) otherwise
+// which is quadratic for small residuals and linear for large ones, so that outliers do not dominate the loss. The gradient is the clamped residual,
+//		∂pred = clamp(r, -delta, delta) × ∂y / N
+// and ∂target is its negation, where N is the number of elements.
+type huberLossOp struct {
+	delta float64
+	d     int
+}
+
+// huberLossOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Tensor d a → a
+func (op huberLossOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(op.d, a)
+	return newFunctionType(t, t, a)
+}
+
+func (op huberLossOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "huberLossOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	if !inputs[0].shape.Eq(inputs[1].shape) {
+		return nil, errors.Errorf("Expected pred and target to be of the same shape. Got %v and %v instead", inputs[0].shape, inputs[1].shape)
+	}
+	return scalarShape, nil
+}
+
+func (op huberLossOp) DiffWRT(inputs int) []bool { return []bool{true, true} }
+
+func (op huberLossOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "huberLossOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	retVal = make(Nodes, 2)
+	for i := range retVal {
+		diff := huberLossDiffOp{fwd: op, wrt: i}
+		if retVal[i], err = applyOp(diff, inputs[0], inputs[1], gradNode); err != nil {
+			return nil, errors.Wrap(err, applyOpFail)
+		}
+		retVal[i].setGroup(gradClust)
+	}
+	return
+}
+
+func (op huberLossOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "huberLossOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	ydv := output.boundTo.(*dualValue)
+	for i, in := range inputs {
+		diff := huberLossDiffOp{fwd: op, wrt: i}
+		var d Value
+		if d, err = diff.Do(inputs[0].Value(), inputs[1].Value(), ydv.d); err != nil {
+			return errors.Wrapf(err, doFail, diff)
+		}
+
+		xdv := in.boundTo.(*dualValue)
+		add := newElemBinOp(addOpType, in, in)
+		if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+			return errors.Wrapf(err, unsafeDoFail, add)
+		}
+	}
+	return
+}
+
+func (op huberLossOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "huberLossOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var r []float64
+	var dt Dtype
+	if r, _, dt, err = op.residuals(inputs[0], inputs[1]); err != nil {
+		return
+	}
+
+	var sum float64
+	for _, v := range r {
+		if a := math.Abs(v); a <= op.delta {
+			sum += 0.5 * v * v
+		} else {
+			sum += op.delta * (a - 0.5*op.delta)
+		}
+	}
+	loss := sum / float64(len(r))
+
+	if dt == Float32 {
+		return NewScalarValue(float32(loss)), nil
+	}
+	return NewScalarValue(loss), nil
+}
+
+// residuals returns pred - target, which have to be of the same shape
+func (op huberLossOp) residuals(predV, targetV Value) (r []float64, shp types.Shape, dt Dtype, err error) {
+	var pred, target []float64
+	var tShape types.Shape
+	if pred, shp, dt, err = floatsOperand(predV); err != nil {
+		return
+	}
+	if target, tShape, _, err = floatsOperand(targetV); err != nil {
+		return
+	}
+	if !shp.Eq(tShape) {
+		err = errors.Errorf("Shape mismatch: %v and %v", shp, tShape)
+		return
+	}
+
+	r = make([]float64, len(pred))
+	for i := range pred {
+		r[i] = pred[i] - target[i]
+	}
+	return
+}
+
+func (op huberLossOp) returnsPtr() bool    { return false }
+func (op huberLossOp) callsExtern() bool   { return false }
+func (op huberLossOp) overwriteInput() int { return -1 }
+func (op huberLossOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("huberLoss"))
+	if err := binary.Write(h, binary.LittleEndian, op.delta); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, byte(op.d)); err != nil {
+		panic(err)
+	}
+}
+
+func (op huberLossOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op huberLossOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeFloat(op.delta)
+	w.writeInt(op.d)
+	return w.bytes()
+}
+
+func (op *huberLossOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.delta = r.readFloat()
+	op.d = r.readInt()
+	r.check(op.delta > 0, "Expected a positive delta. Got %v instead", op.delta)
+	r.checkAxes(op.d)
+	return r.done()
+}
+
+func (op huberLossOp) String() string { return fmt.Sprintf("HuberLoss(%v)", op.delta) }
+
+// huberLossDiffOp is the derivative of huberLossOp with regards to either pred (wrt = 0) or target (wrt = 1).
+// The inputs are pred, target and the gradient of the loss.
+type huberLossDiffOp struct {
+	fwd huberLossOp
+	wrt int
+}
+
+// huberLossDiffOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Tensor d a → a → Tensor d a
+func (op huberLossDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(op.fwd.d, a)
+	return newFunctionType(t, t, a, t)
+}
+
+func (op huberLossDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "huberLossDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[op.wrt].shape.Clone(), nil
+}
+
+func (op huberLossDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op huberLossDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op huberLossDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "huberLossDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var r, grad []float64
+	var shp types.Shape
+	var dt Dtype
+	if r, shp, dt, err = op.fwd.residuals(inputs[0], inputs[1]); err != nil {
+		return
+	}
+	if grad, err = reducedFloats(inputs[2], 1); err != nil {
+		return nil, errors.Wrap(err, "huberLossDiffOp.Do()")
+	}
+
+	scale := grad[0] / float64(len(r))
+	if op.wrt == 1 {
+		scale = -scale
+	}
+	d := make([]float64, len(r))
+	for i, v := range r {
+		d[i] = math.Max(-op.fwd.delta, math.Min(v, op.fwd.delta)) * scale
+	}
+	return floatsValue(d, shp, dt), nil
+}
+
+func (op huberLossDiffOp) returnsPtr() bool    { return false }
+func (op huberLossDiffOp) callsExtern() bool   { return false }
+func (op huberLossDiffOp) overwriteInput() int { return -1 }
+func (op huberLossDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	op.fwd.WriteHash(h)
+	if err := binary.Write(h, binary.LittleEndian, byte(op.wrt)); err != nil {
+		panic(err)
+	}
+}
+
+func (op huberLossDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op huberLossDiffOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeFloat(op.fwd.delta)
+	w.writeInt(op.fwd.d)
+	w.writeInt(op.wrt)
+	return w.bytes()
+}
+
+func (op *huberLossDiffOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.fwd.delta = r.readFloat()
+	op.fwd.d = r.readInt()
+	op.wrt = r.readInt()
+	r.check(op.fwd.delta > 0, "Expected a positive delta. Got %v instead", op.fwd.delta)
+	r.checkAxes(op.fwd.d)
+	r.checkWRT(op.wrt, 2)
+	return r.done()
+}
+
+func (op huberLossDiffOp) String() string { return fmt.Sprintf("∂%v/∂%d", op.fwd, op.wrt) }
+
+// floatsOperand returns the elements of a tensor as float64s, along with its shape and Dtype
+func floatsOperand(v Value) (x []float64, shp types.Shape, dt Dtype, err error) {
+	t, ok := v.(Tensor)
+	if !ok {
+		err = errors.Errorf("Expected a Tensor. Got %v of %T instead", v, v)
+		return
+	}
+
+	shp = t.Shape()
+	dt = t.Dtype()
+	switch tt := t.Tensor.(type) {
+	case *tf64.Tensor:
+		x = materializedF64s(tt)
+	case *tf32.Tensor:
+		x = f32sToF64s(materializedF32s(tt))
+	default:
+		err = errors.Errorf(nyiFail, "floatsOperand", t.Tensor)
+	}
+	return
+}
+
+// floatsValue creates a tensor of the given shape and Dtype out of x
+func floatsValue(x []float64, shp types.Shape, dt Dtype) Value {
+	if dt == Float32 {
+		return FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(x)), tf32.WithShape(shp.Clone()...)))
+	}
+	return FromTensor(tf64.NewTensor(tf64.WithBacking(x), tf64.WithShape(shp.Clone()...)))
+}
@@ -0,0 +1,2281 @@
+package gorgonia
+
+import (
+	"math"
+	"testing"
+
+	tf32 "github.com/chewxy/gorgonia/tensor/f32"
+	tf64 "github.com/chewxy/gorgonia/tensor/f64"
+	ti "github.com/chewxy/gorgonia/tensor/i"
+	"github.com/chewxy/gorgonia/tensor/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// lstmCellRef is a naive reference implementation of a LSTM cell step, used to check LSTMCell.
+// The weights are in row major order.
+func lstmCellRef(x, h, c, wx, wh, b []float64) (hNew, cNew []float64) {
+	hidden := len(c)
+	gates := make([]float64, 4*hidden)
+	for r := range gates {
+		gates[r] = b[r]
+		for k, v := range x {
+			gates[r] += wx[r*len(x)+k] * v
+		}
+		for k, v := range h {
+			gates[r] += wh[r*hidden+k] * v
+		}
+	}
+
+	sigmoid := func(a float64) float64 { return 1 / (1 + math.Exp(-a)) }
+	hNew = make([]float64, hidden)
+	cNew = make([]float64, hidden)
+	for j := 0; j < hidden; j++ {
+		i := sigmoid(gates[j])
+		f := sigmoid(gates[hidden+j])
+		o := sigmoid(gates[2*hidden+j])
+		u := math.Tanh(gates[3*hidden+j])
+		cNew[j] = f*c[j] + i*u
+		hNew[j] = o * math.Tanh(cNew[j])
+	}
+	return
+}
+
+// lstmCellRefCost is the cost used in the tests: Σh'² + Σc'
+func lstmCellRefCost(x, h, c, wx, wh, b []float64) (retVal float64) {
+	hNew, cNew := lstmCellRef(x, h, c, wx, wh, b)
+	for i := range hNew {
+		retVal += hNew[i]*hNew[i] + cNew[i]
+	}
+	return
+}
+
+func TestLSTMCellOp(t *testing.T) {
+	assert := assert.New(t)
+
+	gates := []float64{0.1, -0.2, 0.3, -0.4, 0.5, -0.6, 0.7, -0.8, 0.9, -1.0, 1.1, -1.2}
+	c := []float64{0.5, -0.25, 1}
+	grad := []float64{1, -2, 0.5, 0.3, 0.7, -1}
+
+	op := lstmCellOp{}
+	gatesV := FromTensor(tf64.NewTensor(tf64.WithBacking(gates), tf64.WithShape(12)))
+	cV := FromTensor(tf64.NewTensor(tf64.WithBacking(c), tf64.WithShape(3)))
+	gradV := FromTensor(tf64.NewTensor(tf64.WithBacking(grad), tf64.WithShape(6)))
+
+	// forwards: [h'; c']
+	ret, err := op.Do(gatesV, cV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := extractF64s(ret)
+	assert.Equal(6, len(out))
+
+	// cost used for the gradient check is <grad, [h'; c']>
+	cost := func(gates, c []float64) (retVal float64) {
+		o := make([]float64, 2*len(c))
+		lstmCellFwdf64(gates, c, o)
+		for i := range o {
+			retVal += o[i] * grad[i]
+		}
+		return
+	}
+
+	var dGates, dC Value
+	if dGates, err = (lstmCellDiffOp{wrt: 0}).Do(gatesV, cV, gradV); err != nil {
+		t.Fatal(err)
+	}
+	if dC, err = (lstmCellDiffOp{wrt: 1}).Do(gatesV, cV, gradV); err != nil {
+		t.Fatal(err)
+	}
+
+	correctDGates := numericGrad(gates, func() float64 { return cost(gates, c) })
+	correctDC := numericGrad(c, func() float64 { return cost(gates, c) })
+	assert.True(floatsClose(correctDGates, extractF64s(dGates)), "dGates: expected %v. Got %v", correctDGates, dGates)
+	assert.True(floatsClose(correctDC, extractF64s(dC)), "dC: expected %v. Got %v", correctDC, dC)
+}
+
+func TestLSTMCell(t *testing.T) {
+	assert := assert.New(t)
+
+	xs := []float64{0.5, -1}
+	hs := []float64{0.1, 0.2, -0.3}
+	cs := []float64{1, -0.5, 0.25}
+	wxs := []float64{
+		0.1, 0.2, -0.3, 0.4, 0.5, -0.6,
+		0.7, -0.8, 0.9, 0.1, -0.2, 0.3,
+		-0.4, 0.5, 0.6, -0.7, 0.8, 0.9,
+		-0.1, -0.2, 0.3, 0.4, -0.5, 0.6,
+	}
+	whs := []float64{
+		0.3, -0.1, 0.2, 0.05, 0.4, -0.3,
+		-0.2, 0.1, 0.6, 0.2, -0.5, 0.3,
+		0.1, 0.1, -0.1, 0.4, 0.3, -0.2,
+		0.2, -0.4, 0.1, 0.3, 0.2, 0.1,
+		-0.3, 0.2, 0.2, 0.1, -0.1, 0.5,
+		0.4, 0.3, -0.2, 0.1, 0.2, -0.6,
+	}
+	bs := []float64{0.1, -0.1, 0.2, 0.3, -0.2, 0.1, 0, 0.05, -0.05, 0.2, 0.1, -0.3}
+
+	correctH, correctC := lstmCellRef(xs, hs, cs, wxs, whs, bs)
+	refCost := func() float64 { return lstmCellRefCost(xs, hs, cs, wxs, whs, bs) }
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		x := NewVector(g, Float64, WithName("x"), WithShape(2, 1), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(2, 1))))
+		h := NewVector(g, Float64, WithName("h"), WithShape(3, 1), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(hs)), tf64.WithShape(3, 1))))
+		c := NewVector(g, Float64, WithName("c"), WithShape(3, 1), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(cs)), tf64.WithShape(3, 1))))
+		wx := NewMatrix(g, Float64, WithName("wx"), WithShape(12, 2), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(wxs)), tf64.WithShape(12, 2))))
+		wh := NewMatrix(g, Float64, WithName("wh"), WithShape(12, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(whs)), tf64.WithShape(12, 3))))
+		b := NewVector(g, Float64, WithName("b"), WithShape(12, 1), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(bs)), tf64.WithShape(12, 1))))
+
+		hNew, cNew, err := LSTMCell(x, h, c, wx, wh, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cost := Must(Add(Must(Sum(Must(Square(hNew)))), Must(Sum(cNew))))
+		if useTape {
+			if _, err = Grad(cost, x, h, c, wx, wh, b); err != nil {
+				t.Fatal(err)
+			}
+
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.True(floatsClose(correctH, extractF64s(hNew.Value())), "h': expected %v. Got %v", correctH, hNew.Value())
+		assert.True(floatsClose(correctC, extractF64s(cNew.Value())), "c': expected %v. Got %v", correctC, cNew.Value())
+
+		for _, wrt := range []struct {
+			n       *Node
+			backing []float64
+		}{{x, xs}, {h, hs}, {c, cs}, {wx, wxs}, {wh, whs}, {b, bs}} {
+			correct := numericGrad(wrt.backing, refCost)
+			grad, err := wrt.n.Grad()
+			if err != nil {
+				t.Errorf("%v: %v", wrt.n, err)
+				continue
+			}
+			assert.True(floatsClose(correct, extractF64s(grad)), "Tape %t. Gradient of %v: expected %v. Got %v", useTape, wrt.n, correct, grad)
+		}
+	}
+}
+
+// numericGrad computes the central difference gradient of the cost wrt all the elements of xs. xs is perturbed in place, and restored
+func numericGrad(xs []float64, cost func() float64) []float64 {
+	const h = 1e-6
+	retVal := make([]float64, len(xs))
+	for i := range xs {
+		orig := xs[i]
+		xs[i] = orig + h
+		plus := cost()
+		xs[i] = orig - h
+		minus := cost()
+		xs[i] = orig
+		retVal[i] = (plus - minus) / (2 * h)
+	}
+	return retVal
+}
+
+func floatsClose(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, v := range a {
+		if math.Abs(v-b[i]) > 1e-6 {
+			return false
+		}
+	}
+	return true
+}
+
+func clonef64s(a []float64) []float64 {
+	retVal := make([]float64, len(a))
+	copy(retVal, a)
+	return retVal
+}
+
+func TestPositionalEncoding(t *testing.T) {
+	assert := assert.New(t)
+
+	pe, err := PositionalEncoding(4, 6, Float64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(pe.isConstant())
+	assert.Equal(types.Shape{4, 6}, pe.Shape())
+
+	data := extractF64s(pe.Value())
+
+	// position 0 alternates between sin(0) and cos(0)
+	assert.True(floatsEqual([]float64{0, 1, 0, 1, 0, 1}, data[0:6]))
+
+	// position 1 and 3
+	for _, pos := range []float64{1, 3} {
+		correct := []float64{
+			math.Sin(pos), math.Cos(pos),
+			math.Sin(pos / math.Pow(10000, 2.0/6.0)), math.Cos(pos / math.Pow(10000, 2.0/6.0)),
+			math.Sin(pos / math.Pow(10000, 4.0/6.0)), math.Cos(pos / math.Pow(10000, 4.0/6.0)),
+		}
+		p := int(pos)
+		assert.True(floatsEqual(correct, data[p*6:p*6+6]), "Position %v. Expected %v. Got %v", pos, correct, data[p*6:p*6+6])
+	}
+
+	// using it in a graph. The encodings are not differentiable, but x still is.
+	g := NewGraph()
+	x := NewMatrix(g, Float64, WithName("x"), WithShape(4, 6), WithInit(Zeroes()))
+	cost := Must(Sum(Must(Add(x, pe))))
+	grads, err := Grad(cost, x)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prog, locMap, err := Compile(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewTapeMachine(prog, locMap)
+	if err = m.RunAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	var sum float64
+	for _, v := range data {
+		sum += v
+	}
+	assert.True(floatEquals(sum, extractF64(cost.Value())))
+	assert.True(floatsEqual(extractF64s(ones(Float64, 4, 6)), extractF64s(grads[0].Value())))
+
+	// bad dimensions
+	_, err = PositionalEncoding(0, 6, Float64)
+	assert.NotNil(err)
+}
+
+// ropeRef is a naive reference implementation of RoPE on a (batch, heads, seq, dim) tensor in row major order.
+func ropeRef(x []float64, seq, dim, offset int) []float64 {
+	retVal := make([]float64, len(x))
+	for row := 0; row < len(x)/dim; row++ {
+		pos := row%seq + offset
+		for i := 0; i < dim; i += 2 {
+			theta := float64(pos) * math.Pow(10000, -float64(i)/float64(dim))
+			retVal[row*dim+i] = x[row*dim+i]*math.Cos(theta) - x[row*dim+i+1]*math.Sin(theta)
+			retVal[row*dim+i+1] = x[row*dim+i]*math.Sin(theta) + x[row*dim+i+1]*math.Cos(theta)
+		}
+	}
+	return retVal
+}
+
+func TestRoPE(t *testing.T) {
+	assert := assert.New(t)
+
+	shape := []int{1, 2, 3, 4}
+	offset := 2
+	xs := []float64{
+		0.1, 0.2, 0.3, 0.4, -0.5, 0.6, 0.7, -0.8, 0.9, 1.0, -1.1, 1.2,
+		1.3, -1.4, 1.5, 1.6, -1.7, 1.8, 1.9, 2.0, 2.1, -2.2, 2.3, 2.4,
+	}
+	grad := make([]float64, len(xs))
+	for i := range grad {
+		grad[i] = float64(i%5) - 1.5
+	}
+	correct := ropeRef(xs, 3, 4, offset)
+
+	// RoPE is orthogonal, so the norm is preserved
+	var n0, n1 float64
+	for i := range xs {
+		n0 += xs[i] * xs[i]
+		n1 += correct[i] * correct[i]
+	}
+	assert.True(floatEquals(n0, n1))
+
+	// forwards
+	g := NewGraph()
+	x := NewTensor(g, Float64, 4, WithName("x"), WithShape(shape...), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(shape...))))
+	rotated, err := RoPE(x, offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(types.Shape(shape), rotated.Shape())
+
+	prog, locMap, err := Compile(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewTapeMachine(prog, locMap)
+	if err = m.RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(floatsClose(correct, extractF64s(rotated.Value())), "Expected %v. Got %v", correct, rotated.Value())
+
+	// the symbolic gradient is the inverse rotation of the gradient
+	gradNode := NewTensor(g, Float64, 4, WithName("grad"), WithShape(shape...))
+	diffs, err := rotated.op.SymDiff(Nodes{x}, rotated, gradNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(ropeOp{offset: offset, inverse: true}, diffs[0].op)
+
+	// the gradient of <grad, RoPE(x)> wrt x
+	gradV := FromTensor(tf64.NewTensor(tf64.WithBacking(grad), tf64.WithShape(shape...)))
+	dx, err := (ropeOp{offset: offset, inverse: true}).Do(gradV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	correctDX := numericGrad(xs, func() (retVal float64) {
+		for i, v := range ropeRef(xs, 3, 4, offset) {
+			retVal += v * grad[i]
+		}
+		return
+	})
+	assert.True(floatsClose(correctDX, extractF64s(dx)), "dx: expected %v. Got %v", correctDX, dx)
+
+	// rotating and then rotating back is the identity
+	x32 := FromTensor(tf32.NewTensor(tf32.WithBacking([]float32{1, 2, 3, 4, 5, 6, 7, 8}), tf32.WithShape(1, 1, 2, 4)))
+	r32, err := (ropeOp{offset: 3}).Do(x32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r32, err = (ropeOp{offset: 3, inverse: true}).Do(r32); err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range r32.(Tensor).Tensor.(*tf32.Tensor).Data().([]float32) {
+		assert.InDelta(float32(i+1), v, 1e-5)
+	}
+
+	// odd dims are not allowed
+	x = NewTensor(g, Float64, 4, WithName("odd"), WithShape(1, 1, 2, 3))
+	if _, err := RoPE(x, 0); err == nil {
+		t.Error("Expected an error for an odd dim")
+	}
+}
+
+func TestSplitMergeHeads(t *testing.T) {
+	assert := assert.New(t)
+
+	batch, seq, dModel, heads := 2, 3, 8, 4
+	headDim := dModel / heads
+
+	xs := make([]float64, batch*seq*dModel)
+	for i := range xs {
+		xs[i] = float64(i)
+	}
+	ws := make([]float64, len(xs))
+	for i := range ws {
+		ws[i] = float64(i%7) - 3
+	}
+
+	// splitRef maps the (b, s, h*headDim + k) element to (b, h, s, k)
+	splitRef := func(a []float64) []float64 {
+		retVal := make([]float64, len(a))
+		for b := 0; b < batch; b++ {
+			for s := 0; s < seq; s++ {
+				for h := 0; h < heads; h++ {
+					for k := 0; k < headDim; k++ {
+						retVal[((b*heads+h)*seq+s)*headDim+k] = a[(b*seq+s)*dModel+h*headDim+k]
+					}
+				}
+			}
+		}
+		return retVal
+	}
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		x := NewTensor(g, Float64, 3, WithName("x"), WithShape(batch, seq, dModel), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(batch, seq, dModel))))
+		w := NewTensor(g, Float64, 4, WithName("w"), WithShape(batch, heads, seq, headDim), WithValue(tf64.NewTensor(tf64.WithBacking(splitRef(ws)), tf64.WithShape(batch, heads, seq, headDim))))
+
+		split, err := SplitHeads(x, heads)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(types.Shape{batch, heads, seq, headDim}, split.Shape())
+
+		merged, err := MergeHeads(Must(HadamardProd(split, w)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(types.Shape{batch, seq, dModel}, merged.Shape())
+
+		flat := Must(Reshape(merged, types.Shape{batch * seq, dModel}))
+		cost := Must(Sum(flat))
+
+		if useTape {
+			if _, err = Grad(cost, x); err != nil {
+				t.Fatal(err)
+			}
+
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// the transpose is lazy, so the value has to be materialized before comparing
+		splitT := split.Value().(Tensor).Tensor.Materialize()
+		assert.Equal(splitRef(xs), splitT.Data(), "Tape %t", useTape)
+
+		var correctCost float64
+		correctMerged := make([]float64, len(xs))
+		for i := range xs {
+			correctMerged[i] = xs[i] * ws[i]
+			correctCost += correctMerged[i]
+		}
+		assert.Equal(correctMerged, extractF64s(merged.Value()), "Tape %t", useTape)
+		assert.True(floatEquals(correctCost, extractF64(cost.Value())), "Tape %t. Expected %v. Got %v", useTape, correctCost, cost.Value())
+
+		// the gradient wrt x is simply w, in the layout of x
+		grad, err := x.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(types.Shape{batch, seq, dModel}, grad.Shape())
+		assert.True(floatsClose(ws, extractF64s(grad)), "Tape %t. Expected %v. Got %v", useTape, ws, grad)
+	}
+
+	// dModel has to be divisible by the number of heads
+	g := NewGraph()
+	x := NewTensor(g, Float64, 3, WithName("x"), WithShape(1, 2, 6))
+	if _, err := SplitHeads(x, 4); err == nil {
+		t.Error("Expected an error when dModel is not divisible by the number of heads")
+	}
+}
+
+func TestMoEGate(t *testing.T) {
+	assert := assert.New(t)
+
+	tokens, experts, k := 3, 4, 2
+	logits := []float64{
+		0.5, 2, -1, 1,
+		3, 0.1, 0.2, 2.5,
+		-1, -2, 1, 1, // tie, so the lower index wins
+	}
+	grad := []float64{
+		1, -2, 0.5, 3,
+		0.3, 2, -1, 0.7,
+		2, 1, -0.5, 0.25,
+	}
+	correctIndices := []int{1, 3, 0, 3, 2, 3}
+
+	gateRef := func() []float64 {
+		retVal := make([]float64, len(logits))
+		for r := 0; r < tokens; r++ {
+			i, j := correctIndices[r*k], correctIndices[r*k+1]
+			a, b := logits[r*experts+i], logits[r*experts+j]
+			retVal[r*experts+i] = math.Exp(a) / (math.Exp(a) + math.Exp(b))
+			retVal[r*experts+j] = math.Exp(b) / (math.Exp(a) + math.Exp(b))
+		}
+		return retVal
+	}
+	refCost := func() (retVal float64) {
+		for i, w := range gateRef() {
+			retVal += w * grad[i]
+		}
+		return
+	}
+	correct := gateRef()
+	correctGrad := numericGrad(logits, refCost)
+
+	g := NewGraph()
+	x := NewMatrix(g, Float64, WithName("logits"), WithShape(tokens, experts), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(logits)), tf64.WithShape(tokens, experts))))
+	gr := NewMatrix(g, Float64, WithName("grad"), WithShape(tokens, experts), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(grad)), tf64.WithShape(tokens, experts))))
+	weights, indices, err := MoEGate(x, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(types.Shape{tokens, experts}, weights.Shape())
+	assert.Equal(types.Shape{tokens, k}, indices.Shape())
+
+	cost := Must(Sum(Must(Sum(Must(HadamardProd(weights, gr)), 1))))
+	if _, err = Grad(cost, x); err != nil {
+		t.Fatal(err)
+	}
+
+	prog, locMap, err := Compile(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewTapeMachine(prog, locMap)
+	if err = m.RunAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	ws := extractF64s(weights.Value())
+	assert.True(floatsClose(correct, ws), "Expected %v. Got %v", correct, ws)
+	assert.Equal(correctIndices, indices.Value().(Tensor).Tensor.Data())
+	for r := 0; r < tokens; r++ {
+		var nonzero int
+		var sum float64
+		for _, w := range ws[r*experts : (r+1)*experts] {
+			if w != 0 {
+				nonzero++
+			}
+			sum += w
+		}
+		assert.Equal(k, nonzero, "token %d", r)
+		assert.True(floatEquals(1, sum), "token %d", r)
+	}
+
+	// the gradient is only routed to the selected experts
+	dx, err := x.Grad()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dxs := extractF64s(dx)
+	assert.True(floatsClose(correctGrad, dxs), "Expected %v. Got %v", correctGrad, dxs)
+	for i, w := range ws {
+		if w == 0 {
+			assert.Equal(0.0, dxs[i], "unselected expert %d got a gradient", i)
+		}
+	}
+
+	// k larger than the number of experts
+	if _, _, err = MoEGate(x, experts+1); err == nil {
+		t.Error("Expected an error when k > experts")
+	}
+}
+
+func TestMoELoadBalanceLoss(t *testing.T) {
+	assert := assert.New(t)
+
+	tokens, experts := 4, 3
+	probs := []float64{
+		0.7, 0.2, 0.1,
+		0.1, 0.3, 0.6,
+		0.5, 0.4, 0.1,
+		0.2, 0.6, 0.2,
+	}
+	assignments := []int{0, 2, 0, 1}
+
+	refCost := func() (retVal float64) {
+		for i := 0; i < experts; i++ {
+			var f, p float64
+			for t, a := range assignments {
+				if a == i {
+					f++
+				}
+				p += probs[t*experts+i]
+			}
+			retVal += f / float64(len(assignments)) * p / float64(tokens)
+		}
+		return retVal * float64(experts)
+	}
+	correct := refCost()
+	correctGrad := numericGrad(probs, refCost)
+
+	g := NewGraph()
+	p := NewMatrix(g, Float64, WithName("p"), WithShape(tokens, experts), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(probs)), tf64.WithShape(tokens, experts))))
+	a := NewVector(g, Int, WithName("a"), WithShape(tokens), WithValue(ti.NewTensor(ti.WithBacking(assignments), ti.WithShape(tokens))))
+	loss, err := MoELoadBalanceLoss(p, a, experts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(loss.IsScalar())
+
+	if _, err = Grad(loss, p); err != nil {
+		t.Fatal(err)
+	}
+	prog, locMap, err := Compile(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewTapeMachine(prog, locMap)
+	if err = m.RunAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(floatEquals(correct, extractF64(loss.Value())), "Expected %v. Got %v", correct, loss.Value())
+	dp, err := p.Grad()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(floatsClose(correctGrad, extractF64s(dp)), "Expected %v. Got %v", correctGrad, dp)
+
+	// perfectly balanced routing with uniform probabilities has a loss of 1
+	op := loadBalanceLossOp{numExperts: 2, assignDims: 2}
+	uniform := FromTensor(tf64.NewTensor(tf64.WithBacking([]float64{0.5, 0.5, 0.5, 0.5}), tf64.WithShape(2, 2)))
+	top2 := FromTensor(ti.NewTensor(ti.WithBacking([]int{0, 1, 1, 0}), ti.WithShape(2, 2)))
+	v, err := op.Do(uniform, top2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(floatEquals(1, extractF64(v)))
+
+	// out of range assignments
+	bad := FromTensor(ti.NewTensor(ti.WithBacking([]int{0, 3}), ti.WithShape(2)))
+	_, err = op.Do(uniform, bad)
+	assert.NotNil(err)
+}
+
+// arcFaceRef is a naive reference implementation of the ArcFace loss
+func arcFaceRef(f, w []float64, targets []int, dim int, margin, scale float64) (retVal float64) {
+	classes := len(w) / dim
+	for i, y := range targets {
+		z := make([]float64, classes)
+		var sum float64
+		for j := range z {
+			var c float64
+			for k := 0; k < dim; k++ {
+				c += f[i*dim+k] * w[j*dim+k]
+			}
+			if j == y {
+				c = math.Cos(math.Acos(c) + margin)
+			}
+			z[j] = scale * c
+			sum += math.Exp(z[j])
+		}
+		retVal += math.Log(sum) - z[y]
+	}
+	return retVal / float64(len(targets))
+}
+
+func TestArcFace(t *testing.T) {
+	assert := assert.New(t)
+
+	normalize := func(a []float64, dim int) []float64 {
+		for i := 0; i < len(a); i += dim {
+			var n float64
+			for _, v := range a[i : i+dim] {
+				n += v * v
+			}
+			n = math.Sqrt(n)
+			for j := i; j < i+dim; j++ {
+				a[j] /= n
+			}
+		}
+		return a
+	}
+
+	dim, margin, scale := 3, 0.3, 4.0
+	fs := normalize([]float64{1, 2, -0.5, -1, 0.5, 1}, dim)
+	ws := normalize([]float64{1, 1, 0, 0, -1, 1, -1, 0.2, 0.3}, dim)
+	targets := []int{0, 2}
+
+	refCost := func() float64 { return arcFaceRef(fs, ws, targets, dim, margin, scale) }
+	correct := refCost()
+	correctDF := numericGrad(fs, refCost)
+	correctDW := numericGrad(ws, refCost)
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		f := NewMatrix(g, Float64, WithName("f"), WithShape(2, dim), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(fs)), tf64.WithShape(2, dim))))
+		w := NewMatrix(g, Float64, WithName("w"), WithShape(3, dim), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(ws)), tf64.WithShape(3, dim))))
+		y := NewVector(g, Int, WithName("y"), WithShape(2), WithValue(ti.NewTensor(ti.WithBacking(targets), ti.WithShape(2))))
+
+		loss, err := ArcFace(f, w, y, margin, scale)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(loss.IsScalar())
+
+		if useTape {
+			if _, err = Grad(loss, f, w); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.True(floatEquals(correct, extractF64(loss.Value())), "Tape %t. Expected %v. Got %v", useTape, correct, loss.Value())
+
+		df, err := f.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dw, err := w.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(correctDF, extractF64s(df)), "Tape %t. ∂f: expected %v. Got %v", useTape, correctDF, df)
+		assert.True(floatsClose(correctDW, extractF64s(dw)), "Tape %t. ∂w: expected %v. Got %v", useTape, correctDW, dw)
+	}
+
+	// with no margin it is the plain softmax cross entropy of the scaled cosines, and the margin makes the loss larger
+	fv := FromTensor(tf64.NewTensor(tf64.WithBacking(fs), tf64.WithShape(2, dim)))
+	wv := FromTensor(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(3, dim)))
+	yv := FromTensor(ti.NewTensor(ti.WithBacking(targets), ti.WithShape(2)))
+	plain, err := (arcFaceOp{scale: scale}).Do(fv, wv, yv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(floatEquals(arcFaceRef(fs, ws, targets, dim, 0, scale), extractF64(plain)))
+	assert.True(correct > extractF64(plain))
+}
+
+func TestCenterLoss(t *testing.T) {
+	assert := assert.New(t)
+
+	xs := []float64{
+		1, 2,
+		-1, 0.5,
+		3, 1,
+	}
+	labels := []int{0, 1, 0}
+	centers := []float64{
+		0.5, 1,
+		-1, -1,
+	}
+	alpha := 0.5
+
+	refCost := func() (retVal float64) {
+		for i, y := range labels {
+			for k := 0; k < 2; k++ {
+				d := xs[i*2+k] - centers[y*2+k]
+				retVal += d * d
+			}
+		}
+		return retVal / 2
+	}
+	correct := refCost()
+	correctGrad := numericGrad(xs, refCost)
+
+	// c(0) has 2 samples, c(1) has 1
+	correctCenters := []float64{
+		0.5 - alpha*((0.5-1)+(0.5-3))/3, 1 - alpha*((1-2)+(1-1))/3,
+		-1 - alpha*(-1+1)/2, -1 - alpha*(-1-0.5)/2,
+	}
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		x := NewMatrix(g, Float64, WithName("x"), WithShape(3, 2), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(3, 2))))
+		y := NewVector(g, Int, WithName("y"), WithShape(3), WithValue(ti.NewTensor(ti.WithBacking(labels), ti.WithShape(3))))
+
+		loss, err := CenterLoss(x, y, 2, alpha)
+		if err != nil {
+			t.Fatal(err)
+		}
+		op := loss.op.(centerLossOp)
+		assert.Equal(make([]float64, 4), op.centers)
+		copy(op.centers, centers)
+
+		if useTape {
+			if _, err = Grad(loss, x); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.True(floatEquals(correct, extractF64(loss.Value())), "Tape %t. Expected %v. Got %v", useTape, correct, loss.Value())
+		dx, err := x.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(correctGrad, extractF64s(dx)), "Tape %t. Expected %v. Got %v", useTape, correctGrad, dx)
+
+		// the centers are updated after the gradient has been computed
+		assert.True(floatsClose(correctCenters, op.centers), "Tape %t. Expected %v. Got %v", useTape, correctCenters, op.centers)
+	}
+
+	// without computing the gradient the centers are left alone
+	g := NewGraph()
+	x := NewMatrix(g, Float64, WithName("x"), WithShape(3, 2), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(3, 2))))
+	y := NewVector(g, Int, WithName("y"), WithShape(3), WithValue(ti.NewTensor(ti.WithBacking(labels), ti.WithShape(3))))
+	loss := Must(CenterLoss(x, y, 2, alpha))
+	op := loss.op.(centerLossOp)
+	copy(op.centers, centers)
+
+	prog, locMap, err := Compile(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewTapeMachine(prog, locMap)
+	if err = m.RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(floatEquals(correct, extractF64(loss.Value())))
+	assert.Equal(centers, op.centers)
+
+	// two center losses never share their centers
+	other := Must(CenterLoss(x, y, 2, alpha))
+	assert.True(loss != other)
+}
+
+func TestRunningStats(t *testing.T) {
+	assert := assert.New(t)
+
+	xs := []float64{
+		1, 2, -1,
+		3, 0.5, -2,
+		-1, 4, 0,
+		2, 1.5, 5,
+	}
+	ws := []float64{
+		1, -1, 2,
+		0.5, 3, -2,
+		-1, 1, 1,
+		2, 0.5, -0.5,
+	}
+	xs2 := []float64{
+		0, 1, 2,
+		-2, 3, 1,
+		4, 0, -3,
+		1, 1, 1,
+	}
+	const batch, features = 4, 3
+	const momentum, eps = 0.25, 1e-5
+
+	// moments are the mean and variance of each column of x
+	moments := func(x []float64) (mean, variance []float64) {
+		mean = make([]float64, features)
+		variance = make([]float64, features)
+		for i, v := range x {
+			mean[i%features] += v / batch
+		}
+		for i, v := range x {
+			d := v - mean[i%features]
+			variance[i%features] += d * d / batch
+		}
+		return
+	}
+	// normalize normalizes each column of x with the given statistics, or those of x if they are nil
+	normalize := func(x, mean, variance []float64) []float64 {
+		if mean == nil {
+			mean, variance = moments(x)
+		}
+		retVal := make([]float64, len(x))
+		for i, v := range x {
+			retVal[i] = (v - mean[i%features]) / math.Sqrt(variance[i%features]+eps)
+		}
+		return retVal
+	}
+	// cost = Σ w × normalize(x)
+	cost := func(x []float64) (retVal float64) {
+		for i, v := range normalize(x, nil, nil) {
+			retVal += ws[i] * v
+		}
+		return
+	}
+	cxs := clonef64s(xs)
+	correctDX := numericGrad(cxs, func() float64 { return cost(cxs) })
+	batchMean, batchVar := moments(xs)
+
+	build := func() (g *ExprGraph, x, y, c *Node, stats *BatchNormStats) {
+		g = NewGraph()
+		x = NewMatrix(g, Float64, WithName("x"), WithShape(batch, features), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(batch, features))))
+		w := NewMatrix(g, Float64, WithName("w"), WithShape(batch, features), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(batch, features))))
+
+		var err error
+		if y, stats, err = RunningStats(x, momentum, eps); err != nil {
+			t.Fatal(err)
+		}
+		assert.True(stats.Training())
+		assert.Equal(types.Shape{batch, features}, y.Shape())
+		c = Must(Sum(Must(HadamardProd(y, w))))
+		return
+	}
+
+	// a single training step
+	for _, useTape := range []bool{true, false} {
+		g, x, y, c, stats := build()
+		var yV Value
+		Read(y, &yV)
+		if useTape {
+			if _, err := Grad(c, x); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = NewTapeMachine(prog, locMap).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			if err := NewLispMachine(g).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		correct := normalize(xs, nil, nil)
+		assert.True(floatsClose(correct, extractF64s(yV)), "Tape %t. Expected %v. Got %v", useTape, correct, yV)
+		dx, err := x.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(correctDX, extractF64s(dx)), "Tape %t. Expected %v. Got %v", useTape, correctDX, dx)
+
+		correctMean := make([]float64, features)
+		correctVar := make([]float64, features)
+		for k := range correctMean {
+			correctMean[k] = momentum * batchMean[k]
+			correctVar[k] = (1 - momentum) + momentum*batchVar[k]
+		}
+		assert.True(floatsClose(correctMean, stats.Mean()), "Tape %t. Expected %v. Got %v", useTape, correctMean, stats.Mean())
+		assert.True(floatsClose(correctVar, stats.Variance()), "Tape %t. Expected %v. Got %v", useTape, correctVar, stats.Variance())
+	}
+
+	// training on the same batch over and over, the running statistics converge to those of the batch
+	g, x, y, c, stats := build()
+	var yV Value
+	Read(y, &yV)
+	if _, err := Grad(c, x); err != nil {
+		t.Fatal(err)
+	}
+	prog, locMap, err := Compile(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewTapeMachine(prog, locMap)
+	for i := 0; i < 100; i++ {
+		if err = m.RunAll(); err != nil {
+			t.Fatal(err)
+		}
+		m.Reset()
+	}
+	assert.True(floatsClose(batchMean, stats.Mean()), "Expected %v. Got %v", batchMean, stats.Mean())
+	assert.True(floatsClose(batchVar, stats.Variance()), "Expected %v. Got %v", batchVar, stats.Variance())
+
+	// inference uses the running statistics, whatever the batch, and leaves them alone
+	stats.SetTraining(false)
+	runMean, runVar := stats.Mean(), stats.Variance()
+	if err = Let(x, tf64.NewTensor(tf64.WithBacking(clonef64s(xs2)), tf64.WithShape(batch, features))); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	correct := normalize(xs2, runMean, runVar)
+	assert.True(floatsClose(correct, extractF64s(yV)), "Expected %v. Got %v", correct, yV)
+	assert.Equal(runMean, stats.Mean())
+	assert.Equal(runVar, stats.Variance())
+
+	// which makes it a fixed affine transform
+	dx, err := x.Grad()
+	if err != nil {
+		t.Fatal(err)
+	}
+	correctDX = make([]float64, len(ws))
+	for i, w := range ws {
+		correctDX[i] = w / math.Sqrt(runVar[i%features]+eps)
+	}
+	assert.True(floatsClose(correctDX, extractF64s(dx)), "Expected %v. Got %v", correctDX, dx)
+
+	// two running stats never share their statistics
+	_, other, err := RunningStats(x, momentum, eps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(other != stats)
+
+	if _, _, err = RunningStats(NewVector(g, Float64, WithName("v"), WithShape(3)), momentum, eps); err == nil {
+		t.Error("Expected an error with a vector")
+	}
+	if _, _, err = RunningStats(x, 1.5, eps); err == nil {
+		t.Error("Expected an error with a momentum greater than 1")
+	}
+}
+
+// ntxentRef is the naive NT-Xent, with the ith and the (i+n/2)th embeddings paired up
+func ntxentRef(x []float64, n, dim int, temperature float64) (retVal float64) {
+	sim := func(i, k int) float64 {
+		var dot, ni, nk float64
+		for j := 0; j < dim; j++ {
+			dot += x[i*dim+j] * x[k*dim+j]
+			ni += x[i*dim+j] * x[i*dim+j]
+			nk += x[k*dim+j] * x[k*dim+j]
+		}
+		return dot / math.Sqrt(ni*nk) / temperature
+	}
+
+	for i := 0; i < n; i++ {
+		var sum float64
+		for k := 0; k < n; k++ {
+			if k != i {
+				sum += math.Exp(sim(i, k))
+			}
+		}
+		retVal -= math.Log(math.Exp(sim(i, (i+n/2)%n)) / sum)
+	}
+	return retVal / float64(n)
+}
+
+func TestNTXent(t *testing.T) {
+	assert := assert.New(t)
+
+	n, dim, temperature := 4, 3, 0.5
+	xs := []float64{
+		1, 2, -0.5,
+		-1, 0.5, 1,
+		1.2, 1.5, 0,
+		-0.5, 1, 2,
+	}
+
+	refCost := func() float64 { return ntxentRef(xs, n, dim, temperature) }
+	correct := refCost()
+	correctGrad := numericGrad(xs, refCost)
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		x := NewMatrix(g, Float64, WithName("x"), WithShape(n, dim), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(n, dim))))
+
+		loss, err := NTXent(x, temperature)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(loss.IsScalar())
+
+		if useTape {
+			if _, err = Grad(loss, x); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.True(floatEquals(correct, extractF64(loss.Value())), "Tape %t. Expected %v. Got %v", useTape, correct, loss.Value())
+
+		dx, err := x.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(correctGrad, extractF64s(dx)), "Tape %t. Expected %v. Got %v", useTape, correctGrad, dx)
+	}
+
+	// the loss is invariant to the scale of the embeddings
+	scaled := make([]float64, len(xs))
+	for i, v := range xs {
+		scaled[i] = 3 * v
+	}
+	v, err := (ntxentOp{temperature}).Do(FromTensor(tf64.NewTensor(tf64.WithBacking(scaled), tf64.WithShape(n, dim))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(floatEquals(correct, extractF64(v)))
+
+	// float32
+	v, err = (ntxentOp{temperature}).Do(FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(xs)), tf32.WithShape(n, dim))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.InDelta(correct, float64(v.Data().(float32)), 1e-5)
+
+	// an odd number of embeddings cannot be paired up
+	g := NewGraph()
+	odd := NewMatrix(g, Float64, WithName("odd"), WithShape(3, dim))
+	if _, err = NTXent(odd, temperature); err == nil {
+		t.Error("Expected an error with an odd number of embeddings")
+	}
+}
+
+func TestRegularization(t *testing.T) {
+	assert := assert.New(t)
+
+	ws := []float64{1, -2, 0.5, 3, -0.25, 2}
+	vs := []float64{-1, 4, 1.5}
+	bias := -3.0
+
+	var l1, l2 float64
+	for _, xs := range [][]float64{ws, vs, {bias}} {
+		for _, v := range xs {
+			l1 += math.Abs(v)
+			l2 += v * v
+		}
+	}
+
+	sign := func(xs []float64) []float64 {
+		retVal := make([]float64, len(xs))
+		for i, v := range xs {
+			if v < 0 {
+				retVal[i] = -1
+			} else {
+				retVal[i] = 1
+			}
+		}
+		return retVal
+	}
+	double := func(xs []float64) []float64 {
+		retVal := make([]float64, len(xs))
+		for i, v := range xs {
+			retVal[i] = 2 * v
+		}
+		return retVal
+	}
+
+	for _, l1Reg := range []bool{false, true} {
+		for _, useTape := range []bool{true, false} {
+			g := NewGraph()
+			w := NewMatrix(g, Float64, WithName("w"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(ws)), tf64.WithShape(2, 3))))
+			v := NewVector(g, Float64, WithName("v"), WithShape(3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(vs)), tf64.WithShape(3))))
+			b := NewScalar(g, Float64, WithName("b"), WithValue(bias))
+
+			var reg *Node
+			var err error
+			if l1Reg {
+				reg, err = L1Reg(w, v, b)
+			} else {
+				reg, err = L2Reg(w, v, b)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.True(reg.IsScalar())
+
+			if useTape {
+				if _, err = Grad(reg, w, v, b); err != nil {
+					t.Fatal(err)
+				}
+				prog, locMap, err := Compile(g)
+				if err != nil {
+					t.Fatal(err)
+				}
+				m := NewTapeMachine(prog, locMap)
+				if err = m.RunAll(); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				m := NewLispMachine(g)
+				if err = m.RunAll(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			dw, err := w.Grad()
+			if err != nil {
+				t.Fatal(err)
+			}
+			dv, err := v.Grad()
+			if err != nil {
+				t.Fatal(err)
+			}
+			db, err := b.Grad()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if l1Reg {
+				assert.True(floatEquals(l1, extractF64(reg.Value())), "L1, Tape %t", useTape)
+				assert.Equal(sign(ws), extractF64s(dw), "L1, Tape %t", useTape)
+				assert.Equal(sign(vs), extractF64s(dv), "L1, Tape %t", useTape)
+				assert.Equal(-1.0, extractF64(db), "L1, Tape %t", useTape)
+			} else {
+				assert.True(floatEquals(l2, extractF64(reg.Value())), "L2, Tape %t", useTape)
+				assert.Equal(double(ws), extractF64s(dw), "L2, Tape %t", useTape)
+				assert.Equal(double(vs), extractF64s(dv), "L2, Tape %t", useTape)
+				assert.Equal(2*bias, extractF64(db), "L2, Tape %t", useTape)
+			}
+		}
+	}
+
+	if _, err := L2Reg(); err == nil {
+		t.Error("Expected an error without any nodes")
+	}
+}
+
+func TestBiasAdd(t *testing.T) {
+	assert := assert.New(t)
+
+	xs := []float64{
+		1, 2, 3,
+		4, 5, 6,
+		-1, 0, 1,
+		0.5, 0.25, -2,
+	}
+	bs := []float64{10, -20, 0.5}
+	ws := []float64{
+		1, -1, 2,
+		0, 3, 1,
+		-2, 1, 1,
+		4, 0.5, -1,
+	}
+	correct := []float64{
+		11, -18, 3.5,
+		14, -15, 6.5,
+		9, -20, 1.5,
+		10.5, -19.75, -1.5,
+	}
+	correctDB := []float64{3, 3.5, 3} // the columns of w, summed
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		x := NewMatrix(g, Float64, WithName("x"), WithShape(4, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(4, 3))))
+		b := NewVector(g, Float64, WithName("b"), WithShape(3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(bs)), tf64.WithShape(3))))
+		w := NewMatrix(g, Float64, WithName("w"), WithShape(4, 3), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(4, 3))))
+
+		y, err := BiasAdd(x, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(types.Shape{4, 3}, y.Shape())
+		cost := Must(Sum(Must(HadamardProd(y, w))))
+
+		if useTape {
+			if _, err = Grad(cost, x, b); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.True(floatsClose(correct, extractF64s(y.Value())), "Tape %t: %v", useTape, y.Value())
+
+		dx, err := x.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := b.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(ws, extractF64s(dx)), "Tape %t: %v", useTape, dx)
+		assert.True(floatsClose(correctDB, extractF64s(db)), "Tape %t: %v", useTape, db)
+	}
+
+	// float32
+	x32 := FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(xs)), tf32.WithShape(4, 3)))
+	b32 := FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(bs)), tf32.WithShape(3)))
+	y32, err := biasAddOp{}.Do(x32, b32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(f64sToF32s(correct), y32.Data())
+
+	g := NewGraph()
+	x := NewMatrix(g, Float64, WithName("x"), WithShape(4, 3))
+	b := NewVector(g, Float64, WithName("b"), WithShape(4))
+	if _, err = BiasAdd(x, b); err == nil {
+		t.Error("Expected an error when the bias does not match the columns of x")
+	}
+}
+
+func TestOuterSum(t *testing.T) {
+	assert := assert.New(t)
+
+	as := []float64{1, -2}
+	bs := []float64{10, 0.5, -3}
+	ws := []float64{
+		1, -1, 2,
+		0.5, 3, -2,
+	}
+	correct := []float64{
+		11, 1.5, -2,
+		8, -1.5, -5,
+	}
+
+	// cost = Σ w ⊙ OuterSum(a, b)
+	outerSumRef := func(a, b []float64) (retVal float64) {
+		for i, u := range a {
+			for j, v := range b {
+				retVal += ws[i*3+j] * (u + v)
+			}
+		}
+		return
+	}
+	ac, bc := clonef64s(as), clonef64s(bs)
+	correctDA := numericGrad(ac, func() float64 { return outerSumRef(ac, bc) })
+	correctDB := numericGrad(bc, func() float64 { return outerSumRef(ac, bc) })
+	assert.True(floatsClose([]float64{2, 1.5}, correctDA))    // the rows of w, summed
+	assert.True(floatsClose([]float64{1.5, 2, 0}, correctDB)) // the columns of w, summed
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		a := NewVector(g, Float64, WithName("a"), WithShape(2), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(as)), tf64.WithShape(2))))
+		b := NewVector(g, Float64, WithName("b"), WithShape(3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(bs)), tf64.WithShape(3))))
+		w := NewMatrix(g, Float64, WithName("w"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(2, 3))))
+
+		z, err := OuterSum(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(types.Shape{2, 3}, z.Shape())
+		cost := Must(Sum(Must(HadamardProd(z, w))))
+
+		if useTape {
+			if _, err = Grad(cost, a, b); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = NewTapeMachine(prog, locMap).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			if err = NewLispMachine(g).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.True(floatsClose(correct, extractF64s(z.Value())), "Tape %t: %v", useTape, z.Value())
+
+		da, err := a.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := b.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(correctDA, extractF64s(da)), "Tape %t. Expected %v. Got %v", useTape, correctDA, da)
+		assert.True(floatsClose(correctDB, extractF64s(db)), "Tape %t. Expected %v. Got %v", useTape, correctDB, db)
+	}
+
+	// float32
+	a32 := FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(as)), tf32.WithShape(2)))
+	b32 := FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(bs)), tf32.WithShape(3)))
+	z32, err := outerSumOp{}.Do(a32, b32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(f64sToF32s(correct), z32.Data())
+
+	g := NewGraph()
+	_, err = OuterSum(NewMatrix(g, Float64, WithShape(2, 3)), NewVector(g, Float64, WithShape(3)))
+	assert.NotNil(err)
+}
+
+func TestAffine(t *testing.T) {
+	assert := assert.New(t)
+
+	xs := []float64{
+		1, 2, 3,
+		4, 5, 6,
+		-1, 0, 1,
+		0.5, 0.25, -2,
+	}
+	gammas := []float64{2, -1, 0.5}
+	betas := []float64{10, -20, 0.5}
+	ws := []float64{
+		1, -1, 2,
+		0, 3, 1,
+		-2, 1, 1,
+		4, 0.5, -1,
+	}
+	correct := []float64{
+		12, -22, 2,
+		18, -25, 3.5,
+		8, -20, 1,
+		11, -20.25, -0.5,
+	}
+	correctDX := []float64{
+		2, 1, 1,
+		0, -3, 0.5,
+		-4, -1, 0.5,
+		8, -0.5, -0.5,
+	}
+	correctDGamma := []float64{5, 13.125, 15} // the columns of x ⊙ w, summed
+	correctDBeta := []float64{3, 3.5, 3}      // the columns of w, summed
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		x := NewMatrix(g, Float64, WithName("x"), WithShape(4, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(4, 3))))
+		gamma := NewVector(g, Float64, WithName("gamma"), WithShape(3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(gammas)), tf64.WithShape(3))))
+		beta := NewVector(g, Float64, WithName("beta"), WithShape(3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(betas)), tf64.WithShape(3))))
+		w := NewMatrix(g, Float64, WithName("w"), WithShape(4, 3), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(4, 3))))
+
+		y, err := Affine(x, gamma, beta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(types.Shape{4, 3}, y.Shape())
+		var yV Value
+		Read(y, &yV)
+		cost := Must(Sum(Must(HadamardProd(y, w))))
+
+		if useTape {
+			if _, err = Grad(cost, x, gamma, beta); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = NewTapeMachine(prog, locMap).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			if err = NewLispMachine(g).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.True(floatsClose(correct, extractF64s(yV)), "Tape %t: %v", useTape, yV)
+
+		dx, err := x.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dGamma, err := gamma.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dBeta, err := beta.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(correctDX, extractF64s(dx)), "Tape %t: %v", useTape, dx)
+		assert.True(floatsClose(correctDGamma, extractF64s(dGamma)), "Tape %t: %v", useTape, dGamma)
+		assert.True(floatsClose(correctDBeta, extractF64s(dBeta)), "Tape %t: %v", useTape, dBeta)
+	}
+
+	// float32
+	x32 := FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(xs)), tf32.WithShape(4, 3)))
+	gamma32 := FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(gammas)), tf32.WithShape(3)))
+	beta32 := FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(betas)), tf32.WithShape(3)))
+	y32, err := affineOp{}.Do(x32, gamma32, beta32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(f64sToF32s(correct), y32.Data())
+
+	g := NewGraph()
+	x := NewMatrix(g, Float64, WithName("x"), WithShape(4, 3))
+	gamma := NewVector(g, Float64, WithName("gamma"), WithShape(3))
+	beta := NewVector(g, Float64, WithName("beta"), WithShape(4))
+	if _, err = Affine(x, gamma, beta); err == nil {
+		t.Error("Expected an error when beta does not match the columns of x")
+	}
+}
+
+// logSoftmaxRef is the naive log(softmax(x)) of the rows (along == 1) or the columns (along == 0) of a (r, c) matrix
+func logSoftmaxRef(x []float64, r, c, along int) []float64 {
+	retVal := make([]float64, len(x))
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			var sum float64
+			if along == 1 {
+				for k := 0; k < c; k++ {
+					sum += math.Exp(x[i*c+k])
+				}
+			} else {
+				for k := 0; k < r; k++ {
+					sum += math.Exp(x[k*c+j])
+				}
+			}
+			retVal[i*c+j] = math.Log(math.Exp(x[i*c+j]) / sum)
+		}
+	}
+	return retVal
+}
+
+func TestClipGrad(t *testing.T) {
+	assert := assert.New(t)
+
+	xs := []float64{1, -2, 3, 0.5}
+	ws := []float64{0.5, -3, 10, -1}
+
+	// cost = Σ w * clip(x) + 5s, so the gradient of clip(x) is w, and that of clip(s) is 5
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		x := NewVector(g, Float64, WithName("x"), WithShape(4), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(4))))
+		w := NewVector(g, Float64, WithName("w"), WithShape(4), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(4))))
+		s := NewScalar(g, Float64, WithName("s"), WithValue(-4.0))
+
+		cx, err := ClipGrad(x, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cs, err := ClipGrad(s, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(x.Shape(), cx.Shape())
+		assert.True(cs.IsScalar())
+
+		var cxV Value
+		Read(cx, &cxV)
+
+		cost := Must(Add(Must(Sum(Must(HadamardProd(cx, w)))), Must(Mul(cs, NewConstant(5.0)))))
+		if useTape {
+			if _, err = Grad(cost, x, s); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// the forward pass is untouched
+		assert.Equal(xs, extractF64s(cxV), "Tape %t", useTape)
+		assert.Equal(-4.0, extractF64(cs.Value()), "Tape %t", useTape)
+
+		dx, err := x.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ds, err := s.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal([]float64{0.5, -1, 1, -1}, extractF64s(dx), "Tape %t", useTape)
+		assert.Equal(1.0, extractF64(ds), "Tape %t", useTape)
+	}
+
+	g := NewGraph()
+	x := NewVector(g, Float64, WithName("x"), WithShape(4))
+	for _, limit := range []float64{0, -1, math.NaN(), math.Inf(1)} {
+		if _, err := ClipGrad(x, limit); err == nil {
+			t.Errorf("Expected an error with a limit of %v", limit)
+		}
+	}
+}
+
+func TestLogSoftmax(t *testing.T) {
+	assert := assert.New(t)
+
+	xs := []float64{
+		1, 2, 3,
+		-1, 0.5, 4,
+	}
+	ws := []float64{
+		1, -2, 0.5,
+		3, 1, -1,
+	}
+
+	for _, axis := range []int{-1, 0, 1} {
+		along := axis
+		if along < 0 {
+			along = 1
+		}
+		correct := logSoftmaxRef(xs, 2, 3, along)
+
+		// cost = Σ w * LogSoftmax(x)
+		xc := clonef64s(xs)
+		correctDX := numericGrad(xc, func() float64 {
+			var retVal float64
+			for i, v := range logSoftmaxRef(xc, 2, 3, along) {
+				retVal += ws[i] * v
+			}
+			return retVal
+		})
+
+		for _, useTape := range []bool{true, false} {
+			g := NewGraph()
+			x := NewMatrix(g, Float64, WithName("x"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(2, 3))))
+			w := NewMatrix(g, Float64, WithName("w"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(2, 3))))
+
+			y, err := LogSoftmax(x, axis)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cost := Must(Sum(Must(HadamardProd(y, w))))
+
+			if useTape {
+				if _, err = Grad(cost, x); err != nil {
+					t.Fatal(err)
+				}
+				prog, locMap, err := Compile(g)
+				if err != nil {
+					t.Fatal(err)
+				}
+				m := NewTapeMachine(prog, locMap)
+				if err = m.RunAll(); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				m := NewLispMachine(g)
+				if err = m.RunAll(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			assert.True(floatsClose(correct, extractF64s(y.Value())), "Axis %d, Tape %t: %v", axis, useTape, y.Value())
+
+			dx, err := x.Grad()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.True(floatsClose(correctDX, extractF64s(dx)), "Axis %d, Tape %t. Expected %v. Got %v", axis, useTape, correctDX, dx)
+		}
+	}
+
+	// large logits, where log(softmax(x)) would overflow
+	big := FromTensor(tf64.NewTensor(tf64.WithBacking([]float64{1000, 1001, 1002}), tf64.WithShape(3)))
+	y, err := logSoftmaxOp{along: 0, d: 1}.Do(big)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(floatsClose(logSoftmaxRef([]float64{0, 1, 2}, 1, 3, 1), extractF64s(y)), "%v", y)
+
+	// float32
+	x32 := FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(xs)), tf32.WithShape(2, 3)))
+	y32, err := logSoftmaxOp{along: 1, d: 2}.Do(x32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range logSoftmaxRef(xs, 2, 3, 1) {
+		assert.InDelta(v, float64(y32.Data().([]float32)[i]), 1e-6)
+	}
+
+	g := NewGraph()
+	x := NewMatrix(g, Float64, WithName("x"), WithShape(2, 3))
+	if _, err = LogSoftmax(x, 2); err == nil {
+		t.Error("Expected an error when the axis is out of range")
+	}
+	if _, err = LogSoftmax(NewScalar(g, Float64, WithName("s")), 0); err == nil {
+		t.Error("Expected an error with a scalar")
+	}
+}
+
+func TestSoftmaxGrad(t *testing.T) {
+	assert := assert.New(t)
+
+	// softmax of each row of x, a (r, c) matrix
+	softmaxRef := func(x []float64, r, c int) []float64 {
+		retVal := make([]float64, len(x))
+		for i := 0; i < r; i++ {
+			var sum float64
+			for j := 0; j < c; j++ {
+				sum += math.Exp(x[i*c+j])
+			}
+			for j := 0; j < c; j++ {
+				retVal[i*c+j] = math.Exp(x[i*c+j]) / sum
+			}
+		}
+		return retVal
+	}
+
+	// the Jacobian-vector product of the softmax of a vector, numerically: ∂/∂x Σ u * softmax(x)
+	xs := []float64{0.5, -1, 2, 0.1}
+	us := []float64{1, -2, 0.5, 3}
+	xc := clonef64s(xs)
+	correct := numericGrad(xc, func() float64 {
+		var retVal float64
+		for i, v := range softmaxRef(xc, 1, 4) {
+			retVal += us[i] * v
+		}
+		return retVal
+	})
+
+	s := FromTensor(tf64.NewTensor(tf64.WithBacking(softmaxRef(xs, 1, 4)), tf64.WithShape(4)))
+	u := FromTensor(tf64.NewTensor(tf64.WithBacking(clonef64s(us)), tf64.WithShape(4)))
+	jvp, err := softmaxGradOp{d: 1}.Do(s, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(floatsClose(correct, extractF64s(jvp)), "Expected %v. Got %v", correct, jvp)
+
+	// each row of a matrix is a softmax, and SoftmaxGrad is differentiable wrt both of its inputs.
+	// cost = Σ w * SoftmaxGrad(s, u)
+	ss := softmaxRef([]float64{1, 2, 3, -1, 0.5, 4}, 2, 3)
+	ums := []float64{
+		0.5, -1, 2,
+		1, 3, -0.5,
+	}
+	ws := []float64{
+		1, -2, 0.5,
+		3, 1, -1,
+	}
+	ref := func(s, u []float64) []float64 {
+		retVal := make([]float64, len(s))
+		for i := 0; i < 2; i++ {
+			var dot float64
+			for j := 0; j < 3; j++ {
+				dot += u[i*3+j] * s[i*3+j]
+			}
+			for j := 0; j < 3; j++ {
+				retVal[i*3+j] = s[i*3+j] * (u[i*3+j] - dot)
+			}
+		}
+		return retVal
+	}
+	cost := func(s, u []float64) float64 {
+		var retVal float64
+		for i, v := range ref(s, u) {
+			retVal += ws[i] * v
+		}
+		return retVal
+	}
+	sc, uc := clonef64s(ss), clonef64s(ums)
+	correctDS := numericGrad(sc, func() float64 { return cost(sc, uc) })
+	correctDU := numericGrad(uc, func() float64 { return cost(sc, uc) })
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		s := NewMatrix(g, Float64, WithName("s"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(ss)), tf64.WithShape(2, 3))))
+		u := NewMatrix(g, Float64, WithName("u"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(ums)), tf64.WithShape(2, 3))))
+		w := NewMatrix(g, Float64, WithName("w"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(2, 3))))
+
+		y, err := SoftmaxGrad(s, u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(types.Shape{2, 3}, y.Shape())
+
+		var yV Value
+		Read(y, &yV)
+
+		c := Must(Sum(Must(HadamardProd(y, w))))
+		if useTape {
+			if _, err = Grad(c, s, u); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.True(floatsClose(ref(ss, ums), extractF64s(yV)), "Tape %t: %v", useTape, yV)
+
+		ds, err := s.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(correctDS, extractF64s(ds)), "Tape %t. Expected %v. Got %v", useTape, correctDS, ds)
+
+		du, err := u.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(correctDU, extractF64s(du)), "Tape %t. Expected %v. Got %v", useTape, correctDU, du)
+	}
+
+	// the shapes have to match
+	g := NewGraph()
+	a := NewMatrix(g, Float64, WithShape(2, 3))
+	b := NewMatrix(g, Float64, WithShape(3, 2))
+	_, err = SoftmaxGrad(a, b)
+	assert.NotNil(err)
+}
+
+func TestGELUGrad(t *testing.T) {
+	assert := assert.New(t)
+
+	gelu := func(x float64) float64 { return 0.5 * x * (1 + math.Erf(x/math.Sqrt2)) }
+
+	// the backward pass of the GELU, numerically: ∂/∂x Σ u * gelu(x)
+	xs := []float64{
+		-3, -1.5, -0.5, 0,
+		0.25, 1, 2, 4,
+	}
+	us := []float64{
+		1, -2, 0.5, 3,
+		-1, 2, 0.5, -0.5,
+	}
+	xc := clonef64s(xs)
+	correct := numericGrad(xc, func() (retVal float64) {
+		for i, x := range xc {
+			retVal += us[i] * gelu(x)
+		}
+		return
+	})
+
+	x := FromTensor(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(2, 4)))
+	u := FromTensor(tf64.NewTensor(tf64.WithBacking(clonef64s(us)), tf64.WithShape(2, 4)))
+	dx, err := geluGradOp{}.Do(x, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(floatsClose(correct, extractF64s(dx)), "Expected %v. Got %v", correct, dx)
+
+	// GELUGrad is differentiable wrt both of its inputs.
+	// cost = Σ w * GELUGrad(x, u)
+	ws := []float64{
+		0.5, 1, -1, 2,
+		1.5, -0.5, 1, 0.25,
+	}
+	cost := func(x, u []float64) (retVal float64) {
+		for i := range x {
+			h := 1e-5
+			slope := (gelu(x[i]+h) - gelu(x[i]-h)) / (2 * h)
+			retVal += ws[i] * u[i] * slope
+		}
+		return
+	}
+	uc := clonef64s(us)
+	xc = clonef64s(xs)
+	correctDU := numericGrad(uc, func() float64 { return cost(xc, uc) })
+	correctDX := make([]float64, len(xs))
+	for i, x := range xs {
+		correctDX[i] = ws[i] * us[i] * math.Exp(-0.5*x*x) / math.Sqrt(2*math.Pi) * (2 - x*x)
+	}
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		x := NewMatrix(g, Float64, WithName("x"), WithShape(2, 4), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(2, 4))))
+		u := NewMatrix(g, Float64, WithName("u"), WithShape(2, 4), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(us)), tf64.WithShape(2, 4))))
+		w := NewMatrix(g, Float64, WithName("w"), WithShape(2, 4), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(2, 4))))
+
+		y, err := GELUGrad(x, u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(types.Shape{2, 4}, y.Shape())
+
+		var yV Value
+		Read(y, &yV)
+
+		c := Must(Sum(Must(HadamardProd(y, w))))
+		if useTape {
+			if _, err = Grad(c, x, u); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = NewTapeMachine(prog, locMap).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			if err = NewLispMachine(g).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.True(floatsClose(correct, extractF64s(yV)), "Tape %t. Expected %v. Got %v", useTape, correct, yV)
+
+		dx, err := x.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(correctDX, extractF64s(dx)), "Tape %t. Expected %v. Got %v", useTape, correctDX, dx)
+		du, err := u.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(correctDU, extractF64s(du)), "Tape %t. Expected %v. Got %v", useTape, correctDU, du)
+	}
+
+	// float32 scalars
+	g := NewGraph()
+	s := NewScalar(g, Float32, WithName("s"), WithValue(float32(1)))
+	up := NewScalar(g, Float32, WithName("up"), WithValue(float32(2)))
+	y := Must(GELUGrad(s, up))
+	if err = NewLispMachine(g).RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	h := 1e-5
+	assert.InDelta(2*(gelu(1+h)-gelu(1-h))/(2*h), float64(y.Value().Data().(float32)), 1e-5)
+
+	g = NewGraph()
+	_, err = GELUGrad(NewVector(g, Float64, WithShape(3)), NewVector(g, Float64, WithShape(4)))
+	assert.NotNil(err)
+}
+
+func TestL2Normalize(t *testing.T) {
+	assert := assert.New(t)
+
+	xs := []float64{
+		3, 4, 0,
+		1, -2, 2,
+	}
+	ws := []float64{
+		1, -2, 0.5,
+		3, 1, -1,
+	}
+
+	// l2Ref normalizes x, a (2, 3) matrix, along the axis
+	l2Ref := func(x []float64, along int) []float64 {
+		retVal := make([]float64, len(x))
+		for i := 0; i < 2; i++ {
+			for j := 0; j < 3; j++ {
+				var sum float64
+				if along == 1 {
+					for k := 0; k < 3; k++ {
+						sum += x[i*3+k] * x[i*3+k]
+					}
+				} else {
+					for k := 0; k < 2; k++ {
+						sum += x[k*3+j] * x[k*3+j]
+					}
+				}
+				retVal[i*3+j] = x[i*3+j] / math.Sqrt(sum)
+			}
+		}
+		return retVal
+	}
+
+	for _, axis := range []int{-1, 0, 1} {
+		along := axis
+		if along < 0 {
+			along = 1
+		}
+		correct := l2Ref(xs, along)
+
+		// cost = Σ w * L2Normalize(x)
+		xc := clonef64s(xs)
+		correctDX := numericGrad(xc, func() float64 {
+			var retVal float64
+			for i, v := range l2Ref(xc, along) {
+				retVal += ws[i] * v
+			}
+			return retVal
+		})
+
+		for _, useTape := range []bool{true, false} {
+			g := NewGraph()
+			x := NewMatrix(g, Float64, WithName("x"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(2, 3))))
+			w := NewMatrix(g, Float64, WithName("w"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(2, 3))))
+
+			y, err := L2Normalize(x, axis)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(types.Shape{2, 3}, y.Shape())
+			var yV Value
+			Read(y, &yV)
+
+			cost := Must(Sum(Must(HadamardProd(y, w))))
+			if useTape {
+				if _, err = Grad(cost, x); err != nil {
+					t.Fatal(err)
+				}
+				prog, locMap, err := Compile(g)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err = NewTapeMachine(prog, locMap).RunAll(); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				if err = NewLispMachine(g).RunAll(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			ys := extractF64s(yV)
+			assert.True(floatsClose(correct, ys), "Axis %d, Tape %t: %v", axis, useTape, yV)
+
+			// every vector along the axis is of unit length
+			norms := make([]float64, 3-along)
+			for i, v := range ys {
+				if along == 1 {
+					norms[i/3] += v * v
+				} else {
+					norms[i%3] += v * v
+				}
+			}
+			for _, n := range norms {
+				assert.InDelta(1, n, 1e-9, "Axis %d, Tape %t", axis, useTape)
+			}
+
+			dx, err := x.Grad()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.True(floatsClose(correctDX, extractF64s(dx)), "Axis %d, Tape %t. Expected %v. Got %v", axis, useTape, correctDX, dx)
+		}
+	}
+
+	// a vector of zeros stays zeros, and its gradient is finite
+	op := l2NormalizeOp{along: 0, d: 1, eps: 1e-12}
+	zeros := FromTensor(tf32.NewTensor(tf32.WithBacking([]float32{0, 0, 0}), tf32.WithShape(3)))
+	y, err := op.Do(zeros)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]float32{0, 0, 0}, y.Data())
+	d, err := l2NormalizeDiffOp(op).Do(zeros, FromTensor(tf32.NewTensor(tf32.WithBacking([]float32{1, 1, 1}), tf32.WithShape(3))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range d.Data().([]float32) {
+		assert.False(math.IsInf(float64(v), 0) || math.IsNaN(float64(v)), "%v", d)
+	}
+
+	g := NewGraph()
+	_, err = L2Normalize(NewMatrix(g, Float64, WithShape(2, 3)), 2)
+	assert.NotNil(err)
+}
+
+func TestHuberLoss(t *testing.T) {
+	assert := assert.New(t)
+
+	// residuals of -2.5, 0.5, 0, -0.25, 3 and 1: with a delta of 1, half of them are in the quadratic region and half in the linear one
+	preds := []float64{-1.5, 2, 0, 0.75, 4, 1}
+	targets := []float64{1, 1.5, 0, 1, 1, 0}
+	delta := 1.0
+
+	huberRef := func(p, q []float64) float64 {
+		var sum float64
+		for i := range p {
+			r := p[i] - q[i]
+			if math.Abs(r) <= delta {
+				sum += 0.5 * r * r
+			} else {
+				sum += delta * (math.Abs(r) - 0.5*delta)
+			}
+		}
+		return sum / float64(len(p))
+	}
+	correct := huberRef(preds, targets)
+	assert.InDelta((2+0.125+0+0.03125+2.5+0.5)/6, correct, 1e-12)
+
+	// cost = 3 * HuberLoss(pred, target)
+	pc, tc := clonef64s(preds), clonef64s(targets)
+	correctDP := numericGrad(pc, func() float64 { return 3 * huberRef(pc, tc) })
+	correctDT := numericGrad(tc, func() float64 { return 3 * huberRef(pc, tc) })
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		p := NewMatrix(g, Float64, WithName("p"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(preds)), tf64.WithShape(2, 3))))
+		q := NewMatrix(g, Float64, WithName("q"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(targets)), tf64.WithShape(2, 3))))
+
+		loss, err := HuberLoss(p, q, delta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(loss.IsScalar())
+		var lossV Value
+		Read(loss, &lossV)
+
+		cost := Must(Mul(loss, NewConstant(3.0)))
+		if useTape {
+			if _, err = Grad(cost, p, q); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = NewTapeMachine(prog, locMap).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			if err = NewLispMachine(g).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.InDelta(correct, extractF64(lossV), 1e-12, "Tape %t", useTape)
+
+		dp, err := p.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(correctDP, extractF64s(dp)), "Tape %t. Expected %v. Got %v", useTape, correctDP, dp)
+		// the gradient is the clamped residual, scaled by 3/N
+		assert.True(floatsClose([]float64{-0.5, 0.25, 0, -0.125, 0.5, 0.5}, extractF64s(dp)), "Tape %t: %v", useTape, dp)
+
+		dq, err := q.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(correctDT, extractF64s(dq)), "Tape %t. Expected %v. Got %v", useTape, correctDT, dq)
+	}
+
+	// float32
+	op := huberLossOp{delta: delta, d: 1}
+	p32 := FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(preds)), tf32.WithShape(6)))
+	q32 := FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(targets)), tf32.WithShape(6)))
+	l, err := op.Do(p32, q32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.InDelta(correct, float64(l.Data().(float32)), 1e-6)
+	d, err := huberLossDiffOp{fwd: op, wrt: 0}.Do(p32, q32, NewScalarValue(float32(6)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]float32{-1, 0.5, 0, -0.25, 1, 1}, d.Data())
+
+	g := NewGraph()
+	p := NewVector(g, Float64, WithShape(3))
+	_, err = HuberLoss(p, NewVector(g, Float64, WithShape(4)), delta)
+	assert.NotNil(err)
+	_, err = HuberLoss(p, NewVector(g, Float64, WithShape(3)), 0)
+	assert.NotNil(err)
+}
+
+func TestNoise(t *testing.T) {
+	assert := assert.New(t)
+
+	// runs Σ(x ⊙ noise) twice, and returns the noise drawn and the gradient of x from each run
+	run := func(useTape bool, seed int64) (noises, grads [][]float64) {
+		g := NewGraph()
+		x := NewMatrix(g, Float64, WithName("x"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking([]float64{1, 2, 3, 4, 5, 6}), tf64.WithShape(2, 3))))
+		noise := GaussianNoise(Float64, types.Shape{2, 3}, 1, 2, seed)
+		cost := Must(Sum(Must(HadamardProd(x, noise))))
+
+		var m VM
+		if useTape {
+			if _, err := Grad(cost, x); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m = NewTapeMachine(prog, locMap)
+		}
+
+		for i := 0; i < 2; i++ {
+			if !useTape {
+				m = NewLispMachine(g)
+			}
+			if err := m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+			noises = append(noises, clonef64s(extractF64s(noise.Value())))
+
+			dx, err := x.Grad()
+			if err != nil {
+				t.Fatal(err)
+			}
+			grads = append(grads, clonef64s(extractF64s(dx)))
+			if useTape {
+				m.(*tapeMachine).Reset()
+			}
+		}
+		return
+	}
+
+	for _, useTape := range []bool{true, false} {
+		noises, grads := run(useTape, 42)
+		assert.Equal(noises[0], grads[0], "Tape %t", useTape)
+		if useTape {
+			// the lisp machine accumulates the gradients of the inputs from one run to the next
+			assert.Equal(noises[1], grads[1], "Tape %t", useTape)
+		}
+		assert.NotEqual(noises[0], noises[1], "Tape %t: every run should draw fresh values", useTape)
+
+		again, _ := run(useTape, 42)
+		assert.Equal(noises, again, "Tape %t: the same seed should draw the same values", useTape)
+
+		other, _ := run(useTape, 43)
+		assert.NotEqual(noises[0], other[0], "Tape %t", useTape)
+	}
+
+	// dtypes and shapes
+	g := NewGraph()
+	u := UniformNoise(Float32, types.Shape{4, 5}, -1, 1, 7, WithName("u"))
+	assert.Nil(u.g)
+	assert.Equal(types.Shape{4, 5}, u.Shape())
+	assert.Equal("u", u.Name())
+	s := GaussianNoise(Float64, nil, 0, 1, 7)
+	assert.True(s.IsScalar())
+
+	ux := Must(Add(u, NewMatrix(g, Float32, WithName("x"), WithShape(4, 5), WithInit(Zeroes()))))
+	sx := Must(Add(s, NewScalar(g, Float64, WithName("y"), WithValue(0.0))))
+	if err := NewLispMachine(g, ExecuteFwdOnly()).RunAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	us, ok := ux.Value().Data().([]float32)
+	if !ok {
+		t.Fatalf("Expected []float32. Got %T instead", ux.Value().Data())
+	}
+	assert.Len(us, 20)
+	for _, v := range us {
+		assert.True(v >= -1 && v < 1, "%v is out of range", v)
+	}
+	assert.Equal(us, u.Value().Data())
+	_, ok = sx.Value().Data().(float64)
+	assert.True(ok)
+
+	// noise is not a constant: folding leaves it, and what is computed from it, alone
+	g = NewGraph()
+	x := NewScalar(g, Float64, WithName("x"), WithValue(1.0))
+	noise := GaussianNoise(Float64, nil, 0, 1, 42)
+	xn := Must(Add(x, Must(Mul(noise, g.AddNode(NewConstant(2.0))))))
+	if err := FoldConstants(g); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(5, len(g.AllNodes()), "Nothing should be folded")
+	prog, locMap, err := Compile(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewTapeMachine(prog, locMap)
+	var draws []float64
+	for i := 0; i < 2; i++ {
+		if err = m.RunAll(); err != nil {
+			t.Fatal(err)
+		}
+		draws = append(draws, xn.Value().Data().(float64))
+		m.Reset()
+	}
+	assert.NotEqual(draws[0], draws[1], "Every run should still draw fresh values once the graph is folded")
+
+	// nor is it stabilized like one: log(noise + x)
+	g = NewGraph()
+	x = NewScalar(g, Float64, WithName("x"), WithValue(1.0))
+	l, err := Log(Must(Add(GaussianNoise(Float64, nil, 0, 1, 42), x)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(lnOpType, l.op.(elemUnaryOp).unaryOpType())
+
+	// not yet drawn, or not created by GaussianNoise or UniformNoise
+	assert.Nil(GaussianNoise(Float64, types.Shape{2}, 0, 1, 7).Value())
+	if _, err := (noiseOp{}).Do(); err == nil {
+		t.Error("Expected an error from a noiseOp without a random number generator")
+	}
+}
@@ -12,71 +12,123 @@ import (
 	"fmt"
 	"hash"
 	"hash/fnv"
+	"math"
+	"sort"
+	"sync/atomic"
 
 	"github.com/chewxy/gorgonia/tensor"
+	tb "github.com/chewxy/gorgonia/tensor/b"
 	tf32 "github.com/chewxy/gorgonia/tensor/f32"
 	tf64 "github.com/chewxy/gorgonia/tensor/f64"
+	ti "github.com/chewxy/gorgonia/tensor/i"
 	"github.com/chewxy/gorgonia/tensor/types"
+	"github.com/chewxy/math32"
 	"github.com/pkg/errors"
 )
 
+// TieBreak decides where the gradient of a max goes when several elements tie for the max.
+//
+// MaxWithArg always routes the gradient to the first of the tied elements, as its indices do.
+type TieBreak byte
+
+const (
+	// AllTies gives the gradient of the max to every element that is equal to the max
+	AllTies TieBreak = iota
+
+	// FirstTie gives the gradient of the max only to the first element that is equal to the max, in row-major order.
+	// This is what frameworks that route the gradient through an argmax do.
+	FirstTie
+)
+
 type maxOp struct {
 	along axes
 	d     int
+	ties  TieBreak
 }
 
-func newMaxOp(along axes, dim int) *maxOp {
+func newMaxOp(along axes, dim int, ties TieBreak) *maxOp {
 	return &maxOp{
 		along: along,
 		d:     dim,
+		ties:  ties,
 	}
 }
 
 func (op maxOp) Type() Type {
 	a := newTypeVariable("a", withTVConstraints(summable))
 	t := newTensorType(op.d, a)
+	return newFunctionType(t, op.retType(a))
+}
 
-	var retType Type
+func (op maxOp) retType(a Type) Type {
 	if op.d == 1 || len(op.along) == 0 || len(op.along) == op.d {
 		// then it redueces down
-		retType = a
-		return newFunctionType(t, a)
-	} else {
-		retType = newTensorType(op.d-1, a)
+		return a
 	}
-	return newFunctionType(t, retType)
+	return newTensorType(op.d-len(op.along), a)
+}
+
+// inferShape removes the axes the max is found along, as the max of a tensor does
+func (op maxOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "maxOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return op.reducedShape(inputs[0].shape)
+}
+
+func (op maxOp) reducedShape(in types.Shape) (retVal types.Shape, err error) {
+	for _, a := range op.along {
+		if a < 0 || a >= len(in) {
+			return nil, errors.Errorf("Axis %d is out of range for the shape %v", a, in)
+		}
+	}
+	if in.IsVector() || len(op.along) == 0 || len(op.along) >= op.d {
+		return scalarShape, nil
+	}
+
+	for i, size := range in {
+		if !op.along.contains(i) {
+			retVal = append(retVal, size)
+		}
+	}
+	return
 }
 
-func (op maxOp) inferShape(Type, ...*Node) (types.Shape, error) { return scalarShape, nil } // TODO, THIS IS INCORRECT
-func (op maxOp) DiffWRT(i int) []bool                           { return []bool{true} }
+func (op maxOp) DiffWRT(i int) []bool { return []bool{true} }
 
+// SymDiff routes the gradient of each max to the elements that are equal to it, as chosen by op.ties
 func (op maxOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
 	if len(inputs) != 1 {
 		err = NewError(GraphError, "Expect at least 1 input. Got %d instead", len(inputs))
 		return
 	}
-	t := inputs[0]
-	opDim := len(t.Shape())
 
-	var leftAxes []byte
-	for i := 0; i < opDim; i++ {
-		for _, ax := range op.along {
-			if i == ax {
-				leftAxes = append(leftAxes, byte(i))
-				break
-			}
-		}
+	var dx *Node
+	if dx, err = applyOp(maxDiffOp(op), inputs[0], output, gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
 	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
 
-	var eq *Node
-	bcpat := NewBroadcastPattern(leftAxes, nil)
-	if eq, err = Broadcast(eqOpType, output, t, bcpat); err != nil {
-		return nil, errors.Wrap(err, operationError)
+func (op maxOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "Expected only one input for maxop. Got %d instead", len(inputs))
+		return
 	}
 
-	retVal[0], err = Broadcast(mulOpType, gradNode, eq, bcpat)
-	if err != nil {
-		return nil, errors.Wrap(err, operationError)
+	xdv := inputs[0].boundTo.(*dualValue)
+	odv := output.boundTo.(*dualValue)
+
+	var d Value
+	if d, err = maxDiffOp(op).Do(xdv.Value, odv.Value, odv.d); err != nil {
+		return errors.Wrap(err, "maxOp.DoDiff()")
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
 	}
 	return
 }
@@ -86,11 +138,73 @@ func (op maxOp) Do(inputs ...Value) (retVal Value, err error) {
 		err = NewError(GraphError, "Expected only one input for maxop. Got %d instead", len(inputs))
 		return
 	}
-	return nil, NewError(NotYetImplemented, "maxOp")
+
+	var x []float64
+	var shp types.Shape
+	var dt Dtype
+	if x, shp, dt, err = floatsOperand(inputs[0]); err != nil {
+		return nil, errors.Wrap(err, "maxOp.Do()")
+	}
+	if len(x) == 0 {
+		return nil, errors.Errorf("Cannot find the max of an empty tensor shaped %v", shp)
+	}
+
+	var reduced types.Shape
+	if reduced, err = op.reducedShape(shp); err != nil {
+		return
+	}
+
+	// each element of x is compared with the max it is reduced into, which is found with the strides of a sum along the same axes
+	strides, size := op.strides(shp)
+	max := make([]float64, size)
+	seen := make([]bool, size)
+	forEachReduced(shp, strides, func(i, j int) {
+		if !seen[j] || x[i] > max[j] {
+			max[j], seen[j] = x[i], true
+		}
+	})
+
+	if reduced.IsScalar() {
+		if dt == Float32 {
+			return NewScalarValue(float32(max[0])), nil
+		}
+		return NewScalarValue(max[0]), nil
+	}
+	return floatsValue(max, reduced, dt), nil
+}
+
+// strides returns the strides with which the max is walked, one per axis of the input shape, along with the size of the max
+func (op maxOp) strides(shp types.Shape) (strides []int, size int) {
+	along := op.along
+	if len(along) == 0 {
+		along = intRange(0, len(shp))
+	}
+	return sumOp{along: along}.broadcastStrides(shp)
+}
+
+// forEachReduced calls fn with the index of every element of a tensor of the given shape, in row-major order, along with the index of the element it is reduced into.
+// The strides are those of the reduced tensor, one per axis of the shape, with 0s along the reduced axes.
+func forEachReduced(shp types.Shape, strides []int, fn func(i, j int)) {
+	coord := make([]int, len(shp))
+	var j int
+	for i, total := 0, shp.TotalSize(); i < total; i++ {
+		fn(i, j)
+
+		// move on to the next coordinate, like an odometer
+		for a := len(shp) - 1; a >= 0; a-- {
+			coord[a]++
+			j += strides[a]
+			if coord[a] < shp[a] {
+				break
+			}
+			j -= strides[a] * coord[a]
+			coord[a] = 0
+		}
+	}
 }
 
-func (op maxOp) returnsPtr() bool    { return true }
-func (op maxOp) overwriteInput() int { return 0 }
+func (op maxOp) returnsPtr() bool    { return false }
+func (op maxOp) overwriteInput() int { return -1 }
 func (op maxOp) callsExtern() bool   { return false }
 
 func (op maxOp) WriteHash(h hash.Hash) {
@@ -99,6 +213,9 @@ func (op maxOp) WriteHash(h hash.Hash) {
 		panic(err)
 	}
 	fmt.Fprintf(h, "%v->%v", op.d, op.along)
+	if op.ties != AllTies {
+		fmt.Fprintf(h, "ties%d", op.ties)
+	}
 }
 
 func (op maxOp) Hashcode() uint32 {
@@ -107,8 +224,142 @@ func (op maxOp) Hashcode() uint32 {
 	return h.Sum32()
 }
 
-func (op maxOp) String() string { return fmt.Sprintf("MaxAlong%v", op.along) }
-func (op maxOp) isUnary() bool  { return true }
+func (op maxOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInts(op.along)
+	w.writeInt(op.d)
+	w.writeByte(byte(op.ties))
+	return w.bytes()
+}
+
+func (op *maxOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.along = axes(r.readInts())
+	op.d = r.readInt()
+	op.ties = TieBreak(r.readByte())
+	r.checkAxes(op.d, op.along...)
+	r.check(op.ties == AllTies || op.ties == FirstTie, "Unknown TieBreak %d", op.ties)
+	return r.done()
+}
+
+func (op maxOp) String() string {
+	if op.ties == FirstTie {
+		return fmt.Sprintf("MaxAlong%v(FirstTie)", op.along)
+	}
+	return fmt.Sprintf("MaxAlong%v", op.along)
+}
+func (op maxOp) isUnary() bool { return true }
+
+// maxDiffOp is the derivative of maxOp. It takes the input, the max and the gradient of the max, and puts the gradient of each max
+// where the elements that are equal to the max are - all of them, or only the first of them, depending on the ties.
+// Everything else gets 0.
+type maxDiffOp struct {
+	along axes
+	d     int
+	ties  TieBreak
+}
+
+// maxDiffOp has this type:
+//		op :: (Summable a) ⇒ Tensor d a → b → b → Tensor d a
+// where b is the type of the max
+func (op maxDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(summable))
+	t := newTensorType(op.d, a)
+	b := maxOp(op).retType(a)
+	return newFunctionType(t, b, b, t)
+}
+
+func (op maxDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "maxDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op maxDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op maxDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op maxDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "maxDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var x, max, grad []float64
+	var shp types.Shape
+	var dt Dtype
+	if x, shp, dt, err = floatsOperand(inputs[0]); err != nil {
+		return nil, errors.Wrap(err, "maxDiffOp.Do()")
+	}
+	strides, size := maxOp(op).strides(shp)
+	if max, err = reducedFloats(inputs[1], size); err != nil {
+		return nil, errors.Wrap(err, "maxDiffOp.Do()")
+	}
+	if grad, err = reducedFloats(inputs[2], size); err != nil {
+		return nil, errors.Wrap(err, "maxDiffOp.Do()")
+	}
+
+	dx := make([]float64, len(x))
+	taken := make([]bool, size)
+	forEachReduced(shp, strides, func(i, j int) {
+		if x[i] != max[j] || (op.ties == FirstTie && taken[j]) {
+			return
+		}
+		dx[i] = grad[j]
+		taken[j] = true
+	})
+	return floatsValue(dx, shp, dt), nil
+}
+
+// reducedFloats returns the elements of the output of a reduction (or of its gradient), which may be a scalar, as float64s
+func reducedFloats(v Value, size int) (retVal []float64, err error) {
+	switch vt := v.(type) {
+	case *dualValue:
+		return reducedFloats(vt.Value, size)
+	case Scalar:
+		switch s := vt.v.(type) {
+		case float64:
+			retVal = []float64{s}
+		case float32:
+			retVal = []float64{float64(s)}
+		default:
+			return nil, errors.Errorf(nyiFail, "reducedFloats", vt.t)
+		}
+	default:
+		if retVal, _, _, err = floatsOperand(v); err != nil {
+			return
+		}
+	}
+	if len(retVal) != size {
+		return nil, errors.Errorf("Expected %d elements. Got %v instead", size, v)
+	}
+	return
+}
+
+func (op maxDiffOp) returnsPtr() bool    { return false }
+func (op maxDiffOp) callsExtern() bool   { return false }
+func (op maxDiffOp) overwriteInput() int { return -1 }
+func (op maxDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	maxOp(op).WriteHash(h)
+}
+
+func (op maxDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op maxDiffOp) MarshalBinary() ([]byte, error) {
+	return maxOp(op).MarshalBinary()
+}
+
+func (op *maxDiffOp) UnmarshalBinary(data []byte) error {
+	return (*maxOp)(op).UnmarshalBinary(data)
+}
+
+func (op maxDiffOp) String() string { return fmt.Sprintf("∂%v", maxOp(op)) }
 
 /* ARGMAX OP */
 // type argmaxOp struct {
@@ -120,127 +371,973 @@ func (op maxOp) isUnary() bool  { return true }
 
 // }
 
-/* SUM OP */
+/* ARGMIN OP */
 
-type sumOp struct {
-	along      axes
-	d          int
-	inputShape types.Shape
+// argminOp finds the index of the min along an axis. Ties are broken in favour of the lower index, and NaNs are never the min.
+// It is not differentiable.
+//
+// Vectors (d == 1) are reduced down to a scalar index.
+type argminOp struct {
+	along int // axis
+	d     int
 }
 
-func newSumOp(along axes, s types.Shape, d int) sumOp {
-	return sumOp{
-		along:      along,
-		d:          d,
-		inputShape: s,
-	}
+// argminOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Tensor (d-1) Int
+func (op argminOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(op.d, a)
+	return newFunctionType(t, logSumExpOp(op).retType(Int))
 }
 
-// sumOp is a function with this type:
-//		sumOp :: (Summable a) ⇒ Tensor d a → Tensor d-1 a
-func (op sumOp) Type() Type {
-	a := newTypeVariable("a", withTVConstraints(summable))
-	t := newTensorType(op.d, a)
-	var retType Type
-	if op.d == 1 || len(op.along) == 0 || len(op.along) == op.d {
-		// then it redueces down
-		retType = a
-		return newFunctionType(t, a)
-	} else {
-		retType = newTensorType(op.d-1, a)
+// inferShape drops the axis the min is found along
+func (op argminOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "argminOp expects 1 input. Got %d instead", len(inputs))
+		return
 	}
-	return newFunctionType(t, retType)
+	return maxWithArgOp(op).reducedShape(inputs[0].shape)
 }
 
-func (op sumOp) inferShape(t Type, inputs ...*Node) (shape types.Shape, err error) {
+func (op argminOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op argminOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op argminOp) Do(inputs ...Value) (retVal Value, err error) {
 	if len(inputs) != 1 {
-		err = NewError(GraphError, "sumOp requires only one input")
+		err = NewError(GraphError, "argminOp expects 1 input. Got %d instead", len(inputs))
 		return
 	}
 
-	in := inputs[0]
-	shapeLogf("Infering... Type: %v", t)
-	shapeLogf("input shape: %v", in.shape)
-	switch {
-	case in.IsScalar():
-		shape = scalarShape
-	case in.IsVector() && !in.IsRowVec() && !in.IsColVec():
-		if len(op.along) > 1 || (len(op.along) == 1 && op.along[0] != 0) {
-			return nil, errors.Errorf("Shape mismatch: along is %v. Shape is %v", op.along, in.shape)
-		}
-		shape = scalarShape
+	t, ok := inputs[0].(Tensor)
+	if !ok {
+		return nil, errors.Errorf("Expected a Tensor. Got %v of %T instead", inputs[0], inputs[0])
+	}
+
+	shp := t.Shape()
+	var reduced types.Shape
+	if reduced, err = maxWithArgOp(op).reducedShape(shp); err != nil {
+		return
+	}
+	outer, n, inner := maxWithArgOp(op).strides(shp)
+
+	out := make([]int, outer*inner)
+	switch tt := t.Tensor.(type) {
+	case *tf64.Tensor:
+		argminf64(materializedF64s(tt), out, n, inner)
+	case *tf32.Tensor:
+		argminf32(materializedF32s(tt), out, n, inner)
 	default:
-		shape = in.Shape().Clone()
-		if len(op.along) > len(shape) {
-			return nil, errors.Errorf("Shape mismatch: %v and %v", shape, op.along)
-		}
+		return nil, errors.Errorf(nyiFail, "argminOp.Do()", t.Tensor)
+	}
 
-		if monotonic, incr1 := types.IsMonotonicInts(op.along); monotonic && incr1 && len(op.along) == len(shape) {
-			shape = scalarShape
-			return
-		}
+	if reduced.IsScalar() {
+		return NewScalarValue(out[0]), nil
+	}
+	return FromTensor(ti.NewTensor(ti.WithBacking(out), ti.WithShape(reduced...))), nil
+}
 
-		for _, a := range op.along {
-			if a >= len(shape) {
-				return nil, errors.Errorf("Axis %d is greater or equal to the length of the shape %v", a, shape)
+func (op argminOp) returnsPtr() bool    { return false }
+func (op argminOp) callsExtern() bool   { return false }
+func (op argminOp) overwriteInput() int { return -1 }
+func (op argminOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("argmin"))
+	if err := binary.Write(h, binary.LittleEndian, byte(op.along)); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, byte(op.d)); err != nil {
+		panic(err)
+	}
+}
+
+func (op argminOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op argminOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.along)
+	w.writeInt(op.d)
+	return w.bytes()
+}
+
+func (op *argminOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.along = r.readInt()
+	op.d = r.readInt()
+	r.checkAxes(op.d, op.along)
+	return r.done()
+}
+
+func (op argminOp) String() string { return fmt.Sprintf("Argmin(%d)", op.along) }
+
+// argminf64 scans the n elements along the axis of each of the len(out) vectors of a, which are inner apart, and writes the index of the min into out
+func argminf64(a []float64, out []int, n, inner int) {
+	for j := range out {
+		base := (j/inner)*n*inner + j%inner
+		best := -1
+		for k := 0; k < n; k++ {
+			v := a[base+k*inner]
+			if math.IsNaN(v) {
+				continue
+			}
+			if best < 0 || v < a[base+best*inner] {
+				best = k
 			}
-			shape[a] = 1
 		}
-
-		if oneone.Eq(shape) {
-			shape = scalarShape
+		if best < 0 {
+			best = 0 // all NaNs
 		}
+		out[j] = best
+	}
+}
 
+func argminf32(a []float32, out []int, n, inner int) {
+	for j := range out {
+		base := (j/inner)*n*inner + j%inner
+		best := -1
+		for k := 0; k < n; k++ {
+			v := a[base+k*inner]
+			if math32.IsNaN(v) {
+				continue
+			}
+			if best < 0 || v < a[base+best*inner] {
+				best = k
+			}
+		}
+		if best < 0 {
+			best = 0 // all NaNs
+		}
+		out[j] = best
 	}
-	return
 }
 
-func (op sumOp) DiffWRT(i int) []bool { return []bool{true} }
+/* MAX WITH ARG OP */
 
-func (op sumOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+// maxWithArgOp finds the max along an axis, as well as where the max is, in a single pass.
+// Because an op only has one output, both are packed into one tensor, which has a new first axis of size 2:
+// packed[0] holds the max values and packed[1] holds their indices (in the Dtype of the input), each of which has the shape of the input with the axis removed.
+// Ties are broken in favour of the lower index.
+//
+// Vectors (d == 1) are reduced down to a scalar max and index, so their packed tensor is a (2) vector.
+type maxWithArgOp struct {
+	along int // axis
+	d     int
+}
+
+// maxWithArgOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Tensor d a
+func (op maxWithArgOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(op.d, a)
+	return newFunctionType(t, t)
+}
+
+func (op maxWithArgOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
 	if len(inputs) != 1 {
-		err = NewError(GraphError, "Requires only one input to differentiate sumop")
+		err = NewError(GraphError, "maxWithArgOp expects 1 input. Got %d instead", len(inputs))
 		return
 	}
-	children := make(Nodes, len(op.along)+1)
-	children[0] = gradNode
-	for i, a := range op.along {
-		var n *Node
-		if n, err = SizeOf(a, inputs[0]); err != nil {
-			return nil, errors.Wrap(err, operationError)
+
+	var reduced types.Shape
+	if reduced, err = op.reducedShape(inputs[0].shape); err != nil {
+		return
+	}
+	return append(types.Shape{2}, reduced...), nil
+}
+
+// reducedShape is the shape of the max (and of the indices), which is the input shape without the axis
+func (op maxWithArgOp) reducedShape(s types.Shape) (types.Shape, error) {
+	if op.d == 1 {
+		if op.along != 0 {
+			return nil, errors.Errorf("A vector can only be reduced along axis 0. Got axis %d instead", op.along)
 		}
-		WithGroupName(gradClust)(n)
-		children[i+1] = n
+		return scalarShape, nil
 	}
 
-	retVal = make(Nodes, 1)
-	repeat := newRepeatOp(op.along, children)
+	if op.along < 0 || op.along >= len(s) {
+		return nil, errors.Errorf("Cannot reduce along axis %d of a tensor shaped %v", op.along, s)
+	}
+	reduced := make(types.Shape, 0, len(s)-1)
+	reduced = append(reduced, s[:op.along]...)
+	return append(reduced, s[op.along+1:]...), nil
+}
 
-	symdiffLogf("repeat: %v", repeat.Type())
-	symdiffLogf("children %#Y", children)
-	symdiffLogf("children: %v", children)
-	retVal[0], err = applyOp(repeat, children...)
-	if err != nil {
+// strides splits up the input shape into the number of elements before the axis, along the axis, and after the axis
+func (op maxWithArgOp) strides(s types.Shape) (outer, n, inner int) {
+	if op.d == 1 {
+		return 1, s.TotalSize(), 1
+	}
+	return splitAlong(s, op.along)
+}
+
+// splitAlong splits up a shape into the number of elements before the axis, along the axis, and after the axis.
+// The elements along the axis are then inner apart, and the element (o, k, i) is at o*n*inner + k*inner + i.
+func splitAlong(s types.Shape, axis int) (outer, n, inner int) {
+	outer, inner = 1, 1
+	for _, v := range s[:axis] {
+		outer *= v
+	}
+	for _, v := range s[axis+1:] {
+		inner *= v
+	}
+	return outer, s[axis], inner
+}
+
+func (op maxWithArgOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+// SymDiff routes the gradient of the max values to where the max values are. The gradient of the indices is ignored.
+func (op maxWithArgOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "maxWithArgOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(maxWithArgDiffOp(op), inputs[0], output, gradNode); err != nil {
 		return nil, errors.Wrap(err, applyOpFail)
 	}
-	retVal[0].setGroup(gradClust)
-	return
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
 }
 
-func (op sumOp) DoDiff(inputs Nodes, output *Node) (err error) {
+func (op maxWithArgOp) DoDiff(inputs Nodes, output *Node) (err error) {
 	if len(inputs) != 1 {
-		err = NewError(GraphError, "Requires only one input to differentiate sumop")
+		err = NewError(GraphError, "maxWithArgOp expects 1 input. Got %d instead", len(inputs))
 		return
 	}
 
 	xdv := inputs[0].boundTo.(*dualValue)
-	ydv := output.boundTo.(*dualValue)
-	xShape := xdv.Value.Shape()
+	odv := output.boundTo.(*dualValue)
 
-	var T types.Tensor
-	switch ydvd := ydv.d.(type) {
-	case Scalar:
-		switch ydvd.t {
+	var d Value
+	if d, err = maxWithArgDiffOp(op).Do(xdv.Value, odv.Value, odv.d); err != nil {
+		return errors.Wrap(err, "maxWithArgOp.DoDiff()")
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op maxWithArgOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "maxWithArgOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	t, ok := inputs[0].(Tensor)
+	if !ok {
+		return nil, errors.Errorf("Expected a Tensor. Got %v instead", inputs[0])
+	}
+
+	var reduced types.Shape
+	if reduced, err = op.reducedShape(t.Shape()); err != nil {
+		return
+	}
+	outer, n, inner := op.strides(t.Shape())
+	if n == 0 {
+		return nil, errors.Errorf("Cannot find the max of an empty axis of a tensor shaped %v", t.Shape())
+	}
+
+	var x []float64
+	switch tt := t.Tensor.(type) {
+	case *tf64.Tensor:
+		x = materializedF64s(tt)
+	case *tf32.Tensor:
+		x = f32sToF64s(materializedF32s(tt))
+	default:
+		return nil, errors.Errorf(nyiFail, "maxWithArgOp.Do()", t.Tensor)
+	}
+
+	size := outer * inner
+	packed := make([]float64, 2*size)
+	for o := 0; o < outer; o++ {
+		for i := 0; i < inner; i++ {
+			base := o*n*inner + i
+			max, arg := x[base], 0
+			for k := 1; k < n; k++ {
+				if v := x[base+k*inner]; v > max {
+					max, arg = v, k
+				}
+			}
+			packed[o*inner+i] = max
+			packed[size+o*inner+i] = float64(arg)
+		}
+	}
+
+	shape := append(types.Shape{2}, reduced...)
+	if t.Dtype() == Float32 {
+		return FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(packed)), tf32.WithShape(shape...))), nil
+	}
+	return FromTensor(tf64.NewTensor(tf64.WithBacking(packed), tf64.WithShape(shape...))), nil
+}
+
+func (op maxWithArgOp) returnsPtr() bool    { return false }
+func (op maxWithArgOp) callsExtern() bool   { return false }
+func (op maxWithArgOp) overwriteInput() int { return -1 }
+func (op maxWithArgOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("maxWithArg"))
+	if err := binary.Write(h, binary.LittleEndian, int64(op.along)); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, byte(op.d)); err != nil {
+		panic(err)
+	}
+}
+
+func (op maxWithArgOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op maxWithArgOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.along)
+	w.writeInt(op.d)
+	return w.bytes()
+}
+
+func (op *maxWithArgOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.along = r.readInt()
+	op.d = r.readInt()
+	r.checkAxes(op.d, op.along)
+	return r.done()
+}
+
+func (op maxWithArgOp) String() string { return fmt.Sprintf("MaxWithArg(%d)", op.along) }
+
+// maxWithArgDiffOp is the derivative of maxWithArgOp. It takes the input, the packed output and the gradient of the packed output,
+// and puts the gradient of each max value where the max value is. Everything else gets 0.
+type maxWithArgDiffOp struct {
+	along int
+	d     int
+}
+
+// maxWithArgDiffOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Tensor d a → Tensor d a → Tensor d a
+func (op maxWithArgDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(op.d, a)
+	return newFunctionType(t, t, t, t)
+}
+
+func (op maxWithArgDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "maxWithArgDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op maxWithArgDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op maxWithArgDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op maxWithArgDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "maxWithArgDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	x, ok := inputs[0].(Tensor)
+	if !ok {
+		return nil, errors.Errorf("Expected a Tensor. Got %v instead", inputs[0])
+	}
+	outer, n, inner := maxWithArgOp(op).strides(x.Shape())
+	size := outer * inner
+
+	var packed, grad []float64
+	for i, v := range inputs[1:] {
+		var data []float64
+		switch vt := v.(type) {
+		case Tensor:
+			switch tt := vt.Tensor.(type) {
+			case *tf64.Tensor:
+				data = materializedF64s(tt)
+			case *tf32.Tensor:
+				data = f32sToF64s(materializedF32s(tt))
+			}
+		}
+		if len(data) != 2*size {
+			return nil, errors.Errorf("Expected a packed max and index tensor of %d elements. Got %v instead", 2*size, v)
+		}
+		if i == 0 {
+			packed = data
+		} else {
+			grad = data
+		}
+	}
+
+	dx := make([]float64, x.Shape().TotalSize())
+	for o := 0; o < outer; o++ {
+		for i := 0; i < inner; i++ {
+			arg := int(packed[size+o*inner+i])
+			dx[o*n*inner+arg*inner+i] = grad[o*inner+i]
+		}
+	}
+
+	if x.Dtype() == Float32 {
+		return FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(dx)), tf32.WithShape(x.Shape().Clone()...))), nil
+	}
+	return FromTensor(tf64.NewTensor(tf64.WithBacking(dx), tf64.WithShape(x.Shape().Clone()...))), nil
+}
+
+func (op maxWithArgDiffOp) returnsPtr() bool    { return false }
+func (op maxWithArgDiffOp) callsExtern() bool   { return false }
+func (op maxWithArgDiffOp) overwriteInput() int { return -1 }
+func (op maxWithArgDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	maxWithArgOp(op).WriteHash(h)
+}
+
+func (op maxWithArgDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op maxWithArgDiffOp) MarshalBinary() ([]byte, error) {
+	return maxWithArgOp(op).MarshalBinary()
+}
+
+func (op *maxWithArgDiffOp) UnmarshalBinary(data []byte) error {
+	return (*maxWithArgOp)(op).UnmarshalBinary(data)
+}
+
+func (op maxWithArgDiffOp) String() string { return fmt.Sprintf("∂MaxWithArg(%d)", op.along) }
+
+// maxArgIndicesOp unpacks the indices from the packed output of maxWithArgOp (or topKOp) as Ints. It is not differentiable.
+type maxArgIndicesOp struct {
+	d int // dims of the packed tensor
+}
+
+// maxArgIndicesOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Tensor d-1 Int
+func (op maxArgIndicesOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(op.d, a)
+	if op.d == 1 {
+		return newFunctionType(t, Int)
+	}
+	return newFunctionType(t, newTensorType(op.d-1, Int))
+}
+
+func (op maxArgIndicesOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "maxArgIndicesOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	s := inputs[0].shape
+	if len(s) == 0 || s[0] != 2 {
+		return nil, errors.Errorf("Expected a packed max and index tensor. Got a shape of %v instead", s)
+	}
+	if len(s) == 1 {
+		return scalarShape, nil
+	}
+	return s[1:].Clone(), nil
+}
+
+func (op maxArgIndicesOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op maxArgIndicesOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op maxArgIndicesOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "maxArgIndicesOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	t, ok := inputs[0].(Tensor)
+	if !ok || len(t.Shape()) == 0 || t.Shape()[0] != 2 {
+		return nil, errors.Errorf("Expected a packed max and index tensor. Got %v instead", inputs[0])
+	}
+
+	var packed []float64
+	switch tt := t.Tensor.(type) {
+	case *tf64.Tensor:
+		packed = materializedF64s(tt)
+	case *tf32.Tensor:
+		packed = f32sToF64s(materializedF32s(tt))
+	default:
+		return nil, errors.Errorf(nyiFail, "maxArgIndicesOp.Do()", t.Tensor)
+	}
+
+	size := len(packed) / 2
+	indices := make([]int, size)
+	for i := range indices {
+		indices[i] = int(packed[size+i])
+	}
+
+	if len(t.Shape()) == 1 {
+		return NewScalarValue(indices[0]), nil
+	}
+	return FromTensor(ti.NewTensor(ti.WithBacking(indices), ti.WithShape(t.Shape()[1:].Clone()...))), nil
+}
+
+func (op maxArgIndicesOp) returnsPtr() bool    { return false }
+func (op maxArgIndicesOp) callsExtern() bool   { return false }
+func (op maxArgIndicesOp) overwriteInput() int { return -1 }
+func (op maxArgIndicesOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("maxArgIndices"))
+	if err := binary.Write(h, binary.LittleEndian, byte(op.d)); err != nil {
+		panic(err)
+	}
+}
+
+func (op maxArgIndicesOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op maxArgIndicesOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.d)
+	return w.bytes()
+}
+
+func (op *maxArgIndicesOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.d = r.readInt()
+	r.checkAxes(op.d)
+	return r.done()
+}
+
+func (op maxArgIndicesOp) String() string { return "MaxArgIndices" }
+
+/* TOP K OP */
+
+// topKOp finds the k largest values along an axis, as well as where they are, in descending order of the values. Ties go to the lower index.
+// Like maxWithArgOp, both are packed into one tensor with a new first axis of size 2: packed[0] holds the values and packed[1] holds their indices
+// (in the Dtype of the input), each of which has the shape of the input with the axis shrunk to k. The indices are unpacked with maxArgIndicesOp.
+type topKOp struct {
+	k     int
+	along int // axis
+	d     int
+}
+
+// topKOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Tensor d+1 a
+func (op topKOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(newTensorType(op.d, a), newTensorType(op.d+1, a))
+}
+
+func (op topKOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "topKOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var selected types.Shape
+	if selected, err = op.selectedShape(inputs[0].shape); err != nil {
+		return
+	}
+	return append(types.Shape{2}, selected...), nil
+}
+
+// selectedShape is the shape of the values (and of the indices), which is the input shape with the axis shrunk to k
+func (op topKOp) selectedShape(s types.Shape) (types.Shape, error) {
+	if op.along < 0 || op.along >= len(s) {
+		return nil, errors.Errorf("Cannot select along axis %d of a tensor shaped %v", op.along, s)
+	}
+	if op.k <= 0 || op.k > s[op.along] {
+		return nil, errors.Errorf("Cannot select the top %d of %d along axis %d of a tensor shaped %v", op.k, s[op.along], op.along, s)
+	}
+	retVal := s.Clone()
+	retVal[op.along] = op.k
+	return retVal, nil
+}
+
+func (op topKOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+// SymDiff scatters the gradient of the values back to where the values are. The gradient of the indices is ignored.
+func (op topKOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "topKOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(topKDiffOp(op), inputs[0], output, gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op topKOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "topKOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	odv := output.boundTo.(*dualValue)
+
+	var d Value
+	if d, err = topKDiffOp(op).Do(xdv.Value, odv.Value, odv.d); err != nil {
+		return errors.Wrap(err, "topKOp.DoDiff()")
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op topKOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "topKOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	t, ok := inputs[0].(Tensor)
+	if !ok {
+		return nil, errors.Errorf("Expected a Tensor. Got %v instead", inputs[0])
+	}
+
+	var selected types.Shape
+	if selected, err = op.selectedShape(t.Shape()); err != nil {
+		return
+	}
+	outer, n, inner := splitAlong(t.Shape(), op.along)
+
+	var x []float64
+	switch tt := t.Tensor.(type) {
+	case *tf64.Tensor:
+		x = materializedF64s(tt)
+	case *tf32.Tensor:
+		x = f32sToF64s(materializedF32s(tt))
+	default:
+		return nil, errors.Errorf(nyiFail, "topKOp.Do()", t.Tensor)
+	}
+
+	size := outer * op.k * inner
+	packed := make([]float64, 2*size)
+	lane := make([]float64, n)
+	idx := make([]int, op.k)
+	for o := 0; o < outer; o++ {
+		for i := 0; i < inner; i++ {
+			base := o*n*inner + i
+			for j := range lane {
+				lane[j] = x[base+j*inner]
+			}
+			topKf64(lane, idx)
+
+			for j, arg := range idx {
+				at := o*op.k*inner + j*inner + i
+				packed[at] = lane[arg]
+				packed[size+at] = float64(arg)
+			}
+		}
+	}
+
+	shape := append(types.Shape{2}, selected...)
+	if t.Dtype() == Float32 {
+		return FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(packed)), tf32.WithShape(shape...))), nil
+	}
+	return FromTensor(tf64.NewTensor(tf64.WithBacking(packed), tf64.WithShape(shape...))), nil
+}
+
+func (op topKOp) returnsPtr() bool    { return false }
+func (op topKOp) callsExtern() bool   { return false }
+func (op topKOp) overwriteInput() int { return -1 }
+func (op topKOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("topK"))
+	if err := binary.Write(h, binary.LittleEndian, int64(op.k)); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, int64(op.along)); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, byte(op.d)); err != nil {
+		panic(err)
+	}
+}
+
+func (op topKOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op topKOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.k)
+	w.writeInt(op.along)
+	w.writeInt(op.d)
+	return w.bytes()
+}
+
+func (op *topKOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.k = r.readInt()
+	op.along = r.readInt()
+	op.d = r.readInt()
+	r.check(op.k > 0, "Expected a positive k. Got %d instead", op.k)
+	r.checkAxes(op.d, op.along)
+	return r.done()
+}
+
+func (op topKOp) String() string { return fmt.Sprintf("TopK(%d, %d)", op.k, op.along) }
+
+// topKDiffOp is the derivative of topKOp. It takes the input, the packed output and the gradient of the packed output,
+// and scatters the gradient of each value to where the value is. Everything else gets 0.
+type topKDiffOp struct {
+	k     int
+	along int
+	d     int
+}
+
+// topKDiffOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Tensor d+1 a → Tensor d+1 a → Tensor d a
+func (op topKDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(op.d, a)
+	packed := newTensorType(op.d+1, a)
+	return newFunctionType(t, packed, packed, t)
+}
+
+func (op topKDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "topKDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op topKDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op topKDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op topKDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "topKDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	x, ok := inputs[0].(Tensor)
+	if !ok {
+		return nil, errors.Errorf("Expected a Tensor. Got %v instead", inputs[0])
+	}
+	outer, n, inner := splitAlong(x.Shape(), op.along)
+	size := outer * op.k * inner
+
+	var packed, grad []float64
+	for i, v := range inputs[1:] {
+		var data []float64
+		switch vt := v.(type) {
+		case Tensor:
+			switch tt := vt.Tensor.(type) {
+			case *tf64.Tensor:
+				data = materializedF64s(tt)
+			case *tf32.Tensor:
+				data = f32sToF64s(materializedF32s(tt))
+			}
+		}
+		if len(data) != 2*size {
+			return nil, errors.Errorf("Expected a packed top %d and index tensor of %d elements. Got %v instead", op.k, 2*size, v)
+		}
+		if i == 0 {
+			packed = data
+		} else {
+			grad = data
+		}
+	}
+
+	dx := make([]float64, x.Shape().TotalSize())
+	for o := 0; o < outer; o++ {
+		for j := 0; j < op.k; j++ {
+			for i := 0; i < inner; i++ {
+				at := o*op.k*inner + j*inner + i
+				arg := int(packed[size+at])
+				dx[o*n*inner+arg*inner+i] += grad[at]
+			}
+		}
+	}
+
+	if x.Dtype() == Float32 {
+		return FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(dx)), tf32.WithShape(x.Shape().Clone()...))), nil
+	}
+	return FromTensor(tf64.NewTensor(tf64.WithBacking(dx), tf64.WithShape(x.Shape().Clone()...))), nil
+}
+
+func (op topKDiffOp) returnsPtr() bool    { return false }
+func (op topKDiffOp) callsExtern() bool   { return false }
+func (op topKDiffOp) overwriteInput() int { return -1 }
+func (op topKDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	topKOp(op).WriteHash(h)
+}
+
+func (op topKDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op topKDiffOp) MarshalBinary() ([]byte, error) {
+	return topKOp(op).MarshalBinary()
+}
+
+func (op *topKDiffOp) UnmarshalBinary(data []byte) error {
+	return (*topKOp)(op).UnmarshalBinary(data)
+}
+
+func (op topKDiffOp) String() string { return fmt.Sprintf("∂TopK(%d, %d)", op.k, op.along) }
+
+/* SUM OP */
+
+type sumOp struct {
+	along      axes
+	d          int
+	inputShape types.Shape
+}
+
+func newSumOp(along axes, s types.Shape, d int) sumOp {
+	return sumOp{
+		along:      along,
+		d:          d,
+		inputShape: s,
+	}
+}
+
+// sumOp is a function with this type:
+//		sumOp :: (Summable a) ⇒ Tensor d a → Tensor d-n a
+// where n is the number of axes summed along
+func (op sumOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(summable))
+	t := newTensorType(op.d, a)
+	var retType Type
+	if op.d == 1 || len(op.along) == 0 || len(op.along) == op.d {
+		// then it redueces down
+		retType = a
+		return newFunctionType(t, a)
+	} else {
+		retType = newTensorType(op.d-len(op.along), a)
+	}
+	return newFunctionType(t, retType)
+}
+
+func (op sumOp) inferShape(t Type, inputs ...*Node) (shape types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "sumOp requires only one input")
+		return
+	}
+
+	in := inputs[0]
+	shapeLogf("Infering... Type: %v", t)
+	shapeLogf("input shape: %v", in.shape)
+	switch {
+	case in.IsScalar():
+		shape = scalarShape
+	case in.IsVector() && !in.IsRowVec() && !in.IsColVec():
+		if len(op.along) > 1 || (len(op.along) == 1 && op.along[0] != 0) {
+			return nil, errors.Errorf("Shape mismatch: along is %v. Shape is %v", op.along, in.shape)
+		}
+		shape = scalarShape
+	default:
+		shape = in.Shape().Clone()
+		if len(op.along) > len(shape) {
+			return nil, errors.Errorf("Shape mismatch: %v and %v", shape, op.along)
+		}
+
+		if monotonic, incr1 := types.IsMonotonicInts(op.along); monotonic && incr1 && len(op.along) == len(shape) {
+			shape = scalarShape
+			return
+		}
+
+		for _, a := range op.along {
+			if a < 0 || a >= len(shape) {
+				return nil, errors.Errorf("Axis %d is out of range for the shape %v", a, shape)
+			}
+			shape[a] = 1
+		}
+
+		// matrices keep the summed axis, as a row or column vector. Tensors of more dims lose the summed axes, just like the sum that is computed.
+		if len(shape) > 2 {
+			kept := shape[:0]
+			for i, size := range shape {
+				if !op.along.contains(i) {
+					kept = append(kept, size)
+				}
+			}
+			shape = kept
+		}
+
+		if oneone.Eq(shape) {
+			shape = scalarShape
+		}
+
+	}
+	return
+}
+
+func (op sumOp) DiffWRT(i int) []bool { return []bool{true} }
+
+func (op sumOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "Requires only one input to differentiate sumop")
+		return
+	}
+	// the summed axes of tensors of more than 2 dims are gone, so they are put back as axes of size 1 for the gradient to be repeated along
+	grad := gradNode
+	if x := inputs[0]; len(x.shape) > 2 && !gradNode.IsScalar() {
+		kept := x.shape.Clone()
+		for _, a := range op.along {
+			kept[a] = 1
+		}
+		if grad, err = Reshape(gradNode, kept); err != nil {
+			return nil, errors.Wrap(err, operationError)
+		}
+		grad.setGroup(gradClust)
+	}
+
+	children := make(Nodes, len(op.along)+1)
+	children[0] = grad
+	for i, a := range op.along {
+		var n *Node
+		if n, err = SizeOf(a, inputs[0]); err != nil {
+			return nil, errors.Wrap(err, operationError)
+		}
+		WithGroupName(gradClust)(n)
+		children[i+1] = n
+	}
+
+	retVal = make(Nodes, 1)
+	repeat := newRepeatOp(op.along, children)
+
+	symdiffLogf("repeat: %v", repeat.Type())
+	symdiffLogf("children %#Y", children)
+	symdiffLogf("children: %v", children)
+	retVal[0], err = applyOp(repeat, children...)
+	if err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	retVal[0].setGroup(gradClust)
+	return
+}
+
+func (op sumOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "Requires only one input to differentiate sumop")
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+	xShape := xdv.Value.Shape()
+
+	var T types.Tensor
+	switch ydvd := ydv.d.(type) {
+	case Scalar:
+		switch ydvd.t {
 		case Float64:
 			f := ydvd.v.(float64)
 			T = tf64.NewTensor(tf64.AsScalar(f))
@@ -248,96 +1345,3305 @@ func (op sumOp) DoDiff(inputs Nodes, output *Node) (err error) {
 			f := ydvd.v.(float32)
 			T = tf32.NewTensor(tf32.AsScalar(f))
 		default:
-			return errors.Errorf(nyiFail, "sumOp.DoDiff", ydvd.t)
+			return errors.Errorf(nyiFail, "sumOp.DoDiff", ydvd.t)
+		}
+	case Tensor:
+		T = ydvd.Tensor
+	}
+
+	var val Value
+	if !T.Shape().Eq(xdv.d.Shape()) {
+		// y's gradient is broadcast along all the summed axes and added to x's gradient in one pass, without repeating it axis by axis
+		if xd, ok := xdv.d.(Tensor); ok && op.broadcastAdd(xd.Tensor, T, xShape) {
+			return nil
+		}
+
+		// otherwise it is broadcast into a temporary from the pool, which is returned once it has been added to x's gradient
+		pool := output.g.tempPool()
+		if _, ok := xdv.d.(Tensor); ok && pool != nil {
+			tmp := pool.borrow(ydv.d.Dtype(), xShape)
+			defer pool.put(tmp)
+			if op.broadcastAdd(tmp, T, xShape) {
+				val = FromTensor(tmp)
+			}
+		}
+
+		if val == nil {
+			for _, a := range op.along {
+				if xShape[a] == 1 {
+					continue // don't need to repeat
+				}
+				if T, err = tensor.Repeat(T, a, xShape[a]); err != nil {
+					return errors.Wrapf(err, repFail, a, xShape[a])
+				}
+			}
+			val = FromTensor(T)
+		}
+	} else {
+		val = ydv.d
+	}
+
+	// then just add the two
+	add := newEBOByType(addOpType, xdv.d.Type(), val.Type())
+
+	var d Value
+	if d, err = add.UnsafeDo(xdv.d, val); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+
+	// check if xdv.d is scalar
+	if xdv.d.Type().isScalar() {
+		return xdv.SetDeriv(d)
+	}
+	return
+
+}
+
+func (op sumOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "Expect only one input for sumOp. GOt %v instead", len(inputs))
+		return
+	}
+
+	a := inputs[0]
+	at := a.(Tensor)
+	if Deterministic() && op.sumsAll(at.Tensor) {
+		return sequentialSum(at.Tensor)
+	}
+
+	switch t := at.Tensor.(type) {
+	case *tf64.Tensor:
+		var ret *tf64.Tensor
+		if ret, err = t.Sum(op.along...); err == nil {
+			if ret.IsScalar() {
+				retVal = NewScalarValue(ret.ScalarValue())
+			} else {
+				retVal = FromTensor(ret)
+			}
+		} else {
+			return nil, errors.Wrap(err, "failed to apply *tf64.Tensor.Sum()")
+		}
+	case *tf32.Tensor:
+		var ret *tf32.Tensor
+		if ret, err = t.Sum(op.along...); err == nil {
+			if ret.IsScalar() {
+				retVal = NewScalarValue(ret.ScalarValue())
+			} else {
+				retVal = FromTensor(ret)
+			}
+		} else {
+			return nil, errors.Wrap(err, "failed to apply *tf32.Tensor.Sum()")
+		}
+	default:
+		return nil, errors.Errorf(nyiFail, "sumOp.Do()", at.Tensor)
+	}
+	return
+}
+
+// broadcastAdd adds y, the gradient of the output, to x, the gradient of the input, broadcasting y along the summed axes.
+// It gives the same results as repeating y along each of the summed axes and adding the result to x, but it does not allocate any intermediate tensors.
+// It returns false if x and y cannot be added this way (x is a view, say), in which case they should be repeated and added.
+func (op sumOp) broadcastAdd(x, y types.Tensor, shape types.Shape) bool {
+	strides, size := op.broadcastStrides(shape)
+	ySize := y.Shape().TotalSize()
+	if y.Shape().IsScalar() {
+		ySize = 1
+	}
+	if ySize != size {
+		return false
+	}
+
+	switch xt := x.(type) {
+	case *tf64.Tensor:
+		yt, ok := y.(*tf64.Tensor)
+		if !ok || xt.IsMaterializable() {
+			return false
+		}
+		broadcastAddf64(xt.Data().([]float64), materializedF64s(yt), shape, strides)
+	case *tf32.Tensor:
+		yt, ok := y.(*tf32.Tensor)
+		if !ok || xt.IsMaterializable() {
+			return false
+		}
+		broadcastAddf32(xt.Data().([]float32), materializedF32s(yt), shape, strides)
+	default:
+		return false
+	}
+	return true
+}
+
+// broadcastStrides returns the strides with which the output of the sum is walked, one per axis of the input shape, along with the size of the output.
+// The output holds the elements of the axes that are not summed, in row-major order. Along the summed axes it does not move at all.
+func (op sumOp) broadcastStrides(shape types.Shape) (strides []int, size int) {
+	strides = make([]int, len(shape))
+	size = 1
+	for i := len(shape) - 1; i >= 0; i-- {
+		if op.along.contains(i) {
+			continue
+		}
+		strides[i] = size
+		size *= shape[i]
+	}
+	return
+}
+
+// broadcastAddf64 adds y to x, where x is of the given shape. y is walked with the strides, one per axis of x.
+func broadcastAddf64(x, y []float64, shape types.Shape, strides []int) {
+	coord := make([]int, len(shape))
+	var j int
+	for i := range x {
+		x[i] += y[j]
+		for d := len(shape) - 1; d >= 0; d-- {
+			coord[d]++
+			j += strides[d]
+			if coord[d] < shape[d] {
+				break
+			}
+			coord[d] = 0
+			j -= strides[d] * shape[d]
+		}
+	}
+}
+
+// broadcastAddf32 adds y to x, where x is of the given shape. y is walked with the strides, one per axis of x.
+func broadcastAddf32(x, y []float32, shape types.Shape, strides []int) {
+	coord := make([]int, len(shape))
+	var j int
+	for i := range x {
+		x[i] += y[j]
+		for d := len(shape) - 1; d >= 0; d-- {
+			coord[d]++
+			j += strides[d]
+			if coord[d] < shape[d] {
+				break
+			}
+			coord[d] = 0
+			j -= strides[d] * shape[d]
+		}
+	}
+}
+
+// sumsAll checks if the op sums up all the elements of t into a scalar
+func (op sumOp) sumsAll(t types.Tensor) bool {
+	if len(op.along) == 0 {
+		return true
+	}
+	monotonic, incr1 := types.IsMonotonicInts(op.along)
+	return monotonic && incr1 && op.along[0] == 0 && len(op.along) == t.Dims()
+}
+
+// sequentialSum sums up all the elements of t one by one, in row-major order. This is the fixed summation order used when the execution is deterministic.
+func sequentialSum(t types.Tensor) (retVal Value, err error) {
+	switch tt := t.(type) {
+	case *tf64.Tensor:
+		var sum float64
+		for _, v := range materializedF64s(tt) {
+			sum += v
+		}
+		return NewScalarValue(sum), nil
+	case *tf32.Tensor:
+		var sum float32
+		for _, v := range materializedF32s(tt) {
+			sum += v
+		}
+		return NewScalarValue(sum), nil
+	}
+	return nil, errors.Errorf(nyiFail, "sequentialSum", t)
+}
+
+func (op sumOp) returnsPtr() bool    { return true }
+func (op sumOp) overwriteInput() int { return 0 }
+func (op sumOp) callsExtern() bool   { return false }
+
+func (op sumOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("sum"))
+	fmt.Fprintf(h, "%v->%v", op.along, op.inputShape)
+}
+
+func (op sumOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op sumOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInts(op.along)
+	w.writeInt(op.d)
+	w.writeInts(op.inputShape)
+	return w.bytes()
+}
+
+func (op *sumOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.along = axes(r.readInts())
+	op.d = r.readInt()
+	op.inputShape = r.readShape()
+	r.checkAxes(op.d, op.along...)
+	return r.done()
+}
+
+func (op sumOp) String() string { return fmt.Sprintf("Σ%v", op.along) }
+func (op sumOp) isUnary() bool  { return true }
+
+/* MEAN SQUARE OP */
+
+// meanSquareOp computes the mean of the squares of x along the axes in a single pass, without the tensor of squares that Mean(Square(x)) creates.
+// It is the mean square of RMS normalization. The gradient is 2x/N × gradZ, where N is the number of elements each mean is taken over.
+type meanSquareOp struct {
+	along axes
+	d     int
+}
+
+// meanSquareOp has the type of maxOp:
+//		op :: (Float a) ⇒ Tensor d a → Tensor d-len(along) a
+// which is a scalar if every axis is reduced
+func (op meanSquareOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(newTensorType(op.d, a), maxOp{along: op.along, d: op.d}.retType(a))
+}
+
+func (op meanSquareOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "meanSquareOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return maxOp{along: op.along, d: op.d}.reducedShape(inputs[0].shape)
+}
+
+func (op meanSquareOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+func (op meanSquareOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "meanSquareOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(meanSquareDiffOp(op), inputs[0], gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op meanSquareOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "meanSquareOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	diff := meanSquareDiffOp(op)
+	var d Value
+	if d, err = diff.Do(xdv.Value, ydv.d); err != nil {
+		return errors.Wrapf(err, doFail, diff)
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op meanSquareOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "meanSquareOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var x []float64
+	var shp, reduced types.Shape
+	var dt Dtype
+	if x, shp, dt, err = floatsOperand(inputs[0]); err != nil {
+		return
+	}
+	if len(x) == 0 {
+		return nil, errors.Errorf("Cannot find the mean square of an empty tensor shaped %v", shp)
+	}
+	if reduced, err = (maxOp{along: op.along, d: op.d}).reducedShape(shp); err != nil {
+		return
+	}
+
+	strides, size := maxOp{along: op.along}.strides(shp)
+	n := float64(len(x) / size)
+	y := make([]float64, size)
+	forEachReduced(shp, strides, func(i, j int) { y[j] += x[i] * x[i] })
+	for j := range y {
+		y[j] /= n
+	}
+
+	if reduced.IsScalar() {
+		if dt == Float32 {
+			return NewScalarValue(float32(y[0])), nil
+		}
+		return NewScalarValue(y[0]), nil
+	}
+	return floatsValue(y, reduced, dt), nil
+}
+
+func (op meanSquareOp) returnsPtr() bool    { return false }
+func (op meanSquareOp) callsExtern() bool   { return false }
+func (op meanSquareOp) overwriteInput() int { return -1 }
+func (op meanSquareOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("meanSquare"))
+	fmt.Fprintf(h, "%v->%v", op.d, op.along)
+}
+
+func (op meanSquareOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op meanSquareOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInts(op.along)
+	w.writeInt(op.d)
+	return w.bytes()
+}
+
+func (op *meanSquareOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.along = axes(r.readInts())
+	op.d = r.readInt()
+	r.checkAxes(op.d, op.along...)
+	return r.done()
+}
+
+func (op meanSquareOp) String() string { return fmt.Sprintf("MeanSquare%v", op.along) }
+
+// meanSquareDiffOp is the derivative of meanSquareOp. It takes x and the gradient of the output, and returns 2x/N × gradZ,
+// with gradZ broadcast along the reduced axes.
+type meanSquareDiffOp meanSquareOp
+
+// meanSquareDiffOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → b → Tensor d a
+// where b is the type of the output of meanSquareOp
+func (op meanSquareDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(op.d, a)
+	return newFunctionType(t, maxOp{along: op.along, d: op.d}.retType(a), t)
+}
+
+func (op meanSquareDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "meanSquareDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op meanSquareDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op meanSquareDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op meanSquareDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "meanSquareDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var x, grad []float64
+	var shp types.Shape
+	var dt Dtype
+	if x, shp, dt, err = floatsOperand(inputs[0]); err != nil {
+		return
+	}
+	strides, size := maxOp{along: op.along}.strides(shp)
+	if grad, err = reducedFloats(inputs[1], size); err != nil {
+		return nil, errors.Wrap(err, "meanSquareDiffOp.Do()")
+	}
+
+	scale := 2 / float64(len(x)/size)
+	dx := make([]float64, len(x))
+	forEachReduced(shp, strides, func(i, j int) { dx[i] = scale * x[i] * grad[j] })
+	return floatsValue(dx, shp, dt), nil
+}
+
+func (op meanSquareDiffOp) returnsPtr() bool    { return false }
+func (op meanSquareDiffOp) callsExtern() bool   { return false }
+func (op meanSquareDiffOp) overwriteInput() int { return -1 }
+func (op meanSquareDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	meanSquareOp(op).WriteHash(h)
+}
+
+func (op meanSquareDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op meanSquareDiffOp) MarshalBinary() ([]byte, error) {
+	return meanSquareOp(op).MarshalBinary()
+}
+
+func (op *meanSquareDiffOp) UnmarshalBinary(data []byte) error {
+	return (*meanSquareOp)(op).UnmarshalBinary(data)
+}
+
+func (op meanSquareDiffOp) String() string { return fmt.Sprintf("∂MeanSquare%v", op.along) }
+
+/* NAN MEAN OP */
+
+// nanMeanOp computes the mean of x along the axes, leaving out the NaNs: each mean is the sum of the elements that are not NaN,
+// divided by how many of them there are. It reduces like maxOp does. If every element along the axes is a NaN, the mean is 0.
+// The gradient of each element that is not a NaN is gradZ/count, and the gradient of the NaNs is 0.
+type nanMeanOp struct {
+	along axes
+	d     int
+}
+
+// nanMeanOp has the type of maxOp:
+//		op :: (Float a) ⇒ Tensor d a → Tensor d-len(along) a
+// which is a scalar if every axis is reduced
+func (op nanMeanOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(newTensorType(op.d, a), maxOp{along: op.along, d: op.d}.retType(a))
+}
+
+func (op nanMeanOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "nanMeanOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return maxOp{along: op.along, d: op.d}.reducedShape(inputs[0].shape)
+}
+
+func (op nanMeanOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+func (op nanMeanOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "nanMeanOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(nanMeanDiffOp(op), inputs[0], gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op nanMeanOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "nanMeanOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	diff := nanMeanDiffOp(op)
+	var d Value
+	if d, err = diff.Do(xdv.Value, ydv.d); err != nil {
+		return errors.Wrapf(err, doFail, diff)
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op nanMeanOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "nanMeanOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var x []float64
+	var shp, reduced types.Shape
+	var dt Dtype
+	if x, shp, dt, err = floatsOperand(inputs[0]); err != nil {
+		return
+	}
+	if len(x) == 0 {
+		return nil, errors.Errorf("Cannot find the mean of an empty tensor shaped %v", shp)
+	}
+	if reduced, err = (maxOp{along: op.along, d: op.d}).reducedShape(shp); err != nil {
+		return
+	}
+
+	y, counts := op.sumCount(x, shp)
+	for j, c := range counts {
+		if c > 0 {
+			y[j] /= float64(c)
+		}
+	}
+
+	if reduced.IsScalar() {
+		if dt == Float32 {
+			return NewScalarValue(float32(y[0])), nil
+		}
+		return NewScalarValue(y[0]), nil
+	}
+	return floatsValue(y, reduced, dt), nil
+}
+
+// sumCount sums up the elements of x that are not NaNs along the axes, and counts them
+func (op nanMeanOp) sumCount(x []float64, shp types.Shape) (sums []float64, counts []int) {
+	strides, size := maxOp{along: op.along}.strides(shp)
+	sums = make([]float64, size)
+	counts = make([]int, size)
+	forEachReduced(shp, strides, func(i, j int) {
+		if !math.IsNaN(x[i]) {
+			sums[j] += x[i]
+			counts[j]++
+		}
+	})
+	return
+}
+
+func (op nanMeanOp) returnsPtr() bool    { return false }
+func (op nanMeanOp) callsExtern() bool   { return false }
+func (op nanMeanOp) overwriteInput() int { return -1 }
+func (op nanMeanOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("nanMean"))
+	fmt.Fprintf(h, "%v->%v", op.d, op.along)
+}
+
+func (op nanMeanOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op nanMeanOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInts(op.along)
+	w.writeInt(op.d)
+	return w.bytes()
+}
+
+func (op *nanMeanOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.along = axes(r.readInts())
+	op.d = r.readInt()
+	r.checkAxes(op.d, op.along...)
+	return r.done()
+}
+
+func (op nanMeanOp) String() string { return fmt.Sprintf("NanMean%v", op.along) }
+
+// nanMeanDiffOp is the derivative of nanMeanOp. It takes x and the gradient of the output, and returns gradZ/count,
+// with gradZ broadcast along the reduced axes, where x is not a NaN, and 0 where it is.
+type nanMeanDiffOp nanMeanOp
+
+// nanMeanDiffOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → b → Tensor d a
+// where b is the type of the output of nanMeanOp
+func (op nanMeanDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(op.d, a)
+	return newFunctionType(t, maxOp{along: op.along, d: op.d}.retType(a), t)
+}
+
+func (op nanMeanDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "nanMeanDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op nanMeanDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op nanMeanDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op nanMeanDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "nanMeanDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var x, grad []float64
+	var shp types.Shape
+	var dt Dtype
+	if x, shp, dt, err = floatsOperand(inputs[0]); err != nil {
+		return
+	}
+	strides, size := maxOp{along: op.along}.strides(shp)
+	if grad, err = reducedFloats(inputs[1], size); err != nil {
+		return nil, errors.Wrap(err, "nanMeanDiffOp.Do()")
+	}
+
+	// an axis that is all NaNs has a count of 0, but none of its elements gets a gradient, so the count is never divided by
+	_, counts := nanMeanOp(op).sumCount(x, shp)
+	dx := make([]float64, len(x))
+	forEachReduced(shp, strides, func(i, j int) {
+		if !math.IsNaN(x[i]) {
+			dx[i] = grad[j] / float64(counts[j])
+		}
+	})
+	return floatsValue(dx, shp, dt), nil
+}
+
+func (op nanMeanDiffOp) returnsPtr() bool    { return false }
+func (op nanMeanDiffOp) callsExtern() bool   { return false }
+func (op nanMeanDiffOp) overwriteInput() int { return -1 }
+func (op nanMeanDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	nanMeanOp(op).WriteHash(h)
+}
+
+func (op nanMeanDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op nanMeanDiffOp) MarshalBinary() ([]byte, error) {
+	return nanMeanOp(op).MarshalBinary()
+}
+
+func (op *nanMeanDiffOp) UnmarshalBinary(data []byte) error {
+	return (*nanMeanOp)(op).UnmarshalBinary(data)
+}
+
+func (op nanMeanDiffOp) String() string { return fmt.Sprintf("∂NanMean%v", op.along) }
+
+/* PROD OP */
+
+// prodOp multiplies the elements of x along the axes. It reduces like maxOp does.
+//
+// The gradient of x_i is the product of the other elements along the axes. The usual shortcut, prod/x_i, divides by zero as soon as an element is 0,
+// so prodDiffOp counts the zeros instead:
+//		no zeros:	∂x_i = prod × gradZ / x_i
+//		one zero:	the gradient of the zero is the product of the rest × gradZ, and every other gradient is 0 (their products include the zero)
+//		more zeros:	every product of the rest includes a zero, so every gradient is 0
+type prodOp struct {
+	along axes
+	d     int
+}
+
+// prodOp has the type of maxOp:
+//		op :: (Float a) ⇒ Tensor d a → Tensor d-len(along) a
+// which is a scalar if every axis is reduced
+func (op prodOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(newTensorType(op.d, a), maxOp{along: op.along, d: op.d}.retType(a))
+}
+
+func (op prodOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "prodOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return maxOp{along: op.along, d: op.d}.reducedShape(inputs[0].shape)
+}
+
+func (op prodOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+func (op prodOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "prodOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(prodDiffOp(op), inputs[0], gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op prodOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "prodOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	diff := prodDiffOp(op)
+	var d Value
+	if d, err = diff.Do(xdv.Value, ydv.d); err != nil {
+		return errors.Wrapf(err, doFail, diff)
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op prodOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "prodOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var x []float64
+	var shp, reduced types.Shape
+	var dt Dtype
+	if x, shp, dt, err = floatsOperand(inputs[0]); err != nil {
+		return
+	}
+	if reduced, err = (maxOp{along: op.along, d: op.d}).reducedShape(shp); err != nil {
+		return
+	}
+
+	strides, size := maxOp{along: op.along}.strides(shp)
+	y := make([]float64, size)
+	for j := range y {
+		y[j] = 1
+	}
+	forEachReduced(shp, strides, func(i, j int) { y[j] *= x[i] })
+
+	if reduced.IsScalar() {
+		if dt == Float32 {
+			return NewScalarValue(float32(y[0])), nil
+		}
+		return NewScalarValue(y[0]), nil
+	}
+	return floatsValue(y, reduced, dt), nil
+}
+
+func (op prodOp) returnsPtr() bool    { return false }
+func (op prodOp) callsExtern() bool   { return false }
+func (op prodOp) overwriteInput() int { return -1 }
+func (op prodOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("prod"))
+	fmt.Fprintf(h, "%v->%v", op.d, op.along)
+}
+
+func (op prodOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op prodOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInts(op.along)
+	w.writeInt(op.d)
+	return w.bytes()
+}
+
+func (op *prodOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.along = axes(r.readInts())
+	op.d = r.readInt()
+	r.checkAxes(op.d, op.along...)
+	return r.done()
+}
+
+func (op prodOp) String() string { return fmt.Sprintf("Π%v", op.along) }
+
+// prodDiffOp is the derivative of prodOp. It takes x and the gradient of the output, and returns the product of the rest of the elements × gradZ,
+// with gradZ broadcast along the reduced axes. See prodOp for how zeros are handled.
+type prodDiffOp prodOp
+
+// prodDiffOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → b → Tensor d a
+// where b is the type of the output of prodOp
+func (op prodDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(op.d, a)
+	return newFunctionType(t, maxOp{along: op.along, d: op.d}.retType(a), t)
+}
+
+func (op prodDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "prodDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op prodDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op prodDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op prodDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "prodDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var x, grad []float64
+	var shp types.Shape
+	var dt Dtype
+	if x, shp, dt, err = floatsOperand(inputs[0]); err != nil {
+		return
+	}
+	strides, size := maxOp{along: op.along}.strides(shp)
+	if grad, err = reducedFloats(inputs[1], size); err != nil {
+		return nil, errors.Wrap(err, "prodDiffOp.Do()")
+	}
+
+	// the product of the nonzero elements, and the number of zeros, of each product
+	nonzeros := make([]float64, size)
+	zeros := make([]int, size)
+	for j := range nonzeros {
+		nonzeros[j] = 1
+	}
+	forEachReduced(shp, strides, func(i, j int) {
+		if x[i] == 0 {
+			zeros[j]++
+			return
+		}
+		nonzeros[j] *= x[i]
+	})
+
+	dx := make([]float64, len(x))
+	forEachReduced(shp, strides, func(i, j int) {
+		switch {
+		case zeros[j] == 0:
+			dx[i] = nonzeros[j] * grad[j] / x[i]
+		case zeros[j] == 1 && x[i] == 0:
+			dx[i] = nonzeros[j] * grad[j]
+		}
+	})
+	return floatsValue(dx, shp, dt), nil
+}
+
+func (op prodDiffOp) returnsPtr() bool    { return false }
+func (op prodDiffOp) callsExtern() bool   { return false }
+func (op prodDiffOp) overwriteInput() int { return -1 }
+func (op prodDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	prodOp(op).WriteHash(h)
+}
+
+func (op prodDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op prodDiffOp) MarshalBinary() ([]byte, error) {
+	return prodOp(op).MarshalBinary()
+}
+
+func (op *prodDiffOp) UnmarshalBinary(data []byte) error {
+	return (*prodOp)(op).UnmarshalBinary(data)
+}
+
+func (op prodDiffOp) String() string { return fmt.Sprintf("∂Π%v", op.along) }
+
+/* COUNT NONZERO OP */
+
+// The elements whose magnitude is at most these tolerances are counted as zeros by countNonzeroOp,
+// so that what is left over from a subtraction does not count as a nonzero.
+const (
+	nonzeroTolf64 = 1e-12
+	nonzeroTolf32 = 1e-6
+)
+
+// countNonzeroOp counts the nonzero elements along the axes, as Ints. It reduces like maxOp does, and is not differentiable.
+type countNonzeroOp struct {
+	along axes
+	d     int
+}
+
+// countNonzeroOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Tensor d-len(along) Int
+// which is an Int if every axis is reduced
+func (op countNonzeroOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(newTensorType(op.d, a), maxOp{along: op.along, d: op.d}.retType(Int))
+}
+
+func (op countNonzeroOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "countNonzeroOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return maxOp{along: op.along, d: op.d}.reducedShape(inputs[0].shape)
+}
+
+func (op countNonzeroOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op countNonzeroOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op countNonzeroOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "countNonzeroOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	t, ok := inputs[0].(Tensor)
+	if !ok {
+		return nil, errors.Errorf("Expected a Tensor. Got %v of %T instead", inputs[0], inputs[0])
+	}
+	shp := t.Shape()
+
+	var reduced types.Shape
+	if reduced, err = (maxOp{along: op.along, d: op.d}).reducedShape(shp); err != nil {
+		return
+	}
+	strides, size := maxOp{along: op.along}.strides(shp)
+	counts := make([]int, size)
+
+	switch tt := t.Tensor.(type) {
+	case *tf64.Tensor:
+		x := materializedF64s(tt)
+		forEachReduced(shp, strides, func(i, j int) {
+			if math.Abs(x[i]) > nonzeroTolf64 {
+				counts[j]++
+			}
+		})
+	case *tf32.Tensor:
+		x := materializedF32s(tt)
+		forEachReduced(shp, strides, func(i, j int) {
+			if math32.Abs(x[i]) > nonzeroTolf32 {
+				counts[j]++
+			}
+		})
+	default:
+		return nil, errors.Errorf(nyiFail, "countNonzeroOp.Do()", t.Tensor)
+	}
+
+	if reduced.IsScalar() {
+		return NewScalarValue(counts[0]), nil
+	}
+	return FromTensor(ti.NewTensor(ti.WithBacking(counts), ti.WithShape(reduced...))), nil
+}
+
+func (op countNonzeroOp) returnsPtr() bool    { return false }
+func (op countNonzeroOp) callsExtern() bool   { return false }
+func (op countNonzeroOp) overwriteInput() int { return -1 }
+func (op countNonzeroOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("countNonzero"))
+	fmt.Fprintf(h, "%v->%v", op.d, op.along)
+}
+
+func (op countNonzeroOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op countNonzeroOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInts(op.along)
+	w.writeInt(op.d)
+	return w.bytes()
+}
+
+func (op *countNonzeroOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.along = axes(r.readInts())
+	op.d = r.readInt()
+	r.checkAxes(op.d, op.along...)
+	return r.done()
+}
+
+func (op countNonzeroOp) String() string { return fmt.Sprintf("CountNonzero%v", op.along) }
+
+/* FIND FIRST OP */
+
+// notFound is what findFirstOp returns when there is no nonzero element along the axis
+const notFound = -1
+
+// findFirstOp finds the index of the first nonzero (or true) element along an axis, as Ints, or notFound if there isn't one.
+// Unlike countNonzeroOp, every element that is not exactly zero counts, as it is meant for masks. It is not differentiable.
+type findFirstOp struct {
+	axis, d int
+}
+
+// findFirstOp has this type:
+//		op :: (Logical a) ⇒ Tensor d a → Tensor d-1 Int
+// which is an Int if the input is a vector
+func (op findFirstOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(logicals))
+	return newFunctionType(newTensorType(op.d, a), maxOp{along: axes{op.axis}, d: op.d}.retType(Int))
+}
+
+func (op findFirstOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "findFirstOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return maxOp{along: axes{op.axis}, d: op.d}.reducedShape(inputs[0].shape)
+}
+
+func (op findFirstOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op findFirstOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op findFirstOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "findFirstOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var x []bool
+	var shp, reduced types.Shape
+	if x, shp, _, err = logicalOperand(inputs[0]); err != nil {
+		return
+	}
+	if op.axis >= len(shp) {
+		return nil, errors.Errorf(invalidAxis, op.axis, len(shp))
+	}
+	if reduced, err = (maxOp{along: axes{op.axis}, d: op.d}).reducedShape(shp); err != nil {
+		return
+	}
+
+	outer, dim, inner := 1, shp[op.axis], 1
+	for _, size := range shp[:op.axis] {
+		outer *= size
+	}
+	for _, size := range shp[op.axis+1:] {
+		inner *= size
+	}
+	found := make([]int, outer*inner)
+	for o := 0; o < outer; o++ {
+		for in := 0; in < inner; in++ {
+			found[o*inner+in] = notFound
+			for k := 0; k < dim; k++ {
+				if x[(o*dim+k)*inner+in] {
+					found[o*inner+in] = k
+					break
+				}
+			}
+		}
+	}
+
+	if reduced.IsScalar() {
+		return NewScalarValue(found[0]), nil
+	}
+	return FromTensor(ti.NewTensor(ti.WithBacking(found), ti.WithShape(reduced...))), nil
+}
+
+func (op findFirstOp) returnsPtr() bool    { return false }
+func (op findFirstOp) callsExtern() bool   { return false }
+func (op findFirstOp) overwriteInput() int { return -1 }
+func (op findFirstOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("findFirst"))
+	fmt.Fprintf(h, "%v->%v", op.d, op.axis)
+}
+
+func (op findFirstOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op findFirstOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.axis)
+	w.writeInt(op.d)
+	return w.bytes()
+}
+
+func (op *findFirstOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.axis = r.readInt()
+	op.d = r.readInt()
+	r.checkAxes(op.d, op.axis)
+	return r.done()
+}
+
+func (op findFirstOp) String() string { return fmt.Sprintf("FindFirst{axis=%d}", op.axis) }
+
+// logicalOperand reads a Tensor of bools, or of 0s and 1s, as bools. Every number that is not exactly zero is true.
+func logicalOperand(v Value) (x []bool, shp types.Shape, dt Dtype, err error) {
+	t, ok := v.(Tensor)
+	if !ok {
+		err = errors.Errorf("Expected a Tensor. Got %v of %T instead", v, v)
+		return
+	}
+
+	shp = t.Shape()
+	dt = t.Dtype()
+	switch tt := t.Tensor.(type) {
+	case *tb.Tensor:
+		if tt.IsMaterializable() {
+			tt = tt.Materialize().(*tb.Tensor)
+		}
+		x = tt.Data().([]bool)
+	case *tf64.Tensor:
+		data := materializedF64s(tt)
+		x = make([]bool, len(data))
+		for i, v := range data {
+			x[i] = v != 0
+		}
+	case *tf32.Tensor:
+		data := materializedF32s(tt)
+		x = make([]bool, len(data))
+		for i, v := range data {
+			x[i] = v != 0
+		}
+	default:
+		err = errors.Errorf(nyiFail, "logicalOperand", t.Tensor)
+	}
+	return
+}
+
+/* REDUCE ANY/ALL OP */
+
+// reduceLogicalOp reduces a tensor of bools, or of 0s and 1s, with a logical OR (any), or a logical AND (all) if all is set.
+// It reduces like maxOp does, and the result is of the same Dtype as the input: bools for bools, and 0s and 1s for numbers.
+// It is not differentiable.
+type reduceLogicalOp struct {
+	all   bool
+	along axes
+	d     int
+}
+
+// reduceLogicalOp has this type:
+//		op :: (Logical a) ⇒ Tensor d a → Tensor d-len(along) a
+// which is a scalar if every axis is reduced
+func (op reduceLogicalOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(logicals))
+	return newFunctionType(newTensorType(op.d, a), maxOp{along: op.along, d: op.d}.retType(a))
+}
+
+func (op reduceLogicalOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "reduceLogicalOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return maxOp{along: op.along, d: op.d}.reducedShape(inputs[0].shape)
+}
+
+func (op reduceLogicalOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op reduceLogicalOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op reduceLogicalOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "reduceLogicalOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var x []bool
+	var shp, reduced types.Shape
+	var dt Dtype
+	if x, shp, dt, err = logicalOperand(inputs[0]); err != nil {
+		return
+	}
+	if reduced, err = (maxOp{along: op.along, d: op.d}).reducedShape(shp); err != nil {
+		return
+	}
+
+	strides, size := maxOp{along: op.along}.strides(shp)
+	y := make([]bool, size)
+	for j := range y {
+		y[j] = op.all
+	}
+	forEachReduced(shp, strides, func(i, j int) {
+		if op.all {
+			y[j] = y[j] && x[i]
+		} else {
+			y[j] = y[j] || x[i]
+		}
+	})
+
+	if dt == Bool {
+		if reduced.IsScalar() {
+			return NewScalarValue(y[0]), nil
+		}
+		return FromTensor(tb.NewTensor(tb.WithBacking(y), tb.WithShape(reduced...))), nil
+	}
+
+	ys := make([]float64, size)
+	for j, v := range y {
+		if v {
+			ys[j] = 1
+		}
+	}
+	if reduced.IsScalar() {
+		if dt == Float32 {
+			return NewScalarValue(float32(ys[0])), nil
+		}
+		return NewScalarValue(ys[0]), nil
+	}
+	return floatsValue(ys, reduced, dt), nil
+}
+
+func (op reduceLogicalOp) returnsPtr() bool    { return false }
+func (op reduceLogicalOp) callsExtern() bool   { return false }
+func (op reduceLogicalOp) overwriteInput() int { return -1 }
+func (op reduceLogicalOp) WriteHash(h hash.Hash) {
+	if op.all {
+		h.Write([]byte("reduceAll"))
+	} else {
+		h.Write([]byte("reduceAny"))
+	}
+	fmt.Fprintf(h, "%v->%v", op.d, op.along)
+}
+
+func (op reduceLogicalOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op reduceLogicalOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeBool(op.all)
+	w.writeInts(op.along)
+	w.writeInt(op.d)
+	return w.bytes()
+}
+
+func (op *reduceLogicalOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.all = r.readBool()
+	op.along = axes(r.readInts())
+	op.d = r.readInt()
+	r.checkAxes(op.d, op.along...)
+	return r.done()
+}
+
+func (op reduceLogicalOp) String() string {
+	if op.all {
+		return fmt.Sprintf("All%v", op.along)
+	}
+	return fmt.Sprintf("Any%v", op.along)
+}
+
+/* HISTOGRAM OP */
+
+// histogramOp counts the elements of a tensor that fall in each of bins buckets of equal width, which span [min, max).
+// The elements below min are counted in the first bucket, and those at or above max in the last one. NaNs are not counted.
+// It is meant for inspecting activations, and is not differentiable.
+type histogramOp struct {
+	bins     int
+	min, max float64
+	d        int
+}
+
+// histogramOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Vector Int
+func (op histogramOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(newTensorType(op.d, a), newTensorType(1, Int))
+}
+
+func (op histogramOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "histogramOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return types.Shape{op.bins}, nil
+}
+
+func (op histogramOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op histogramOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op histogramOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "histogramOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	t, ok := inputs[0].(Tensor)
+	if !ok {
+		return nil, errors.Errorf("Expected a Tensor. Got %v of %T instead", inputs[0], inputs[0])
+	}
+
+	counts := make([]int, op.bins)
+	switch tt := t.Tensor.(type) {
+	case *tf64.Tensor:
+		for _, v := range materializedF64s(tt) {
+			op.count(counts, v)
+		}
+	case *tf32.Tensor:
+		for _, v := range materializedF32s(tt) {
+			op.count(counts, float64(v))
+		}
+	default:
+		return nil, errors.Errorf(nyiFail, "histogramOp.Do()", t.Tensor)
+	}
+	return FromTensor(ti.NewTensor(ti.WithBacking(counts), ti.WithShape(op.bins))), nil
+}
+
+// count adds v to the bucket it falls in, clamping it into the edge buckets if it is out of range
+func (op histogramOp) count(counts []int, v float64) {
+	if math.IsNaN(v) {
+		return
+	}
+	var bin int
+	switch {
+	case v < op.min:
+		bin = 0
+	case v >= op.max:
+		bin = op.bins - 1
+	default:
+		// just below max, (v - min) / (max - min) may round up to 1
+		bin = int((v - op.min) / (op.max - op.min) * float64(op.bins))
+		if bin >= op.bins {
+			bin = op.bins - 1
+		}
+	}
+	counts[bin]++
+}
+
+func (op histogramOp) returnsPtr() bool    { return false }
+func (op histogramOp) callsExtern() bool   { return false }
+func (op histogramOp) overwriteInput() int { return -1 }
+func (op histogramOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("histogram"))
+	if err := binary.Write(h, binary.LittleEndian, int64(op.bins)); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, op.min); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, op.max); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, byte(op.d)); err != nil {
+		panic(err)
+	}
+}
+
+func (op histogramOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op histogramOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.bins)
+	w.writeFloat(op.min)
+	w.writeFloat(op.max)
+	w.writeInt(op.d)
+	return w.bytes()
+}
+
+// UnmarshalBinary checks the parameters the way Histogram does
+func (op *histogramOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.bins = r.readInt()
+	op.min = r.readFloat()
+	op.max = r.readFloat()
+	op.d = r.readInt()
+	r.check(op.bins > 0, "Expected at least one bin. Got %d instead", op.bins)
+	r.check(op.min < op.max && !math.IsInf(op.min, 0) && !math.IsInf(op.max, 0), "Expected a finite range with min < max. Got [%v, %v) instead", op.min, op.max)
+	r.checkAxes(op.d)
+	return r.done()
+}
+
+func (op histogramOp) String() string {
+	return fmt.Sprintf("Histogram(%d, [%v, %v))", op.bins, op.min, op.max)
+}
+
+/* LOG SUM EXP OP */
+
+// logSumExpOp computes log Σ exp(x) along an axis, as
+//		y = max(x) + log Σ exp(x - max(x))
+// so that the exponentials neither overflow nor all underflow to 0.
+// The gradient is the softmax along the axis, which is exp(x - y): y already holds both the max and the log of the sum,
+// so the backwards pass needs neither a max nor a sum of its own, just one exponential per element.
+type logSumExpOp struct {
+	along int // axis
+	d     int
+}
+
+// logSumExpOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Tensor d-1 a
+// which is a scalar for vectors
+func (op logSumExpOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(newTensorType(op.d, a), op.retType(a))
+}
+
+func (op logSumExpOp) retType(a Type) Type {
+	if op.d == 1 {
+		return a
+	}
+	return newTensorType(op.d-1, a)
+}
+
+func (op logSumExpOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "logSumExpOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return maxWithArgOp(op).reducedShape(inputs[0].shape)
+}
+
+func (op logSumExpOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+func (op logSumExpOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "logSumExpOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(logSumExpDiffOp(op), inputs[0], output, gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op logSumExpOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "logSumExpOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	diff := logSumExpDiffOp(op)
+	var d Value
+	if d, err = diff.Do(xdv.Value, ydv.Value, ydv.d); err != nil {
+		return errors.Wrapf(err, doFail, diff)
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op logSumExpOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "logSumExpOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var x []float64
+	var shp, reduced types.Shape
+	var dt Dtype
+	if x, shp, dt, err = floatsOperand(inputs[0]); err != nil {
+		return
+	}
+	if reduced, err = maxWithArgOp(op).reducedShape(shp); err != nil {
+		return
+	}
+	outer, n, inner := maxWithArgOp(op).strides(shp)
+	if n == 0 {
+		return nil, errors.Errorf("Cannot compute the log sum exp of an empty axis of a tensor shaped %v", shp)
+	}
+
+	y := make([]float64, outer*inner)
+	for o := 0; o < outer; o++ {
+		for i := 0; i < inner; i++ {
+			base := o*n*inner + i
+			max := x[base]
+			for k := 1; k < n; k++ {
+				max = math.Max(max, x[base+k*inner])
+			}
+
+			// all -∞ (or one +∞) has nothing left to shift
+			if math.IsInf(max, 0) {
+				y[o*inner+i] = max
+				continue
+			}
+
+			var sum float64
+			for k := 0; k < n; k++ {
+				sum += math.Exp(x[base+k*inner] - max)
+			}
+			y[o*inner+i] = max + math.Log(sum)
+		}
+	}
+
+	if reduced.IsScalar() {
+		if dt == Float32 {
+			return NewScalarValue(float32(y[0])), nil
+		}
+		return NewScalarValue(y[0]), nil
+	}
+	return floatsValue(y, reduced, dt), nil
+}
+
+func (op logSumExpOp) returnsPtr() bool    { return false }
+func (op logSumExpOp) callsExtern() bool   { return false }
+func (op logSumExpOp) overwriteInput() int { return -1 }
+func (op logSumExpOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("logSumExp"))
+	if err := binary.Write(h, binary.LittleEndian, int64(op.along)); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, byte(op.d)); err != nil {
+		panic(err)
+	}
+}
+
+func (op logSumExpOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op logSumExpOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.along)
+	w.writeInt(op.d)
+	return w.bytes()
+}
+
+func (op *logSumExpOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.along = r.readInt()
+	op.d = r.readInt()
+	r.checkAxes(op.d, op.along)
+	return r.done()
+}
+
+func (op logSumExpOp) String() string { return fmt.Sprintf("LogSumExp(%d)", op.along) }
+
+// logSumExpDiffOp is the derivative of logSumExpOp. It takes x, the output y and the gradient of y, and returns exp(x - y) × gradY,
+// with y and gradY broadcast along the axis.
+type logSumExpDiffOp logSumExpOp
+
+// logSumExpDiffOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → b → b → Tensor d a
+// where b is the type of the output of logSumExpOp
+func (op logSumExpDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(op.d, a)
+	b := logSumExpOp(op).retType(a)
+	return newFunctionType(t, b, b, t)
+}
+
+func (op logSumExpDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "logSumExpDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op logSumExpDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op logSumExpDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op logSumExpDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "logSumExpDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var x, y, grad []float64
+	var shp types.Shape
+	var dt Dtype
+	if x, shp, dt, err = floatsOperand(inputs[0]); err != nil {
+		return
+	}
+	outer, n, inner := maxWithArgOp(op).strides(shp)
+	if y, err = reducedFloats(inputs[1], outer*inner); err != nil {
+		return nil, errors.Wrap(err, "logSumExpDiffOp.Do()")
+	}
+	if grad, err = reducedFloats(inputs[2], outer*inner); err != nil {
+		return nil, errors.Wrap(err, "logSumExpDiffOp.Do()")
+	}
+
+	dx := make([]float64, len(x))
+	for o := 0; o < outer; o++ {
+		for i := 0; i < inner; i++ {
+			lse, g := y[o*inner+i], grad[o*inner+i]
+			if math.IsInf(lse, -1) {
+				continue // every element is -∞, and none of them can change the output
+			}
+			base := o*n*inner + i
+			for k := 0; k < n; k++ {
+				dx[base+k*inner] = math.Exp(x[base+k*inner]-lse) * g
+			}
+		}
+	}
+	return floatsValue(dx, shp, dt), nil
+}
+
+func (op logSumExpDiffOp) returnsPtr() bool    { return false }
+func (op logSumExpDiffOp) callsExtern() bool   { return false }
+func (op logSumExpDiffOp) overwriteInput() int { return -1 }
+func (op logSumExpDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	logSumExpOp(op).WriteHash(h)
+}
+
+func (op logSumExpDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op logSumExpDiffOp) MarshalBinary() ([]byte, error) {
+	return logSumExpOp(op).MarshalBinary()
+}
+
+func (op *logSumExpDiffOp) UnmarshalBinary(data []byte) error {
+	return (*logSumExpOp)(op).UnmarshalBinary(data)
+}
+
+func (op logSumExpDiffOp) String() string { return fmt.Sprintf("∂LogSumExp(%d)", op.along) }
+
+/* FOLD OP */
+
+// foldIDs hands out the ids of the foldOps. Like applyFnOp, a foldOp is made unique by its id, as its functions cannot be compared or hashed.
+var foldIDs uint64
+
+// foldOp reduces along an axis with a Go function, as a left fold that starts from init:
+//		y = f(…f(f(init, x₀), x₁)…, xₙ₋₁)
+// df is the derivative of y wrt an element x, given x and y. It may be nil, in which case the op is not differentiable.
+// The gradient is df(x, y) × gradY, with y and gradY broadcast along the axis.
+//
+// Vectors (d == 1) are reduced down to a scalar.
+type foldOp struct {
+	along int // axis
+	d     int
+
+	f    func(a, b float64) float64
+	df   func(x, y float64) float64
+	init float64
+	id   uint64
+}
+
+func newFoldOp(along, d int, f func(a, b float64) float64, init float64, df func(x, y float64) float64) foldOp {
+	return foldOp{
+		along: along,
+		d:     d,
+		f:     f,
+		df:    df,
+		init:  init,
+		id:    atomic.AddUint64(&foldIDs, 1),
+	}
+}
+
+// foldOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Tensor d-1 a
+// which is a scalar for vectors
+func (op foldOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(newTensorType(op.d, a), op.retType(a))
+}
+
+func (op foldOp) retType(a Type) Type { return logSumExpOp(op.axis()).retType(a) }
+
+// axis returns the maxWithArgOp that reduces along the same axis, which shapes and strides the fold
+func (op foldOp) axis() maxWithArgOp { return maxWithArgOp{along: op.along, d: op.d} }
+
+// inferShape drops the axis that is folded along
+func (op foldOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "foldOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return op.axis().reducedShape(inputs[0].shape)
+}
+
+func (op foldOp) DiffWRT(inputs int) []bool { return []bool{op.df != nil} }
+
+func (op foldOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "foldOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	if op.df == nil {
+		return nil, nondiffErr(op)
+	}
+
+	var dx *Node
+	if dx, err = applyOp(foldDiffOp(op), inputs[0], output, gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op foldOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "foldOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	if op.df == nil {
+		return nondiffErr(op)
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	diff := foldDiffOp(op)
+	var d Value
+	if d, err = diff.Do(xdv.Value, ydv.Value, ydv.d); err != nil {
+		return errors.Wrapf(err, doFail, diff)
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op foldOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "foldOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var x []float64
+	var shp, reduced types.Shape
+	var dt Dtype
+	if x, shp, dt, err = floatsOperand(inputs[0]); err != nil {
+		return
+	}
+	if reduced, err = op.axis().reducedShape(shp); err != nil {
+		return
+	}
+	outer, n, inner := op.axis().strides(shp)
+
+	y := make([]float64, outer*inner)
+	for o := 0; o < outer; o++ {
+		for i := 0; i < inner; i++ {
+			base := o*n*inner + i
+			acc := op.init
+			for k := 0; k < n; k++ {
+				acc = op.f(acc, x[base+k*inner])
+			}
+			y[o*inner+i] = acc
+		}
+	}
+
+	if reduced.IsScalar() {
+		if dt == Float32 {
+			return NewScalarValue(float32(y[0])), nil
+		}
+		return NewScalarValue(y[0]), nil
+	}
+	return floatsValue(y, reduced, dt), nil
+}
+
+func (op foldOp) returnsPtr() bool    { return false }
+func (op foldOp) callsExtern() bool   { return false }
+func (op foldOp) overwriteInput() int { return -1 }
+func (op foldOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("fold"))
+	if err := binary.Write(h, binary.LittleEndian, op.id); err != nil {
+		panic(err)
+	}
+}
+
+func (op foldOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// MarshalBinary always fails. The functions a foldOp folds with are Go code, which cannot be written out
+func (op foldOp) MarshalBinary() ([]byte, error) {
+	return nil, errors.Errorf("%v cannot be encoded: it folds with Go functions. Register an op of its own instead", op)
+}
+
+func (op foldOp) String() string { return fmt.Sprintf("Fold#%d(%d)", op.id, op.along) }
+
+// foldDiffOp is the derivative of foldOp. It takes x, the output y and the gradient of y, and returns df(x, y) × gradY,
+// with y and gradY broadcast along the axis.
+type foldDiffOp foldOp
+
+// foldDiffOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → b → b → Tensor d a
+// where b is the type of the output of foldOp
+func (op foldDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(op.d, a)
+	b := foldOp(op).retType(a)
+	return newFunctionType(t, b, b, t)
+}
+
+func (op foldDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "foldDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op foldDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op foldDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op foldDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "foldDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+	if op.df == nil {
+		return nil, nondiffErr(foldOp(op))
+	}
+
+	var x, y, grad []float64
+	var shp types.Shape
+	var dt Dtype
+	if x, shp, dt, err = floatsOperand(inputs[0]); err != nil {
+		return
+	}
+	outer, n, inner := foldOp(op).axis().strides(shp)
+	if y, err = reducedFloats(inputs[1], outer*inner); err != nil {
+		return nil, errors.Wrap(err, "foldDiffOp.Do()")
+	}
+	if grad, err = reducedFloats(inputs[2], outer*inner); err != nil {
+		return nil, errors.Wrap(err, "foldDiffOp.Do()")
+	}
+
+	dx := make([]float64, len(x))
+	for o := 0; o < outer; o++ {
+		for i := 0; i < inner; i++ {
+			out, g := y[o*inner+i], grad[o*inner+i]
+			base := o*n*inner + i
+			for k := 0; k < n; k++ {
+				dx[base+k*inner] = op.df(x[base+k*inner], out) * g
+			}
+		}
+	}
+	return floatsValue(dx, shp, dt), nil
+}
+
+func (op foldDiffOp) returnsPtr() bool    { return false }
+func (op foldDiffOp) callsExtern() bool   { return false }
+func (op foldDiffOp) overwriteInput() int { return -1 }
+func (op foldDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	foldOp(op).WriteHash(h)
+}
+
+func (op foldDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// MarshalBinary always fails, for the same reason foldOp.MarshalBinary does
+func (op foldDiffOp) MarshalBinary() ([]byte, error) {
+	return nil, errors.Errorf("%v cannot be encoded: it folds with Go functions. Register an op of its own instead", op)
+}
+
+func (op foldDiffOp) String() string { return fmt.Sprintf("∂Fold#%d(%d)", op.id, op.along) }
+
+/* CUMULATIVE MAX OP */
+
+// cumMaxOp computes the running max along an axis: the kth element along the axis is the max of the first k+1 elements.
+// The gradient of every element of the output goes to the element of the input that is the running max there. Ties go to the lower index,
+// so that the gradient stays with the first element to reach the max, and NaNs never become the running max once there is one.
+type cumMaxOp struct {
+	along int // axis
+	d     int
+}
+
+// cumMaxOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Tensor d a
+func (op cumMaxOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(op.d, a)
+	return newFunctionType(t, t)
+}
+
+// inferShape is the identity: the running max has one element for every element of the input
+func (op cumMaxOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "cumMaxOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op cumMaxOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+func (op cumMaxOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "cumMaxOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(cumMaxDiffOp(op), inputs[0], gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op cumMaxOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "cumMaxOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	diff := cumMaxDiffOp(op)
+	var d Value
+	if d, err = diff.Do(xdv.Value, ydv.d); err != nil {
+		return errors.Wrapf(err, doFail, diff)
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], output)
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op cumMaxOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "cumMaxOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var x []float64
+	var shp types.Shape
+	var dt Dtype
+	if x, shp, dt, err = floatsOperand(inputs[0]); err != nil {
+		return
+	}
+
+	y := make([]float64, len(x))
+	op.scan(x, shp, func(k, best int) { y[k] = x[best] })
+	return floatsValue(y, shp, dt), nil
+}
+
+// scan walks along the axis, and calls fn with the index of every element and the index of the running max there
+func (op cumMaxOp) scan(x []float64, shp types.Shape, fn func(k, best int)) {
+	outer, n, inner := maxWithArgOp(op).strides(shp)
+	for o := 0; o < outer; o++ {
+		for i := 0; i < inner; i++ {
+			base := o*n*inner + i
+			best := base
+			for k := 0; k < n; k++ {
+				j := base + k*inner
+				if v := x[j]; v > x[best] || (math.IsNaN(x[best]) && !math.IsNaN(v)) {
+					best = j
+				}
+				fn(j, best)
+			}
+		}
+	}
+}
+
+func (op cumMaxOp) returnsPtr() bool    { return false }
+func (op cumMaxOp) callsExtern() bool   { return false }
+func (op cumMaxOp) overwriteInput() int { return -1 }
+func (op cumMaxOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("cumMax"))
+	if err := binary.Write(h, binary.LittleEndian, byte(op.along)); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, byte(op.d)); err != nil {
+		panic(err)
+	}
+}
+
+func (op cumMaxOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op cumMaxOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.along)
+	w.writeInt(op.d)
+	return w.bytes()
+}
+
+func (op *cumMaxOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.along = r.readInt()
+	op.d = r.readInt()
+	r.checkAxes(op.d, op.along)
+	return r.done()
+}
+
+func (op cumMaxOp) String() string { return fmt.Sprintf("CumMax(%d)", op.along) }
+
+// cumMaxDiffOp is the derivative of cumMaxOp. It takes x and the gradient of the output, and adds up the gradient of every element of the output
+// at the element of x that is the running max there.
+type cumMaxDiffOp cumMaxOp
+
+// cumMaxDiffOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Tensor d a → Tensor d a
+func (op cumMaxDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(op.d, a)
+	return newFunctionType(t, t, t)
+}
+
+func (op cumMaxDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "cumMaxDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op cumMaxDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op cumMaxDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op cumMaxDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "cumMaxDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var x, grad []float64
+	var shp types.Shape
+	var dt Dtype
+	if x, shp, dt, err = floatsOperand(inputs[0]); err != nil {
+		return
+	}
+	if grad, _, _, err = floatsOperand(inputs[1]); err != nil {
+		return
+	}
+	if len(grad) != len(x) {
+		return nil, errors.Errorf("Expected a gradient shaped %v. Got %v instead", shp, inputs[1].Shape())
+	}
+
+	dx := make([]float64, len(x))
+	cumMaxOp(op).scan(x, shp, func(k, best int) { dx[best] += grad[k] })
+	return floatsValue(dx, shp, dt), nil
+}
+
+func (op cumMaxDiffOp) returnsPtr() bool    { return false }
+func (op cumMaxDiffOp) callsExtern() bool   { return false }
+func (op cumMaxDiffOp) overwriteInput() int { return -1 }
+func (op cumMaxDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	cumMaxOp(op).WriteHash(h)
+}
+
+func (op cumMaxDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op cumMaxDiffOp) MarshalBinary() ([]byte, error) {
+	return cumMaxOp(op).MarshalBinary()
+}
+
+func (op *cumMaxDiffOp) UnmarshalBinary(data []byte) error {
+	return (*cumMaxOp)(op).UnmarshalBinary(data)
+}
+
+func (op cumMaxDiffOp) String() string { return fmt.Sprintf("∂CumMax(%d)", op.along) }
+
+/* MEDIAN OP */
+
+// medianOp finds the median along an axis. Of an even number of elements, the median is the mean of the two in the middle.
+// The gradient goes to the element in the middle, or is split in halves between the two in the middle. NaNs are sorted after every number,
+// and equal elements keep their order along the axis, so that the gradient always goes to the same elements.
+//
+// Vectors (d == 1) are reduced down to a scalar.
+type medianOp struct {
+	along int // axis
+	d     int
+}
+
+// medianOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Tensor d-1 a
+// which is a scalar for vectors
+func (op medianOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(newTensorType(op.d, a), logSumExpOp(op).retType(a))
+}
+
+// inferShape drops the axis the median is found along
+func (op medianOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "medianOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return maxWithArgOp(op).reducedShape(inputs[0].shape)
+}
+
+func (op medianOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+func (op medianOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "medianOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(medianDiffOp(op), inputs[0], gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op medianOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "medianOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	diff := medianDiffOp(op)
+	var d Value
+	if d, err = diff.Do(xdv.Value, ydv.d); err != nil {
+		return errors.Wrapf(err, doFail, diff)
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op medianOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "medianOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var x []float64
+	var shp, reduced types.Shape
+	var dt Dtype
+	if x, shp, dt, err = floatsOperand(inputs[0]); err != nil {
+		return
+	}
+	if reduced, err = maxWithArgOp(op).reducedShape(shp); err != nil {
+		return
+	}
+
+	var lo, hi []int
+	if lo, hi, err = op.middle(x, shp); err != nil {
+		return
+	}
+	y := make([]float64, len(lo))
+	for j := range y {
+		y[j] = (x[lo[j]] + x[hi[j]]) / 2
+	}
+
+	if reduced.IsScalar() {
+		if dt == Float32 {
+			return NewScalarValue(float32(y[0])), nil
+		}
+		return NewScalarValue(y[0]), nil
+	}
+	return floatsValue(y, reduced, dt), nil
+}
+
+// middle finds the indices into x of the two elements in the middle of each run of elements along the axis, once sorted.
+// Of an odd number of elements, both are the same one.
+func (op medianOp) middle(x []float64, shp types.Shape) (lo, hi []int, err error) {
+	outer, n, inner := maxWithArgOp(op).strides(shp)
+	if n == 0 {
+		return nil, nil, errors.Errorf("Cannot find the median of an empty axis of a tensor shaped %v", shp)
+	}
+
+	lo = make([]int, outer*inner)
+	hi = make([]int, outer*inner)
+	idx := make([]int, n)
+	for j := range lo {
+		base := (j/inner)*n*inner + j%inner
+		for k := range idx {
+			idx[k] = base + k*inner
+		}
+		sort.SliceStable(idx, func(a, b int) bool {
+			xa, xb := x[idx[a]], x[idx[b]]
+			return xa < xb || (!math.IsNaN(xa) && math.IsNaN(xb))
+		})
+		lo[j], hi[j] = idx[(n-1)/2], idx[n/2]
+	}
+	return
+}
+
+func (op medianOp) returnsPtr() bool    { return false }
+func (op medianOp) callsExtern() bool   { return false }
+func (op medianOp) overwriteInput() int { return -1 }
+func (op medianOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("median"))
+	if err := binary.Write(h, binary.LittleEndian, byte(op.along)); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, byte(op.d)); err != nil {
+		panic(err)
+	}
+}
+
+func (op medianOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op medianOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.along)
+	w.writeInt(op.d)
+	return w.bytes()
+}
+
+func (op *medianOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.along = r.readInt()
+	op.d = r.readInt()
+	r.checkAxes(op.d, op.along)
+	return r.done()
+}
+
+func (op medianOp) String() string { return fmt.Sprintf("Median(%d)", op.along) }
+
+// medianDiffOp is the derivative of medianOp. It takes x and the gradient of the median, and routes the gradient to the element in the middle,
+// or half of it to each of the two elements in the middle.
+type medianDiffOp medianOp
+
+// medianDiffOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → b → Tensor d a
+// where b is the type of the output of medianOp
+func (op medianDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(op.d, a)
+	return newFunctionType(t, logSumExpOp(op).retType(a), t)
+}
+
+func (op medianDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "medianDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op medianDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op medianDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op medianDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "medianDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var x, grad []float64
+	var shp types.Shape
+	var dt Dtype
+	if x, shp, dt, err = floatsOperand(inputs[0]); err != nil {
+		return
+	}
+
+	var lo, hi []int
+	if lo, hi, err = medianOp(op).middle(x, shp); err != nil {
+		return
+	}
+	if grad, err = reducedFloats(inputs[1], len(lo)); err != nil {
+		return nil, errors.Wrap(err, "medianDiffOp.Do()")
+	}
+
+	dx := make([]float64, len(x))
+	for j, g := range grad {
+		dx[lo[j]] += g / 2
+		dx[hi[j]] += g / 2
+	}
+	return floatsValue(dx, shp, dt), nil
+}
+
+func (op medianDiffOp) returnsPtr() bool    { return false }
+func (op medianDiffOp) callsExtern() bool   { return false }
+func (op medianDiffOp) overwriteInput() int { return -1 }
+func (op medianDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	medianOp(op).WriteHash(h)
+}
+
+func (op medianDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op medianDiffOp) MarshalBinary() ([]byte, error) {
+	return medianOp(op).MarshalBinary()
+}
+
+func (op *medianDiffOp) UnmarshalBinary(data []byte) error {
+	return (*medianOp)(op).UnmarshalBinary(data)
+}
+
+func (op medianDiffOp) String() string { return fmt.Sprintf("∂Median(%d)", op.along) }
+
+/* EXPONENTIAL MOVING AVERAGE OP */
+
+// emaOp computes the exponential moving average along an axis, a recurrence that starts from the first element:
+//		y[0] = x[0]
+//		y[t] = αx[t] + (1-α)y[t-1]
+// The gradient is propagated backwards through the recurrence, from the last element to the first:
+//		g[t] = ∂y[t] + (1-α)g[t+1]
+//		∂x[t] = αg[t], and ∂x[0] = g[0]
+type emaOp struct {
+	along int // axis
+	d     int
+	alpha float64
+}
+
+// emaOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Tensor d a
+func (op emaOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(op.d, a)
+	return newFunctionType(t, t)
+}
+
+// inferShape is the identity: the average is taken at every step of the sequence
+func (op emaOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "emaOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op emaOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+func (op emaOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "emaOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(emaDiffOp(op), gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op emaOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "emaOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	diff := emaDiffOp(op)
+	var d Value
+	if d, err = diff.Do(ydv.d); err != nil {
+		return errors.Wrapf(err, doFail, diff)
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], output)
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op emaOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "emaOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var x []float64
+	var shp types.Shape
+	var dt Dtype
+	if x, shp, dt, err = floatsOperand(inputs[0]); err != nil {
+		return
+	}
+
+	y := make([]float64, len(x))
+	outer, n, inner := maxWithArgOp{along: op.along, d: op.d}.strides(shp)
+	for o := 0; o < outer; o++ {
+		for i := 0; i < inner; i++ {
+			base := o*n*inner + i
+			for k := 0; k < n; k++ {
+				j := base + k*inner
+				if k == 0 {
+					y[j] = x[j]
+					continue
+				}
+				y[j] = op.alpha*x[j] + (1-op.alpha)*y[j-inner]
+			}
+		}
+	}
+	return floatsValue(y, shp, dt), nil
+}
+
+func (op emaOp) returnsPtr() bool    { return false }
+func (op emaOp) callsExtern() bool   { return false }
+func (op emaOp) overwriteInput() int { return -1 }
+func (op emaOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("ema"))
+	if err := binary.Write(h, binary.LittleEndian, byte(op.along)); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, byte(op.d)); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, op.alpha); err != nil {
+		panic(err)
+	}
+}
+
+func (op emaOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op emaOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.along)
+	w.writeInt(op.d)
+	w.writeFloat(op.alpha)
+	return w.bytes()
+}
+
+func (op *emaOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.along = r.readInt()
+	op.d = r.readInt()
+	op.alpha = r.readFloat()
+	r.checkAxes(op.d, op.along)
+	r.check(op.alpha > 0 && op.alpha <= 1, "Expected alpha to be within (0, 1]. Got %v instead", op.alpha)
+	return r.done()
+}
+
+func (op emaOp) String() string { return fmt.Sprintf("EMA(%d, %v)", op.along, op.alpha) }
+
+// emaDiffOp is the derivative of emaOp. It only takes the gradient of the output, as the recurrence is linear,
+// and runs the recurrence backwards over it.
+type emaDiffOp emaOp
+
+// emaDiffOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Tensor d a
+func (op emaDiffOp) Type() Type { return emaOp(op).Type() }
+
+func (op emaDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "emaDiffOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op emaDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op emaDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op emaDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "emaDiffOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var grad []float64
+	var shp types.Shape
+	var dt Dtype
+	if grad, shp, dt, err = floatsOperand(inputs[0]); err != nil {
+		return
+	}
+
+	dx := make([]float64, len(grad))
+	outer, n, inner := maxWithArgOp{along: op.along, d: op.d}.strides(shp)
+	for o := 0; o < outer; o++ {
+		for i := 0; i < inner; i++ {
+			base := o*n*inner + i
+			var carry float64 // the gradient of y[t+1], carried back to y[t]
+			for k := n - 1; k >= 0; k-- {
+				j := base + k*inner
+				g := grad[j] + (1-op.alpha)*carry
+				carry = g
+				if k == 0 {
+					dx[j] = g
+				} else {
+					dx[j] = op.alpha * g
+				}
+			}
+		}
+	}
+	return floatsValue(dx, shp, dt), nil
+}
+
+func (op emaDiffOp) returnsPtr() bool    { return false }
+func (op emaDiffOp) callsExtern() bool   { return false }
+func (op emaDiffOp) overwriteInput() int { return -1 }
+func (op emaDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	emaOp(op).WriteHash(h)
+}
+
+func (op emaDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op emaDiffOp) MarshalBinary() ([]byte, error) {
+	return emaOp(op).MarshalBinary()
+}
+
+func (op *emaDiffOp) UnmarshalBinary(data []byte) error {
+	return (*emaOp)(op).UnmarshalBinary(data)
+}
+
+func (op emaDiffOp) String() string { return fmt.Sprintf("∂EMA(%d, %v)", op.along, op.alpha) }
+
+/* MASKED SUM OP */
+
+// maskedSumOp sums up the elements of a tensor multiplied by a mask of the same shape, along the given axes.
+// Only the tensor is differentiated. The mask is not.
+type maskedSumOp struct {
+	along      axes
+	d          int
+	inputShape types.Shape
+}
+
+func newMaskedSumOp(along axes, s types.Shape, d int) maskedSumOp {
+	return maskedSumOp{
+		along:      along,
+		d:          d,
+		inputShape: s,
+	}
+}
+
+// maskedSumOp is a function with this type:
+//		maskedSumOp :: (Float a) ⇒ Tensor d a → Tensor d a → Tensor d-n a
+// where n is the number of axes summed along
+func (op maskedSumOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(op.d, a)
+	if op.d == 1 || len(op.along) == 0 || len(op.along) == op.d {
+		return newFunctionType(t, t, a)
+	}
+	return newFunctionType(t, t, newTensorType(op.d-len(op.along), a))
+}
+
+func (op maskedSumOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "maskedSumOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	x, mask := inputs[0], inputs[1]
+	if !x.shape.Eq(mask.shape) {
+		return nil, errors.Errorf("Expected the mask to be shaped %v. Got %v instead", x.shape, mask.shape)
+	}
+	return op.sumOp().inferShape(t, x)
+}
+
+func (op maskedSumOp) DiffWRT(inputs int) []bool { return []bool{true, false} }
+
+// SymDiff repeats the gradient along the summed axes, exactly like sumOp does, and then masks it.
+func (op maskedSumOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "maskedSumOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var grads Nodes
+	if grads, err = op.sumOp().SymDiff(inputs[:1], output, gradNode); err != nil {
+		return nil, errors.Wrap(err, "maskedSumOp.SymDiff()")
+	}
+
+	var dx *Node
+	if dx, err = HadamardProd(grads[0], inputs[1]); err != nil {
+		return nil, errors.Wrap(err, operationError)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx, nil}, nil
+}
+
+func (op maskedSumOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "maskedSumOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	var mask, grad []float64
+	var shp types.Shape
+	var dt Dtype
+	if mask, shp, dt, err = floatsOperand(inputs[1].Value()); err != nil {
+		return errors.Wrap(err, "maskedSumOp.DoDiff()")
+	}
+
+	switch yd := ydv.d.(type) {
+	case Scalar:
+		switch v := yd.v.(type) {
+		case float64:
+			grad = []float64{v}
+		case float32:
+			grad = []float64{float64(v)}
+		default:
+			return errors.Errorf(nyiFail, "maskedSumOp.DoDiff", yd.t)
 		}
-	case Tensor:
-		T = ydvd.Tensor
+	default:
+		if grad, _, _, err = floatsOperand(yd); err != nil {
+			return errors.Wrap(err, "maskedSumOp.DoDiff()")
+		}
+	}
+
+	strides, size := op.sumOp().broadcastStrides(shp)
+	if len(grad) != size {
+		return errors.Errorf("Expected a gradient of %d elements. Got %v instead", size, ydv.d)
+	}
+
+	d := make([]float64, len(mask))
+	broadcastAddf64(d, grad, shp, strides)
+	for i, m := range mask {
+		d[i] *= m
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(xdv.d, floatsValue(d, shp, dt)); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op maskedSumOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "maskedSumOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var x, mask []float64
+	var shp, maskShape types.Shape
+	var dt Dtype
+	if x, shp, dt, err = floatsOperand(inputs[0]); err != nil {
+		return nil, errors.Wrap(err, "maskedSumOp.Do()")
+	}
+	if mask, maskShape, _, err = floatsOperand(inputs[1]); err != nil {
+		return nil, errors.Wrap(err, "maskedSumOp.Do()")
+	}
+	if !shp.Eq(maskShape) {
+		return nil, errors.Errorf("Expected the mask to be shaped %v. Got %v instead", shp, maskShape)
+	}
+
+	masked := make([]float64, len(x))
+	for i, v := range x {
+		masked[i] = v * mask[i]
+	}
+	return op.sumOp().Do(floatsValue(masked, shp, dt))
+}
+
+// sumOp is the sum that is taken after masking
+func (op maskedSumOp) sumOp() sumOp { return newSumOp(op.along, op.inputShape, op.d) }
+
+func (op maskedSumOp) returnsPtr() bool    { return false }
+func (op maskedSumOp) overwriteInput() int { return -1 }
+func (op maskedSumOp) callsExtern() bool   { return false }
+
+func (op maskedSumOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("maskedSum"))
+	fmt.Fprintf(h, "%v->%v", op.along, op.inputShape)
+}
+
+func (op maskedSumOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op maskedSumOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInts(op.along)
+	w.writeInt(op.d)
+	w.writeInts(op.inputShape)
+	return w.bytes()
+}
+
+func (op *maskedSumOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.along = axes(r.readInts())
+	op.d = r.readInt()
+	op.inputShape = r.readShape()
+	r.checkAxes(op.d, op.along...)
+	return r.done()
+}
+
+func (op maskedSumOp) String() string { return fmt.Sprintf("MaskedΣ%v", op.along) }
+
+/* COSINE SIMILARITY OP */
+
+// cosineSimilarityOp computes the cosine similarity of a and b along an axis:
+//		y = a·b / (‖a‖ ‖b‖)
+// in one pass over a and b, rather than as a dot product, two norms and a division. The gradients are fused too:
+//		∂a = (b/(‖a‖ ‖b‖) - y × a/‖a‖²) × ∂y
+// and likewise for b. Where either a or b is all zeros the cosine is undefined, so the similarity is taken to be 0 and neither gets a gradient,
+// instead of dividing by zero.
+type cosineSimilarityOp struct {
+	along int // axis
+	d     int
+}
+
+// cosineSimilarityOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Tensor d a → Tensor d-1 a
+// which is a scalar for vectors
+func (op cosineSimilarityOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(op.d, a)
+	return newFunctionType(t, t, logSumExpOp(op).retType(a))
+}
+
+func (op cosineSimilarityOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "cosineSimilarityOp expects 2 inputs. Got %d instead", len(inputs))
+		return
 	}
+	if !inputs[0].shape.Eq(inputs[1].shape) {
+		return nil, errors.Errorf("Shape mismatch: %v and %v", inputs[0].shape, inputs[1].shape)
+	}
+	return maxWithArgOp(op).reducedShape(inputs[0].shape)
+}
 
-	var val Value
-	if !T.Shape().Eq(xdv.d.Shape()) {
-		// TO DO: Optimize: figure out a way to bunch it all up so you can repeat in one call
-		for _, a := range op.along {
-			if xShape[a] == 1 {
-				continue // don't need to repeat
-			}
-			if T, err = tensor.Repeat(T, a, xShape[a]); err != nil {
-				return errors.Wrapf(err, repFail, a, xShape[a])
-			}
+func (op cosineSimilarityOp) DiffWRT(inputs int) []bool { return []bool{true, true} }
+
+func (op cosineSimilarityOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "cosineSimilarityOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	retVal = make(Nodes, 2)
+	for i := range retVal {
+		diff := cosineSimilarityDiffOp{fwd: op, wrt: i}
+		if retVal[i], err = applyOp(diff, inputs[0], inputs[1], gradNode); err != nil {
+			return nil, errors.Wrap(err, applyOpFail)
 		}
+		retVal[i].setGroup(gradClust)
+	}
+	return
+}
 
-		val = FromTensor(T)
-	} else {
-		val = ydv.d
+func (op cosineSimilarityOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "cosineSimilarityOp expects 2 inputs. Got %d instead", len(inputs))
+		return
 	}
 
-	// then just add the two
-	add := newEBOByType(addOpType, xdv.d.Type(), val.Type())
+	adv := inputs[0].boundTo.(*dualValue)
+	bdv := inputs[1].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
 
-	var d Value
-	if d, err = add.UnsafeDo(xdv.d, val); err != nil {
+	var da, db Value
+	if da, db, err = op.backward(adv.Value, bdv.Value, ydv.d); err != nil {
+		return errors.Wrap(err, "cosineSimilarityOp.DoDiff()")
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(adv.d, da); err != nil {
 		return errors.Wrapf(err, unsafeDoFail, add)
 	}
 
-	// check if xdv.d is scalar
-	if xdv.d.Type().isScalar() {
-		return xdv.SetDeriv(d)
+	add = newElemBinOp(addOpType, inputs[1], inputs[1])
+	if _, err = add.UnsafeDo(bdv.d, db); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
 	}
 	return
+}
+
+func (op cosineSimilarityOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "cosineSimilarityOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var a, b []float64
+	var shp, reduced types.Shape
+	var dt Dtype
+	if a, b, shp, dt, err = op.operands(inputs[0], inputs[1]); err != nil {
+		return
+	}
+	if reduced, err = maxWithArgOp(op).reducedShape(shp); err != nil {
+		return
+	}
+
+	dots, na, nb := op.moments(a, b, shp)
+	y := make([]float64, len(dots))
+	for j, dot := range dots {
+		if na[j] != 0 && nb[j] != 0 {
+			y[j] = dot / (na[j] * nb[j])
+		}
+	}
 
+	if reduced.IsScalar() {
+		if dt == Float32 {
+			return NewScalarValue(float32(y[0])), nil
+		}
+		return NewScalarValue(y[0]), nil
+	}
+	return floatsValue(y, reduced, dt), nil
 }
 
-func (op sumOp) Do(inputs ...Value) (retVal Value, err error) {
-	if len(inputs) != 1 {
-		err = NewError(GraphError, "Expect only one input for sumOp. GOt %v instead", len(inputs))
+// operands checks that a and b are tensors of the same shape, and returns them as float64s
+func (op cosineSimilarityOp) operands(av, bv Value) (a, b []float64, shp types.Shape, dt Dtype, err error) {
+	var bShape types.Shape
+	if a, shp, dt, err = floatsOperand(av); err != nil {
+		return
+	}
+	if b, bShape, _, err = floatsOperand(bv); err != nil {
 		return
 	}
+	if !shp.Eq(bShape) {
+		err = errors.Errorf("Shape mismatch: %v and %v", shp, bShape)
+	}
+	return
+}
 
-	a := inputs[0]
-	at := a.(Tensor)
-	switch t := at.Tensor.(type) {
-	case *tf64.Tensor:
-		var ret *tf64.Tensor
-		if ret, err = t.Sum(op.along...); err == nil {
-			if ret.IsScalar() {
-				retVal = NewScalarValue(ret.ScalarValue())
-			} else {
-				retVal = FromTensor(ret)
+// moments computes the dot products of a and b along the axis, and the norms of a and of b, one for each element of the output
+func (op cosineSimilarityOp) moments(a, b []float64, shp types.Shape) (dots, na, nb []float64) {
+	outer, n, inner := maxWithArgOp(op).strides(shp)
+	dots = make([]float64, outer*inner)
+	na = make([]float64, outer*inner)
+	nb = make([]float64, outer*inner)
+	for o := 0; o < outer; o++ {
+		for i := 0; i < inner; i++ {
+			j := o*inner + i
+			for k := 0; k < n; k++ {
+				at := o*n*inner + k*inner + i
+				dots[j] += a[at] * b[at]
+				na[j] += a[at] * a[at]
+				nb[j] += b[at] * b[at]
 			}
-		} else {
-			return nil, errors.Wrap(err, "failed to apply *tf64.Tensor.Sum()")
+			na[j] = math.Sqrt(na[j])
+			nb[j] = math.Sqrt(nb[j])
 		}
-	case *tf32.Tensor:
-		var ret *tf32.Tensor
-		if ret, err = t.Sum(op.along...); err == nil {
-			if ret.IsScalar() {
-				retVal = NewScalarValue(ret.ScalarValue())
-			} else {
-				retVal = FromTensor(ret)
+	}
+	return
+}
+
+// backward computes the gradients of a and b, given the gradient of the output
+func (op cosineSimilarityOp) backward(av, bv, gradV Value) (da, db Value, err error) {
+	var a, b, grad []float64
+	var shp types.Shape
+	var dt Dtype
+	if a, b, shp, dt, err = op.operands(av, bv); err != nil {
+		return
+	}
+
+	outer, n, inner := maxWithArgOp(op).strides(shp)
+	if grad, err = reducedFloats(gradV, outer*inner); err != nil {
+		return nil, nil, errors.Wrap(err, "cosineSimilarityOp.backward()")
+	}
+
+	dots, na, nb := op.moments(a, b, shp)
+	dA := make([]float64, len(a))
+	dB := make([]float64, len(b))
+	for o := 0; o < outer; o++ {
+		for i := 0; i < inner; i++ {
+			j := o*inner + i
+			if na[j] == 0 || nb[j] == 0 {
+				continue
+			}
+			prod := na[j] * nb[j]
+			y := dots[j] / prod
+			for k := 0; k < n; k++ {
+				at := o*n*inner + k*inner + i
+				dA[at] = (b[at]/prod - y*a[at]/(na[j]*na[j])) * grad[j]
+				dB[at] = (a[at]/prod - y*b[at]/(nb[j]*nb[j])) * grad[j]
 			}
-		} else {
-			return nil, errors.Wrap(err, "failed to apply *tf32.Tensor.Sum()")
 		}
-	default:
-		return nil, errors.Errorf(nyiFail, "sumOp.Do()", at.Tensor)
+	}
+	return floatsValue(dA, shp, dt), floatsValue(dB, shp, dt), nil
+}
+
+func (op cosineSimilarityOp) returnsPtr() bool    { return false }
+func (op cosineSimilarityOp) callsExtern() bool   { return false }
+func (op cosineSimilarityOp) overwriteInput() int { return -1 }
+func (op cosineSimilarityOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("cosineSimilarity"))
+	fmt.Fprintf(h, "%v->%v", op.d, op.along)
+}
+
+func (op cosineSimilarityOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op cosineSimilarityOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.along)
+	w.writeInt(op.d)
+	return w.bytes()
+}
+
+func (op *cosineSimilarityOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.along = r.readInt()
+	op.d = r.readInt()
+	r.checkAxes(op.d, op.along)
+	return r.done()
+}
+
+func (op cosineSimilarityOp) String() string { return fmt.Sprintf("CosineSimilarity(%d)", op.along) }
+
+// cosineSimilarityDiffOp is the derivative of cosineSimilarityOp with regards to either a (wrt = 0) or b (wrt = 1).
+// The inputs are a, b and the gradient of the output.
+type cosineSimilarityDiffOp struct {
+	fwd cosineSimilarityOp
+	wrt int
+}
+
+// cosineSimilarityDiffOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Tensor d a → b → Tensor d a
+// where b is the type of the output of cosineSimilarityOp
+func (op cosineSimilarityDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	t := newTensorType(op.fwd.d, a)
+	return newFunctionType(t, t, logSumExpOp(op.fwd).retType(a), t)
+}
+
+func (op cosineSimilarityDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "cosineSimilarityDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[op.wrt].shape.Clone(), nil
+}
+
+func (op cosineSimilarityDiffOp) DiffWRT(inputs int) []bool { return make([]bool, inputs) }
+func (op cosineSimilarityDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) {
+	return nil, nondiffErr(op)
+}
+
+func (op cosineSimilarityDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "cosineSimilarityDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var da, db Value
+	if da, db, err = op.fwd.backward(inputs[0], inputs[1], inputs[2]); err != nil {
+		return
+	}
+	if op.wrt == 0 {
+		return da, nil
+	}
+	return db, nil
+}
+
+func (op cosineSimilarityDiffOp) returnsPtr() bool    { return false }
+func (op cosineSimilarityDiffOp) callsExtern() bool   { return false }
+func (op cosineSimilarityDiffOp) overwriteInput() int { return -1 }
+func (op cosineSimilarityDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	op.fwd.WriteHash(h)
+	if err := binary.Write(h, binary.LittleEndian, byte(op.wrt)); err != nil {
+		panic(err)
+	}
+}
+
+func (op cosineSimilarityDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op cosineSimilarityDiffOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.fwd.along)
+	w.writeInt(op.fwd.d)
+	w.writeInt(op.wrt)
+	return w.bytes()
+}
+
+func (op *cosineSimilarityDiffOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.fwd.along = r.readInt()
+	op.fwd.d = r.readInt()
+	op.wrt = r.readInt()
+	r.checkAxes(op.fwd.d, op.fwd.along)
+	r.checkWRT(op.wrt, 2)
+	return r.done()
+}
+
+func (op cosineSimilarityDiffOp) String() string { return fmt.Sprintf("∂%v/∂%d", op.fwd, op.wrt) }
+
+// weightedSumOp computes the sum of values × weights along an axis, where the weights are broadcast to the shape of the values:
+//		y = Σ values × weights
+// in one pass, without the product ever being a tensor of its own. The gradients are the output gradient broadcast back along the axis,
+//		∂values = weights × ∂y
+//		∂weights = values × ∂y
+// with ∂weights summed over the axes the weights were broadcast along, so that it has the shape of the weights.
+type weightedSumOp struct {
+	along int // axis
+	d     int // dims of the values
+	wd    int // dims of the weights
+}
+
+// weightedSumOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Tensor wd a → Tensor d-1 a
+// which is a scalar for vectors
+func (op weightedSumOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(newTensorType(op.d, a), newTensorType(op.wd, a), logSumExpOp{along: op.along, d: op.d}.retType(a))
+}
+
+func (op weightedSumOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "weightedSumOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	if _, err = broadcastAxes(inputs[1].shape, inputs[0].shape); err != nil {
+		return
+	}
+	return maxWithArgOp{along: op.along, d: op.d}.reducedShape(inputs[0].shape)
+}
+
+func (op weightedSumOp) DiffWRT(inputs int) []bool { return []bool{true, true} }
+
+func (op weightedSumOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "weightedSumOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	retVal = make(Nodes, 2)
+	for i := range retVal {
+		diff := weightedSumDiffOp{fwd: op, wrt: i}
+		if retVal[i], err = applyOp(diff, inputs[0], inputs[1], gradNode); err != nil {
+			return nil, errors.Wrap(err, applyOpFail)
+		}
+		retVal[i].setGroup(gradClust)
 	}
 	return
 }
 
-func (op sumOp) returnsPtr() bool    { return true }
-func (op sumOp) overwriteInput() int { return 0 }
-func (op sumOp) callsExtern() bool   { return false }
+func (op weightedSumOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "weightedSumOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
 
-func (op sumOp) WriteHash(h hash.Hash) {
-	h.Write([]byte("sum"))
-	fmt.Fprintf(h, "%v->%v", op.along, op.inputShape)
+	vdv := inputs[0].boundTo.(*dualValue)
+	wdv := inputs[1].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	var dv, dw Value
+	if dv, dw, err = op.backward(vdv.Value, wdv.Value, ydv.d); err != nil {
+		return errors.Wrap(err, "weightedSumOp.DoDiff()")
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(vdv.d, dv); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+
+	add = newElemBinOp(addOpType, inputs[1], inputs[1])
+	if _, err = add.UnsafeDo(wdv.d, dw); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
 }
 
-func (op sumOp) Hashcode() uint32 {
+func (op weightedSumOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "weightedSumOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var v, w []float64
+	var shp, wShape, reduced types.Shape
+	var dt Dtype
+	if v, w, shp, wShape, dt, err = op.operands(inputs[0], inputs[1]); err != nil {
+		return
+	}
+	if reduced, err = (maxWithArgOp{along: op.along, d: op.d}).reducedShape(shp); err != nil {
+		return
+	}
+
+	var wIdx, yIdx []int
+	var size int
+	if wIdx, yIdx, size, err = op.indices(shp, wShape); err != nil {
+		return
+	}
+
+	y := make([]float64, size)
+	for i, x := range v {
+		y[yIdx[i]] += x * w[wIdx[i]]
+	}
+
+	if reduced.IsScalar() {
+		if dt == Float32 {
+			return NewScalarValue(float32(y[0])), nil
+		}
+		return NewScalarValue(y[0]), nil
+	}
+	return floatsValue(y, reduced, dt), nil
+}
+
+// operands returns the values and the weights as float64s, along with their shapes
+func (op weightedSumOp) operands(vv, wv Value) (v, w []float64, shp, wShape types.Shape, dt Dtype, err error) {
+	if v, shp, dt, err = floatsOperand(vv); err != nil {
+		return
+	}
+	w, wShape, _, err = floatsOperand(wv)
+	return
+}
+
+// indices maps every element of the values to the weight it is multiplied by, and to the element of the output it is summed into.
+// size is the number of elements of the output.
+func (op weightedSumOp) indices(shp, wShape types.Shape) (wIdx, yIdx []int, size int, err error) {
+	var bcast []int
+	if bcast, err = broadcastAxes(wShape, shp); err != nil {
+		return
+	}
+
+	// padding the weights with leading axes of size 1 leaves their elements where they are, so the broadcast axes can be treated as reduced ones
+	wStrides, _ := sumOp{along: bcast}.broadcastStrides(shp)
+	yStrides, size := sumOp{along: axes{op.along}}.broadcastStrides(shp)
+
+	total := shp.TotalSize()
+	wIdx = make([]int, total)
+	yIdx = make([]int, total)
+	forEachReduced(shp, wStrides, func(i, j int) { wIdx[i] = j })
+	forEachReduced(shp, yStrides, func(i, j int) { yIdx[i] = j })
+	return
+}
+
+// backward computes the gradients of the values and of the weights, given the gradient of the output
+func (op weightedSumOp) backward(vv, wv, gradV Value) (dv, dw Value, err error) {
+	var v, w, grad []float64
+	var shp, wShape types.Shape
+	var dt Dtype
+	if v, w, shp, wShape, dt, err = op.operands(vv, wv); err != nil {
+		return
+	}
+
+	var wIdx, yIdx []int
+	var size int
+	if wIdx, yIdx, size, err = op.indices(shp, wShape); err != nil {
+		return
+	}
+	if grad, err = reducedFloats(gradV, size); err != nil {
+		return nil, nil, errors.Wrap(err, "weightedSumOp.backward()")
+	}
+
+	dV := make([]float64, len(v))
+	dW := make([]float64, len(w))
+	for i, x := range v {
+		g := grad[yIdx[i]]
+		dV[i] = w[wIdx[i]] * g
+		dW[wIdx[i]] += x * g
+	}
+	return floatsValue(dV, shp, dt), floatsValue(dW, wShape, dt), nil
+}
+
+func (op weightedSumOp) returnsPtr() bool    { return false }
+func (op weightedSumOp) callsExtern() bool   { return false }
+func (op weightedSumOp) overwriteInput() int { return -1 }
+func (op weightedSumOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("weightedSum"))
+	fmt.Fprintf(h, "%v,%v->%v", op.d, op.wd, op.along)
+}
+
+func (op weightedSumOp) Hashcode() uint32 {
 	h := fnv.New32a()
 	op.WriteHash(h)
 	return h.Sum32()
 }
 
-func (op sumOp) String() string { return fmt.Sprintf("Σ%v", op.along) }
-func (op sumOp) isUnary() bool  { return true }
+func (op weightedSumOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.along)
+	w.writeInt(op.d)
+	w.writeInt(op.wd)
+	return w.bytes()
+}
+
+func (op *weightedSumOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.along = r.readInt()
+	op.d = r.readInt()
+	op.wd = r.readInt()
+	r.checkAxes(op.d, op.along)
+	r.checkAxes(op.wd)
+	return r.done()
+}
+
+func (op weightedSumOp) String() string { return fmt.Sprintf("WeightedSum(%d)", op.along) }
+
+// weightedSumDiffOp is the derivative of weightedSumOp with regards to either the values (wrt = 0) or the weights (wrt = 1).
+// The inputs are the values, the weights and the gradient of the output.
+type weightedSumDiffOp struct {
+	fwd weightedSumOp
+	wrt int
+}
+
+// weightedSumDiffOp has this type:
+//		op :: (Float a) ⇒ Tensor d a → Tensor wd a → b → c
+// where b is the type of the output of weightedSumOp, and c is the type of the input the gradient is of
+func (op weightedSumDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	v := newTensorType(op.fwd.d, a)
+	w := newTensorType(op.fwd.wd, a)
+	ret := v
+	if op.wrt == 1 {
+		ret = w
+	}
+	return newFunctionType(v, w, logSumExpOp{along: op.fwd.along, d: op.fwd.d}.retType(a), ret)
+}
+
+func (op weightedSumDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "weightedSumDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[op.wrt].shape.Clone(), nil
+}
+
+func (op weightedSumDiffOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op weightedSumDiffOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+
+func (op weightedSumDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "weightedSumDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var dv, dw Value
+	if dv, dw, err = op.fwd.backward(inputs[0], inputs[1], inputs[2]); err != nil {
+		return
+	}
+	if op.wrt == 0 {
+		return dv, nil
+	}
+	return dw, nil
+}
+
+func (op weightedSumDiffOp) returnsPtr() bool    { return false }
+func (op weightedSumDiffOp) callsExtern() bool   { return false }
+func (op weightedSumDiffOp) overwriteInput() int { return -1 }
+func (op weightedSumDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	op.fwd.WriteHash(h)
+	if err := binary.Write(h, binary.LittleEndian, byte(op.wrt)); err != nil {
+		panic(err)
+	}
+}
+
+func (op weightedSumDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op weightedSumDiffOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.fwd.along)
+	w.writeInt(op.fwd.d)
+	w.writeInt(op.fwd.wd)
+	w.writeInt(op.wrt)
+	return w.bytes()
+}
+
+func (op *weightedSumDiffOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.fwd.along = r.readInt()
+	op.fwd.d = r.readInt()
+	op.fwd.wd = r.readInt()
+	op.wrt = r.readInt()
+	r.checkAxes(op.fwd.d, op.fwd.along)
+	r.checkAxes(op.fwd.wd)
+	r.checkWRT(op.wrt, 2)
+	return r.done()
+}
+
+func (op weightedSumDiffOp) String() string { return fmt.Sprintf("∂%v/∂%d", op.fwd, op.wrt) }
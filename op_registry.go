@@ -0,0 +1,616 @@
+package gorgonia
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"sync"
+
+	tb "github.com/chewxy/gorgonia/tensor/b"
+	tf32 "github.com/chewxy/gorgonia/tensor/f32"
+	tf64 "github.com/chewxy/gorgonia/tensor/f64"
+	ti "github.com/chewxy/gorgonia/tensor/i"
+	"github.com/chewxy/gorgonia/tensor/types"
+	"github.com/pkg/errors"
+)
+
+// opRegistry holds the factories of the registered ops, keyed by name. It also knows the name of each type of op, so that an op can be written out by name.
+var opRegistry = struct {
+	sync.RWMutex
+	factories map[string]func() Op
+	names     map[reflect.Type]string
+}{
+	factories: make(map[string]func() Op),
+	names:     make(map[reflect.Type]string),
+}
+
+func init() {
+	RegisterOp("letOp", func() Op { return letOp{} })
+	RegisterOp("readOp", func() Op { return readOp{} })
+	RegisterOp("constantScalar", func() Op { return constantScalar{} })
+	RegisterOp("constantTensor", func() Op { return constantTensor{} })
+
+	RegisterOp("elemBinOp", func() Op { return elemBinOp{} })
+	RegisterOp("elemUnaryOp", func() Op { return elemUnaryOp{} })
+	RegisterOp("linAlgBinOp", func() Op { return linAlgBinOp{} })
+	RegisterOp("addScalarOp", func() Op { return addScalarOp{} })
+	RegisterOp("powConstOp", func() Op { return powConstOp{} })
+	RegisterOp("powConstDiffOp", func() Op { return powConstDiffOp{} })
+	RegisterOp("thresholdOp", func() Op { return thresholdOp{} })
+	RegisterOp("maximumScalarOp", func() Op { return maximumScalarOp{} })
+	RegisterOp("maximumScalarDiffOp", func() Op { return maximumScalarDiffOp{} })
+	RegisterOp("logitOp", func() Op { return logitOp{} })
+	RegisterOp("logitDiffOp", func() Op { return logitDiffOp{} })
+	RegisterOp("hardSigmoidOp", func() Op { return hardSigmoidOp{} })
+	RegisterOp("hardSigmoidDiffOp", func() Op { return hardSigmoidDiffOp{} })
+	RegisterOp("signedSqrtOp", func() Op { return signedSqrtOp{} })
+	RegisterOp("signedSqrtDiffOp", func() Op { return signedSqrtDiffOp{} })
+	RegisterOp("swishOp", func() Op { return swishOp{} })
+	RegisterOp("swishDiffOp", func() Op { return swishDiffOp{} })
+	RegisterOp("fakeQuantOp", func() Op { return fakeQuantOp{} })
+	RegisterOp("fakeQuantDiffOp", func() Op { return fakeQuantDiffOp{} })
+	RegisterOp("applyFnOp", func() Op { return applyFnOp{} })
+	RegisterOp("applyFnDiffOp", func() Op { return applyFnDiffOp{} })
+	RegisterOp("choleskyOp", func() Op { return choleskyOp{} })
+	RegisterOp("choleskyDiffOp", func() Op { return choleskyDiffOp{} })
+	RegisterOp("matPowOp", func() Op { return matPowOp{} })
+	RegisterOp("matPowDiffOp", func() Op { return matPowDiffOp{} })
+	RegisterOp("luOp", func() Op { return luOp{} })
+	RegisterOp("detOp", func() Op { return detOp{} })
+	RegisterOp("detDiffOp", func() Op { return detDiffOp{} })
+	RegisterOp("logDetOp", func() Op { return logDetOp{} })
+	RegisterOp("logDetDiffOp", func() Op { return logDetDiffOp{} })
+	RegisterOp("matInverseOp", func() Op { return matInverseOp{} })
+	RegisterOp("matInverseDiffOp", func() Op { return matInverseDiffOp{} })
+
+	RegisterOp("maxOp", func() Op { return &maxOp{} }) // newMaxOp makes pointers
+	RegisterOp("maxDiffOp", func() Op { return maxDiffOp{} })
+	RegisterOp("argminOp", func() Op { return argminOp{} })
+	RegisterOp("maxWithArgOp", func() Op { return maxWithArgOp{} })
+	RegisterOp("maxWithArgDiffOp", func() Op { return maxWithArgDiffOp{} })
+	RegisterOp("maxArgIndicesOp", func() Op { return maxArgIndicesOp{} })
+	RegisterOp("topKOp", func() Op { return topKOp{} })
+	RegisterOp("topKDiffOp", func() Op { return topKDiffOp{} })
+	RegisterOp("sumOp", func() Op { return sumOp{} })
+	RegisterOp("maskedSumOp", func() Op { return maskedSumOp{} })
+	RegisterOp("meanSquareOp", func() Op { return meanSquareOp{} })
+	RegisterOp("meanSquareDiffOp", func() Op { return meanSquareDiffOp{} })
+	RegisterOp("nanMeanOp", func() Op { return nanMeanOp{} })
+	RegisterOp("nanMeanDiffOp", func() Op { return nanMeanDiffOp{} })
+	RegisterOp("prodOp", func() Op { return prodOp{} })
+	RegisterOp("prodDiffOp", func() Op { return prodDiffOp{} })
+	RegisterOp("countNonzeroOp", func() Op { return countNonzeroOp{} })
+	RegisterOp("findFirstOp", func() Op { return findFirstOp{} })
+	RegisterOp("reduceLogicalOp", func() Op { return reduceLogicalOp{} })
+	RegisterOp("histogramOp", func() Op { return histogramOp{} })
+	RegisterOp("logSumExpOp", func() Op { return logSumExpOp{} })
+	RegisterOp("logSumExpDiffOp", func() Op { return logSumExpDiffOp{} })
+	RegisterOp("foldOp", func() Op { return foldOp{} })
+	RegisterOp("foldDiffOp", func() Op { return foldDiffOp{} })
+	RegisterOp("cumMaxOp", func() Op { return cumMaxOp{} })
+	RegisterOp("cumMaxDiffOp", func() Op { return cumMaxDiffOp{} })
+	RegisterOp("medianOp", func() Op { return medianOp{} })
+	RegisterOp("medianDiffOp", func() Op { return medianDiffOp{} })
+	RegisterOp("emaOp", func() Op { return emaOp{} })
+	RegisterOp("emaDiffOp", func() Op { return emaDiffOp{} })
+	RegisterOp("cosineSimilarityOp", func() Op { return cosineSimilarityOp{} })
+	RegisterOp("cosineSimilarityDiffOp", func() Op { return cosineSimilarityDiffOp{} })
+	RegisterOp("weightedSumOp", func() Op { return weightedSumOp{} })
+	RegisterOp("weightedSumDiffOp", func() Op { return weightedSumDiffOp{} })
+
+	RegisterOp("atOp", func() Op { return atOp{} })
+	RegisterOp("sizeOp", func() Op { return sizeOp{} })
+	RegisterOp("shapeOfOp", func() Op { return shapeOfOp{} })
+	RegisterOp("repeatOp", func() Op { return &repeatOp{} }) // newRepeatOp makes pointers
+	RegisterOp("sliceOp", func() Op { return sliceOp{} })
+	RegisterOp("sliceIncrOp", func() Op { return sliceIncrOp{} })
+	RegisterOp("transposeOp", func() Op { return transposeOp{} })
+	RegisterOp("reshapeOp", func() Op { return reshapeOp{} })
+	RegisterOp("broadcastToOp", func() Op { return broadcastToOp{} })
+	RegisterOp("broadcastToDiffOp", func() Op { return broadcastToDiffOp{} })
+	RegisterOp("gatherOp", func() Op { return gatherOp{} })
+	RegisterOp("gatherDiffOp", func() Op { return gatherDiffOp{} })
+	RegisterOp("scatterOp", func() Op { return scatterOp{} })
+	RegisterOp("scatterDiffOp", func() Op { return scatterDiffOp{} })
+	RegisterOp("blockDiagOp", func() Op { return blockDiagOp{} })
+	RegisterOp("blockDiagDiffOp", func() Op { return blockDiagDiffOp{} })
+	RegisterOp("symmetrizeOp", func() Op { return symmetrizeOp{} })
+	RegisterOp("reverseOp", func() Op { return reverseOp{} })
+	RegisterOp("rollOp", func() Op { return rollOp{} })
+	RegisterOp("layoutOp", func() Op { return layoutOp{} })
+
+	RegisterOp("randomOp", func() Op { return randomOp{} })
+	RegisterOp("noiseOp", func() Op { return noiseOp{} })
+	RegisterOp("lstmCellOp", func() Op { return lstmCellOp{} })
+	RegisterOp("lstmCellDiffOp", func() Op { return lstmCellDiffOp{} })
+	RegisterOp("positionalEncodingOp", func() Op { return positionalEncodingOp{} })
+	RegisterOp("ropeOp", func() Op { return ropeOp{} })
+	RegisterOp("moeGateOp", func() Op { return moeGateOp{} })
+	RegisterOp("moeGateDiffOp", func() Op { return moeGateDiffOp{} })
+	RegisterOp("topKIndicesOp", func() Op { return topKIndicesOp{} })
+	RegisterOp("loadBalanceLossOp", func() Op { return loadBalanceLossOp{} })
+	RegisterOp("loadBalanceLossDiffOp", func() Op { return loadBalanceLossDiffOp{} })
+	RegisterOp("arcFaceOp", func() Op { return arcFaceOp{} })
+	RegisterOp("arcFaceDiffOp", func() Op { return arcFaceDiffOp{} })
+	RegisterOp("centerLossOp", func() Op { return centerLossOp{} })
+	RegisterOp("centerLossDiffOp", func() Op { return centerLossDiffOp{} })
+	RegisterOp("runningStatsOp", func() Op { return runningStatsOp{} })
+	RegisterOp("runningStatsDiffOp", func() Op { return runningStatsDiffOp{} })
+	RegisterOp("ntxentOp", func() Op { return ntxentOp{} })
+	RegisterOp("ntxentDiffOp", func() Op { return ntxentDiffOp{} })
+	RegisterOp("biasAddOp", func() Op { return biasAddOp{} })
+	RegisterOp("outerSumOp", func() Op { return outerSumOp{} })
+	RegisterOp("affineOp", func() Op { return affineOp{} })
+	RegisterOp("affineDiffOp", func() Op { return affineDiffOp{} })
+	RegisterOp("clipGradOp", func() Op { return clipGradOp{} })
+	RegisterOp("clipGradDiffOp", func() Op { return clipGradDiffOp{} })
+	RegisterOp("logSoftmaxOp", func() Op { return logSoftmaxOp{} })
+	RegisterOp("logSoftmaxDiffOp", func() Op { return logSoftmaxDiffOp{} })
+	RegisterOp("softmaxGradOp", func() Op { return softmaxGradOp{} })
+	RegisterOp("softmaxGradDiffOp", func() Op { return softmaxGradDiffOp{} })
+	RegisterOp("geluGradOp", func() Op { return geluGradOp{} })
+	RegisterOp("geluGradDiffOp", func() Op { return geluGradDiffOp{} })
+	RegisterOp("l2NormalizeOp", func() Op { return l2NormalizeOp{} })
+	RegisterOp("l2NormalizeDiffOp", func() Op { return l2NormalizeDiffOp{} })
+	RegisterOp("huberLossOp", func() Op { return huberLossOp{} })
+	RegisterOp("huberLossDiffOp", func() Op { return huberLossDiffOp{} })
+
+	RegisterOp("checkpointOp", func() Op { return checkpointOp{} })
+	RegisterOp("checkpointDiffOp", func() Op { return checkpointDiffOp{} })
+}
+
+// RegisterOp registers a factory for an Op under the given name. The factory is used to reconstruct the op when a graph is read back in (see DecodeOp),
+// and the name is used when printing an op whose String() is empty.
+//
+// Each type of op may only be registered once, and each name may only be used once. RegisterOp panics otherwise, much like gob.Register does.
+func RegisterOp(name string, factory func() Op) {
+	if name == "" {
+		panic("Cannot register an op without a name")
+	}
+	if factory == nil {
+		panic(fmt.Sprintf("Cannot register op %q with a nil factory", name))
+	}
+
+	op := factory()
+	if op == nil {
+		panic(fmt.Sprintf("The factory of op %q returned nil", name))
+	}
+	t := reflect.TypeOf(op)
+
+	opRegistry.Lock()
+	defer opRegistry.Unlock()
+
+	if _, ok := opRegistry.factories[name]; ok {
+		panic(fmt.Sprintf("Op %q is already registered", name))
+	}
+	if existing, ok := opRegistry.names[t]; ok {
+		panic(fmt.Sprintf("Op type %v is already registered as %q", t, existing))
+	}
+
+	opRegistry.factories[name] = factory
+	opRegistry.names[t] = name
+}
+
+// registeredName returns the name the type of op was registered under
+func registeredName(op Op) (name string, ok bool) {
+	opRegistry.RLock()
+	name, ok = opRegistry.names[reflect.TypeOf(op)]
+	opRegistry.RUnlock()
+	return
+}
+
+// opFactory returns the factory registered under the name
+func opFactory(name string) (factory func() Op, ok bool) {
+	opRegistry.RLock()
+	factory, ok = opRegistry.factories[name]
+	opRegistry.RUnlock()
+	return
+}
+
+// opString returns the string representation of an op. Ops whose String() is empty fall back to their registered name, and then to their Go type.
+func opString(op Op) string {
+	if op == nil {
+		return "<nil>"
+	}
+	if s := op.String(); s != "" {
+		return s
+	}
+	if name, ok := registeredName(op); ok {
+		return name
+	}
+	return fmt.Sprintf("%T", op)
+}
+
+// OpRecord is the serializable form of an Op: the name it was registered under, and its parameters, if any.
+// It can be written out with encoding/gob or encoding/json.
+type OpRecord struct {
+	Name string
+	Data []byte `json:",omitempty"`
+}
+
+// EncodeOp creates the OpRecord of an op. The op must have been registered with RegisterOp.
+//
+// If the op implements encoding.BinaryMarshaler, its parameters are written into the record. Otherwise the op must be
+// exactly what its factory creates, as there would be no way to reconstruct its parameters.
+//
+// The built-in ops write their parameters with a leading version byte, and check them when they are read back in, so that a record
+// written by another version of the package, or one that describes an op that could not have been made, is an error rather than a broken op.
+func EncodeOp(op Op) (retVal OpRecord, err error) {
+	name, ok := registeredName(op)
+	if !ok {
+		return retVal, errors.Errorf("Op %v of type %T is not registered", op, op)
+	}
+	retVal.Name = name
+
+	if m, ok := op.(encoding.BinaryMarshaler); ok {
+		if retVal.Data, err = m.MarshalBinary(); err != nil {
+			return retVal, errors.Wrapf(err, "Unable to marshal %v", op)
+		}
+		return
+	}
+
+	factory, _ := opFactory(name)
+	if !reflect.DeepEqual(op, factory()) {
+		return retVal, errors.Errorf("Op %v has parameters but %T does not implement encoding.BinaryMarshaler", op, op)
+	}
+	return
+}
+
+// DecodeOp reconstructs an op from its OpRecord, by calling the registered factory and then unmarshalling the parameters into the op.
+// Ops that are values (as opposed to pointers) are unmarshalled through a pointer to them, so it is enough for *T to implement encoding.BinaryUnmarshaler.
+func DecodeOp(rec OpRecord) (retVal Op, err error) {
+	factory, ok := opFactory(rec.Name)
+	if !ok {
+		return nil, errors.Errorf("No op registered as %q", rec.Name)
+	}
+
+	retVal = factory()
+	if len(rec.Data) == 0 {
+		return
+	}
+
+	if u, ok := retVal.(encoding.BinaryUnmarshaler); ok {
+		if err = u.UnmarshalBinary(rec.Data); err != nil {
+			return nil, errors.Wrapf(err, "Unable to unmarshal %q", rec.Name)
+		}
+		return
+	}
+
+	ptr := reflect.New(reflect.TypeOf(retVal))
+	ptr.Elem().Set(reflect.ValueOf(retVal))
+	u, ok := ptr.Interface().(encoding.BinaryUnmarshaler)
+	if !ok {
+		return nil, errors.Errorf("%q has parameters but %T does not implement encoding.BinaryUnmarshaler", rec.Name, retVal)
+	}
+	if err = u.UnmarshalBinary(rec.Data); err != nil {
+		return nil, errors.Wrapf(err, "Unable to unmarshal %q", rec.Name)
+	}
+	return ptr.Elem().Interface().(Op), nil
+}
+
+// opParamsVersion is the first byte of the parameters of a built-in op. It is bumped whenever any built-in op writes out its parameters differently.
+const opParamsVersion byte = 1
+
+// paramWriter writes out the parameters of a built-in op, in little endian, after the opParamsVersion.
+// It keeps the first error, which bytes returns, so that a MarshalBinary can write out all of the parameters before checking.
+type paramWriter struct {
+	buf bytes.Buffer
+	err error
+}
+
+func newParamWriter() *paramWriter {
+	w := new(paramWriter)
+	w.buf.WriteByte(opParamsVersion)
+	return w
+}
+
+func (w *paramWriter) write(v interface{}) {
+	if w.err == nil {
+		w.err = binary.Write(&w.buf, binary.LittleEndian, v)
+	}
+}
+
+func (w *paramWriter) fail(format string, args ...interface{}) {
+	if w.err == nil {
+		w.err = errors.Errorf(format, args...)
+	}
+}
+
+func (w *paramWriter) writeByte(b byte)     { w.write(b) }
+func (w *paramWriter) writeBool(b bool)     { w.write(b) }
+func (w *paramWriter) writeInt(i int)       { w.write(int64(i)) }
+func (w *paramWriter) writeFloat(f float64) { w.write(f) }
+
+// writeInts writes out the number of ints before the ints. A nil slice is written out as -1 ints, so that it is read back as nil and not as an empty slice.
+func (w *paramWriter) writeInts(is []int) {
+	if is == nil {
+		w.writeInt(-1)
+		return
+	}
+	w.writeInt(len(is))
+	for _, i := range is {
+		w.writeInt(i)
+	}
+}
+
+// writeType writes out a Dtype, or a *TensorType of a Dtype. Those are the types that ops are made with once the types are pruned.
+func (w *paramWriter) writeType(t Type) {
+	switch tt := t.(type) {
+	case Dtype:
+		w.writeByte(0)
+		w.writeByte(byte(tt))
+	case *TensorType:
+		dt, ok := tt.of.(Dtype)
+		if !ok {
+			w.fail("Cannot write out %v, which is not a tensor of a Dtype", t)
+			return
+		}
+		w.writeByte(1)
+		w.writeByte(byte(dt))
+		w.writeInt(tt.d)
+		w.writeInts(tt.shape)
+	default:
+		w.fail("Cannot write out %v of %T. Only Dtypes and tensor types can be written out", t, t)
+	}
+}
+
+// writeValue writes out the Dtype, the shape and the elements of a Scalar or a Tensor
+func (w *paramWriter) writeValue(v Value) {
+	var data interface{}
+	switch vt := v.(type) {
+	case Scalar:
+		w.writeByte(0)
+		w.writeByte(byte(vt.t))
+		data = vt.v
+		if i, ok := data.(int); ok {
+			data = int64(i)
+		}
+	case Tensor:
+		if vt.Tensor == nil {
+			w.fail("Cannot write out a Tensor that holds no tensor")
+			return
+		}
+		// the elements are written straight off the backing array, which a view does not lay out by its shape
+		if vt.DataSize() != vt.Shape().TotalSize() {
+			w.fail("Cannot write out a view of shape %v. Materialize it first", vt.Shape())
+			return
+		}
+		w.writeByte(1)
+		w.writeByte(byte(vt.Dtype()))
+		w.writeInts(vt.Shape())
+		data = vt.Data()
+		if is, ok := data.([]int); ok {
+			i64s := make([]int64, len(is))
+			for i, v := range is {
+				i64s[i] = int64(v)
+			}
+			data = i64s
+		}
+	default:
+		w.fail("Cannot write out %v of %T. Only Scalars and Tensors can be written out", v, v)
+		return
+	}
+	w.write(data)
+}
+
+// bytes returns what has been written out, or the first error
+func (w *paramWriter) bytes() ([]byte, error) {
+	if w.err != nil {
+		return nil, w.err
+	}
+	return w.buf.Bytes(), nil
+}
+
+// paramReader reads back the parameters that a paramWriter wrote out. Like the paramWriter, it keeps the first error, which done returns.
+// An UnmarshalBinary reads all of the parameters, checks them, and then calls done.
+type paramReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func newParamReader(data []byte) *paramReader {
+	r := &paramReader{r: bytes.NewReader(data)}
+	if v := r.readByte(); r.err == nil && v != opParamsVersion {
+		r.err = errors.Errorf("Expected parameters of version %d. Got version %d instead", opParamsVersion, v)
+	}
+	return r
+}
+
+func (r *paramReader) read(v interface{}) {
+	if r.err == nil {
+		if err := binary.Read(r.r, binary.LittleEndian, v); err != nil {
+			r.err = errors.Wrap(err, "The parameters are cut short")
+		}
+	}
+}
+
+// check fails with the error if ok is false, unless reading has failed already
+func (r *paramReader) check(ok bool, format string, args ...interface{}) {
+	if r.err == nil && !ok {
+		r.err = errors.Errorf(format, args...)
+	}
+}
+
+// checkAxes checks that the dims of a tensor, and the axes of it, are not negative
+func (r *paramReader) checkAxes(d int, along ...int) {
+	ok := d >= 0
+	for _, a := range along {
+		ok = ok && a >= 0
+	}
+	r.check(ok, "Expected a tensor of non-negative dims, and non-negative axes of it. Got %d dims and axes %v instead", d, along)
+}
+
+// checkWRT checks that the input a derivative op is taken with respect to is one of the inputs of the op it is the derivative of
+func (r *paramReader) checkWRT(wrt, inputs int) {
+	r.check(wrt >= 0 && wrt < inputs, "Expected the derivative wrt one of %d inputs. Got input %d instead", inputs, wrt)
+}
+
+func (r *paramReader) readByte() (b byte) {
+	r.read(&b)
+	return
+}
+
+func (r *paramReader) readBool() (b bool) {
+	r.read(&b)
+	return
+}
+
+func (r *paramReader) readFloat() (f float64) {
+	r.read(&f)
+	return
+}
+
+func (r *paramReader) readInt() int {
+	var i int64
+	r.read(&i)
+	return int(i)
+}
+
+func (r *paramReader) readInts() []int {
+	n := r.readInt()
+	if r.err != nil || n == -1 {
+		return nil
+	}
+	r.check(n >= 0 && n <= r.r.Len()/8, "Expected at most %d ints. Got a count of %d instead", r.r.Len()/8, n)
+	if r.err != nil {
+		return nil
+	}
+	is := make([]int, n)
+	for i := range is {
+		is[i] = r.readInt()
+	}
+	return is
+}
+
+func (r *paramReader) readShape() types.Shape {
+	s := r.readInts()
+	for _, d := range s {
+		r.check(d > 0, "Expected a shape of positive sizes. Got %v instead", s)
+	}
+	if s == nil {
+		return nil
+	}
+	return types.Shape(s)
+}
+
+func (r *paramReader) readDtype() Dtype {
+	dt := Dtype(r.readByte())
+	r.check(dt < Ptr, "Expected a Dtype. Got %d instead", dt)
+	return dt
+}
+
+func (r *paramReader) readType() Type {
+	switch kind := r.readByte(); {
+	case r.err != nil:
+		return nil
+	case kind == 0:
+		return r.readDtype()
+	case kind == 1:
+		dt := r.readDtype()
+		d := r.readInt()
+		shape := r.readShape()
+		r.check(d >= 0, "Expected a tensor type of non-negative dims. Got %d instead", d)
+		if r.err != nil {
+			return nil
+		}
+		tt := newTensorType(d, dt)
+		tt.shape = shape
+		return tt
+	default:
+		r.check(false, "Expected a Dtype or a tensor type. Got kind %d instead", kind)
+		return nil
+	}
+}
+
+func (r *paramReader) readValue() Value {
+	kind := r.readByte()
+	dt := r.readDtype()
+	if r.err != nil {
+		return nil
+	}
+
+	switch kind {
+	case 0:
+		var v interface{}
+		switch dt {
+		case Float64:
+			var f float64
+			r.read(&f)
+			v = f
+		case Float32:
+			var f float32
+			r.read(&f)
+			v = f
+		case Int:
+			v = r.readInt()
+		case Int64:
+			var i int64
+			r.read(&i)
+			v = i
+		case Int32:
+			var i int32
+			r.read(&i)
+			v = i
+		case Byte:
+			v = r.readByte()
+		case Bool:
+			v = r.readBool()
+		}
+		if r.err != nil {
+			return nil
+		}
+		return NewScalarValue(v)
+	case 1:
+		shape := r.readShape()
+		r.check(len(shape) > 0, "Expected the shape of a tensor. Got %v instead", shape)
+		if r.err != nil {
+			return nil
+		}
+		// the size is checked against what is left before anything is allocated, so that a bad shape cannot make a huge allocation
+		size := shape.TotalSize()
+		r.check(size <= r.r.Len(), "Expected a tensor of at most %d elements. Got a shape of %v instead", r.r.Len(), shape)
+		if r.err != nil {
+			return nil
+		}
+
+		var t types.Tensor
+		switch dt {
+		case Float64:
+			backing := make([]float64, size)
+			r.read(backing)
+			t = tf64.NewTensor(tf64.WithBacking(backing), tf64.WithShape(shape...))
+		case Float32:
+			backing := make([]float32, size)
+			r.read(backing)
+			t = tf32.NewTensor(tf32.WithBacking(backing), tf32.WithShape(shape...))
+		case Int:
+			i64s := make([]int64, size)
+			r.read(i64s)
+			backing := make([]int, size)
+			for i, v := range i64s {
+				backing[i] = int(v)
+			}
+			t = ti.NewTensor(ti.WithBacking(backing), ti.WithShape(shape...))
+		case Bool:
+			backing := make([]bool, size)
+			r.read(backing)
+			t = tb.NewTensor(tb.WithBacking(backing), tb.WithShape(shape...))
+		default:
+			r.check(false, "Expected a tensor of Float64, Float32, Int or Bool. Got %v instead", dt)
+		}
+		if r.err != nil {
+			return nil
+		}
+		return FromTensor(t)
+	}
+	r.check(false, "Expected a Scalar or a Tensor. Got kind %d instead", kind)
+	return nil
+}
+
+// done returns the first error, if any. It is also an error to leave any of the parameters unread.
+func (r *paramReader) done() error {
+	r.check(r.r.Len() == 0, "%d bytes are left over after the parameters", r.r.Len())
+	return r.err
+}
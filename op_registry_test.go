@@ -0,0 +1,336 @@
+package gorgonia
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"hash"
+	"hash/fnv"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	tf64 "github.com/chewxy/gorgonia/tensor/f64"
+	ti "github.com/chewxy/gorgonia/tensor/i"
+	"github.com/chewxy/gorgonia/tensor/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// dummyOp is a registered op with a parameter, whose String() is left empty so that it is printed by its registered name
+type dummyOp struct {
+	factor float64
+}
+
+func (op dummyOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(a, a)
+}
+
+func (op dummyOp) inferShape(t Type, inputs ...*Node) (types.Shape, error) {
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op dummyOp) DiffWRT(inputs int) []bool                  { return make([]bool, inputs) }
+func (op dummyOp) SymDiff(Nodes, *Node, *Node) (Nodes, error) { return nil, nondiffErr(op) }
+func (op dummyOp) Do(inputs ...Value) (Value, error) {
+	return anyToValue(inputs[0].Data().(float64) * op.factor)
+}
+func (op dummyOp) returnsPtr() bool    { return false }
+func (op dummyOp) callsExtern() bool   { return false }
+func (op dummyOp) overwriteInput() int { return -1 }
+func (op dummyOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("dummy"))
+	binary.Write(h, binary.LittleEndian, op.factor)
+}
+func (op dummyOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+func (op dummyOp) String() string { return "" }
+
+func (op dummyOp) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, math.Float64bits(op.factor))
+	return buf, nil
+}
+
+func (op *dummyOp) UnmarshalBinary(data []byte) error {
+	if len(data) != 8 {
+		return errors.Errorf("Expected 8 bytes. Got %d instead", len(data))
+	}
+	op.factor = math.Float64frombits(binary.LittleEndian.Uint64(data))
+	return nil
+}
+
+// unregisteredOp is never registered
+type unregisteredOp struct{ dummyOp }
+
+func init() {
+	RegisterOp("dummyOp", func() Op { return dummyOp{factor: 1} })
+}
+
+func TestRegisterOp(t *testing.T) {
+	assert := assert.New(t)
+
+	// round trip through gob and json
+	op := dummyOp{factor: 2.5}
+	rec, err := EncodeOp(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal("dummyOp", rec.Name)
+
+	var buf bytes.Buffer
+	if err = gob.NewEncoder(&buf).Encode(rec); err != nil {
+		t.Fatal(err)
+	}
+	var gobRec OpRecord
+	if err = gob.NewDecoder(&buf).Decode(&gobRec); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeOp(gobRec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(op, decoded)
+	assert.Equal(op.Hashcode(), decoded.Hashcode())
+
+	js, err := json.Marshal(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jsonRec OpRecord
+	if err = json.Unmarshal(js, &jsonRec); err != nil {
+		t.Fatal(err)
+	}
+	if decoded, err = DecodeOp(jsonRec); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(op, decoded)
+
+	// the decoded op works in a graph, and is printed by its registered name
+	g := NewGraph()
+	x := NewScalar(g, Float64, WithName("x"))
+	y := Must(applyOp(decoded, x))
+	assert.True(strings.HasPrefix(y.Name(), "dummyOp("), "Got %q", y.Name())
+	assert.Equal("dummyOp", opString(decoded))
+
+	Let(x, 2.0)
+	m := NewLispMachine(g, ExecuteFwdOnly())
+	if err = m.RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(5.0, y.Value().Data())
+
+	// an empty record gives what the factory creates
+	if decoded, err = DecodeOp(OpRecord{Name: "dummyOp"}); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(dummyOp{factor: 1}, decoded)
+
+	// built-in ops are registered
+	rec, err = EncodeOp(letOp{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(OpRecord{Name: "letOp"}, rec)
+	if decoded, err = DecodeOp(rec); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(letOp{}, decoded)
+
+	// built-in ops with parameters write them out
+	a := NewScalar(g, Float64, WithName("a"))
+	add := newElemBinOp(addOpType, a, a)
+	if rec, err = EncodeOp(add); err != nil {
+		t.Fatal(err)
+	}
+	if decoded, err = DecodeOp(rec); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(add, decoded)
+
+	// built-in ops whose parameters cannot be written out are not silently dropped
+	if _, err = EncodeOp(newApplyFnOp(math.Sin, math.Cos)); err == nil {
+		t.Error("Expected an error encoding an applyFnOp")
+	}
+
+	// unknown ops
+	if _, err = EncodeOp(unregisteredOp{}); err == nil {
+		t.Error("Expected an error encoding an unregistered op")
+	}
+	if _, err = DecodeOp(OpRecord{Name: "unknownOp"}); err == nil {
+		t.Error("Expected an error decoding an unregistered op")
+	}
+	assert.Equal("gorgonia.unregisteredOp", opString(unregisteredOp{}))
+
+	// names and types may only be registered once
+	assert.Panics(func() { RegisterOp("dummyOp", func() Op { return unregisteredOp{} }) })
+	assert.Panics(func() { RegisterOp("anotherDummyOp", func() Op { return dummyOp{} }) })
+}
+
+func TestEncodeBuiltinOps(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGraph()
+	x := NewMatrix(g, Float32, WithShape(2, 3), WithName("x"))
+	tt := newTensorType(2, Float64)
+	tt.shape = types.Shape{2, 3}
+	pe, err := newPositionalEncodingOp(3, 4, Float64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ops := []Op{
+		sumOp{along: axes{1}, d: 2},
+		sumOp{along: axes{0, 1}, d: 2, inputShape: types.Shape{2, 3}},
+		newMaxOp(axes{1}, 2, FirstTie),
+		newEBOByType(addOpType, Float64, Float64),
+		newEBOByType(mulOpType, tt, Float64),
+		newEBOByType(gtOpType, Float64, tt).withIntResult(),
+		newElemUnaryOp(tanhOpType, x),
+		linAlgBinOp{āBinaryOperator: matVecMulOperator, transA: true, batched: true},
+		reshapeOp{from: types.Shape{2, 3}, to: types.Shape{3, 2}},
+		transposeOp{pattern: []int{1, 0}, d: 2},
+		newSliceOp(S(1, 3), 1, 2),
+		newSliceOp(nil, 0, 2),
+		sliceIncrOp{newSliceOp(S(0), 0, 1)},
+		&repeatOp{along: axes{1}, inputShape: types.Shape{2, 3}, d: 2, arg0Dim: 2, children: 2},
+		broadcastToOp{from: types.Shape{3, 1}, to: types.Shape{3, 4}},
+		layoutOp{from: NHWC, to: NCHW},
+		fakeQuantDiffOp{scale: 0.1, zeroPoint: 3, qmin: 0, qmax: 255},
+		huberLossDiffOp{fwd: huberLossOp{delta: 1.5, d: 2}, wrt: 1},
+		randomOp{which: gaussian, shape: types.Shape{2, 2}, dt: Float32, a: 0, b: 1},
+		constantScalar{NewScalarValue(2.5)},
+		constantScalar{NewScalarValue(int32(-7))},
+		constantTensor{FromTensor(tf64.NewTensor(tf64.WithBacking([]float64{1, 2, 3, 4, 5, 6}), tf64.WithShape(2, 3)))},
+		constantTensor{FromTensor(ti.NewTensor(ti.WithBacking([]int{1, -2, 3}), ti.WithShape(3)))},
+		pe,
+	}
+	for _, op := range ops {
+		rec, err := EncodeOp(op)
+		if err != nil {
+			t.Errorf("Encoding %v: %v", op, err)
+			continue
+		}
+		decoded, err := DecodeOp(rec)
+		if err != nil {
+			t.Errorf("Decoding %v: %v", op, err)
+			continue
+		}
+		assert.Equal(op, decoded, "%v", op)
+		assert.Equal(op.Hashcode(), decoded.Hashcode(), "%v", op)
+	}
+
+	// a noiseOp is rebuilt with a generator of its own, which draws the same values as the original did from the start
+	noise := makeNoiseOp(uniform, Float64, -1, 1, 1337, types.Shape{4})
+	rec, err := EncodeOp(noise)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeOp(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(noise.Hashcode(), decoded.Hashcode())
+	assert.Equal(noise.String(), decoded.String())
+	want, _ := noise.Do()
+	got, err := decoded.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(want.Data(), got.Data())
+
+	// a decoded op works in a graph
+	xv := tf64.NewTensor(tf64.WithBacking([]float64{1, 2, 3, 4, 5, 6}), tf64.WithShape(2, 3))
+	m := NewMatrix(g, Float64, WithShape(2, 3), WithValue(xv))
+	sum := Must(Sum(m, 1))
+	if rec, err = EncodeOp(sum.op); err != nil {
+		t.Fatal(err)
+	}
+	if decoded, err = DecodeOp(rec); err != nil {
+		t.Fatal(err)
+	}
+	g2 := NewGraph()
+	m2 := NewMatrix(g2, Float64, WithShape(2, 3), WithValue(xv))
+	sum2 := Must(applyOp(decoded, m2))
+	if err = NewLispMachine(g2, ExecuteFwdOnly()).RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]float64{6, 15}, sum2.Value().Data())
+
+	// records of another version, cut short, or with bytes left over, are errors
+	if rec, err = EncodeOp(transposeOp{pattern: []int{1, 0}, d: 2}); err != nil {
+		t.Fatal(err)
+	}
+	bad := append([]byte{opParamsVersion + 1}, rec.Data[1:]...)
+	_, err = DecodeOp(OpRecord{Name: rec.Name, Data: bad})
+	if assert.NotNil(err) {
+		assert.Contains(err.Error(), "version")
+	}
+	_, err = DecodeOp(OpRecord{Name: rec.Name, Data: rec.Data[:len(rec.Data)-1]})
+	assert.NotNil(err, "The parameters are cut short")
+	_, err = DecodeOp(OpRecord{Name: rec.Name, Data: append(append([]byte{}, rec.Data...), 0)})
+	assert.NotNil(err, "A byte is left over")
+
+	// ops that could not have been made are errors too
+	tensorOpOnScalars := newEBOByType(addOpType, tt, Float64)
+	tensorOpOnScalars.arg0 = Float64
+	invalid := []Op{
+		reshapeOp{from: types.Shape{2, 3}, to: types.Shape{4, 2}},
+		transposeOp{pattern: []int{0, 0}, d: 2},
+		transposeOp{pattern: []int{1, 0}, d: 3},
+		broadcastToOp{from: types.Shape{3, 2}, to: types.Shape{3, 4}},
+		sumOp{along: axes{-1}, d: 2},
+		fakeQuantOp{scale: 0.1, zeroPoint: 300, qmin: 0, qmax: 255},
+		layoutOp{from: NHWC, to: Layout(7)},
+		huberLossDiffOp{fwd: huberLossOp{delta: 1.5, d: 2}, wrt: 2},
+		tensorOpOnScalars,
+	}
+	for _, op := range invalid {
+		rec, err := EncodeOp(op)
+		if err != nil {
+			t.Errorf("Encoding %v: %v", op, err)
+			continue
+		}
+		_, err = DecodeOp(rec)
+		assert.NotNil(err, "%v", op)
+	}
+
+	// operators are looked up again, and must exist for the Dtype
+	unary, err := newElemUnaryOp(tanhOpType, x).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unary[1] = byte(Bool)
+	var euo elemUnaryOp
+	if err = euo.UnmarshalBinary(unary); assert.NotNil(err) {
+		assert.Contains(err.Error(), "There is no tanh operator on Bool")
+	}
+	unary[1], unary[2] = byte(Float32), byte(maxʘUnaryOperator)
+	assert.NotNil(euo.UnmarshalBinary(unary))
+
+	// ops that hold functions or state say why they cannot be encoded
+	for _, op := range []Op{newApplyFnOp(math.Sin, nil), foldOp{}, centerLossOp{}, runningStatsOp{}, readOp{}} {
+		_, err := EncodeOp(op)
+		assert.NotNil(err, "%T", op)
+	}
+
+	// every registered op that has parameters writes them out
+	opRegistry.RLock()
+	defer opRegistry.RUnlock()
+	for typ, name := range opRegistry.names {
+		st := typ
+		if st.Kind() == reflect.Ptr {
+			st = st.Elem()
+		}
+		if st.Kind() == reflect.Struct && st.NumField() > 0 && !typ.Implements(reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()) {
+			t.Errorf("%q has parameters but does not implement encoding.BinaryMarshaler", name)
+		}
+	}
+}
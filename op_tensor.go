@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"hash"
 	"hash/fnv"
+	"strings"
 
 	"github.com/chewxy/gorgonia/tensor"
 	tf32 "github.com/chewxy/gorgonia/tensor/f32"
@@ -80,6 +81,21 @@ func (op atOp) Hashcode() uint32 {
 	return h.Sum32()
 }
 
+func (op atOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInts(op.coordinates)
+	w.writeInt(op.d)
+	return w.bytes()
+}
+
+func (op *atOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.coordinates = coordinates(r.readInts())
+	op.d = r.readInt()
+	r.checkAxes(op.d, op.coordinates...)
+	return r.done()
+}
+
 func (op atOp) isStmt() bool { return true }
 
 type sizeOp struct {
@@ -177,6 +193,104 @@ func (op sizeOp) Hashcode() uint32 {
 	return h.Sum32()
 }
 
+func (op sizeOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.axis)
+	w.writeInt(op.d)
+	w.writeInt(op.val)
+	return w.bytes()
+}
+
+func (op *sizeOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.axis = r.readInt()
+	op.d = r.readInt()
+	op.val = r.readInt()
+	r.checkAxes(op.d, op.axis)
+	r.check(op.val >= 0, "Expected a size that is not negative. Got %d instead", op.val)
+	return r.done()
+}
+
+// shapeOfOp returns the shape of its input as a vector of ints.
+type shapeOfOp struct {
+	d    int // dimensions of the input type
+	rank int // length of the input's shape. This may differ from d for row and column vectors
+}
+
+// shapeOfOp is a function with this type:
+//		shapeOfOp :: Tensor d a → Vector Int
+func (op shapeOfOp) Type() Type {
+	a := newTypeVariable("a")
+	tt := newTensorType(op.d, a)
+	return newFunctionType(tt, newTensorType(1, Int))
+}
+
+func (op shapeOfOp) returnsPtr() bool    { return false }
+func (op shapeOfOp) overwriteInput() int { return -1 }
+func (op shapeOfOp) callsExtern() bool   { return false }
+func (op shapeOfOp) inferShape(Type, ...*Node) (types.Shape, error) {
+	return types.Shape{op.rank}, nil
+}
+func (op shapeOfOp) DiffWRT(i int) []bool { return make([]bool, i) }
+func (op shapeOfOp) String() string       { return "ShapeOf" }
+
+func (op shapeOfOp) SymDiff(inputs Nodes, output, gradNode *Node) (Nodes, error) {
+	return nil, nondiffErr(op)
+}
+
+func (op shapeOfOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "shapeOfOp only takes one input. Got %v instead", len(inputs))
+		return
+	}
+
+	t, ok := inputs[0].(Tensor)
+	if !ok {
+		return nil, errors.Errorf("Expected a Tensor. Got %v of %T instead", inputs[0], inputs[0])
+	}
+
+	shp := t.Shape()
+	if len(shp) != op.rank {
+		return nil, errors.Errorf("Expected a Tensor with a shape of length %d. Got one with shape %v instead", op.rank, shp)
+	}
+
+	backing := make([]int, len(shp))
+	copy(backing, shp)
+	return FromTensor(ti.NewTensor(ti.WithBacking(backing), ti.WithShape(len(backing)))), nil
+}
+
+func (op shapeOfOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("shapeOf"))
+	if err := binary.Write(h, binary.LittleEndian, byte(op.d)); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, byte(op.rank)); err != nil {
+		panic(err)
+	}
+}
+
+func (op shapeOfOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op shapeOfOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.d)
+	w.writeInt(op.rank)
+	return w.bytes()
+}
+
+func (op *shapeOfOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.d = r.readInt()
+	op.rank = r.readInt()
+	r.checkAxes(op.d)
+	r.check(op.rank >= op.d, "Expected a shape of at least %d dims. Got a rank of %d instead", op.d, op.rank)
+	return r.done()
+}
+
 type repeatOp struct {
 	along axes
 
@@ -260,10 +374,27 @@ func (op repeatOp) inferShape(retType Type, inputs ...*Node) (retVal types.Shape
 		if size, ok := rep.op.(sizeOp); ok && size.val > 0 {
 			knownRepeats[i] = size.val
 		}
+
+		// sizes that are known ahead of time are constants
+		if c, ok := rep.op.(constantScalar); ok {
+			if r, err := valuesToInts([]Value{c.v}); err == nil {
+				knownRepeats[i] = r[0]
+			}
+		}
 	}
 
 	if input.IsScalar() {
-		retVal = types.Shape{1, 1} // fill it up just in case
+		// fill it up just in case, with as many dims as it takes to repeat along every axis
+		dims := 2
+		for _, axis := range op.along {
+			if axis+1 > dims {
+				dims = axis + 1
+			}
+		}
+		retVal = make(types.Shape, dims)
+		for i := range retVal {
+			retVal[i] = 1
+		}
 	} else {
 		retVal = input.shape.Clone()
 	}
@@ -416,6 +547,18 @@ func (op repeatOp) Do(inputs ...Value) (retVal Value, err error) {
 			err = nyi("repeatOp.Do() Scalar Input", iv)
 			return
 		}
+
+		// a scalar repeated into a tensor of more than 2 dims has to have all of them to begin with
+		if op.d > 2 {
+			ones := make([]int, op.d)
+			for i := range ones {
+				ones[i] = 1
+			}
+			if err = t.Reshape(ones...); err != nil {
+				err = errors.Wrapf(err, reshapeFail, ones, t.DataSize())
+				return
+			}
+		}
 	}
 
 	// actually do repeat
@@ -459,6 +602,29 @@ func (op repeatOp) Hashcode() uint32 {
 	return h.Sum32()
 }
 
+func (op repeatOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInts(op.along)
+	w.writeInts(op.inputShape)
+	w.writeInt(op.d)
+	w.writeInt(op.arg0Dim)
+	w.writeInt(op.children)
+	return w.bytes()
+}
+
+func (op *repeatOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.along = axes(r.readInts())
+	op.inputShape = r.readShape()
+	op.d = r.readInt()
+	op.arg0Dim = r.readInt()
+	op.children = r.readInt()
+	r.checkAxes(op.d, op.along...)
+	r.checkAxes(op.arg0Dim)
+	r.check(op.children == len(op.along)+1, "Expected a repeat along %v to have %d children. Got %d instead", op.along, len(op.along)+1, op.children)
+	return r.done()
+}
+
 // sliceOp represents a slicing operation. If end <= start, it means ":"
 type sliceOp struct {
 	types.Slice
@@ -512,7 +678,12 @@ func (op sliceOp) inferShape(typ Type, inputs ...*Node) (s types.Shape, err erro
 	}
 
 	t := inputs[0]
-	return t.shape.S(op.Slice)
+	if op.along >= len(t.shape) {
+		return nil, errors.Errorf("Cannot slice along axis %d of a tensor shaped %v", op.along, t.shape)
+	}
+	slices := make([]types.Slice, op.along+1)
+	slices[op.along] = op.Slice
+	return t.shape.S(slices...)
 }
 
 func (op sliceOp) DiffWRT(i int) []bool {
@@ -671,6 +842,33 @@ func (op sliceOp) Hashcode() uint32 {
 	return h.Sum32()
 }
 
+// MarshalBinary writes out the start, end and step of the slice. A decoded sliceOp slices the same way, but its slice is the package's own types.Slice.
+// sliceIncrOp is written out by the same methods, which it gets from the sliceOp it embeds.
+func (op sliceOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeBool(op.Slice != nil)
+	if op.Slice != nil {
+		w.writeInt(op.Start())
+		w.writeInt(op.End())
+		w.writeInt(op.Step())
+	}
+	w.writeInt(op.along)
+	w.writeInt(op.d)
+	return w.bytes()
+}
+
+func (op *sliceOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.Slice = nil
+	if r.readBool() {
+		op.Slice = sli{start: r.readInt(), end: r.readInt(), step: r.readInt()}
+	}
+	op.along = r.readInt()
+	op.d = r.readInt()
+	r.checkAxes(op.d, op.along)
+	return r.done()
+}
+
 func (op sliceOp) String() string {
 	var buf bytes.Buffer
 	buf.WriteString("T[")
@@ -904,6 +1102,41 @@ func (op sliceIncrOp) String() string {
 	return buf.String()
 }
 
+// Layout names the order of the axes of a 4D batch of images: N is the batch, C the channels, and H and W the height and width.
+type Layout byte
+
+const (
+	// NCHW puts the channels before the height and width, as cuDNN does by default.
+	NCHW Layout = iota
+
+	// NHWC puts the channels last, as TensorFlow does by default.
+	NHWC
+)
+
+func (l Layout) String() string {
+	switch l {
+	case NCHW:
+		return "NCHW"
+	case NHWC:
+		return "NHWC"
+	}
+	return fmt.Sprintf("Layout(%d)", byte(l))
+}
+
+// permutation returns the pattern that transposes a tensor laid out as l into the layout to
+func (l Layout) permutation(to Layout) (pattern []int, err error) {
+	from, into := l.String(), to.String()
+	if len(from) != 4 || len(into) != 4 {
+		return nil, errors.Errorf("Cannot transpose from %v to %v", l, to)
+	}
+
+	pattern = make([]int, 4)
+	for i, axis := range into {
+		pattern[i] = strings.IndexRune(from, axis)
+	}
+	return
+}
+
 type transposeOp struct {
 	pattern []int
 	d       int
@@ -1034,6 +1267,31 @@ func (op transposeOp) Hashcode() uint32 {
 	return h.Sum32()
 }
 
+func (op transposeOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInts(op.pattern)
+	w.writeInt(op.d)
+	return w.bytes()
+}
+
+// UnmarshalBinary checks that the pattern is a permutation of the d axes, as Transpose would have made it
+func (op *transposeOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.pattern = r.readInts()
+	op.d = r.readInt()
+
+	seen := make([]bool, len(op.pattern))
+	ok := len(op.pattern) == op.d
+	for _, a := range op.pattern {
+		ok = ok && a >= 0 && a < len(seen) && !seen[a]
+		if ok {
+			seen[a] = true
+		}
+	}
+	r.check(ok, "Expected the pattern to be a permutation of %d axes. Got %v instead", op.d, op.pattern)
+	return r.done()
+}
+
 func (op transposeOp) String() string {
 	var buf bytes.Buffer
 	buf.WriteString("Aᵀ{")
@@ -1047,3 +1305,1793 @@ func (op transposeOp) String() string {
 	buf.WriteString("}")
 	return buf.String()
 }
+
+// reshapeOp reshapes a tensor to the given shape. The total size of the shape may not change.
+type reshapeOp struct {
+	from, to types.Shape
+}
+
+// reshaping a tensor has type
+//		reshape :: Tensor a → Tensor a
+// where the dimensions of the input and output may differ
+func (op reshapeOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	// a column vector is still a vector, so Dims() is used instead of the length of the shapes
+	from := newTensorType(op.from.Dims(), a)
+	to := newTensorType(op.to.Dims(), a)
+	return newFunctionType(from, to)
+}
+
+func (op reshapeOp) inferShape(typ Type, inputs ...*Node) (types.Shape, error) {
+	if len(inputs) != 1 {
+		return nil, NewError(GraphError, "reshapeOp should only have one input. Got %v instead", len(inputs))
+	}
+
+	if inputs[0].shape.TotalSize() != op.to.TotalSize() {
+		return nil, errors.Errorf("Cannot reshape %v to %v", inputs[0].shape, op.to)
+	}
+	return op.to.Clone(), nil
+}
+
+func (op reshapeOp) DiffWRT(i int) []bool { return []bool{true} }
+
+func (op reshapeOp) SymDiff(inputs Nodes, outputNode, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		return nil, NewError(GraphError, "reshapeOp should only have one input. Got %v instead", len(inputs))
+	}
+
+	var dx *Node
+	if dx, err = applyOp(reshapeOp{from: op.to, to: op.from}, gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	return Nodes{dx}, nil
+}
+
+func (op reshapeOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	xdv := inputs[0].boundTo.(*dualValue)
+	zdv := output.boundTo.(*dualValue)
+
+	back := reshapeOp{from: op.to, to: op.from}
+	var d Value
+	if d, err = back.Do(zdv.d); err != nil {
+		return errors.Wrapf(err, doFail, back)
+	}
+
+	add := newEBOByType(addOpType, inputs[0].t, inputs[0].t)
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		err = errors.Wrapf(err, doFail, add)
+	}
+	return
+}
+
+func (op reshapeOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		return nil, errors.Errorf("reshapeOp should only have one input. Got %v instead", len(inputs))
+	}
+
+	t, ok := inputs[0].(Tensor)
+	if !ok {
+		return nil, errors.Errorf("Expected a Tensor. Got %v of %T instead", inputs[0], inputs[0])
+	}
+
+	if t.Shape().TotalSize() != op.to.TotalSize() {
+		return nil, errors.Errorf(reshapeFail, op.to, t.DataSize())
+	}
+
+	// the input is not touched; the (materialized) data is copied into a new tensor of the new shape
+	switch tt := t.Tensor.(type) {
+	case *tf64.Tensor:
+		data := make([]float64, op.to.TotalSize())
+		copy(data, materializedF64s(tt))
+		retVal = FromTensor(tf64.NewTensor(tf64.WithBacking(data), tf64.WithShape(op.to.Clone()...)))
+	case *tf32.Tensor:
+		data := make([]float32, op.to.TotalSize())
+		copy(data, materializedF32s(tt))
+		retVal = FromTensor(tf32.NewTensor(tf32.WithBacking(data), tf32.WithShape(op.to.Clone()...)))
+	default:
+		return nil, errors.Errorf(nyiFail, "reshapeOp.Do()", t.Tensor)
+	}
+	return
+}
+
+func (op reshapeOp) returnsPtr() bool    { return false }
+func (op reshapeOp) callsExtern() bool   { return false }
+func (op reshapeOp) overwriteInput() int { return -1 }
+
+func (op reshapeOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("reshapeOp"))
+	for _, s := range []types.Shape{op.from, op.to} {
+		if err := binary.Write(h, binary.LittleEndian, byte(len(s))); err != nil {
+			panic(err)
+		}
+		for _, d := range s {
+			if err := binary.Write(h, binary.LittleEndian, int64(d)); err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+func (op reshapeOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op reshapeOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInts(op.from)
+	w.writeInts(op.to)
+	return w.bytes()
+}
+
+// UnmarshalBinary checks that the shapes have the same total size, as Reshape would have
+func (op *reshapeOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.from = r.readShape()
+	op.to = r.readShape()
+	r.check(len(op.from) > 0 && len(op.to) > 0, "Expected the shapes of tensors. Got %v and %v instead", op.from, op.to)
+	r.check(op.from.TotalSize() == op.to.TotalSize(), "Cannot reshape %v to %v", op.from, op.to)
+	return r.done()
+}
+
+func (op reshapeOp) String() string { return fmt.Sprintf("Reshape%v", op.to) }
+
+// broadcastToOp broadcasts a tensor to a larger shape, following the usual (NumPy) broadcasting rules:
+// the shapes are aligned on their last axes, and each axis of the input has to be either 1 or the size of the corresponding axis of the output.
+// Missing leading axes are treated as axes of size 1.
+type broadcastToOp struct {
+	from, to types.Shape
+}
+
+// broadcastTo has type
+//		broadcastTo :: Tensor a → Tensor a
+// where the output may have more dimensions than the input
+func (op broadcastToOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	from := newTensorType(op.from.Dims(), a)
+	to := newTensorType(op.to.Dims(), a)
+	return newFunctionType(from, to)
+}
+
+func (op broadcastToOp) inferShape(typ Type, inputs ...*Node) (types.Shape, error) {
+	if len(inputs) != 1 {
+		return nil, NewError(GraphError, "broadcastToOp should only have one input. Got %v instead", len(inputs))
+	}
+
+	if _, err := broadcastAxes(inputs[0].shape, op.to); err != nil {
+		return nil, err
+	}
+	return op.to.Clone(), nil
+}
+
+func (op broadcastToOp) DiffWRT(i int) []bool { return []bool{true} }
+
+func (op broadcastToOp) SymDiff(inputs Nodes, outputNode, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		return nil, NewError(GraphError, "broadcastToOp should only have one input. Got %v instead", len(inputs))
+	}
+
+	var dx *Node
+	if dx, err = applyOp(broadcastToDiffOp(op), gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	return Nodes{dx}, nil
+}
+
+func (op broadcastToOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	xdv := inputs[0].boundTo.(*dualValue)
+	zdv := output.boundTo.(*dualValue)
+
+	back := broadcastToDiffOp(op)
+	var d Value
+	if d, err = back.Do(zdv.d); err != nil {
+		return errors.Wrapf(err, doFail, back)
+	}
+
+	add := newEBOByType(addOpType, inputs[0].t, inputs[0].t)
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		err = errors.Wrapf(err, doFail, add)
+	}
+	return
+}
+
+func (op broadcastToOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		return nil, errors.Errorf("broadcastToOp should only have one input. Got %v instead", len(inputs))
+	}
+
+	var axes []int
+	if axes, err = broadcastAxes(inputs[0].Shape(), op.to); err != nil {
+		return
+	}
+
+	// pad the shape with the leading axes, then repeat along every broadcast axis
+	padded := make(types.Shape, len(op.to)-len(op.from), len(op.to))
+	for i := range padded {
+		padded[i] = 1
+	}
+	padded = append(padded, op.from...)
+
+	pad := reshapeOp{from: op.from, to: padded}
+	if retVal, err = pad.Do(inputs[0]); err != nil {
+		return nil, errors.Wrapf(err, doFail, pad)
+	}
+
+	t := retVal.(Tensor).Tensor
+	for _, axis := range axes {
+		if t, err = tensor.Repeat(t, axis, op.to[axis]); err != nil {
+			return nil, errors.Wrapf(err, repFail, axis, op.to[axis])
+		}
+	}
+	return FromTensor(t), nil
+}
+
+func (op broadcastToOp) returnsPtr() bool    { return false }
+func (op broadcastToOp) callsExtern() bool   { return false }
+func (op broadcastToOp) overwriteInput() int { return -1 }
+
+func (op broadcastToOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("broadcastToOp"))
+	reshapeOp(op).WriteHash(h)
+}
+
+func (op broadcastToOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op broadcastToOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInts(op.from)
+	w.writeInts(op.to)
+	return w.bytes()
+}
+
+// UnmarshalBinary checks that from can be broadcast to to, as BroadcastTo would have
+func (op *broadcastToOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.from = r.readShape()
+	op.to = r.readShape()
+	r.check(len(op.from) > 0, "Expected the shape of a tensor. Got %v instead", op.from)
+	if r.err == nil {
+		_, r.err = broadcastAxes(op.from, op.to)
+	}
+	return r.done()
+}
+
+func (op broadcastToOp) String() string { return fmt.Sprintf("BroadcastTo%v", op.to) }
+
+// broadcastToDiffOp sums the gradient of a broadcastToOp back over the broadcast axes.
+type broadcastToDiffOp struct {
+	from, to types.Shape
+}
+
+func (op broadcastToDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	from := newTensorType(op.from.Dims(), a)
+	to := newTensorType(op.to.Dims(), a)
+	return newFunctionType(to, from)
+}
+
+func (op broadcastToDiffOp) inferShape(typ Type, inputs ...*Node) (types.Shape, error) {
+	if len(inputs) != 1 {
+		return nil, NewError(GraphError, "broadcastToDiffOp should only have one input. Got %v instead", len(inputs))
+	}
+	return op.from.Clone(), nil
+}
+
+func (op broadcastToDiffOp) DiffWRT(i int) []bool { return []bool{true} }
+
+func (op broadcastToDiffOp) SymDiff(inputs Nodes, outputNode, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		return nil, NewError(GraphError, "broadcastToDiffOp should only have one input. Got %v instead", len(inputs))
+	}
+
+	var dx *Node
+	if dx, err = applyOp(broadcastToOp(op), gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	return Nodes{dx}, nil
+}
+
+func (op broadcastToDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		return nil, errors.Errorf("broadcastToDiffOp should only have one input. Got %v instead", len(inputs))
+	}
+
+	t, ok := inputs[0].(Tensor)
+	if !ok {
+		return nil, errors.Errorf("Expected a Tensor. Got %v of %T instead", inputs[0], inputs[0])
+	}
+
+	var axes []int
+	if axes, err = broadcastAxes(op.from, t.Shape()); err != nil {
+		return
+	}
+
+	// the last axis is summed first, so that the rest of the axes stay where they are
+	sum := t.Tensor
+	for i := len(axes) - 1; i >= 0; i-- {
+		if sum, err = tensor.Sum(sum, axes[i]); err != nil {
+			return nil, errors.Wrap(err, sumFail)
+		}
+	}
+
+	unpad := reshapeOp{from: sum.Shape(), to: op.from}
+	return unpad.Do(FromTensor(sum))
+}
+
+func (op broadcastToDiffOp) returnsPtr() bool    { return false }
+func (op broadcastToDiffOp) callsExtern() bool   { return false }
+func (op broadcastToDiffOp) overwriteInput() int { return -1 }
+
+func (op broadcastToDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("broadcastToDiffOp"))
+	reshapeOp(op).WriteHash(h)
+}
+
+func (op broadcastToDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op broadcastToDiffOp) MarshalBinary() ([]byte, error) {
+	return broadcastToOp(op).MarshalBinary()
+}
+
+func (op *broadcastToDiffOp) UnmarshalBinary(data []byte) error {
+	return (*broadcastToOp)(op).UnmarshalBinary(data)
+}
+
+func (op broadcastToDiffOp) String() string { return fmt.Sprintf("Σ→%v", op.from) }
+
+// broadcastAxes returns the axes of to along which a tensor of shape from has to be repeated, after from has been padded with leading axes of size 1.
+func broadcastAxes(from, to types.Shape) (retVal []int, err error) {
+	if len(from) > len(to) {
+		return nil, errors.Errorf("Cannot broadcast %v to %v: too many dimensions", from, to)
+	}
+
+	offset := len(to) - len(from)
+	for i, d := range to {
+		f := 1
+		if i >= offset {
+			f = from[i-offset]
+		}
+
+		switch {
+		case f == d:
+		case f == 1:
+			retVal = append(retVal, i)
+		default:
+			return nil, errors.Errorf("Cannot broadcast %v to %v: axis %d has size %d", from, to, i, f)
+		}
+	}
+	return
+}
+
+// gatherOp takes the elements of a tensor at the given indices along an axis, in the style of NumPy's take.
+// The indices are an Int vector, and may repeat.
+type gatherOp struct {
+	axis, d int
+}
+
+// gatherOp has this type:
+//		op :: Tensor-d a → Vector Int → Tensor-d a
+func (op gatherOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	tt := newTensorType(op.d, a)
+	return newFunctionType(tt, newTensorType(1, Int), tt)
+}
+
+func (op gatherOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "gatherOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	x, indices := inputs[0], inputs[1]
+	if op.axis < 0 || op.axis >= len(x.shape) {
+		return nil, errors.Errorf(invalidAxis, op.axis, len(x.shape))
+	}
+
+	retVal = x.shape.Clone()
+	retVal[op.axis] = indices.shape.TotalSize()
+	return
+}
+
+func (op gatherOp) DiffWRT(inputs int) []bool { return []bool{true, false} }
+
+func (op gatherOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "gatherOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(gatherDiffOp(op), inputs[0], inputs[1], gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx, nil}, nil
+}
+
+func (op gatherOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "gatherOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	var d Value
+	if d, err = gatherDiffOp(op).Do(xdv.Value, inputs[1].Value(), ydv.d); err != nil {
+		return errors.Wrap(err, "gatherOp.DoDiff()")
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op gatherOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "gatherOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var x Tensor
+	var indices []int
+	var outer, dim, inner int
+	if x, indices, outer, dim, inner, err = gatherOperands(op.axis, inputs[0], inputs[1]); err != nil {
+		return
+	}
+
+	shp := x.Shape().Clone()
+	shp[op.axis] = len(indices)
+	switch xt := x.Tensor.(type) {
+	case *tf64.Tensor:
+		data := materializedF64s(xt)
+		out := make([]float64, shp.TotalSize())
+		for o := 0; o < outer; o++ {
+			for j, idx := range indices {
+				copy(out[(o*len(indices)+j)*inner:(o*len(indices)+j+1)*inner], data[(o*dim+idx)*inner:(o*dim+idx+1)*inner])
+			}
+		}
+		retVal = FromTensor(tf64.NewTensor(tf64.WithBacking(out), tf64.WithShape(shp...)))
+	case *tf32.Tensor:
+		data := materializedF32s(xt)
+		out := make([]float32, shp.TotalSize())
+		for o := 0; o < outer; o++ {
+			for j, idx := range indices {
+				copy(out[(o*len(indices)+j)*inner:(o*len(indices)+j+1)*inner], data[(o*dim+idx)*inner:(o*dim+idx+1)*inner])
+			}
+		}
+		retVal = FromTensor(tf32.NewTensor(tf32.WithBacking(out), tf32.WithShape(shp...)))
+	default:
+		return nil, errors.Errorf(nyiFail, "gatherOp.Do()", x.Tensor)
+	}
+	return
+}
+
+func (op gatherOp) returnsPtr() bool    { return false }
+func (op gatherOp) callsExtern() bool   { return false }
+func (op gatherOp) overwriteInput() int { return -1 }
+
+func (op gatherOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("gatherOp"))
+	if err := binary.Write(h, binary.LittleEndian, byte(op.axis)); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, byte(op.d)); err != nil {
+		panic(err)
+	}
+}
+
+func (op gatherOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op gatherOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.axis)
+	w.writeInt(op.d)
+	return w.bytes()
+}
+
+func (op *gatherOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.axis = r.readInt()
+	op.d = r.readInt()
+	r.checkAxes(op.d, op.axis)
+	return r.done()
+}
+
+func (op gatherOp) String() string { return fmt.Sprintf("Gather{axis=%d}", op.axis) }
+
+// gatherDiffOp is the derivative of gatherOp. It takes the gathered tensor, the indices, and the gradient,
+// and scatter-adds the gradient back along the axis. Repeated indices accumulate.
+type gatherDiffOp struct {
+	axis, d int
+}
+
+// gatherDiffOp has this type:
+//		op :: Tensor-d a → Vector Int → Tensor-d a → Tensor-d a
+func (op gatherDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	tt := newTensorType(op.d, a)
+	return newFunctionType(tt, newTensorType(1, Int), tt, tt)
+}
+
+func (op gatherDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "gatherDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op gatherDiffOp) DiffWRT(inputs int) []bool { return make([]bool, inputs) }
+func (op gatherDiffOp) SymDiff(inputs Nodes, output, gradNode *Node) (Nodes, error) {
+	return nil, nondiffErr(op)
+}
+
+func (op gatherDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "gatherDiffOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var x Tensor
+	var indices []int
+	var outer, dim, inner int
+	if x, indices, outer, dim, inner, err = gatherOperands(op.axis, inputs[0], inputs[1]); err != nil {
+		return
+	}
+
+	grad, ok := inputs[2].(Tensor)
+	if !ok || grad.Shape().TotalSize() != outer*len(indices)*inner {
+		return nil, errors.Errorf("Expected a gradient with %d elements. Got %v instead", outer*len(indices)*inner, inputs[2])
+	}
+	if grad.Dtype() != x.Dtype() {
+		return nil, errors.Errorf(dtypeMismatch, x.Dtype(), grad.Dtype())
+	}
+
+	shp := x.Shape().Clone()
+	switch gt := grad.Tensor.(type) {
+	case *tf64.Tensor:
+		g := materializedF64s(gt)
+		out := make([]float64, shp.TotalSize())
+		for o := 0; o < outer; o++ {
+			for j, idx := range indices {
+				src := g[(o*len(indices)+j)*inner : (o*len(indices)+j+1)*inner]
+				dst := out[(o*dim+idx)*inner : (o*dim+idx+1)*inner]
+				for k, v := range src {
+					dst[k] += v
+				}
+			}
+		}
+		retVal = FromTensor(tf64.NewTensor(tf64.WithBacking(out), tf64.WithShape(shp...)))
+	case *tf32.Tensor:
+		g := materializedF32s(gt)
+		out := make([]float32, shp.TotalSize())
+		for o := 0; o < outer; o++ {
+			for j, idx := range indices {
+				src := g[(o*len(indices)+j)*inner : (o*len(indices)+j+1)*inner]
+				dst := out[(o*dim+idx)*inner : (o*dim+idx+1)*inner]
+				for k, v := range src {
+					dst[k] += v
+				}
+			}
+		}
+		retVal = FromTensor(tf32.NewTensor(tf32.WithBacking(out), tf32.WithShape(shp...)))
+	default:
+		return nil, errors.Errorf(nyiFail, "gatherDiffOp.Do()", grad.Tensor)
+	}
+	return
+}
+
+func (op gatherDiffOp) returnsPtr() bool    { return false }
+func (op gatherDiffOp) callsExtern() bool   { return false }
+func (op gatherDiffOp) overwriteInput() int { return -1 }
+
+func (op gatherDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	gatherOp(op).WriteHash(h)
+}
+
+func (op gatherDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op gatherDiffOp) MarshalBinary() ([]byte, error) {
+	return gatherOp(op).MarshalBinary()
+}
+
+func (op *gatherDiffOp) UnmarshalBinary(data []byte) error {
+	return (*gatherOp)(op).UnmarshalBinary(data)
+}
+
+func (op gatherDiffOp) String() string { return fmt.Sprintf("∂Gather{axis=%d}", op.axis) }
+
+// gatherOperands checks the operands of a gather, and returns the indices as well as the sizes of the
+// axes before the gathered axis (outer), the gathered axis (dim), and the axes after (inner).
+func gatherOperands(axis int, xV, indicesV Value) (x Tensor, indices []int, outer, dim, inner int, err error) {
+	var ok bool
+	if x, ok = xV.(Tensor); !ok {
+		err = errors.Errorf("Expected a Tensor. Got %v of %T instead", xV, xV)
+		return
+	}
+
+	shp := x.Shape()
+	if axis < 0 || axis >= len(shp) {
+		err = errors.Errorf(invalidAxis, axis, len(shp))
+		return
+	}
+
+	it, ok := indicesV.(Tensor)
+	if !ok {
+		err = errors.Errorf("Expected a Tensor of indices. Got %v of %T instead", indicesV, indicesV)
+		return
+	}
+	iT, ok := it.Tensor.(*ti.Tensor)
+	if !ok {
+		err = errors.Errorf(dtypeMismatch, Int, it.Dtype())
+		return
+	}
+	if iT.IsMaterializable() {
+		iT = iT.Materialize().(*ti.Tensor)
+	}
+	indices = iT.Data().([]int)
+
+	dim = shp[axis]
+	outer, inner = 1, 1
+	for _, s := range shp[:axis] {
+		outer *= s
+	}
+	for _, s := range shp[axis+1:] {
+		inner *= s
+	}
+
+	for _, idx := range indices {
+		if idx < 0 || idx >= dim {
+			err = errors.Errorf("Index %d is out of bounds for axis %d of size %d", idx, axis, dim)
+			return
+		}
+	}
+	return
+}
+
+// scatterOp writes the updates into a copy of the base at the given indices along an axis. It is the inverse of gatherOp.
+// If an index repeats, the last update written to it wins.
+type scatterOp struct {
+	axis, d int
+}
+
+// scatterOp has this type:
+//		op :: Tensor-d a → Vector Int → Tensor-d a → Tensor-d a
+func (op scatterOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	tt := newTensorType(op.d, a)
+	return newFunctionType(tt, newTensorType(1, Int), tt, tt)
+}
+
+func (op scatterOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "scatterOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	base, indices, updates := inputs[0], inputs[1], inputs[2]
+	if op.axis < 0 || op.axis >= len(base.shape) {
+		return nil, errors.Errorf(invalidAxis, op.axis, len(base.shape))
+	}
+
+	expected := base.shape.Clone()
+	expected[op.axis] = indices.shape.TotalSize()
+	if !expected.Eq(updates.shape) {
+		return nil, errors.Errorf("Expected updates shaped %v. Got %v instead", expected, updates.shape)
+	}
+	return base.shape.Clone(), nil
+}
+
+func (op scatterOp) DiffWRT(inputs int) []bool { return []bool{true, false, true} }
+
+func (op scatterOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "scatterOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var dBase, dUpdates *Node
+	if dBase, err = applyOp(scatterDiffOp(op), gradNode, inputs[1]); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dBase.setGroup(gradClust)
+
+	if dUpdates, err = applyOp(gatherOp(op), gradNode, inputs[1]); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dUpdates.setGroup(gradClust)
+	return Nodes{dBase, nil, dUpdates}, nil
+}
+
+func (op scatterOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "scatterOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	bdv := inputs[0].boundTo.(*dualValue)
+	udv := inputs[2].boundTo.(*dualValue)
+	odv := output.boundTo.(*dualValue)
+	indices := inputs[1].Value()
+
+	var dBase, dUpdates Value
+	if dBase, err = scatterDiffOp(op).Do(odv.d, indices); err != nil {
+		return errors.Wrap(err, "scatterOp.DoDiff()")
+	}
+	if dUpdates, err = gatherOp(op).Do(odv.d, indices); err != nil {
+		return errors.Wrap(err, "scatterOp.DoDiff()")
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(bdv.d, dBase); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+
+	add = newElemBinOp(addOpType, inputs[2], inputs[2])
+	if _, err = add.UnsafeDo(udv.d, dUpdates); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op scatterOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 3 {
+		err = NewError(GraphError, "scatterOp expects 3 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var base Tensor
+	var indices []int
+	var outer, dim, inner int
+	if base, indices, outer, dim, inner, err = gatherOperands(op.axis, inputs[0], inputs[1]); err != nil {
+		return
+	}
+
+	updates, ok := inputs[2].(Tensor)
+	if !ok || updates.Shape().TotalSize() != outer*len(indices)*inner {
+		return nil, errors.Errorf("Expected updates with %d elements. Got %v instead", outer*len(indices)*inner, inputs[2])
+	}
+	if updates.Dtype() != base.Dtype() {
+		return nil, errors.Errorf(dtypeMismatch, base.Dtype(), updates.Dtype())
+	}
+
+	shp := base.Shape().Clone()
+	switch bt := base.Tensor.(type) {
+	case *tf64.Tensor:
+		out := make([]float64, shp.TotalSize())
+		copy(out, materializedF64s(bt))
+		u := materializedF64s(updates.Tensor.(*tf64.Tensor))
+		for o := 0; o < outer; o++ {
+			for j, idx := range indices {
+				copy(out[(o*dim+idx)*inner:(o*dim+idx+1)*inner], u[(o*len(indices)+j)*inner:(o*len(indices)+j+1)*inner])
+			}
+		}
+		retVal = FromTensor(tf64.NewTensor(tf64.WithBacking(out), tf64.WithShape(shp...)))
+	case *tf32.Tensor:
+		out := make([]float32, shp.TotalSize())
+		copy(out, materializedF32s(bt))
+		u := materializedF32s(updates.Tensor.(*tf32.Tensor))
+		for o := 0; o < outer; o++ {
+			for j, idx := range indices {
+				copy(out[(o*dim+idx)*inner:(o*dim+idx+1)*inner], u[(o*len(indices)+j)*inner:(o*len(indices)+j+1)*inner])
+			}
+		}
+		retVal = FromTensor(tf32.NewTensor(tf32.WithBacking(out), tf32.WithShape(shp...)))
+	default:
+		return nil, errors.Errorf(nyiFail, "scatterOp.Do()", base.Tensor)
+	}
+	return
+}
+
+func (op scatterOp) returnsPtr() bool    { return false }
+func (op scatterOp) callsExtern() bool   { return false }
+func (op scatterOp) overwriteInput() int { return -1 }
+
+func (op scatterOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("scatterOp"))
+	if err := binary.Write(h, binary.LittleEndian, byte(op.axis)); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, byte(op.d)); err != nil {
+		panic(err)
+	}
+}
+
+func (op scatterOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op scatterOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.axis)
+	w.writeInt(op.d)
+	return w.bytes()
+}
+
+func (op *scatterOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.axis = r.readInt()
+	op.d = r.readInt()
+	r.checkAxes(op.d, op.axis)
+	return r.done()
+}
+
+func (op scatterOp) String() string { return fmt.Sprintf("Scatter{axis=%d}", op.axis) }
+
+// scatterDiffOp is the derivative of scatterOp wrt the base. It takes the gradient and the indices,
+// and returns the gradient with the scattered positions zeroed, as they were overwritten.
+type scatterDiffOp struct {
+	axis, d int
+}
+
+// scatterDiffOp has this type:
+//		op :: Tensor-d a → Vector Int → Tensor-d a
+func (op scatterDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	tt := newTensorType(op.d, a)
+	return newFunctionType(tt, newTensorType(1, Int), tt)
+}
+
+func (op scatterDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "scatterDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+	return inputs[0].shape.Clone(), nil
+}
+
+func (op scatterDiffOp) DiffWRT(inputs int) []bool { return make([]bool, inputs) }
+func (op scatterDiffOp) SymDiff(inputs Nodes, output, gradNode *Node) (Nodes, error) {
+	return nil, nondiffErr(op)
+}
+
+func (op scatterDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 2 {
+		err = NewError(GraphError, "scatterDiffOp expects 2 inputs. Got %d instead", len(inputs))
+		return
+	}
+
+	var grad Tensor
+	var indices []int
+	var outer, dim, inner int
+	if grad, indices, outer, dim, inner, err = gatherOperands(op.axis, inputs[0], inputs[1]); err != nil {
+		return
+	}
+
+	shp := grad.Shape().Clone()
+	switch gt := grad.Tensor.(type) {
+	case *tf64.Tensor:
+		out := make([]float64, shp.TotalSize())
+		copy(out, materializedF64s(gt))
+		for o := 0; o < outer; o++ {
+			for _, idx := range indices {
+				zeroed := out[(o*dim+idx)*inner : (o*dim+idx+1)*inner]
+				for k := range zeroed {
+					zeroed[k] = 0
+				}
+			}
+		}
+		retVal = FromTensor(tf64.NewTensor(tf64.WithBacking(out), tf64.WithShape(shp...)))
+	case *tf32.Tensor:
+		out := make([]float32, shp.TotalSize())
+		copy(out, materializedF32s(gt))
+		for o := 0; o < outer; o++ {
+			for _, idx := range indices {
+				zeroed := out[(o*dim+idx)*inner : (o*dim+idx+1)*inner]
+				for k := range zeroed {
+					zeroed[k] = 0
+				}
+			}
+		}
+		retVal = FromTensor(tf32.NewTensor(tf32.WithBacking(out), tf32.WithShape(shp...)))
+	default:
+		return nil, errors.Errorf(nyiFail, "scatterDiffOp.Do()", grad.Tensor)
+	}
+	return
+}
+
+func (op scatterDiffOp) returnsPtr() bool    { return false }
+func (op scatterDiffOp) callsExtern() bool   { return false }
+func (op scatterDiffOp) overwriteInput() int { return -1 }
+
+func (op scatterDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂"))
+	scatterOp(op).WriteHash(h)
+}
+
+func (op scatterDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op scatterDiffOp) MarshalBinary() ([]byte, error) {
+	return scatterOp(op).MarshalBinary()
+}
+
+func (op *scatterDiffOp) UnmarshalBinary(data []byte) error {
+	return (*scatterOp)(op).UnmarshalBinary(data)
+}
+
+func (op scatterDiffOp) String() string { return fmt.Sprintf("∂Scatter{axis=%d}", op.axis) }
+
+// blockDiagOp places n matrices on the diagonal of a larger matrix, which is zero everywhere else.
+// The result has as many rows as all the inputs put together, and as many columns.
+type blockDiagOp struct {
+	n int
+}
+
+// blockDiagOp has this type:
+//		op :: Matrix a → Matrix a → ... → Matrix a
+// with n matrices as inputs
+func (op blockDiagOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	ts := make([]Type, op.n+1)
+	for i := range ts {
+		ts[i] = newTensorType(2, a)
+	}
+	return newFunctionType(ts...)
+}
+
+func (op blockDiagOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != op.n {
+		err = NewError(GraphError, "blockDiagOp expects %d inputs. Got %d instead", op.n, len(inputs))
+		return
+	}
+
+	var rows, cols int
+	for _, in := range inputs {
+		if len(in.shape) != 2 {
+			return nil, errors.Errorf("Expected a matrix. Got %v, which has a shape of %v instead", in, in.shape)
+		}
+		rows += in.shape[0]
+		cols += in.shape[1]
+	}
+	return types.Shape{rows, cols}, nil
+}
+
+func (op blockDiagOp) DiffWRT(inputs int) []bool {
+	retVal := make([]bool, inputs)
+	for i := range retVal {
+		retVal[i] = true
+	}
+	return retVal
+}
+
+func (op blockDiagOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != op.n {
+		err = NewError(GraphError, "blockDiagOp expects %d inputs. Got %d instead", op.n, len(inputs))
+		return
+	}
+
+	var row, col int
+	for _, in := range inputs {
+		diff := blockDiagDiffOp{row: row, col: col, rows: in.shape[0], cols: in.shape[1]}
+
+		var dx *Node
+		if dx, err = applyOp(diff, gradNode); err != nil {
+			return nil, errors.Wrap(err, applyOpFail)
+		}
+		dx.setGroup(gradClust)
+		retVal = append(retVal, dx)
+
+		row += in.shape[0]
+		col += in.shape[1]
+	}
+	return
+}
+
+func (op blockDiagOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != op.n {
+		err = NewError(GraphError, "blockDiagOp expects %d inputs. Got %d instead", op.n, len(inputs))
+		return
+	}
+
+	odv := output.boundTo.(*dualValue)
+	var row, col int
+	for _, in := range inputs {
+		diff := blockDiagDiffOp{row: row, col: col, rows: in.shape[0], cols: in.shape[1]}
+
+		var d Value
+		if d, err = diff.Do(odv.d); err != nil {
+			return errors.Wrapf(err, doFail, diff)
+		}
+
+		indv := in.boundTo.(*dualValue)
+		add := newElemBinOp(addOpType, in, in)
+		if _, err = add.UnsafeDo(indv.d, d); err != nil {
+			return errors.Wrapf(err, unsafeDoFail, add)
+		}
+
+		row += in.shape[0]
+		col += in.shape[1]
+	}
+	return
+}
+
+func (op blockDiagOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != op.n {
+		err = NewError(GraphError, "blockDiagOp expects %d inputs. Got %d instead", op.n, len(inputs))
+		return
+	}
+
+	blocks := make([]Tensor, len(inputs))
+	var rows, cols int
+	for i, in := range inputs {
+		var ok bool
+		if blocks[i], ok = in.(Tensor); !ok || len(blocks[i].Shape()) != 2 {
+			return nil, errors.Errorf("Expected a matrix. Got %v instead", in)
+		}
+		if blocks[i].Dtype() != blocks[0].Dtype() {
+			return nil, errors.Errorf(dtypeMismatch, blocks[0].Dtype(), blocks[i].Dtype())
+		}
+		rows += blocks[i].Shape()[0]
+		cols += blocks[i].Shape()[1]
+	}
+
+	var row, col int
+	switch blocks[0].Tensor.(type) {
+	case *tf64.Tensor:
+		out := make([]float64, rows*cols)
+		for _, b := range blocks {
+			r, c := b.Shape()[0], b.Shape()[1]
+			data := materializedF64s(b.Tensor.(*tf64.Tensor))
+			for i := 0; i < r; i++ {
+				copy(out[(row+i)*cols+col:], data[i*c:(i+1)*c])
+			}
+			row += r
+			col += c
+		}
+		retVal = FromTensor(tf64.NewTensor(tf64.WithBacking(out), tf64.WithShape(rows, cols)))
+	case *tf32.Tensor:
+		out := make([]float32, rows*cols)
+		for _, b := range blocks {
+			r, c := b.Shape()[0], b.Shape()[1]
+			data := materializedF32s(b.Tensor.(*tf32.Tensor))
+			for i := 0; i < r; i++ {
+				copy(out[(row+i)*cols+col:], data[i*c:(i+1)*c])
+			}
+			row += r
+			col += c
+		}
+		retVal = FromTensor(tf32.NewTensor(tf32.WithBacking(out), tf32.WithShape(rows, cols)))
+	default:
+		return nil, errors.Errorf(nyiFail, "blockDiagOp.Do()", blocks[0].Tensor)
+	}
+	return
+}
+
+func (op blockDiagOp) returnsPtr() bool    { return false }
+func (op blockDiagOp) callsExtern() bool   { return false }
+func (op blockDiagOp) overwriteInput() int { return -1 }
+
+func (op blockDiagOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("blockDiagOp"))
+	if err := binary.Write(h, binary.LittleEndian, int64(op.n)); err != nil {
+		panic(err)
+	}
+}
+
+func (op blockDiagOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op blockDiagOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.n)
+	return w.bytes()
+}
+
+func (op *blockDiagOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.n = r.readInt()
+	r.check(op.n > 0, "BlockDiag needs at least one matrix. Got %d instead", op.n)
+	return r.done()
+}
+
+func (op blockDiagOp) String() string { return fmt.Sprintf("BlockDiag{%d}", op.n) }
+
+// blockDiagDiffOp is the derivative of blockDiagOp wrt one of its blocks. It takes the gradient of the output,
+// and slices out the rows×cols block that starts at (row, col).
+type blockDiagDiffOp struct {
+	row, col   int
+	rows, cols int
+}
+
+// blockDiagDiffOp has this type:
+//		op :: Matrix a → Matrix a
+func (op blockDiagDiffOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(newTensorType(2, a), newTensorType(2, a))
+}
+
+func (op blockDiagDiffOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "blockDiagDiffOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+	return types.Shape{op.rows, op.cols}, nil
+}
+
+func (op blockDiagDiffOp) DiffWRT(inputs int) []bool { return make([]bool, inputs) }
+func (op blockDiagDiffOp) SymDiff(inputs Nodes, output, gradNode *Node) (Nodes, error) {
+	return nil, nondiffErr(op)
+}
+
+func (op blockDiagDiffOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "blockDiagDiffOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	grad, ok := inputs[0].(Tensor)
+	if !ok || len(grad.Shape()) != 2 {
+		return nil, errors.Errorf("Expected a matrix. Got %v instead", inputs[0])
+	}
+	shp := grad.Shape()
+	if op.row+op.rows > shp[0] || op.col+op.cols > shp[1] {
+		return nil, errors.Errorf("Cannot slice a %d×%d block at (%d, %d) out of a matrix shaped %v", op.rows, op.cols, op.row, op.col, shp)
+	}
+
+	cols := shp[1]
+	switch gt := grad.Tensor.(type) {
+	case *tf64.Tensor:
+		data := materializedF64s(gt)
+		out := make([]float64, op.rows*op.cols)
+		for i := 0; i < op.rows; i++ {
+			start := (op.row+i)*cols + op.col
+			copy(out[i*op.cols:(i+1)*op.cols], data[start:start+op.cols])
+		}
+		retVal = FromTensor(tf64.NewTensor(tf64.WithBacking(out), tf64.WithShape(op.rows, op.cols)))
+	case *tf32.Tensor:
+		data := materializedF32s(gt)
+		out := make([]float32, op.rows*op.cols)
+		for i := 0; i < op.rows; i++ {
+			start := (op.row+i)*cols + op.col
+			copy(out[i*op.cols:(i+1)*op.cols], data[start:start+op.cols])
+		}
+		retVal = FromTensor(tf32.NewTensor(tf32.WithBacking(out), tf32.WithShape(op.rows, op.cols)))
+	default:
+		return nil, errors.Errorf(nyiFail, "blockDiagDiffOp.Do()", grad.Tensor)
+	}
+	return
+}
+
+func (op blockDiagDiffOp) returnsPtr() bool    { return false }
+func (op blockDiagDiffOp) callsExtern() bool   { return false }
+func (op blockDiagDiffOp) overwriteInput() int { return -1 }
+
+func (op blockDiagDiffOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("∂blockDiagOp"))
+	for _, v := range []int{op.row, op.col, op.rows, op.cols} {
+		if err := binary.Write(h, binary.LittleEndian, int64(v)); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (op blockDiagDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op blockDiagDiffOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.row)
+	w.writeInt(op.col)
+	w.writeInt(op.rows)
+	w.writeInt(op.cols)
+	return w.bytes()
+}
+
+func (op *blockDiagDiffOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.row = r.readInt()
+	op.col = r.readInt()
+	op.rows = r.readInt()
+	op.cols = r.readInt()
+	r.check(op.row >= 0 && op.col >= 0 && op.rows > 0 && op.cols > 0, "Expected a block at a non-negative row and column, of positive size. Got a (%d, %d) block at (%d, %d) instead", op.rows, op.cols, op.row, op.col)
+	return r.done()
+}
+
+func (op blockDiagDiffOp) String() string {
+	return fmt.Sprintf("∂BlockDiag{%d:%d, %d:%d}", op.row, op.row+op.rows, op.col, op.col+op.cols)
+}
+
+// symmetrizeOp computes (A + Aᵀ)/2 of a square matrix A. It is linear, and its own adjoint,
+// so the gradient is the output gradient symmetrized the same way: (gradZ + gradZᵀ)/2.
+type symmetrizeOp struct{}
+
+// symmetrizeOp has this type:
+//		op :: Matrix a → Matrix a
+func (op symmetrizeOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	return newFunctionType(newTensorType(2, a), newTensorType(2, a))
+}
+
+func (op symmetrizeOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "symmetrizeOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	shp := inputs[0].shape
+	if len(shp) != 2 || shp[0] != shp[1] {
+		return nil, errors.Errorf("Expected a square matrix. Got %v, which has a shape of %v instead", inputs[0], shp)
+	}
+	return shp.Clone(), nil
+}
+
+func (op symmetrizeOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+func (op symmetrizeOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "symmetrizeOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(op, gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op symmetrizeOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "symmetrizeOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	var d Value
+	if d, err = op.Do(ydv.d); err != nil {
+		return errors.Wrapf(err, doFail, op)
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op symmetrizeOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "symmetrizeOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	t, ok := inputs[0].(Tensor)
+	if !ok || len(t.Shape()) != 2 || t.Shape()[0] != t.Shape()[1] {
+		return nil, errors.Errorf("Expected a square matrix. Got %v instead", inputs[0])
+	}
+
+	n := t.Shape()[0]
+	switch tt := t.Tensor.(type) {
+	case *tf64.Tensor:
+		data := materializedF64s(tt)
+		out := make([]float64, n*n)
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				out[i*n+j] = (data[i*n+j] + data[j*n+i]) / 2
+			}
+		}
+		retVal = FromTensor(tf64.NewTensor(tf64.WithBacking(out), tf64.WithShape(n, n)))
+	case *tf32.Tensor:
+		data := materializedF32s(tt)
+		out := make([]float32, n*n)
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				out[i*n+j] = (data[i*n+j] + data[j*n+i]) / 2
+			}
+		}
+		retVal = FromTensor(tf32.NewTensor(tf32.WithBacking(out), tf32.WithShape(n, n)))
+	default:
+		return nil, errors.Errorf(nyiFail, "symmetrizeOp.Do()", t.Tensor)
+	}
+	return
+}
+
+func (op symmetrizeOp) returnsPtr() bool    { return false }
+func (op symmetrizeOp) callsExtern() bool   { return false }
+func (op symmetrizeOp) overwriteInput() int { return -1 }
+
+func (op symmetrizeOp) WriteHash(h hash.Hash) { h.Write([]byte("symmetrizeOp")) }
+
+func (op symmetrizeOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op symmetrizeOp) String() string { return "Symmetrize" }
+
+// reverseOp reverses the order of the elements of a tensor along an axis. It is linear, and its own inverse as well as its own adjoint,
+// so the gradient is the output gradient reversed along the same axis.
+type reverseOp struct {
+	axis, d int
+}
+
+// reverseOp has this type:
+//		op :: Tensor-d a → Tensor-d a
+func (op reverseOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	tt := newTensorType(op.d, a)
+	return newFunctionType(tt, tt)
+}
+
+func (op reverseOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "reverseOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	x := inputs[0]
+	if op.axis < 0 || op.axis >= len(x.shape) {
+		return nil, errors.Errorf(invalidAxis, op.axis, len(x.shape))
+	}
+	return x.shape.Clone(), nil
+}
+
+func (op reverseOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+func (op reverseOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "reverseOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(op, gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op reverseOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "reverseOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	var d Value
+	if d, err = op.Do(ydv.d); err != nil {
+		return errors.Wrapf(err, doFail, op)
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op reverseOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "reverseOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	t, ok := inputs[0].(Tensor)
+	if !ok {
+		return nil, errors.Errorf("Expected a Tensor. Got %v of %T instead", inputs[0], inputs[0])
+	}
+	shp := t.Shape()
+	if op.axis < 0 || op.axis >= len(shp) {
+		return nil, errors.Errorf(invalidAxis, op.axis, len(shp))
+	}
+
+	// each run of inner elements is moved as a whole, from position k along the axis to position n-1-k
+	outer, n, inner := splitAlong(shp, op.axis)
+	switch tt := t.Tensor.(type) {
+	case *tf64.Tensor:
+		data := materializedF64s(tt)
+		out := make([]float64, len(data))
+		for o := 0; o < outer; o++ {
+			for k := 0; k < n; k++ {
+				from, to := (o*n+k)*inner, (o*n+n-1-k)*inner
+				copy(out[to:to+inner], data[from:from+inner])
+			}
+		}
+		retVal = FromTensor(tf64.NewTensor(tf64.WithBacking(out), tf64.WithShape(shp.Clone()...)))
+	case *tf32.Tensor:
+		data := materializedF32s(tt)
+		out := make([]float32, len(data))
+		for o := 0; o < outer; o++ {
+			for k := 0; k < n; k++ {
+				from, to := (o*n+k)*inner, (o*n+n-1-k)*inner
+				copy(out[to:to+inner], data[from:from+inner])
+			}
+		}
+		retVal = FromTensor(tf32.NewTensor(tf32.WithBacking(out), tf32.WithShape(shp.Clone()...)))
+	default:
+		return nil, errors.Errorf(nyiFail, "reverseOp.Do()", t.Tensor)
+	}
+	return
+}
+
+func (op reverseOp) returnsPtr() bool    { return false }
+func (op reverseOp) callsExtern() bool   { return false }
+func (op reverseOp) overwriteInput() int { return -1 }
+
+func (op reverseOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("reverseOp"))
+	if err := binary.Write(h, binary.LittleEndian, byte(op.axis)); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, byte(op.d)); err != nil {
+		panic(err)
+	}
+}
+
+func (op reverseOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op reverseOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.axis)
+	w.writeInt(op.d)
+	return w.bytes()
+}
+
+func (op *reverseOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.axis = r.readInt()
+	op.d = r.readInt()
+	r.checkAxes(op.d, op.axis)
+	return r.done()
+}
+
+func (op reverseOp) String() string { return fmt.Sprintf("Reverse{axis=%d}", op.axis) }
+
+// rollOp circularly shifts the elements of a tensor along an axis, so that the element at position k moves to k+shift, modulo the size of the axis.
+// Its inverse, which is also its adjoint, rolls by -shift, so the gradient is the output gradient rolled back by -shift.
+type rollOp struct {
+	shift   int
+	axis, d int
+}
+
+// rollOp has this type:
+//		op :: Tensor-d a → Tensor-d a
+func (op rollOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	tt := newTensorType(op.d, a)
+	return newFunctionType(tt, tt)
+}
+
+func (op rollOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "rollOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	x := inputs[0]
+	if op.axis < 0 || op.axis >= len(x.shape) {
+		return nil, errors.Errorf(invalidAxis, op.axis, len(x.shape))
+	}
+	return x.shape.Clone(), nil
+}
+
+// inverse returns the rollOp that undoes op
+func (op rollOp) inverse() rollOp {
+	op.shift = -op.shift
+	return op
+}
+
+func (op rollOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+func (op rollOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "rollOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(op.inverse(), gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op rollOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "rollOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	ydv := output.boundTo.(*dualValue)
+
+	inv := op.inverse()
+	var d Value
+	if d, err = inv.Do(ydv.d); err != nil {
+		return errors.Wrapf(err, doFail, inv)
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op rollOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "rollOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	t, ok := inputs[0].(Tensor)
+	if !ok {
+		return nil, errors.Errorf("Expected a Tensor. Got %v of %T instead", inputs[0], inputs[0])
+	}
+	shp := t.Shape()
+	if op.axis < 0 || op.axis >= len(shp) {
+		return nil, errors.Errorf(invalidAxis, op.axis, len(shp))
+	}
+
+	// each run of inner elements is moved as a whole, from position k along the axis to position k+shift
+	outer, n, inner := splitAlong(shp, op.axis)
+	shift := op.shift % n
+	if shift < 0 {
+		shift += n
+	}
+	switch tt := t.Tensor.(type) {
+	case *tf64.Tensor:
+		data := materializedF64s(tt)
+		out := make([]float64, len(data))
+		for o := 0; o < outer; o++ {
+			for k := 0; k < n; k++ {
+				from, to := (o*n+k)*inner, (o*n+(k+shift)%n)*inner
+				copy(out[to:to+inner], data[from:from+inner])
+			}
+		}
+		retVal = FromTensor(tf64.NewTensor(tf64.WithBacking(out), tf64.WithShape(shp.Clone()...)))
+	case *tf32.Tensor:
+		data := materializedF32s(tt)
+		out := make([]float32, len(data))
+		for o := 0; o < outer; o++ {
+			for k := 0; k < n; k++ {
+				from, to := (o*n+k)*inner, (o*n+(k+shift)%n)*inner
+				copy(out[to:to+inner], data[from:from+inner])
+			}
+		}
+		retVal = FromTensor(tf32.NewTensor(tf32.WithBacking(out), tf32.WithShape(shp.Clone()...)))
+	default:
+		return nil, errors.Errorf(nyiFail, "rollOp.Do()", t.Tensor)
+	}
+	return
+}
+
+func (op rollOp) returnsPtr() bool    { return false }
+func (op rollOp) callsExtern() bool   { return false }
+func (op rollOp) overwriteInput() int { return -1 }
+
+func (op rollOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("rollOp"))
+	if err := binary.Write(h, binary.LittleEndian, int64(op.shift)); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, byte(op.axis)); err != nil {
+		panic(err)
+	}
+	if err := binary.Write(h, binary.LittleEndian, byte(op.d)); err != nil {
+		panic(err)
+	}
+}
+
+func (op rollOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op rollOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeInt(op.shift)
+	w.writeInt(op.axis)
+	w.writeInt(op.d)
+	return w.bytes()
+}
+
+func (op *rollOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.shift = r.readInt()
+	op.axis = r.readInt()
+	op.d = r.readInt()
+	r.checkAxes(op.d, op.axis)
+	return r.done()
+}
+
+func (op rollOp) String() string { return fmt.Sprintf("Roll{shift=%d, axis=%d}", op.shift, op.axis) }
+
+// layoutOp transposes a 4D batch of images from one Layout to another. Unlike transposeOp, which returns a view,
+// it copies the elements into their new order, as the code the result is handed over to expects the elements of a layout to be contiguous.
+type layoutOp struct {
+	from, to Layout
+}
+
+// layoutOp has this type:
+//		op :: (Float a) ⇒ Tensor-4 a → Tensor-4 a
+func (op layoutOp) Type() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	tt := newTensorType(4, a)
+	return newFunctionType(tt, tt)
+}
+
+func (op layoutOp) inferShape(t Type, inputs ...*Node) (retVal types.Shape, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "layoutOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	x := inputs[0]
+	if len(x.shape) != 4 {
+		return nil, errors.Errorf("Expected a 4D tensor. Got %v instead", x.shape)
+	}
+
+	var pattern []int
+	if pattern, err = op.from.permutation(op.to); err != nil {
+		return
+	}
+	retVal = make(types.Shape, 4)
+	for i, axis := range pattern {
+		retVal[i] = x.shape[axis]
+	}
+	return
+}
+
+func (op layoutOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+// SymDiff transposes the gradient back into the layout of the input
+func (op layoutOp) SymDiff(inputs Nodes, output, gradNode *Node) (retVal Nodes, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "layoutOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var dx *Node
+	if dx, err = applyOp(layoutOp{from: op.to, to: op.from}, gradNode); err != nil {
+		return nil, errors.Wrap(err, applyOpFail)
+	}
+	dx.setGroup(gradClust)
+	return Nodes{dx}, nil
+}
+
+func (op layoutOp) DoDiff(inputs Nodes, output *Node) (err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "layoutOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	xdv := inputs[0].boundTo.(*dualValue)
+	odv := output.boundTo.(*dualValue)
+
+	back := layoutOp{from: op.to, to: op.from}
+	var d Value
+	if d, err = back.Do(odv.d); err != nil {
+		return errors.Wrapf(err, doFail, back)
+	}
+
+	add := newElemBinOp(addOpType, inputs[0], inputs[0])
+	if _, err = add.UnsafeDo(xdv.d, d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, add)
+	}
+	return
+}
+
+func (op layoutOp) Do(inputs ...Value) (retVal Value, err error) {
+	if len(inputs) != 1 {
+		err = NewError(GraphError, "layoutOp expects 1 input. Got %d instead", len(inputs))
+		return
+	}
+
+	var x []float64
+	var shp types.Shape
+	var dt Dtype
+	if x, shp, dt, err = floatsOperand(inputs[0]); err != nil {
+		return
+	}
+	if len(shp) != 4 {
+		return nil, errors.Errorf("Expected a 4D tensor. Got %v instead", shp)
+	}
+
+	var pattern []int
+	if pattern, err = op.from.permutation(op.to); err != nil {
+		return
+	}
+
+	// axis i of the output is axis pattern[i] of the input, so it is walked with the stride of that axis
+	strides := []int{shp[1] * shp[2] * shp[3], shp[2] * shp[3], shp[3], 1}
+	outShape := make(types.Shape, 4)
+	outStrides := make([]int, 4)
+	for i, axis := range pattern {
+		outShape[i] = shp[axis]
+		outStrides[i] = strides[axis]
+	}
+
+	y := make([]float64, 0, len(x))
+	for a := 0; a < outShape[0]; a++ {
+		for b := 0; b < outShape[1]; b++ {
+			for c := 0; c < outShape[2]; c++ {
+				for d := 0; d < outShape[3]; d++ {
+					y = append(y, x[a*outStrides[0]+b*outStrides[1]+c*outStrides[2]+d*outStrides[3]])
+				}
+			}
+		}
+	}
+	return floatsValue(y, outShape, dt), nil
+}
+
+func (op layoutOp) returnsPtr() bool    { return false }
+func (op layoutOp) callsExtern() bool   { return false }
+func (op layoutOp) overwriteInput() int { return -1 }
+
+func (op layoutOp) WriteHash(h hash.Hash) {
+	h.Write([]byte("layoutOp"))
+	h.Write([]byte{byte(op.from), byte(op.to)})
+}
+
+func (op layoutOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+func (op layoutOp) MarshalBinary() ([]byte, error) {
+	w := newParamWriter()
+	w.writeByte(byte(op.from))
+	w.writeByte(byte(op.to))
+	return w.bytes()
+}
+
+func (op *layoutOp) UnmarshalBinary(data []byte) error {
+	r := newParamReader(data)
+	op.from = Layout(r.readByte())
+	op.to = Layout(r.readByte())
+	if r.err == nil {
+		_, r.err = op.from.permutation(op.to)
+	}
+	return r.done()
+}
+
+func (op layoutOp) String() string { return fmt.Sprintf("%v→%v", op.from, op.to) }
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"testing"
 
+	tf32 "github.com/chewxy/gorgonia/tensor/f32"
 	tf64 "github.com/chewxy/gorgonia/tensor/f64"
+	ti "github.com/chewxy/gorgonia/tensor/i"
 	"github.com/chewxy/gorgonia/tensor/types"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -293,7 +295,7 @@ func TestSliceOp(t *testing.T) {
 		t.Error(err)
 	}
 
-	assert.Equal(types.Shape{2}, shape)
+	assert.Equal(scalarShape, shape)
 
 	if v, err = slice.Do(TT); err != nil {
 		t.Fatal(err)
@@ -422,3 +424,904 @@ func TestTransposeOp(t *testing.T) {
 
 	assert.Equal(types.Shape{3, 2}, AT.shape)
 }
+
+func TestTransposeLayout(t *testing.T) {
+	assert := assert.New(t)
+
+	// nchw and nhwc are the flat indices of the element at (n, c, h, w) of a (2, 3, 4, 5) tensor laid out as NCHW and NHWC
+	const N, C, H, W = 2, 3, 4, 5
+	nchw := func(n, c, h, w int) int { return ((n*C+c)*H+h)*W + w }
+	nhwc := func(n, c, h, w int) int { return ((n*H+h)*W+w)*C + c }
+
+	cases := []struct {
+		from, to Layout
+		shape    types.Shape
+		correct  types.Shape
+		src, dst func(n, c, h, w int) int
+	}{
+		{NCHW, NHWC, types.Shape{N, C, H, W}, types.Shape{N, H, W, C}, nchw, nhwc},
+		{NHWC, NCHW, types.Shape{N, H, W, C}, types.Shape{N, C, H, W}, nhwc, nchw},
+	}
+
+	for _, c := range cases {
+		xs := tf64.RangeFloat64(0, N*C*H*W)
+		ws := tf64.RangeFloat64(0, N*C*H*W)
+		for i := range ws {
+			ws[i] = float64(i%7) - 3
+		}
+
+		// the output puts the element at src(...) of the input at dst(...), and the gradient does the reverse
+		correct := make([]float64, len(xs))
+		correctDX := make([]float64, len(xs))
+		for n := 0; n < N; n++ {
+			for ch := 0; ch < C; ch++ {
+				for h := 0; h < H; h++ {
+					for w := 0; w < W; w++ {
+						correct[c.dst(n, ch, h, w)] = xs[c.src(n, ch, h, w)]
+						correctDX[c.src(n, ch, h, w)] = ws[c.dst(n, ch, h, w)]
+					}
+				}
+			}
+		}
+
+		for _, useTape := range []bool{true, false} {
+			g := NewGraph()
+			x := NewTensor(g, Float64, 4, WithName("x"), WithShape(c.shape...), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(c.shape...))))
+			w := NewTensor(g, Float64, 4, WithName("w"), WithShape(c.correct...), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(c.correct...))))
+
+			y, err := TransposeLayout(x, c.from, c.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(c.correct, y.Shape())
+
+			// transposing back gives x again
+			back, err := TransposeLayout(y, c.to, c.from)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(c.shape, back.Shape())
+			var yv, backV Value
+			Read(y, &yv)
+			Read(back, &backV)
+
+			cost := Must(Sum(Must(HadamardProd(y, w))))
+			if useTape {
+				if _, err = Grad(cost, x); err != nil {
+					t.Fatal(err)
+				}
+				prog, locMap, err := Compile(g)
+				if err != nil {
+					t.Fatal(err)
+				}
+				m := NewTapeMachine(prog, locMap)
+				if err = m.RunAll(); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				m := NewLispMachine(g)
+				if err = m.RunAll(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			assert.Equal(correct, extractF64s(yv), "%v to %v Tape %t", c.from, c.to, useTape)
+			assert.Equal(xs, extractF64s(backV), "%v to %v and back Tape %t", c.from, c.to, useTape)
+
+			dx, err := x.Grad()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(correctDX, extractF64s(dx), "%v to %v Tape %t", c.from, c.to, useTape)
+		}
+	}
+
+	g := NewGraph()
+	x := NewTensor(g, Float64, 4, WithName("x"), WithShape(N, C, H, W))
+	same, err := TransposeLayout(x, NCHW, NCHW)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(same == x, "Expected transposing to the same layout to do nothing")
+
+	m := NewMatrix(g, Float64, WithName("m"), WithShape(2, 3))
+	if _, err = TransposeLayout(m, NCHW, NHWC); err == nil {
+		t.Error("Expected an error with a matrix")
+	}
+}
+
+func TestShapeOf(t *testing.T) {
+	assert := assert.New(t)
+	g := NewGraph()
+
+	x := NewTensor(g, Float64, 3, WithName("x"), WithShape(2, 3, 4), WithInit(RangedFrom(0)))
+	s, err := ShapeOf(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(types.Shape{3}, s.Shape())
+	assert.Equal([]bool{false}, s.op.DiffWRT(1))
+
+	// column vectors are shaped (n, 1)
+	v := NewVector(g, Float64, WithName("v"), WithShape(5, 1), WithInit(RangedFrom(0)))
+	vs, err := ShapeOf(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(types.Shape{2}, vs.Shape())
+
+	prog, locMap, err := Compile(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewTapeMachine(prog, locMap)
+	if err = m.RunAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal([]int{2, 3, 4}, s.Value().(Tensor).Tensor.Data())
+	assert.Equal(Int, s.Value().Dtype())
+	assert.Equal([]int{5, 1}, vs.Value().(Tensor).Tensor.Data())
+
+	// scalars have no shape vector
+	_, err = ShapeOf(NewScalar(g, Float64))
+	assert.NotNil(err)
+}
+
+func TestReshapeOp(t *testing.T) {
+	assert := assert.New(t)
+	g := NewGraph()
+	A := NewMatrix(g, Float64, WithShape(2, 3), WithInit(RangedFrom(0)))
+	AT := Must(Transpose(A))
+	R := Must(Reshape(AT, types.Shape{2, 3}))
+	Must(Sum(R))
+
+	m := NewLispMachine(g)
+	if err := m.RunAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(types.Shape{2, 3}, R.shape)
+	assert.Equal([]float64{0, 3, 1, 4, 2, 5}, extractF64s(R.Value()))
+
+	// A must not have been touched
+	assert.Equal([]float64{0, 1, 2, 3, 4, 5}, extractF64s(A.Value()))
+	assert.Equal(types.Shape{2, 3}, A.Value().Shape())
+
+	// a vector reshaped into a column vector is reshaped, and is typed as the vector it still is
+	x := NewVector(g, Float64, WithShape(3))
+	col, err := Reshape(x, types.Shape{3, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(x != col)
+	assert.Equal(types.Shape{3, 1}, col.shape)
+	assert.Equal(1, col.Dims())
+
+	_, err = Reshape(A, types.Shape{4, 2})
+	assert.NotNil(err)
+}
+
+func TestAsColRowVec(t *testing.T) {
+	assert := assert.New(t)
+
+	xs := []float64{1, 2, 3}
+	ms := []float64{
+		1, 2, 3,
+		4, 5, 6,
+	}
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		x := NewVector(g, Float64, WithName("x"), WithShape(3), WithValue(tf64.NewTensor(tf64.WithBacking(xs), tf64.WithShape(3))))
+		m := NewMatrix(g, Float64, WithName("m"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(ms), tf64.WithShape(2, 3))))
+		w := NewVector(g, Float64, WithName("w"), WithShape(1, 3), WithValue(tf64.NewTensor(tf64.WithBacking([]float64{4, 5, 6}), tf64.WithShape(1, 3))))
+
+		col := Must(AsColVec(x))
+		row := Must(AsRowVec(x))
+		assert.Equal(types.Shape{3, 1}, col.Shape())
+		assert.Equal(types.Shape{1, 3}, row.Shape())
+		assert.True(col.IsColVec())
+		assert.True(row.IsRowVec())
+
+		// the gradient of Σ(m × col) + Σ(row ⊙ w) wrt x is the column sums of m plus w
+		mx := Must(Mul(m, col))
+		xw := Must(HadamardProd(row, w))
+		cost := Must(Add(Must(Sum(mx)), Must(Sum(xw))))
+
+		if useTape {
+			if _, err := Grad(cost, x); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err := m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.Equal([]float64{1, 2, 3}, extractF64s(col.Value()), "Tape %t", useTape)
+		assert.Equal(types.Shape{3, 1}, col.Value().Shape(), "Tape %t", useTape)
+		assert.Equal(types.Shape{1, 3}, row.Value().Shape(), "Tape %t", useTape)
+		assert.Equal([]float64{14, 32}, extractF64s(mx.Value()), "Tape %t", useTape)
+		assert.Equal([]float64{4, 10, 18}, extractF64s(xw.Value()), "Tape %t", useTape)
+
+		grad, err := x.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(types.Shape{3}, grad.Shape(), "Tape %t", useTape)
+		assert.Equal([]float64{9, 12, 15}, extractF64s(grad), "Tape %t", useTape)
+	}
+
+	// already a column vector
+	g := NewGraph()
+	c := NewVector(g, Float64, WithName("c"), WithShape(3, 1))
+	assert.Equal(c, Must(AsColVec(c)))
+
+	// not a vector
+	A := NewMatrix(g, Float64, WithName("A"), WithShape(2, 3))
+	if _, err := AsColVec(A); err == nil {
+		t.Error("Expected an error with a matrix")
+	}
+	if _, err := AsRowVec(A); err == nil {
+		t.Error("Expected an error with a matrix")
+	}
+}
+
+func TestSqueeze(t *testing.T) {
+	assert := assert.New(t)
+
+	xs := []float64{1, 2, 3}
+	ws := []float64{10, -1, 0.5}
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		x := NewTensor(g, Float64, 3, WithName("x"), WithShape(1, 3, 1), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(1, 3, 1))))
+		w := NewVector(g, Float64, WithName("w"), WithShape(3), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(3))))
+
+		sq, err := Squeeze(x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(types.Shape{3}, sq.Shape())
+
+		// and back again
+		unsq := Must(Unsqueeze(Must(Unsqueeze(sq, 0)), 2))
+		assert.Equal(types.Shape{1, 3, 1}, unsq.Shape())
+		var uv Value
+		Read(unsq, &uv)
+
+		cost := Must(Sum(Must(HadamardProd(sq, w))))
+		if useTape {
+			if _, err = Grad(cost, x); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.Equal(xs, extractF64s(sq.Value()), "Tape %t", useTape)
+		assert.Equal(types.Shape{1, 3, 1}, uv.Shape(), "Tape %t", useTape)
+		assert.Equal(xs, extractF64s(uv), "Tape %t", useTape)
+
+		dx, err := x.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(types.Shape{1, 3, 1}, dx.Shape(), "Tape %t", useTape)
+		assert.Equal(ws, extractF64s(dx), "Tape %t", useTape)
+	}
+
+	g := NewGraph()
+	x := NewTensor(g, Float64, 3, WithName("x"), WithShape(1, 3, 1))
+
+	// only the named axes are removed
+	sq, err := Squeeze(x, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(types.Shape{1, 3}, sq.Shape())
+	unsq, err := Unsqueeze(sq, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(types.Shape{1, 3, 1}, unsq.Shape())
+
+	if _, err = Squeeze(x, 1); err == nil {
+		t.Error("Expected an error squeezing an axis of size 3")
+	}
+	if _, err = Squeeze(x, 3); err == nil {
+		t.Error("Expected an error squeezing an axis that does not exist")
+	}
+	if _, err = Unsqueeze(x, 4); err == nil {
+		t.Error("Expected an error inserting an axis past the end")
+	}
+	one := NewMatrix(g, Float64, WithName("one"), WithShape(1, 1))
+	if _, err = Squeeze(one); err == nil {
+		t.Error("Expected an error squeezing a (1, 1) matrix down to a scalar")
+	}
+}
+
+func TestGather(t *testing.T) {
+	assert := assert.New(t)
+
+	xs := []float64{
+		0, 1, 2,
+		3, 4, 5,
+		6, 7, 8,
+	}
+	ws := []float64{
+		1, -2,
+		0.5, 3,
+		-1, 0.25,
+	}
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		x := NewMatrix(g, Float64, WithName("x"), WithShape(3, 3), WithValue(tf64.NewTensor(tf64.WithBacking(append([]float64{}, xs...)), tf64.WithShape(3, 3))))
+		w := NewMatrix(g, Float64, WithName("w"), WithShape(3, 2), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(3, 2))))
+		indices := NewVector(g, Int, WithName("indices"), WithShape(2), WithValue(ti.NewTensor(ti.WithBacking([]int{2, 0}), ti.WithShape(2))))
+
+		cols, err := Gather(x, 1, indices)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(types.Shape{3, 2}, cols.Shape())
+
+		cost := Must(Sum(Must(HadamardProd(cols, w))))
+		if useTape {
+			if _, err = Grad(cost, x); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.Equal([]float64{2, 0, 5, 3, 8, 6}, extractF64s(cols.Value()), "Tape %t", useTape)
+
+		// the gradient of Σ cols ⊙ w wrt x is w scattered back into the gathered columns
+		grad, err := x.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		correct := []float64{
+			-2, 0, 1,
+			3, 0, 0.5,
+			0.25, 0, -1,
+		}
+		assert.Equal(correct, extractF64s(grad), "Tape %t", useTape)
+	}
+
+	// repeated indices accumulate the gradient
+	op := gatherOp{axis: 0, d: 2}
+	x := FromTensor(tf64.NewTensor(tf64.WithBacking([]float64{1, 2, 3, 4}), tf64.WithShape(2, 2)))
+	idx := FromTensor(ti.NewTensor(ti.WithBacking([]int{1, 1, 0}), ti.WithShape(3)))
+	rows, err := op.Do(x, idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]float64{3, 4, 3, 4, 1, 2}, extractF64s(rows))
+
+	grad := FromTensor(tf64.NewTensor(tf64.WithBacking([]float64{1, 2, 3, 4, 5, 6}), tf64.WithShape(3, 2)))
+	dx, err := gatherDiffOp(op).Do(x, idx, grad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]float64{5, 6, 4, 6}, extractF64s(dx))
+
+	// f32, along the columns
+	op = gatherOp{axis: 1, d: 2}
+	x32 := FromTensor(tf32.NewTensor(tf32.WithBacking([]float32{1, 2, 3, 4}), tf32.WithShape(2, 2)))
+	oob := FromTensor(ti.NewTensor(ti.WithBacking([]int{0, 2}), ti.WithShape(2)))
+	if _, err = op.Do(x32, oob); err == nil {
+		t.Error("Expected an out of bounds error")
+	}
+
+	idx = FromTensor(ti.NewTensor(ti.WithBacking([]int{1}), ti.WithShape(1)))
+	if rows, err = op.Do(x32, idx); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]float32{2, 4}, rows.(Tensor).Tensor.Data())
+}
+
+func TestScatter(t *testing.T) {
+	assert := assert.New(t)
+
+	bs := []float64{
+		0, 1, 2,
+		3, 4, 5,
+		6, 7, 8,
+	}
+	us := []float64{
+		10, 11, 12,
+		13, 14, 15,
+	}
+	ws := []float64{
+		1, 2, 3,
+		4, 5, 6,
+		7, 8, 9,
+	}
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		base := NewMatrix(g, Float64, WithName("base"), WithShape(3, 3), WithValue(tf64.NewTensor(tf64.WithBacking(append([]float64{}, bs...)), tf64.WithShape(3, 3))))
+		updates := NewMatrix(g, Float64, WithName("updates"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(append([]float64{}, us...)), tf64.WithShape(2, 3))))
+		w := NewMatrix(g, Float64, WithName("w"), WithShape(3, 3), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(3, 3))))
+		indices := NewVector(g, Int, WithName("indices"), WithShape(2), WithValue(ti.NewTensor(ti.WithBacking([]int{2, 0}), ti.WithShape(2))))
+
+		scattered, err := Scatter(base, 0, indices, updates)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(types.Shape{3, 3}, scattered.Shape())
+
+		cost := Must(Sum(Must(HadamardProd(scattered, w))))
+		if useTape {
+			if _, err = Grad(cost, base, updates); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		correct := []float64{
+			13, 14, 15,
+			3, 4, 5,
+			10, 11, 12,
+		}
+		assert.Equal(correct, extractF64s(scattered.Value()), "Tape %t", useTape)
+		assert.Equal(bs, extractF64s(base.Value()), "Tape %t: base was clobbered", useTape)
+
+		// the rows that were overwritten get no gradient
+		dBase, err := base.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal([]float64{0, 0, 0, 4, 5, 6, 0, 0, 0}, extractF64s(dBase), "Tape %t", useTape)
+
+		// the updates get the gradient of the rows they were written into
+		dUpdates, err := updates.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal([]float64{7, 8, 9, 1, 2, 3}, extractF64s(dUpdates), "Tape %t", useTape)
+	}
+
+	// the shape of the updates must match
+	g := NewGraph()
+	base := NewMatrix(g, Float64, WithName("base"), WithShape(3, 3))
+	updates := NewMatrix(g, Float64, WithName("updates"), WithShape(3, 2))
+	indices := NewVector(g, Int, WithName("indices"), WithShape(2))
+	_, err := Scatter(base, 0, indices, updates)
+	assert.NotNil(err)
+}
+
+func TestBlockDiag(t *testing.T) {
+	assert := assert.New(t)
+
+	as := []float64{1, 2, 3, 4}
+	bs := []float64{5, 6, 7, 8}
+	ws := []float64{
+		1, 2, 3, 4,
+		5, 6, 7, 8,
+		9, 10, 11, 12,
+		13, 14, 15, 16,
+	}
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		a := NewMatrix(g, Float64, WithName("a"), WithShape(2, 2), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(as)), tf64.WithShape(2, 2))))
+		b := NewMatrix(g, Float64, WithName("b"), WithShape(2, 2), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(bs)), tf64.WithShape(2, 2))))
+		w := NewMatrix(g, Float64, WithName("w"), WithShape(4, 4), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(4, 4))))
+
+		bd, err := BlockDiag(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(types.Shape{4, 4}, bd.Shape())
+
+		cost := Must(Sum(Must(HadamardProd(bd, w))))
+		if useTape {
+			if _, err = Grad(cost, a, b); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		correct := []float64{
+			1, 2, 0, 0,
+			3, 4, 0, 0,
+			0, 0, 5, 6,
+			0, 0, 7, 8,
+		}
+		assert.Equal(correct, extractF64s(bd.Value()), "Tape %t", useTape)
+
+		// each block gets the gradient at its place on the diagonal
+		da, err := a.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal([]float64{1, 2, 5, 6}, extractF64s(da), "Tape %t", useTape)
+
+		db, err := b.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal([]float64{11, 12, 15, 16}, extractF64s(db), "Tape %t", useTape)
+	}
+
+	// blocks don't have to be square
+	op := blockDiagOp{n: 2}
+	row := FromTensor(tf32.NewTensor(tf32.WithBacking([]float32{1, 2}), tf32.WithShape(1, 2)))
+	col := FromTensor(tf32.NewTensor(tf32.WithBacking([]float32{3, 4}), tf32.WithShape(2, 1)))
+	v, err := op.Do(row, col)
+	if err != nil {
+		t.Fatal(err)
+	}
+	correct := []float32{
+		1, 2, 0,
+		0, 0, 3,
+		0, 0, 4,
+	}
+	assert.Equal(types.Shape{3, 3}, v.Shape())
+	assert.Equal(correct, v.(Tensor).Tensor.(*tf32.Tensor).Data())
+
+	g := NewGraph()
+	vec := NewVector(g, Float64, WithName("vec"), WithShape(2))
+	_, err = BlockDiag(vec)
+	assert.NotNil(err)
+	_, err = BlockDiag()
+	assert.NotNil(err)
+}
+
+func TestSymmetrize(t *testing.T) {
+	assert := assert.New(t)
+
+	as := []float64{
+		1, 2, 3,
+		4, 5, 6,
+		7, 8, 9,
+	}
+	ws := []float64{
+		1, 0, 4,
+		2, 1, 0,
+		0, 6, 1,
+	}
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		a := NewMatrix(g, Float64, WithName("a"), WithShape(3, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(as)), tf64.WithShape(3, 3))))
+		w := NewMatrix(g, Float64, WithName("w"), WithShape(3, 3), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(3, 3))))
+
+		sym, err := Symmetrize(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cost := Must(Sum(Must(HadamardProd(sym, w))))
+		if useTape {
+			if _, err = Grad(cost, a); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		correct := []float64{
+			1, 3, 5,
+			3, 5, 7,
+			5, 7, 9,
+		}
+		assert.Equal(correct, extractF64s(sym.Value()), "Tape %t", useTape)
+
+		// the gradient is (w + wᵀ)/2, which is symmetric even though w is not
+		correctDA := []float64{
+			1, 1, 2,
+			1, 1, 3,
+			2, 3, 1,
+		}
+		da, err := a.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(correctDA, extractF64s(da), "Tape %t", useTape)
+	}
+
+	g := NewGraph()
+	rect := NewMatrix(g, Float64, WithName("rect"), WithShape(2, 3))
+	_, err := Symmetrize(rect)
+	assert.NotNil(err)
+	vec := NewVector(g, Float64, WithName("vec"), WithShape(3))
+	_, err = Symmetrize(vec)
+	assert.NotNil(err)
+}
+
+func TestReverse(t *testing.T) {
+	assert := assert.New(t)
+
+	testCases := []struct {
+		shape   types.Shape
+		axis    int
+		xs, ws  []float64
+		correct []float64
+		dx      []float64 // w reversed along the axis
+	}{
+		{types.Shape{4}, 0, []float64{1, 2, 3, 4}, []float64{1, -1, 2, 0.5}, []float64{4, 3, 2, 1}, []float64{0.5, 2, -1, 1}},
+		{types.Shape{2, 3}, 0, []float64{1, 2, 3, 4, 5, 6}, []float64{1, 0, 2, -1, 3, 0.5}, []float64{4, 5, 6, 1, 2, 3}, []float64{-1, 3, 0.5, 1, 0, 2}},
+		{types.Shape{2, 3}, 1, []float64{1, 2, 3, 4, 5, 6}, []float64{1, 0, 2, -1, 3, 0.5}, []float64{3, 2, 1, 6, 5, 4}, []float64{2, 0, 1, 0.5, 3, -1}},
+	}
+
+	for _, tc := range testCases {
+		for _, useTape := range []bool{true, false} {
+			g := NewGraph()
+			x := NewTensor(g, Float64, len(tc.shape), WithName("x"), WithShape(tc.shape...), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(tc.xs)), tf64.WithShape(tc.shape...))))
+			w := NewTensor(g, Float64, len(tc.shape), WithName("w"), WithShape(tc.shape...), WithValue(tf64.NewTensor(tf64.WithBacking(tc.ws), tf64.WithShape(tc.shape...))))
+
+			rev, err := Reverse(x, tc.axis)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(tc.shape, rev.Shape())
+
+			var revV Value
+			Read(rev, &revV)
+
+			cost := Must(Sum(Must(HadamardProd(rev, w))))
+			if useTape {
+				if _, err = Grad(cost, x); err != nil {
+					t.Fatal(err)
+				}
+				prog, locMap, err := Compile(g)
+				if err != nil {
+					t.Fatal(err)
+				}
+				m := NewTapeMachine(prog, locMap)
+				if err = m.RunAll(); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				m := NewLispMachine(g)
+				if err = m.RunAll(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			assert.Equal(tc.correct, extractF64s(revV), "%v along %d, Tape %t", tc.shape, tc.axis, useTape)
+
+			dx, err := x.Grad()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(tc.dx, extractF64s(dx), "%v along %d, Tape %t", tc.shape, tc.axis, useTape)
+		}
+	}
+
+	// float32, and reversing twice gives back the input
+	x32 := FromTensor(tf32.NewTensor(tf32.WithBacking([]float32{1, 2, 3, 4, 5, 6}), tf32.WithShape(2, 3)))
+	op := reverseOp{axis: 1, d: 2}
+	once, err := op.Do(x32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]float32{3, 2, 1, 6, 5, 4}, once.Data())
+	twice, err := op.Do(once)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(x32.Data(), twice.Data())
+
+	g := NewGraph()
+	vec := NewVector(g, Float64, WithName("vec"), WithShape(3))
+	_, err = Reverse(vec, 1)
+	assert.NotNil(err)
+	s := NewScalar(g, Float64, WithName("s"))
+	_, err = Reverse(s, 0)
+	assert.NotNil(err)
+}
+
+func TestRoll(t *testing.T) {
+	assert := assert.New(t)
+
+	xs := []float64{1, 2, 3, 4, 5}
+	ws := []float64{1, -1, 2, 0.5, 3}
+	testCases := []struct {
+		shift   int
+		correct []float64
+		dx      []float64 // w rolled by -shift
+	}{
+		{2, []float64{4, 5, 1, 2, 3}, []float64{2, 0.5, 3, 1, -1}},
+		{-2, []float64{3, 4, 5, 1, 2}, []float64{0.5, 3, 1, -1, 2}},
+		{7, []float64{4, 5, 1, 2, 3}, []float64{2, 0.5, 3, 1, -1}},
+		{-12, []float64{3, 4, 5, 1, 2}, []float64{0.5, 3, 1, -1, 2}},
+		{5, xs, ws},
+	}
+
+	for _, tc := range testCases {
+		for _, useTape := range []bool{true, false} {
+			g := NewGraph()
+			x := NewVector(g, Float64, WithName("x"), WithShape(5), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(5))))
+			w := NewVector(g, Float64, WithName("w"), WithShape(5), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(ws)), tf64.WithShape(5))))
+
+			rolled, err := Roll(x, tc.shift, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(types.Shape{5}, rolled.Shape())
+
+			var rolledV Value
+			Read(rolled, &rolledV)
+
+			cost := Must(Sum(Must(HadamardProd(rolled, w))))
+			if useTape {
+				if _, err = Grad(cost, x); err != nil {
+					t.Fatal(err)
+				}
+				prog, locMap, err := Compile(g)
+				if err != nil {
+					t.Fatal(err)
+				}
+				m := NewTapeMachine(prog, locMap)
+				if err = m.RunAll(); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				m := NewLispMachine(g)
+				if err = m.RunAll(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			assert.Equal(tc.correct, extractF64s(rolledV), "Shift %d, Tape %t", tc.shift, useTape)
+
+			dx, err := x.Grad()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(tc.dx, extractF64s(dx), "Shift %d, Tape %t", tc.shift, useTape)
+		}
+	}
+
+	// float32 matrix, rolled along each axis
+	x32 := FromTensor(tf32.NewTensor(tf32.WithBacking([]float32{1, 2, 3, 4, 5, 6}), tf32.WithShape(2, 3)))
+	rows, err := rollOp{shift: 1, axis: 0, d: 2}.Do(x32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]float32{4, 5, 6, 1, 2, 3}, rows.Data())
+	cols, err := rollOp{shift: -1, axis: 1, d: 2}.Do(x32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]float32{2, 3, 1, 5, 6, 4}, cols.Data())
+
+	g := NewGraph()
+	vec := NewVector(g, Float64, WithName("vec"), WithShape(3))
+	_, err = Roll(vec, 1, 1)
+	assert.NotNil(err)
+}
+
+func TestSizeOf(t *testing.T) {
+	assert := assert.New(t)
+
+	// the shape is known: the size is folded into a constant of the same Dtype
+	g := NewGraph()
+	x := NewMatrix(g, Float64, WithName("x"), WithShape(3, 4))
+	s, err := SizeOf(1, x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(s.isConstant())
+	assert.True(g.AllNodes().Contains(s))
+	assert.Equal(4.0, s.Value().Data())
+
+	x32 := NewMatrix(g, Float32, WithName("x32"), WithShape(3, 4))
+	if s, err = SizeOf(0, x32); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(s.isConstant())
+	assert.Equal(float32(3), s.Value().Data())
+
+	sc := NewScalar(g, Float64, WithName("sc"))
+	if s, err = SizeOf(0, sc); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(1.0, s.Value().Data())
+
+	if _, err = SizeOf(2, x); err == nil {
+		t.Error("Expected an error with an axis that is out of range")
+	}
+
+	// the shape is unknown: the size is read off the value at runtime
+	g = NewGraph()
+	y := NewMatrix(g, Float64, WithName("y"))
+	if s, err = SizeOf(1, y); err != nil {
+		t.Fatal(err)
+	}
+	assert.False(s.isConstant())
+	assert.IsType(sizeOp{}, s.op)
+
+	Let(y, tf64.NewTensor(tf64.WithShape(2, 5)))
+	m := NewLispMachine(g, ExecuteFwdOnly())
+	if err = m.RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(5.0, s.Value().Data())
+
+	// folded sizes still tell repeats how large they are
+	g = NewGraph()
+	v := NewVector(g, Float64, WithName("v"), WithShape(3, 1), WithInit(RangedFrom(0)))
+	w := NewMatrix(g, Float64, WithName("w"), WithShape(3, 4), WithInit(RangedFrom(0)))
+	sum, err := Broadcast(addOpType, v, w, NewBroadcastPattern([]byte{1}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(types.Shape{3, 4}, sum.Shape())
+	assert.True(sum.children[0].children[1].isConstant())
+	assert.Equal(types.Shape{3, 4}, sum.children[0].Shape())
+}
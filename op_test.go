@@ -49,3 +49,115 @@ func TestOpEquality(t *testing.T) {
 		t.Error("oops")
 	}
 }
+
+// checkHashCollisions checks that ops which are meant to be different all have different Hashcode()s, and reports the ones that collide.
+func checkHashCollisions(t *testing.T, ops ...Op) {
+	seen := make(map[uint32]Op)
+	for _, op := range ops {
+		hash := op.Hashcode()
+		if other, ok := seen[hash]; ok {
+			t.Errorf("%v (%#v) and %v (%#v) have the same hash %x", other, other, op, op, hash)
+			continue
+		}
+		seen[hash] = op
+	}
+}
+
+func TestOpHashCollisions(t *testing.T) {
+	add := scalarBinOp{ʘBinaryOperatorType: addOpType, t: Float64}
+	tadd := tBinOp{ʘBinaryOperatorType: addOpType, tensorLeft: true}
+
+	// each pair of types prints the same, but is structurally different
+	pairs := [][2]Type{
+		{Float64, newTypeVariable("Float64")},
+		{newTensorType(1, Float64), newTypeVariable("Vector Float64")},
+		{newTensorType(2, newTypeVariable("a")), newTypeVariable("Matrix a")},
+		{newTensorType(3, Float32), newTensorType(3, newTypeVariable("Float32"))},
+	}
+
+	for _, pair := range pairs {
+		if pair[0].String() != pair[1].String() {
+			t.Fatalf("Expected %v and %v to print the same", pair[0], pair[1])
+		}
+
+		var binOp ʘBinaryOperator = add
+		if _, ok := pair[0].(*TensorType); ok {
+			binOp = tadd
+		}
+		checkHashCollisions(t,
+			elemBinOp{ʘBinaryOperator: binOp, arg0: pair[0], arg1: pair[0]},
+			elemBinOp{ʘBinaryOperator: binOp, arg0: pair[1], arg1: pair[1]},
+		)
+	}
+
+	// the same string spread differently over the two arguments
+	checkHashCollisions(t,
+		elemBinOp{ʘBinaryOperator: add, arg0: newTypeVariable("a,"), arg1: newTypeVariable("b")},
+		elemBinOp{ʘBinaryOperator: add, arg0: newTypeVariable("a"), arg1: newTypeVariable(",b")},
+	)
+
+	// and the ops that are the same still hash the same
+	a := elemBinOp{ʘBinaryOperator: tadd, arg0: newTensorType(2, Float64), arg1: Float64}
+	b := elemBinOp{ʘBinaryOperator: tadd, arg0: newTensorType(2, Float64), arg1: Float64}
+	if a.Hashcode() != b.Hashcode() {
+		t.Errorf("Expected %v and %v to have the same hash", a, b)
+	}
+}
+
+func TestCommutativeOpHash(t *testing.T) {
+	g := NewGraph()
+	a := NewMatrix(g, Float64, WithName("a"), WithShape(2, 2))
+	b := NewMatrix(g, Float64, WithName("b"), WithShape(2, 2))
+	s := NewScalar(g, Float64, WithName("s"))
+
+	for _, ot := range []ʘBinaryOperatorType{addOpType, mulOpType, eqOpType, neOpType} {
+		// the ops
+		op1 := newElemBinOp(ot, a, s)
+		op2 := newElemBinOp(ot, s, a)
+		if op1.Hashcode() != op2.Hashcode() {
+			t.Errorf("Expected %v of a matrix and a scalar to hash the same either way round", ot)
+		}
+
+		// the nodes
+		ab, err := applyOp(newElemBinOp(ot, a, b), a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ba, err := applyOp(newElemBinOp(ot, b, a), b, a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ab.Hashcode() != ba.Hashcode() {
+			t.Errorf("Expected a %v b and b %v a to hash the same", ot, ot)
+		}
+		if ab != ba {
+			t.Errorf("Expected a %v b and b %v a to be the same node", ot, ot)
+		}
+	}
+
+	for _, ot := range []ʘBinaryOperatorType{subOpType, divOpType, ltOpType, gteOpType} {
+		op1 := newElemBinOp(ot, a, s)
+		op2 := newElemBinOp(ot, s, a)
+		if op1.Hashcode() == op2.Hashcode() {
+			t.Errorf("Expected %v of a matrix and a scalar to hash differently when swapped", ot)
+		}
+
+		ab, err := applyOp(newElemBinOp(ot, a, b), a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ba, err := applyOp(newElemBinOp(ot, b, a), b, a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ab.Hashcode() == ba.Hashcode() {
+			t.Errorf("Expected a %v b and b %v a to hash differently", ot, ot)
+		}
+	}
+
+	// a + b and b + a are computed once
+	c := Must(Add(Must(Add(a, b)), Must(Add(b, a))))
+	if c.children[0] != c.children[1] {
+		t.Error("Expected a + b and b + a to be merged")
+	}
+}
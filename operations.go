@@ -2,6 +2,7 @@ package gorgonia
 
 import (
 	"fmt"
+	"math"
 
 	"github.com/chewxy/gorgonia/tensor/types"
 	"github.com/pkg/errors"
@@ -100,6 +101,62 @@ func Add(a, b *Node) (retVal *Node, err error) {
 	return binOpNode(op, a, b)
 }
 
+// AddScalar adds s to every element of the tensor a. It computes the same thing as Add(a, NewConstant(s)), but on a fast path:
+// the tensor is not copied unless it is a view, and it is added to in place when the tape machine finds that a can be overwritten.
+func AddScalar(a *Node, s float64) (retVal *Node, err error) {
+	if a.IsScalar() {
+		return nil, errors.Errorf("Expected a Tensor. Got a scalar %v instead", a)
+	}
+
+	var dt Dtype
+	if dt, err = dtypeOf(a.t); err != nil {
+		return nil, errors.Wrap(err, dtypeOfFail)
+	}
+
+	var c *Node
+	switch dt {
+	case Float64:
+		c = NewConstant(s)
+	case Float32:
+		c = NewConstant(float32(s))
+	default:
+		return nil, errors.Errorf(nyiFail, "AddScalar", dt)
+	}
+	if a.g != nil {
+		c = a.g.AddNode(c)
+	}
+	return applyOp(addScalarOp{d: a.Dims()}, a, c)
+}
+
+// AddEps adds a small constant eps to a, to keep it away from 0 before it is divided by, square rooted or logged.
+// The gradient passes through to a unchanged. Tensors are added to on the fast path of AddScalar.
+//
+// The recommended way to normalize by a standard deviation, as layer and batch normalization do in other frameworks, is
+//		std := Must(Sqrt(Must(AddEps(variance, 1e-5))))
+// or Rsqrt(AddEps(variance, eps)) to get the reciprocal in one go. Keeping eps out of the reductions themselves means that
+// Mean and Sum compute exactly what they say, and that the epsilon is spelt out where it matters.
+func AddEps(a *Node, eps float64) (retVal *Node, err error) {
+	if !a.IsScalar() {
+		return AddScalar(a, eps)
+	}
+
+	var dt Dtype
+	if dt, err = dtypeOf(a.t); err != nil {
+		return nil, errors.Wrap(err, dtypeOfFail)
+	}
+
+	var c *Node
+	switch dt {
+	case Float64:
+		c = NewConstant(eps)
+	case Float32:
+		c = NewConstant(float32(eps))
+	default:
+		return nil, errors.Errorf(nyiFail, "AddEps", dt)
+	}
+	return Add(a, c)
+}
+
 // Sub: pointwise a - b
 func Sub(a, b *Node) (retVal *Node, err error) {
 	op := newElemBinOp(subOpType, a, b)
@@ -119,30 +176,31 @@ func HadamardProd(a, b *Node) (retVal *Node, err error) {
 // If only one of the nodes is a vector, then the operator used will be a matrix-vector multiplication will be used, and most importantly,
 // a transpose will be used (when necessary)
 // If both nodes are matrices, then well, matrix multiplication will be done
-func Mul(a, b *Node) (retVal *Node, err error) {
+// The opts, such as WithHighPrecisionAccum, are applied to the multiplication. They are ignored when the multiplication is redirected to HadamardMul()
+func Mul(a, b *Node, opts ...LinAlgOpt) (retVal *Node, err error) {
 	if a.IsScalar() || b.IsScalar() {
 		return HadamardProd(a, b)
 	}
 
-	var op BinaryOp
+	var op linAlgBinOp
 	switch {
 	case a.IsVector() && b.IsVector():
 		op = linAlgBinOp{āBinaryOperator: vecDotOperator}
-		return binOpNode(op, a, b)
 	case a.IsVector() && b.IsMatrix():
 		op = linAlgBinOp{āBinaryOperator: matVecMulOperator, transA: true}
-		return binOpNode(op, b, a)
+		a, b = b, a
 	case a.IsMatrix() && b.IsVector():
 		op = linAlgBinOp{āBinaryOperator: matVecMulOperator}
-		return binOpNode(op, a, b)
 	case a.IsMatrix() && b.IsMatrix():
 		op = linAlgBinOp{āBinaryOperator: matMulOperator}
-		return binOpNode(op, a, b)
 	default:
 		return nil, errors.Errorf(nyiFail, "Mul", fmt.Sprintf("a %v b %v", a.shape, b.shape))
 	}
-	panic("unreachable")
 
+	for _, opt := range opts {
+		opt(&op)
+	}
+	return binOpNode(op, a, b)
 }
 
 func OuterProd(a, b *Node) (retVal *Node, err error) {
@@ -155,12 +213,122 @@ func OuterProd(a, b *Node) (retVal *Node, err error) {
 	return binOpNode(op, a, b)
 }
 
+// OuterSum adds every element of a, a (m) vector, to every element of b, a (n) vector, into a (m, n) matrix where z[i, j] = a[i] + b[j].
+// It is a + b with a broadcast along the columns and b along the rows, as used by additive attention, but without materializing either.
+// The gradient of a is the gradient summed along axis 1, and that of b is the gradient summed along axis 0.
+func OuterSum(a, b *Node) (retVal *Node, err error) {
+	if !a.IsVector() || !b.IsVector() {
+		return nil, errors.Errorf("Expected only vectors to be able to do OuterSum. Got %v and %v instead", a.shape, b.shape)
+	}
+	return applyOp(outerSumOp{}, a, b)
+}
+
+// BatchMatVecMul multiplies the matrix a with a batch of vectors, stacked as the columns of vs: given a of shape (m, k) and vs of shape (k, b),
+// the result is of shape (m, b), where each column is a × the corresponding column of vs. All the vectors are multiplied in one matrix multiplication.
+func BatchMatVecMul(a, vs *Node) (retVal *Node, err error) {
+	if !a.IsMatrix() || !vs.IsMatrix() {
+		return nil, errors.Errorf("Expected a matrix and a batch of vectors to be able to do BatchMatVecMul. Got %v and %v instead", a.shape, vs.shape)
+	}
+
+	op := linAlgBinOp{āBinaryOperator: matVecMulOperator, batched: true}
+	return binOpNode(op, a, vs)
+}
+
+// BatchDot performs a row-wise dot product of two matrices of the same shape: given a and b of shape (batch, k), the result is a vector of shape (batch),
+// where each element is the dot product of the corresponding rows of a and b.
+func BatchDot(a, b *Node) (retVal *Node, err error) {
+	if !a.IsMatrix() || !b.IsMatrix() {
+		return nil, errors.Errorf("Expected only matrices to be able to do BatchDot. Got %v and %v instead", a.shape, b.shape)
+	}
+
+	op := linAlgBinOp{āBinaryOperator: batchedDotOperator}
+	return binOpNode(op, a, b)
+}
+
+// Cholesky returns the lower triangular factor L of a symmetric positive definite matrix a, such that a = L × Lᵀ.
+// Only the lower triangle of a is read. Running the op on a matrix that is not positive definite returns an error.
+func Cholesky(a *Node) (retVal *Node, err error) {
+	if !a.IsMatrix() {
+		return nil, errors.Errorf("Expected a matrix to be able to do Cholesky. Got %v instead", a.shape)
+	}
+	return applyOp(choleskyOp{}, a)
+}
+
+// MatPow raises a square matrix a to the p-th power, by repeated squaring. a⁰ is the identity matrix, which does not depend on a,
+// so MatPow is not differentiable when p is 0.
+func MatPow(a *Node, p int) (retVal *Node, err error) {
+	if !a.IsMatrix() || a.shape[0] != a.shape[1] {
+		return nil, errors.Errorf("Expected a square matrix to be able to do MatPow. Got %v instead", a.shape)
+	}
+	if p < 0 {
+		return nil, errors.Errorf("Expected a power that is not negative. Got %d instead", p)
+	}
+	return applyOp(matPowOp{p: p}, a)
+}
+
+// Det computes the determinant of a square matrix a from its LU decomposition. The gradient is gradZ × det × a⁻ᵀ, so a must be invertible
+// for Det to be differentiated.
+//
+// The LU decomposition is a node of its own. Det and MatInverse of the same matrix share it, so a graph that needs both decomposes a once.
+func Det(a *Node) (retVal *Node, err error) {
+	var lu *Node
+	if lu, err = luOf(a, "Det"); err != nil {
+		return
+	}
+	return applyOp(detOp{}, a, lu)
+}
+
+// MatInverse computes the inverse of a square matrix a from its LU decomposition, which it shares with Det. Unlike Inverse, which is
+// the elementwise 1/a, it is the matrix inverse. It is an error to invert a singular matrix. The gradient is -a⁻ᵀ × gradZ × a⁻ᵀ.
+func MatInverse(a *Node) (retVal *Node, err error) {
+	var lu *Node
+	if lu, err = luOf(a, "MatInverse"); err != nil {
+		return
+	}
+	return applyOp(matInverseOp{}, a, lu)
+}
+
+// LogDet computes log|det(a)| of a square matrix a from its LU decomposition, which it shares with Det and MatInverse. The log determinant
+// is summed up from the diagonal of the decomposition, without forming the determinant, so it does not overflow where log(Det(a)) would.
+// For a symmetric positive definite matrix, such as a covariance matrix, it is the log determinant. The gradient is gradZ × a⁻ᵀ,
+// so a must be invertible for LogDet to be differentiated.
+func LogDet(a *Node) (retVal *Node, err error) {
+	var lu *Node
+	if lu, err = luOf(a, "LogDet"); err != nil {
+		return
+	}
+	return applyOp(logDetOp{}, a, lu)
+}
+
+// luOf returns the node of the LU decomposition of a. Identical nodes are merged by the graph, so every call for the same a returns the same node.
+func luOf(a *Node, fn string) (retVal *Node, err error) {
+	if !a.IsMatrix() || a.shape[0] != a.shape[1] {
+		return nil, errors.Errorf("Expected a square matrix to be able to do %v. Got %v instead", fn, a.shape)
+	}
+	return applyOp(luOp{}, a)
+}
+
 // HadamardDiv: pointwise a / b
 func HadamardDiv(a, b *Node) (retVal *Node, err error) {
 	op := newElemBinOp(divOpType, a, b)
 	return binOpNode(op, a, b)
 }
 
+// SafeDiv divides a by b + eps, elementwise. The gradients are those of HadamardDiv with b + eps as the denominator, so they stay finite
+// where b is 0, instead of blowing up. It is meant for ratios and normalizations whose denominators are not negative, such as counts and norms:
+// a denominator near -eps is as unsafe as ever.
+func SafeDiv(a, b *Node, eps float64) (retVal *Node, err error) {
+	if eps < 0 {
+		return nil, errors.Errorf("Expected eps to not be negative. Got %v instead", eps)
+	}
+
+	var denom *Node
+	if denom, err = AddEps(b, eps); err != nil {
+		return nil, errors.Wrap(err, operationError)
+	}
+	return HadamardDiv(a, denom)
+}
+
 func Div(a, b *Node) (retVal *Node, err error) {
 	if a.IsScalar() || b.IsScalar() {
 		return HadamardDiv(a, b)
@@ -175,6 +343,75 @@ func Pow(a, b *Node) (retVal *Node, err error) {
 	return binOpNode(op, a, b)
 }
 
+// BroadcastPow raises every element of base to the power of the matching element of exp, which is broadcast to the shape of base following
+// the usual (NumPy) broadcasting rules. A (batch, channels) base may be raised to a (channels) exponent, say, as in parameterized activations
+// where each channel learns its own exponent.
+//
+// Both base and exp are differentiable. The gradient of exp is z × ln(base) × gradZ, summed back over the axes exp was broadcast along,
+// so base has to be positive wherever the gradient of exp is wanted.
+func BroadcastPow(base, exp *Node) (retVal *Node, err error) {
+	if base.IsScalar() || exp.IsScalar() {
+		return nil, errors.Errorf("Expected Tensors. Got %v and %v instead. Use Pow for scalars", base, exp)
+	}
+
+	var bexp *Node
+	if bexp, err = BroadcastTo(exp, base.shape); err != nil {
+		return nil, errors.Wrap(err, "Cannot broadcast the exponent to the base")
+	}
+	return Pow(base, bexp)
+}
+
+// PowConst raises every element of a to the integer power exp. Unlike Pow, which goes through math.Pow, it multiplies a by itself,
+// which is faster for small powers and exact for squares and cubes. The gradient is exp × a^(exp-1) × gradZ.
+func PowConst(a *Node, exp int) (retVal *Node, err error) {
+	return applyOp(powConstOp{exp: exp}, a)
+}
+
+// ApplyFn applies f, a Go function, to every element of n, without having to define an op for it. df is the derivative of f,
+// from which the gradient df(n) × gradZ is computed. df may be nil if the result is never differentiated, but then differentiating it is an error,
+// and so is running it on a LispMachine that does backpropagation.
+//
+// Every call creates a new op, as there is no way to tell whether two functions are the same.
+func ApplyFn(n *Node, f, df func(float64) float64) (retVal *Node, err error) {
+	if f == nil {
+		return nil, errors.New("Cannot apply a nil function")
+	}
+	return applyOp(newApplyFnOp(f, df), n)
+}
+
+// GreaterThan compares every element of a with threshold, and returns a mask of the same shape and Dtype as a, which is 1 where a > threshold
+// and 0 elsewhere. It is Gt with a scalar and retSame, in a single op that does not need a node for the threshold.
+//
+// A comparison has no gradient, so by default nothing is backpropagated through the mask. If straightThrough is set,
+// the gradient is passed straight through to a, as if the mask were a itself. This is the straight-through estimator used to train binarized units.
+func GreaterThan(a *Node, threshold float64, straightThrough bool) (retVal *Node, err error) {
+	return applyOp(thresholdOp{threshold: threshold, straightThrough: straightThrough}, a)
+}
+
+// FakeQuant simulates quantization for quantization aware training. Every element x of n is quantized to the integer
+// clip(round(x / scale) + zeroPoint, qmin, qmax), with halves rounded to even, and is then dequantized back to a float.
+// The gradient is the straight-through estimator: it is passed through where round(x / scale) + zeroPoint is within [qmin, qmax], and is 0 where it is clipped.
+//
+// scale must be positive, and zeroPoint must be within [qmin, qmax], so that 0 is exactly representable.
+func FakeQuant(n *Node, scale float64, zeroPoint, qmin, qmax int) (retVal *Node, err error) {
+	if !(scale > 0) || math.IsInf(scale, 1) {
+		return nil, errors.Errorf("Expected a positive, finite scale. Got %v instead", scale)
+	}
+	if qmin > qmax {
+		return nil, errors.Errorf("Expected qmin <= qmax. Got [%d, %d] instead", qmin, qmax)
+	}
+	if zeroPoint < qmin || zeroPoint > qmax {
+		return nil, errors.Errorf("Expected the zero point to be within [%d, %d]. Got %d instead", qmin, qmax, zeroPoint)
+	}
+	return applyOp(fakeQuantOp{scale: scale, zeroPoint: zeroPoint, qmin: qmin, qmax: qmax}, n)
+}
+
+// MaximumScalar computes max(x, c) of every element x of n. The gradient is passed through where x > c, and is 0 where x <= c.
+// MaximumScalar(n, 0) is the ReLU, which Relu provides.
+func MaximumScalar(n *Node, c float64) (retVal *Node, err error) {
+	return applyOp(maximumScalarOp{c: c}, n)
+}
+
 // Gt: pointwise a > b. retSame indicates if the return value should be the same type as the input values
 func Gt(a, b *Node, retSame bool) (retVal *Node, err error) {
 	op := newElemBinOp(gtOpType, a, b)
@@ -189,6 +426,37 @@ func Gte(a, b *Node, retSame bool) (retVal *Node, err error) {
 	return binOpNode(op, a, b)
 }
 
+// GtInt: pointwise a > b, returning an Int 1 where it holds and 0 where it does not, for use in index arithmetic.
+func GtInt(a, b *Node) (retVal *Node, err error) {
+	op := newElemBinOp(gtOpType, a, b).withIntResult()
+	return binOpNode(op, a, b)
+}
+
+// GteInt: pointwise a >= b, returning an Int 1 where it holds and 0 where it does not, for use in index arithmetic.
+func GteInt(a, b *Node) (retVal *Node, err error) {
+	op := newElemBinOp(gteOpType, a, b).withIntResult()
+	return binOpNode(op, a, b)
+}
+
+// And: pointwise logical a ∧ b. a and b may either be Bool or the numeric 1/0 representation.
+// In the latter case, any non-zero value is considered true, and the result is 1 for true and 0 for false.
+func And(a, b *Node) (retVal *Node, err error) {
+	op := newElemBinOp(andOpType, a, b)
+	return binOpNode(op, a, b)
+}
+
+// Or: pointwise logical a ∨ b. See And() for the accepted representations.
+func Or(a, b *Node) (retVal *Node, err error) {
+	op := newElemBinOp(orOpType, a, b)
+	return binOpNode(op, a, b)
+}
+
+// Xor: pointwise logical a ⊕ b. See And() for the accepted representations.
+func Xor(a, b *Node) (retVal *Node, err error) {
+	op := newElemBinOp(xorOpType, a, b)
+	return binOpNode(op, a, b)
+}
+
 /* UNARY STUFF */
 
 func unaryOpNode(op Op, a *Node) (retVal *Node, err error) {
@@ -271,6 +539,7 @@ func Log2(a *Node) (retVal *Node, err error) {
 	return unaryOpNode(op, a)
 }
 
+// Neg negates a, which may be a scalar or a tensor. The gradient flowing back to a is -gradZ.
 func Neg(a *Node) (retVal *Node, err error) {
 	op := newElemUnaryOp(negOpType, a)
 	return unaryOpNode(op, a)
@@ -291,6 +560,12 @@ func Inverse(a *Node) (retVal *Node, err error) {
 	return unaryOpNode(op, a)
 }
 
+// Rsqrt computes 1/√a pointwise in a single op, instead of a Sqrt followed by an Inverse.
+func Rsqrt(a *Node) (retVal *Node, err error) {
+	op := newElemUnaryOp(rsqrtOpType, a)
+	return unaryOpNode(op, a)
+}
+
 func Cube(a *Node) (retVal *Node, err error) {
 	op := newElemUnaryOp(cubeOpType, a)
 	return unaryOpNode(op, a)
@@ -301,6 +576,24 @@ func Sigmoid(a *Node) (retVal *Node, err error) {
 	return unaryOpNode(op, a)
 }
 
+// Logit computes log(p / (1-p)), the inverse of Sigmoid, of every element p of a. p is clamped to [1e-7, 1-1e-7] first,
+// so that 0s and 1s give large but finite logits and gradients. The gradient is gradZ / (p(1-p)).
+func Logit(a *Node) (retVal *Node, err error) {
+	return applyOp(logitOp{eps: logitEps}, a)
+}
+
+// HardSigmoid computes clip(0.2a + 0.5, 0, 1), a piecewise linear approximation of Sigmoid that is cheaper to compute.
+// The gradient is 0.2 × gradZ where 0.2a + 0.5 is strictly between 0 and 1, and 0 where it is clipped.
+func HardSigmoid(a *Node) (retVal *Node, err error) {
+	return applyOp(hardSigmoidOp{}, a)
+}
+
+// SignedSqrt computes sign(a) × √|a| pointwise, which squashes large values like Sqrt does but keeps their sign.
+// The gradient is gradZ / (2√|a|), with |a| taken to be at least 1e-12 so that it stays finite at 0.
+func SignedSqrt(a *Node) (retVal *Node, err error) {
+	return applyOp(signedSqrtOp{eps: signedSqrtEps}, a)
+}
+
 func Tanh(a *Node) (retVal *Node, err error) {
 	op := newElemUnaryOp(tanhOpType, a)
 	return unaryOpNode(op, a)
@@ -311,6 +604,13 @@ func Log1p(a *Node) (retVal *Node, err error) {
 	return unaryOpNode(op, a)
 }
 
+// Not: pointwise logical ¬a. a may either be Bool or the numeric 1/0 representation.
+// In the latter case, any non-zero value is considered true, and the result is 1 for true and 0 for false.
+func Not(a *Node) (retVal *Node, err error) {
+	op := newElemUnaryOp(notOpType, a)
+	return unaryOpNode(op, a)
+}
+
 // more complex unaries
 
 func SoftMax(a *Node) (retVal *Node, err error) {
@@ -382,7 +682,18 @@ func At(a *Node, coords ...int) (retVal *Node, err error) {
 	return applyOp(op, a)
 }
 
+// Max finds the max of a along the given axes, or of all of a if no axes are given. Negative axes count from the end, so -1 is the last axis.
+//
+// The gradient of each max goes to every element that is equal to it. Use MaxTies to send it only to the first of them.
 func Max(a *Node, along ...int) (retVal *Node, err error) {
+	return MaxTies(a, AllTies, along...)
+}
+
+// MaxTies is Max, with a choice of where the gradient of a max goes when several elements tie for it. See TieBreak.
+func MaxTies(a *Node, ties TieBreak, along ...int) (retVal *Node, err error) {
+	if ties != AllTies && ties != FirstTie {
+		return nil, errors.Errorf("Unknown TieBreak %d", ties)
+	}
 	if a.IsScalar() {
 		// can't max a scalar. Should return error
 		// err = NewError(TypeError, "Cannot Max a Scalar")
@@ -393,12 +704,132 @@ func Max(a *Node, along ...int) (retVal *Node, err error) {
 	if len(along) == 0 {
 		along = intRange(0, dims)
 	}
+	if along, err = normalizeAxes(along, len(a.shape)); err != nil {
+		return
+	}
 
-	op := newMaxOp(along, dims)
+	op := newMaxOp(along, dims, ties)
 
 	return applyOp(op, a)
 }
 
+// MaxWithArg finds both the max of a along the axis, and the index of the max along the axis, in a single pass over a.
+// Both have the shape of a with the axis removed (a vector is reduced to scalars), and the indices are Ints. Ties go to the lower index.
+//
+// Only the max values are differentiable: their gradient flows to the elements of a where the max values are.
+func MaxWithArg(a *Node, axis int) (values, indices *Node, err error) {
+	if a.IsScalar() {
+		return nil, nil, errors.Errorf("Cannot find the max of a scalar (%v) along an axis", a)
+	}
+	if axis < 0 {
+		axis += len(a.shape)
+	}
+
+	op := maxWithArgOp{along: axis, d: a.Dims()}
+	var packed *Node
+	if packed, err = applyOp(op, a); err != nil {
+		return nil, nil, errors.Wrap(err, applyOpFail)
+	}
+
+	if values, err = Slice(packed, S(0)); err != nil {
+		return nil, nil, errors.Wrapf(err, sliceFail, S(0))
+	}
+	if indices, err = applyOp(maxArgIndicesOp{d: packed.Dims()}, packed); err != nil {
+		return nil, nil, errors.Wrap(err, applyOpFail)
+	}
+	return
+}
+
+// Argmin finds the index of the min of n along the axis, which is removed. A negative axis counts from the end, and a vector is reduced to a scalar.
+// The indices are Ints, ties go to the lower index and NaNs are skipped. Argmin is not differentiable.
+func Argmin(n *Node, axis int) (retVal *Node, err error) {
+	if n.IsScalar() {
+		return nil, errors.Errorf("Cannot find the min of a scalar (%v) along an axis", n)
+	}
+
+	var along []int
+	if along, err = normalizeAxes([]int{axis}, len(n.shape)); err != nil {
+		return
+	}
+	return applyOp(argminOp{along: along[0], d: n.Dims()}, n)
+}
+
+// CumMax computes the running max of n along the axis: every element is the max of the elements up to and including it along the axis.
+// The result is shaped like n. A negative axis counts from the end. The gradient of every element goes to the element of n that is the running max there,
+// and ties go to the lower index.
+func CumMax(n *Node, axis int) (retVal *Node, err error) {
+	if n.IsScalar() {
+		return nil, errors.Errorf("Cannot compute the running max of a scalar (%v) along an axis", n)
+	}
+
+	var along []int
+	if along, err = normalizeAxes([]int{axis}, len(n.shape)); err != nil {
+		return
+	}
+	return applyOp(cumMaxOp{along: along[0], d: n.Dims()}, n)
+}
+
+// EMA computes the exponential moving average of n along the axis, y[t] = αx[t] + (1-α)y[t-1], starting from y[0] = x[0].
+// The result is shaped like n. A negative axis counts from the end. α must be within (0, 1]: the larger it is, the less the average is smoothed,
+// and at 1 it is n itself. The gradient is propagated backwards through the recurrence.
+func EMA(n *Node, axis int, alpha float64) (retVal *Node, err error) {
+	if n.IsScalar() {
+		return nil, errors.Errorf("Cannot compute the moving average of a scalar (%v) along an axis", n)
+	}
+	if !(alpha > 0 && alpha <= 1) {
+		return nil, errors.Errorf("Expected alpha to be within (0, 1]. Got %v instead", alpha)
+	}
+
+	var along []int
+	if along, err = normalizeAxes([]int{axis}, len(n.shape)); err != nil {
+		return
+	}
+	return applyOp(emaOp{along: along[0], d: n.Dims(), alpha: alpha}, n)
+}
+
+// Median finds the median of n along the axis, which is removed. A negative axis counts from the end, and a vector is reduced to a scalar.
+// Of an even number of elements, the median is the mean of the two in the middle. The gradient goes to the element in the middle,
+// or is split in halves between the two in the middle.
+func Median(n *Node, axis int) (retVal *Node, err error) {
+	if n.IsScalar() {
+		return nil, errors.Errorf("Cannot find the median of a scalar (%v) along an axis", n)
+	}
+
+	var along []int
+	if along, err = normalizeAxes([]int{axis}, len(n.shape)); err != nil {
+		return
+	}
+	return applyOp(medianOp{along: along[0], d: n.Dims()}, n)
+}
+
+// TopK finds the k largest values of a along the axis, and their indices along the axis, in a single pass over a.
+// Both have the shape of a with the axis shrunk to k, and are in descending order of the values. The indices are Ints. Ties go to the lower index.
+//
+// Only the values are differentiable: their gradient is scattered back to the elements of a that were selected.
+func TopK(a *Node, k int, axis int) (values, indices *Node, err error) {
+	if a.IsScalar() {
+		return nil, nil, errors.Errorf("Cannot select the top %d of a scalar (%v)", k, a)
+	}
+	if axis < 0 {
+		axis += len(a.shape)
+	}
+
+	op := topKOp{k: k, along: axis, d: a.Dims()}
+	var packed *Node
+	if packed, err = applyOp(op, a); err != nil {
+		return nil, nil, errors.Wrap(err, applyOpFail)
+	}
+
+	if values, err = Slice(packed, S(0)); err != nil {
+		return nil, nil, errors.Wrapf(err, sliceFail, S(0))
+	}
+	if indices, err = applyOp(maxArgIndicesOp{d: packed.Dims()}, packed); err != nil {
+		return nil, nil, errors.Wrap(err, applyOpFail)
+	}
+	return
+}
+
+// Mean averages a along the given axes, or all of a if no axes are given. Negative axes count from the end, so -1 is the last axis.
 func Mean(a *Node, along ...int) (retVal *Node, err error) {
 	if a.IsScalar() {
 		// can't mean a scalar... return error
@@ -412,6 +843,9 @@ func Mean(a *Node, along ...int) (retVal *Node, err error) {
 	if len(along) == 0 {
 		along = intRange(0, dims)
 	}
+	if along, err = normalizeAxes(along, len(a.shape)); err != nil {
+		return
+	}
 
 	var s *Node
 	if s, err = Sum(a, along...); err != nil {
@@ -434,6 +868,145 @@ func Mean(a *Node, along ...int) (retVal *Node, err error) {
 	return
 }
 
+// MeanSquare computes the mean of the squares of a along the given axes, or of all of a if no axes are given, in a single pass over a.
+// Negative axes count from the end. The axes are removed, so reducing every axis gives a scalar. The gradient is 2a/N × gradZ,
+// where N is the number of elements each mean is taken over.
+//
+// RMS normalization divides by the root of the mean square, which can be done with Rsqrt:
+//		rms := Must(Rsqrt(Must(AddEps(Must(MeanSquare(x, -1)), 1e-6))))
+func MeanSquare(a *Node, along ...int) (retVal *Node, err error) {
+	if a.IsScalar() {
+		return nil, errors.Errorf("Cannot find the mean square of a scalar (%v) along axes", a)
+	}
+
+	if len(along) == 0 {
+		along = intRange(0, a.Dims())
+	}
+	if along, err = normalizeAxes(along, len(a.shape)); err != nil {
+		return
+	}
+	return applyOp(meanSquareOp{along: along, d: a.Dims()}, a)
+}
+
+// NanMean computes the mean of a along the given axes, or of all of a if no axes are given, leaving out the NaNs, which is how missing values
+// are usually marked. Each mean is divided by the number of elements that are not NaNs. Negative axes count from the end.
+// The axes are removed, so reducing every axis gives a scalar.
+//
+// The gradient only goes to the elements that are not NaNs, each of which gets gradZ/count. If every element along the axes is a NaN,
+// the mean is 0 and none of them gets a gradient.
+func NanMean(a *Node, along ...int) (retVal *Node, err error) {
+	if a.IsScalar() {
+		return nil, errors.Errorf("Cannot find the mean of a scalar (%v) along axes", a)
+	}
+
+	if len(along) == 0 {
+		along = intRange(0, a.Dims())
+	}
+	if along, err = normalizeAxes(along, len(a.shape)); err != nil {
+		return
+	}
+	return applyOp(nanMeanOp{along: along, d: a.Dims()}, a)
+}
+
+// Prod multiplies the elements of a along the given axes, or all of a if no axes are given. Negative axes count from the end.
+// The axes are removed, so multiplying along every axis gives a scalar.
+//
+// The gradient does not divide by the elements, so it is well defined when some of them are 0: the gradient of a lone zero is the product
+// of the rest, and when there are two or more zeros along the axes every gradient is 0.
+func Prod(a *Node, along ...int) (retVal *Node, err error) {
+	if a.IsScalar() {
+		return nil, errors.Errorf("Cannot find the product of a scalar (%v) along axes", a)
+	}
+
+	if len(along) == 0 {
+		along = intRange(0, a.Dims())
+	}
+	if along, err = normalizeAxes(along, len(a.shape)); err != nil {
+		return
+	}
+	return applyOp(prodOp{along: along, d: a.Dims()}, a)
+}
+
+// CountNonzero counts the nonzero elements of a along the given axes, or all of a if no axes are given. Negative axes count from the end.
+// The axes are removed, so counting along every axis gives a scalar. The counts are Ints, and are not differentiable.
+//
+// Elements that are within a small tolerance of 0 (1e-12 for Float64, 1e-6 for Float32) count as zeros.
+func CountNonzero(a *Node, along ...int) (retVal *Node, err error) {
+	if a.IsScalar() {
+		return nil, errors.Errorf("Cannot count the nonzeros of a scalar (%v) along axes", a)
+	}
+
+	if len(along) == 0 {
+		along = intRange(0, a.Dims())
+	}
+	if along, err = normalizeAxes(along, len(a.shape)); err != nil {
+		return
+	}
+	return applyOp(countNonzeroOp{along: along, d: a.Dims()}, a)
+}
+
+// FindFirst returns the index of the first nonzero, or true, element of a along the axis, or -1 if every element is zero. Negative axes count from the end.
+// The axis is removed, and the indices are Ints, so finding the first true element of a vector gives an Int scalar.
+//
+// It is meant for masks, such as finding where a sequence ends, so it is not differentiable.
+func FindFirst(a *Node, axis int) (retVal *Node, err error) {
+	if a.IsScalar() {
+		return nil, errors.Errorf("Cannot find the first nonzero element of a scalar (%v)", a)
+	}
+
+	var along []int
+	if along, err = normalizeAxes([]int{axis}, len(a.shape)); err != nil {
+		return
+	}
+	return applyOp(findFirstOp{axis: along[0], d: a.Dims()}, a)
+}
+
+// ReduceAny reduces a tensor of bools, or of 0s and 1s, with a logical OR along the given axes, or all of them if no axes are given.
+// Negative axes count from the end. The result is of the same Dtype as a, so it is made of 0s and 1s if a is.
+// It is not differentiable.
+func ReduceAny(a *Node, along ...int) (retVal *Node, err error) {
+	return reduceLogical(a, false, along)
+}
+
+// ReduceAll reduces a tensor of bools, or of 0s and 1s, with a logical AND along the given axes, or all of them if no axes are given.
+// Negative axes count from the end. The result is of the same Dtype as a, so it is made of 0s and 1s if a is.
+// It is not differentiable.
+func ReduceAll(a *Node, along ...int) (retVal *Node, err error) {
+	return reduceLogical(a, true, along)
+}
+
+func reduceLogical(a *Node, all bool, along []int) (retVal *Node, err error) {
+	if a.IsScalar() {
+		return nil, errors.Errorf("Cannot reduce a scalar (%v) along axes", a)
+	}
+
+	if len(along) == 0 {
+		along = intRange(0, a.Dims())
+	}
+	if along, err = normalizeAxes(along, len(a.shape)); err != nil {
+		return
+	}
+	return applyOp(reduceLogicalOp{all: all, along: along, d: a.Dims()}, a)
+}
+
+// Histogram counts the elements of a in each of bins buckets of equal width, which span [min, max), and returns the counts as a (bins) vector of Ints.
+// Elements below min are counted in the first bucket, and elements at or above max in the last one, so every element that is not a NaN is counted.
+//
+// It is meant for inspecting activations while debugging, and is not differentiable.
+func Histogram(a *Node, bins int, min, max float64) (retVal *Node, err error) {
+	if a.IsScalar() {
+		return nil, errors.Errorf("Expected a Tensor. Got a scalar %v instead", a)
+	}
+	if bins < 1 {
+		return nil, errors.Errorf("Expected at least one bin. Got %d instead", bins)
+	}
+	if !(min < max) || math.IsInf(min, 0) || math.IsInf(max, 0) {
+		return nil, errors.Errorf("Expected a finite range with min < max. Got [%v, %v) instead", min, max)
+	}
+	return applyOp(histogramOp{bins: bins, min: min, max: max, d: a.Dims()}, a)
+}
+
+// Sum sums up a along the given axes, or all of a if no axes are given. Negative axes count from the end, so -1 is the last axis.
 func Sum(a *Node, along ...int) (retVal *Node, err error) {
 	if a.IsScalar() {
 		retVal = a // or error?
@@ -442,20 +1015,183 @@ func Sum(a *Node, along ...int) (retVal *Node, err error) {
 
 	dims := a.Dims()
 	if len(along) == 0 {
-		switch {
-		case a.IsRowVec():
-			along = []int{1}
-		case a.IsColVec(), a.IsVector():
-			along = []int{0}
-		default:
-			along = intRange(0, dims)
-		}
+		along = sumAllAxes(a)
+	}
+	if along, err = normalizeAxes(along, len(a.shape)); err != nil {
+		return
 	}
 
 	op := newSumOp(along, a.shape, dims)
 	return applyOp(op, a)
 }
 
+// MaskedSum sums up the elements of a, multiplied by the mask, along the given axes. Like Sum, it sums up everything if no axes are given,
+// and negative axes count from the end.
+// With a mask of 0s and 1s, only the elements where the mask is 1 are summed - the valid steps of a padded sequence, for instance.
+//
+// The mask must be shaped like a. The gradient flows back to a through the mask, but the mask itself is not differentiated.
+func MaskedSum(a, mask *Node, along ...int) (retVal *Node, err error) {
+	if a.IsScalar() {
+		return nil, errors.Errorf("Cannot take the masked sum of a scalar")
+	}
+	if !a.shape.Eq(mask.shape) {
+		return nil, errors.Errorf("Expected the mask to be shaped %v. Got %v instead", a.shape, mask.shape)
+	}
+
+	dims := a.Dims()
+	if len(along) == 0 {
+		along = sumAllAxes(a)
+	}
+	if along, err = normalizeAxes(along, len(a.shape)); err != nil {
+		return
+	}
+
+	op := newMaskedSumOp(along, a.shape, dims)
+	return applyOp(op, a, mask)
+}
+
+// sumAllAxes returns the axes to sum along in order to sum up all the elements of a
+func sumAllAxes(a *Node) []int {
+	switch {
+	case a.IsRowVec():
+		return []int{1}
+	case a.IsColVec(), a.IsVector():
+		return []int{0}
+	}
+	return intRange(0, a.Dims())
+}
+
+// LogSumExp computes log Σ exp(a) along the axis, without overflowing, by shifting a by its max first. The axis is removed, and a vector
+// is reduced to a scalar. Negative axes count from the end.
+//
+// The gradient is the softmax of a along the axis. It is computed from the output, which saves the backwards pass from finding the max and the sum again.
+func LogSumExp(a *Node, axis int) (retVal *Node, err error) {
+	if a.IsScalar() {
+		return nil, errors.Errorf("Cannot compute the log sum exp of a scalar (%v) along an axis", a)
+	}
+
+	var along []int
+	if along, err = normalizeAxes([]int{axis}, len(a.shape)); err != nil {
+		return
+	}
+	return applyOp(logSumExpOp{along: along[0], d: a.Dims()}, a)
+}
+
+// Fold reduces n along the axis with reducer, a Go function, as a left fold that starts from init: reducer(…reducer(init, x₀)…, xₙ₋₁).
+// It generalizes Sum, Prod and Max to reductions that have no op of their own. The axis is removed, and a vector is reduced to a scalar.
+// Negative axes count from the end.
+//
+// Fold is not differentiable. FoldWithGrad is, if the derivative of the reduction is known.
+func Fold(n *Node, axis int, reducer func(a, b float64) float64, init float64) (retVal *Node, err error) {
+	return FoldWithGrad(n, axis, reducer, init, nil)
+}
+
+// FoldWithGrad is Fold with df, the derivative of the output y wrt an element x of n, given x and y. For a sum of squares, df is 2x,
+// and for a product it is y/x. The gradient is df(x, y) × gradY. A nil df makes it the same as Fold.
+//
+// Every call creates a new op, as there is no way to tell whether two functions are the same.
+func FoldWithGrad(n *Node, axis int, reducer func(a, b float64) float64, init float64, df func(x, y float64) float64) (retVal *Node, err error) {
+	if reducer == nil {
+		return nil, errors.New("Cannot fold with a nil reducer")
+	}
+	if n.IsScalar() {
+		return nil, errors.Errorf("Cannot fold a scalar (%v) along an axis", n)
+	}
+
+	var along []int
+	if along, err = normalizeAxes([]int{axis}, len(n.shape)); err != nil {
+		return
+	}
+	return applyOp(newFoldOp(along[0], n.Dims(), reducer, init, df), n)
+}
+
+// CosineSimilarity computes the cosine similarity a·b / (‖a‖ ‖b‖) of a and b along the axis, which is removed. A negative axis counts from the end,
+// and vectors are reduced to a scalar. a and b must be of the same shape.
+//
+// It is a single op, whose gradient is computed in one go. Where either a or b is all zeros, the similarity is 0 and neither gets a gradient.
+func CosineSimilarity(a, b *Node, axis int) (retVal *Node, err error) {
+	if a.IsScalar() || b.IsScalar() {
+		return nil, errors.Errorf("Cannot compute the cosine similarity of scalars along an axis")
+	}
+	if !a.shape.Eq(b.shape) {
+		return nil, errors.Errorf("Expected a and b to be of the same shape. Got %v and %v instead", a.shape, b.shape)
+	}
+
+	var along []int
+	if along, err = normalizeAxes([]int{axis}, len(a.shape)); err != nil {
+		return
+	}
+	return applyOp(cosineSimilarityOp{along: along[0], d: a.Dims()}, a, b)
+}
+
+// WeightedSum computes the sum of values × weights along the axis, which is removed, as a single op. A negative axis counts from the end,
+// and vectors are reduced to a scalar. The weights are broadcast to the shape of the values, following the usual (NumPy) broadcasting rules,
+// so a (3, 4) matrix of values may be weighted by a (4) vector, say.
+//
+// The gradient of the values is the weights × gradZ, and that of the weights is the values × gradZ, summed over the axes the weights were broadcast along.
+func WeightedSum(values, weights *Node, axis int) (retVal *Node, err error) {
+	if values.IsScalar() || weights.IsScalar() {
+		return nil, errors.Errorf("Cannot compute the weighted sum of scalars along an axis")
+	}
+	if _, err = broadcastAxes(weights.shape, values.shape); err != nil {
+		return nil, errors.Wrap(err, "Cannot broadcast the weights to the values")
+	}
+
+	var along []int
+	if along, err = normalizeAxes([]int{axis}, len(values.shape)); err != nil {
+		return
+	}
+	return applyOp(weightedSumOp{along: along[0], d: values.Dims(), wd: weights.Dims()}, values, weights)
+}
+
+// SumAll sums up every element of a into a scalar. The gradient of a is the gradient of the scalar, broadcast back to the shape of a.
+func SumAll(a *Node) (retVal *Node, err error) {
+	if a.IsScalar() {
+		return a, nil
+	}
+	if retVal, err = Sum(a, sumAllAxes(a)...); err != nil {
+		return nil, errors.Wrap(err, operationError)
+	}
+	return scalarOf("SumAll", a, retVal)
+}
+
+// MeanAll averages every element of a into a scalar. The gradient of a is the gradient of the scalar divided by the number of elements of a,
+// broadcast back to the shape of a.
+func MeanAll(a *Node) (retVal *Node, err error) {
+	if a.IsScalar() {
+		return a, nil
+	}
+	if retVal, err = Mean(a, intRange(0, a.Dims())...); err != nil {
+		return nil, errors.Wrap(err, operationError)
+	}
+	return scalarOf("MeanAll", a, retVal)
+}
+
+// MaxAll finds the max of every element of a, as a scalar. a is flattened and the max is found with MaxWithArg, so the gradient flows back
+// to the element where the max is, and to no other. Ties go to the element that comes first in row-major order.
+func MaxAll(a *Node) (retVal *Node, err error) {
+	if a.IsScalar() {
+		return a, nil
+	}
+
+	var flat *Node
+	if flat, err = Reshape(a, types.Shape{a.shape.TotalSize()}); err != nil {
+		return nil, errors.Wrap(err, operationError)
+	}
+	if retVal, _, err = MaxWithArg(flat, 0); err != nil {
+		return nil, errors.Wrap(err, operationError)
+	}
+	return scalarOf("MaxAll", a, retVal)
+}
+
+// scalarOf checks that the reduction of a over all of its axes is indeed a scalar
+func scalarOf(fn string, a, reduced *Node) (*Node, error) {
+	if !reduced.IsScalar() {
+		return nil, errors.Errorf("%v of %v is expected to be a scalar. Got %v shaped %v instead", fn, a, reduced, reduced.shape)
+	}
+	return reduced, nil
+}
+
 // Norm returns the p-norm of a Value. Use p=2 if you want to use unordered norms.
 //
 // This is a simpler version of the norms found in the Tensor package, which specializes and optimizes even more
@@ -509,6 +1245,111 @@ func Norm(a *Node, axis, p int) (retVal *Node, err error) {
 	return
 }
 
+// FrobeniusInner computes the Frobenius inner product ⟨a, b⟩ = Σ a ⊙ b of two matrices of the same shape, as a scalar.
+// Unlike Mul, it pairs up the elements of a and b one by one. The gradients are b × gradZ for a, and a × gradZ for b.
+func FrobeniusInner(a, b *Node) (retVal *Node, err error) {
+	if !a.IsMatrix() || !b.IsMatrix() {
+		return nil, errors.Errorf("Expected two matrices. Got %v and %v instead", a.t, b.t)
+	}
+	if !a.shape.Eq(b.shape) {
+		return nil, errors.Errorf("Cannot take the Frobenius inner product of matrices shaped %v and %v", a.shape, b.shape)
+	}
+
+	var prod *Node
+	if prod, err = HadamardProd(a, b); err != nil {
+		return nil, errors.Wrap(err, operationError)
+	}
+	return Sum(prod)
+}
+
+// PairwiseDist computes the Euclidean distances between the rows of a and the rows of b. Given a of shape (m, d) and b of shape (n, d),
+// the result is an (m, n) matrix, where the element at (i, j) is ‖a[i] - b[j]‖.
+//
+// The squared distances are computed as ‖a[i]‖² + ‖b[j]‖² - 2 a[i]·b[j], so all of them come out of a single a × bᵀ. The cancellation in there
+// may leave squared distances that ought to be 0 slightly negative, so they are clamped to a small epsilon before they are square rooted.
+// The distance between two identical points is therefore √ε rather than 0, and its gradient is 0 rather than infinite.
+func PairwiseDist(a, b *Node) (retVal *Node, err error) {
+	if !a.IsMatrix() || !b.IsMatrix() {
+		return nil, errors.Errorf("Expected two matrices. Got %v and %v instead", a.t, b.t)
+	}
+	if a.shape[1] != b.shape[1] {
+		return nil, errors.Errorf("Cannot find the distances between points of %d dims and points of %d dims", a.shape[1], b.shape[1])
+	}
+	m, n := a.shape[0], b.shape[0]
+
+	var dt Dtype
+	if dt, err = dtypeOf(a.t); err != nil {
+		return nil, errors.Wrap(err, dtypeOfFail)
+	}
+	var two *Node
+	var eps float64
+	switch dt {
+	case Float64:
+		two, eps = twof64, 1e-12
+	case Float32:
+		two, eps = twof32, 1e-6
+	default:
+		return nil, errors.Errorf(nyiFail, "PairwiseDist", dt)
+	}
+
+	// ‖a[i]‖² broadcast along the columns, and ‖b[j]‖² broadcast along the rows
+	norms := func(x *Node, shape types.Shape) (*Node, error) {
+		sq, err := Square(x)
+		if err != nil {
+			return nil, err
+		}
+		if sq, err = Sum(sq, 1); err != nil {
+			return nil, err
+		}
+		// the sum is a (k) vector, even if its node is shaped like a (k, 1) column
+		if sq, err = Reshape(sq, types.Shape{shape.TotalSize()}); err != nil {
+			return nil, err
+		}
+		if sq, err = Reshape(sq, shape); err != nil {
+			return nil, err
+		}
+		return BroadcastTo(sq, types.Shape{m, n})
+	}
+	var aa, bb *Node
+	if aa, err = norms(a, types.Shape{m, 1}); err != nil {
+		return nil, errors.Wrap(err, operationError)
+	}
+	if bb, err = norms(b, types.Shape{1, n}); err != nil {
+		return nil, errors.Wrap(err, operationError)
+	}
+
+	var bT, ab *Node
+	if bT, err = Transpose(b); err != nil {
+		return nil, errors.Wrap(err, operationError)
+	}
+	if ab, err = Mul(a, bT); err != nil {
+		return nil, errors.Wrap(err, operationError)
+	}
+	if ab, err = HadamardProd(ab, two); err != nil {
+		return nil, errors.Wrap(err, hadamardProdFail)
+	}
+
+	var d2 *Node
+	if d2, err = Add(aa, bb); err != nil {
+		return nil, errors.Wrap(err, operationError)
+	}
+	if d2, err = Sub(d2, ab); err != nil {
+		return nil, errors.Wrap(err, operationError)
+	}
+
+	// max(d², ε) = relu(d² - ε) + ε
+	if d2, err = AddScalar(d2, -eps); err != nil {
+		return nil, errors.Wrap(err, operationError)
+	}
+	if d2, err = Rectify(d2); err != nil {
+		return nil, errors.Wrap(err, operationError)
+	}
+	if d2, err = AddScalar(d2, eps); err != nil {
+		return nil, errors.Wrap(err, operationError)
+	}
+	return Sqrt(d2)
+}
+
 // Reduction
 
 // ReduceAdd takes a slice of *Nodes, and folds them into one by adding
@@ -583,7 +1424,10 @@ func ReduceMul(nodes Nodes, opts ...NodeConsOpt) (retVal *Node, err error) {
 
 /* Shape related operations */
 
-// SizeOf returns the size of a value along an axis
+// SizeOf returns the size of a value along an axis, as a scalar of the same Dtype as the value.
+//
+// If the shape of x is known, the size is folded into a constant node straight away. Otherwise a sizeOp is added to the graph,
+// which reads the size off the value when the graph is run.
 func SizeOf(axis int, x *Node) (retVal *Node, err error) {
 	op := sizeOp{
 		axis: axis,
@@ -592,12 +1436,60 @@ func SizeOf(axis int, x *Node) (retVal *Node, err error) {
 
 	// if the shape is known
 	if x.shape != nil {
-		op.val = x.shape[axis]
+		size := 1 // the size of a scalar is 1, whatever the axis
+		if !x.IsScalar() {
+			if axis < 0 || axis >= len(x.shape) {
+				return nil, errors.Errorf("Cannot get the size of axis %d of %v, which has a shape of %v", axis, x, x.shape)
+			}
+			size = x.shape[axis]
+		}
+		op.val = size
+
+		var dt Dtype
+		if dt, err = dtypeOf(x.t); err != nil {
+			return nil, errors.Wrap(err, dtypeOfFail)
+		}
+
+		var v interface{}
+		switch dt {
+		case Float64:
+			v = float64(size)
+		case Float32:
+			v = float32(size)
+		case Int:
+			v = size
+		}
+
+		if v != nil {
+			retVal = NewConstant(v)
+			if x.g != nil {
+				retVal = x.g.AddNode(retVal)
+			}
+			return
+		}
 	}
 
 	return applyOp(op, x)
 }
 
+// ShapeOf returns the shape of a value as a vector of ints. It is not differentiable.
+func ShapeOf(x *Node) (retVal *Node, err error) {
+	if x.IsScalar() {
+		return nil, errors.Errorf("Cannot get the shape of a scalar value (%v) as a vector", x)
+	}
+
+	if x.shape == nil {
+		return nil, errors.Errorf("Cannot get the shape of %v, as its shape is unknown", x)
+	}
+
+	// the rank is taken from the shape, because vectors such as (2, 1) have Dims() of 1
+	op := shapeOfOp{
+		d:    x.Dims(),
+		rank: len(x.shape),
+	}
+	return applyOp(op, x)
+}
+
 // Slice slices a *Node. For T[:] slices, pass in nil. Will error out if node's type is not a Tensor
 func Slice(n *Node, slices ...types.Slice) (retVal *Node, err error) {
 	if _, ok := n.t.(*TensorType); !ok {
@@ -626,6 +1518,41 @@ func Slice(n *Node, slices ...types.Slice) (retVal *Node, err error) {
 	return
 }
 
+// Split partitions n along an axis into consecutive chunks of the given sizes, which have to add up to the size of the axis.
+// The chunks are slices of n, so they are views, and the gradients of the chunks are put back together into the gradient of n.
+// As with Slice, a chunk of size 1 loses the axis.
+func Split(n *Node, axis int, sizes []int) (retVal Nodes, err error) {
+	if n.IsScalar() {
+		return nil, errors.Errorf("Cannot split a scalar (%v)", n)
+	}
+	if axis < 0 || axis >= len(n.shape) {
+		return nil, errors.Errorf("Cannot split %v, which has a shape of %v, along axis %d", n, n.shape, axis)
+	}
+
+	var total int
+	for _, size := range sizes {
+		if size <= 0 {
+			return nil, errors.Errorf("Expected the sizes of the chunks to be positive. Got %v instead", sizes)
+		}
+		total += size
+	}
+	if total != n.shape[axis] {
+		return nil, errors.Errorf("Expected the sizes of the chunks %v to add up to %d, the size of axis %d of %v. Got %d instead", sizes, n.shape[axis], axis, n, total)
+	}
+
+	var start int
+	for _, size := range sizes {
+		op := newSliceOp(S(start, start+size, 1), axis, n.Dims())
+		var chunk *Node
+		if chunk, err = applyOp(op, n); err != nil {
+			return nil, errors.Wrap(err, operationError)
+		}
+		retVal = append(retVal, chunk)
+		start += size
+	}
+	return
+}
+
 func Transpose(n *Node, axes ...int) (retVal *Node, err error) {
 	// prep axes
 	if len(axes) > 0 && len(axes) != n.Dims() {
@@ -651,3 +1578,195 @@ func Transpose(n *Node, axes ...int) (retVal *Node, err error) {
 
 	return applyOp(op, n)
 }
+
+// TransposeLayout transposes a 4D batch of images from one layout to another, such as NHWC to NCHW. Unlike Transpose, the result is not a view:
+// the elements are copied into the new layout, so that the result can be handed to code that expects it. The gradient is transposed back to the layout of n.
+func TransposeLayout(n *Node, from, to Layout) (retVal *Node, err error) {
+	if len(n.shape) != 4 {
+		return nil, errors.Errorf("Expected a 4D tensor to be able to transpose it from %v to %v. Got %v, which has a shape of %v instead", from, to, n, n.shape)
+	}
+	if _, err = from.permutation(to); err != nil {
+		return
+	}
+	if from == to {
+		return n, nil
+	}
+	return applyOp(layoutOp{from: from, to: to}, n)
+}
+
+// Reshape reshapes a *Node into the given shape. The total size of the new shape has to be the same as the old.
+func Reshape(n *Node, to types.Shape) (retVal *Node, err error) {
+	if n.IsScalar() {
+		return nil, errors.Errorf("Cannot reshape a scalar value (%v)", n)
+	}
+	if n.shape.TotalSize() != to.TotalSize() {
+		return nil, errors.Errorf("Cannot reshape %v to %v", n.shape, to)
+	}
+	// Eq considers a vector and a column vector to be the same shape, hence the check on the dims
+	if len(n.shape) == len(to) && n.shape.Eq(to) {
+		return n, nil
+	}
+
+	op := reshapeOp{
+		from: n.shape.Clone(),
+		to:   to.Clone(),
+	}
+	return applyOp(op, n)
+}
+
+// AsColVec reshapes a vector of length k (be it a (k), (1, k) or (k, 1) vector) into a column vector of shape (k, 1).
+// The gradient is reshaped back to the shape of n.
+func AsColVec(n *Node) (retVal *Node, err error) {
+	if !n.IsVector() {
+		return nil, errors.Errorf("Expected a vector. Got %v of shape %v instead", n, n.shape)
+	}
+	return Reshape(n, types.Shape{n.shape.TotalSize(), 1})
+}
+
+// AsRowVec reshapes a vector of length k (be it a (k), (1, k) or (k, 1) vector) into a row vector of shape (1, k).
+// The gradient is reshaped back to the shape of n.
+func AsRowVec(n *Node) (retVal *Node, err error) {
+	if !n.IsVector() {
+		return nil, errors.Errorf("Expected a vector. Got %v of shape %v instead", n, n.shape)
+	}
+	return Reshape(n, types.Shape{1, n.shape.TotalSize()})
+}
+
+// Squeeze removes the given axes of n, each of which must be of size 1. With no axes given, all the axes of size 1 are removed.
+// It is a Reshape, so the gradient is reshaped back to the shape of n.
+func Squeeze(n *Node, axes ...int) (retVal *Node, err error) {
+	if n.IsScalar() {
+		return nil, errors.Errorf("Cannot squeeze a scalar value (%v)", n)
+	}
+
+	for _, a := range axes {
+		if a < 0 || a >= len(n.shape) {
+			return nil, errors.Errorf("Cannot squeeze axis %d of %v", a, n.shape)
+		}
+		if n.shape[a] != 1 {
+			return nil, errors.Errorf("Cannot squeeze axis %d of %v, which is of size %d", a, n.shape, n.shape[a])
+		}
+	}
+
+	to := make(types.Shape, 0, len(n.shape))
+	for i, size := range n.shape {
+		if size == 1 && (len(axes) == 0 || intsContain(axes, i)) {
+			continue
+		}
+		to = append(to, size)
+	}
+	if len(to) == 0 {
+		return nil, errors.Errorf("Squeezing %v would leave a scalar", n.shape)
+	}
+	return Reshape(n, to)
+}
+
+// Unsqueeze inserts an axis of size 1 into the shape of n, so that it becomes the given axis. Unsqueezing a (3) vector at axis 0 gives a (1, 3) row vector, for instance.
+// It is a Reshape, so the gradient is reshaped back to the shape of n.
+func Unsqueeze(n *Node, axis int) (retVal *Node, err error) {
+	if n.IsScalar() {
+		return nil, errors.Errorf("Cannot unsqueeze a scalar value (%v)", n)
+	}
+	if axis < 0 || axis > len(n.shape) {
+		return nil, errors.Errorf("Cannot insert axis %d into %v", axis, n.shape)
+	}
+
+	to := make(types.Shape, 0, len(n.shape)+1)
+	to = append(to, n.shape[:axis]...)
+	to = append(to, 1)
+	to = append(to, n.shape[axis:]...)
+	return Reshape(n, to)
+}
+
+// Gather takes the slices of n at the given indices along the axis, in the style of NumPy's take. indices is an Int vector, and may contain repeats.
+// The returned node has the same shape as n, except that the size of the axis is the number of indices.
+// The gradient is scatter-added back along the axis.
+func Gather(n *Node, axis int, indices *Node) (retVal *Node, err error) {
+	if n.IsScalar() {
+		return nil, errors.Errorf("Cannot gather from a scalar value (%v)", n)
+	}
+	if axis < 0 || axis >= len(n.shape) {
+		return nil, errors.Errorf(invalidAxis, axis, len(n.shape))
+	}
+
+	op := gatherOp{
+		axis: axis,
+		d:    n.Dims(),
+	}
+	return applyOp(op, n, indices)
+}
+
+// Scatter writes updates into (a copy of) base at the given indices along the axis. It is the inverse of Gather: updates has the same shape as base,
+// except that the size of the axis is the number of indices. If an index repeats, the last update wins.
+// The gradient wrt base is the output gradient with the scattered positions zeroed, and the gradient wrt updates is the output gradient gathered at the indices.
+func Scatter(base *Node, axis int, indices, updates *Node) (retVal *Node, err error) {
+	if base.IsScalar() {
+		return nil, errors.Errorf("Cannot scatter into a scalar value (%v)", base)
+	}
+	if axis < 0 || axis >= len(base.shape) {
+		return nil, errors.Errorf(invalidAxis, axis, len(base.shape))
+	}
+
+	op := scatterOp{
+		axis: axis,
+		d:    base.Dims(),
+	}
+	return applyOp(op, base, indices, updates)
+}
+
+// BlockDiag places the matrices on the diagonal of a larger matrix, which is zero everywhere else. The first matrix goes in the top left corner,
+// and each of the rest starts at the row and column after the one before it ends. The gradient of each matrix is its block of the output gradient.
+func BlockDiag(ns ...*Node) (retVal *Node, err error) {
+	if len(ns) == 0 {
+		return nil, errors.New("BlockDiag needs at least one matrix")
+	}
+	for _, n := range ns {
+		if !n.IsMatrix() {
+			return nil, errors.Errorf("Expected a matrix. Got %v, which has a shape of %v instead", n, n.shape)
+		}
+	}
+	return applyOp(blockDiagOp{n: len(ns)}, ns...)
+}
+
+// Symmetrize computes (n + nᵀ)/2, the symmetric part of a square matrix. The gradient is symmetrized the same way.
+func Symmetrize(n *Node) (retVal *Node, err error) {
+	if !n.IsMatrix() || n.shape[0] != n.shape[1] {
+		return nil, errors.Errorf("Expected a square matrix. Got %v, which has a shape of %v instead", n, n.shape)
+	}
+	return applyOp(symmetrizeOp{}, n)
+}
+
+// Reverse flips the order of the elements of n along an axis, so that the last comes first. Reversing twice gives back n.
+// The gradient is the output gradient reversed along the same axis.
+func Reverse(n *Node, axis int) (retVal *Node, err error) {
+	if n.IsScalar() {
+		return nil, errors.Errorf("Cannot reverse a scalar value (%v)", n)
+	}
+	if axis < 0 || axis >= len(n.shape) {
+		return nil, errors.Errorf(invalidAxis, axis, len(n.shape))
+	}
+
+	op := reverseOp{
+		axis: axis,
+		d:    n.Dims(),
+	}
+	return applyOp(op, n)
+}
+
+// Roll circularly shifts the elements of n along an axis by shift places, in the style of NumPy's roll: the elements shifted past the end come back in at the start.
+// A negative shift rolls the other way, and shifts beyond the size of the axis wrap around. The gradient is the output gradient rolled back by -shift.
+func Roll(n *Node, shift, axis int) (retVal *Node, err error) {
+	if n.IsScalar() {
+		return nil, errors.Errorf("Cannot roll a scalar value (%v)", n)
+	}
+	if axis < 0 || axis >= len(n.shape) {
+		return nil, errors.Errorf(invalidAxis, axis, len(n.shape))
+	}
+
+	op := rollOp{
+		shift: shift,
+		axis:  axis,
+		d:     n.Dims(),
+	}
+	return applyOp(op, n)
+}
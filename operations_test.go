@@ -1,8 +1,10 @@
 package gorgonia
 
 import (
+	"math"
 	"testing"
 
+	tf32 "github.com/chewxy/gorgonia/tensor/f32"
 	tf64 "github.com/chewxy/gorgonia/tensor/f64"
 	"github.com/chewxy/gorgonia/tensor/types"
 	"github.com/stretchr/testify/assert"
@@ -158,6 +160,103 @@ func TestMul(t *testing.T) {
 	}
 }
 
+func TestBatchDot(t *testing.T) {
+	assert := assert.New(t)
+
+	batch, k := 3, 4
+	as := []float64{
+		0, 1, 2, 3,
+		4, 5, 6, 7,
+		8, 9, 10, 11,
+	}
+	bs := []float64{
+		1, -1, 2, 0.5,
+		0, 2, -3, 1,
+		-2, 1, 1, 3,
+	}
+	ws := []float64{1, 2, -1}
+
+	// z = rowwise a⋅b, cost = Σ w * z, so ∂a = b * w[:, None] and ∂b = a * w[:, None]
+	correct := make([]float64, batch)
+	correctDA := make([]float64, batch*k)
+	correctDB := make([]float64, batch*k)
+	for i := 0; i < batch; i++ {
+		for j := 0; j < k; j++ {
+			correct[i] += as[i*k+j] * bs[i*k+j]
+			correctDA[i*k+j] = bs[i*k+j] * ws[i]
+			correctDB[i*k+j] = as[i*k+j] * ws[i]
+		}
+	}
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		a := NewMatrix(g, Float64, WithName("a"), WithShape(batch, k), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(as)), tf64.WithShape(batch, k))))
+		b := NewMatrix(g, Float64, WithName("b"), WithShape(batch, k), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(bs)), tf64.WithShape(batch, k))))
+		w := NewVector(g, Float64, WithName("w"), WithShape(batch), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(ws)), tf64.WithShape(batch))))
+
+		z, err := BatchDot(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(types.Shape{batch}, z.Shape())
+
+		cost := Must(Sum(Must(HadamardProd(z, w))))
+
+		if useTape {
+			if _, err = Grad(cost, a, b); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.Equal(correct, extractF64s(z.Value()), "Tape %t", useTape)
+
+		da, err := a.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := b.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(correctDA, extractF64s(da), "Tape %t", useTape)
+		assert.Equal(correctDB, extractF64s(db), "Tape %t", useTape)
+	}
+
+	// float32
+	a := FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(as)), tf32.WithShape(batch, k)))
+	b := FromTensor(tf32.NewTensor(tf32.WithBacking(f64sToF32s(bs)), tf32.WithShape(batch, k)))
+	z, err := linAlgBinOp{āBinaryOperator: batchedDotOperator}.Do(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(f64sToF32s(correct), z.Data())
+
+	// shapes must match
+	g := NewGraph()
+	x := NewMatrix(g, Float64, WithName("x"), WithShape(3, 4))
+	y := NewMatrix(g, Float64, WithName("y"), WithShape(4, 3))
+	if _, err = BatchDot(x, y); err == nil {
+		t.Error("Expected an error with mismatched shapes")
+	}
+	v := NewVector(g, Float64, WithName("v"), WithShape(3))
+	if _, err = BatchDot(v, v); err == nil {
+		t.Error("Expected an error with vectors")
+	}
+}
+
 func TestSoftMax(t *testing.T) {
 	assert := assert.New(t)
 	g := NewGraph()
@@ -245,6 +344,238 @@ func TestSlice(t *testing.T) {
 	t.Logf("x[0, 0]: %v ", x00.Value())
 }
 
+func TestSplit(t *testing.T) {
+	assert := assert.New(t)
+
+	xs := []float64{1, 2, 3, 4, 5, 6}
+	ws := [][]float64{{10, 20}, {-1, -2, -3, -4}}
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		x := NewVector(g, Float64, WithName("x"), WithShape(6), WithValue(tf64.NewTensor(tf64.WithBacking(xs), tf64.WithShape(6))))
+
+		chunks, err := Split(x, 0, []int{2, 4})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(2, len(chunks))
+		assert.Equal(types.Shape{2}, chunks[0].Shape())
+		assert.Equal(types.Shape{4}, chunks[1].Shape())
+
+		// cost = w₀·x[0:2] + w₁·x[2:6]
+		var cost *Node
+		for i, chunk := range chunks {
+			w := NewVector(g, Float64, WithShape(len(ws[i])), WithValue(tf64.NewTensor(tf64.WithBacking(ws[i]), tf64.WithShape(len(ws[i])))))
+			dot := Must(Sum(Must(HadamardProd(chunk, w))))
+			if cost == nil {
+				cost = dot
+				continue
+			}
+			cost = Must(Add(cost, dot))
+		}
+
+		if useTape {
+			if _, err = Grad(cost, x); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.Equal([]float64{1, 2}, extractF64s(chunks[0].Value()), "Tape %t", useTape)
+		assert.Equal([]float64{3, 4, 5, 6}, extractF64s(chunks[1].Value()), "Tape %t", useTape)
+
+		// the gradients of the chunks are put back together
+		dx, err := x.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal([]float64{10, 20, -1, -2, -3, -4}, extractF64s(dx), "Tape %t", useTape)
+	}
+
+	// along the columns of a matrix
+	g := NewGraph()
+	m := NewMatrix(g, Float64, WithName("m"), WithShape(2, 5), WithValue(tf64.NewTensor(tf64.WithBacking(tf64.RangeFloat64(0, 10)), tf64.WithShape(2, 5))))
+	chunks, err := Split(m, 1, []int{3, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(types.Shape{2, 3}, chunks[0].Shape())
+	assert.Equal(types.Shape{2, 2}, chunks[1].Shape())
+
+	machine := NewLispMachine(g, ExecuteFwdOnly())
+	if err = machine.RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(types.Shape{2, 3}, chunks[0].Value().Shape())
+	assert.Equal([]float64{0, 1, 2, 5, 6, 7}, materializedF64s(chunks[0].Value().(Tensor).Tensor.(*tf64.Tensor)))
+	assert.Equal([]float64{3, 4, 8, 9}, materializedF64s(chunks[1].Value().(Tensor).Tensor.(*tf64.Tensor)))
+
+	// bad sizes
+	if _, err = Split(m, 1, []int{2, 2}); err == nil {
+		t.Error("Expected an error when the sizes do not add up to the size of the axis")
+	}
+	if _, err = Split(m, 0, []int{2, 0}); err == nil {
+		t.Error("Expected an error with a chunk of size 0")
+	}
+	if _, err = Split(m, 2, []int{2}); err == nil {
+		t.Error("Expected an error when the axis is out of range")
+	}
+}
+
+func TestAddEps(t *testing.T) {
+	assert := assert.New(t)
+
+	xs := []float64{0, 1, 2, 3, 4, 5}
+	ws := []float64{1, -1, 2, 0.5, 3, -2}
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		x := NewMatrix(g, Float64, WithName("x"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(2, 3))))
+		w := NewMatrix(g, Float64, WithName("w"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(2, 3))))
+		v := NewScalar(g, Float64, WithName("v"), WithValue(4.0))
+
+		y, err := AddEps(x, 0.5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.IsType(addScalarOp{}, y.op)
+		var yv Value
+		Read(y, &yv)
+
+		// the layer norm pattern: √(v + ε)
+		std := Must(Sqrt(Must(AddEps(v, 0.25))))
+
+		cost := Must(Add(Must(Sum(Must(HadamardProd(y, w)))), std))
+		if useTape {
+			if _, err = Grad(cost, x, v); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.Equal([]float64{0.5, 1.5, 2.5, 3.5, 4.5, 5.5}, extractF64s(yv), "Tape %t", useTape)
+		assert.Equal(math.Sqrt(4.25), extractF64(std.Value()), "Tape %t", useTape)
+
+		// the gradient passes straight through
+		dx, err := x.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(ws, extractF64s(dx), "Tape %t", useTape)
+		dv, err := v.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatEquals(0.5/math.Sqrt(4.25), extractF64(dv)), "Tape %t: %v", useTape, dv)
+	}
+
+	g := NewGraph()
+	b := NewScalar(g, Bool, WithName("b"))
+	if _, err := AddEps(b, 1e-5); err == nil {
+		t.Error("Expected an error adding an epsilon to a Bool")
+	}
+}
+
+func TestSafeDiv(t *testing.T) {
+	assert := assert.New(t)
+
+	as := []float64{1, 2, 3, -1, 0, 6}
+	bs := []float64{2, 0, 4, 0, 0, 3}
+	ws := []float64{1, -1, 2, 0.5, 3, -2}
+	eps := 1e-3
+
+	var correct, correctDA, correctDB []float64
+	for i, a := range as {
+		d := bs[i] + eps
+		correct = append(correct, a/d)
+		correctDA = append(correctDA, ws[i]/d)
+		correctDB = append(correctDB, -ws[i]*a/(d*d))
+	}
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		a := NewMatrix(g, Float64, WithName("a"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(as)), tf64.WithShape(2, 3))))
+		b := NewMatrix(g, Float64, WithName("b"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(bs)), tf64.WithShape(2, 3))))
+		w := NewMatrix(g, Float64, WithName("w"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(2, 3))))
+
+		q, err := SafeDiv(a, b, eps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var qv Value
+		Read(q, &qv)
+
+		cost := Must(Sum(Must(HadamardProd(q, w))))
+		if useTape {
+			if _, err = Grad(cost, a, b); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.True(floatsClose(correct, extractF64s(qv)), "Tape %t. Expected %v. Got %v", useTape, correct, qv)
+
+		da, err := a.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := b.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(correctDA, extractF64s(da)), "Tape %t. Expected %v. Got %v", useTape, correctDA, da)
+		assert.True(floatsClose(correctDB, extractF64s(db)), "Tape %t. Expected %v. Got %v", useTape, correctDB, db)
+
+		// the zeros in b don't make the gradients blow up
+		for _, v := range append(extractF64s(da), extractF64s(db)...) {
+			assert.False(math.IsInf(v, 0) || math.IsNaN(v), "Tape %t: %v", useTape, v)
+		}
+	}
+
+	g := NewGraph()
+	a := NewVector(g, Float64, WithName("a"), WithShape(2))
+	b := NewVector(g, Float64, WithName("b"), WithShape(2))
+	if _, err := SafeDiv(a, b, -1); err == nil {
+		t.Error("Expected an error with a negative eps")
+	}
+}
+
 func TestSum(t *testing.T) {
 	assert := assert.New(t)
 	var g *ExprGraph
@@ -421,3 +752,339 @@ func TestNorm(t *testing.T) {
 	assert.Equal(correct, extractF64s(norm.Value()))
 
 }
+
+func TestFrobeniusInner(t *testing.T) {
+	assert := assert.New(t)
+
+	as := []float64{
+		1, 2, 3,
+		4, 5, 6,
+	}
+	bs := []float64{
+		0.5, -1, 2,
+		3, 0, -0.25,
+	}
+
+	inner := func(a, b []float64) float64 {
+		var retVal float64
+		for i := range a {
+			retVal += a[i] * b[i]
+		}
+		return retVal
+	}
+
+	// cost = 2⟨a, b⟩, so that gradZ is not 1
+	cas, cbs := clonef64s(as), clonef64s(bs)
+	correctDA := numericGrad(cas, func() float64 { return 2 * inner(cas, cbs) })
+	correctDB := numericGrad(cbs, func() float64 { return 2 * inner(cas, cbs) })
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		a := NewMatrix(g, Float64, WithName("a"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(as)), tf64.WithShape(2, 3))))
+		b := NewMatrix(g, Float64, WithName("b"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(bs)), tf64.WithShape(2, 3))))
+
+		f, err := FrobeniusInner(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(f.IsScalar())
+
+		c := Must(Mul(f, twof64))
+		if useTape {
+			if _, err = Grad(c, a, b); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.True(floatEquals(inner(as, bs), extractF64(f.Value())), "Tape %t: %v", useTape, f.Value())
+
+		da, err := a.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := b.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(correctDA, extractF64s(da)), "Tape %t. Expected %v. Got %v", useTape, correctDA, da)
+		assert.True(floatsClose(correctDB, extractF64s(db)), "Tape %t. Expected %v. Got %v", useTape, correctDB, db)
+	}
+
+	g := NewGraph()
+	a := NewMatrix(g, Float64, WithName("a"), WithShape(2, 3))
+	b := NewMatrix(g, Float64, WithName("b"), WithShape(3, 2))
+	if _, err := FrobeniusInner(a, b); err == nil {
+		t.Error("Expected an error with matrices of different shapes")
+	}
+	v := NewVector(g, Float64, WithName("v"), WithShape(6))
+	if _, err := FrobeniusInner(v, v); err == nil {
+		t.Error("Expected an error with vectors")
+	}
+}
+
+func TestPairwiseDist(t *testing.T) {
+	assert := assert.New(t)
+
+	m, n, d := 3, 4, 2
+	as := []float64{
+		0, 0,
+		1, 2,
+		-1, 0.5,
+	}
+	bs := []float64{
+		3, 4,
+		1, 2,
+		0, -1,
+		-2, 1,
+	}
+	ws := []float64{
+		1, -1, 2, 0.5,
+		0.5, 2, -1, 1,
+		-2, 1, 1, 3,
+	}
+
+	dists := func(a, b []float64) []float64 {
+		retVal := make([]float64, 0, m*n)
+		for i := 0; i < m; i++ {
+			for j := 0; j < n; j++ {
+				var sq float64
+				for k := 0; k < d; k++ {
+					diff := a[i*d+k] - b[j*d+k]
+					sq += diff * diff
+				}
+				retVal = append(retVal, math.Sqrt(sq))
+			}
+		}
+		return retVal
+	}
+
+	// cost = Σ w * dist. a[1] and b[1] are the same point, whose distance has no gradient
+	cost := func(a, b []float64) float64 {
+		var retVal float64
+		for i, v := range dists(a, b) {
+			if i == 1*n+1 {
+				continue
+			}
+			retVal += ws[i] * v
+		}
+		return retVal
+	}
+	cas, cbs := clonef64s(as), clonef64s(bs)
+	correctDA := numericGrad(cas, func() float64 { return cost(cas, cbs) })
+	correctDB := numericGrad(cbs, func() float64 { return cost(cas, cbs) })
+	correct := dists(as, bs)
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		a := NewMatrix(g, Float64, WithName("a"), WithShape(m, d), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(as)), tf64.WithShape(m, d))))
+		b := NewMatrix(g, Float64, WithName("b"), WithShape(n, d), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(bs)), tf64.WithShape(n, d))))
+		w := NewMatrix(g, Float64, WithName("w"), WithShape(m, n), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(m, n))))
+
+		dist, err := PairwiseDist(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(types.Shape{m, n}, dist.Shape())
+		var distV Value
+		Read(dist, &distV)
+
+		c := Must(Sum(Must(HadamardProd(dist, w))))
+		if useTape {
+			if _, err = Grad(c, a, b); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		got := extractF64s(distV)
+		assert.True(floatsClose(correct, got), "Tape %t. Expected %v. Got %v", useTape, correct, got)
+		assert.True(got[1*n+1] > 0, "Tape %t: the distance between identical points is clamped to √ε. Got %v", useTape, got[1*n+1])
+
+		da, err := a.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := b.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(correctDA, extractF64s(da)), "Tape %t. Expected %v. Got %v", useTape, correctDA, da)
+		assert.True(floatsClose(correctDB, extractF64s(db)), "Tape %t. Expected %v. Got %v", useTape, correctDB, db)
+	}
+
+	g := NewGraph()
+	a := NewMatrix(g, Float64, WithName("a"), WithShape(m, d))
+	b := NewMatrix(g, Float64, WithName("b"), WithShape(n, d+1))
+	if _, err := PairwiseDist(a, b); err == nil {
+		t.Error("Expected an error with points of different dims")
+	}
+	v := NewVector(g, Float64, WithName("v"), WithShape(d))
+	if _, err := PairwiseDist(a, v); err == nil {
+		t.Error("Expected an error with a vector")
+	}
+}
+
+func TestBatchMatVecMul(t *testing.T) {
+	assert := assert.New(t)
+
+	m, k, b := 2, 3, 5
+	as := []float64{
+		1, 2, -1,
+		0, 3, 2,
+	}
+	vs := []float64{
+		1, 0, 2, -1, 3,
+		2, 1, 0, 1, -2,
+		0, -1, 1, 2, 1,
+	}
+	ws := []float64{
+		1, 2, 0, -1, 1,
+		3, -2, 1, 1, 0,
+	}
+
+	// z = a × vs, cost = Σ w * z, so ∂a = w × vsᵀ and ∂vs = aᵀ × w
+	correct := make([]float64, m*b)
+	correctDA := make([]float64, m*k)
+	correctDV := make([]float64, k*b)
+	for i := 0; i < m; i++ {
+		for c := 0; c < b; c++ {
+			for j := 0; j < k; j++ {
+				correct[i*b+c] += as[i*k+j] * vs[j*b+c]
+				correctDA[i*k+j] += ws[i*b+c] * vs[j*b+c]
+				correctDV[j*b+c] += as[i*k+j] * ws[i*b+c]
+			}
+		}
+	}
+
+	// aᵀ, for the transposed edition
+	ats := make([]float64, k*m)
+	for i := 0; i < m; i++ {
+		for j := 0; j < k; j++ {
+			ats[j*m+i] = as[i*k+j]
+		}
+	}
+	correctDAT := make([]float64, k*m)
+	for i := 0; i < m; i++ {
+		for j := 0; j < k; j++ {
+			correctDAT[j*m+i] = correctDA[i*k+j]
+		}
+	}
+
+	for _, transA := range []bool{false, true} {
+		for _, useTape := range []bool{true, false} {
+			g := NewGraph()
+			var a, z *Node
+			var err error
+			if transA {
+				a = NewMatrix(g, Float64, WithName("a"), WithShape(k, m), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(ats)), tf64.WithShape(k, m))))
+			} else {
+				a = NewMatrix(g, Float64, WithName("a"), WithShape(m, k), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(as)), tf64.WithShape(m, k))))
+			}
+			v := NewMatrix(g, Float64, WithName("vs"), WithShape(k, b), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(vs)), tf64.WithShape(k, b))))
+			w := NewMatrix(g, Float64, WithName("w"), WithShape(m, b), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(ws)), tf64.WithShape(m, b))))
+
+			if transA {
+				z, err = binOpNode(linAlgBinOp{āBinaryOperator: matVecMulOperator, transA: true, batched: true}, a, v)
+			} else {
+				z, err = BatchMatVecMul(a, v)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(types.Shape{m, b}, z.Shape())
+
+			cost := Must(Sum(Must(HadamardProd(z, w))))
+
+			if useTape {
+				if _, err = Grad(cost, a, v); err != nil {
+					t.Fatal(err)
+				}
+				prog, locMap, err := Compile(g)
+				if err != nil {
+					t.Fatal(err)
+				}
+				m := NewTapeMachine(prog, locMap)
+				if err = m.RunAll(); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				m := NewLispMachine(g)
+				if err = m.RunAll(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			assert.Equal(correct, extractF64s(z.Value()), "TransA %t, Tape %t", transA, useTape)
+
+			da, err := a.Grad()
+			if err != nil {
+				t.Fatal(err)
+			}
+			dv, err := v.Grad()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if transA {
+				assert.Equal(correctDAT, extractF64s(da), "TransA %t, Tape %t", transA, useTape)
+			} else {
+				assert.Equal(correctDA, extractF64s(da), "TransA %t, Tape %t", transA, useTape)
+			}
+			assert.Equal(correctDV, extractF64s(dv), "TransA %t, Tape %t", transA, useTape)
+		}
+	}
+
+	// each column is the matVecMul of the column
+	a := FromTensor(tf64.NewTensor(tf64.WithBacking(clonef64s(as)), tf64.WithShape(m, k)))
+	for c := 0; c < b; c++ {
+		col := make([]float64, k)
+		for j := range col {
+			col[j] = vs[j*b+c]
+		}
+		z, err := linAlgBinOp{āBinaryOperator: matVecMulOperator}.Do(a, FromTensor(tf64.NewTensor(tf64.WithBacking(col), tf64.WithShape(k))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal([]float64{correct[c], correct[b+c]}, extractF64s(z))
+	}
+
+	// a batched matVecMul is not a matMul
+	assert.NotEqual(linAlgBinOp{āBinaryOperator: matMulOperator}.Hashcode(), linAlgBinOp{āBinaryOperator: matVecMulOperator, batched: true}.Hashcode())
+	assert.NotEqual(linAlgBinOp{āBinaryOperator: matVecMulOperator}.Hashcode(), linAlgBinOp{āBinaryOperator: matVecMulOperator, batched: true}.Hashcode())
+
+	// shapes must match
+	g := NewGraph()
+	x := NewMatrix(g, Float64, WithName("x"), WithShape(m, k))
+	y := NewMatrix(g, Float64, WithName("y"), WithShape(k+1, b))
+	if _, err := BatchMatVecMul(x, y); err == nil {
+		t.Error("Expected an error with mismatched shapes")
+	}
+	vec := NewVector(g, Float64, WithName("vec"), WithShape(k))
+	if _, err := BatchMatVecMul(x, vec); err == nil {
+		t.Error("Expected an error with a single vector")
+	}
+}
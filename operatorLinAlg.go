@@ -1,6 +1,12 @@
 package gorgonia
 
-import "github.com/pkg/errors"
+import (
+	"github.com/chewxy/gorgonia/tensor"
+	tf32 "github.com/chewxy/gorgonia/tensor/f32"
+	tf64 "github.com/chewxy/gorgonia/tensor/f64"
+	"github.com/chewxy/gorgonia/tensor/types"
+	"github.com/pkg/errors"
+)
 
 // ā and Ā are used to denote that it's a matrix/vector type.
 // if you want to type it, it's Latin Letter A with Macron (lowercase and capital)
@@ -9,10 +15,11 @@ import "github.com/pkg/errors"
 type āBinaryOperator byte
 
 const (
-	matMulOperator    āBinaryOperator = iota // emits S/DGEMM BLAS calls
-	matVecMulOperator                        // emits S/DGEMV BLAS calls
-	vecDotOperator                           // emits S/DDOT BLAS calls
-	outerProdOperator                        // emits S/DGER BLAS calls
+	matMulOperator     āBinaryOperator = iota // emits S/DGEMM BLAS calls
+	matVecMulOperator                         // emits S/DGEMV BLAS calls
+	vecDotOperator                            // emits S/DDOT BLAS calls
+	outerProdOperator                         // emits S/DGER BLAS calls
+	batchedDotOperator                        // emits one S/DDOT BLAS call per row
 
 	maxĀBinaryOperator // delimits all possible linalg operators. Add above this line
 )
@@ -101,6 +108,7 @@ func matMulDiff(transA, transB bool, x, y, z *Node) (err error) {
 	xdv := x.boundTo.(*dualValue)
 	ydv := y.boundTo.(*dualValue)
 	zdv := z.boundTo.(*dualValue)
+	pool := z.g.tempPool()
 
 	op := linAlgBinOp{
 		āBinaryOperator: matMulOperator,
@@ -112,7 +120,7 @@ func matMulDiff(transA, transB bool, x, y, z *Node) (err error) {
 		op.transB = transB
 
 		// dzdx
-		err = op.IncrDo(xdv.d, ydv.Value, zdv.d)
+		err = op.incrDo(pool, xdv.d, ydv.Value, zdv.d)
 		if ver, ok := err.(Valuer); ok {
 			xdv.SetDeriv(ver.Value()) // ignore errors on purpose
 		} else if err != nil {
@@ -120,7 +128,7 @@ func matMulDiff(transA, transB bool, x, y, z *Node) (err error) {
 		}
 
 		// dzdy
-		err = op.IncrDo(ydv.d, zdv.d, xdv.Value)
+		err = op.incrDo(pool, ydv.d, zdv.d, xdv.Value)
 		if ver, ok := err.(Valuer); ok {
 			ydv.SetDeriv(ver.Value()) // ignore errors on purpose
 			return nil
@@ -129,7 +137,7 @@ func matMulDiff(transA, transB bool, x, y, z *Node) (err error) {
 
 	case !transA && transB:
 		// dzdx
-		err = op.IncrDo(xdv.d, zdv.d, ydv.Value)
+		err = op.incrDo(pool, xdv.d, zdv.d, ydv.Value)
 		if ver, ok := err.(Valuer); ok {
 			xdv.SetDeriv(ver.Value()) // ignore errors on purpose
 		} else if err != nil {
@@ -138,7 +146,7 @@ func matMulDiff(transA, transB bool, x, y, z *Node) (err error) {
 
 		// dzdy
 		op.transA = true
-		err = op.IncrDo(ydv.d, zdv.d, xdv.Value)
+		err = op.incrDo(pool, ydv.d, zdv.d, xdv.Value)
 		if ver, ok := err.(Valuer); ok {
 			ydv.SetDeriv(ver.Value()) // ignore errors on purpose
 			return nil
@@ -148,7 +156,7 @@ func matMulDiff(transA, transB bool, x, y, z *Node) (err error) {
 	case transA && !transB:
 		// dzdx
 		op.transB = true
-		err = op.IncrDo(xdv.d, ydv.Value, zdv.d)
+		err = op.incrDo(pool, xdv.d, ydv.Value, zdv.d)
 		if ver, ok := err.(Valuer); ok {
 			xdv.SetDeriv(ver.Value()) // ignore errors on purpose
 			return nil
@@ -157,7 +165,7 @@ func matMulDiff(transA, transB bool, x, y, z *Node) (err error) {
 		// dzdy
 		op.transA = false
 		op.transB = false
-		err = op.IncrDo(ydv.d, xdv.Value, zdv.d)
+		err = op.incrDo(pool, ydv.d, xdv.Value, zdv.d)
 		if ver, ok := err.(Valuer); ok {
 			ydv.SetDeriv(ver.Value()) // ignore errors on purpose
 		} else if err != nil {
@@ -167,7 +175,7 @@ func matMulDiff(transA, transB bool, x, y, z *Node) (err error) {
 		return
 	case !transA && !transB:
 		op.transB = true
-		err = op.IncrDo(xdv.d, zdv.d, ydv.Value)
+		err = op.incrDo(pool, xdv.d, zdv.d, ydv.Value)
 		if ver, ok := err.(Valuer); ok {
 			xdv.SetDeriv(ver.Value()) // ignore errors on purpose
 		} else if err != nil {
@@ -176,7 +184,7 @@ func matMulDiff(transA, transB bool, x, y, z *Node) (err error) {
 
 		op.transA = true
 		op.transB = false
-		err = op.IncrDo(ydv.d, xdv.Value, zdv.d)
+		err = op.incrDo(pool, ydv.d, xdv.Value, zdv.d)
 		if ver, ok := err.(Valuer); ok {
 			ydv.SetDeriv(ver.Value()) // ignore errors on purpose
 			return nil
@@ -188,6 +196,10 @@ func matMulDiff(transA, transB bool, x, y, z *Node) (err error) {
 }
 
 func matVecMulDiffExpr(transA, transB bool, x, y, z, gradZ *Node) (retVal Nodes, err error) {
+	if y.IsMatrix() {
+		return batchedMatVecMulDiffExpr(transA, x, y, gradZ)
+	}
+
 	var dzdx, dzdy *Node
 	if transA {
 		dzdx, err = OuterProd(y, gradZ)
@@ -224,18 +236,23 @@ func matVecMulDiffExpr(transA, transB bool, x, y, z, gradZ *Node) (retVal Nodes,
 }
 
 func matVecMulDiff(transA, transB bool, x, y, z *Node) (err error) {
+	if y.IsMatrix() {
+		return batchedMatVecMulDiff(transA, x, y, z)
+	}
+
 	xdv := x.boundTo.(*dualValue)
 	ydv := y.boundTo.(*dualValue)
 	zdv := z.boundTo.(*dualValue)
+	pool := z.g.tempPool()
 
 	op := linAlgBinOp{
 		āBinaryOperator: outerProdOperator,
 	}
 
 	if transA {
-		err = op.IncrDo(xdv.d, ydv.Value, zdv.d)
+		err = op.incrDo(pool, xdv.d, ydv.Value, zdv.d)
 	} else {
-		err = op.IncrDo(xdv.d, zdv.d, ydv.Value)
+		err = op.incrDo(pool, xdv.d, zdv.d, ydv.Value)
 	}
 
 	if ver, ok := err.(Valuer); ok {
@@ -249,7 +266,74 @@ func matVecMulDiff(transA, transB bool, x, y, z *Node) (err error) {
 		transA:          !transA,
 	}
 
-	err = op.IncrDo(ydv.d, xdv.Value, zdv.d)
+	err = op.incrDo(pool, ydv.d, xdv.Value, zdv.d)
+	if ver, ok := err.(Valuer); ok {
+		ydv.SetDeriv(ver.Value()) // ignore errors on purpose
+		return nil
+	}
+	return
+}
+
+// batchedMatVecMulDiffExpr differentiates z = x × y, where the columns of y are a batch of vectors.
+// The gradient of x is the sum of the outer products of the gradient of each column of z with the column of y, which is a matrix multiplication:
+//		∂x = ∂z × yᵀ	(or y × ∂zᵀ when x is transposed)
+// and the gradient of y is every column of ∂z multiplied by xᵀ, which is a batched matVecMul too.
+func batchedMatVecMulDiffExpr(transA bool, x, y, gradZ *Node) (retVal Nodes, err error) {
+	var dzdx, dzdy *Node
+	mm := linAlgBinOp{
+		āBinaryOperator: matMulOperator,
+		transB:          true,
+	}
+	if transA {
+		dzdx, err = binOpNode(mm, y, gradZ)
+	} else {
+		dzdx, err = binOpNode(mm, gradZ, y)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, binOpNodeFail, mm)
+	}
+
+	op := linAlgBinOp{
+		āBinaryOperator: matVecMulOperator,
+		transA:          !transA,
+		batched:         true,
+	}
+	if dzdy, err = binOpNode(op, x, gradZ); err != nil {
+		return nil, errors.Wrapf(err, binOpNodeFail, op)
+	}
+	return Nodes{dzdx, dzdy}, nil
+}
+
+// batchedMatVecMulDiff is the DoDiff version of batchedMatVecMulDiffExpr
+func batchedMatVecMulDiff(transA bool, x, y, z *Node) (err error) {
+	xdv := x.boundTo.(*dualValue)
+	ydv := y.boundTo.(*dualValue)
+	zdv := z.boundTo.(*dualValue)
+	pool := z.g.tempPool()
+
+	mm := linAlgBinOp{
+		āBinaryOperator: matMulOperator,
+		transB:          true,
+	}
+	if transA {
+		err = mm.incrDo(pool, xdv.d, ydv.Value, zdv.d)
+	} else {
+		err = mm.incrDo(pool, xdv.d, zdv.d, ydv.Value)
+	}
+
+	if ver, ok := err.(Valuer); ok {
+		xdv.SetDeriv(ver.Value()) // ignore errors on purpose
+	} else if err != nil {
+		return
+	}
+
+	op := linAlgBinOp{
+		āBinaryOperator: matVecMulOperator,
+		transA:          !transA,
+		batched:         true,
+	}
+
+	err = op.incrDo(pool, ydv.d, xdv.Value, zdv.d)
 	if ver, ok := err.(Valuer); ok {
 		ydv.SetDeriv(ver.Value()) // ignore errors on purpose
 		return nil
@@ -325,3 +409,73 @@ func outerProdDiff(transA, transB bool, x, y, z *Node) (err error) {
 	}
 	return
 }
+
+func batchedDotDiffExpr(transA, transB bool, x, y, z, gradZ *Node) (retVal Nodes, err error) {
+	var g, dzdx, dzdy *Node
+	if g, err = Reshape(gradZ, types.Shape{z.shape[0], 1}); err != nil {
+		return nil, errors.Wrap(err, "Failed to carry Reshape()")
+	}
+
+	// gradZ[:, None] is broadcast along the columns
+	pattern := NewBroadcastPattern(nil, []byte{1})
+	if dzdx, err = Broadcast(mulOpType, y, g, pattern); err != nil {
+		return nil, errors.Wrap(err, "Failed to carry Broadcast()")
+	}
+	if dzdy, err = Broadcast(mulOpType, x, g, pattern); err != nil {
+		return nil, errors.Wrap(err, "Failed to carry Broadcast()")
+	}
+	retVal = Nodes{dzdx, dzdy}
+	return
+}
+
+func batchedDotDiff(transA, transB bool, x, y, z *Node) (err error) {
+	xdv := x.boundTo.(*dualValue)
+	ydv := y.boundTo.(*dualValue)
+	zdv := z.boundTo.(*dualValue)
+
+	var g Value
+	if g, err = batchedDotGradCols(zdv.d, x.shape[1]); err != nil {
+		return
+	}
+
+	mul := newElemBinOp(mulOpType, x, y)
+	err = mul.IncrDo(xdv.d, ydv.Value, g)
+	if ver, ok := err.(Valuer); ok {
+		xdv.SetDeriv(ver.Value()) // ignore errors on purpose
+	} else if err != nil {
+		return
+	}
+
+	err = mul.IncrDo(ydv.d, xdv.Value, g)
+	if ver, ok := err.(Valuer); ok {
+		ydv.SetDeriv(ver.Value()) // ignore errors on purpose
+		return nil
+	}
+	return
+}
+
+// batchedDotGradCols repeats the gradient of a batched dot product (a vector of size batch) along the columns of a (batch, k) matrix
+func batchedDotGradCols(grad Value, k int) (retVal Value, err error) {
+	t, ok := grad.(Tensor)
+	if !ok {
+		return nil, errors.Errorf("Expected the gradient to be a Tensor. Got %v of %T instead", grad, grad)
+	}
+
+	// the data is shared with col, but tensor.Repeat always copies
+	var col types.Tensor
+	batch := t.Shape().TotalSize()
+	switch tt := t.Tensor.(type) {
+	case *tf64.Tensor:
+		col = tf64.NewTensor(tf64.WithBacking(materializedF64s(tt)), tf64.WithShape(batch, 1))
+	case *tf32.Tensor:
+		col = tf32.NewTensor(tf32.WithBacking(materializedF32s(tt)), tf32.WithShape(batch, 1))
+	default:
+		return nil, errors.Errorf(nyiFail, "batchedDotGradCols", t.Tensor)
+	}
+
+	var rep types.Tensor
+	if rep, err = tensor.Repeat(col, 1, k); err != nil {
+		return nil, errors.Wrapf(err, repFail, 1, k)
+	}
+	return FromTensor(rep), nil
+}
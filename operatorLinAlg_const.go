@@ -7,6 +7,7 @@ var āBinOpStrs = [maxĀBinaryOperator]string{
 	"×",
 	"⋅",
 	"⊗",
+	"⋅",
 	// "×××",
 }
 
@@ -15,6 +16,7 @@ var āBinOpDiffExprs = [maxĀBinaryOperator]func(tA, tB bool, x, y, z, grad *Nod
 	matVecMulDiffExpr,
 	vecDotDiffExpr,
 	outerProdDiffExpr,
+	batchedDotDiffExpr,
 }
 
 var āBinOpDiffs = [maxĀBinaryOperator]func(tA, tB bool, x, y, z *Node) error{
@@ -22,6 +24,7 @@ var āBinOpDiffs = [maxĀBinaryOperator]func(tA, tB bool, x, y, z *Node) error{
 	matVecMulDiff,
 	vecDotDiff,
 	outerProdDiff,
+	batchedDotDiff,
 }
 
 var āBinOpTypes = [maxĀBinaryOperator]func() Type{
@@ -29,6 +32,7 @@ var āBinOpTypes = [maxĀBinaryOperator]func() Type{
 	matVecMulType,
 	vecDotType,
 	outerProdType,
+	batchedDotType,
 }
 
 /* TYPES FOR LINALG BINARY OP*/
@@ -45,6 +49,17 @@ func matVecMulType() Type {
 	return newFunctionType(m, v, v)
 }
 
+// a batched matVecMulOp, which multiplies a matrix with a batch of vectors stacked as the columns of a matrix, is a function with this type:
+//		matVecMulOp :: (Float a) ⇒ Matrix a → Matrix a → Matrix a
+//
+// For the moment only floats are allowed
+func batchedMatVecMulType() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	m := newTensorType(2, a)
+
+	return newFunctionType(m, m, m)
+}
+
 // matMulOp is a function with this type:
 //		matMulOp :: (Float a) ⇒ Matrix a → Matrix a → Matrix a
 //
@@ -78,3 +93,15 @@ func outerProdType() Type {
 
 	return newFunctionType(v, v, m)
 }
+
+// batchedDotOp is a function with this type:
+//		batchedDotOp :: (Float a) ⇒ Matrix a → Matrix a → Vector a
+//
+// For the moment only floats are allowed
+func batchedDotType() Type {
+	a := newTypeVariable("a", withTVConstraints(floats))
+	v := newTensorType(1, a)
+	m := newTensorType(2, a)
+
+	return newFunctionType(m, m, v)
+}
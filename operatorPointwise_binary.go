@@ -1,8 +1,14 @@
 package gorgonia
 
 import (
+	"fmt"
 	"math"
+	"sync"
 
+	tb "github.com/chewxy/gorgonia/tensor/b"
+	tf32 "github.com/chewxy/gorgonia/tensor/f32"
+	tf64 "github.com/chewxy/gorgonia/tensor/f64"
+	ti "github.com/chewxy/gorgonia/tensor/i"
 	"github.com/chewxy/gorgonia/tensor/types"
 	"github.com/pkg/errors"
 )
@@ -11,18 +17,23 @@ import (
 
 type ʘBinaryOperator interface {
 	isArith() bool
+	isLogical() bool
 	binOpType() ʘBinaryOperatorType
+	returnsInt() bool
 	Do(bool, ...Value) (Value, error)
 	String() string
 }
 
 type scalarBinOp struct {
 	ʘBinaryOperatorType
-	t Dtype
+	t     Dtype
+	asInt bool // for comparison ops, return an Int 1 or 0 instead of a Bool. Takes precedence over same
 }
 
 func (o scalarBinOp) binOpType() ʘBinaryOperatorType { return o.ʘBinaryOperatorType }
 func (o scalarBinOp) isArith() bool                  { return o.ʘBinaryOperatorType.isArith() }
+func (o scalarBinOp) isLogical() bool                { return o.ʘBinaryOperatorType.isLogical() }
+func (o scalarBinOp) returnsInt() bool               { return o.asInt && !o.isArith() && !o.isLogical() }
 func (o scalarBinOp) String() string                 { return o.ʘBinaryOperatorType.String() }
 
 func (o scalarBinOp) Do(same bool, vals ...Value) (retVal Value, err error) {
@@ -74,11 +85,20 @@ func (o scalarBinOp) Do(same bool, vals ...Value) (retVal Value, err error) {
 			r = af == bf
 		case neOpType:
 			r = af != bf
+		case andOpType:
+			r = af != 0 && bf != 0
+		case orOpType:
+			r = af != 0 || bf != 0
+		case xorOpType:
+			r = (af != 0) != (bf != 0)
 		default:
 			err = errors.Errorf(nyiFail, "scalarBinOp.Do() - Float64", o.ʘBinaryOperatorType)
 		}
 
-		if same && !o.isArith() {
+		switch {
+		case o.returnsInt():
+			r = boolToInt(r.(bool))
+		case (same && !o.isArith()) || o.isLogical():
 			if r.(bool) {
 				r = float64(1)
 			} else {
@@ -111,17 +131,49 @@ func (o scalarBinOp) Do(same bool, vals ...Value) (retVal Value, err error) {
 			r = af == bf
 		case neOpType:
 			r = af != bf
+		case andOpType:
+			r = af != 0 && bf != 0
+		case orOpType:
+			r = af != 0 || bf != 0
+		case xorOpType:
+			r = (af != 0) != (bf != 0)
 		default:
 			err = errors.Errorf("scalarBinOp.Do() - Float32", o.ʘBinaryOperatorType)
 		}
 
-		if same && !o.isArith() {
+		switch {
+		case o.returnsInt():
+			r = boolToInt(r.(bool))
+		case (same && !o.isArith()) || o.isLogical():
 			if r.(bool) {
 				r = float32(1)
 			} else {
 				r = float32(0)
 			}
 		}
+	case Int, Int64, Int32:
+		r, err = o.doInt(same, a.v, b.v)
+	case Bool:
+		ab := a.v.(bool)
+		bb := b.v.(bool)
+		switch o.ʘBinaryOperatorType {
+		case eqOpType:
+			r = ab == bb
+		case neOpType:
+			r = ab != bb
+		case andOpType:
+			r = ab && bb
+		case orOpType:
+			r = ab || bb
+		case xorOpType:
+			r = ab != bb
+		default:
+			err = errors.Errorf(nyiFail, "scalarBinOp.Do() - Bool", o.ʘBinaryOperatorType)
+		}
+
+		if o.returnsInt() && err == nil {
+			r = boolToInt(r.(bool))
+		}
 	default:
 		err = errors.Errorf(nyiFail, "scalarBinOp.Do() - Unhandled Scalar Type", o.t)
 	}
@@ -132,17 +184,110 @@ func (o scalarBinOp) Do(same bool, vals ...Value) (retVal Value, err error) {
 	return anyToValue(r)
 }
 
+// doInt carries out the op on two integer scalars of o.t. Arithmetic overflows as set by IntOverflow.
+func (o scalarBinOp) doInt(same bool, av, bv interface{}) (r interface{}, err error) {
+	var ai, bi int64
+	if ai, err = intScalar(av); err != nil {
+		return
+	}
+	if bi, err = intScalar(bv); err != nil {
+		return
+	}
+
+	if o.isArith() {
+		var ri int64
+		if ri, err = intArith(o.ʘBinaryOperatorType, ai, bi, o.t); err != nil {
+			return nil, errors.Wrapf(err, doFail, o)
+		}
+		return intOfDtype(ri, o.t), nil
+	}
+
+	var rb bool
+	switch o.ʘBinaryOperatorType {
+	case ltOpType:
+		rb = ai < bi
+	case gtOpType:
+		rb = ai > bi
+	case lteOpType:
+		rb = ai <= bi
+	case gteOpType:
+		rb = ai >= bi
+	case eqOpType:
+		rb = ai == bi
+	case neOpType:
+		rb = ai != bi
+	case andOpType:
+		rb = ai != 0 && bi != 0
+	case orOpType:
+		rb = ai != 0 || bi != 0
+	case xorOpType:
+		rb = (ai != 0) != (bi != 0)
+	default:
+		return nil, errors.Errorf(nyiFail, "scalarBinOp.Do() - Int", o.ʘBinaryOperatorType)
+	}
+
+	switch {
+	case o.returnsInt():
+		return boolToInt(rb), nil
+	case same || o.isLogical():
+		return intOfDtype(int64(boolToInt(rb)), o.t), nil
+	}
+	return rb, nil
+}
+
+var dtypePromotion = struct {
+	sync.Mutex
+	allowed bool
+}{}
+
+// AllowDtypePromotion allows or disallows the promotion of Float32 operands to Float64 when a tensor binary operation is carried out
+// on a Float32 and a Float64 operand. It is disallowed by default, and such an operation is an error.
+//
+// When it is allowed, the Float32 operand is converted to a Float64 (a copy is made), and the result is a Float64.
+// Note that the types of the nodes of a graph are still checked strictly - the promotion only happens to the values that the ops are run on.
+func AllowDtypePromotion(allow bool) {
+	dtypePromotion.Lock()
+	dtypePromotion.allowed = allow
+	dtypePromotion.Unlock()
+}
+
+// dtypePromotionAllowed returns true if AllowDtypePromotion(true) has been called
+func dtypePromotionAllowed() bool {
+	dtypePromotion.Lock()
+	defer dtypePromotion.Unlock()
+	return dtypePromotion.allowed
+}
+
+// promoteToFloat64 converts a Float32 Scalar or Tensor into a Float64 one. Other values are returned as is.
+func promoteToFloat64(v Value) Value {
+	switch vt := v.(type) {
+	case Scalar:
+		if f, ok := vt.v.(float32); ok {
+			return NewScalarValue(float64(f))
+		}
+	case Tensor:
+		if t, ok := vt.Tensor.(*tf32.Tensor); ok {
+			data := f32sToF64s(materializedF32s(t))
+			return FromTensor(tf64.NewTensor(tf64.WithBacking(data), tf64.WithShape(t.Shape().Clone()...)))
+		}
+	}
+	return v
+}
+
 type tBinOp struct {
 	ʘBinaryOperatorType
 	tensorLeft bool
+	asInt      bool // for comparison ops, return an Int tensor of 1s and 0s instead of a Bool tensor. Takes precedence over same
 }
 
 func (o tBinOp) binOpType() ʘBinaryOperatorType { return o.ʘBinaryOperatorType }
 func (o tBinOp) String() string                 { return o.ʘBinaryOperatorType.String() }
 func (o tBinOp) isArith() bool                  { return o.ʘBinaryOperatorType.isArith() }
+func (o tBinOp) isLogical() bool                { return o.ʘBinaryOperatorType.isLogical() }
+func (o tBinOp) returnsInt() bool               { return o.asInt && !o.isArith() && !o.isLogical() }
 
 func (o tBinOp) Do(same bool, inputs ...Value) (Value, error) {
-	if same {
+	if same && !o.returnsInt() {
 		return o.do(inputs, types.AsSameType())
 	}
 	return o.do(inputs)
@@ -193,7 +338,14 @@ func (o tBinOp) do(vals []Value, opts ...types.FuncOpt) (retVal Value, err error
 	d1 := vals[1].Dtype()
 
 	if d0 != d1 {
-		return nil, errors.Errorf("Dtype mismatch for bin op: %v and %v", d0, d1)
+		floats := (d0 == Float32 && d1 == Float64) || (d0 == Float64 && d1 == Float32)
+		if !floats || !dtypePromotionAllowed() {
+			return nil, errors.Errorf("Dtype mismatch for bin op: %v and %v", d0, d1)
+		}
+
+		// the promoted operand is a copy, so it's fine to overwrite it in an unsafe operation
+		vals = []Value{promoteToFloat64(vals[0]), promoteToFloat64(vals[1])}
+		d0 = Float64
 	}
 
 	// extract the goddamn values
@@ -230,11 +382,21 @@ func (o tBinOp) do(vals []Value, opts ...types.FuncOpt) (retVal Value, err error
 		}
 	}
 
+	// an Int result is made out of the Bool one. Being of another Dtype, it cannot be written into the operands or be incremented,
+	// so only a preallocated Int tensor is reused
+	var intReuse *ti.Tensor
+	if o.returnsInt() {
+		if intReuse, err = parseIntResultOpts(opts...); err != nil {
+			return
+		}
+		opts = nil
+	}
+
 	var r interface{}
 	switch d0 {
 	case Float64:
 		// get function, call function
-		if o.isArith() {
+		if o.isArith() || o.isLogical() {
 			fn := tf64BinOps[o.ʘBinaryOperatorType]
 			if fn == nil {
 				return nil, errors.Errorf("nil function returned for %v", o.ʘBinaryOperatorType)
@@ -253,7 +415,7 @@ func (o tBinOp) do(vals []Value, opts ...types.FuncOpt) (retVal Value, err error
 		}
 	case Float32:
 		// get function, call function
-		if o.isArith() {
+		if o.isArith() || o.isLogical() {
 			fn := tf32BinOps[o.ʘBinaryOperatorType]
 			if fn == nil {
 				return nil, errors.Errorf("nil function returned for %v", o.ʘBinaryOperatorType)
@@ -270,13 +432,371 @@ func (o tBinOp) do(vals []Value, opts ...types.FuncOpt) (retVal Value, err error
 				return nil, errors.Wrap(err, "Calling the function failed")
 			}
 		}
+	case Bool:
+		fn := tbBinOps[o.ʘBinaryOperatorType]
+		if fn == nil {
+			return nil, errors.Errorf("nil function returned for %v", o.ʘBinaryOperatorType)
+		}
+		if r, err = (*fn)(a, b, opts...); err != nil {
+			return nil, errors.Wrap(err, "Calling the function failed")
+		}
+	case Int:
+		if !o.isArith() {
+			return nil, errors.Errorf(nyiFail, "tBinOp.do() - Int", o.ʘBinaryOperatorType)
+		}
+		if r, err = tiArith(o.ʘBinaryOperatorType, a, b, opts...); err != nil {
+			return nil, errors.Wrapf(err, doFail, o)
+		}
 	default:
 		return nil, errors.Errorf(nyiFail, "tBinOp.do()", d0)
 	}
 
+	if o.returnsInt() {
+		if r, err = boolTensorToInts(r, intReuse); err != nil {
+			return nil, errors.Wrapf(err, doFail, o)
+		}
+	}
 	return anyToValue(r)
 }
 
+// parseIntResultOpts returns the preallocated Int tensor to write the result of a comparison into, if there is one.
+// Incrementing is not supported, as the result of a comparison is not a quantity to be accumulated.
+func parseIntResultOpts(opts ...types.FuncOpt) (reuse *ti.Tensor, err error) {
+	for _, opt := range opts {
+		flag, v := opt()
+		switch flag {
+		case types.Reuse:
+			var ok bool
+			if reuse, ok = v.(*ti.Tensor); !ok {
+				return nil, errors.Errorf("Expected reuse to be *ti.Tensor. Got %T instead", v)
+			}
+		case types.Incr:
+			return nil, errors.New("Cannot increment by the Int result of a comparison")
+		}
+	}
+	return
+}
+
+// boolTensorToInts converts the Bool tensor a comparison returns into an Int tensor of 1s and 0s, which is written into reuse if it is not nil
+func boolTensorToInts(r interface{}, reuse *ti.Tensor) (retVal *ti.Tensor, err error) {
+	bt, ok := r.(*tb.Tensor)
+	if !ok {
+		return nil, errors.Errorf("Expected the comparison to return a *tb.Tensor. Got %T instead", r)
+	}
+
+	bools := bt.Materialize().(*tb.Tensor).Data().([]bool)
+	if reuse == nil {
+		reuse = ti.NewTensor(ti.WithShape(bt.Shape().Clone()...))
+	} else if reuse.Shape().TotalSize() != len(bools) {
+		return nil, errors.Errorf("Expected reuse to have the same size as the operands. Got %v and %v instead", reuse.Shape(), bt.Shape())
+	}
+
+	data := reuse.Data().([]int)
+	for i, b := range bools {
+		data[i] = boolToInt(b)
+	}
+	return reuse, nil
+}
+
+// boolToInt returns 1 for true and 0 for false
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+/* LOGICAL OPERATORS */
+
+// tf64LogicalOp creates a logical operator that works on *tf64.Tensor and float64 operands.
+// Any non-zero value is considered true. The result is 1 for true and 0 for false.
+func tf64LogicalOp(op ʘBinaryOperatorType) tf64BinOp {
+	return func(a, b interface{}, opts ...types.FuncOpt) (retVal *tf64.Tensor, err error) {
+		var as, bs []float64
+		var t *tf64.Tensor
+		if as, bs, t, err = tf64LogicalOperands(a, b); err != nil {
+			return
+		}
+
+		var unsafe bool
+		var reuse types.Tensor
+		if unsafe, reuse, err = parseLogicalOpts(opts...); err != nil {
+			return
+		}
+
+		switch {
+		case reuse != nil:
+			var ok bool
+			if retVal, ok = reuse.(*tf64.Tensor); !ok {
+				return nil, errors.Errorf("Expected reuse to be *tf64.Tensor. Got %T instead", reuse)
+			}
+			if retVal.Shape().TotalSize() != t.Shape().TotalSize() {
+				return nil, errors.Errorf("Expected reuse to have the same size as the operands. Got %v and %v instead", retVal.Shape(), t.Shape())
+			}
+		case unsafe:
+			retVal = t
+		default:
+			retVal = tf64.NewTensor(tf64.WithShape(t.Shape().Clone()...))
+		}
+
+		data := retVal.Data().([]float64)[:t.Shape().TotalSize()]
+		for i := range data {
+			var av, bv float64
+			if len(as) == 1 {
+				av = as[0]
+			} else {
+				av = as[i]
+			}
+			if len(bs) == 1 {
+				bv = bs[0]
+			} else {
+				bv = bs[i]
+			}
+
+			if logical(op, av != 0, bv != 0) {
+				data[i] = 1
+			} else {
+				data[i] = 0
+			}
+		}
+		return
+	}
+}
+
+// tf32LogicalOp creates a logical operator that works on *tf32.Tensor and float32 operands.
+// Any non-zero value is considered true. The result is 1 for true and 0 for false.
+func tf32LogicalOp(op ʘBinaryOperatorType) tf32BinOp {
+	return func(a, b interface{}, opts ...types.FuncOpt) (retVal *tf32.Tensor, err error) {
+		var as, bs []float32
+		var t *tf32.Tensor
+		if as, bs, t, err = tf32LogicalOperands(a, b); err != nil {
+			return
+		}
+
+		var unsafe bool
+		var reuse types.Tensor
+		if unsafe, reuse, err = parseLogicalOpts(opts...); err != nil {
+			return
+		}
+
+		switch {
+		case reuse != nil:
+			var ok bool
+			if retVal, ok = reuse.(*tf32.Tensor); !ok {
+				return nil, errors.Errorf("Expected reuse to be *tf32.Tensor. Got %T instead", reuse)
+			}
+			if retVal.Shape().TotalSize() != t.Shape().TotalSize() {
+				return nil, errors.Errorf("Expected reuse to have the same size as the operands. Got %v and %v instead", retVal.Shape(), t.Shape())
+			}
+		case unsafe:
+			retVal = t
+		default:
+			retVal = tf32.NewTensor(tf32.WithShape(t.Shape().Clone()...))
+		}
+
+		data := retVal.Data().([]float32)[:t.Shape().TotalSize()]
+		for i := range data {
+			var av, bv float32
+			if len(as) == 1 {
+				av = as[0]
+			} else {
+				av = as[i]
+			}
+			if len(bs) == 1 {
+				bv = bs[0]
+			} else {
+				bv = bs[i]
+			}
+
+			if logical(op, av != 0, bv != 0) {
+				data[i] = 1
+			} else {
+				data[i] = 0
+			}
+		}
+		return
+	}
+}
+
+// tbLogicalOp creates a logical operator that works on *tb.Tensor and bool operands.
+func tbLogicalOp(op ʘBinaryOperatorType) tbBinOp {
+	return func(a, b interface{}, opts ...types.FuncOpt) (retVal *tb.Tensor, err error) {
+		var as, bs []bool
+		var t *tb.Tensor
+		if as, bs, t, err = tbLogicalOperands(a, b); err != nil {
+			return
+		}
+
+		var unsafe bool
+		var reuse types.Tensor
+		if unsafe, reuse, err = parseLogicalOpts(opts...); err != nil {
+			return
+		}
+
+		switch {
+		case reuse != nil:
+			var ok bool
+			if retVal, ok = reuse.(*tb.Tensor); !ok {
+				return nil, errors.Errorf("Expected reuse to be *tb.Tensor. Got %T instead", reuse)
+			}
+			if retVal.Shape().TotalSize() != t.Shape().TotalSize() {
+				return nil, errors.Errorf("Expected reuse to have the same size as the operands. Got %v and %v instead", retVal.Shape(), t.Shape())
+			}
+		case unsafe:
+			retVal = t
+		default:
+			retVal = tb.NewTensor(tb.WithShape(t.Shape().Clone()...))
+		}
+
+		data := retVal.Data().([]bool)[:t.Shape().TotalSize()]
+		for i := range data {
+			var av, bv bool
+			if len(as) == 1 {
+				av = as[0]
+			} else {
+				av = as[i]
+			}
+			if len(bs) == 1 {
+				bv = bs[0]
+			} else {
+				bv = bs[i]
+			}
+			data[i] = logical(op, av, bv)
+		}
+		return
+	}
+}
+
+func logical(op ʘBinaryOperatorType, a, b bool) bool {
+	switch op {
+	case andOpType:
+		return a && b
+	case orOpType:
+		return a || b
+	case xorOpType:
+		return a != b
+	}
+	panic(fmt.Sprintf("%v is not a logical operator", op))
+}
+
+// parseLogicalOpts parses the FuncOpts supported by the logical operators. Incrementing a logical value makes no sense, so it is an error.
+func parseLogicalOpts(opts ...types.FuncOpt) (unsafe bool, reuse types.Tensor, err error) {
+	for _, opt := range opts {
+		flag, val := opt()
+		switch flag {
+		case types.UnsafeOp:
+			unsafe = true
+		case types.Reuse:
+			reuse = val.(types.Tensor)
+		case types.Incr:
+			return false, nil, errors.New("Logical operators cannot be incremented")
+		}
+	}
+	return
+}
+
+// tf64LogicalOperands extracts the data of the operands. t is the tensor operand, which determines the shape of the result.
+// If both operands are tensors, their shapes have to match.
+func tf64LogicalOperands(a, b interface{}) (as, bs []float64, t *tf64.Tensor, err error) {
+	switch at := a.(type) {
+	case *tf64.Tensor:
+		as = at.Data().([]float64)
+		t = at
+	case float64:
+		as = []float64{at}
+	default:
+		return nil, nil, nil, errors.Errorf(nyiFail, "tf64LogicalOp", a)
+	}
+
+	switch bt := b.(type) {
+	case *tf64.Tensor:
+		if t != nil && !t.Shape().Eq(bt.Shape()) {
+			return nil, nil, nil, errors.Errorf("Shape mismatch: %v and %v", t.Shape(), bt.Shape())
+		}
+		bs = bt.Data().([]float64)
+		if t == nil {
+			t = bt
+		}
+	case float64:
+		bs = []float64{bt}
+	default:
+		return nil, nil, nil, errors.Errorf(nyiFail, "tf64LogicalOp", b)
+	}
+
+	if t == nil {
+		return nil, nil, nil, errors.New("Expected at least one of the operands to be a *tf64.Tensor")
+	}
+	return
+}
+
+// tf32LogicalOperands extracts the data of the operands. t is the tensor operand, which determines the shape of the result.
+// If both operands are tensors, their shapes have to match.
+func tf32LogicalOperands(a, b interface{}) (as, bs []float32, t *tf32.Tensor, err error) {
+	switch at := a.(type) {
+	case *tf32.Tensor:
+		as = at.Data().([]float32)
+		t = at
+	case float32:
+		as = []float32{at}
+	default:
+		return nil, nil, nil, errors.Errorf(nyiFail, "tf32LogicalOp", a)
+	}
+
+	switch bt := b.(type) {
+	case *tf32.Tensor:
+		if t != nil && !t.Shape().Eq(bt.Shape()) {
+			return nil, nil, nil, errors.Errorf("Shape mismatch: %v and %v", t.Shape(), bt.Shape())
+		}
+		bs = bt.Data().([]float32)
+		if t == nil {
+			t = bt
+		}
+	case float32:
+		bs = []float32{bt}
+	default:
+		return nil, nil, nil, errors.Errorf(nyiFail, "tf32LogicalOp", b)
+	}
+
+	if t == nil {
+		return nil, nil, nil, errors.New("Expected at least one of the operands to be a *tf32.Tensor")
+	}
+	return
+}
+
+// tbLogicalOperands extracts the data of the operands. t is the tensor operand, which determines the shape of the result.
+// If both operands are tensors, their shapes have to match.
+func tbLogicalOperands(a, b interface{}) (as, bs []bool, t *tb.Tensor, err error) {
+	switch at := a.(type) {
+	case *tb.Tensor:
+		as = at.Data().([]bool)
+		t = at
+	case bool:
+		as = []bool{at}
+	default:
+		return nil, nil, nil, errors.Errorf(nyiFail, "tbLogicalOp", a)
+	}
+
+	switch bt := b.(type) {
+	case *tb.Tensor:
+		if t != nil && !t.Shape().Eq(bt.Shape()) {
+			return nil, nil, nil, errors.Errorf("Shape mismatch: %v and %v", t.Shape(), bt.Shape())
+		}
+		bs = bt.Data().([]bool)
+		if t == nil {
+			t = bt
+		}
+	case bool:
+		bs = []bool{bt}
+	default:
+		return nil, nil, nil, errors.Errorf(nyiFail, "tbLogicalOp", b)
+	}
+
+	if t == nil {
+		return nil, nil, nil, errors.New("Expected at least one of the operands to be a *tb.Tensor")
+	}
+	return
+}
+
 // type binDiffFn func(x, y, z, gradZ *Node) (Nodes, err error)
 
 func addDiffExpr(x, y, z, gradZ *Node) (retVal Nodes, err error) {
@@ -507,13 +1027,134 @@ func hadamardDivDiff(x, y, z *Node) (err error) {
 	return nil
 }
 
-// TODO: go back in time, pay more attention to calculus class in high school and learn how to differentiate x^y
-func hadamardPowDiffExpr(x, y, z, grad *Node) (retVal Nodes, err error) {
-	return nil, errors.New("hadamardPowDiffExpr not yet implemented")
+// hadamardPowDiffExpr differentiates z = x^y:
+//		dz/dx = y × x^(y-1) × gradZ
+//		dz/dy = z × ln(x) × gradZ
+// The gradient of a constant exponent is not computed, as ln(x) is NaN wherever x is negative, which is where integral powers are usually taken.
+func hadamardPowDiffExpr(x, y, z, gradZ *Node) (retVal Nodes, err error) {
+	var one *Node
+	if one, err = powDiffOne(y); err != nil {
+		return
+	}
+
+	var dzdx, dzdy *Node
+	if dzdx, err = Sub(y, one); err != nil {
+		return nil, errors.Wrap(err, "Failed to carry Sub()")
+	}
+	WithGroupName(gradClust)(dzdx)
+	if dzdx, err = Pow(x, dzdx); err != nil {
+		return nil, errors.Wrap(err, "Failed to carry Pow()")
+	}
+	WithGroupName(gradClust)(dzdx)
+	if dzdx, err = HadamardProd(y, dzdx); err != nil {
+		return nil, errors.Wrap(err, "Failed to carry HadamardProd()")
+	}
+	WithGroupName(gradClust)(dzdx)
+	if dzdx, err = HadamardProd(dzdx, gradZ); err != nil {
+		return nil, errors.Wrap(err, "Failed to carry HadamardProd()")
+	}
+	WithGroupName(gradClust)(dzdx)
+
+	if y.isConstant() {
+		return Nodes{dzdx, nil}, nil
+	}
+
+	if dzdy, err = Log(x); err != nil {
+		return nil, errors.Wrap(err, "Failed to carry Log()")
+	}
+	WithGroupName(gradClust)(dzdy)
+	if dzdy, err = HadamardProd(z, dzdy); err != nil {
+		return nil, errors.Wrap(err, "Failed to carry HadamardProd()")
+	}
+	WithGroupName(gradClust)(dzdy)
+	if dzdy, err = HadamardProd(dzdy, gradZ); err != nil {
+		return nil, errors.Wrap(err, "Failed to carry HadamardProd()")
+	}
+	WithGroupName(gradClust)(dzdy)
+	return Nodes{dzdx, dzdy}, nil
 }
 
 func hadamardPowDiff(x, y, z *Node) (err error) {
-	return errors.New("hadamardPowDiff not yet implemented")
+	xdv := x.boundTo.(*dualValue)
+	ydv := y.boundTo.(*dualValue)
+	zdv := z.boundTo.(*dualValue)
+
+	var one *Node
+	if one, err = powDiffOne(y); err != nil {
+		return
+	}
+	oneV := one.Value()
+
+	// dzdx = y × x^(y-1) × dz
+	var d Value
+	sub := newEBOByType(subOpType, ydv.Value.Type(), oneV.Type())
+	if d, err = sub.Do(ydv.Value, oneV); err != nil {
+		return errors.Wrapf(err, doFail, sub)
+	}
+	pow := newEBOByType(powOpType, xdv.Value.Type(), d.Type())
+	if d, err = pow.Do(xdv.Value, d); err != nil {
+		return errors.Wrapf(err, doFail, pow)
+	}
+	mul := newEBOByType(mulOpType, ydv.Value.Type(), d.Type())
+	if d, err = mul.Do(ydv.Value, d); err != nil {
+		return errors.Wrapf(err, doFail, mul)
+	}
+
+	mul = newEBOByType(mulOpType, d.Type(), zdv.d.Type())
+	err = mul.IncrDo(xdv.d, d, zdv.d)
+	if err != nil {
+		var ver Valuer
+		var ok bool
+		if ver, ok = err.(Valuer); !ok {
+			return
+		}
+
+		xdv.SetDeriv(ver.Value()) // ignore errors on purpose
+	}
+
+	if y.isConstant() {
+		return nil
+	}
+
+	// dzdy = z × ln(x) × dz
+	ln := newElemUnaryOp(lnOpType, x)
+	if d, err = ln.Do(xdv.Value); err != nil {
+		return errors.Wrapf(err, doFail, ln)
+	}
+	mul = newEBOByType(mulOpType, zdv.Value.Type(), d.Type())
+	if d, err = mul.Do(zdv.Value, d); err != nil {
+		return errors.Wrapf(err, doFail, mul)
+	}
+
+	mul = newEBOByType(mulOpType, d.Type(), zdv.d.Type())
+	err = mul.IncrDo(ydv.d, d, zdv.d)
+	if err != nil {
+		var ver Valuer
+		var ok bool
+		if ver, ok = err.(Valuer); !ok {
+			return
+		}
+
+		ydv.SetDeriv(ver.Value()) // ignore errors on purpose
+	}
+
+	return nil
+}
+
+// powDiffOne is the constant 1 in the Dtype of the exponent
+func powDiffOne(y *Node) (retVal *Node, err error) {
+	var dt Dtype
+	if dt, err = dtypeOf(y.t); err != nil {
+		return nil, errors.Wrap(err, dtypeOfFail)
+	}
+
+	switch dt {
+	case Float64:
+		return onef64, nil
+	case Float32:
+		return onef32, nil
+	}
+	return nil, errors.Errorf(nyiFail, "hadamardPowDiff", dt)
 }
 
 func nondiffBinOpExpr(x, y, z, grad *Node) (retVal Nodes, err error) {
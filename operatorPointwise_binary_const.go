@@ -1,6 +1,7 @@
 package gorgonia
 
 import (
+	tb "github.com/chewxy/gorgonia/tensor/b"
 	tf64 "github.com/chewxy/gorgonia/tensor/f64"
 	"github.com/chewxy/gorgonia/tensor/types"
 )
@@ -24,6 +25,11 @@ var (
 	teqf64  = tf64CmpOp(tf64.Eq)
 	tnef64  = tf64CmpOp(tf64.Ne)
 
+	// logical
+	tandf64 = tf64LogicalOp(andOpType)
+	torf64  = tf64LogicalOp(orOpType)
+	txorf64 = tf64LogicalOp(xorOpType)
+
 	/* tf32 */
 
 	taddf32 = tf32BinOp(tf32.Add)
@@ -39,12 +45,24 @@ var (
 	tgtef32 = tf32CmpOp(tf32.Gte)
 	teqf32  = tf32CmpOp(tf32.Eq)
 	tnef32  = tf32CmpOp(tf32.Ne)
+
+	// logical
+	tandf32 = tf32LogicalOp(andOpType)
+	torf32  = tf32LogicalOp(orOpType)
+	txorf32 = tf32LogicalOp(xorOpType)
+
+	/* tb */
+
+	tandb = tbLogicalOp(andOpType)
+	torb  = tbLogicalOp(orOpType)
+	txorb = tbLogicalOp(xorOpType)
 )
 
 type tf32BinOp func(a, b interface{}, opts ...types.FuncOpt) (*tf32.Tensor, error)
 type tf32CmpOp func(a, b interface{}, opts ...types.FuncOpt) (types.Tensor, error)
 type tf64BinOp func(a, b interface{}, opts ...types.FuncOpt) (*tf64.Tensor, error)
 type tf64CmpOp func(a, b interface{}, opts ...types.FuncOpt) (types.Tensor, error)
+type tbBinOp func(a, b interface{}, opts ...types.FuncOpt) (*tb.Tensor, error)
 
 type ʘBinaryOperatorType byte
 
@@ -64,6 +82,11 @@ const (
 	eqOpType
 	neOpType
 
+	// logical
+	andOpType
+	orOpType
+	xorOpType
+
 	maxʘBinaryOpType // delimits the end of all possible binOpType
 )
 
@@ -88,6 +111,11 @@ var ʘBinOpStrs = [maxʘBinaryOpType]string{
 	">=",
 	"==",
 	"!=",
+
+	// logical ops
+	"∧",
+	"∨",
+	"⊕",
 }
 
 // ʘBinOpCommutative is the array that stores whether a binary operator is commutative
@@ -95,16 +123,19 @@ var ʘBinOpStrs = [maxʘBinaryOpType]string{
 var ʘBinOpCommutative = [maxʘBinaryOpType]bool{
 	true, false, true, false, false,
 	false, false, false, false, true, true,
+	true, true, true,
 }
 
 var ʘBinOpDiffExprs = [maxʘBinaryOpType]func(x, y, z, gradZ *Node) (Nodes, error){
 	addDiffExpr, subDiffExpr, hadamardProdDiffExpr, hadamardDivDiffExpr, hadamardPowDiffExpr,
 	nondiffBinOpExpr, nondiffBinOpExpr, nondiffBinOpExpr, nondiffBinOpExpr, nondiffBinOpExpr, nondiffBinOpExpr,
+	nondiffBinOpExpr, nondiffBinOpExpr, nondiffBinOpExpr,
 }
 
 var ʘBinOpDiffFns = [maxʘBinaryOpType]func(x, y, z *Node) error{
 	addDiff, subDiff, hadamardProdDiff, hadamardDivDiff, hadamardPowDiff,
 	nondiffBinOp, nondiffBinOp, nondiffBinOp, nondiffBinOp, nondiffBinOp, nondiffBinOp,
+	nondiffBinOp, nondiffBinOp, nondiffBinOp,
 }
 
 // isCommutative gives info about whether the operator is commutative
@@ -143,6 +174,16 @@ func (b ʘBinaryOperatorType) isArith() bool {
 	return false
 }
 
+// isLogical indicates if the binary operator is a logical operator. Logical operators return the same type as their inputs
+func (b ʘBinaryOperatorType) isLogical() bool {
+	switch b {
+	case andOpType, orOpType, xorOpType:
+		return true
+	default:
+		return false
+	}
+}
+
 var tf64BinOps = [maxʘBinaryOpType]*tf64BinOp{
 	&taddf64,
 	&tsubf64,
@@ -155,6 +196,9 @@ var tf64BinOps = [maxʘBinaryOpType]*tf64BinOp{
 	nil, // gte
 	nil, // eq
 	nil, // ne
+	&tandf64,
+	&torf64,
+	&txorf64,
 }
 
 var tf64CmpOps = [maxʘBinaryOpType]*tf64CmpOp{
@@ -169,6 +213,9 @@ var tf64CmpOps = [maxʘBinaryOpType]*tf64CmpOp{
 	&tgtef64,
 	&teqf64,
 	&tnef64,
+	nil, // and
+	nil, // or
+	nil, // xor
 }
 
 var tf32BinOps = [maxʘBinaryOpType]*tf32BinOp{
@@ -183,6 +230,9 @@ var tf32BinOps = [maxʘBinaryOpType]*tf32BinOp{
 	nil, // gte
 	nil, // eq
 	nil, // ne
+	&tandf32,
+	&torf32,
+	&txorf32,
 }
 
 var tf32CmpOps = [maxʘBinaryOpType]*tf32CmpOp{
@@ -197,4 +247,24 @@ var tf32CmpOps = [maxʘBinaryOpType]*tf32CmpOp{
 	&tgtef32,
 	&teqf32,
 	&tnef32,
+	nil, // and
+	nil, // or
+	nil, // xor
+}
+
+var tbBinOps = [maxʘBinaryOpType]*tbBinOp{
+	nil, // add
+	nil, // sub
+	nil, // mul
+	nil, // div
+	nil, // pow
+	nil, // lt
+	nil, // gt
+	nil, // lte
+	nil, // gte
+	nil, // eq
+	nil, // ne
+	&tandb,
+	&torb,
+	&txorb,
 }
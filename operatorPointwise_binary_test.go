@@ -4,6 +4,8 @@ import (
 	"math/rand"
 	"testing"
 
+	tb "github.com/chewxy/gorgonia/tensor/b"
+	tf32 "github.com/chewxy/gorgonia/tensor/f32"
 	tf64 "github.com/chewxy/gorgonia/tensor/f64"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -399,3 +401,213 @@ func TestHadamardDivDiff(t *testing.T) {
 	assert.True(floatsEqual(dzdy, extractF64s(ydv.d)))
 
 }
+
+func TestLogicalOps(t *testing.T) {
+	assert := assert.New(t)
+
+	as := []bool{true, true, false, false}
+	bs := []bool{true, false, true, false}
+	correct := map[ʘBinaryOperatorType][]bool{
+		andOpType: {true, false, false, false},
+		orOpType:  {true, true, true, false},
+		xorOpType: {false, true, true, false},
+	}
+	fns := map[ʘBinaryOperatorType]func(a, b *Node) (*Node, error){
+		andOpType: And,
+		orOpType:  Or,
+		xorOpType: Xor,
+	}
+
+	for ot, fn := range fns {
+		// bool backed
+		g := NewGraph()
+		a := NewVector(g, Bool, WithName("a"), WithShape(4), WithValue(tb.NewTensor(tb.WithBacking(as), tb.WithShape(4))))
+		b := NewVector(g, Bool, WithName("b"), WithShape(4), WithValue(tb.NewTensor(tb.WithBacking(bs), tb.WithShape(4))))
+		c := Must(fn(a, b))
+		assert.True(typeEq(a.t, c.t), "%v: expected the result to have the same type as the inputs. Got %v", ot, c.t)
+
+		prog, locMap, err := Compile(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := NewTapeMachine(prog, locMap)
+		if err = m.RunAll(); err != nil {
+			t.Fatalf("%v: %v", ot, err)
+		}
+		assert.Equal(correct[ot], c.Value().(Tensor).Tensor.Data(), "%v on bools", ot)
+
+		// numeric 1/0 representation. Any non zero value is true
+		g = NewGraph()
+		af := NewVector(g, Float64, WithName("a"), WithShape(4), WithValue(tf64.NewTensor(tf64.WithBacking([]float64{1, 2, 0, 0}), tf64.WithShape(4))))
+		bf := NewVector(g, Float64, WithName("b"), WithShape(4), WithValue(tf64.NewTensor(tf64.WithBacking([]float64{-1, 0, 1, 0}), tf64.WithShape(4))))
+		cf := Must(fn(af, bf))
+
+		prog, locMap, err = Compile(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m = NewTapeMachine(prog, locMap)
+		if err = m.RunAll(); err != nil {
+			t.Fatalf("%v: %v", ot, err)
+		}
+		assert.Equal(boolsToF64s(correct[ot]), extractF64s(cf.Value()), "%v on float64s", ot)
+
+		// scalars
+		op := newEBOByType(ot, Bool, Bool)
+		for i := range as {
+			v, err := op.Do(NewScalarValue(as[i]), NewScalarValue(bs[i]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(correct[ot][i], v.(Scalar).v, "%v on scalar bools %v and %v", ot, as[i], bs[i])
+		}
+
+		op = newEBOByType(ot, Float32, Float32)
+		v, err := op.Do(NewScalarValue(float32(3)), NewScalarValue(float32(0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(boolsToF64s(correct[ot])[1], float64(v.(Scalar).v.(float32)), "%v on scalar float32s", ot)
+	}
+
+	// tensor-scalar
+	op := newEBOByType(andOpType, newTensorType(1, Bool), Bool)
+	v, err := op.Do(FromTensor(tb.NewTensor(tb.WithBacking(as), tb.WithShape(4))), NewScalarValue(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(as, v.(Tensor).Tensor.Data())
+
+	// logical ops are not differentiable
+	assert.Equal([]bool{false, false}, op.DiffWRT(2))
+}
+
+func boolsToF64s(bs []bool) []float64 {
+	retVal := make([]float64, len(bs))
+	for i, b := range bs {
+		if b {
+			retVal[i] = 1
+		}
+	}
+	return retVal
+}
+
+func TestAllowDtypePromotion(t *testing.T) {
+	assert := assert.New(t)
+	defer AllowDtypePromotion(false)
+
+	add := tBinOp{ʘBinaryOperatorType: addOpType, tensorLeft: true}
+	backing := []float32{1, 2, 3}
+	a := FromTensor(tf32.NewTensor(tf32.WithBacking(backing), tf32.WithShape(3)))
+	b := FromTensor(tf64.NewTensor(tf64.WithBacking([]float64{0.5, 0.25, 0.125}), tf64.WithShape(3)))
+	s := NewScalarValue(float32(10))
+
+	// strict by default
+	if _, err := add.Do(false, a, b); err == nil {
+		t.Error("Expected an error adding a Float32 tensor to a Float64 tensor")
+	}
+
+	AllowDtypePromotion(true)
+	correct := []float64{1.5, 2.25, 3.125}
+	for _, vals := range [][]Value{{a, b}, {b, a}} {
+		ret, err := add.Do(false, vals...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(Float64, ret.Dtype())
+		assert.Equal(correct, extractF64s(ret))
+	}
+
+	// unsafe operations overwrite the promoted copy, not the original
+	ret, err := add.UnsafeDo(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(correct, extractF64s(ret))
+	assert.Equal([]float32{1, 2, 3}, backing)
+
+	// scalars are promoted too
+	if ret, err = add.Do(false, b, s); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]float64{10.5, 10.25, 10.125}, extractF64s(ret))
+
+	// only floats are promoted
+	i := NewScalarValue(1)
+	if _, err = add.Do(false, b, i); err == nil {
+		t.Error("Expected an error adding an Int to a Float64 tensor")
+	}
+
+	AllowDtypePromotion(false)
+	if _, err = add.Do(false, b, a); err == nil {
+		t.Error("Expected an error once promotion is disallowed again")
+	}
+}
+
+func TestIntComparison(t *testing.T) {
+	assert := assert.New(t)
+
+	as := []float64{1, 5, 3, -2}
+	bs := []float64{2, 4, 3, -3}
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		a := NewVector(g, Float64, WithName("a"), WithShape(4), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(as)), tf64.WithShape(4))))
+		b := NewVector(g, Float64, WithName("b"), WithShape(4), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(bs)), tf64.WithShape(4))))
+
+		gt, err := GtInt(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gte, err := GteInt(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(typeEq(newTensorType(1, Int), gt.t), "Got %v", gt.t)
+		assert.True(typeEq(newTensorType(1, Int), gte.t), "Got %v", gte.t)
+
+		if useTape {
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = NewTapeMachine(prog, locMap).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			if err = NewLispMachine(g, ExecuteFwdOnly()).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.Equal([]int{0, 1, 0, 1}, gt.Value().Data(), "Tape %t", useTape)
+		assert.Equal([]int{0, 1, 1, 1}, gte.Value().Data(), "Tape %t", useTape)
+
+		// the operands are left alone
+		assert.Equal(as, extractF64s(a.Value()), "Tape %t", useTape)
+	}
+
+	// tensor-scalar, in float32
+	op := newEBOByType(gtOpType, newTensorType(1, Float32), Float32).withIntResult()
+	v, err := op.Do(FromTensor(tf32.NewTensor(tf32.WithBacking([]float32{1, 5, 3}), tf32.WithShape(3))), NewScalarValue(float32(2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]int{0, 1, 1}, v.Data())
+
+	// scalars
+	op = newEBOByType(gtOpType, Float64, Float64).withIntResult()
+	if v, err = op.Do(NewScalarValue(3.0), NewScalarValue(2.0)); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(1, v.Data())
+	assert.True(typeEq(Int, op.Type().(*functionType).retType()))
+
+	// a Bool and an Int result are different ops, and arithmetic is not affected
+	assert.NotEqual(newEBOByType(gtOpType, Float64, Float64).Hashcode(), op.Hashcode())
+	add := newEBOByType(addOpType, Float64, Float64).withIntResult()
+	if v, err = add.Do(NewScalarValue(3.0), NewScalarValue(2.0)); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(5.0, v.Data())
+}
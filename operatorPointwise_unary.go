@@ -6,9 +6,10 @@ import "github.com/pkg/errors"
 // pros : no overloading = clear understanding
 // cons : no overloading = a lot of extra code
 //
-// There are TWO ʘUnaryOperator types so far:
+// There are THREE ʘUnaryOperator types so far:
 //		sf32UnaryOperator - scalar float32 unary operator
 //		sf64UnaryOperator - scalar float64 unary operator
+//		sbUnaryOperator - scalar bool unary operator (logical operators only)
 //
 // Because *TensorTypes are parameterized by a scalar type, it isn't necessary to create operators
 // that will work on *TensorTypes. A simple type switch will do.
@@ -50,6 +51,8 @@ func (f *sf32UnaryOperator) unaryOpType() ʘUnaryOperatorType {
 		return sqrtOpType
 	case &inversef32:
 		return inverseOpType
+	case &rsqrtf32:
+		return rsqrtOpType
 	case &cubef32:
 		return cubeOpType
 	case &tanhf32:
@@ -62,6 +65,8 @@ func (f *sf32UnaryOperator) unaryOpType() ʘUnaryOperatorType {
 		return expm1OpType
 	case &softplusf32:
 		return softplusOpType
+	case &notf32:
+		return notOpType
 	}
 	return maxʘUnaryOperator
 }
@@ -98,6 +103,8 @@ func (f *sf64UnaryOperator) unaryOpType() ʘUnaryOperatorType {
 		return sqrtOpType
 	case &inversef64:
 		return inverseOpType
+	case &rsqrtf64:
+		return rsqrtOpType
 	case &cubef64:
 		return cubeOpType
 	case &tanhf64:
@@ -110,6 +117,8 @@ func (f *sf64UnaryOperator) unaryOpType() ʘUnaryOperatorType {
 		return expm1OpType
 	case &softplusf64:
 		return softplusOpType
+	case &notf64:
+		return notOpType
 	}
 
 	return maxʘUnaryOperator
@@ -117,6 +126,18 @@ func (f *sf64UnaryOperator) unaryOpType() ʘUnaryOperatorType {
 
 func (f *sf64UnaryOperator) String() string { return f.unaryOpType().String() }
 
+type sbUnaryOperator func(bool) bool
+
+func (f *sbUnaryOperator) unaryOpType() ʘUnaryOperatorType {
+	switch f {
+	case &notb:
+		return notOpType
+	}
+	return maxʘUnaryOperator
+}
+
+func (f *sbUnaryOperator) String() string { return f.unaryOpType().String() }
+
 /*
 DIFFERENTIATION EXPRESSIONS
 
@@ -366,12 +387,13 @@ func negDiff(x, y *Node) (err error) {
 	xdv := x.boundTo.(*dualValue)
 	ydv := y.boundTo.(*dualValue)
 
+	// ∂x += -∂y. Scalars are not modified in place by UnsafeDo, so the result has to be set as the derivative
 	sub := newElemBinOp(subOpType, x, y)
-	_, err = sub.UnsafeDo(xdv.d, ydv.d)
-	if ver, ok := err.(Valuer); ok {
-		return xdv.SetDeriv(ver.Value())
+	var d Value
+	if d, err = sub.UnsafeDo(xdv.d, ydv.d); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, sub)
 	}
-	return
+	return xdv.SetDeriv(d)
 }
 
 func squareDiffExpr(x, y, gradY *Node) (retVal *Node, err error) {
@@ -555,6 +577,85 @@ func inverseDiff(x, y *Node) (err error) {
 	return
 }
 
+// rsqrtDiffExpr differentiates y = 1/√x, whose derivative is -½ x^(-3/2), or -½ y³
+func rsqrtDiffExpr(x, y, gradY *Node) (retVal *Node, err error) {
+	var negHalf *Node
+	var dt Dtype
+
+	if dt, err = dtypeOf(x.t); err != nil {
+		return nil, errors.Wrap(err, dtypeOfFail)
+	}
+
+	switch dt {
+	case Float32:
+		negHalf = negHalff32
+	case Float64:
+		negHalf = negHalff64
+	default:
+		return nil, errors.Errorf("rsqrtDiffExpr does not handle Dtypes other than Float32 and Float64. Got %v instead", dt)
+	}
+
+	if retVal, err = Cube(y); err == nil {
+		WithGroupName(gradClust)(retVal)
+		if retVal, err = HadamardProd(retVal, negHalf); err == nil {
+			WithGroupName(gradClust)(retVal)
+			retVal, err = HadamardProd(retVal, gradY)
+			if err != nil {
+				return nil, errors.Wrap(err, hadamardProdFail)
+			}
+		} else {
+			return nil, errors.Wrap(err, hadamardProdFail)
+		}
+	} else {
+		return nil, errors.Wrap(err, operationError)
+	}
+	return
+}
+
+func rsqrtDiff(x, y *Node) (err error) {
+	xdv := x.boundTo.(*dualValue)
+	ydv := y.boundTo.(*dualValue)
+
+	var negHalf *Node
+	var dt Dtype
+
+	if dt, err = dtypeOf(x.t); err != nil {
+		return errors.Wrap(err, dtypeOfFail)
+	}
+
+	switch dt {
+	case Float32:
+		negHalf = negHalff32
+	case Float64:
+		negHalf = negHalff64
+	default:
+		return errors.Errorf("rsqrtDiff does not handle Dtypes other than Float32 and Float64. Got %v instead", dt)
+	}
+
+	cube := newElemUnaryOp(cubeOpType, y)
+
+	var d Value
+	if d, err = cube.Do(ydv.Value); err != nil {
+		return errors.Wrapf(err, doFail, cube)
+	}
+
+	if dT, ok := d.(Tensor); ok {
+		defer returnTensor(dT)
+	}
+
+	mul := newElemBinOp(mulOpType, x, y)
+	if d, err = mul.UnsafeDo(d, negHalf.boundTo); err != nil {
+		return errors.Wrapf(err, unsafeDoFail, mul)
+	}
+
+	err = mul.IncrDo(xdv.d, d, ydv.d)
+	if ver, ok := err.(Valuer); ok {
+		xdv.SetDeriv(ver.Value()) // ignore errors on purpose
+		return nil
+	}
+	return
+}
+
 func cubeDiffExpr(x, y, gradY *Node) (retVal *Node, err error) {
 	var three *Node
 	var dt Dtype
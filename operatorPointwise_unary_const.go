@@ -26,6 +26,7 @@ var (
 	squaref64  = sf64UnaryOperator(_squaref64)
 	sqrtf64    = sf64UnaryOperator(math.Sqrt)
 	inversef64 = sf64UnaryOperator(_inversef64)
+	rsqrtf64   = sf64UnaryOperator(_rsqrtf64)
 
 	// activation functions
 	cubef64    = sf64UnaryOperator(_cubef64)
@@ -39,6 +40,9 @@ var (
 	// softplus isn't necessarily only a numerical stabilization op
 	// (you can use it elsewhere), but I included it under numerical optimization
 
+	// logical
+	notf64 = sf64UnaryOperator(_notf64)
+
 	/* Float32 */
 
 	// non differentiable
@@ -57,6 +61,7 @@ var (
 	squaref32  = sf32UnaryOperator(_squaref32)
 	sqrtf32    = sf32UnaryOperator(math32.Sqrt)
 	inversef32 = sf32UnaryOperator(_inversef32)
+	rsqrtf32   = sf32UnaryOperator(_rsqrtf32)
 
 	// typically used in activation functions
 	cubef32    = sf32UnaryOperator(_cubef32)
@@ -67,6 +72,13 @@ var (
 	log1pf32    = sf32UnaryOperator(math32.Log1p)
 	expm1f32    = sf32UnaryOperator(math32.Expm1)
 	softplusf32 = sf32UnaryOperator(_softplusf32)
+
+	// logical
+	notf32 = sf32UnaryOperator(_notf32)
+
+	/* Bool */
+
+	notb = sbUnaryOperator(_notb)
 )
 
 type ʘUnaryOperatorType byte
@@ -87,6 +99,7 @@ const (
 	squareOpType
 	sqrtOpType
 	inverseOpType // multiplicative inverse
+	rsqrtOpType   // reciprocal square root
 
 	// typically used in activation functions
 	cubeOpType
@@ -98,6 +111,9 @@ const (
 	expm1OpType
 	softplusOpType
 
+	// logical
+	notOpType
+
 	maxʘUnaryOperator // delimits end of all possible unary ops
 )
 
@@ -115,9 +131,11 @@ var ʘUnaryOpStrs = [maxʘUnaryOperator]string{
 	"abs", "sign", "ceil", "floor",
 	"sin", "cos", "exp",
 	"ln", "log2", "neg", "sq", "sqrt",
-	"inv", "cube", "tanh", "sigmoid",
+	"inv", "rsqrt", "cube", "tanh", "sigmoid",
 
 	"log1p", "expm1", "softplus",
+
+	"¬",
 }
 
 // ʘUnaryOpDifferentiable is the array of whether a unary operator is differentiable
@@ -126,27 +144,33 @@ var ʘUnaryOpDifferentiable = [maxʘUnaryOperator]bool{
 	true, false, false, false,
 	true, true, true,
 	true, true, true, true, true,
-	true, true, true, true,
+	true, true, true, true, true,
 
 	true, true, true,
+
+	false,
 }
 
 var ʘUnaryOpDiffExprs = [maxʘUnaryOperator]func(x, y, gradY *Node) (*Node, error){
 	absDiffExpr, nondiffUnaryOpExpr, nondiffUnaryOpExpr, nondiffUnaryOpExpr,
 	sinDiffExpr, cosDiffExpr, expDiffExpr,
 	lnDiffExpr, log2DiffExpr, negDiffExpr, squareDiffExpr, sqrtDiffExpr,
-	inverseDiffExpr, cubeDiffExpr, tanhDiffExpr, sigmoidDiffExpr,
+	inverseDiffExpr, rsqrtDiffExpr, cubeDiffExpr, tanhDiffExpr, sigmoidDiffExpr,
 
 	log1pDiffExpr, expm1DiffExpr, softplusDiffExpr,
+
+	nondiffUnaryOpExpr,
 }
 
 var ʘUnaryOpDiffFns = [maxʘUnaryOperator]func(x, y *Node) error{
 	absDiff, nondiffUnaryOp, nondiffUnaryOp, nondiffUnaryOp,
 	sinDiff, cosDiff, expDiff,
 	lnDiff, log2Diff, negDiff, squareDiff, sqrtDiff,
-	inverseDiff, cubeDiff, tanhDiff, sigmoidDiff,
+	inverseDiff, rsqrtDiff, cubeDiff, tanhDiff, sigmoidDiff,
 
 	log1pDiff, expm1Diff, softplusDiff,
+
+	nondiffUnaryOp,
 }
 
 var sf64UnaryOperators = [maxʘUnaryOperator]*sf64UnaryOperator{
@@ -163,6 +187,7 @@ var sf64UnaryOperators = [maxʘUnaryOperator]*sf64UnaryOperator{
 	&squaref64,
 	&sqrtf64,
 	&inversef64,
+	&rsqrtf64,
 	&cubef64,
 	&tanhf64,
 	&sigmoidf64,
@@ -170,6 +195,8 @@ var sf64UnaryOperators = [maxʘUnaryOperator]*sf64UnaryOperator{
 	&log1pf64,
 	&expm1f64,
 	&softplusf64,
+
+	&notf64,
 }
 
 var sf32UnaryOperators = [maxʘUnaryOperator]*sf32UnaryOperator{
@@ -186,6 +213,7 @@ var sf32UnaryOperators = [maxʘUnaryOperator]*sf32UnaryOperator{
 	&squaref32,
 	&sqrtf32,
 	&inversef32,
+	&rsqrtf32,
 	&cubef32,
 	&tanhf32,
 	&sigmoidf32,
@@ -193,4 +221,11 @@ var sf32UnaryOperators = [maxʘUnaryOperator]*sf32UnaryOperator{
 	&log1pf32,
 	&expm1f32,
 	&softplusf32,
+
+	&notf32,
+}
+
+// sbUnaryOperators are the unary operators that are defined on Bools. Only logical operators are.
+var sbUnaryOperators = [maxʘUnaryOperator]*sbUnaryOperator{
+	notOpType: &notb,
 }
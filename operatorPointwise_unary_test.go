@@ -7,6 +7,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	tb "github.com/chewxy/gorgonia/tensor/b"
+	tf32 "github.com/chewxy/gorgonia/tensor/f32"
 	tf64 "github.com/chewxy/gorgonia/tensor/f64"
 )
 
@@ -149,6 +151,20 @@ func TestLog2Diff(t *testing.T) {
 	assert.Equal(correctT, xdvd.Data())
 }
 
+func TestNegDiff(t *testing.T) {
+	assert := assert.New(t)
+	_, x, _, xT, _, err := unaryOpDiffTest(negOpType)
+	if err != nil {
+		t.Error(err)
+	}
+
+	assert.Equal(-1.0, x.boundTo.(*dualValue).d.(Scalar).v)
+
+	// Tensor edition
+	xdvd := xT.boundTo.(*dualValue).d.(Tensor).Tensor.(*tf64.Tensor)
+	assert.Equal([]float64{-1, -1}, xdvd.Data())
+}
+
 func TestSquareDiff(t *testing.T) {
 	assert := assert.New(t)
 	var err error
@@ -202,6 +218,74 @@ func TestInverseDiff(t *testing.T) {
 	assert.Equal(correctT, xdvd.Data())
 }
 
+func TestRsqrtDiff(t *testing.T) {
+	assert := assert.New(t)
+
+	xs := []float64{0.25, 1, 2, 9}
+	ws := []float64{1, -2, 0.5, 3}
+
+	// cost = Σ w / √x
+	cost := func(x []float64) float64 {
+		var retVal float64
+		for i, v := range x {
+			retVal += ws[i] / math.Sqrt(v)
+		}
+		return retVal
+	}
+	cxs := clonef64s(xs)
+	correct := numericGrad(cxs, func() float64 { return cost(cxs) })
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		x := NewVector(g, Float64, WithName("x"), WithShape(4), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(4))))
+		w := NewVector(g, Float64, WithName("w"), WithShape(4), WithValue(tf64.NewTensor(tf64.WithBacking(ws), tf64.WithShape(4))))
+		c := Must(Sum(Must(HadamardProd(Must(Rsqrt(x)), w))))
+
+		if useTape {
+			if _, err := Grad(c, x); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err := m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.True(floatEquals(cost(xs), extractF64(c.Value())), "Tape %t: %v", useTape, c.Value())
+		dx, err := x.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(floatsClose(correct, extractF64s(dx)), "Tape %t. Expected %v. Got %v", useTape, correct, dx)
+	}
+
+	// the same as 1/√x, composed
+	g := NewGraph()
+	x := NewVector(g, Float64, WithName("x"), WithShape(4), WithValue(tf64.NewTensor(tf64.WithBacking(clonef64s(xs)), tf64.WithShape(4))))
+	x32 := NewVector(g, Float32, WithName("x32"), WithShape(4), WithValue(tf32.NewTensor(tf32.WithBacking(f64sToF32s(xs)), tf32.WithShape(4))))
+	rsqrt := Must(Rsqrt(x))
+	composed := Must(Inverse(Must(Sqrt(x))))
+	rsqrt32 := Must(Rsqrt(x32))
+	composed32 := Must(Inverse(Must(Sqrt(x32))))
+
+	m := NewLispMachine(g, ExecuteFwdOnly())
+	if err := m.RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(floatsClose([]float64{2, 1, 1 / math.Sqrt2, 1.0 / 3}, extractF64s(rsqrt.Value())), "%v", rsqrt.Value())
+	assert.True(floatsClose(extractF64s(composed.Value()), extractF64s(rsqrt.Value())))
+	assert.True(floatsClose(f32sToF64s(composed32.Value().Data().([]float32)), f32sToF64s(rsqrt32.Value().Data().([]float32))))
+}
+
 func TestCubeDiff(t *testing.T) {
 	assert := assert.New(t)
 	v, x, _, xT, _, err := unaryOpDiffTest(cubeOpType)
@@ -302,3 +386,104 @@ func TestSoftplusDiff(t *testing.T) {
 	correct0 := sigmoidf64(-v)
 	assert.Equal([]float64{correct0, correct}, xdvd.Data())
 }
+
+func TestNot(t *testing.T) {
+	assert := assert.New(t)
+
+	// bool backed
+	g := NewGraph()
+	x := NewVector(g, Bool, WithName("x"), WithShape(3), WithValue(tb.NewTensor(tb.WithBacking([]bool{true, false, true}), tb.WithShape(3))))
+	y := Must(Not(x))
+
+	// numeric 1/0 representation
+	xf := NewVector(g, Float64, WithName("xf"), WithShape(3), WithValue(tf64.NewTensor(tf64.WithBacking([]float64{1, 0, -2}), tf64.WithShape(3))))
+	yf := Must(Not(xf))
+
+	// scalar
+	xs := NewScalar(g, Bool, WithName("xs"), WithValue(false))
+	ys := Must(Not(xs))
+
+	prog, locMap, err := Compile(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewTapeMachine(prog, locMap)
+	if err = m.RunAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal([]bool{false, true, false}, y.Value().(Tensor).Tensor.Data())
+	assert.Equal([]float64{0, 1, 0}, extractF64s(yf.Value()))
+	assert.Equal(true, ys.Value().(Scalar).v)
+
+	// not is not differentiable
+	assert.Equal([]bool{false}, y.op.DiffWRT(1))
+}
+
+func TestNeg(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, useTape := range []bool{true, false} {
+		g := NewGraph()
+		x := NewScalar(g, Float64, WithName("x"), WithValue(2.5))
+		xT := NewVector(g, Float64, WithName("xT"), WithShape(3), WithValue(tf64.NewTensor(tf64.WithBacking([]float64{1, -2, 0}), tf64.WithShape(3))))
+		w := NewVector(g, Float64, WithName("w"), WithShape(3), WithValue(tf64.NewTensor(tf64.WithBacking([]float64{3, 4, 5}), tf64.WithShape(3))))
+
+		y := Must(Neg(x))
+		yT := Must(Neg(xT))
+
+		// cost = 3(-x) + Σ w ⊙ -xT, so that ∂y = 3 and ∂yT = w
+		cost := Must(Add(Must(Mul(y, NewConstant(3.0))), Must(Sum(Must(HadamardProd(yT, w))))))
+
+		if useTape {
+			if _, err := Grad(cost, x, xT); err != nil {
+				t.Fatal(err)
+			}
+			prog, locMap, err := Compile(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := NewTapeMachine(prog, locMap)
+			if err = m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			m := NewLispMachine(g)
+			if err := m.RunAll(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.Equal(-2.5, extractF64(y.Value()), "Tape %t", useTape)
+		assert.Equal([]float64{-1, 2, 0}, extractF64s(yT.Value()), "Tape %t", useTape)
+
+		// the gradients are -∂y
+		dx, err := x.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dxT, err := xT.Grad()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(-3.0, extractF64(dx), "Tape %t", useTape)
+		assert.Equal([]float64{-3, -4, -5}, extractF64s(dxT), "Tape %t", useTape)
+	}
+
+	// UnsafeDo negates tensors in place
+	op := newElemUnaryOp(negOpType, NewVector(NewGraph(), Float64, WithShape(3)))
+	T := tf64.NewTensor(tf64.WithBacking([]float64{1, -2, 0}), tf64.WithShape(3))
+	v, err := op.UnsafeDo(FromTensor(T))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]float64{-1, 2, 0}, T.Data())
+	assert.True(T == v.(Tensor).Tensor)
+
+	op = newElemUnaryOp(negOpType, NewScalar(NewGraph(), Float64))
+	if v, err = op.UnsafeDo(NewScalarValue(2.5)); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(-2.5, extractF64(v))
+}
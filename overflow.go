@@ -0,0 +1,225 @@
+package gorgonia
+
+import (
+	"math"
+	"sync/atomic"
+
+	ti "github.com/chewxy/gorgonia/tensor/i"
+	"github.com/chewxy/gorgonia/tensor/types"
+	"github.com/pkg/errors"
+)
+
+// IntOverflowMode is what integer arithmetic does with a result that does not fit in the Dtype of its operands. See IntOverflow.
+type IntOverflowMode int32
+
+const (
+	// WrapOnOverflow wraps the result around, the way Go's integer arithmetic does. It is the default.
+	WrapOnOverflow IntOverflowMode = iota
+	// SaturateOnOverflow clamps the result to the largest or the smallest value of the Dtype, whichever the result went past.
+	SaturateOnOverflow
+	// ErrorOnOverflow stops with an error that names the op and the operands that overflowed.
+	ErrorOnOverflow
+)
+
+// intOverflow holds the IntOverflowMode in use. It is read for every element of integer arithmetic, so it is accessed atomically instead of behind a lock.
+var intOverflow int32
+
+// intBits is the size of an Int in bits
+const intBits = 32 << (^uint(0) >> 63)
+
+// IntOverflow sets what the integer arithmetic of the binary operators (Add, Sub, HadamardProd, HadamardDiv and the like, on Int, Int64 and Int32 operands)
+// does when a result overflows. It wraps around by default.
+//
+// Division by 0 is always an error, whatever the mode. Dividing the smallest value of a Dtype by -1 overflows like any other op.
+func IntOverflow(mode IntOverflowMode) { atomic.StoreInt32(&intOverflow, int32(mode)) }
+
+// IntOverflowBehaviour returns the IntOverflowMode in use. See IntOverflow.
+func IntOverflowBehaviour() IntOverflowMode { return IntOverflowMode(atomic.LoadInt32(&intOverflow)) }
+
+// intArith carries out an arithmetic op on a and b, which hold values of the integer Dtype t, in the IntOverflowMode in use
+func intArith(op ʘBinaryOperatorType, a, b int64, t Dtype) (r int64, err error) {
+	var bits uint
+	switch t {
+	case Int:
+		bits = intBits
+	case Int64:
+		bits = 64
+	case Int32:
+		bits = 32
+	default:
+		return 0, errors.Errorf(nyiFail, "intArith", t)
+	}
+	min := int64(-1) << (bits - 1)
+	max := -(min + 1)
+
+	// the result is computed in 64 bits. A result of a narrower Dtype overflows if it is out of range, and a 64 bit result if it has wrapped around.
+	// positive is the direction the result overflowed in.
+	var overflowed, positive bool
+	switch op {
+	case addOpType:
+		r = a + b
+		overflowed = (a > 0 && b > 0 && r < 0) || (a < 0 && b < 0 && r >= 0)
+		positive = a > 0
+	case subOpType:
+		r = a - b
+		overflowed = (b < 0 && r < a) || (b > 0 && r > a)
+		positive = b < 0
+	case mulOpType:
+		r = a * b
+		overflowed = a != 0 && (r/a != b || (a == -1 && b == math.MinInt64))
+		positive = (a < 0) == (b < 0)
+	case divOpType:
+		if b == 0 {
+			return 0, errors.Errorf("Integer division by 0 in %v: %d %v %d", op, a, op, b)
+		}
+		if a == min && b == -1 {
+			r, overflowed, positive = min, true, true
+		} else {
+			r = a / b
+		}
+	default:
+		return 0, errors.Errorf(nyiFail, "intArith", op)
+	}
+	if bits < 64 {
+		overflowed = overflowed || r < min || r > max
+	}
+	if !overflowed {
+		return r, nil
+	}
+
+	switch IntOverflowBehaviour() {
+	case SaturateOnOverflow:
+		if positive {
+			return max, nil
+		}
+		return min, nil
+	case ErrorOnOverflow:
+		return 0, errors.Errorf("Integer overflow in %v: %d %v %d does not fit in %v", op, a, op, b, t)
+	}
+
+	// wrap around to the width of the Dtype, keeping the sign
+	shift := 64 - bits
+	return r << shift >> shift, nil
+}
+
+// intScalar returns the value of an Int, Int64 or Int32 Scalar as an int64
+func intScalar(v interface{}) (int64, error) {
+	switch i := v.(type) {
+	case int:
+		return int64(i), nil
+	case int64:
+		return i, nil
+	case int32:
+		return int64(i), nil
+	}
+	return 0, errors.Errorf("Expected an integer. Got %v of %T instead", v, v)
+}
+
+// intOfDtype converts an int64 back into the integer Dtype t
+func intOfDtype(i int64, t Dtype) interface{} {
+	switch t {
+	case Int64:
+		return i
+	case Int32:
+		return int32(i)
+	}
+	return int(i)
+}
+
+// tiArith carries out an arithmetic op on Int tensors, or on an Int tensor and an int, in the IntOverflowMode in use.
+// Like the tensor packages' own arithmetic, it writes into the tensor operand if it is unsafe, and honours the reuse and incr options.
+func tiArith(op ʘBinaryOperatorType, a, b interface{}, opts ...types.FuncOpt) (retVal *ti.Tensor, err error) {
+	var as, bs []int
+	var at, bt *ti.Tensor
+	if as, at, err = tiOperand(a); err != nil {
+		return
+	}
+	if bs, bt, err = tiOperand(b); err != nil {
+		return
+	}
+
+	t := at
+	if t == nil {
+		t = bt
+	}
+	if t == nil {
+		return nil, errors.Errorf("Expected at least one Int tensor. Got %T and %T instead", a, b)
+	}
+	if at != nil && bt != nil && !at.Shape().Eq(bt.Shape()) {
+		return nil, errors.Errorf("Shape mismatch: %v and %v", at.Shape(), bt.Shape())
+	}
+	// the elements are read straight off the backing arrays, which views do not lay out by their shape
+	size := t.Shape().TotalSize()
+	if (at != nil && len(as) != size) || (bt != nil && len(bs) != size) {
+		return nil, errors.Errorf("Expected the backing of an Int tensor of shape %v to have %d elements. Views are not supported", t.Shape(), size)
+	}
+
+	var reuse, incr *ti.Tensor
+	var unsafe bool
+	for _, opt := range opts {
+		flag, v := opt()
+		switch flag {
+		case types.UnsafeOp:
+			unsafe = true
+		case types.Reuse, types.Incr:
+			rt, ok := v.(*ti.Tensor)
+			if !ok {
+				return nil, errors.Errorf("Expected an Int tensor to reuse. Got %T instead", v)
+			}
+			if rt.Shape().TotalSize() != size {
+				return nil, errors.Errorf("Expected the tensor to reuse to have %d elements. Got %v instead", size, rt.Shape())
+			}
+			if flag == types.Reuse {
+				reuse = rt
+			} else {
+				incr = rt
+			}
+		}
+	}
+
+	switch {
+	case incr != nil:
+		retVal = incr
+	case reuse != nil:
+		retVal = reuse
+	case unsafe:
+		retVal = t
+	default:
+		retVal = ti.NewTensor(ti.WithShape(t.Shape().Clone()...))
+	}
+
+	// all the results are computed before any is written, so that an overflow error leaves the operands as they were
+	rs := make([]int, size)
+	for i := range rs {
+		x, y := as[0], bs[0]
+		if at != nil {
+			x = as[i]
+		}
+		if bt != nil {
+			y = bs[i]
+		}
+		var r int64
+		if r, err = intArith(op, int64(x), int64(y), Int); err != nil {
+			return nil, err
+		}
+		if incr != nil {
+			if r, err = intArith(addOpType, int64(incr.Data().([]int)[i]), r, Int); err != nil {
+				return nil, err
+			}
+		}
+		rs[i] = int(r)
+	}
+	copy(retVal.Data().([]int), rs)
+	return retVal, nil
+}
+
+// tiOperand returns the elements of an operand of tiArith, which is either an Int tensor or an int. The tensor is nil for an int.
+func tiOperand(v interface{}) (data []int, t *ti.Tensor, err error) {
+	switch vt := v.(type) {
+	case *ti.Tensor:
+		return vt.Data().([]int), vt, nil
+	case int:
+		return []int{vt}, nil, nil
+	}
+	return nil, nil, errors.Errorf("Expected an Int tensor or an int. Got %v of %T instead", v, v)
+}
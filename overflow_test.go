@@ -0,0 +1,158 @@
+package gorgonia
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	ti "github.com/chewxy/gorgonia/tensor/i"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntOverflow(t *testing.T) {
+	assert := assert.New(t)
+	defer IntOverflow(WrapOnOverflow)
+
+	// scalarBinOp on Int32s, at the boundary
+	do := func(ot ʘBinaryOperatorType, a, b int32) (interface{}, error) {
+		v, err := newEBOByType(ot, Int32, Int32).Do(NewScalarValue(a), NewScalarValue(b))
+		if err != nil {
+			return nil, err
+		}
+		return v.Data(), nil
+	}
+
+	cases := []struct {
+		op       ʘBinaryOperatorType
+		a, b     int32
+		wrap     int32
+		saturate int32
+	}{
+		{addOpType, math.MaxInt32, 1, math.MinInt32, math.MaxInt32},
+		{addOpType, math.MinInt32, -1, math.MaxInt32, math.MinInt32},
+		{subOpType, math.MinInt32, 1, math.MaxInt32, math.MinInt32},
+		{subOpType, 0, math.MinInt32, math.MinInt32, math.MaxInt32},
+		{mulOpType, math.MaxInt32, 2, -2, math.MaxInt32},
+		{mulOpType, math.MinInt32, 2, 0, math.MinInt32},
+		{mulOpType, 1 << 16, -(1 << 16), 0, math.MinInt32},
+		{divOpType, math.MinInt32, -1, math.MinInt32, math.MaxInt32},
+	}
+
+	assert.Equal(WrapOnOverflow, IntOverflowBehaviour(), "Wrapping around is the default")
+	for _, c := range cases {
+		r, err := do(c.op, c.a, c.b)
+		assert.Nil(err)
+		assert.Equal(c.wrap, r, "%d %v %d", c.a, c.op, c.b)
+	}
+
+	IntOverflow(SaturateOnOverflow)
+	for _, c := range cases {
+		r, err := do(c.op, c.a, c.b)
+		assert.Nil(err)
+		assert.Equal(c.saturate, r, "%d %v %d", c.a, c.op, c.b)
+	}
+
+	IntOverflow(ErrorOnOverflow)
+	for _, c := range cases {
+		_, err := do(c.op, c.a, c.b)
+		if assert.NotNil(err, "%d %v %d", c.a, c.op, c.b) {
+			assert.True(strings.Contains(err.Error(), "overflow"), "%v", err)
+			assert.True(strings.Contains(err.Error(), c.op.String()), "The op should be named: %v", err)
+		}
+	}
+
+	// results that fit are the same in every mode
+	for _, mode := range []IntOverflowMode{WrapOnOverflow, SaturateOnOverflow, ErrorOnOverflow} {
+		IntOverflow(mode)
+		r, err := do(addOpType, math.MaxInt32-1, 1)
+		assert.Nil(err)
+		assert.Equal(int32(math.MaxInt32), r)
+		r, err = do(mulOpType, -(1 << 15), 1<<16)
+		assert.Nil(err)
+		assert.Equal(int32(math.MinInt32), r)
+		r, err = do(subOpType, -7, 3)
+		assert.Nil(err)
+		assert.Equal(int32(-10), r)
+
+		_, err = do(divOpType, 1, 0)
+		assert.NotNil(err, "Dividing by 0 is always an error")
+	}
+
+	// the operands of an error are named, and so is the Dtype
+	_, err := do(addOpType, math.MaxInt32, 1)
+	assert.Contains(err.Error(), "2147483647 + 1 does not fit in Int32")
+
+	// Int64 scalars wrap around at their own boundary
+	IntOverflow(WrapOnOverflow)
+	v, err := newEBOByType(addOpType, Int64, Int64).Do(NewScalarValue(int64(math.MaxInt64)), NewScalarValue(int64(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(int64(math.MinInt64), v.Data())
+	IntOverflow(SaturateOnOverflow)
+	if v, err = newEBOByType(mulOpType, Int64, Int64).Do(NewScalarValue(int64(-1)), NewScalarValue(int64(math.MinInt64))); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(int64(math.MaxInt64), v.Data())
+
+	// tBinOp on Int tensors, and an Int tensor and an Int
+	xs := []int{math.MaxInt64, 1, math.MinInt64}
+	tensorOf := func() Value { return FromTensor(ti.NewTensor(ti.WithBacking(append([]int{}, xs...)), ti.WithShape(3))) }
+	ones := FromTensor(ti.NewTensor(ti.WithBacking([]int{1, 1, -1}), ti.WithShape(3)))
+	tt := newEBOByType(addOpType, newTensorType(1, Int), newTensorType(1, Int))
+	ts := newEBOByType(addOpType, newTensorType(1, Int), Int)
+
+	IntOverflow(WrapOnOverflow)
+	if v, err = tt.Do(tensorOf(), ones); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]int{math.MinInt64, 2, math.MaxInt64}, v.Data())
+
+	IntOverflow(SaturateOnOverflow)
+	if v, err = tt.Do(tensorOf(), ones); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]int{math.MaxInt64, 2, math.MinInt64}, v.Data())
+	if v, err = ts.Do(tensorOf(), NewScalarValue(-2)); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]int{math.MaxInt64 - 2, -1, math.MinInt64}, v.Data())
+
+	IntOverflow(ErrorOnOverflow)
+	a := tensorOf()
+	_, err = tt.UnsafeDo(a, ones)
+	assert.NotNil(err)
+	assert.Equal(xs, a.Data(), "An overflow should leave the operands alone")
+
+	// in place, and incrementing, when nothing overflows
+	b := FromTensor(ti.NewTensor(ti.WithBacking([]int{1, 2, 3}), ti.WithShape(3)))
+	if v, err = tt.UnsafeDo(b, ones); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]int{2, 3, 2}, b.Data())
+	incr := FromTensor(ti.NewTensor(ti.WithBacking([]int{10, 20, 30}), ti.WithShape(3)))
+	if err = tt.IncrDo(incr, b, ones); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]int{13, 24, 31}, incr.Data())
+
+	// tensors must have the same shape, not just the same size
+	m23 := FromTensor(ti.NewTensor(ti.WithShape(2, 3)))
+	m32 := FromTensor(ti.NewTensor(ti.WithShape(3, 2)))
+	tm := newEBOByType(addOpType, newTensorType(2, Int), newTensorType(2, Int))
+	_, err = tm.Do(m23, m32)
+	assert.NotNil(err)
+
+	// tBinOp materializes views, but tiArith on its own reads the backing of a view. That is an error, not a panic, whichever side the view is on
+	backing := ti.NewTensor(ti.WithBacking([]int{1, 2, 3, 4, 5, 6}), ti.WithShape(3, 2))
+	view, err := backing.Slice(nil, S(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotPanics(func() { _, err = tiArith(addOpType, 1, view) })
+	assert.NotNil(err)
+	assert.NotPanics(func() { _, err = tiArith(addOpType, view, ti.NewTensor(ti.WithShape(3))) })
+	assert.NotNil(err)
+	_, err = tiArith(addOpType, ti.NewTensor(ti.WithShape(2, 3)), ti.NewTensor(ti.WithShape(3, 2)))
+	assert.NotNil(err)
+}
@@ -0,0 +1,226 @@
+package gorgonia
+
+import "github.com/pkg/errors"
+
+// This file contains passes that rewrite an *ExprGraph
+
+// CSE performs common subexpression elimination on the graph. Nodes that have the same op (as compared by the Hashcode() of the op),
+// type and shape, and the exact same children are merged into one canonical node. The consumers of the merged nodes are rewired to use the canonical node.
+//
+// Nodes are hash-consed when they are added to the graph, so for graphs built with the usual functions this is mostly a no-op.
+// CSE is useful after the graph has been rewritten. Nodes of stateful ops (the random ops used by Dropout, for example) are never merged.
+func CSE(g *ExprGraph) (err error) {
+	var sorted Nodes
+	if sorted, err = Sort(g); err != nil {
+		return
+	}
+
+	canonical := make(map[uint32]Nodes)
+
+	// children first, so that by the time a node is visited, its children are canonical
+	for i := len(sorted) - 1; i >= 0; i-- {
+		n := sorted[i]
+		g.rehash(n)
+
+		if n.isInput() || n.isStmt || n.isStateful() {
+			continue
+		}
+
+		hash := n.Hashcode()
+		var replaced bool
+		for _, c := range canonical[hash] {
+			if sameSubexpr(n, c) {
+				g.replaceNode(n, c)
+				replaced = true
+				break
+			}
+		}
+
+		if !replaced {
+			canonical[hash] = append(canonical[hash], n)
+		}
+	}
+	return nil
+}
+
+// FoldConstants evaluates, at build time, the nodes whose children are all constants, replacing them with a constant holding the result.
+// Folding is repeated up the graph, so 2*3 + 1 becomes a single constant 7. Constants that are no longer used after the folding are removed from the graph.
+//
+// Nodes of ops that call external code (CallsExtern) and of stateful ops are left alone, as are statements. So are nodes whose ops fail on the constants:
+// they fail when the graph is run instead, and the rest of the graph is folded all the same.
+func FoldConstants(g *ExprGraph) (err error) {
+	var sorted Nodes
+	if sorted, err = Sort(g); err != nil {
+		return
+	}
+
+	// children first, so that constants propagate upwards
+	for i := len(sorted) - 1; i >= 0; i-- {
+		n := sorted[i]
+		if !foldable(n) {
+			continue
+		}
+
+		// an op that cannot be computed now is left to fail, or not, when the graph is run
+		var v Value
+		if v, err = foldValue(n); err != nil {
+			continue
+		}
+
+		// the folded constant may well be one of the children, as constants are hash-consed too
+		c := g.AddNode(NewConstant(v))
+		children := n.children
+		g.replaceNode(n, c)
+
+		for _, child := range children {
+			if child != c && len(g.to[child]) == 0 && g.Has(child) {
+				g.removeNode(child)
+			}
+		}
+	}
+	return nil
+}
+
+// foldValue computes the value of a foldable node from the values of its children
+func foldValue(n *Node) (retVal Value, err error) {
+	inputs := make([]Value, len(n.children))
+	for j, child := range n.children {
+		v := child.op.(constant).Value()
+
+		// never let an op clobber the value of a constant
+		if n.op.overwriteInput() >= 0 || n.op.returnsPtr() {
+			if v, err = v.clone(); err != nil {
+				return nil, errors.Wrap(err, cloneFail)
+			}
+		}
+		inputs[j] = v
+	}
+
+	if retVal, err = n.op.Do(inputs...); err != nil {
+		return nil, errors.Wrapf(err, doFail, n.op)
+	}
+	return
+}
+
+// foldable checks if n is the result of applying a pure op on constants only. Like CSE, it leaves stateful nodes alone, whether or not they are constants.
+func foldable(n *Node) bool {
+	if n.isInput() || n.isStmt || n.isConstant() || n.isStateful() || len(n.children) == 0 {
+		return false
+	}
+
+	if n.op.callsExtern() {
+		return false
+	}
+
+	for _, child := range n.children {
+		if !child.isConstant() || child.isStateful() {
+			return false
+		}
+	}
+	return true
+}
+
+// sameSubexpr checks if two nodes compute the same expression on the same children. The children of a commutative operation may be in either order.
+func sameSubexpr(a, b *Node) bool {
+	if !nodeEq(a, b) {
+		return false
+	}
+
+	if a.commutes() && b.commutes() && a.children[0] == b.children[1] && a.children[1] == b.children[0] {
+		return true
+	}
+
+	for i, child := range a.children {
+		if b.children[i] != child {
+			return false
+		}
+	}
+	return true
+}
+
+// replaceNode rewires all the consumers of old to use with instead, and then removes old from the graph.
+func (g *ExprGraph) replaceNode(old, with *Node) {
+	for _, parent := range g.to[old] {
+		parent.children.replace(old, with)
+		g.to[with] = g.to[with].Add(parent)
+	}
+
+	// keep the derivations intact
+	for _, n := range g.all {
+		if n.deriv == old {
+			n.deriv = with
+		}
+		n.derivOf.replace(old, with)
+	}
+	if with.deriv == nil {
+		with.deriv = old.deriv
+	}
+	for _, d := range old.derivOf {
+		with.derivOf = with.derivOf.Add(d)
+	}
+
+	g.removeNode(old)
+}
+
+// removeNode removes n and the edges to its children from the graph. Unlike RemoveNode, it is careful not to unindex other nodes with the same hash.
+func (g *ExprGraph) removeNode(n *Node) {
+	for _, child := range n.children {
+		g.to[child] = g.to[child].remove(n)
+	}
+
+	g.leaves = g.leaves.remove(n)
+	if g.roots != nil {
+		g.roots = g.roots.remove(n)
+	}
+
+	g.unindex(n, n.Hashcode())
+	delete(g.to, n)
+	g.all = g.all.remove(n)
+}
+
+// rehash recalculates the hash of n (its children may have been replaced) and reindexes it if the hash has changed.
+func (g *ExprGraph) rehash(n *Node) {
+	old := n.Hashcode()
+	n.hashed = false
+	if n.Hashcode() != old {
+		g.unindex(n, old)
+		g.index(n)
+	}
+}
+
+// index adds n to the hash tables of the graph, like AddNode does, except that no node is deduplicated.
+func (g *ExprGraph) index(n *Node) {
+	hash := n.Hashcode()
+	existing, ok := g.byHash[hash]
+	switch {
+	case !ok:
+		g.byHash[hash] = n
+	case existing == nil:
+		g.evac[hash] = append(g.evac[hash], n)
+	case existing != n:
+		g.evac[hash] = Nodes{existing, n}
+		g.byHash[hash] = nil
+	}
+}
+
+// unindex removes n from the hash tables of the graph.
+func (g *ExprGraph) unindex(n *Node, hash uint32) {
+	if existing, ok := g.byHash[hash]; ok && existing != nil {
+		if existing == n {
+			delete(g.byHash, hash)
+		}
+		return
+	}
+
+	evac := g.evac[hash].remove(n)
+	switch len(evac) {
+	case 0:
+		delete(g.byHash, hash)
+		delete(g.evac, hash)
+	case 1:
+		g.byHash[hash] = evac[0]
+		delete(g.evac, hash)
+	default:
+		g.evac[hash] = evac
+	}
+}
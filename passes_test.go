@@ -0,0 +1,215 @@
+package gorgonia
+
+import (
+	"testing"
+
+	tf64 "github.com/chewxy/gorgonia/tensor/f64"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSE(t *testing.T) {
+	assert := assert.New(t)
+
+	// a*b + a*b never needs CSE: the second a*b is the first one already, as the graph hash-conses its nodes
+	g := NewGraph()
+	a := NewScalar(g, Float64, WithName("a"))
+	b := NewScalar(g, Float64, WithName("b"))
+	ab0 := Must(Mul(a, b))
+	ab1 := Must(Mul(a, b))
+	assert.True(ab0 == ab1)
+
+	// a*b + a*c, where c is then rewired to be b, leaving two a*b nodes in the graph
+	g = NewGraph()
+	a = NewScalar(g, Float64, WithName("a"))
+	b = NewScalar(g, Float64, WithName("b"))
+	c := NewScalar(g, Float64, WithName("c"))
+	ab := Must(Mul(a, b))
+	ac := Must(Mul(a, c))
+	sum := Must(Add(ab, ac))
+	g.replaceNode(c, b)
+	assert.Equal(5, len(g.AllNodes()))
+
+	if err := CSE(g); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(4, len(g.AllNodes()))
+	assert.Equal(Nodes{ab, ab}, sum.children)
+	assert.False(g.AllNodes().Contains(ac))
+	assert.Equal(Nodes{sum}, graphNodeToNode(g.To(ab)))
+	assert.Equal(sum, g.byHash[sum.Hashcode()])
+
+	Let(a, 2.0)
+	Let(b, 3.0)
+	prog, locMap, err := Compile(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewTapeMachine(prog, locMap)
+	if err = m.RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(12.0, sum.Value().Data())
+
+	// a*b + b*c, where c is then rewired to be a, leaving a*b and b*a in the graph
+	g = NewGraph()
+	a = NewScalar(g, Float64, WithName("a"))
+	b = NewScalar(g, Float64, WithName("b"))
+	c = NewScalar(g, Float64, WithName("c"))
+	ab = Must(Mul(a, b))
+	ba := Must(Mul(b, c))
+	sum = Must(Add(ab, ba))
+	g.replaceNode(c, a)
+	assert.Equal(Nodes{b, a}, ba.children)
+	assert.Equal(5, len(g.AllNodes()))
+
+	if err = CSE(g); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(4, len(g.AllNodes()))
+	assert.Equal(sum.children[0], sum.children[1])
+
+	// stateful nodes are never merged
+	g = NewGraph()
+	r0 := UniformRandomNode(g, Float64, 0, 1, 2, 2)
+	r1 := UniformRandomNode(g, Float64, 0, 1, 2, 2)
+	assert.True(r0 != r1)
+
+	x := NewMatrix(g, Float64, WithName("x"), WithShape(2, 2), WithInit(RangedFrom(0)))
+	Must(Add(Must(HadamardProd(x, r0)), Must(HadamardProd(x, r1))))
+
+	if err = CSE(g); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(g.AllNodes().Contains(r0))
+	assert.True(g.AllNodes().Contains(r1))
+
+	if prog, locMap, err = Compile(g); err != nil {
+		t.Fatal(err)
+	}
+	m = NewTapeMachine(prog, locMap)
+	if err = m.RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEqual(extractF64s(r0.Value()), extractF64s(r1.Value()))
+}
+
+// externMulOp is a multiplication that pretends to call external code
+type externMulOp struct{ elemBinOp }
+
+func (op externMulOp) callsExtern() bool { return true }
+
+// statefulConstantOp is a constant that pretends to hold state
+type statefulConstantOp struct{ constantScalar }
+
+func (op statefulConstantOp) isStateful() bool { return true }
+
+func TestFoldConstants(t *testing.T) {
+	assert := assert.New(t)
+
+	// 2*3 + 1
+	g := NewGraph()
+	two := g.AddNode(NewConstant(2.0))
+	three := g.AddNode(NewConstant(3.0))
+	one := g.AddNode(NewConstant(1.0))
+	Must(Add(Must(Mul(two, three)), one))
+	assert.Equal(5, len(g.AllNodes()))
+
+	if err := FoldConstants(g); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(1, len(g.AllNodes()))
+	folded := g.AllNodes()[0]
+	assert.True(folded.isConstant())
+	assert.Equal(7.0, folded.op.(constant).Value().Data())
+	assert.Equal(Nodes{folded}, g.Roots())
+
+	// x * (2*3): only the constant subexpression is folded
+	g = NewGraph()
+	x := NewScalar(g, Float64, WithName("x"))
+	two = g.AddNode(NewConstant(2.0))
+	three = g.AddNode(NewConstant(3.0))
+	xs := Must(Mul(x, Must(Mul(two, three))))
+
+	if err := FoldConstants(g); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(3, len(g.AllNodes()))
+	assert.True(xs.children[1].isConstant())
+	assert.Equal(6.0, xs.children[1].op.(constant).Value().Data())
+
+	Let(x, 2.0)
+	prog, locMap, err := Compile(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewTapeMachine(prog, locMap)
+	if err = m.RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(12.0, xs.Value().Data())
+
+	// ops that call external code are not folded
+	g = NewGraph()
+	two = g.AddNode(NewConstant(2.0))
+	three = g.AddNode(NewConstant(3.0))
+	ext := Must(applyOp(externMulOp{newElemBinOp(mulOpType, two, three)}, two, three))
+
+	if err = FoldConstants(g); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(g.AllNodes().Contains(ext))
+	assert.Equal(3, len(g.AllNodes()))
+
+	// neither are ops on stateful constants
+	g = NewGraph()
+	two = NewConstant(2.0)
+	two.op = statefulConstantOp{two.op.(constantScalar)}
+	two = g.AddNode(two)
+	three = g.AddNode(NewConstant(3.0))
+	st := Must(Mul(two, three))
+
+	if err = FoldConstants(g); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(g.AllNodes().Contains(st))
+	assert.Equal(3, len(g.AllNodes()))
+
+	// a constant subexpression that cannot be computed is left for the graph to fail on when it is run. The rest is folded all the same
+	g = NewGraph()
+	two = g.AddNode(NewConstant(2))
+	three = g.AddNode(NewConstant(3))
+	num := g.AddNode(NewConstant(1))
+	den := g.AddNode(NewConstant(0))
+	quo := Must(HadamardDiv(num, den))
+	sum := Must(Add(Must(Mul(two, three)), quo))
+
+	if err = FoldConstants(g); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(g.AllNodes().Contains(quo))
+	assert.Equal(Nodes{num, den}, quo.children)
+	assert.True(sum.children[0].isConstant())
+	assert.Equal(6, sum.children[0].op.(constant).Value().Data())
+	assert.Equal(5, len(g.AllNodes()))
+	assert.NotNil(NewLispMachine(g, ExecuteFwdOnly()).RunAll())
+
+	// the values of the constants are not clobbered
+	g = NewGraph()
+	c := g.AddNode(NewConstant(tf64.NewTensor(tf64.WithBacking([]float64{1, 2, 3, 4}), tf64.WithShape(2, 2))))
+	Must(Add(Must(Transpose(c)), c))
+	if err = FoldConstants(g); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(1, len(g.AllNodes()))
+	assert.Equal([]float64{1, 2, 3, 4}, extractF64s(c.op.(constant).Value()))
+	assert.Equal([]float64{2, 5, 5, 8}, extractF64s(g.AllNodes()[0].op.(constant).Value()))
+
+	// a constant that folds into one of its own children (0 + 0 = 0) stays in the graph
+	g = NewGraph()
+	zero := g.AddNode(NewConstant(0.0))
+	Must(Add(zero, zero))
+	if err = FoldConstants(g); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(Nodes{zero}, g.AllNodes())
+}
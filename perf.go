@@ -2,11 +2,14 @@ package gorgonia
 
 import (
 	"sync"
+	"sync/atomic"
 
+	"github.com/chewxy/gorgonia/tensor"
 	tb "github.com/chewxy/gorgonia/tensor/b"
 	tf32 "github.com/chewxy/gorgonia/tensor/f32"
 	tf64 "github.com/chewxy/gorgonia/tensor/f64"
 	ti "github.com/chewxy/gorgonia/tensor/i"
+	"github.com/chewxy/gorgonia/tensor/types"
 )
 
 var nodePool = new(sync.Pool)
@@ -167,3 +170,135 @@ func returnTensor(t Tensor) {
 		return
 	}
 }
+
+// handles the temporaries of ops
+
+// poolDisabled is 1 when the ops allocate their temporaries instead of drawing them from the buffer pool of the graph. It is read every time an op
+// needs a temporary, so it is accessed atomically instead of behind a lock.
+var poolDisabled int32
+
+// SetPoolEnabled turns the pooling of temporaries on or off. It is on by default.
+//
+// Some ops need temporary tensors while they run, which are thrown away as soon as they are done: linAlgBinOp transposes copies of its inputs
+// when it differentiates a matrix multiplication, and sumOp broadcasts the gradient of its output when it cannot be added to the gradient of its
+// input in place. When pooling is on, these are drawn from a pool that belongs to the graph, and returned to it after use, so that a backward pass
+// that is run over and over creates little garbage. The pool holds on to the temporaries for as long as the graph is around.
+func SetPoolEnabled(on bool) {
+	var v int32
+	if !on {
+		v = 1
+	}
+	atomic.StoreInt32(&poolDisabled, v)
+}
+
+// PoolEnabled returns true if the temporaries of ops are pooled. See SetPoolEnabled.
+func PoolEnabled() bool { return atomic.LoadInt32(&poolDisabled) == 0 }
+
+// bufferPool holds the backings of the temporary tensors of the ops that run on a graph, grouped by Dtype and size.
+// A nil *bufferPool is valid: it allocates every temporary, and lets go of them when they are returned.
+type bufferPool struct {
+	sync.Mutex
+	f64 map[int][][]float64
+	f32 map[int][][]float32
+}
+
+// tempPool returns the pool that the ops of the graph draw their temporaries from, or nil if pooling is turned off
+func (g *ExprGraph) tempPool() *bufferPool {
+	if g == nil || !PoolEnabled() {
+		return nil
+	}
+	return g.pool
+}
+
+// borrow returns a zeroed tensor of the Dtype and shape. Only float tensors are pooled.
+func (p *bufferPool) borrow(dt Dtype, shape types.Shape) types.Tensor {
+	size := shape.TotalSize()
+	if p == nil {
+		switch dt {
+		case Float32:
+			return tf32.NewTensor(tf32.WithShape(shape...))
+		default:
+			return tf64.NewTensor(tf64.WithShape(shape...))
+		}
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	switch dt {
+	case Float32:
+		var backing []float32
+		if free := p.f32[size]; len(free) > 0 {
+			backing = free[len(free)-1]
+			p.f32[size] = free[:len(free)-1]
+			for i := range backing {
+				backing[i] = 0
+			}
+		} else {
+			backing = make([]float32, size)
+		}
+		return tf32.NewTensor(tf32.WithBacking(backing), tf32.WithShape(shape...))
+	default:
+		var backing []float64
+		if free := p.f64[size]; len(free) > 0 {
+			backing = free[len(free)-1]
+			p.f64[size] = free[:len(free)-1]
+			for i := range backing {
+				backing[i] = 0
+			}
+		} else {
+			backing = make([]float64, size)
+		}
+		return tf64.NewTensor(tf64.WithBacking(backing), tf64.WithShape(shape...))
+	}
+}
+
+// clone copies t into a tensor borrowed from the pool. Tensors that are views, or have been transposed, are cloned the usual way.
+func (p *bufferPool) clone(t types.Tensor) types.Tensor {
+	switch tt := t.(type) {
+	case *tf64.Tensor:
+		if !tt.IsMaterializable() {
+			retVal := p.borrow(Float64, tt.Shape())
+			copy(retVal.Data().([]float64), tt.Data().([]float64))
+			return retVal
+		}
+	case *tf32.Tensor:
+		if !tt.IsMaterializable() {
+			retVal := p.borrow(Float32, tt.Shape())
+			copy(retVal.Data().([]float32), tt.Data().([]float32))
+			return retVal
+		}
+	}
+	return tensor.Clone(t)
+}
+
+// put returns the backing of a tensor to the pool. The tensor must not be used afterwards. Views are not returned, as they do not own their backing.
+func (p *bufferPool) put(t types.Tensor) {
+	if p == nil {
+		return
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	switch tt := t.(type) {
+	case *tf64.Tensor:
+		if tt.IsView() {
+			return
+		}
+		backing := tt.Data().([]float64)
+		if p.f64 == nil {
+			p.f64 = make(map[int][][]float64)
+		}
+		p.f64[len(backing)] = append(p.f64[len(backing)], backing)
+	case *tf32.Tensor:
+		if tt.IsView() {
+			return
+		}
+		backing := tt.Data().([]float32)
+		if p.f32 == nil {
+			p.f32 = make(map[int][][]float32)
+		}
+		p.f32[len(backing)] = append(p.f32[len(backing)], backing)
+	}
+}
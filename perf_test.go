@@ -3,6 +3,8 @@ package gorgonia
 import (
 	"testing"
 
+	tf64 "github.com/chewxy/gorgonia/tensor/f64"
+	"github.com/chewxy/gorgonia/tensor/types"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -11,3 +13,97 @@ func TestNodePool(t *testing.T) {
 	n := borrowNode()
 	assert.NotNil(n)
 }
+
+func TestBufferPool(t *testing.T) {
+	assert := assert.New(t)
+
+	p := new(bufferPool)
+	a := p.borrow(Float64, types.Shape{2, 3})
+	assert.Equal(types.Shape{2, 3}, a.Shape())
+	backing := a.Data().([]float64)
+	backing[0] = 7
+	p.put(a)
+
+	// the backing is reused, and zeroed
+	b := p.borrow(Float64, types.Shape{3, 2})
+	assert.Equal(types.Shape{3, 2}, b.Shape())
+	assert.True(&backing[0] == &b.Data().([]float64)[0])
+	assert.Equal(make([]float64, 6), b.Data())
+
+	// different sizes and dtypes are kept apart
+	c := p.borrow(Float32, types.Shape{6})
+	assert.Equal(make([]float32, 6), c.Data())
+	d := p.borrow(Float64, types.Shape{6})
+	assert.True(&backing[0] != &d.Data().([]float64)[0])
+
+	// clones
+	src := tf64.NewTensor(tf64.WithBacking([]float64{1, 2, 3, 4}), tf64.WithShape(2, 2))
+	cl := p.clone(src)
+	assert.Equal(src.Data(), cl.Data())
+	assert.True(&src.Data().([]float64)[0] != &cl.Data().([]float64)[0])
+
+	// a nil pool allocates
+	var nilPool *bufferPool
+	e := nilPool.borrow(Float32, types.Shape{2, 2})
+	assert.Equal(make([]float32, 4), e.Data())
+	nilPool.put(e)
+
+	// the pool of a graph
+	g := NewGraph()
+	assert.True(PoolEnabled())
+	assert.NotNil(g.tempPool())
+	SetPoolEnabled(false)
+	assert.False(PoolEnabled())
+	assert.Nil(g.tempPool())
+	SetPoolEnabled(true)
+	assert.NotNil(g.tempPool())
+
+	// the gradients are the same either way. The first run fills the pool, and the second draws from it
+	grads := func() (dx, dw []float64) {
+		g := NewGraph()
+		x := NewMatrix(g, Float64, WithName("x"), WithShape(2, 3), WithValue(tf64.NewTensor(tf64.WithBacking([]float64{1, 2, 3, 4, 5, 6}), tf64.WithShape(2, 3))))
+		w := NewMatrix(g, Float64, WithName("w"), WithShape(3, 2), WithValue(tf64.NewTensor(tf64.WithBacking([]float64{-1, 0.5, 2, 1, 0, 3}), tf64.WithShape(3, 2))))
+		Must(Sum(Must(Mean(Must(Mul(x, w)), 1))))
+
+		for i := 0; i < 2; i++ {
+			if err := NewLispMachine(g).RunAll(); err != nil {
+				t.Fatal(err)
+			}
+			xg, _ := x.Grad()
+			wg, _ := w.Grad()
+			dx, dw = clonef64s(extractF64s(xg)), clonef64s(extractF64s(wg))
+		}
+		return
+	}
+	dx, dw := grads()
+	SetPoolEnabled(false)
+	dx2, dw2 := grads()
+	SetPoolEnabled(true)
+	assert.Equal(dx2, dx)
+	assert.Equal(dw2, dw)
+}
+
+func BenchmarkBackward_Pooled(b *testing.B)   { benchmarkBackward(b, true) }
+func BenchmarkBackward_Unpooled(b *testing.B) { benchmarkBackward(b, false) }
+
+// benchmarkBackward runs the forward and backward passes of Σ mean(x × w × v) along the rows
+func benchmarkBackward(b *testing.B, pooled bool) {
+	SetPoolEnabled(pooled)
+	defer SetPoolEnabled(true)
+
+	g := NewGraph()
+	x := NewMatrix(g, Float64, WithName("x"), WithShape(64, 128), WithInit(Gaussian(0, 1)))
+	w := NewMatrix(g, Float64, WithName("w"), WithShape(128, 128), WithInit(Gaussian(0, 1)))
+	v := NewMatrix(g, Float64, WithName("v"), WithShape(128, 32), WithInit(Gaussian(0, 1)))
+
+	h := Must(Mul(Must(Mul(x, w)), v))
+	Must(Sum(Must(Mean(h, 1))))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := NewLispMachine(g).RunAll(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
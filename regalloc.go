@@ -208,8 +208,9 @@ func buildIntervals(sorted Nodes) map[*Node]*interval {
 		instrNum := instructions - 1 - i
 		nInter := intervals[n]
 
-		// inputs will be live the entire program
-		if n.isInput() {
+		// inputs will be live the entire program. So will constants: a constant tensor hands out its own value, which must not be overwritten,
+		// or the constant would hold something else the next time the program is run
+		if n.isInput() || n.isConstant() {
 			nInter.addRange(instrNum, instructions)
 			continue
 		}
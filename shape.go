@@ -7,6 +7,16 @@ var scalarShape = types.ScalarShape()
 type axes []int
 type coordinates []int
 
+// contains checks if the axis is one of the axes
+func (a axes) contains(axis int) bool {
+	for _, v := range a {
+		if v == axis {
+			return true
+		}
+	}
+	return false
+}
+
 // only works for 2D
 func transpose(shape types.Shape) types.Shape {
 	if len(shape) != 2 {
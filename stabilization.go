@@ -54,6 +54,11 @@ func logStabilization(a *Node) (retVal *Node, err error) {
 		return a, noStabilizationErr{}
 	}
 
+	// neither input of the addition is 1
+	if x == nil {
+		return a, noStabilizationErr{}
+	}
+
 	g := a.g
 	g.removeAllEdgesFrom(a) // remove all references
 	g.RemoveNode(a)
@@ -82,6 +82,25 @@ func Inner(a, b types.Tensor) (retVal types.Tensor, err error) {
 	return
 }
 
+func BatchedInner(a, b types.Tensor, opts ...types.FuncOpt) (retVal types.Tensor, err error) {
+	if a.Dtype() != b.Dtype() {
+		err = types.DtypeMismatchErr(a.Dtype(), b.Dtype())
+		return
+	}
+
+	switch at := a.(type) {
+	case *tf64.Tensor:
+		bt := b.(*tf64.Tensor)
+		return at.BatchedInner(bt, opts...)
+	case *tf32.Tensor:
+		bt := b.(*tf32.Tensor)
+		return at.BatchedInner(bt, opts...)
+	default:
+		err = types.NewError(types.NotYetImplemented, "BatchedInner() does not handle Tensor of %T yet", a)
+	}
+	return
+}
+
 func Outer(a, b types.Tensor, opts ...types.FuncOpt) (retVal types.Tensor, err error) {
 	if a.Dtype() != b.Dtype() {
 		err = types.DtypeMismatchErr(a.Dtype(), b.Dtype())
@@ -65,6 +65,68 @@ func (t *Tensor) inner(other *Tensor) (retVal *Tensor, err error) {
 	return
 }
 
+// BatchedInner performs a dot product on each pair of rows of two matrices of the same shape: given two (batch, k) matrices, it returns a vector of size batch.
+// It takes an optional reuse Tensor, where the Tensor is reused as the result, and an optional incr Tensor, which the result is added to.
+func (t *Tensor) BatchedInner(other *Tensor, opts ...types.FuncOpt) (retVal *Tensor, err error) {
+	if !t.IsMatrix() || !other.IsMatrix() {
+		err = types.NewError(types.OpError, "BatchedInner only works when there are two matrices. t has %v; other has %v", t.Shape(), other.Shape())
+		return
+	}
+
+	if !t.Shape().Eq(other.Shape()) {
+		err = shapeMismatchError(t.Shape(), other.Shape())
+		return
+	}
+
+	batch := t.Shape()[0]
+	expectedShape := types.Shape{batch}
+
+	reuse, incr := parseReuseIncr(opts...)
+	if reuse != nil {
+		if err = reuseCheckShape(reuse, expectedShape); err != nil {
+			return
+		}
+		retVal = reuse
+	}
+
+	if retVal == nil {
+		retVal = newBorrowedTensor(batch, WithShape(expectedShape...))
+	}
+
+	t.batchedInner(other, retVal)
+
+	// handle increments
+	if incr != nil {
+		if !expectedShape.Eq(incr.Shape()) {
+			err = shapeMismatchError(expectedShape, incr.Shape())
+			return
+		}
+		vecAdd(incr.data, retVal.data)
+
+		// return retVal to pool - if and only if retVal is not reuse
+		// reuse indicates that someone else also has the reference to the *Tensor
+		if retVal != reuse {
+			ReturnTensor(retVal)
+		}
+
+		// then
+		retVal = incr
+	}
+	return
+}
+
+// batchedInner calls BLAS' Sdot once per row. The strides are used so that transposed matrices do not have to be materialized
+func (t *Tensor) batchedInner(other, retVal *Tensor) {
+	batch := t.Shape()[0]
+	k := t.Shape()[1]
+
+	ts := t.Strides()
+	ots := other.Strides()
+	for i := 0; i < batch; i++ {
+		retVal.data[i] = whichblas.Sdot(k, t.data[i*ts[0]:], ts[1], other.data[i*ots[0]:], ots[1])
+	}
+}
+
 // MatVecMul multiplies a matrix and a vector together. t must be a Matrix, and other must be a vector otherwise it will error out
 func (t *Tensor) MatVecMul(other *Tensor, opts ...types.FuncOpt) (retVal *Tensor, err error) {
 	// check that it's a matrix x vector
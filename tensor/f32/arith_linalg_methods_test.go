@@ -502,6 +502,63 @@ func TestTOuter(t *testing.T) {
 
 }
 
+func TestTBatchedInner(t *testing.T) {
+	assert := assert.New(t)
+	var a, b, R, R1, incr *Tensor
+	var err error
+
+	// standard test
+	a = NewTensor(WithShape(3, 2), WithBacking(RangeFloat32(0, 6)))
+	b = NewTensor(WithShape(3, 2), WithBacking(RangeFloat32(1, 7)))
+	R, err = a.BatchedInner(b)
+	assert.Nil(err)
+	assert.Equal([]float32{2, 18, 50}, R.data)
+	assert.Equal(types.Shape{3}, R.Shape())
+
+	// with reuse
+	R = NewTensor(WithShape(3))
+	R1, err = a.BatchedInner(b, types.WithReuse(R))
+	assert.Nil(err)
+	if R != R1 {
+		t.Error("reuse is not returned")
+	}
+	assert.Equal([]float32{2, 18, 50}, R.data)
+
+	// with incr
+	incr = NewTensor(WithShape(3), WithBacking([]float32{100, 200, 300}))
+	R, err = a.BatchedInner(b, types.WithIncr(incr))
+	assert.Nil(err)
+	if R != incr {
+		t.Error("incr not returned")
+	}
+	assert.Equal([]float32{102, 218, 350}, R.data)
+
+	// transposed
+	a = NewTensor(WithShape(2, 3), WithBacking([]float32{0, 2, 4, 1, 3, 5}))
+	a.T()
+	R, err = a.BatchedInner(b)
+	assert.Nil(err)
+	assert.Equal([]float32{2, 18, 50}, R.data)
+
+	/* ONLY IDIOTS DO THESE */
+
+	// mismatched shapes
+	a = NewTensor(WithShape(3, 2), WithBacking(RangeFloat32(0, 6)))
+	b = NewTensor(WithShape(2, 3), WithBacking(RangeFloat32(0, 6)))
+	_, err = a.BatchedInner(b)
+	assert.NotNil(err)
+
+	// wrong reuse size
+	R = NewTensor(WithShape(2))
+	_, err = a.BatchedInner(a, types.WithReuse(R))
+	assert.NotNil(err)
+
+	// not matrices
+	a = NewTensor(WithShape(6), WithBacking(RangeFloat32(0, 6)))
+	_, err = a.BatchedInner(a)
+	assert.NotNil(err)
+}
+
 func TestTouter(t *testing.T) {
 	assert := assert.New(t)
 	var a, b, R *Tensor
@@ -1,6 +1,11 @@
 package gorgonia
 
-import "fmt"
+import (
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/fnv"
+)
 
 // Type represents the type of data that a value, or a variable contains. There is a type-analysis
 // phase that happens in the graph as the graph is compiled.
@@ -287,6 +292,54 @@ func typeEq(a, b Type) bool {
 	return false
 }
 
+// these tag the kind of type being hashed by writeTypeHash
+const (
+	noTypeTag byte = iota
+	dtypeTag
+	tensorTypeTag
+	typeVariableTag
+	functionTypeTag
+)
+
+// writeTypeHash writes the structure of a type into a hash: a tag for the kind of type, followed by its parts
+// (the dims and the type of the elements of a TensorType, for example). Unlike hashing the String() of a type,
+// types that print the same, such as the Dtype float64 and a type variable named "float64", hash differently.
+//
+// Like typeEq, the shape of a TensorType is not part of its hash, and a type variable that has been instantiated hashes as its instance.
+func writeTypeHash(h hash.Hash, t Type) {
+	switch tt := prune(t).(type) {
+	case nil:
+		h.Write([]byte{noTypeTag})
+	case Dtype:
+		h.Write([]byte{dtypeTag, byte(tt)})
+	case *TensorType:
+		h.Write([]byte{tensorTypeTag})
+		if err := binary.Write(h, binary.LittleEndian, int64(tt.d)); err != nil {
+			panic(err)
+		}
+		writeTypeHash(h, tt.of)
+	case *typeVariable:
+		h.Write([]byte{typeVariableTag})
+		if err := binary.Write(h, binary.LittleEndian, int64(len(tt.name))); err != nil {
+			panic(err)
+		}
+		h.Write([]byte(tt.name))
+	case *functionType:
+		h.Write([]byte{functionTypeTag})
+		writeTypeHash(h, tt.ts[0])
+		writeTypeHash(h, tt.ts[1])
+	default:
+		panic(fmt.Sprintf("Unable to hash type %v of %T", t, t))
+	}
+}
+
+// typeHash is the hash of the structure of a type. See writeTypeHash.
+func typeHash(t Type) uint32 {
+	h := fnv.New32a()
+	writeTypeHash(h, t)
+	return h.Sum32()
+}
+
 func dtypeOf(t Type) (retVal Dtype, err error) {
 	pruned := prune(t)
 	switch p := pruned.(type) {
@@ -17,6 +17,7 @@ func (tc *simpleTC) addInstance(t Type) {
 var arithable *simpleTC
 var floats *simpleTC
 var summable *simpleTC
+var logicals *simpleTC
 var scalarOrTensor *simpleTC
 
 func init() {
@@ -38,6 +39,11 @@ func init() {
 	summable.addInstance(Float64)
 	summable.addInstance(Float32)
 
+	logicals = new(simpleTC)
+	logicals.addInstance(Bool)
+	logicals.addInstance(Float64)
+	logicals.addInstance(Float32)
+
 	scalarOrTensor = new(simpleTC)
 	scalarOrTensor.addInstance(Float64)
 	scalarOrTensor.addInstance(Float32)
@@ -29,14 +29,25 @@ func nodeToGraphNode(in []*Node) (out []graph.Node) {
 	return
 }
 
+// the tensor package has no Byte type, so the Dtypes only line up until Int32
 func dtypeToDtype(t types.Dtype) Dtype {
 	if t >= types.MAXDTYPE || Dtype(t) >= Ptr {
 		panic("Unsupported Dtype")
 	}
+	if t == types.Bool {
+		return Bool
+	}
 	return Dtype(t)
 }
 
 func dtypeToTensorDtype(t Dtype) types.Dtype {
+	switch t {
+	case Bool:
+		return types.Bool
+	case Byte:
+		panic("Unsupported Dtype")
+	}
+
 	if t >= Ptr || types.Dtype(t) >= types.MAXDTYPE {
 		panic("Unsupported Dtype")
 	}
@@ -95,6 +106,40 @@ func valuesToInts(values []Value) (retVal []int, err error) {
 	return
 }
 
+func intsContain(a []int, want int) bool {
+	for _, v := range a {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
+
+func anyTrue(a []bool) bool {
+	for _, v := range a {
+		if v {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeAxes counts the negative axes from the end, NumPy style, so that -1 is the last of the dims axes. The axes are copied.
+// It returns an error if any of the axes is out of range.
+func normalizeAxes(along []int, dims int) (retVal []int, err error) {
+	retVal = make([]int, len(along))
+	for i, a := range along {
+		if a < 0 {
+			a += dims
+		}
+		if a < 0 || a >= dims {
+			return nil, errors.Errorf("Axis %d is out of range for a tensor with %d dims", along[i], dims)
+		}
+		retVal[i] = a
+	}
+	return
+}
+
 func intRange(start, end int) []int {
 	size := end - start
 	incr := true
@@ -209,7 +254,7 @@ func hasNaN(v Value) bool {
 			}
 			return false
 		case Float32:
-			T := vt.Tensor.(*tf64.Tensor)
+			T := vt.Tensor.(*tf32.Tensor)
 			data := T.Data().([]float32)
 			for _, datum := range data {
 				if math32.IsNaN(datum) {
@@ -206,7 +206,14 @@ func (m *lispMachine) forward() (err error) {
 	m.watchedLogf("After:")
 	m.watchedLogf(m.valueFmt, n.boundTo)
 
-	if aop, ok := op.(AdOp); ok && m.runBwd() {
+	if CheckNumerics() && !n.isStmt {
+		if err = checkOutput(op, n, n.boundTo); err != nil {
+			return
+		}
+	}
+
+	// ops that are not differentiable with regards to any of their inputs, like the comparison ops, have nothing to backpropagate
+	if aop, ok := op.(AdOp); ok && m.runBwd() && anyTrue(op.DiffWRT(len(n.children))) {
 		instr := adInstr{
 			AdOp: aop,
 
@@ -247,6 +254,16 @@ func (m *lispMachine) backward() (err error) {
 		return errors.Wrapf(err, autodiffFail, instr.AdOp)
 	}
 
+	// constants take no gradient. Whatever the op accumulated into them is thrown away
+	for _, in := range instr.inputs {
+		if !in.isConstant() {
+			continue
+		}
+		if dt, ok := in.boundTo.(*dualValue).d.(Tensor); ok {
+			dt.Tensor.Zero()
+		}
+	}
+
 	m.watchedLogf("After:")
 	m.enterLoggingContext()
 	for _, in := range instr.inputs {
@@ -255,6 +272,14 @@ func (m *lispMachine) backward() (err error) {
 
 	m.leaveLoggingContext()
 
+	if CheckNumerics() {
+		for _, in := range instr.inputs {
+			if err = checkGrad(instr.AdOp, instr.output, in, in.boundTo.(*dualValue).d); err != nil {
+				return
+			}
+		}
+	}
+
 	if m.watchNaN() {
 		if hasNaN(instr.output.boundTo) {
 			return errors.New("NaN found in value")
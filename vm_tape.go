@@ -39,7 +39,7 @@ func NewTapeMachine(prog *program, locMap map[*Node]register, opts ...VMOpt) *ta
 		valueFmt: "%3.3f",
 	}
 
-	if b, ok := whichblas.(batchedBLAS); ok {
+	if b, ok := WhichBLAS().(batchedBLAS); ok {
 		m.b = b
 	}
 
@@ -546,10 +546,16 @@ func (instr execOp) exec(m *tapeMachine) (err error) {
 	m.leaveLoggingContext()
 	// TODO: type and shape checks
 
+	node := m.p.g.Node(instr.id).(*Node)
+	if CheckNumerics() {
+		if err = checkOutput(instr.op, node, v); err != nil {
+			return
+		}
+	}
+
 	// Write
 	dest := instr.writeTo.id
 	m.storage[dest] = v
-	node := m.p.g.Node(instr.id).(*Node)
 
 	if m.trace() {
 		var cloned Value
@@ -2,12 +2,12 @@ package gorgonia
 
 import (
 	"math"
-	"time"
 
 	tf32 "github.com/chewxy/gorgonia/tensor/f32"
 	tf64 "github.com/chewxy/gorgonia/tensor/f64"
 	"github.com/chewxy/gorgonia/tensor/types"
 	"github.com/leesper/go_rng"
+	"github.com/pkg/errors"
 )
 
 // This file provides several weight initialization utility functions.
@@ -129,7 +129,7 @@ func GlorotU(gain float64) InitWFn {
 func Gaussian64(mean, stdev float64, s ...int) []float64 {
 	size := types.Shape(s).TotalSize()
 
-	rand := rng.NewGaussianGenerator(time.Now().UnixNano())
+	rand := rng.NewGaussianGenerator(randomSeed())
 	retVal := make([]float64, size)
 	for i := range retVal {
 		retVal[i] = rand.Gaussian(mean, stdev)
@@ -141,7 +141,7 @@ func Gaussian64(mean, stdev float64, s ...int) []float64 {
 func Gaussian32(mean, stdev float64, s ...int) []float32 {
 	size := types.Shape(s).TotalSize()
 
-	rand := rng.NewGaussianGenerator(time.Now().UnixNano())
+	rand := rng.NewGaussianGenerator(randomSeed())
 	retVal := make([]float32, size)
 	for i := range retVal {
 		retVal[i] = float32(rand.Gaussian(mean, stdev))
@@ -153,7 +153,7 @@ func Gaussian32(mean, stdev float64, s ...int) []float32 {
 func Uniform64(low, high float64, s ...int) []float64 {
 	size := types.Shape(s).TotalSize()
 
-	rand := rng.NewUniformGenerator(time.Now().UnixNano())
+	rand := rng.NewUniformGenerator(randomSeed())
 	retVal := make([]float64, size)
 	for i := range retVal {
 		retVal[i] = rand.Float64Range(low, high)
@@ -167,7 +167,7 @@ func Uniform32(low, high float64, s ...int) []float32 {
 	l := float32(low)
 	h := float32(high)
 
-	rand := rng.NewUniformGenerator(time.Now().UnixNano())
+	rand := rng.NewUniformGenerator(randomSeed())
 	retVal := make([]float32, size)
 	for i := range retVal {
 		retVal[i] = rand.Float32Range(l, h)
@@ -179,7 +179,7 @@ func Binomial64(trials, prob float64, s ...int) []float64 {
 	size := types.Shape(s).TotalSize()
 	t := int64(trials)
 
-	rand := rng.NewBinomialGenerator(time.Now().UnixNano())
+	rand := rng.NewBinomialGenerator(randomSeed())
 	retVal := make([]float64, size)
 	for i := range retVal {
 		retVal[i] = float64(rand.Binomial(t, prob))
@@ -191,7 +191,7 @@ func Binomial32(trials, prob float64, s ...int) []float32 {
 	size := types.Shape(s).TotalSize()
 	t := int64(trials)
 
-	rand := rng.NewBinomialGenerator(time.Now().UnixNano())
+	rand := rng.NewBinomialGenerator(randomSeed())
 	retVal := make([]float32, size)
 	for i := range retVal {
 		retVal[i] = float32(rand.Binomial(t, prob))
@@ -219,7 +219,7 @@ func GlorotEtAlN64(gain float64, s ...int) []float64 {
 
 	stdev := gain * math.Sqrt(2.0/fanIn)
 
-	rand := rng.NewGaussianGenerator(time.Now().UnixNano())
+	rand := rng.NewGaussianGenerator(randomSeed())
 	retVal := make([]float64, size)
 	for i := range retVal {
 		retVal[i] = rand.Gaussian(0.0, stdev)
@@ -261,7 +261,7 @@ func GlorotEtAlU64(gain float64, s ...int) []float64 {
 	lo := 0.0 - math.Sqrt(3.0)*stdev
 	hi := 0.0 + math.Sqrt(3.0)*stdev
 
-	rand := rng.NewUniformGenerator(time.Now().UnixNano())
+	rand := rng.NewUniformGenerator(randomSeed())
 	retVal := make([]float64, size)
 	for i := range retVal {
 		retVal[i] = rand.Float64Range(lo, hi)
@@ -303,7 +303,7 @@ func HeEtAlN64(gain float64, s ...int) []float64 {
 	size := types.Shape(s).TotalSize()
 	stdev := gain * math.Sqrt(1.0/fanIn)
 
-	rand := rng.NewGaussianGenerator(time.Now().UnixNano())
+	rand := rng.NewGaussianGenerator(randomSeed())
 	retVal := make([]float64, size)
 	for i := range retVal {
 		retVal[i] = rand.Gaussian(0.0, stdev)
@@ -339,10 +339,95 @@ func HeEtAlU64(gain float64, s ...int) []float64 {
 	lo := 0.0 - math.Sqrt(3.0)*stdev
 	hi := 0.0 + math.Sqrt(3.0)*stdev
 
-	rand := rng.NewUniformGenerator(time.Now().UnixNano())
+	rand := rng.NewUniformGenerator(randomSeed())
 	retVal := make([]float64, size)
 	for i := range retVal {
 		retVal[i] = rand.Float64Range(lo, hi)
 	}
 	return retVal
 }
+
+/* SEEDED INITIALIZERS */
+
+// GlorotUniform creates a variable shaped s, whose values are drawn from the uniform distribution of Glorot et al:
+//		U(-limit, limit), where limit = √(6 / (fanIn + fanOut))
+// which has a variance of 2 / (fanIn + fanOut). The values are drawn from a generator seeded with seed, so the same seed always gives the same values,
+// whether or not execution is deterministic. See fans for how the fan in and fan out of a shape are found.
+//
+// Example Usage:
+//		w := Must(GlorotUniform(g, Float64, types.Shape{784, 100}, 42, WithName("w")))
+func GlorotUniform(g *ExprGraph, dt Dtype, s types.Shape, seed int64, opts ...NodeConsOpt) (retVal *Node, err error) {
+	return seededWeights(g, dt, s, seed, false, glorotVariance, "GlorotUniform", opts)
+}
+
+// GlorotNormal creates a variable shaped s, whose values are drawn from the normal distribution of Glorot et al:
+//		N(0, 2 / (fanIn + fanOut))
+// from a generator seeded with seed. See GlorotUniform.
+func GlorotNormal(g *ExprGraph, dt Dtype, s types.Shape, seed int64, opts ...NodeConsOpt) (retVal *Node, err error) {
+	return seededWeights(g, dt, s, seed, true, glorotVariance, "GlorotNormal", opts)
+}
+
+// HeUniform creates a variable shaped s, whose values are drawn from the uniform distribution of He et al:
+//		U(-limit, limit), where limit = √(6 / fanIn)
+// which has a variance of 2 / fanIn, from a generator seeded with seed. It suits weights followed by ReLUs. See GlorotUniform.
+func HeUniform(g *ExprGraph, dt Dtype, s types.Shape, seed int64, opts ...NodeConsOpt) (retVal *Node, err error) {
+	return seededWeights(g, dt, s, seed, false, heVariance, "HeUniform", opts)
+}
+
+// HeNormal creates a variable shaped s, whose values are drawn from the normal distribution of He et al:
+//		N(0, 2 / fanIn)
+// from a generator seeded with seed. It suits weights followed by ReLUs. See GlorotUniform.
+func HeNormal(g *ExprGraph, dt Dtype, s types.Shape, seed int64, opts ...NodeConsOpt) (retVal *Node, err error) {
+	return seededWeights(g, dt, s, seed, true, heVariance, "HeNormal", opts)
+}
+
+func glorotVariance(fanIn, fanOut float64) float64 { return 2 / (fanIn + fanOut) }
+func heVariance(fanIn, _ float64) float64          { return 2 / fanIn }
+
+// fans returns the fan in and the fan out of weights shaped s. Matrices are taken to be (in, out), as they are when inputs are multiplied by them,
+// and 4D tensors to be convolution kernels shaped (out channels, in channels, kernel height, kernel width).
+func fans(s types.Shape) (fanIn, fanOut float64, err error) {
+	switch len(s) {
+	case 2:
+		return float64(s[0]), float64(s[1]), nil
+	case 4:
+		field := float64(s[2] * s[3])
+		return float64(s[1]) * field, float64(s[0]) * field, nil
+	}
+	return 0, 0, errors.Errorf("Expected the shape of a matrix or of convolution kernels. Got %v instead", s)
+}
+
+// seededWeights creates a variable shaped s, whose values have a mean of 0 and the variance for the fans of s. They are drawn from a generator
+// seeded with seed, from a normal distribution if normal is set, and from a uniform distribution otherwise.
+func seededWeights(g *ExprGraph, dt Dtype, s types.Shape, seed int64, normal bool, variance func(fanIn, fanOut float64) float64, fn string, opts []NodeConsOpt) (retVal *Node, err error) {
+	if dt != Float64 && dt != Float32 {
+		return nil, errors.Errorf(nyiFail, fn, dt)
+	}
+
+	var fanIn, fanOut float64
+	if fanIn, fanOut, err = fans(s); err != nil {
+		return nil, errors.Wrap(err, fn)
+	}
+	if fanIn == 0 || fanOut == 0 {
+		return nil, errors.Errorf("%v: Cannot initialize weights shaped %v, which have no fan in or no fan out", fn, s)
+	}
+
+	stdev := math.Sqrt(variance(fanIn, fanOut))
+	xs := make([]float64, s.TotalSize())
+	if normal {
+		rand := rng.NewGaussianGenerator(seed)
+		for i := range xs {
+			xs[i] = rand.Gaussian(0, stdev)
+		}
+	} else {
+		// the variance of U(-limit, limit) is limit²/3
+		limit := math.Sqrt(3) * stdev
+		rand := rng.NewUniformGenerator(seed)
+		for i := range xs {
+			xs[i] = rand.Float64Range(-limit, limit)
+		}
+	}
+
+	consOpts := append([]NodeConsOpt{WithShape(s...), WithValue(floatsValue(xs, s, dt))}, opts...)
+	return NewTensor(g, dt, len(s), consOpts...), nil
+}
@@ -0,0 +1,103 @@
+package gorgonia
+
+import (
+	"math"
+	"testing"
+
+	"github.com/chewxy/gorgonia/tensor/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeededInitializers(t *testing.T) {
+	assert := assert.New(t)
+
+	type initializer func(g *ExprGraph, dt Dtype, s types.Shape, seed int64, opts ...NodeConsOpt) (*Node, error)
+	cases := []struct {
+		name     string
+		init     initializer
+		variance func(fanIn, fanOut float64) float64
+	}{
+		{"GlorotUniform", GlorotUniform, func(fanIn, fanOut float64) float64 { return 2 / (fanIn + fanOut) }},
+		{"GlorotNormal", GlorotNormal, func(fanIn, fanOut float64) float64 { return 2 / (fanIn + fanOut) }},
+		{"HeUniform", HeUniform, func(fanIn, _ float64) float64 { return 2 / fanIn }},
+		{"HeNormal", HeNormal, func(fanIn, _ float64) float64 { return 2 / fanIn }},
+	}
+
+	shapes := []struct {
+		shape         types.Shape
+		fanIn, fanOut float64
+	}{
+		{types.Shape{300, 200}, 300, 200},
+		{types.Shape{64, 32, 3, 3}, 32 * 9, 64 * 9},
+	}
+
+	for _, c := range cases {
+		for _, s := range shapes {
+			g := NewGraph()
+			w, err := c.init(g, Float64, s.shape, 42, WithName("w"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal("w", w.name)
+			assert.Equal(s.shape, w.Shape())
+
+			xs := extractF64s(w.Value())
+			var mean, variance float64
+			for _, x := range xs {
+				mean += x
+			}
+			mean /= float64(len(xs))
+			for _, x := range xs {
+				variance += (x - mean) * (x - mean)
+			}
+			variance /= float64(len(xs))
+
+			correct := c.variance(s.fanIn, s.fanOut)
+			assert.True(math.Abs(variance-correct) < 0.05*correct, "%v %v: expected a variance of %v. Got %v", c.name, s.shape, correct, variance)
+			assert.True(math.Abs(mean) < 0.05*math.Sqrt(correct), "%v %v: expected a mean of 0. Got %v", c.name, s.shape, mean)
+
+			// the same seed gives the same values, and another seed other values
+			same := Must(c.init(g, Float64, s.shape, 42, WithName("same")))
+			assert.Equal(xs, extractF64s(same.Value()), "%v %v", c.name, s.shape)
+			other := Must(c.init(g, Float64, s.shape, 43, WithName("other")))
+			assert.NotEqual(xs, extractF64s(other.Value()), "%v %v", c.name, s.shape)
+
+			// as Float32
+			w32 := Must(c.init(g, Float32, s.shape, 42, WithName("w32")))
+			assert.Equal(f64sToF32s(xs), w32.Value().Data(), "%v %v", c.name, s.shape)
+		}
+	}
+
+	// the weights are variables, which are learnt like any other
+	g := NewGraph()
+	w := Must(HeNormal(g, Float64, types.Shape{3, 2}, 7, WithName("w")))
+	assert.True(w.isInput())
+	x := NewMatrix(g, Float64, WithName("x"), WithShape(4, 3), WithInit(RangedFrom(0)))
+	cost := Must(Sum(Must(Mul(x, w))))
+	if _, err := Grad(cost, w); err != nil {
+		t.Fatal(err)
+	}
+	prog, locMap, err := Compile(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = NewTapeMachine(prog, locMap).RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	dw, err := w.Grad()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ∂w is xᵀ · 1, whose rows are the column sums of x
+	assert.Equal([]float64{18, 18, 22, 22, 26, 26}, extractF64s(dw))
+
+	if _, err = GlorotUniform(g, Float64, types.Shape{3}, 42); err == nil {
+		t.Error("Expected an error with a vector")
+	}
+	if _, err = GlorotUniform(g, Float64, types.Shape{2, 3, 4}, 42); err == nil {
+		t.Error("Expected an error with a 3D shape")
+	}
+	if _, err = HeNormal(g, Int, types.Shape{2, 3}, 42); err == nil {
+		t.Error("Expected an error with Ints")
+	}
+}